@@ -1,23 +1,46 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/cookies"
+	"github.com/surge-downloader/surge/internal/engine/netrc"
+	"github.com/surge-downloader/surge/internal/engine/stream"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/extract"
+	"github.com/surge-downloader/surge/internal/utils"
 )
 
 var addCmd = &cobra.Command{
 	Use:     "add [url]...",
 	Aliases: []string{"get"},
 	Short:   "Add a new download to the running Surge instance",
-	Long:    `Add one or more URLs to the download queue of a running Surge instance.`,
+	Long:    `Add one or more URLs, magnet links, .torrent files or Metalink documents (.meta4/.metalink files or URLs) to the download queue of a running Surge instance.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Initialize Global State (needed for config/paths)
 		initializeGlobalState()
 
 		batchFile, _ := cmd.Flags().GetString("batch")
 		output, _ := cmd.Flags().GetString("output")
+		expectedChecksum, _ := cmd.Flags().GetString("checksum")
+		at, _ := cmd.Flags().GetString("at")
+		window, _ := cmd.Flags().GetString("window")
+		limit, _ := cmd.Flags().GetString("limit")
+		extractFlag, _ := cmd.Flags().GetString("extract")
+		variant, _ := cmd.Flags().GetString("variant")
+		priorityFlag, _ := cmd.Flags().GetString("priority")
+		category, _ := cmd.Flags().GetString("category")
+		cookiesFile, _ := cmd.Flags().GetString("cookies")
+		headerFlags, _ := cmd.Flags().GetStringArray("header")
+		user, _ := cmd.Flags().GetString("user")
+		password, _ := cmd.Flags().GetString("password")
 
 		// Collect URLs
 		var urls []string
@@ -40,6 +63,91 @@ var addCmd = &cobra.Command{
 			return
 		}
 
+		// A checksum identifies exactly one file
+		if expectedChecksum != "" {
+			if len(urls) > 1 {
+				fmt.Fprintln(os.Stderr, "Error: --checksum can only be used with a single URL")
+				os.Exit(1)
+			}
+			if _, err := checksum.Parse(expectedChecksum); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid checksum: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		startAt, err := download.ParseStartAt(at, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if window != "" {
+			if _, err := download.ParseWindow(window); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		var speedLimit int64
+		if limit != "" {
+			if speedLimit, err = utils.ParseBytes(limit); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid limit: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		extractMode, err := extract.ParseMode(extractFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := stream.ParseSelector(variant); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		priority, err := download.ParsePriority(priorityFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Cookies are checked here so a bad file fails before anything is queued
+		var cookieData string
+		if cookiesFile != "" {
+			data, err := os.ReadFile(cookiesFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading cookies file: %v\n", err)
+				os.Exit(1)
+			}
+			if _, err := cookies.Parse(bytes.NewReader(data)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid cookies file: %v\n", err)
+				os.Exit(1)
+			}
+			cookieData = string(data)
+		}
+
+		headers, err := parseHeaders(headerFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, ok := headers["Authorization"]; user != "" && !ok {
+			// curl-style user:password, otherwise ask
+			if name, pass, ok := strings.Cut(user, ":"); ok && password == "" {
+				user, password = name, pass
+			} else if password == "" {
+				if password, err = promptPassword(user); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers["Authorization"] = netrc.BasicAuth(user, password)
+		} else if password != "" && user == "" {
+			fmt.Fprintln(os.Stderr, "Error: --password requires --user")
+			os.Exit(1)
+		}
+
 		// Check if Surge is running
 		port := readActivePort()
 		if port == 0 {
@@ -49,7 +157,18 @@ var addCmd = &cobra.Command{
 		}
 
 		// Send downloads to server
-		count := processDownloads(urls, output, port)
+		count := processDownloads(urls, output, port, types.DownloadOptions{
+			Checksum:   expectedChecksum,
+			StartAt:    startAt,
+			Window:     window,
+			SpeedLimit: speedLimit,
+			Extract:    extractMode,
+			Variant:    variant,
+			Priority:   priority,
+			Category:   category,
+			Cookies:    cookieData,
+			Headers:    headers,
+		})
 
 		if count > 0 {
 			fmt.Printf("Successfully added %d downloads.\n", count)
@@ -61,4 +180,17 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 	addCmd.Flags().StringP("batch", "b", "", "File containing URLs to download (one per line)")
 	addCmd.Flags().StringP("output", "o", "", "Output directory")
+	addCmd.Flags().String("checksum", "", "Expected digest to verify on completion (e.g. sha256:<hex>, sha512:<hex>, sha1:<hex>, md5:<hex>)")
+	addCmd.Flags().String("at", "", "Start the download at a given time (HH:MM, +duration, \"YYYY-MM-DD HH:MM\" or RFC 3339)")
+	addCmd.Flags().String("window", "", "Only download during this daily window (e.g. 01:00-07:00)")
+	addCmd.Flags().String("limit", "", "Cap this download's speed per second (e.g. 500K, 5MB)")
+	addCmd.Flags().String("extract", "", "Unpack the archive when it completes: keep, delete (remove the archive) or off")
+	addCmd.Flags().Lookup("extract").NoOptDefVal = extract.ModeKeep
+	addCmd.Flags().String("variant", "", "Rendition to fetch from HLS/DASH streams: best (default), worst or a maximum height like 720p")
+	addCmd.Flags().String("priority", "", "Queue priority: low, normal (default), high or a number; high pauses a lower-priority download if none can start")
+	addCmd.Flags().String("category", "", "Category from settings, instead of matching one by file type and host")
+	addCmd.Flags().StringArrayP("header", "H", nil, "Extra request header \"Key: Value\" (repeatable)")
+	addCmd.Flags().StringP("user", "u", "", "HTTP basic auth user, or user:password; prompts for the password if omitted")
+	addCmd.Flags().String("password", "", "Password for --user")
+	addCmd.Flags().String("cookies", "", "Netscape-format cookies.txt to import into Surge's cookie jar for this and later downloads")
 }
@@ -0,0 +1,706 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// apiPrefix is the root of the versioned REST API. The unversioned endpoints
+// (/download, /pause, /list...) stay for the browser extension.
+const apiPrefix = "/api/v1"
+
+// openAPIPath serves the OpenAPI document; it needs no token
+const openAPIPath = apiPrefix + "/openapi.json"
+
+// Machine-readable codes of /api/v1 error bodies
+const (
+	apiCodeBadRequest       = "bad_request"
+	apiCodeUnauthorized     = "unauthorized"
+	apiCodeForbidden        = "forbidden"
+	apiCodeNotFound         = "not_found"
+	apiCodeMethodNotAllowed = "method_not_allowed"
+	apiCodeConflict         = "conflict"
+	apiCodeApprovalRequired = "approval_required"
+	apiCodeUnavailable      = "service_unavailable"
+	apiCodeInternal         = "internal_error"
+)
+
+// Page sizes of the list endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// ErrorResponse is the body of every /api/v1 error
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an API error
+type ErrorDetail struct {
+	Code    string `json:"code"` // Stable identifier, e.g. "not_found"
+	Message string `json:"message"`
+}
+
+// DownloadList is a page of downloads
+type DownloadList struct {
+	Items  []types.DownloadStatus `json:"items"`
+	Total  int                    `json:"total"` // Matches before pagination
+	Offset int                    `json:"offset"`
+	Limit  int                    `json:"limit"`
+}
+
+// HistoryList is a page of completed downloads
+type HistoryList struct {
+	Items  []types.DownloadEntry `json:"items"`
+	Total  int                   `json:"total"` // Matches before pagination
+	Offset int                   `json:"offset"`
+	Limit  int                   `json:"limit"`
+}
+
+// DownloadCreated answers a POST to /downloads
+type DownloadCreated struct {
+	ID     string   `json:"id,omitempty"`
+	IDs    []string `json:"ids,omitempty"` // One per file when a Metalink document was posted
+	Status string   `json:"status"`        // "queued" or "pending_approval"
+}
+
+// DownloadPatch changes a download; omitted fields are left alone
+type DownloadPatch struct {
+	Status     *string `json:"status,omitempty"`      // "paused" or "downloading"
+	SpeedLimit *int64  `json:"speed_limit,omitempty"` // Bytes per second, 0 = unlimited
+	Move       *string `json:"move,omitempty"`        // Reorder a queued download: "top", "up" or "down"
+}
+
+// apiParam is a query or path parameter of an API operation
+type apiParam struct {
+	Name        string
+	In          string // "query" or "path"
+	Type        string // JSON schema type
+	Description string
+}
+
+// apiRoute is one operation of the API. The same table registers the handlers
+// and generates the OpenAPI document, so the two cannot drift apart.
+type apiRoute struct {
+	Method    string
+	Path      string // ServeMux pattern below apiPrefix
+	ID        string // OpenAPI operationId
+	Summary   string
+	Params    []apiParam
+	Body      any         // Example value of the JSON request body, nil for none
+	RawBodies []string    // Other media types accepted as the request body
+	Responses map[int]any // Example value of each success response body, nil for none
+	Errors    []int
+	Public    bool // Served without a bearer token
+	handler   http.HandlerFunc
+}
+
+// apiServer implements the /api/v1 endpoints on top of a DownloadService
+type apiServer struct {
+	defaultOutputDir string
+	service          core.DownloadService
+}
+
+var pageParams = []apiParam{
+	{Name: "offset", In: "query", Type: "integer", Description: "Number of matches to skip"},
+	{Name: "limit", In: "query", Type: "integer", Description: "Page size (default 50, at most 500)"},
+}
+
+var idParam = apiParam{Name: "id", In: "path", Type: "string", Description: "Download id"}
+
+func (a *apiServer) routes() []apiRoute {
+	return []apiRoute{
+		{
+			Method: http.MethodGet, Path: "/downloads", ID: "listDownloads",
+			Summary: "List active, queued and finished downloads",
+			Params: append([]apiParam{
+				{Name: "status", In: "query", Type: "string", Description: "Comma-separated statuses to include, e.g. downloading,paused"},
+				{Name: "q", In: "query", Type: "string", Description: "Case-insensitive substring of the filename or URL"},
+				{Name: "category", In: "query", Type: "string", Description: "Only downloads of this category"},
+			}, pageParams...),
+			Responses: map[int]any{http.StatusOK: DownloadList{}},
+			Errors:    []int{http.StatusBadRequest},
+			handler:   a.listDownloads,
+		},
+		{
+			Method: http.MethodPost, Path: "/downloads", ID: "createDownload",
+			Summary:   "Queue a download, or every file of a posted Metalink document",
+			Params:    []apiParam{{Name: "path", In: "query", Type: "string", Description: "Output directory for a Metalink document"}},
+			Body:      DownloadRequest{},
+			RawBodies: []string{"application/metalink4+xml"},
+			Responses: map[int]any{http.StatusCreated: DownloadCreated{}, http.StatusAccepted: DownloadCreated{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusConflict},
+			handler:   a.createDownload,
+		},
+		{
+			Method: http.MethodGet, Path: "/downloads/{id}", ID: "getDownload",
+			Summary:   "Get a download",
+			Params:    []apiParam{idParam},
+			Responses: map[int]any{http.StatusOK: types.DownloadStatus{}},
+			Errors:    []int{http.StatusNotFound},
+			handler:   a.getDownload,
+		},
+		{
+			Method: http.MethodPatch, Path: "/downloads/{id}", ID: "updateDownload",
+			Summary:   "Pause or resume a download, change its speed limit or move it in the queue",
+			Params:    []apiParam{idParam},
+			Body:      DownloadPatch{},
+			Responses: map[int]any{http.StatusOK: types.DownloadStatus{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+			handler:   a.updateDownload,
+		},
+		{
+			Method: http.MethodDelete, Path: "/downloads/{id}", ID: "deleteDownload",
+			Summary:   "Cancel a download and remove it with its partial file",
+			Params:    []apiParam{idParam},
+			Responses: map[int]any{http.StatusNoContent: nil},
+			Errors:    []int{http.StatusNotFound},
+			handler:   a.deleteDownload,
+		},
+		{
+			Method: http.MethodGet, Path: "/history", ID: "listHistory",
+			Summary: "List completed downloads",
+			Params: append([]apiParam{
+				{Name: "q", In: "query", Type: "string", Description: "Case-insensitive substring of the filename or URL"},
+				{Name: "since", In: "query", Type: "integer", Description: "Only downloads completed at or after this Unix time"},
+				{Name: "until", In: "query", Type: "integer", Description: "Only downloads completed before this Unix time"},
+			}, pageParams...),
+			Responses: map[int]any{http.StatusOK: HistoryList{}},
+			Errors:    []int{http.StatusBadRequest},
+			handler:   a.listHistory,
+		},
+		{
+			Method: http.MethodGet, Path: "/openapi.json", ID: "getOpenAPI",
+			Summary:   "This document",
+			Responses: map[int]any{http.StatusOK: nil},
+			Public:    true,
+			handler:   a.getOpenAPI,
+		},
+	}
+}
+
+// registerAPI adds the /api/v1 endpoints to mux
+func registerAPI(mux *http.ServeMux, defaultOutputDir string, service core.DownloadService) {
+	a := &apiServer{defaultOutputDir: defaultOutputDir, service: service}
+
+	// ServeMux answers wrong methods in plain text, so dispatch on the method here
+	byPath := make(map[string]map[string]http.HandlerFunc)
+	var paths []string
+	for _, route := range a.routes() {
+		if byPath[route.Path] == nil {
+			byPath[route.Path] = make(map[string]http.HandlerFunc)
+			paths = append(paths, route.Path)
+		}
+		byPath[route.Path][route.Method] = route.handler
+	}
+	for _, path := range paths {
+		methods := byPath[path]
+		allowed := make([]string, 0, len(methods))
+		for m := range methods {
+			allowed = append(allowed, m)
+		}
+		sort.Strings(allowed)
+		allow := strings.Join(allowed, ", ")
+
+		mux.HandleFunc(apiPrefix+path, func(w http.ResponseWriter, r *http.Request) {
+			handler, ok := methods[r.Method]
+			if !ok {
+				w.Header().Set("Allow", allow)
+				writeAPIError(w, http.StatusMethodNotAllowed, apiCodeMethodNotAllowed, "Method not allowed")
+				return
+			}
+			handler(w, r)
+		})
+	}
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, apiCodeNotFound, "No such endpoint")
+	})
+}
+
+func (a *apiServer) listDownloads(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	offset, limit, err := parsePage(query)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, err.Error())
+		return
+	}
+	if a.service == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, apiCodeUnavailable, "Service unavailable")
+		return
+	}
+	statuses, err := a.service.List()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, s := range strings.Split(query.Get("status"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			wanted[s] = true
+		}
+	}
+	search := strings.ToLower(query.Get("q"))
+	category := query.Get("category")
+	matches := make([]types.DownloadStatus, 0, len(statuses))
+	for _, s := range statuses {
+		if len(wanted) > 0 && !wanted[s.Status] {
+			continue
+		}
+		if category != "" && !strings.EqualFold(s.Category, category) {
+			continue
+		}
+		if !matchesSearch(search, s.Filename, s.URL) {
+			continue
+		}
+		matches = append(matches, s)
+	}
+
+	writeJSON(w, http.StatusOK, DownloadList{
+		Items:  paginate(matches, offset, limit),
+		Total:  len(matches),
+		Offset: offset,
+		Limit:  limit,
+	})
+}
+
+func (a *apiServer) createDownload(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			utils.Debug("Error closing body: %v", err)
+		}
+	}()
+
+	if download.IsMetalinkContentType(r.Header.Get("Content-Type")) {
+		ids, err := queueMetalink(r.Body, r.URL.Query().Get("path"), a.defaultOutputDir, a.service, auth.FromContext(r.Context()))
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, DownloadCreated{IDs: ids, Status: "queued"})
+		return
+	}
+
+	var req DownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	queued, err := queueDownload(req, a.defaultOutputDir, a.service, auth.FromContext(r.Context()))
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if queued.Pending {
+		writeJSON(w, http.StatusAccepted, DownloadCreated{ID: queued.ID, Status: "pending_approval"})
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/downloads/"+url.PathEscape(queued.ID))
+	writeJSON(w, http.StatusCreated, DownloadCreated{ID: queued.ID, Status: "queued"})
+}
+
+func (a *apiServer) getDownload(w http.ResponseWriter, r *http.Request) {
+	status, ok := a.lookup(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (a *apiServer) updateDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var patch DownloadPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if patch.Status == nil && patch.SpeedLimit == nil && patch.Move == nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, "Nothing to change: set status, speed_limit or move")
+		return
+	}
+	if patch.Status != nil && *patch.Status != "paused" && *patch.Status != "downloading" {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, `Invalid status: must be "paused" or "downloading"`)
+		return
+	}
+	if patch.SpeedLimit != nil && *patch.SpeedLimit < 0 {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, "Invalid speed_limit")
+		return
+	}
+	if patch.Move != nil && !validMoveDirection(*patch.Move) {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, `Invalid move: must be "top", "up" or "down"`)
+		return
+	}
+	if _, ok := a.lookup(w, id); !ok {
+		return
+	}
+
+	if patch.SpeedLimit != nil {
+		if err := a.service.SetSpeedLimit(id, *patch.SpeedLimit); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	if patch.Move != nil {
+		if err := a.service.Move(id, *patch.Move); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	if patch.Status != nil {
+		var err error
+		if *patch.Status == "paused" {
+			err = a.service.Pause(id)
+		} else {
+			err = a.service.Resume(id)
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+
+	status, ok := a.lookup(w, id)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (a *apiServer) deleteDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := a.lookup(w, id); !ok {
+		return
+	}
+	if err := a.service.Delete(id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiServer) listHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	offset, limit, err := parsePage(query)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, err.Error())
+		return
+	}
+	since, err := parseIntParam(query, "since", 0)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, err.Error())
+		return
+	}
+	until, err := parseIntParam(query, "until", 0)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiCodeBadRequest, err.Error())
+		return
+	}
+	if a.service == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, apiCodeUnavailable, "Service unavailable")
+		return
+	}
+	history, err := a.service.History()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	search := strings.ToLower(query.Get("q"))
+	matches := make([]types.DownloadEntry, 0, len(history))
+	for _, e := range history {
+		if since > 0 && e.CompletedAt < since {
+			continue
+		}
+		if until > 0 && e.CompletedAt >= until {
+			continue
+		}
+		if !matchesSearch(search, e.Filename, e.URL) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+
+	writeJSON(w, http.StatusOK, HistoryList{
+		Items:  paginate(matches, offset, limit),
+		Total:  len(matches),
+		Offset: offset,
+		Limit:  limit,
+	})
+}
+
+func (a *apiServer) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildOpenAPI(a.routes()))
+}
+
+// lookup fetches a download's status, answering 404 itself when there is none
+func (a *apiServer) lookup(w http.ResponseWriter, id string) (*types.DownloadStatus, bool) {
+	if a.service == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, apiCodeUnavailable, "Service unavailable")
+		return nil, false
+	}
+	status, err := a.service.GetStatus(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return nil, false
+	}
+	return status, true
+}
+
+// matchesSearch reports whether any field contains the lower-cased search term
+func matchesSearch(search string, fields ...string) bool {
+	if search == "" {
+		return true
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePage reads the offset and limit query parameters
+func parsePage(query url.Values) (offset, limit int, err error) {
+	off, err := parseIntParam(query, "offset", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	lim, err := parseIntParam(query, "limit", defaultPageLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lim < 1 || lim > maxPageLimit {
+		return 0, 0, errors.New("Invalid limit: must be between 1 and " + strconv.Itoa(maxPageLimit))
+	}
+	return int(off), int(lim), nil
+}
+
+// parseIntParam reads a non-negative integer query parameter
+func parseIntParam(query url.Values, name string, def int64) (int64, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 0 {
+		return 0, errors.New("Invalid " + name + ": must be a non-negative integer")
+	}
+	return v, nil
+}
+
+// paginate returns the page of items starting at offset
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		utils.Debug("Failed to encode response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// writeRequestError answers with a *requestError from queueDownload or queueMetalink
+func writeRequestError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		writeAPIError(w, reqErr.Status, reqErr.Code, reqErr.Message)
+		return
+	}
+	writeAPIError(w, http.StatusInternalServerError, apiCodeInternal, err.Error())
+}
+
+// validMoveDirection reports whether dir is a direction DownloadService.Move accepts
+func validMoveDirection(dir string) bool {
+	return dir == download.MoveTop || dir == download.MoveUp || dir == download.MoveDown
+}
+
+// writeServiceError maps a DownloadService error to a status and code
+func writeServiceError(w http.ResponseWriter, err error) {
+	status, code := serviceErrorCode(err)
+	writeAPIError(w, status, code, err.Error())
+}
+
+// serviceErrorCode returns the HTTP status and API error code of a DownloadService error
+func serviceErrorCode(err error) (int, string) {
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		return http.StatusNotFound, apiCodeNotFound
+	case errors.Is(err, core.ErrAlreadyCompleted), errors.Is(err, types.ErrNotQueued):
+		return http.StatusConflict, apiCodeConflict
+	default:
+		return http.StatusInternalServerError, apiCodeInternal
+	}
+}
+
+// buildOpenAPI generates an OpenAPI 3 document describing routes. Schemas are
+// derived from the Go types of the bodies and their json tags.
+func buildOpenAPI(routes []apiRoute) map[string]any {
+	schemas := make(map[string]any)
+	errorContent := map[string]any{
+		"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(ErrorResponse{}), schemas)},
+	}
+
+	paths := make(map[string]any)
+	for _, route := range routes {
+		op := map[string]any{
+			"operationId": route.ID,
+			"summary":     route.Summary,
+		}
+
+		if len(route.Params) > 0 {
+			params := make([]any, 0, len(route.Params))
+			for _, p := range route.Params {
+				params = append(params, map[string]any{
+					"name":        p.Name,
+					"in":          p.In,
+					"required":    p.In == "path",
+					"description": p.Description,
+					"schema":      map[string]any{"type": p.Type},
+				})
+			}
+			op["parameters"] = params
+		}
+
+		if route.Body != nil {
+			content := map[string]any{
+				"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(route.Body), schemas)},
+			}
+			for _, mediaType := range route.RawBodies {
+				content[mediaType] = map[string]any{"schema": map[string]any{"type": "string"}}
+			}
+			op["requestBody"] = map[string]any{"required": true, "content": content}
+		}
+
+		responses := make(map[string]any)
+		for status, body := range route.Responses {
+			resp := map[string]any{"description": http.StatusText(status)}
+			if body != nil {
+				resp["content"] = map[string]any{
+					"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(body), schemas)},
+				}
+			} else if status != http.StatusNoContent {
+				resp["content"] = map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}
+			}
+			responses[strconv.Itoa(status)] = resp
+		}
+		errs := route.Errors
+		if !route.Public {
+			errs = append([]int{http.StatusUnauthorized, http.StatusForbidden}, errs...)
+			errs = append(errs, http.StatusInternalServerError)
+		} else {
+			op["security"] = []any{}
+		}
+		for _, status := range errs {
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     errorContent,
+			}
+		}
+		op["responses"] = responses
+
+		item, _ := paths[route.Path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Surge API",
+			"version":     Version,
+			"description": "Control a running Surge daemon. Send the token from `surge token` as a bearer token.",
+		},
+		"servers": []any{map[string]any{"url": apiPrefix}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []any{}}},
+	}
+}
+
+// schemaFor returns the JSON schema of t. Named structs are added to schemas and
+// referenced; fields without omitempty are required.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		if _, done := schemas[name]; !done {
+			schemas[name] = nil // Guards against recursive types
+			props := make(map[string]any)
+			var required []string
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				tag := f.Tag.Get("json")
+				if !f.IsExported() || tag == "-" {
+					continue
+				}
+				field, opts, _ := strings.Cut(tag, ",")
+				if field == "" {
+					field = f.Name
+				}
+				props[field] = schemaFor(f.Type, schemas)
+				if !strings.Contains(opts, "omitempty") {
+					required = append(required, field)
+				}
+			}
+			schema := map[string]any{"type": "object", "properties": props}
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+			schemas[name] = schema
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/engine/cookies"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// fakeService is an in-memory DownloadService for the API tests
+type fakeService struct {
+	downloads []types.DownloadStatus
+	history   []types.DownloadEntry
+	added     []string
+	paths     []string // Output directory of each added download
+	deleted   []string
+	events    chan interface{} // Returned by StreamEvents when set
+}
+
+func (f *fakeService) find(id string) *types.DownloadStatus {
+	for i := range f.downloads {
+		if f.downloads[i].ID == id {
+			return &f.downloads[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakeService) List() ([]types.DownloadStatus, error)   { return f.downloads, nil }
+func (f *fakeService) History() ([]types.DownloadEntry, error) { return f.history, nil }
+func (f *fakeService) ResumeBatch(ids []string) []error        { return make([]error, len(ids)) }
+func (f *fakeService) Publish(msg interface{}) error           { return nil }
+func (f *fakeService) Shutdown() error                         { return nil }
+func (f *fakeService) StreamEvents(ctx context.Context) (<-chan interface{}, func(), error) {
+	if f.events != nil {
+		return f.events, func() {}, nil
+	}
+	return make(chan interface{}), func() {}, nil
+}
+
+func (f *fakeService) Add(url, path, filename string, mirrors []string, headers map[string]string, opts types.DownloadOptions) (string, error) {
+	f.added = append(f.added, url)
+	f.paths = append(f.paths, path)
+	id := "new-" + filename
+	f.downloads = append(f.downloads, types.DownloadStatus{ID: id, URL: url, Filename: filename, Status: "queued"})
+	return id, nil
+}
+
+func (f *fakeService) Pause(id string) error {
+	d := f.find(id)
+	if d == nil {
+		return core.ErrNotFound
+	}
+	d.Status = "paused"
+	return nil
+}
+
+func (f *fakeService) Resume(id string) error {
+	d := f.find(id)
+	if d == nil {
+		return core.ErrNotFound
+	}
+	if d.Status == "completed" {
+		return core.ErrAlreadyCompleted
+	}
+	d.Status = "downloading"
+	return nil
+}
+
+func (f *fakeService) Delete(id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeService) SetSpeedLimit(id string, bytesPerSec int64) error {
+	d := f.find(id)
+	if d == nil {
+		return core.ErrNotFound
+	}
+	d.SpeedLimit = bytesPerSec
+	return nil
+}
+
+func (f *fakeService) SetActiveWindows(specs []string) error {
+	return nil
+}
+
+func (f *fakeService) Move(id string, direction string) error {
+	d := f.find(id)
+	if d == nil {
+		return core.ErrNotFound
+	}
+	if d.Status != "queued" {
+		return types.ErrNotQueued
+	}
+	return nil
+}
+
+func (f *fakeService) GetStatus(id string) (*types.DownloadStatus, error) {
+	d := f.find(id)
+	if d == nil {
+		return nil, core.ErrNotFound
+	}
+	s := *d
+	return &s, nil
+}
+
+func newTestAPI(t *testing.T) (*fakeService, http.Handler) {
+	t.Helper()
+	svc := &fakeService{
+		downloads: []types.DownloadStatus{
+			{ID: "a", URL: "https://example.com/ubuntu.iso", Filename: "ubuntu.iso", Status: "downloading", Category: "ISOs"},
+			{ID: "b", URL: "https://example.com/debian.iso", Filename: "debian.iso", Status: "paused", Category: "ISOs"},
+			{ID: "c", URL: "https://mirror.org/fedora.iso", Filename: "fedora.iso", Status: "downloading"},
+			{ID: "d", URL: "https://example.com/arch.iso", Filename: "arch.iso", Status: "completed"},
+		},
+		history: []types.DownloadEntry{
+			{ID: "h1", Filename: "old.zip", URL: "https://example.com/old.zip", Status: "completed", CompletedAt: 1000},
+			{ID: "h2", Filename: "new.zip", URL: "https://example.com/new.zip", Status: "completed", CompletedAt: 2000},
+		},
+	}
+	mux := http.NewServeMux()
+	registerAPI(mux, t.TempDir(), svc)
+	return svc, authMiddleware(auth.NewKeyring("secret", ""), mux)
+}
+
+func doAPI(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// expectAPIError checks a response is a JSON error with the given status and code
+func expectAPIError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("Status = %d, want %d. Body: %s", rec.Code, status, rec.Body.String())
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Error body is not JSON: %v: %s", err, rec.Body.String())
+	}
+	if resp.Error.Code != code || resp.Error.Message == "" {
+		t.Errorf("Error = %+v, want code %q", resp.Error, code)
+	}
+}
+
+func TestAPI_ListDownloads(t *testing.T) {
+	_, h := newTestAPI(t)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIDs []string
+		total   int
+	}{
+		{"All", "", []string{"a", "b", "c", "d"}, 4},
+		{"Status filter", "?status=downloading", []string{"a", "c"}, 2},
+		{"Several statuses", "?status=paused,completed", []string{"b", "d"}, 2},
+		{"Search", "?q=EXAMPLE.com", []string{"a", "b", "d"}, 3},
+		{"Category", "?category=isos&status=paused", []string{"b"}, 1},
+		{"Page", "?limit=2&offset=1", []string{"b", "c"}, 4},
+		{"Past the end", "?offset=10", []string{}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doAPI(t, h, http.MethodGet, "/api/v1/downloads"+tt.query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Status = %d: %s", rec.Code, rec.Body.String())
+			}
+			var list DownloadList
+			if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(list.Items))
+			for _, d := range list.Items {
+				ids = append(ids, d.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") || list.Total != tt.total {
+				t.Errorf("Got %v (total %d), want %v (total %d)", ids, list.Total, tt.wantIDs, tt.total)
+			}
+			if list.Items == nil {
+				t.Error("items must be an array, not null")
+			}
+		})
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=501", "?offset=-1", "?limit=ten"} {
+		expectAPIError(t, doAPI(t, h, http.MethodGet, "/api/v1/downloads"+query, ""), http.StatusBadRequest, apiCodeBadRequest)
+	}
+}
+
+func TestAPI_ListHistory(t *testing.T) {
+	_, h := newTestAPI(t)
+
+	rec := doAPI(t, h, http.MethodGet, "/api/v1/history?since=1500", "")
+	var list HistoryList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Items[0].ID != "h2" || list.Limit != defaultPageLimit {
+		t.Errorf("since=1500 gave %+v", list)
+	}
+
+	rec = doAPI(t, h, http.MethodGet, "/api/v1/history?until=1500&q=old", "")
+	list = HistoryList{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Items[0].ID != "h1" {
+		t.Errorf("until=1500&q=old gave %+v", list)
+	}
+}
+
+func TestAPI_GetDownload(t *testing.T) {
+	_, h := newTestAPI(t)
+
+	rec := doAPI(t, h, http.MethodGet, "/api/v1/downloads/b", "")
+	var status types.DownloadStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body.String())
+	}
+	if status.ID != "b" || status.Status != "paused" {
+		t.Errorf("Status = %+v", status)
+	}
+
+	expectAPIError(t, doAPI(t, h, http.MethodGet, "/api/v1/downloads/missing", ""), http.StatusNotFound, apiCodeNotFound)
+}
+
+func TestAPI_UpdateDownload(t *testing.T) {
+	svc, h := newTestAPI(t)
+
+	rec := doAPI(t, h, http.MethodPatch, "/api/v1/downloads/a", `{"status": "paused", "speed_limit": 1048576}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH = %d %s", rec.Code, rec.Body.String())
+	}
+	var status types.DownloadStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "paused" || status.SpeedLimit != 1048576 {
+		t.Errorf("Updated status = %+v", status)
+	}
+
+	rec = doAPI(t, h, http.MethodPatch, "/api/v1/downloads/b", `{"status": "downloading"}`)
+	if rec.Code != http.StatusOK || svc.find("b").Status != "downloading" {
+		t.Errorf("Resume = %d %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		body   string
+		status int
+		code   string
+	}{
+		{"Empty patch", "a", `{}`, http.StatusBadRequest, apiCodeBadRequest},
+		{"Unknown field", "a", `{"paused": true}`, http.StatusBadRequest, apiCodeBadRequest},
+		{"Invalid status", "a", `{"status": "completed"}`, http.StatusBadRequest, apiCodeBadRequest},
+		{"Negative limit", "a", `{"speed_limit": -1}`, http.StatusBadRequest, apiCodeBadRequest},
+		{"Missing download", "zzz", `{"status": "paused"}`, http.StatusNotFound, apiCodeNotFound},
+		{"Resume completed", "d", `{"status": "downloading"}`, http.StatusConflict, apiCodeConflict},
+		{"Invalid move", "a", `{"move": "sideways"}`, http.StatusBadRequest, apiCodeBadRequest},
+		{"Move running", "a", `{"move": "top"}`, http.StatusConflict, apiCodeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectAPIError(t, doAPI(t, h, http.MethodPatch, "/api/v1/downloads/"+tt.id, tt.body), tt.status, tt.code)
+		})
+	}
+}
+
+func TestAPI_DeleteDownload(t *testing.T) {
+	svc, h := newTestAPI(t)
+
+	rec := doAPI(t, h, http.MethodDelete, "/api/v1/downloads/c", "")
+	if rec.Code != http.StatusNoContent || len(svc.deleted) != 1 || svc.deleted[0] != "c" {
+		t.Errorf("DELETE = %d, deleted %v", rec.Code, svc.deleted)
+	}
+	expectAPIError(t, doAPI(t, h, http.MethodDelete, "/api/v1/downloads/missing", ""), http.StatusNotFound, apiCodeNotFound)
+}
+
+func TestAPI_CreateDownload(t *testing.T) {
+	svc, h := newTestAPI(t)
+
+	body, _ := json.Marshal(DownloadRequest{URL: "https://example.com/new.bin", Filename: "new.bin", SkipApproval: true})
+	rec := doAPI(t, h, http.MethodPost, "/api/v1/downloads", string(body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST = %d %s", rec.Code, rec.Body.String())
+	}
+	var created DownloadCreated
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "new-new.bin" || created.Status != "queued" {
+		t.Errorf("Created = %+v", created)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/downloads/new-new.bin" {
+		t.Errorf("Location = %q", loc)
+	}
+	if len(svc.added) != 1 || svc.added[0] != "https://example.com/new.bin" {
+		t.Errorf("Added %v", svc.added)
+	}
+
+	expectAPIError(t, doAPI(t, h, http.MethodPost, "/api/v1/downloads", `{"url": ""}`), http.StatusBadRequest, apiCodeBadRequest)
+	expectAPIError(t, doAPI(t, h, http.MethodPost, "/api/v1/downloads", `not json`), http.StatusBadRequest, apiCodeBadRequest)
+	expectAPIError(t, doAPI(t, h, http.MethodPost, "/api/v1/downloads", `{"url": "https://x/y", "checksum": "md4:00"}`), http.StatusBadRequest, apiCodeBadRequest)
+	expectAPIError(t, doAPI(t, h, http.MethodPost, "/api/v1/downloads", `{"url": "https://x/y", "category": "Nope"}`), http.StatusBadRequest, apiCodeBadRequest)
+	expectAPIError(t, doAPI(t, h, http.MethodPost, "/api/v1/downloads", `{"url": "https://x/y", "cookies": "not a cookie file"}`), http.StatusBadRequest, apiCodeBadRequest)
+}
+
+func TestAPI_CreateDownload_ImportsCookies(t *testing.T) {
+	_, h := newTestAPI(t)
+	cookies.Configure("")
+	defer cookies.Configure("")
+
+	body := `{"url": "https://files.example/report.pdf", "cookies": ".files.example\tTRUE\t/\tTRUE\t0\tsession\tabc"}`
+	if rec := doAPI(t, h, http.MethodPost, "/api/v1/downloads", body); rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	u, _ := url.Parse("https://dl.files.example/other.zip")
+	got := cookies.Shared().Cookies(u)
+	if len(got) != 1 || got[0].Name != "session" || got[0].Value != "abc" {
+		t.Errorf("jar cookies = %v, want session=abc", got)
+	}
+}
+
+func TestAPI_Errors(t *testing.T) {
+	_, h := newTestAPI(t)
+
+	rec := doAPI(t, h, http.MethodPut, "/api/v1/downloads/a", "")
+	expectAPIError(t, rec, http.StatusMethodNotAllowed, apiCodeMethodNotAllowed)
+	if allow := rec.Header().Get("Allow"); allow != "DELETE, GET, PATCH" {
+		t.Errorf("Allow = %q", allow)
+	}
+	expectAPIError(t, doAPI(t, h, http.MethodGet, "/api/v1/nope", ""), http.StatusNotFound, apiCodeNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/downloads", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	expectAPIError(t, rec, http.StatusUnauthorized, apiCodeUnauthorized)
+}
+
+func TestAPI_OpenAPIDocument(t *testing.T) {
+	svc, h := newTestAPI(t)
+
+	// Public: no token
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET openapi.json = %d", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+		Comps   struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&doc); err != nil {
+		t.Fatalf("Document is not JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+
+	// Every route is documented, and every referenced schema exists
+	for _, route := range (&apiServer{service: svc}).routes() {
+		if doc.Paths[route.Path][strings.ToLower(route.Method)] == nil {
+			t.Errorf("%s %s missing from the document", route.Method, route.Path)
+		}
+	}
+	for _, ref := range schemaRefs(rec.Body.String()) {
+		if doc.Comps.Schemas[ref] == nil {
+			t.Errorf("Schema %q referenced but not defined", ref)
+		}
+	}
+	status := doc.Comps.Schemas["DownloadStatus"]
+	if props, _ := status["properties"].(map[string]any); props["speed_limit"] == nil || props["mirrors"] == nil {
+		t.Errorf("DownloadStatus schema = %v", status)
+	}
+}
+
+// schemaRefs returns the schema names of every $ref in a JSON document
+func schemaRefs(doc string) []string {
+	const prefix = `"$ref":"#/components/schemas/`
+	var refs []string
+	for {
+		i := strings.Index(doc, prefix)
+		if i < 0 {
+			return refs
+		}
+		doc = doc[i+len(prefix):]
+		refs = append(refs, doc[:strings.IndexByte(doc, '"')])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -253,6 +254,33 @@ func TestIsPrivateIPHost(t *testing.T) {
 	}
 }
 
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{values: nil, want: nil},
+		{values: []string{"x-token: abc"}, want: map[string]string{"X-Token": "abc"}},
+		{values: []string{"Referer:https://example.com/a:b"}, want: map[string]string{"Referer": "https://example.com/a:b"}},
+		{values: []string{"Accept: */*", "accept: text/html"}, want: map[string]string{"Accept": "text/html"}},
+		{values: []string{"X-Empty:"}, want: map[string]string{"X-Empty": ""}},
+		{values: []string{"no colon"}, wantErr: true},
+		{values: []string{": value"}, wantErr: true},
+		{values: []string{"Bad Key: value"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseHeaders(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseHeaders(%q) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Fatalf("parseHeaders(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
 func TestIsLocalHost(t *testing.T) {
 	tests := []struct {
 		host string
@@ -68,6 +68,19 @@ var connectCmd = &cobra.Command{
 		// Create Remote Service
 		service := core.NewRemoteDownloadService(baseURL, token)
 
+		fingerprint, _ := cmd.Flags().GetString("fingerprint")
+		tlsConfig, notice, err := resolveDaemonTLS(baseURL, fingerprint)
+		if err != nil {
+			fmt.Printf("Failed to connect: %v\n", err)
+			os.Exit(1)
+		}
+		if notice != "" {
+			fmt.Println(notice)
+		}
+		if tlsConfig != nil {
+			service.UseTLSConfig(tlsConfig)
+		}
+
 		// Verify connection
 		_, err = service.List()
 		if err != nil {
@@ -122,6 +135,7 @@ var connectCmd = &cobra.Command{
 func init() {
 	connectCmd.Flags().String("token", "", "Bearer token for remote daemon (or set SURGE_TOKEN)")
 	connectCmd.Flags().Bool("insecure-http", false, "Allow plain HTTP for non-loopback targets")
+	connectCmd.Flags().String("fingerprint", "", "Expected SHA-256 fingerprint of the daemon's TLS certificate")
 	rootCmd.AddCommand(connectCmd)
 }
 
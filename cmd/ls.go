@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -25,6 +27,7 @@ var lsCmd = &cobra.Command{
 
 		jsonOutput, _ := cmd.Flags().GetBool("json")
 		watch, _ := cmd.Flags().GetBool("watch")
+		category, _ := cmd.Flags().GetString("category")
 
 		// If ID provided, show details for that download
 		if len(args) == 1 {
@@ -36,11 +39,11 @@ var lsCmd = &cobra.Command{
 			for {
 				// Clear screen first for watch mode
 				fmt.Print("\033[H\033[2J")
-				printDownloads(jsonOutput)
+				printDownloads(jsonOutput, category)
 				time.Sleep(1 * time.Second)
 			}
 		} else {
-			printDownloads(jsonOutput)
+			printDownloads(jsonOutput, category)
 		}
 	},
 }
@@ -55,9 +58,11 @@ type downloadInfo struct {
 	TotalSize  int64   `json:"total_size"`
 	Downloaded int64   `json:"downloaded"`
 	Speed      float64 `json:"speed,omitempty"`
+	Category   string  `json:"category,omitempty"`
 }
 
-func printDownloads(jsonOutput bool) {
+// printDownloads lists downloads, only those of category when it is set
+func printDownloads(jsonOutput bool, category string) {
 	var downloads []downloadInfo
 
 	// Try to get from running server first
@@ -74,6 +79,7 @@ func printDownloads(jsonOutput bool) {
 					TotalSize:  s.TotalSize,
 					Downloaded: s.Downloaded,
 					Speed:      s.Speed,
+					Category:   s.Category,
 				})
 			}
 		}
@@ -99,10 +105,17 @@ func printDownloads(jsonOutput bool) {
 				Progress:   progress,
 				TotalSize:  d.TotalSize,
 				Downloaded: d.Downloaded,
+				Category:   d.Category,
 			})
 		}
 	}
 
+	if category != "" {
+		downloads = slices.DeleteFunc(downloads, func(d downloadInfo) bool {
+			return !strings.EqualFold(d.Category, category)
+		})
+	}
+
 	if len(downloads) == 0 {
 		if !jsonOutput {
 			fmt.Println("No downloads found.")
@@ -120,8 +133,8 @@ func printDownloads(jsonOutput bool) {
 
 	// Table output
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "ID\tFILENAME\tSTATUS\tPROGRESS\tSPEED\tSIZE")
-	_, _ = fmt.Fprintln(w, "--\t--------\t------\t--------\t-----\t----")
+	_, _ = fmt.Fprintln(w, "ID\tFILENAME\tSTATUS\tPROGRESS\tSPEED\tSIZE\tCATEGORY")
+	_, _ = fmt.Fprintln(w, "--\t--------\t------\t--------\t-----\t----\t--------")
 
 	for _, d := range downloads {
 		progress := fmt.Sprintf("%.1f%%", d.Progress)
@@ -147,7 +160,12 @@ func printDownloads(jsonOutput bool) {
 			filename = filename[:22] + "..."
 		}
 
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", id, filename, d.Status, progress, speed, size)
+		category := d.Category
+		if category == "" {
+			category = "-"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", id, filename, d.Status, progress, speed, size, category)
 	}
 	_ = w.Flush()
 }
@@ -229,6 +247,7 @@ func showDownloadDetails(partialID string, jsonOutput bool) {
 		TotalSize:  found.TotalSize,
 		Downloaded: found.Downloaded,
 		Progress:   progress,
+		Category:   found.Category,
 	}
 	printDownloadDetail(status, jsonOutput)
 }
@@ -244,6 +263,9 @@ func printDownloadDetail(d types.DownloadStatus, jsonOutput bool) {
 	fmt.Printf("URL:        %s\n", d.URL)
 	fmt.Printf("Filename:   %s\n", d.Filename)
 	fmt.Printf("Status:     %s\n", d.Status)
+	if d.Category != "" {
+		fmt.Printf("Category:   %s\n", d.Category)
+	}
 	fmt.Printf("Progress:   %.1f%%\n", d.Progress)
 	fmt.Printf("Downloaded: %s / %s\n", formatSize(d.Downloaded), formatSize(d.TotalSize))
 	if d.Speed > 0 {
@@ -252,10 +274,46 @@ func printDownloadDetail(d types.DownloadStatus, jsonOutput bool) {
 	if d.Error != "" {
 		fmt.Printf("Error:      %s\n", d.Error)
 	}
+	if len(d.Mirrors) > 0 {
+		fmt.Println()
+		printMirrorStats(d.Mirrors)
+	}
+}
+
+// printMirrorStats prints the per-mirror breakdown of an active download
+func printMirrorStats(mirrors []types.MirrorStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MIRROR\tSPEED\tDOWNLOADED\tLATENCY\tERRORS\tSTATE")
+	_, _ = fmt.Fprintln(w, "------\t-----\t----------\t-------\t------\t-----")
+
+	for _, m := range mirrors {
+		speed := "-"
+		if m.Speed > 0 {
+			speed = fmt.Sprintf("%.1f MB/s", m.Speed/types.Megabyte)
+		}
+		latency := "-"
+		if m.Latency > 0 {
+			latency = fmt.Sprintf("%dms", m.Latency.Milliseconds())
+		}
+
+		state := "active"
+		switch {
+		case m.Demoted:
+			state = "demoted"
+		case !m.Active:
+			state = "unavailable"
+		case m.Error:
+			state = "errors"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", m.URL, speed, formatSize(m.Downloaded), latency, m.Errors, state)
+	}
+	_ = w.Flush()
 }
 
 func init() {
 	rootCmd.AddCommand(lsCmd)
 	lsCmd.Flags().Bool("json", false, "Output in JSON format")
 	lsCmd.Flags().Bool("watch", false, "Watch mode: refresh every second")
+	lsCmd.Flags().String("category", "", "Only list downloads of this category")
 }
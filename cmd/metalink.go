@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// isMetalinkArg reports whether a CLI argument names a Metalink document (a local
+// .meta4/.metalink file or a URL to one) rather than a comma-separated mirror list
+func isMetalinkArg(arg string) bool {
+	return !strings.Contains(arg, ",") && download.IsMetalinkName(arg)
+}
+
+// loadMetalink reads a Metalink document from a local path or an HTTP(S) URL
+func loadMetalink(arg string) ([]download.MetalinkFile, error) {
+	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch metalink: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch metalink: %s", resp.Status)
+		}
+		return download.ParseMetalink(resp.Body)
+	}
+
+	file, err := os.Open(arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metalink: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return download.ParseMetalink(file)
+}
+
+// metalinkOptions applies a Metalink file's hash and size to opts.
+// An explicitly supplied checksum wins over the document's.
+func metalinkOptions(opts types.DownloadOptions, f download.MetalinkFile) types.DownloadOptions {
+	if opts.Checksum == "" {
+		opts.Checksum = f.Checksum
+	}
+	opts.ExpectedSize = f.Size
+	return opts
+}
+
+// handleMetalinkDownload queues every file of a Metalink document posted as the
+// request body. The optional "path" query parameter selects the output directory.
+func handleMetalinkDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir string, service core.DownloadService) {
+	ids, err := queueMetalink(r.Body, r.URL.Query().Get("path"), defaultOutputDir, service, auth.FromContext(r.Context()))
+	if err != nil {
+		status := http.StatusInternalServerError
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			status = reqErr.Status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "queued",
+		"message": fmt.Sprintf("%d downloads queued from metalink", len(ids)),
+		"ids":     ids,
+	}); err != nil {
+		utils.Debug("Failed to encode response: %v", err)
+	}
+}
+
+// queueMetalink adds every file of a Metalink document to the service and returns
+// their ids. A non-nil caller limits the output directory to the token's. Errors
+// are *requestError.
+func queueMetalink(body io.Reader, outPath string, defaultOutputDir string, service core.DownloadService, caller *auth.Token) ([]string, error) {
+	if service == nil {
+		return nil, &requestError{Status: http.StatusInternalServerError, Code: apiCodeUnavailable, Message: "Service unavailable"}
+	}
+
+	files, err := download.ParseMetalink(body)
+	if err != nil {
+		return nil, badRequest(err.Error())
+	}
+
+	if strings.Contains(outPath, "..") {
+		return nil, badRequest("Invalid path")
+	}
+	requested := outPath
+	if outPath == "" {
+		outPath = defaultOutputDir
+	}
+	if outPath == "" {
+		if settings, err := config.LoadSettings(); err == nil {
+			outPath = settings.General.DefaultDownloadDir
+		}
+	}
+	if outPath == "" {
+		outPath = "."
+	}
+	if err := os.MkdirAll(outPath, 0o755); err != nil {
+		return nil, internalError("Failed to create output directory: " + err.Error())
+	}
+	outPath = utils.EnsureAbsPath(outPath)
+	if caller != nil && !caller.AllowsDir(outPath) {
+		if requested != "" {
+			return nil, forbidden(fmt.Sprintf("Token %q may not download to %s", caller.Name, outPath))
+		}
+		// The default directory is off limits: use the token's own
+		outPath = caller.Dirs[0]
+		if err := os.MkdirAll(outPath, 0o755); err != nil {
+			return nil, internalError("Failed to create output directory: " + err.Error())
+		}
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		id, err := service.Add(f.URLs[0], outPath, f.Name, f.URLs, nil, metalinkOptions(types.DownloadOptions{}, f))
+		if err != nil {
+			return ids, internalError(fmt.Sprintf("Failed to add %s: %v", f.Name, err))
+		}
+		atomic.AddInt32(&activeDownloads, 1)
+		ids = append(ids, id)
+	}
+
+	utils.Debug("Queued %d downloads from metalink", len(ids))
+	return ids, nil
+}
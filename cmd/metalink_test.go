@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+const testMetalinkSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func testMetalinkDoc(baseURL string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="distro.iso">
+    <size>5</size>
+    <hash type="sha-256">` + testMetalinkSHA256 + `</hash>
+    <url priority="2">` + baseURL + `/mirror2/distro.iso</url>
+    <url priority="1">` + baseURL + `/mirror1/distro.iso</url>
+  </file>
+  <file name="distro.iso.sig">
+    <url>` + baseURL + `/mirror1/distro.iso.sig</url>
+  </file>
+</metalink>`
+}
+
+func TestIsMetalinkArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want bool
+	}{
+		{"distro.meta4", true},
+		{"https://example.com/distro.iso.meta4", true},
+		{"https://example.com/distro.iso", false},
+		{"https://a.com/x.meta4,https://b.com/x.meta4", false},
+	}
+	for _, tt := range tests {
+		if got := isMetalinkArg(tt.arg); got != tt.want {
+			t.Errorf("isMetalinkArg(%q) = %v, want %v", tt.arg, got, tt.want)
+		}
+	}
+}
+
+// TestMetalink_CLI_Integration verifies that "surge add file.meta4" sends one request
+// per file with mirrors in priority order, the hash and the size.
+func TestMetalink_CLI_Integration(t *testing.T) {
+	received := make(chan DownloadRequest, 4)
+	server := testutil.NewHTTPServerT(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req DownloadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to parse JSON: %v", err)
+			return
+		}
+		received <- req
+		_, _ = fmt.Fprintln(w, `{"status":"queued"}`)
+	}))
+	defer server.Close()
+
+	_, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	var port int
+	_, _ = fmt.Sscanf(portStr, "%d", &port)
+
+	metaPath := filepath.Join(t.TempDir(), "distro.meta4")
+	if err := os.WriteFile(metaPath, []byte(testMetalinkDoc("https://example.com")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := processDownloads([]string{metaPath}, ".", port, types.DownloadOptions{}); n != 2 {
+		t.Fatalf("processDownloads added %d downloads, want 2", n)
+	}
+
+	req := <-received
+	if req.Filename != "distro.iso" || req.URL != "https://example.com/mirror1/distro.iso" {
+		t.Errorf("first request = %+v", req)
+	}
+	if len(req.Mirrors) != 2 || req.Mirrors[1] != "https://example.com/mirror2/distro.iso" {
+		t.Errorf("Mirrors = %v, want priority order", req.Mirrors)
+	}
+	if req.Checksum != "sha256:"+testMetalinkSHA256 || req.Size != 5 {
+		t.Errorf("Checksum = %q, Size = %d", req.Checksum, req.Size)
+	}
+
+	req = <-received
+	if req.Filename != "distro.iso.sig" || req.Checksum != "" || req.Size != 0 {
+		t.Errorf("second request = %+v", req)
+	}
+}
+
+func TestHandleDownload_Metalink(t *testing.T) {
+	// Mirrors answer 404 so the queued downloads fail fast in the background
+	mirrors := testutil.NewHTTPServerT(t, http.NotFoundHandler())
+	defer mirrors.Close()
+
+	ch := make(chan interface{}, 100)
+	pool := download.NewWorkerPool(ch, 1)
+	svc := core.NewLocalDownloadServiceWithInput(pool, ch)
+	defer func() { _ = svc.Shutdown() }()
+
+	outDir := t.TempDir()
+	req := httptest.NewRequest(http.MethodPost, "/download?path="+outDir, bytes.NewBufferString(testMetalinkDoc(mirrors.URL)))
+	req.Header.Set("Content-Type", "application/metalink4+xml")
+	rec := httptest.NewRecorder()
+
+	handleDownload(rec, req, "", svc)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Status string   `json:"status"`
+		IDs    []string `json:"ids"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Status != "queued" || len(resp.IDs) != 2 {
+		t.Errorf("response = %+v, want 2 queued ids", resp)
+	}
+
+	// Let the background probes settle before shutdown
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestHandleDownload_MetalinkInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		body string
+	}{
+		{"malformed", "/download", "<metalink>"},
+		{"traversal in path", "/download?path=../etc", testMetalinkDoc("https://example.com")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/metalink4+xml")
+			rec := httptest.NewRecorder()
+
+			handleDownload(rec, req, "", core.NewLocalDownloadService(nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// metricsEnabled serves Prometheus metrics at /metrics (server start --metrics)
+var metricsEnabled bool
+
+// handleMetrics writes the engine's metrics and the pool's download counts
+func handleMetrics(w http.ResponseWriter, r *http.Request, pool *download.WorkerPool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	families := metrics.Gather()
+	if pool != nil {
+		counts := pool.StateCounts()
+		families = append(families, metrics.Family{
+			Name: "surge_downloads",
+			Help: "Downloads held by the engine, by state.",
+			Type: metrics.TypeGauge,
+			Samples: []metrics.Sample{
+				{Labels: map[string]string{"state": "active"}, Value: float64(counts.Active)},
+				{Labels: map[string]string{"state": "queued"}, Value: float64(counts.Queued)},
+				{Labels: map[string]string{"state": "scheduled"}, Value: float64(counts.Scheduled)},
+				{Labels: map[string]string{"state": "paused"}, Value: float64(counts.Paused)},
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := metrics.Write(w, families); err != nil {
+		utils.Debug("Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+)
+
+func TestMetrics_Endpoint(t *testing.T) {
+	pool := download.NewWorkerPool(make(chan any, 10), 3)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handleMetrics(w, r, pool)
+	})
+	h := authMiddleware(auth.NewKeyring("secret", ""), mux)
+
+	// Scrapers authenticate like any other client
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Unauthenticated GET /metrics = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE surge_downloaded_bytes_total counter",
+		"# TYPE surge_download_speed_bytes gauge",
+		`surge_downloads{state="active"} 0`,
+		`surge_downloads{state="queued"} 0`,
+		`surge_downloads{state="paused"} 0`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Metrics are missing %q:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+var moveCmd = &cobra.Command{
+	Use:   "move <ID>",
+	Short: "Reorder a queued download",
+	Long: `Move a queued download within the queue of the running Surge instance.
+
+Downloads start by priority, then in the order they were added. A download
+moved past one of another priority takes on that priority.`,
+	Example: `  surge move 3f2a --top`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initializeGlobalState()
+
+		var directions []string
+		for _, dir := range []string{download.MoveTop, download.MoveUp, download.MoveDown} {
+			if set, _ := cmd.Flags().GetBool(dir); set {
+				directions = append(directions, dir)
+			}
+		}
+		if len(directions) != 1 {
+			fmt.Fprintln(os.Stderr, "Error: use exactly one of --top, --up or --down")
+			os.Exit(1)
+		}
+
+		id, err := resolveDownloadID(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// The queue only exists in a running instance
+		port := readActivePort()
+		if port == 0 {
+			fmt.Fprintln(os.Stderr, "Error: Surge is not running.")
+			os.Exit(1)
+		}
+
+		query := url.Values{"id": {id}, "to": {directions[0]}}
+		resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/move?%s", port, query.Encode()), "application/json", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error connecting to server: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				utils.Debug("Error closing response body: %v", err)
+			}
+		}()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Error: %s\n", strings.TrimSpace(string(body)))
+			os.Exit(1)
+		}
+		fmt.Printf("Moved download %s %s\n", id[:8], directions[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(moveCmd)
+	moveCmd.Flags().Bool(download.MoveTop, false, "Move to the front of the queue")
+	moveCmd.Flags().Bool(download.MoveUp, false, "Move one place up")
+	moveCmd.Flags().Bool(download.MoveDown, false, "Move one place down")
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"context"
-	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -13,13 +13,23 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/auth"
 	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/core"
 	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/cookies"
 	"github.com/surge-downloader/surge/internal/engine/events"
 	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/stream"
+	"github.com/surge-downloader/surge/internal/engine/torrent"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/extract"
 	"github.com/surge-downloader/surge/internal/tui"
 	"github.com/surge-downloader/surge/internal/utils"
+	"github.com/surge-downloader/surge/internal/websocket"
+	"github.com/surge-downloader/surge/internal/webui"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/google/uuid"
@@ -61,6 +71,10 @@ var rootCmd = &cobra.Command{
 			settings = config.DefaultSettings()
 		}
 		GlobalPool = download.NewWorkerPool(GlobalProgressCh, settings.Network.MaxConcurrentDownloads)
+		if err := GlobalPool.SetActiveWindows(settings.General.ActiveWindows); err != nil {
+			utils.Debug("Ignoring invalid active window setting: %v", err)
+		}
+		bandwidth.Global().SetLimit(settings.Network.GlobalSpeedLimit)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		initializeGlobalState()
@@ -114,6 +128,12 @@ var rootCmd = &cobra.Command{
 			}
 		}
 
+		listener, _, err = daemonListener(listener)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Save port for browser extension AND CLI discovery
 		saveActivePort(port)
 		defer removeActivePort()
@@ -136,7 +156,7 @@ var rootCmd = &cobra.Command{
 			}
 
 			if len(urls) > 0 {
-				processDownloads(urls, outputDir, 0) // 0 port = internal direct add
+				processDownloads(urls, outputDir, 0, types.DownloadOptions{}) // 0 port = internal direct add
 			}
 		}()
 
@@ -244,12 +264,22 @@ func StartHeadlessConsumer() {
 					id = id[:8]
 				}
 				fmt.Printf("Queued: %s [%s]\n", m.Filename, id)
+			case events.DownloadScheduledMsg:
+				id := m.DownloadID
+				if len(id) > 8 {
+					id = id[:8]
+				}
+				fmt.Printf("Scheduled: %s [%s] (starts %s)\n", m.Filename, id, m.StartAt.Format("Mon 15:04"))
 			case events.DownloadPausedMsg:
 				id := m.DownloadID
 				if len(id) > 8 {
 					id = id[:8]
 				}
-				fmt.Printf("Paused: %s [%s]\n", m.Filename, id)
+				if m.Reason != "" {
+					fmt.Printf("Paused: %s [%s] (%s)\n", m.Filename, id, m.Reason)
+				} else {
+					fmt.Printf("Paused: %s [%s]\n", m.Filename, id)
+				}
 			case events.DownloadResumedMsg:
 				id := m.DownloadID
 				if len(id) > 8 {
@@ -262,6 +292,28 @@ func StartHeadlessConsumer() {
 					id = id[:8]
 				}
 				fmt.Printf("Removed: %s [%s]\n", m.Filename, id)
+			case events.HookResultMsg:
+				id := m.DownloadID
+				if len(id) > 8 {
+					id = id[:8]
+				}
+				if m.Err != "" {
+					fmt.Printf("Hook failed: %s [%s] %s: %s\n", m.Filename, id, m.Hook, m.Err)
+				} else {
+					fmt.Printf("Hook done: %s [%s] %s (status %d)\n", m.Filename, id, m.Hook, m.ExitCode)
+				}
+			case events.ExtractCompleteMsg:
+				id := m.DownloadID
+				if len(id) > 8 {
+					id = id[:8]
+				}
+				fmt.Printf("Extracted: %s [%s] -> %s (%d files)\n", m.Filename, id, m.Dir, m.Files)
+			case events.ExtractErrorMsg:
+				id := m.DownloadID
+				if len(id) > 8 {
+					id = id[:8]
+				}
+				fmt.Printf("Extract failed: %s [%s]: %s\n", m.Filename, id, m.Err)
 			}
 		}
 	}()
@@ -298,7 +350,7 @@ func removeActivePort() {
 
 // startHTTPServer starts the HTTP server using an existing listener
 func startHTTPServer(ln net.Listener, port int, defaultOutputDir string, service core.DownloadService) {
-	authToken := ensureAuthToken()
+	keyring := auth.NewKeyring(ensureAuthToken(), tokensFile())
 
 	mux := http.NewServeMux()
 
@@ -357,29 +409,7 @@ func startHTTPServer(ln net.Listener, port int, defaultOutputDir string, service
 					continue
 				}
 
-				// Determine event type name based on struct
-				// Events are in internal/engine/events package
-				eventType := "unknown"
-				switch msg.(type) {
-				case events.DownloadStartedMsg:
-					eventType = "started"
-				case events.DownloadCompleteMsg:
-					eventType = "complete"
-				case events.DownloadErrorMsg:
-					eventType = "error"
-				case events.ProgressMsg:
-					eventType = "progress"
-				case events.DownloadPausedMsg:
-					eventType = "paused"
-				case events.DownloadResumedMsg:
-					eventType = "resumed"
-				case events.DownloadQueuedMsg:
-					eventType = "queued"
-				case events.DownloadRemovedMsg:
-					eventType = "removed"
-				case events.DownloadRequestMsg:
-					eventType = "request"
-				}
+				eventType := events.Name(msg)
 
 				// SSE Format:
 				// event: <type>
@@ -443,6 +473,64 @@ func startHTTPServer(ln net.Listener, port int, defaultOutputDir string, service
 		}
 	})
 
+	// Limit endpoint (Protected): sets a download's speed cap, or the global one when id is omitted
+	mux.HandleFunc("/limit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		rate := r.URL.Query().Get("rate")
+		if rate == "" {
+			http.Error(w, "Missing rate parameter", http.StatusBadRequest)
+			return
+		}
+		limit, err := utils.ParseBytes(rate)
+		if err != nil {
+			http.Error(w, "Invalid rate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := service.SetSpeedLimit(id, limit); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "id": id, "limit": limit}); err != nil {
+			utils.Debug("Failed to encode response: %v", err)
+		}
+	})
+
+	// Move endpoint (Protected): reorder a queued download
+	mux.HandleFunc("/move", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		to := r.URL.Query().Get("to")
+		if id == "" || to == "" {
+			http.Error(w, "Missing id or to parameter", http.StatusBadRequest)
+			return
+		}
+		if !validMoveDirection(to) {
+			http.Error(w, "Invalid to: must be top, up or down", http.StatusBadRequest)
+			return
+		}
+
+		if err := service.Move(id, to); err != nil {
+			status, _ := serviceErrorCode(err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"status": "moved", "id": id, "to": to}); err != nil {
+			utils.Debug("Failed to encode response: %v", err)
+		}
+	})
+
 	// Delete endpoint (Protected)
 	mux.HandleFunc("/delete", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete && r.Method != http.MethodPost {
@@ -504,8 +592,28 @@ func startHTTPServer(ln net.Listener, port int, defaultOutputDir string, service
 		}
 	})
 
+	// WebSocket endpoint (Protected): the /events stream plus commands
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(w, r, defaultOutputDir, service)
+	})
+
+	// Versioned REST API (Protected, except its OpenAPI document)
+	registerAPI(mux, defaultOutputDir, service)
+
+	// Prometheus metrics (Protected)
+	if metricsEnabled {
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			handleMetrics(w, r, GlobalPool)
+		})
+	}
+
+	// Web dashboard (Protected, except its sign-in page)
+	if webUIEnabled {
+		registerWebUI(mux, keyring)
+	}
+
 	// Wrap mux with Auth and CORS (CORS outermost to ensure 401/403 include headers)
-	handler := corsMiddleware(authMiddleware(authToken, mux))
+	handler := corsMiddleware(authMiddleware(keyring, mux))
 
 	server := &http.Server{Handler: handler}
 	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
@@ -531,10 +639,12 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func authMiddleware(token string, next http.Handler) http.Handler {
+// authMiddleware identifies the caller's token, checks it grants the scope the
+// request needs and passes it on in the request context
+func authMiddleware(keyring *auth.Keyring, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow health check without auth
-		if r.URL.Path == "/health" {
+		// Allow health check, the API description and the dashboard's sign-in and sign-out pages without auth
+		if r.URL.Path == "/health" || r.URL.Path == openAPIPath || r.URL.Path == webui.LoginPath || r.URL.Path == webui.Prefix+"logout" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -547,20 +657,83 @@ func authMiddleware(token string, next http.Handler) http.Handler {
 
 		// Check for Authorization header
 		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" {
-			if strings.HasPrefix(authHeader, "Bearer ") {
-				providedToken := strings.TrimPrefix(authHeader, "Bearer ")
-				if len(providedToken) == len(token) && subtle.ConstantTimeCompare([]byte(providedToken), []byte(token)) == 1 {
-					next.ServeHTTP(w, r)
-					return
+		providedToken, hasBearer := strings.CutPrefix(authHeader, "Bearer ")
+		if token := r.URL.Query().Get("token"); !hasBearer && r.URL.Path == "/ws" && token != "" {
+			// Browsers cannot set headers on a WebSocket handshake
+			providedToken, hasBearer = token, true
+		}
+		fromCookie := false
+		if !hasBearer && cookieAllowed(r) {
+			// Signed in to the web dashboard
+			if cookie, err := r.Cookie(sessionCookie); err == nil {
+				providedToken, hasBearer, fromCookie = cookie.Value, true, true
+			}
+		}
+		var caller *auth.Token
+		if hasBearer {
+			caller = keyring.Lookup(providedToken)
+		}
+		// Browsers send cookies with cross-site WebSocket handshakes too
+		if caller != nil && fromCookie && r.URL.Path == "/ws" && !websocket.SameOrigin(r) {
+			http.Error(w, "Cross-origin WebSocket request", http.StatusForbidden)
+			return
+		}
+		if caller != nil {
+			if scope := requiredScope(r); !caller.Allows(scope) {
+				message := fmt.Sprintf("Token %q lacks the %s scope", caller.Name, scope)
+				if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+					writeAPIError(w, http.StatusForbidden, apiCodeForbidden, message)
+				} else {
+					http.Error(w, message, http.StatusForbidden)
 				}
+				return
 			}
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), caller)))
+			return
 		}
 
+		if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+			writeAPIError(w, http.StatusUnauthorized, apiCodeUnauthorized, "Missing or invalid bearer token")
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, webui.Prefix) && r.Method == http.MethodGet {
+			http.Redirect(w, r, webui.LoginPath, http.StatusSeeOther)
+			return
+		}
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 	})
 }
 
+// cookieAllowed reports whether r may be authorized by the dashboard's session
+// cookie alone. Browsers attach cookies to requests other sites trigger, so the
+// cookie only opens the dashboard's pages and read-only event streams; the API and
+// anything that changes state need the token as a bearer token.
+func cookieAllowed(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, webui.Prefix) || r.URL.Path == "/events" || r.URL.Path == "/ws"
+}
+
+// requiredScope returns the scope a request needs. Reads only need read; anything
+// not listed here needs admin.
+func requiredScope(r *http.Request) auth.Scope {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return auth.ScopeRead
+	}
+	path := r.URL.Path
+	switch {
+	case path == "/download" || path == apiPrefix+"/downloads":
+		return auth.ScopeAdd
+	case path == "/limit" && r.URL.Query().Get("id") == "":
+		return auth.ScopeAdmin // The global speed limit
+	case path == "/pause" || path == "/resume" || path == "/delete" || path == "/limit" || path == "/move" ||
+		strings.HasPrefix(path, apiPrefix+"/downloads/"):
+		return auth.ScopeControl
+	}
+	return auth.ScopeAdmin
+}
+
 func ensureAuthToken() string {
 	tokenFile := filepath.Join(config.GetSurgeDir(), "token")
 	data, err := os.ReadFile(tokenFile)
@@ -589,6 +762,47 @@ type DownloadRequest struct {
 	Mirrors              []string          `json:"mirrors,omitempty"`
 	SkipApproval         bool              `json:"skip_approval,omitempty"` // Extension validated request, skip TUI prompt
 	Headers              map[string]string `json:"headers,omitempty"`       // Custom HTTP headers from browser (cookies, auth, etc.)
+	Checksum             string            `json:"checksum,omitempty"`      // Expected digest, e.g. "sha256:<hex>"
+	StartAt              string            `json:"start_at,omitempty"`      // Hold until this time (RFC 3339, "HH:MM" or "+duration")
+	Window               string            `json:"window,omitempty"`        // Daily active window, e.g. "01:00-07:00"
+	Limit                string            `json:"limit,omitempty"`         // Per-download speed cap, e.g. "5MB" (per second)
+	Extract              string            `json:"extract,omitempty"`       // Unpack on completion: "keep", "delete" or "off"
+	Variant              string            `json:"variant,omitempty"`       // HLS/DASH rendition: "best", "worst" or a height like "720p"
+	Priority             string            `json:"priority,omitempty"`      // Queue priority: "low", "normal", "high" or a number
+	Category             string            `json:"category,omitempty"`      // Category from settings; empty = match by file type and host
+	Size                 int64             `json:"size,omitempty"`          // Expected file size in bytes (e.g. from a Metalink)
+	Torrent              []byte            `json:"torrent,omitempty"`       // Contents of a .torrent file (base64 in JSON), queued instead of url
+	Cookies              string            `json:"cookies,omitempty"`       // Netscape cookies.txt imported into the cookie jar before queueing
+}
+
+// requestError is a rejected download request: the HTTP status, a machine-readable
+// code for /api/v1 clients and the message the legacy endpoints send as plain text
+type requestError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+func badRequest(message string) *requestError {
+	return &requestError{Status: http.StatusBadRequest, Code: apiCodeBadRequest, Message: message}
+}
+
+func forbidden(message string) *requestError {
+	return &requestError{Status: http.StatusForbidden, Code: apiCodeForbidden, Message: message}
+}
+
+func internalError(message string) *requestError {
+	return &requestError{Status: http.StatusInternalServerError, Code: apiCodeInternal, Message: message}
+}
+
+// queuedDownload is the outcome of an accepted download request
+type queuedDownload struct {
+	ID      string
+	Pending bool // Sent to the TUI for confirmation rather than queued
 }
 
 func handleDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir string, service core.DownloadService) {
@@ -624,11 +838,9 @@ func handleDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir str
 		return
 	}
 
-	// Load settings once for use throughout the function
-	settings, err := config.LoadSettings()
-	if err != nil {
-		// Fallback to defaults if loading fails (though LoadSettings handles missing file)
-		settings = config.DefaultSettings()
+	if download.IsMetalinkContentType(r.Header.Get("Content-Type")) {
+		handleMetalinkDownload(w, r, defaultOutputDir, service)
+		return
 	}
 
 	var req DownloadRequest
@@ -642,26 +854,136 @@ func handleDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir str
 		}
 	}()
 
-	if req.URL == "" {
-		http.Error(w, "URL is required", http.StatusBadRequest)
+	queued, err := queueDownload(req, defaultOutputDir, service, auth.FromContext(r.Context()))
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) && reqErr.Code == apiCodeApprovalRequired {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			if err := json.NewEncoder(w).Encode(map[string]string{
+				"status":  "error",
+				"message": reqErr.Message,
+			}); err != nil {
+				utils.Debug("Failed to encode response: %v", err)
+			}
+			return
+		}
+		status := http.StatusInternalServerError
+		if reqErr != nil {
+			status = reqErr.Status
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	if strings.Contains(req.Path, "..") || strings.Contains(req.Filename, "..") {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	if queued.Pending {
+		// Return 202 Accepted to indicate it's pending approval
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(map[string]string{
+			"status":  "pending_approval",
+			"message": "Download request sent to TUI for confirmation",
+			"id":      queued.ID, // ID might change if user modifies it, but useful for tracking
+		}); err != nil {
+			utils.Debug("Failed to encode response: %v", err)
+		}
 		return
 	}
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"status":  "queued",
+		"message": "Download queued successfully",
+		"id":      queued.ID,
+	}); err != nil {
+		utils.Debug("Failed to encode response: %v", err)
+	}
+}
+
+// queueDownload validates a download request and adds it to the service, or sends
+// it to the TUI for confirmation when settings ask for that. A non-nil caller
+// limits the output directory to the token's. Errors are *requestError.
+func queueDownload(req DownloadRequest, defaultOutputDir string, service core.DownloadService, caller *auth.Token) (queuedDownload, error) {
+	// Load settings once for use throughout the function
+	settings, err := config.LoadSettings()
+	if err != nil {
+		// Fallback to defaults if loading fails (though LoadSettings handles missing file)
+		settings = config.DefaultSettings()
+	}
+
+	// A .torrent file is queued as the magnet link of its info hash
+	if len(req.Torrent) > 0 {
+		magnet, err := torrent.Import(req.Torrent)
+		if err != nil {
+			return queuedDownload{}, badRequest(err.Error())
+		}
+		req.URL = magnet
+	}
+
+	if req.URL == "" {
+		return queuedDownload{}, badRequest("URL is required")
+	}
+
+	if strings.Contains(req.Path, "..") || strings.Contains(req.Filename, "..") {
+		return queuedDownload{}, badRequest("Invalid path")
+	}
 	if strings.Contains(req.Filename, "/") || strings.Contains(req.Filename, "\\") {
-		http.Error(w, "Invalid filename", http.StatusBadRequest)
-		return
+		return queuedDownload{}, badRequest("Invalid filename")
+	}
+	if req.Checksum != "" {
+		if _, err := checksum.Parse(req.Checksum); err != nil {
+			return queuedDownload{}, badRequest("Invalid checksum: " + err.Error())
+		}
+	}
+	extractMode, err := extract.ParseMode(req.Extract)
+	if err != nil {
+		return queuedDownload{}, badRequest(err.Error())
+	}
+	if _, err := stream.ParseSelector(req.Variant); err != nil {
+		return queuedDownload{}, badRequest(err.Error())
+	}
+	startAt, err := download.ParseStartAt(req.StartAt, time.Now())
+	if err != nil {
+		return queuedDownload{}, badRequest(err.Error())
+	}
+	if req.Window != "" {
+		if _, err := download.ParseWindow(req.Window); err != nil {
+			return queuedDownload{}, badRequest(err.Error())
+		}
+	}
+	if req.Size < 0 {
+		return queuedDownload{}, badRequest("Invalid size")
+	}
+	priority, err := download.ParsePriority(req.Priority)
+	if err != nil {
+		return queuedDownload{}, badRequest(err.Error())
+	}
+	if req.Category != "" && config.FindCategory(settings.Categories, req.Category) == nil {
+		return queuedDownload{}, badRequest(fmt.Sprintf("Unknown category %q", req.Category))
+	}
+	var speedLimit int64
+	if req.Limit != "" {
+		if speedLimit, err = utils.ParseBytes(req.Limit); err != nil {
+			return queuedDownload{}, badRequest("Invalid limit: " + err.Error())
+		}
+	}
+	var jarCookies []cookies.Cookie
+	if req.Cookies != "" {
+		if jarCookies, err = cookies.Parse(strings.NewReader(req.Cookies)); err != nil {
+			return queuedDownload{}, badRequest("Invalid cookies: " + err.Error())
+		}
 	}
 
 	utils.Debug("Received download request: URL=%s, Path=%s", req.URL, req.Path)
 
 	downloadID := uuid.New().String()
 	if service == nil {
-		http.Error(w, "Service unavailable", http.StatusInternalServerError)
-		return
+		return queuedDownload{}, &requestError{Status: http.StatusInternalServerError, Code: apiCodeUnavailable, Message: "Service unavailable"}
+	}
+
+	// Cookies go into the shared jar, where every later download from their domains finds them too
+	if len(jarCookies) > 0 {
+		if err := cookies.Shared().Import(jarCookies); err != nil {
+			return queuedDownload{}, internalError("Failed to import cookies: " + err.Error())
+		}
 	}
 
 	// Prepare output path
@@ -677,23 +999,20 @@ func handleDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir str
 		}
 		outPath = filepath.Join(baseDir, req.Path)
 		if err := os.MkdirAll(outPath, 0o755); err != nil {
-			http.Error(w, "Failed to create directory: "+err.Error(), http.StatusInternalServerError)
-			return
+			return queuedDownload{}, internalError("Failed to create directory: " + err.Error())
 		}
 
 	} else if outPath == "" {
 		if defaultOutputDir != "" {
 			outPath = defaultOutputDir
 			if err := os.MkdirAll(outPath, 0o755); err != nil {
-				http.Error(w, "Failed to create output directory: "+err.Error(), http.StatusInternalServerError)
-				return
+				return queuedDownload{}, internalError("Failed to create output directory: " + err.Error())
 			}
 		} else {
 			if settings.General.DefaultDownloadDir != "" {
 				outPath = settings.General.DefaultDownloadDir
 				if err := os.MkdirAll(outPath, 0o755); err != nil {
-					http.Error(w, "Failed to create output directory: "+err.Error(), http.StatusInternalServerError)
-					return
+					return queuedDownload{}, internalError("Failed to create output directory: " + err.Error())
 				}
 			} else {
 				outPath = "."
@@ -704,6 +1023,20 @@ func handleDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir str
 	// Enforce absolute path to ensure resume works even if CWD changes
 	outPath = utils.EnsureAbsPath(outPath)
 
+	if caller != nil && !caller.AllowsDir(outPath) {
+		if req.Path != "" {
+			return queuedDownload{}, forbidden(fmt.Sprintf("Token %q may not download to %s", caller.Name, outPath))
+		}
+		// The default directory is off limits: use the token's own
+		outPath = caller.Dirs[0]
+		if err := os.MkdirAll(outPath, 0o755); err != nil {
+			return queuedDownload{}, internalError("Failed to create output directory: " + err.Error())
+		}
+	}
+
+	// Only unrestricted callers may have a category's directory replace the default one
+	defaultDir := req.Path == "" && (caller == nil || len(caller.Dirs) == 0)
+
 	// Check settings for extension prompt and duplicates
 	// Logic modified to distinguish between ACTIVE (corruption risk) and COMPLETED (overwrite safe)
 	isDuplicate := false
@@ -756,72 +1089,97 @@ func handleDownload(w http.ResponseWriter, r *http.Request, defaultOutputDir str
 					Path:     outPath, // Use the path we resolved (default or requested)
 					Mirrors:  mirrorsForAdd,
 					Headers:  req.Headers,
+					Checksum: req.Checksum,
+					StartAt:  startAt,
+					Window:   req.Window,
+					Extract:  extractMode,
+					Variant:  req.Variant,
+					Priority: priority,
+					Category: req.Category,
+
+					DefaultDir:   defaultDir,
+					SpeedLimit:   speedLimit,
+					ExpectedSize: req.Size,
 				}); err != nil {
-					http.Error(w, "Failed to notify TUI: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-				// Return 202 Accepted to indicate it's pending approval
-				w.WriteHeader(http.StatusAccepted)
-				if err := json.NewEncoder(w).Encode(map[string]string{
-					"status":  "pending_approval",
-					"message": "Download request sent to TUI for confirmation",
-					"id":      downloadID, // ID might change if user modifies it, but useful for tracking
-				}); err != nil {
-					utils.Debug("Failed to encode response: %v", err)
-				}
-				return
-			} else {
-				// Headless mode check
-				if settings.General.ExtensionPrompt || (settings.General.WarnOnDuplicate && isDuplicate) {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusConflict)
-					if err := json.NewEncoder(w).Encode(map[string]string{
-						"status":  "error",
-						"message": "Download rejected: Duplicate download or approval required (Headless mode)",
-					}); err != nil {
-						utils.Debug("Failed to encode response: %v", err)
-					}
-					return
+					return queuedDownload{}, internalError("Failed to notify TUI: " + err.Error())
 				}
+				return queuedDownload{ID: downloadID, Pending: true}, nil
+			}
+			// Headless mode: nobody can approve the request
+			return queuedDownload{}, &requestError{
+				Status:  http.StatusConflict,
+				Code:    apiCodeApprovalRequired,
+				Message: "Download rejected: Duplicate download or approval required (Headless mode)",
 			}
 		}
 	}
 
 	// Add via service
-	newID, err := service.Add(urlForAdd, outPath, req.Filename, mirrorsForAdd, req.Headers)
+	newID, err := service.Add(urlForAdd, outPath, req.Filename, mirrorsForAdd, req.Headers, types.DownloadOptions{
+		Checksum:   req.Checksum,
+		StartAt:    startAt,
+		Window:     req.Window,
+		SpeedLimit: speedLimit,
+		Extract:    extractMode,
+		Variant:    req.Variant,
+		Priority:   priority,
+		Category:   req.Category,
+		DefaultDir: defaultDir,
+
+		ExpectedSize: req.Size,
+	})
 	if err != nil {
-		http.Error(w, "Failed to add download: "+err.Error(), http.StatusInternalServerError)
-		return
+		return queuedDownload{}, internalError("Failed to add download: " + err.Error())
 	}
 
 	// Increment active downloads counter
 	atomic.AddInt32(&activeDownloads, 1)
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{
-		"status":  "queued",
-		"message": "Download queued successfully",
-		"id":      newID,
-	}); err != nil {
-		utils.Debug("Failed to encode response: %v", err)
-	}
+	return queuedDownload{ID: newID}, nil
 }
 
 // processDownloads handles the logic of adding downloads either to local pool or remote server
+// opts is applied to every URL in the batch.
 // Returns the number of successfully added downloads
-func processDownloads(urls []string, outputDir string, port int) int {
+func processDownloads(urls []string, outputDir string, port int, opts types.DownloadOptions) int {
 	successCount := 0
 
 	// If port > 0, we are sending to a remote server
 	if port > 0 {
 		for _, arg := range urls {
+			if isMetalinkArg(arg) {
+				files, err := loadMetalink(arg)
+				if err != nil {
+					fmt.Printf("Error adding %s: %v\n", arg, err)
+					continue
+				}
+				for _, f := range files {
+					if err := sendToServer(f.URLs[0], f.URLs, outputDir, f.Name, port, metalinkOptions(opts, f)); err != nil {
+						fmt.Printf("Error adding %s: %v\n", f.Name, err)
+					} else {
+						successCount++
+					}
+				}
+				continue
+			}
+			if isTorrentArg(arg) {
+				data, err := loadTorrent(arg)
+				if err == nil {
+					err = sendTorrentToServer(data, outputDir, port, opts)
+				}
+				if err != nil {
+					fmt.Printf("Error adding %s: %v\n", arg, err)
+				} else {
+					successCount++
+				}
+				continue
+			}
+
 			url, mirrors := ParseURLArg(arg)
 			if url == "" {
 				continue
 			}
-			err := sendToServer(url, mirrors, outputDir, port)
+			err := sendToServer(url, mirrors, outputDir, "", port, opts)
 			if err != nil {
 				fmt.Printf("Error adding %s: %v\n", url, err)
 			} else {
@@ -848,11 +1206,6 @@ func processDownloads(urls []string, outputDir string, port int) int {
 			continue
 		}
 
-		url, mirrors := ParseURLArg(arg)
-		if url == "" {
-			continue
-		}
-
 		// Prepare output path
 		outPath := outputDir
 		if outPath == "" {
@@ -873,7 +1226,40 @@ func processDownloads(urls []string, outputDir string, port int) int {
 		// But processDownloads is called from QUEUE init routine, primarily for CLI args.
 		// If CLI args provided, user probably wants them added immediately.
 
-		_, err := GlobalService.Add(url, outPath, "", mirrors, nil)
+		if isMetalinkArg(arg) {
+			files, err := loadMetalink(arg)
+			if err != nil {
+				fmt.Printf("Error adding %s: %v\n", arg, err)
+				continue
+			}
+			for _, f := range files {
+				if _, err := GlobalService.Add(f.URLs[0], outPath, f.Name, f.URLs, opts.Headers, metalinkOptions(opts, f)); err != nil {
+					fmt.Printf("Error adding %s: %v\n", f.Name, err)
+					continue
+				}
+				atomic.AddInt32(&activeDownloads, 1)
+				successCount++
+			}
+			continue
+		}
+
+		url, mirrors := ParseURLArg(arg)
+		if isTorrentArg(arg) {
+			data, err := loadTorrent(arg)
+			if err == nil {
+				url, err = torrent.Import(data)
+			}
+			if err != nil {
+				fmt.Printf("Error adding %s: %v\n", arg, err)
+				continue
+			}
+			mirrors = nil
+		}
+		if url == "" {
+			continue
+		}
+
+		_, err := GlobalService.Add(url, outPath, "", mirrors, opts.Headers, opts)
 		if err != nil {
 			fmt.Printf("Error adding %s: %v\n", url, err)
 			continue
@@ -911,6 +1297,7 @@ func initializeGlobalState() {
 
 	// Config engine state
 	state.Configure(filepath.Join(stateDir, "surge.db"))
+	cookies.Configure(filepath.Join(config.GetSurgeDir(), "cookies.txt"))
 
 	// Config logging
 	utils.ConfigureDebug(logsDir)
@@ -14,7 +14,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
+	"github.com/surge-downloader/surge/internal/webui"
 )
 
 var serverCmd = &cobra.Command{
@@ -51,6 +53,9 @@ var serverStartCmd = &cobra.Command{
 		outputDir, _ := cmd.Flags().GetString("output")
 		exitWhenDone, _ := cmd.Flags().GetBool("exit-when-done")
 		noResume, _ := cmd.Flags().GetBool("no-resume")
+		webUIEnabled, _ = cmd.Flags().GetBool("web")
+		metricsEnabled, _ = cmd.Flags().GetBool("metrics")
+		tlsForced, _ = cmd.Flags().GetBool("tls")
 
 		// Save current PID to file
 		savePID()
@@ -132,6 +137,9 @@ func init() {
 	serverStartCmd.Flags().StringP("output", "o", "", "Default output directory")
 	serverStartCmd.Flags().Bool("exit-when-done", false, "Exit when all downloads complete")
 	serverStartCmd.Flags().Bool("no-resume", false, "Do not auto-resume paused downloads on startup")
+	serverStartCmd.Flags().Bool("web", false, "Serve the web dashboard at /ui/")
+	serverStartCmd.Flags().Bool("metrics", false, "Serve Prometheus metrics at /metrics")
+	serverStartCmd.Flags().Bool("tls", false, "Serve the API over HTTPS (same as the daemon_tls setting)")
 }
 
 func savePID() {
@@ -180,6 +188,12 @@ func startServerLogic(cmd *cobra.Command, args []string, portFlag int, batchFile
 		}
 	}
 
+	listener, fingerprint, err := daemonListener(listener)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize Service
 	GlobalService = core.NewLocalDownloadServiceWithInput(GlobalPool, GlobalProgressCh)
 
@@ -203,13 +217,24 @@ func startServerLogic(cmd *cobra.Command, args []string, portFlag int, batchFile
 		}
 
 		if len(urls) > 0 {
-			processDownloads(urls, outputDir, 0)
+			processDownloads(urls, outputDir, 0, types.DownloadOptions{})
 		}
 	}()
 
 	fmt.Printf("Surge %s running in server mode.\n", Version)
 	host := getServerBindHost()
 	fmt.Printf("Serving on %s:%d\n", host, port)
+	scheme := "http"
+	if fingerprint != "" {
+		scheme = "https"
+		fmt.Printf("TLS certificate: %s\n", fingerprint)
+	}
+	if webUIEnabled {
+		fmt.Printf("Web dashboard: %s://%s:%d%s\n", scheme, host, port, webui.Prefix)
+	}
+	if metricsEnabled {
+		fmt.Printf("Metrics: %s://%s:%d/metrics\n", scheme, host, port)
+	}
 	fmt.Println("Press Ctrl+C to exit.")
 
 	StartHeadlessConsumer()
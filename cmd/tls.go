@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/tlsutil"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// tlsForced serves the API over HTTPS whatever the settings say (server start --tls)
+var tlsForced bool
+
+// daemonListener wraps ln in TLS when the daemon_tls setting or --tls asks for
+// it, using the configured certificate or a self-signed one generated on first
+// start. It returns the certificate's fingerprint, or "" for plain HTTP.
+func daemonListener(ln net.Listener) (net.Listener, string, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		settings = config.DefaultSettings()
+	}
+	if !tlsForced && !settings.Network.DaemonTLS {
+		return ln, "", nil
+	}
+
+	var cert tls.Certificate
+	certFile, keyFile := settings.Network.DaemonTLSCert, settings.Network.DaemonTLSKey
+	if certFile == "" && keyFile == "" {
+		var created bool
+		cert, created, err = tlsutil.LoadOrCreate(
+			filepath.Join(config.GetSurgeDir(), "daemon-cert.pem"),
+			filepath.Join(config.GetSurgeDir(), "daemon-key.pem"),
+		)
+		if created {
+			utils.Debug("Generated self-signed daemon certificate")
+		}
+	} else {
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	fingerprint := tlsutil.CertificateFingerprint(cert)
+	utils.Debug("Serving HTTPS, certificate %s", fingerprint)
+	return tlsutil.NewListener(ln, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}), fingerprint, nil
+}
+
+// knownDaemonsFile holds the certificate fingerprints pinned by surge connect
+func knownDaemonsFile() string {
+	return filepath.Join(config.GetSurgeDir(), "known_daemons")
+}
+
+// resolveDaemonTLS decides how surge connect trusts an https:// daemon. A
+// certificate the system trusts needs nothing (nil). Otherwise the daemon must
+// match its pinned fingerprint, or the expected one when given; with neither,
+// it is trusted on first use and pinned. notice describes a new pin.
+func resolveDaemonTLS(baseURL, expected string) (cfg *tls.Config, notice string, err error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme != "https" {
+		return nil, "", err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	if expected != "" {
+		if expected, err = tlsutil.NormalizeFingerprint(expected); err != nil {
+			return nil, "", err
+		}
+	}
+
+	pinned, err := tlsutil.LookupPin(knownDaemonsFile(), addr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	presented, trusted, err := tlsutil.Probe(ctx, addr, u.Hostname())
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case expected != "":
+		if presented != expected {
+			return nil, "", fmt.Errorf("%s presented certificate %s, not the expected %s", addr, presented, expected)
+		}
+		if presented != pinned {
+			if err := tlsutil.SavePin(knownDaemonsFile(), addr, presented); err != nil {
+				return nil, "", err
+			}
+		}
+		return tlsutil.PinnedConfig(presented), "", nil
+
+	case pinned != "":
+		if presented != pinned {
+			return nil, "", fmt.Errorf("the certificate of %s has CHANGED (now %s, pinned %s). "+
+				"If this is expected, reconnect with --fingerprint %s; otherwise someone may be intercepting the connection",
+				addr, presented, pinned, presented)
+		}
+		return tlsutil.PinnedConfig(pinned), "", nil
+
+	case trusted:
+		return nil, "", nil
+	}
+
+	if err := tlsutil.SavePin(knownDaemonsFile(), addr, presented); err != nil {
+		return nil, "", err
+	}
+	notice = fmt.Sprintf("Trusting the certificate of %s on first use:\n  %s\n"+
+		"Compare it with the fingerprint printed by `surge server start`. It is pinned in %s.",
+		addr, presented, knownDaemonsFile())
+	return tlsutil.PinnedConfig(presented), notice, nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/tlsutil"
+)
+
+// serveTestTLS serves a self-signed daemon on loopback and returns its URL and fingerprint
+func serveTestTLS(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	cert, _, err := tlsutil.LoadOrCreate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	go func() { _ = srv.Serve(tlsutil.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}})) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return "https://" + ln.Addr().String(), tlsutil.CertificateFingerprint(cert)
+}
+
+func TestResolveDaemonTLS_TrustOnFirstUse(t *testing.T) {
+	t.Cleanup(func() { _ = os.Remove(knownDaemonsFile()) })
+	baseURL, fingerprint := serveTestTLS(t)
+
+	cfg, notice, err := resolveDaemonTLS(baseURL, "")
+	if err != nil || cfg == nil {
+		t.Fatalf("First connection: cfg %v, err %v", cfg, err)
+	}
+	if !strings.Contains(notice, fingerprint) {
+		t.Errorf("Notice %q does not show the fingerprint %s", notice, fingerprint)
+	}
+
+	// Later connections use the pin silently
+	cfg, notice, err = resolveDaemonTLS(baseURL, "")
+	if err != nil || cfg == nil || notice != "" {
+		t.Fatalf("Second connection: cfg %v, notice %q, err %v", cfg, notice, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(baseURL + "/health")
+	if err != nil {
+		t.Fatalf("Request with the pinned config: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestResolveDaemonTLS_ChangedCertificate(t *testing.T) {
+	t.Cleanup(func() { _ = os.Remove(knownDaemonsFile()) })
+	baseURL, fingerprint := serveTestTLS(t)
+	addr := strings.TrimPrefix(baseURL, "https://")
+
+	if err := tlsutil.SavePin(knownDaemonsFile(), addr, "sha256:"+strings.Repeat("00", 32)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := resolveDaemonTLS(baseURL, ""); err == nil || !strings.Contains(err.Error(), "CHANGED") {
+		t.Fatalf("Changed certificate: err = %v", err)
+	}
+
+	// The expected fingerprint must match what the daemon presents
+	if _, _, err := resolveDaemonTLS(baseURL, "sha256:"+strings.Repeat("11", 32)); err == nil {
+		t.Error("Wrong --fingerprint should fail")
+	}
+
+	// Confirming the new fingerprint replaces the pin
+	if _, _, err := resolveDaemonTLS(baseURL, strings.ToUpper(strings.TrimPrefix(fingerprint, "sha256:"))); err != nil {
+		t.Fatalf("Matching --fingerprint: %v", err)
+	}
+	if pinned, _ := tlsutil.LookupPin(knownDaemonsFile(), addr); pinned != fingerprint {
+		t.Errorf("Pin = %s, want %s", pinned, fingerprint)
+	}
+}
+
+func TestResolveDaemonTLS_PlainHTTP(t *testing.T) {
+	cfg, notice, err := resolveDaemonTLS("http://127.0.0.1:1700", "")
+	if cfg != nil || notice != "" || err != nil {
+		t.Errorf("Plain HTTP: cfg %v, notice %q, err %v", cfg, notice, err)
+	}
+}
@@ -1,20 +1,125 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/config"
 )
 
 var tokenCmd = &cobra.Command{
 	Use:   "token",
 	Short: "Print the auth token used by the Surge daemon",
+	Long: `Print the daemon's own token, which may do anything.
+
+Use the create, list and revoke subcommands to manage named tokens with limited
+scopes for other people and machines.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		token := ensureAuthToken()
 		fmt.Println(token)
 	},
 }
 
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a named token with limited scopes",
+	Long: `Create a named token and print it. The token is shown only once.
+
+Scopes:
+  read     list downloads, history and events
+  add      queue downloads (includes read)
+  control  pause, resume, delete and limit downloads (includes read)
+  admin    everything, including the global speed limit
+
+With --dir, downloads may only be saved in the given directories (and below);
+requests without a path go to the first one.`,
+	Example: `  surge token create laptop-extension --scopes add --dir ~/Downloads/shared`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		scopesFlag, _ := cmd.Flags().GetString("scopes")
+		dirs, _ := cmd.Flags().GetStringArray("dir")
+
+		scopes, err := auth.ParseScopes(scopesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		secret, err := auth.Create(tokensFile(), args[0], scopes, dirs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating token: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(secret)
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List named tokens",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tokens, err := auth.Load(tokensFile())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading tokens: %v\n", err)
+			os.Exit(1)
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No named tokens.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tSCOPES\tDIRECTORIES\tCREATED")
+		for _, t := range tokens {
+			scopes := make([]string, len(t.Scopes))
+			for i, s := range t.Scopes {
+				scopes[i] = string(s)
+			}
+			dirs := "any"
+			if len(t.Dirs) > 0 {
+				dirs = strings.Join(t.Dirs, ", ")
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, strings.Join(scopes, ","), dirs, t.Created.Local().Format("2006-01-02 15:04"))
+		}
+		_ = w.Flush()
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke a named token",
+	Long:  `Revoke a named token. A running daemon stops accepting it immediately.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := auth.Revoke(tokensFile(), args[0]); err != nil {
+			if errors.Is(err, auth.ErrNotFound) {
+				fmt.Fprintf(os.Stderr, "Error: no token named %q\n", args[0])
+			} else {
+				fmt.Fprintf(os.Stderr, "Error revoking token: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("Revoked token %s\n", args[0])
+	},
+}
+
+// tokensFile holds the named tokens
+func tokensFile() string {
+	return filepath.Join(config.GetSurgeDir(), "tokens.json")
+}
+
 func init() {
 	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	tokenCreateCmd.Flags().String("scopes", string(auth.ScopeRead), "Comma-separated scopes: read, add, control, admin")
+	tokenCreateCmd.Flags().StringArray("dir", nil, "Allowed output directory (repeatable); default any")
 }
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/websocket"
+)
+
+// newScopedTestAPI serves the API with a named token for each scope and returns
+// the tokens by scope, plus one restricted to a directory under "dir"
+func newScopedTestAPI(t *testing.T) (*fakeService, http.Handler, map[string]string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	secrets := make(map[string]string)
+	for _, scope := range auth.Scopes {
+		secret, err := auth.Create(path, string(scope)+"-token", []auth.Scope{scope}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[string(scope)] = secret
+	}
+	shared := filepath.Join(t.TempDir(), "shared")
+	secret, err := auth.Create(path, "extension", []auth.Scope{auth.ScopeAdd}, []string{shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets["dir"] = secret
+
+	svc := &fakeService{downloads: []types.DownloadStatus{{ID: "a", Filename: "a.iso", Status: "downloading"}}}
+	keyring := auth.NewKeyring("secret", path)
+	mux := http.NewServeMux()
+	registerAPI(mux, t.TempDir(), svc)
+	mux.HandleFunc("/limit", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/move", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(w, r, t.TempDir(), svc)
+	})
+	return svc, authMiddleware(keyring, mux), secrets
+}
+
+func doAs(h http.Handler, secret, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+secret)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestScopedTokens_Routes(t *testing.T) {
+	_, h, secrets := newScopedTestAPI(t)
+	add := `{"url": "https://example.com/f.bin", "skip_approval": true}`
+
+	tests := []struct {
+		scope  string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"read", http.MethodGet, "/api/v1/downloads", "", http.StatusOK},
+		{"read", http.MethodPost, "/api/v1/downloads", add, http.StatusForbidden},
+		{"read", http.MethodDelete, "/api/v1/downloads/a", "", http.StatusForbidden},
+		{"add", http.MethodGet, "/api/v1/downloads", "", http.StatusOK},
+		{"add", http.MethodPost, "/api/v1/downloads", add, http.StatusCreated},
+		{"add", http.MethodDelete, "/api/v1/downloads/a", "", http.StatusForbidden},
+		{"add", http.MethodPatch, "/api/v1/downloads/a", `{"status": "paused"}`, http.StatusForbidden},
+		{"control", http.MethodPost, "/api/v1/downloads", add, http.StatusForbidden},
+		{"control", http.MethodPatch, "/api/v1/downloads/a", `{"status": "paused"}`, http.StatusOK},
+		{"control", http.MethodDelete, "/api/v1/downloads/a", "", http.StatusNoContent},
+		{"control", http.MethodPost, "/limit?id=a&rate=1MB", "", http.StatusOK},
+		{"control", http.MethodPost, "/limit?rate=1MB", "", http.StatusForbidden},
+		{"admin", http.MethodPost, "/limit?rate=1MB", "", http.StatusOK},
+		{"add", http.MethodPost, "/move?id=a&to=top", "", http.StatusForbidden},
+		{"control", http.MethodPost, "/move?id=a&to=top", "", http.StatusOK},
+		{"admin", http.MethodDelete, "/api/v1/downloads/a", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		rec := doAs(h, secrets[tt.scope], tt.method, tt.path, tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s token: %s %s = %d, want %d (%s)", tt.scope, tt.method, tt.path, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	expectAPIError(t, doAs(h, secrets["read"], http.MethodDelete, "/api/v1/downloads/a", ""), http.StatusForbidden, apiCodeForbidden)
+	expectAPIError(t, doAs(h, "unknown", http.MethodGet, "/api/v1/downloads", ""), http.StatusUnauthorized, apiCodeUnauthorized)
+}
+
+func TestScopedTokens_Dirs(t *testing.T) {
+	svc, h, secrets := newScopedTestAPI(t)
+
+	// Without a path the download goes to the token's directory
+	rec := doAs(h, secrets["dir"], http.MethodPost, "/api/v1/downloads", `{"url": "https://example.com/1.bin", "skip_approval": true}`)
+	if rec.Code != http.StatusCreated || len(svc.paths) != 1 || filepath.Base(svc.paths[0]) != "shared" {
+		t.Fatalf("POST without path = %d, paths %v: %s", rec.Code, svc.paths, rec.Body.String())
+	}
+	shared := svc.paths[0]
+
+	body, _ := json.Marshal(DownloadRequest{URL: "https://example.com/2.bin", Path: filepath.Join(shared, "isos"), SkipApproval: true})
+	if rec := doAs(h, secrets["dir"], http.MethodPost, "/api/v1/downloads", string(body)); rec.Code != http.StatusCreated {
+		t.Errorf("POST below the allowed directory = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body, _ = json.Marshal(DownloadRequest{URL: "https://example.com/3.bin", Path: t.TempDir(), SkipApproval: true})
+	expectAPIError(t, doAs(h, secrets["dir"], http.MethodPost, "/api/v1/downloads", string(body)), http.StatusForbidden, apiCodeForbidden)
+	if len(svc.added) != 2 {
+		t.Errorf("Added %v, want two downloads", svc.added)
+	}
+}
+
+func TestScopedTokens_WebSocket(t *testing.T) {
+	_, h, secrets := newScopedTestAPI(t)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	conn, err := websocket.Dial(context.Background(), srv.URL+"/ws", http.Header{"Authorization": {"Bearer " + secrets["add"]}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	if msg := wsCall(t, conn, core.WSCommand{ID: "1", Type: core.WSCommandList}); msg.Error != nil {
+		t.Errorf("list with an add token: %+v", msg.Error)
+	}
+	if msg := wsCall(t, conn, core.WSCommand{ID: "2", Type: core.WSCommandDelete, DownloadID: "a"}); msg.Error == nil || msg.Error.Code != apiCodeForbidden {
+		t.Errorf("delete with an add token: %+v", msg.Error)
+	}
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/torrent"
+)
+
+// maxTorrentFileSize bounds .torrent files read from disk or fetched over HTTP
+const maxTorrentFileSize = 16 << 20
+
+// isTorrentArg reports whether a CLI argument names a .torrent file (a local path
+// or a URL to one) rather than a comma-separated mirror list
+func isTorrentArg(arg string) bool {
+	return !strings.Contains(arg, ",") && torrent.IsTorrentFile(arg)
+}
+
+// loadTorrent reads a .torrent file from a local path or an HTTP(S) URL
+func loadTorrent(arg string) ([]byte, error) {
+	var r io.Reader
+	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch torrent: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch torrent: %s", resp.Status)
+		}
+		r = resp.Body
+	} else {
+		file, err := os.Open(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open torrent: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		r = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxTorrentFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read torrent: %w", err)
+	}
+	if len(data) > maxTorrentFileSize {
+		return nil, fmt.Errorf("torrent file is too large")
+	}
+	return data, nil
+}
@@ -9,10 +9,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/x/term"
 	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/download"
 	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/torrent"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
 )
@@ -51,6 +56,10 @@ func readURLsFromFile(filepath string) ([]string, error) {
 // ParseURLArg parses a command line argument that might contain comma-separated mirrors
 // Returns the primary URL and a list of all mirrors (including the primary)
 func ParseURLArg(arg string) (string, []string) {
+	// Magnet links have no mirrors and may contain commas in their display name
+	if trimmed := strings.TrimSpace(arg); torrent.IsMagnet(trimmed) {
+		return trimmed, nil
+	}
 	parts := strings.Split(arg, ",")
 	var urls []string
 	for _, p := range parts {
@@ -64,13 +73,80 @@ func ParseURLArg(arg string) (string, []string) {
 	return urls[0], urls
 }
 
+// parseHeaders parses repeated "Key: Value" flags into request headers
+func parseHeaders(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(values))
+	for _, v := range values {
+		key, val, ok := strings.Cut(v, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid header %q: expected \"Key: Value\"", v)
+		}
+		headers[http.CanonicalHeaderKey(key)] = strings.TrimSpace(val)
+	}
+	return headers, nil
+}
+
+// promptPassword reads a password from the terminal without echoing it
+func promptPassword(user string) (string, error) {
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the password of %s: use --password or user:password", user)
+	}
+	fmt.Fprintf(os.Stderr, "Password for %s: ", user)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
 // sendToServer sends a download request to a running surge server
-func sendToServer(url string, mirrors []string, outPath string, port int) error {
+func sendToServer(url string, mirrors []string, outPath string, filename string, port int, opts types.DownloadOptions) error {
+	return postDownloadRequest(newDownloadRequest(url, mirrors, outPath, filename, opts), port)
+}
+
+// sendTorrentToServer sends the contents of a .torrent file to a running surge server
+func sendTorrentToServer(data []byte, outPath string, port int, opts types.DownloadOptions) error {
+	reqBody := newDownloadRequest("", nil, outPath, "", opts)
+	reqBody.Torrent = data
+	return postDownloadRequest(reqBody, port)
+}
+
+// newDownloadRequest builds the /download request body for a URL and its options
+func newDownloadRequest(url string, mirrors []string, outPath string, filename string, opts types.DownloadOptions) DownloadRequest {
 	reqBody := DownloadRequest{
-		URL:     url,
-		Mirrors: mirrors,
-		Path:    outPath,
+		URL:      url,
+		Filename: filename,
+		Mirrors:  mirrors,
+		Path:     outPath,
+		Checksum: opts.Checksum,
+		Window:   opts.Window,
+		Extract:  opts.Extract,
+		Variant:  opts.Variant,
+		Category: opts.Category,
+		Size:     opts.ExpectedSize,
+		Cookies:  opts.Cookies,
+		Headers:  opts.Headers,
 	}
+	if opts.Priority != download.PriorityNormal {
+		reqBody.Priority = download.PriorityName(opts.Priority)
+	}
+	if !opts.StartAt.IsZero() {
+		reqBody.StartAt = opts.StartAt.Format(time.RFC3339)
+	}
+	if opts.SpeedLimit > 0 {
+		reqBody.Limit = strconv.FormatInt(opts.SpeedLimit, 10)
+	}
+	return reqBody
+}
+
+// postDownloadRequest posts a download request to a running surge server
+func postDownloadRequest(reqBody DownloadRequest, port int) error {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/webui"
+)
+
+// sessionCookie carries the token for the web dashboard, which cannot put it in
+// an Authorization header on EventSource requests. It only authorizes GET
+// requests for the dashboard and its event streams (see cookieAllowed).
+const sessionCookie = "surge_token"
+
+// webUIEnabled serves the web dashboard from the daemon (server start --web)
+var webUIEnabled bool
+
+// registerWebUI serves the dashboard under /ui/ with its sign-in page
+func registerWebUI(mux *http.ServeMux, keyring *auth.Keyring) {
+	mux.Handle(webui.Prefix, webui.Handler())
+	mux.HandleFunc(webui.LoginPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			webui.ServeLogin(w, r)
+		case http.MethodPost:
+			handleWebLogin(w, r, keyring)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc(webui.SessionPath, func(w http.ResponseWriter, r *http.Request) {
+		// The dashboard's scripts send the token as a bearer token on API calls
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			http.Error(w, "Not signed in", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": cookie.Value})
+	})
+	mux.HandleFunc(webui.Prefix+"logout", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+		http.Redirect(w, r, webui.LoginPath, http.StatusSeeOther)
+	})
+}
+
+// handleWebLogin checks the submitted token and stores it in the session cookie
+func handleWebLogin(w http.ResponseWriter, r *http.Request, keyring *auth.Keyring) {
+	token := r.PostFormValue("token")
+	if keyring.Lookup(token) == nil {
+		http.Redirect(w, r, webui.LoginPath+"?failed=1", http.StatusSeeOther)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// Other sites must not be able to act with the dashboard's session
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, webui.Prefix, http.StatusSeeOther)
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/webui"
+)
+
+func newTestWebUI(t *testing.T) http.Handler {
+	t.Helper()
+	svc, _ := newTestAPI(t)
+	mux := http.NewServeMux()
+	registerAPI(mux, t.TempDir(), svc)
+	keyring := auth.NewKeyring("secret", "")
+	registerWebUI(mux, keyring)
+	return authMiddleware(keyring, mux)
+}
+
+func serveWeb(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func login(t *testing.T, h http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"token": {token}}
+	req := httptest.NewRequest(http.MethodPost, webui.LoginPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serveWeb(h, req)
+}
+
+func TestWebUI_SignIn(t *testing.T) {
+	h := newTestWebUI(t)
+
+	// Signed out, the dashboard sends the browser to the sign-in page, which is public
+	rec := serveWeb(h, httptest.NewRequest(http.MethodGet, webui.Prefix, nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != webui.LoginPath {
+		t.Fatalf("Signed-out GET %s = %d to %q", webui.Prefix, rec.Code, rec.Header().Get("Location"))
+	}
+	rec = serveWeb(h, httptest.NewRequest(http.MethodGet, webui.LoginPath, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="token"`) {
+		t.Fatalf("Sign-in page = %d", rec.Code)
+	}
+
+	rec = login(t, h, "wrong")
+	if rec.Header().Get("Location") != webui.LoginPath+"?failed=1" || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("Wrong token: %d to %q, cookies %v", rec.Code, rec.Header().Get("Location"), rec.Result().Cookies())
+	}
+
+	rec = login(t, h, "secret")
+	cookies := rec.Result().Cookies()
+	if rec.Header().Get("Location") != webui.Prefix || len(cookies) != 1 {
+		t.Fatalf("Sign-in: %d to %q, cookies %v", rec.Code, rec.Header().Get("Location"), cookies)
+	}
+	cookie := cookies[0]
+	if cookie.Name != sessionCookie || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Cookie = %+v", cookie)
+	}
+
+	// The cookie opens the dashboard, which fetches the token for its API calls
+	for _, path := range []string{webui.Prefix, webui.Prefix + "app.js", webui.SessionPath} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(cookie)
+		if rec := serveWeb(h, req); rec.Code != http.StatusOK {
+			t.Errorf("GET %s with cookie = %d", path, rec.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, webui.SessionPath, nil)
+	req.AddCookie(cookie)
+	var session struct{ Token string }
+	if err := json.NewDecoder(serveWeb(h, req).Body).Decode(&session); err != nil || session.Token != "secret" {
+		t.Errorf("Session = %+v (%v), want the signed-in token", session, err)
+	}
+
+	// A forged cookie does not
+	req = httptest.NewRequest(http.MethodGet, webui.Prefix, nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "secreT"})
+	if rec := serveWeb(h, req); rec.Code != http.StatusSeeOther {
+		t.Errorf("Forged cookie = %d, want redirect to sign-in", rec.Code)
+	}
+}
+
+func TestWebUI_CookieDoesNotAuthorizeAPI(t *testing.T) {
+	h := newTestWebUI(t)
+	cookie := login(t, h, "secret").Result().Cookies()[0]
+
+	// Other sites can make the browser send the cookie, so it never authorizes
+	// the API or a change on its own
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/downloads", nil),
+		httptest.NewRequest(http.MethodPost, "/api/v1/downloads", strings.NewReader(`{"url":"https://example.com/a"}`)),
+		httptest.NewRequest(http.MethodPost, "/pause?id=1", nil),
+		httptest.NewRequest(http.MethodPost, webui.Prefix, nil),
+	}
+	for _, req := range requests {
+		req.AddCookie(cookie)
+		if rec := serveWeb(h, req); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s with cookie only = %d, want 401", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/downloads", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if rec := serveWeb(h, req); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/v1/downloads with bearer = %d", rec.Code)
+	}
+}
+
+func TestWebUI_SignOut(t *testing.T) {
+	h := newTestWebUI(t)
+	cookie := login(t, h, "secret").Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodPost, webui.Prefix+"logout", nil)
+	req.AddCookie(cookie)
+	rec := serveWeb(h, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Sign out = %d", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || cookies[0].MaxAge >= 0 {
+		t.Errorf("Sign out cookies = %v, want %s cleared", cookies, sessionCookie)
+	}
+}
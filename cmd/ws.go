@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/utils"
+	"github.com/surge-downloader/surge/internal/websocket"
+)
+
+// wsSession is one /ws connection: events flow out while commands are read and
+// answered in order
+type wsSession struct {
+	conn             *websocket.Conn
+	service          core.DownloadService
+	defaultOutputDir string
+	caller           *auth.Token // nil when the token was not checked
+
+	mu         sync.Mutex
+	subscribed map[string]bool // nil = every download
+}
+
+// handleWebSocket serves /ws. Authentication already happened in authMiddleware.
+func handleWebSocket(w http.ResponseWriter, r *http.Request, defaultOutputDir string, service core.DownloadService) {
+	if service == nil {
+		http.Error(w, "Service unavailable", http.StatusInternalServerError)
+		return
+	}
+	conn, err := websocket.Accept(w, r)
+	if err != nil {
+		utils.Debug("WebSocket handshake failed: %v", err)
+		return
+	}
+	conn.SetIdleTimeout(core.WSIdleTimeout)
+
+	// The hijacked request's context no longer follows the connection
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, cleanup, err := service.StreamEvents(ctx)
+	if err != nil {
+		_ = conn.CloseWithStatus(websocket.CloseInternalError, "Failed to subscribe to events")
+		return
+	}
+	defer cleanup()
+
+	s := &wsSession{conn: conn, service: service, defaultOutputDir: defaultOutputDir, caller: auth.FromContext(r.Context())}
+	go func() {
+		s.pumpEvents(ctx, stream)
+		// A failed write leaves the reader blocked until the idle timeout
+		_ = conn.Close()
+	}()
+	s.readCommands()
+	_ = conn.Close()
+}
+
+// pumpEvents forwards subscribed events and keeps the connection alive with pings
+func (s *wsSession) pumpEvents(ctx context.Context, stream <-chan interface{}) {
+	ping := time.NewTicker(core.WSPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case msg, ok := <-stream:
+			if !ok {
+				return
+			}
+			if !s.wants(events.DownloadID(msg)) {
+				continue
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				utils.Debug("Error marshaling event: %v", err)
+				continue
+			}
+			if err := s.conn.WriteJSON(core.WSMessage{Type: core.WSMessageEvent, Event: events.Name(msg), Data: data}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wants reports whether events of a download pass the subscription filter
+func (s *wsSession) wants(downloadID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribed == nil || downloadID == "" || s.subscribed[downloadID]
+}
+
+func (s *wsSession) readCommands() {
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				utils.Debug("WebSocket read failed: %v", err)
+			}
+			return
+		}
+
+		var cmd core.WSCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			s.reply(cmd.ID, nil, &core.WSError{Code: apiCodeBadRequest, Message: "Invalid JSON: " + err.Error()})
+			continue
+		}
+		result, cmdErr := s.run(cmd)
+		s.reply(cmd.ID, result, cmdErr)
+	}
+}
+
+// run executes a command and returns its result
+func (s *wsSession) run(cmd core.WSCommand) (any, *core.WSError) {
+	if scope := wsCommandScope(cmd); s.caller != nil && !s.caller.Allows(scope) {
+		return nil, &core.WSError{Code: apiCodeForbidden, Message: fmt.Sprintf("Token %q lacks the %s scope", s.caller.Name, scope)}
+	}
+
+	switch cmd.Type {
+	case core.WSCommandAdd:
+		var req DownloadRequest
+		if err := json.Unmarshal(cmd.Request, &req); err != nil {
+			return nil, &core.WSError{Code: apiCodeBadRequest, Message: "Invalid request: " + err.Error()}
+		}
+		queued, err := queueDownload(req, s.defaultOutputDir, s.service, s.caller)
+		if err != nil {
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				return nil, &core.WSError{Code: reqErr.Code, Message: reqErr.Message}
+			}
+			return nil, &core.WSError{Code: apiCodeInternal, Message: err.Error()}
+		}
+		if queued.Pending {
+			return DownloadCreated{ID: queued.ID, Status: "pending_approval"}, nil
+		}
+		return DownloadCreated{ID: queued.ID, Status: "queued"}, nil
+
+	case core.WSCommandPause, core.WSCommandResume, core.WSCommandDelete, core.WSCommandGet:
+		if cmd.DownloadID == "" {
+			return nil, &core.WSError{Code: apiCodeBadRequest, Message: "Missing download_id"}
+		}
+		var (
+			result any
+			err    error
+		)
+		switch cmd.Type {
+		case core.WSCommandPause:
+			err = s.service.Pause(cmd.DownloadID)
+		case core.WSCommandResume:
+			err = s.service.Resume(cmd.DownloadID)
+		case core.WSCommandDelete:
+			err = s.service.Delete(cmd.DownloadID)
+		case core.WSCommandGet:
+			result, err = s.service.GetStatus(cmd.DownloadID)
+		}
+		return result, serviceWSError(err)
+
+	case core.WSCommandLimit:
+		if cmd.SpeedLimit < 0 {
+			return nil, &core.WSError{Code: apiCodeBadRequest, Message: "Invalid speed_limit"}
+		}
+		return nil, serviceWSError(s.service.SetSpeedLimit(cmd.DownloadID, cmd.SpeedLimit))
+
+	case core.WSCommandMove:
+		if cmd.DownloadID == "" {
+			return nil, &core.WSError{Code: apiCodeBadRequest, Message: "Missing download_id"}
+		}
+		if !validMoveDirection(cmd.Direction) {
+			return nil, &core.WSError{Code: apiCodeBadRequest, Message: `Invalid direction: must be "top", "up" or "down"`}
+		}
+		return nil, serviceWSError(s.service.Move(cmd.DownloadID, cmd.Direction))
+
+	case core.WSCommandSubscribe:
+		s.mu.Lock()
+		s.subscribed = nil
+		if len(cmd.DownloadIDs) > 0 {
+			s.subscribed = make(map[string]bool, len(cmd.DownloadIDs))
+			for _, id := range cmd.DownloadIDs {
+				s.subscribed[id] = true
+			}
+		}
+		s.mu.Unlock()
+		return map[string][]string{"download_ids": cmd.DownloadIDs}, nil
+
+	case core.WSCommandList:
+		statuses, err := s.service.List()
+		return statuses, serviceWSError(err)
+
+	case core.WSCommandHistory:
+		history, err := s.service.History()
+		return history, serviceWSError(err)
+	}
+	return nil, &core.WSError{Code: apiCodeBadRequest, Message: "Unknown command type: " + cmd.Type}
+}
+
+// wsCommandScope returns the scope a command needs, matching requiredScope
+func wsCommandScope(cmd core.WSCommand) auth.Scope {
+	switch cmd.Type {
+	case core.WSCommandAdd:
+		return auth.ScopeAdd
+	case core.WSCommandPause, core.WSCommandResume, core.WSCommandDelete, core.WSCommandMove:
+		return auth.ScopeControl
+	case core.WSCommandLimit:
+		if cmd.DownloadID == "" {
+			return auth.ScopeAdmin // The global speed limit
+		}
+		return auth.ScopeControl
+	}
+	return auth.ScopeRead
+}
+
+func (s *wsSession) reply(id string, result any, cmdErr *core.WSError) {
+	msg := core.WSMessage{Type: core.WSMessageResult, ID: id, Error: cmdErr}
+	if cmdErr == nil && result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &core.WSError{Code: apiCodeInternal, Message: err.Error()}
+		} else {
+			msg.Data = data
+		}
+	}
+	if err := s.conn.WriteJSON(msg); err != nil {
+		utils.Debug("WebSocket write failed: %v", err)
+	}
+}
+
+func serviceWSError(err error) *core.WSError {
+	if err == nil {
+		return nil
+	}
+	_, code := serviceErrorCode(err)
+	return &core.WSError{Code: code, Message: err.Error()}
+}
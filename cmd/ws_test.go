@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/auth"
+	"github.com/surge-downloader/surge/internal/core"
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/websocket"
+)
+
+// newTestWS serves /ws behind the auth middleware and returns its ws:// URL
+func newTestWS(t *testing.T) (*fakeService, string) {
+	t.Helper()
+	svc, _ := newTestAPI(t)
+	svc.events = make(chan interface{}, 10)
+	outDir := t.TempDir()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(w, r, outDir, svc)
+	})
+	srv := httptest.NewServer(authMiddleware(auth.NewKeyring("secret", ""), mux))
+	t.Cleanup(srv.Close)
+	return svc, "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+func dialTestWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{"Authorization": {"Bearer secret"}}
+	conn, err := websocket.Dial(context.Background(), url, header)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// wsCall sends a command and returns its result, skipping events
+func wsCall(t *testing.T, conn *websocket.Conn, cmd core.WSCommand) core.WSMessage {
+	t.Helper()
+	if err := conn.WriteJSON(cmd); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	for {
+		var msg core.WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ReadJSON failed: %v", err)
+		}
+		if msg.Type == core.WSMessageResult {
+			if msg.ID != cmd.ID {
+				t.Fatalf("Result for %q, want %q", msg.ID, cmd.ID)
+			}
+			return msg
+		}
+	}
+}
+
+func TestWS_Commands(t *testing.T) {
+	_, url := newTestWS(t)
+	conn := dialTestWS(t, url)
+
+	addReq, _ := json.Marshal(DownloadRequest{URL: "https://example.com/new.bin", Filename: "new.bin", SkipApproval: true})
+	tests := []struct {
+		name    string
+		cmd     core.WSCommand
+		errCode string
+	}{
+		{"Pause", core.WSCommand{Type: core.WSCommandPause, DownloadID: "a"}, ""},
+		{"Pause unknown", core.WSCommand{Type: core.WSCommandPause, DownloadID: "zzz"}, apiCodeNotFound},
+		{"Pause without id", core.WSCommand{Type: core.WSCommandPause}, apiCodeBadRequest},
+		{"Resume completed", core.WSCommand{Type: core.WSCommandResume, DownloadID: "d"}, apiCodeConflict},
+		{"Limit", core.WSCommand{Type: core.WSCommandLimit, DownloadID: "a", SpeedLimit: 1024}, ""},
+		{"Negative limit", core.WSCommand{Type: core.WSCommandLimit, DownloadID: "a", SpeedLimit: -1}, apiCodeBadRequest},
+		{"Move running", core.WSCommand{Type: core.WSCommandMove, DownloadID: "c", Direction: "top"}, apiCodeConflict},
+		{"Move sideways", core.WSCommand{Type: core.WSCommandMove, DownloadID: "c", Direction: "sideways"}, apiCodeBadRequest},
+		{"Delete", core.WSCommand{Type: core.WSCommandDelete, DownloadID: "b"}, ""},
+		{"Add", core.WSCommand{Type: core.WSCommandAdd, Request: addReq}, ""},
+		{"Add without URL", core.WSCommand{Type: core.WSCommandAdd, Request: json.RawMessage(`{"url": ""}`)}, apiCodeBadRequest},
+		{"Unknown type", core.WSCommand{Type: "reboot"}, apiCodeBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cmd.ID = tt.name
+			msg := wsCall(t, conn, tt.cmd)
+			switch {
+			case tt.errCode == "" && msg.Error != nil:
+				t.Errorf("Unexpected error %+v", msg.Error)
+			case tt.errCode != "" && (msg.Error == nil || msg.Error.Code != tt.errCode):
+				t.Errorf("Error = %+v, want code %q", msg.Error, tt.errCode)
+			}
+		})
+	}
+
+	// The commands above went through to the service
+	msg := wsCall(t, conn, core.WSCommand{ID: "get", Type: core.WSCommandGet, DownloadID: "a"})
+	var status types.DownloadStatus
+	if err := json.Unmarshal(msg.Data, &status); err != nil {
+		t.Fatalf("Bad get result %s: %v", msg.Data, err)
+	}
+	if status.Status != "paused" || status.SpeedLimit != 1024 {
+		t.Errorf("Status = %+v", status)
+	}
+
+	msg = wsCall(t, conn, core.WSCommand{ID: "list", Type: core.WSCommandList})
+	var list []types.DownloadStatus
+	if err := json.Unmarshal(msg.Data, &list); err != nil || len(list) != 5 || list[4].ID != "new-new.bin" {
+		t.Errorf("List = %s %v", msg.Data, err)
+	}
+}
+
+func TestWS_Subscribe(t *testing.T) {
+	svc, url := newTestWS(t)
+	conn := dialTestWS(t, url)
+
+	msg := wsCall(t, conn, core.WSCommand{ID: "1", Type: core.WSCommandSubscribe, DownloadIDs: []string{"b"}})
+	if msg.Error != nil {
+		t.Fatalf("Subscribe failed: %+v", msg.Error)
+	}
+
+	svc.events <- events.ProgressMsg{DownloadID: "a", Downloaded: 1}
+	svc.events <- events.ProgressMsg{DownloadID: "b", Downloaded: 2}
+
+	var event core.WSMessage
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != core.WSMessageEvent || event.Event != "progress" {
+		t.Fatalf("Got %+v, want a progress event", event)
+	}
+	decoded, err := events.Decode(event.Event, event.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := decoded.(events.ProgressMsg); p.DownloadID != "b" || p.Downloaded != 2 {
+		t.Errorf("Event = %+v, want download b only", p)
+	}
+
+	// Subscribing to nothing brings every download back
+	wsCall(t, conn, core.WSCommand{ID: "2", Type: core.WSCommandSubscribe})
+	svc.events <- events.DownloadPausedMsg{DownloadID: "a"}
+	if err := conn.ReadJSON(&event); err != nil || event.Event != "paused" {
+		t.Errorf("Got %+v %v, want paused event", event, err)
+	}
+}
+
+func TestWS_Auth(t *testing.T) {
+	_, url := newTestWS(t)
+
+	_, err := websocket.Dial(context.Background(), url, nil)
+	var hsErr *websocket.HandshakeError
+	if !errors.As(err, &hsErr) || hsErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Dial without token = %v, want 401", err)
+	}
+	if _, err := websocket.Dial(context.Background(), url+"?token=wrong", nil); err == nil {
+		t.Error("Dial with a wrong token succeeded")
+	}
+
+	// Browsers pass the token in the query
+	conn, err := websocket.Dial(context.Background(), url+"?token=secret", nil)
+	if err != nil {
+		t.Fatalf("Dial with query token failed: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestWS_CookieAuthChecksOrigin(t *testing.T) {
+	_, url := newTestWS(t)
+	self := "http" + strings.TrimSuffix(strings.TrimPrefix(url, "ws"), "/ws")
+	cookie := sessionCookie + "=secret"
+
+	// The dashboard's own pages may use the session cookie
+	conn, err := websocket.Dial(context.Background(), url, http.Header{"Cookie": {cookie}, "Origin": {self}})
+	if err != nil {
+		t.Fatalf("Same-origin dial with cookie failed: %v", err)
+	}
+	_ = conn.Close()
+
+	// Other sites may not, even though the browser sends the cookie along
+	for _, origin := range []string{"https://evil.example", ""} {
+		header := http.Header{"Cookie": {cookie}}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		_, err := websocket.Dial(context.Background(), url, header)
+		var hsErr *websocket.HandshakeError
+		if !errors.As(err, &hsErr) || hsErr.StatusCode != http.StatusForbidden {
+			t.Errorf("Dial with cookie from origin %q = %v, want 403", origin, err)
+		}
+	}
+
+	// A token in the query is not tied to the page's origin
+	conn, err = websocket.Dial(context.Background(), url+"?token=secret", http.Header{"Origin": {"https://evil.example"}})
+	if err != nil {
+		t.Fatalf("Dial with query token failed: %v", err)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,266 @@
+// Package auth manages the daemon's named API tokens. Each token has scopes that
+// limit what its holder may do and, optionally, the directories it may download
+// into. Only a SHA-256 hash of every token is stored.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope is a permission granted to a token
+type Scope string
+
+const (
+	ScopeRead    Scope = "read"    // List downloads, history and events
+	ScopeAdd     Scope = "add"     // Queue downloads (includes read)
+	ScopeControl Scope = "control" // Pause, resume, delete and limit downloads (includes read)
+	ScopeAdmin   Scope = "admin"   // Everything, including the global speed limit
+)
+
+// Scopes lists the valid scopes
+var Scopes = []Scope{ScopeRead, ScopeAdd, ScopeControl, ScopeAdmin}
+
+// ErrNotFound is returned when no token has the given name
+var ErrNotFound = errors.New("token not found")
+
+// Token is a named API token
+type Token struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"` // Hex SHA-256 of the secret
+	Scopes  []Scope   `json:"scopes"`
+	Dirs    []string  `json:"dirs,omitempty"` // Allowed output directories; empty allows any
+	Created time.Time `json:"created"`
+}
+
+// Owner is the identity of the daemon's own token, which may do anything
+var Owner = Token{Name: "owner", Scopes: []Scope{ScopeAdmin}}
+
+// Allows reports whether the token grants scope
+func (t *Token) Allows(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin || (scope == ScopeRead && (s == ScopeAdd || s == ScopeControl)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsDir reports whether the token may download into dir, an absolute path
+func (t *Token) AllowsDir(dir string) bool {
+	if len(t.Dirs) == 0 {
+		return true
+	}
+	dir = filepath.Clean(dir)
+	for _, allowed := range t.Dirs {
+		rel, err := filepath.Rel(allowed, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes parses a comma-separated list of scopes
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, part := range strings.Split(s, ",") {
+		scope := Scope(strings.ToLower(strings.TrimSpace(part)))
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q (want read, add, control or admin)", part)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// Load reads the tokens stored at path. A missing file holds no tokens.
+func Load(path string) ([]Token, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+func save(path string, tokens []Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write then rename, so a running daemon never reads a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Create adds a token to the file at path and returns its secret, which is not
+// stored and cannot be shown again. Dirs are made absolute.
+func Create(path, name string, scopes []Scope, dirs []string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == Owner.Name {
+		return "", fmt.Errorf("invalid token name %q", name)
+	}
+	tokens, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tokens {
+		if t.Name == name {
+			return "", fmt.Errorf("a token named %q already exists", name)
+		}
+	}
+
+	absDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		absDirs = append(absDirs, abs)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(buf)
+
+	tokens = append(tokens, Token{
+		Name:    name,
+		Hash:    hashSecret(secret),
+		Scopes:  scopes,
+		Dirs:    absDirs,
+		Created: time.Now().UTC().Truncate(time.Second),
+	})
+	if err := save(path, tokens); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Revoke removes the named token from the file at path
+func Revoke(path, name string) error {
+	tokens, err := Load(path)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tokens, func(t Token) bool { return t.Name == name })
+	if i < 0 {
+		return ErrNotFound
+	}
+	return save(path, slices.Delete(tokens, i, i+1))
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Keyring tells which token a secret belongs to. It rereads the token file when
+// it changes, so tokens created or revoked by the CLI apply to a running daemon.
+type Keyring struct {
+	owner string
+	path  string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	tokens  []Token
+}
+
+// NewKeyring accepts the owner secret plus the tokens stored at path. An empty
+// path means there are no named tokens.
+func NewKeyring(owner, path string) *Keyring {
+	return &Keyring{owner: owner, path: path}
+}
+
+// Lookup returns the token a secret belongs to, or nil
+func (k *Keyring) Lookup(secret string) *Token {
+	if secret == "" {
+		return nil
+	}
+	if len(secret) == len(k.owner) && subtle.ConstantTimeCompare([]byte(secret), []byte(k.owner)) == 1 {
+		owner := Owner
+		return &owner
+	}
+
+	hash := []byte(hashSecret(secret))
+	for _, t := range k.current() {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			return &t
+		}
+	}
+	return nil
+}
+
+// current returns the stored tokens, reloading them if the file changed
+func (k *Keyring) current() []Token {
+	if k.path == "" {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	info, err := os.Stat(k.path)
+	if err != nil {
+		// Missing or unreadable: every named token is revoked
+		k.tokens, k.modTime, k.size = nil, time.Time{}, 0
+		return nil
+	}
+	if !info.ModTime().Equal(k.modTime) || info.Size() != k.size {
+		tokens, err := Load(k.path)
+		if err != nil {
+			k.tokens = nil
+		} else {
+			k.tokens = tokens
+		}
+		k.modTime, k.size = info.ModTime(), info.Size()
+	}
+	return k.tokens
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller's token
+func NewContext(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the caller's token, or nil outside an authenticated request
+func FromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(contextKey{}).(*Token)
+	return t
+}
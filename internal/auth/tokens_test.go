@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToken_Allows(t *testing.T) {
+	tests := []struct {
+		scopes []Scope
+		scope  Scope
+		want   bool
+	}{
+		{[]Scope{ScopeRead}, ScopeRead, true},
+		{[]Scope{ScopeRead}, ScopeAdd, false},
+		{[]Scope{ScopeAdd}, ScopeRead, true},
+		{[]Scope{ScopeAdd}, ScopeControl, false},
+		{[]Scope{ScopeControl}, ScopeRead, true},
+		{[]Scope{ScopeControl}, ScopeAdd, false},
+		{[]Scope{ScopeControl}, ScopeAdmin, false},
+		{[]Scope{ScopeAdd, ScopeControl}, ScopeControl, true},
+		{[]Scope{ScopeAdmin}, ScopeControl, true},
+		{nil, ScopeRead, false},
+	}
+	for _, tt := range tests {
+		tok := Token{Scopes: tt.scopes}
+		if got := tok.Allows(tt.scope); got != tt.want {
+			t.Errorf("%v Allows(%s) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestToken_AllowsDir(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "srv", "shared")
+	tok := Token{Dirs: []string{root}}
+
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{root, true},
+		{filepath.Join(root, "isos"), true},
+		{filepath.Join(root, "..", "private"), false},
+		{root + "-other", false},
+		{filepath.Join(string(filepath.Separator), "srv"), false},
+	}
+	for _, tt := range tests {
+		if got := tok.AllowsDir(tt.dir); got != tt.want {
+			t.Errorf("AllowsDir(%q) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+	if !(&Token{}).AllowsDir("/anywhere") {
+		t.Error("A token without directories should allow any")
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes(" add, Control,add ")
+	if err != nil || len(scopes) != 2 || scopes[0] != ScopeAdd || scopes[1] != ScopeControl {
+		t.Errorf("ParseScopes = %v, %v", scopes, err)
+	}
+	for _, bad := range []string{"", " , ", "write", "read,delete"} {
+		if _, err := ParseScopes(bad); err == nil {
+			t.Errorf("ParseScopes(%q) should fail", bad)
+		}
+	}
+}
+
+func TestKeyring_CreateLookupRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	keyring := NewKeyring("owner-secret", path)
+
+	if tok := keyring.Lookup("owner-secret"); tok == nil || tok.Name != Owner.Name || !tok.Allows(ScopeAdmin) {
+		t.Fatalf("Owner lookup = %+v", tok)
+	}
+	if keyring.Lookup("") != nil || keyring.Lookup("nope") != nil {
+		t.Fatal("Unknown secrets must not match")
+	}
+
+	secret, err := Create(path, "laptop", []Scope{ScopeAdd}, []string{"shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(path, "laptop", []Scope{ScopeRead}, nil); err == nil {
+		t.Error("Duplicate names should be rejected")
+	}
+	if _, err := Create(path, Owner.Name, []Scope{ScopeRead}, nil); err == nil {
+		t.Error("The owner's name should be reserved")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("The token file must store only hashes")
+	}
+
+	tok := keyring.Lookup(secret)
+	if tok == nil || tok.Name != "laptop" {
+		t.Fatalf("Lookup after create = %+v", tok)
+	}
+	if len(tok.Dirs) != 1 || !filepath.IsAbs(tok.Dirs[0]) {
+		t.Errorf("Dirs = %v, want one absolute path", tok.Dirs)
+	}
+
+	// The running keyring sees revocations without a restart
+	if err := Revoke(path, "laptop"); err != nil {
+		t.Fatal(err)
+	}
+	if keyring.Lookup(secret) != nil {
+		t.Error("Revoked token still accepted")
+	}
+	if err := Revoke(path, "laptop"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke of a missing token = %v, want ErrNotFound", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Error("Empty context should carry no token")
+	}
+	tok := &Token{Name: "x"}
+	if got := FromContext(NewContext(context.Background(), tok)); got != tok {
+		t.Errorf("FromContext = %v", got)
+	}
+}
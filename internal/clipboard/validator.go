@@ -15,7 +15,7 @@ type Validator struct {
 // NewValidator creates a new URL validator
 func NewValidator() *Validator {
 	return &Validator{
-		allowedSchemes: map[string]bool{"http": true, "https": true},
+		allowedSchemes: map[string]bool{"http": true, "https": true, "ftp": true, "ftps": true, "sftp": true, "scp": true},
 	}
 }
 
@@ -28,8 +28,17 @@ func (v *Validator) ExtractURL(text string) string {
 		return ""
 	}
 
-	// Must start with http:// or https://
-	if !strings.HasPrefix(text, "http://") && !strings.HasPrefix(text, "https://") {
+	// Magnet links have no host; accept those naming a BitTorrent info hash
+	if parsed, err := url.Parse(text); err == nil && strings.EqualFold(parsed.Scheme, "magnet") {
+		if strings.HasPrefix(strings.ToLower(parsed.Query().Get("xt")), "urn:btih:") {
+			return text
+		}
+		return ""
+	}
+
+	// Must start with a supported scheme, e.g. http:// or ftp://
+	scheme, _, found := strings.Cut(text, "://")
+	if !found || !v.allowedSchemes[scheme] {
 		return ""
 	}
 
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// CategorySettings groups downloads by file type or source. A download belongs
+// to the first category whose rules match: its extension or MIME type is listed
+// (when either list is set) and it comes from one of Hosts (when set).
+type CategorySettings struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions,omitempty"` // e.g. "mp4", ".tar.gz"
+	MimeTypes  []string `json:"mime_types,omitempty"` // e.g. "video/*", "application/pdf"
+	Hosts      []string `json:"hosts,omitempty"`      // Domains, including their subdomains
+	Dir        string   `json:"dir,omitempty"`        // Output directory (empty = default download dir)
+
+	MaxConcurrent         int   `json:"max_concurrent,omitempty"`           // Most downloads of the category running at once (0 = global limit only)
+	MaxConnectionsPerHost int   `json:"max_connections_per_host,omitempty"` // Overrides the network setting (0 = keep)
+	MinChunkSize          int64 `json:"min_chunk_size,omitempty"`           // Overrides the network setting in bytes (0 = keep)
+	SequentialDownload    bool  `json:"sequential_download,omitempty"`      // Always download in order, e.g. to preview video
+}
+
+// FindCategory returns the category with the given name (case-insensitive), or nil
+func FindCategory(categories []CategorySettings, name string) *CategorySettings {
+	if name == "" {
+		return nil
+	}
+	for i := range categories {
+		if strings.EqualFold(categories[i].Name, name) {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+// MatchCategory returns the first category matching a download, or nil. The
+// filename falls back to the last element of the URL path; contentType may be
+// empty before the server has been probed.
+func MatchCategory(categories []CategorySettings, rawURL, filename, contentType string) *CategorySettings {
+	var host string
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+		if filename == "" && strings.Trim(u.Path, "/") != "" {
+			filename = path.Base(u.Path)
+		}
+	}
+	for i := range categories {
+		if categories[i].matches(host, strings.ToLower(filename), contentType) {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+// matches applies c's rules to a lower-cased host and filename
+func (c *CategorySettings) matches(host, filename, contentType string) bool {
+	if len(c.Extensions) == 0 && len(c.MimeTypes) == 0 && len(c.Hosts) == 0 {
+		return false // Only chosen explicitly
+	}
+
+	if len(c.Hosts) > 0 {
+		ok := false
+		for _, h := range c.Hosts {
+			h = strings.ToLower(strings.TrimPrefix(h, "."))
+			if host == h || strings.HasSuffix(host, "."+h) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(c.Extensions) == 0 && len(c.MimeTypes) == 0 {
+		return true
+	}
+	for _, ext := range c.Extensions {
+		ext = "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+		if strings.HasSuffix(filename, ext) && len(filename) > len(ext) {
+			return true
+		}
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		for _, pattern := range c.MimeTypes {
+			pattern = strings.ToLower(pattern)
+			if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+				if strings.HasPrefix(mediaType, prefix+"/") {
+					return true
+				}
+			} else if mediaType == pattern {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package config
+
+import "testing"
+
+func TestMatchCategory(t *testing.T) {
+	categories := []CategorySettings{
+		{Name: "Work", Hosts: []string{"corp.example"}, Extensions: []string{"pdf"}},
+		{Name: "Video", Extensions: []string{"mp4", ".MKV"}, MimeTypes: []string{"video/*"}},
+		{Name: "Archives", Extensions: []string{"zip", "tar.gz"}, MimeTypes: []string{"application/zip"}},
+		{Name: "Documents", Extensions: []string{"pdf"}},
+		{Name: "Mirror", Hosts: []string{"mirror.example"}},
+		{Name: "Manual"},
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		filename    string
+		contentType string
+		want        string
+	}{
+		{"Extension from URL", "https://cdn.example/movie.MP4", "", "", "Video"},
+		{"Extension from filename", "https://cdn.example/get?id=1", "clip.mkv", "", "Video"},
+		{"MIME wildcard", "https://cdn.example/stream", "stream", "video/webm; codecs=vp9", "Video"},
+		{"Exact MIME", "https://cdn.example/dl", "dl", "application/zip", "Archives"},
+		{"Multi-part extension", "https://cdn.example/src.tar.gz", "", "", "Archives"},
+		{"Host and extension", "https://files.corp.example/report.pdf", "", "", "Work"},
+		{"Host without extension", "https://corp.example/report.docx", "", "", ""},
+		{"Other host", "https://notcorp.example/report.pdf", "", "", "Documents"},
+		{"Host only", "https://mirror.example/anything.bin", "", "", "Mirror"},
+		{"Bare extension is not a file", "https://cdn.example/mp4", "", "", ""},
+		{"No match", "https://cdn.example/file.bin", "", "application/octet-stream", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if c := MatchCategory(categories, tt.url, tt.filename, tt.contentType); c != nil {
+				got = c.Name
+			}
+			if got != tt.want {
+				t.Errorf("MatchCategory = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCategory(t *testing.T) {
+	categories := []CategorySettings{{Name: "Video"}, {Name: "ISOs"}}
+
+	if c := FindCategory(categories, "isos"); c == nil || c.Name != "ISOs" {
+		t.Errorf("FindCategory(isos) = %v", c)
+	}
+	if c := FindCategory(categories, "music"); c != nil {
+		t.Errorf("FindCategory(music) = %v, want nil", c)
+	}
+	if c := FindCategory(categories, ""); c != nil {
+		t.Errorf("FindCategory(\"\") = %v, want nil", c)
+	}
+}
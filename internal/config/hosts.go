@@ -0,0 +1,71 @@
+package config
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// HostPolicy overrides connection settings for the servers whose hostname
+// matches Pattern. Limits apply to all downloads from a host together.
+type HostPolicy struct {
+	Pattern        string            `json:"pattern"`                   // Glob on the hostname, e.g. "*.example.com"
+	MaxConnections int               `json:"max_connections,omitempty"` // Connections to the host across all downloads (0 = keep)
+	MaxConcurrent  int               `json:"max_concurrent,omitempty"`  // Downloads from the host running at once (0 = global limit only)
+	MinChunkSize   int64             `json:"min_chunk_size,omitempty"`  // Overrides the chunk setting in bytes (0 = keep)
+	UserAgent      string            `json:"user_agent,omitempty"`      // Overrides the network setting (empty = keep)
+	Headers        map[string]string `json:"headers,omitempty"`         // Sent with every request unless the download sets them itself
+}
+
+// MatchHost reports whether p applies to hostname
+func (p *HostPolicy) MatchHost(hostname string) bool {
+	if hostname == "" {
+		return false
+	}
+	ok, err := path.Match(strings.ToLower(p.Pattern), strings.ToLower(hostname))
+	return err == nil && ok
+}
+
+// MatchHostPolicy returns the first policy whose pattern matches the URL's hostname, or nil
+func MatchHostPolicy(policies []HostPolicy, rawURL string) *HostPolicy {
+	if len(policies) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	for i := range policies {
+		if policies[i].MatchHost(u.Hostname()) {
+			return &policies[i]
+		}
+	}
+	return nil
+}
+
+// ApplyHeaders returns headers with the policy's headers and user agent added.
+// Headers the download already sets are kept; the input map is not modified.
+func (p *HostPolicy) ApplyHeaders(headers map[string]string) map[string]string {
+	if p == nil || (len(p.Headers) == 0 && p.UserAgent == "") {
+		return headers
+	}
+	out := make(map[string]string, len(headers)+len(p.Headers)+1)
+	add := func(key, val string) {
+		for k := range out {
+			if strings.EqualFold(k, key) {
+				return
+			}
+		}
+		out[key] = val
+	}
+	for k, v := range headers {
+		out[k] = v
+	}
+	for k, v := range p.Headers {
+		add(k, v)
+	}
+	if p.UserAgent != "" {
+		add("User-Agent", p.UserAgent)
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestMatchHostPolicy(t *testing.T) {
+	policies := []HostPolicy{
+		{Pattern: "artifacts.corp.example", MaxConnections: 32},
+		{Pattern: "*.Public.example", MaxConnections: 2},
+		{Pattern: "mirror-?.example", MaxConcurrent: 1},
+		{Pattern: "[bad"},
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://artifacts.corp.example/build.tar", "artifacts.corp.example"},
+		{"https://ARTIFACTS.corp.example:8443/build.tar", "artifacts.corp.example"},
+		{"https://dl.public.example/file.iso", "*.Public.example"},
+		{"https://a.b.public.example/file.iso", "*.Public.example"},
+		{"https://public.example/file.iso", ""},
+		{"https://mirror-1.example/file", "mirror-?.example"},
+		{"https://mirror-10.example/file", ""},
+		{"https://other.example/file", ""},
+		{"magnet:?xt=urn:btih:abc", ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if p := MatchHostPolicy(policies, tt.url); p != nil {
+			got = p.Pattern
+		}
+		if got != tt.want {
+			t.Errorf("MatchHostPolicy(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestHostPolicy_ApplyHeaders(t *testing.T) {
+	p := &HostPolicy{
+		UserAgent: "surge-ci",
+		Headers:   map[string]string{"X-Token": "policy", "Referer": "https://corp.example/"},
+	}
+	in := map[string]string{"x-token": "download"}
+
+	got := p.ApplyHeaders(in)
+	want := map[string]string{"x-token": "download", "Referer": "https://corp.example/", "User-Agent": "surge-ci"}
+	if !maps.Equal(got, want) {
+		t.Errorf("ApplyHeaders = %v, want %v", got, want)
+	}
+	if len(in) != 1 {
+		t.Errorf("input headers modified: %v", in)
+	}
+
+	var none *HostPolicy
+	if got := none.ApplyHeaders(in); !maps.Equal(got, in) {
+		t.Errorf("nil policy ApplyHeaders = %v, want %v", got, in)
+	}
+}
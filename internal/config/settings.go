@@ -9,9 +9,12 @@ import (
 
 // Settings holds all user-configurable application settings organized by category.
 type Settings struct {
-	General     GeneralSettings     `json:"general"`
-	Network     NetworkSettings     `json:"network"`
-	Performance PerformanceSettings `json:"performance"`
+	General      GeneralSettings     `json:"general"`
+	Network      NetworkSettings     `json:"network"`
+	Performance  PerformanceSettings `json:"performance"`
+	Hooks        []HookSettings      `json:"hooks,omitempty"`         // Actions run when downloads finish (edited in settings.json)
+	Categories   []CategorySettings  `json:"categories,omitempty"`    // Download categories, matched in order (edited in settings.json)
+	HostPolicies []HostPolicy        `json:"host_policies,omitempty"` // Per-host connection rules, matched in order (edited in settings.json)
 }
 
 // GeneralSettings contains application behavior settings.
@@ -22,9 +25,16 @@ type GeneralSettings struct {
 	AutoResume         bool   `json:"auto_resume"`
 	SkipUpdateCheck    bool   `json:"skip_update_check"`
 
-	ClipboardMonitor  bool `json:"clipboard_monitor"`
-	Theme             int  `json:"theme"`
-	LogRetentionCount int  `json:"log_retention_count"`
+	ClipboardMonitor  bool     `json:"clipboard_monitor"`
+	Theme             int      `json:"theme"`
+	LogRetentionCount int      `json:"log_retention_count"`
+	ActiveWindows     []string `json:"active_windows"` // Daily windows downloads may run in ("HH:MM-HH:MM" each); empty = always
+
+	ExtractArchives bool `json:"extract_archives"`              // Unpack completed .zip/.tar/.tar.gz/.tar.zst files
+	DeleteArchives  bool `json:"delete_archives_after_extract"` // Remove the archive once it has been unpacked
+
+	PreallocateFiles bool  `json:"preallocate_files"` // Reserve the whole file on disk before downloading
+	MinFreeSpace     int64 `json:"min_free_space"`    // Bytes to keep free; downloads pause below this (0 = don't watch)
 }
 
 const (
@@ -42,6 +52,19 @@ type NetworkSettings struct {
 	SequentialDownload     bool   `json:"sequential_download"`
 	MinChunkSize           int64  `json:"min_chunk_size"`
 	WorkerBufferSize       int    `json:"worker_buffer_size"`
+	GlobalSpeedLimit       int64  `json:"global_speed_limit"` // Bytes per second shared by all downloads (0 = unlimited)
+
+	SSHKeyFile        string `json:"ssh_key_file"`    // Private key for sftp:// URLs, used alongside the SSH agent
+	SSHKnownHostsFile string `json:"ssh_known_hosts"` // Host keys trusted for sftp:// URLs (empty = ~/.ssh/known_hosts)
+
+	TorrentListenPort  int           `json:"torrent_listen_port"`  // Port accepting BitTorrent peers
+	SeedRatio          float64       `json:"seed_ratio"`           // Seed completed torrents until uploaded/size reaches this (0 = don't seed)
+	SeedTimeLimit      time.Duration `json:"seed_time_limit"`      // Stop seeding after this long (0 = no limit)
+	TorrentUploadLimit int64         `json:"torrent_upload_limit"` // Bytes per second served to peers across all torrents (0 = unlimited)
+
+	DaemonTLS     bool   `json:"daemon_tls"`      // Serve the daemon API over HTTPS; loopback clients may still use HTTP
+	DaemonTLSCert string `json:"daemon_tls_cert"` // PEM certificate (empty = self-signed, generated on first start)
+	DaemonTLSKey  string `json:"daemon_tls_key"`  // PEM private key of DaemonTLSCert
 }
 
 // UnmarshalJSON implements custom JSON unmarshalling for Settings.
@@ -83,6 +106,17 @@ type PerformanceSettings struct {
 	SpeedEmaAlpha         float64       `json:"speed_ema_alpha"`
 }
 
+// HookSettings describes a command or webhook invoked when a download completes or fails.
+// Exactly one of Command or URL should be set.
+type HookSettings struct {
+	Name           string   `json:"name,omitempty"`
+	Events         []string `json:"events,omitempty"`          // "complete", "error"; empty = both
+	Command        string   `json:"command,omitempty"`         // Shell command; download details are passed as SURGE_* env vars
+	URL            string   `json:"url,omitempty"`             // Webhook that receives the event JSON via POST
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"` // Per-attempt timeout (0 = 30s)
+	Retries        int      `json:"retries,omitempty"`         // Extra attempts after a failure
+}
+
 // SettingMeta provides metadata for a single setting (for UI rendering).
 type SettingMeta struct {
 	Key         string // JSON key name
@@ -104,6 +138,11 @@ func GetSettingsMetadata() map[string][]SettingMeta {
 			{Key: "clipboard_monitor", Label: "Clipboard Monitor", Description: "Watch clipboard for URLs and prompt to download them.", Type: "bool"},
 			{Key: "theme", Label: "App Theme", Description: "UI Theme (System, Light, Dark).", Type: "int"},
 			{Key: "log_retention_count", Label: "Log Retention Count", Description: "Number of recent log files to keep.", Type: "int"},
+			{Key: "active_windows", Label: "Active Windows", Description: "Only run downloads during these daily windows, comma-separated (e.g. 01:00-07:00, 12:00-13:00). Leave empty to always run.", Type: "string"},
+			{Key: "extract_archives", Label: "Extract Archives", Description: "Unpack completed .zip, .tar, .tar.gz and .tar.zst downloads into a folder next to them. Requires restart.", Type: "bool"},
+			{Key: "delete_archives_after_extract", Label: "Delete After Extract", Description: "Delete archives after they have been unpacked successfully. Requires restart.", Type: "bool"},
+			{Key: "preallocate_files", Label: "Preallocate Files", Description: "Reserve the full size of a download on disk before writing, so it cannot run out of space half way.", Type: "bool"},
+			{Key: "min_free_space", Label: "Min Free Space", Description: "Disk space in MB to keep free. Downloads pause below this and resume once space returns (0 = don't watch).", Type: "int64"},
 		},
 		"Network": {
 			{Key: "max_connections_per_host", Label: "Max Connections/Host", Description: "Maximum concurrent connections per host (1-64).", Type: "int"},
@@ -113,6 +152,16 @@ func GetSettingsMetadata() map[string][]SettingMeta {
 			{Key: "sequential_download", Label: "Sequential Download", Description: "Download pieces in order (Streaming Mode). May be slower.", Type: "bool"},
 			{Key: "min_chunk_size", Label: "Min Chunk Size", Description: "Minimum download chunk size in MB (e.g., 2).", Type: "int64"},
 			{Key: "worker_buffer_size", Label: "Worker Buffer Size", Description: "I/O buffer size per worker in KB (e.g., 512).", Type: "int"},
+			{Key: "global_speed_limit", Label: "Global Speed Limit", Description: "Total bandwidth cap shared by all downloads in MB/s (0 = unlimited). Applies immediately.", Type: "int64"},
+			{Key: "ssh_key_file", Label: "SSH Key File", Description: "Private key used for sftp:// downloads in addition to the SSH agent. Leave empty to use ~/.ssh/id_*.", Type: "string"},
+			{Key: "ssh_known_hosts", Label: "SSH Known Hosts", Description: "known_hosts file used to verify sftp:// servers. Leave empty to use ~/.ssh/known_hosts.", Type: "string"},
+			{Key: "torrent_listen_port", Label: "Torrent Listen Port", Description: "TCP port other BitTorrent peers connect to (1-65535). Requires restart.", Type: "int"},
+			{Key: "seed_ratio", Label: "Seed Ratio", Description: "Keep seeding completed torrents until this much of their size was uploaded (0 = don't seed).", Type: "float64"},
+			{Key: "seed_time_limit", Label: "Seed Time Limit", Description: "Stop seeding a torrent after this many minutes, even below the ratio (0 = no limit).", Type: "duration"},
+			{Key: "torrent_upload_limit", Label: "Torrent Upload Limit", Description: "Upload bandwidth shared by all torrents in MB/s (0 = unlimited).", Type: "int64"},
+			{Key: "daemon_tls", Label: "Daemon TLS", Description: "Serve the daemon's API over HTTPS. Local tools on this machine may still use HTTP. Requires restart.", Type: "bool"},
+			{Key: "daemon_tls_cert", Label: "TLS Certificate", Description: "PEM certificate for the daemon. Leave empty to generate a self-signed one. Requires restart.", Type: "string"},
+			{Key: "daemon_tls_key", Label: "TLS Key", Description: "PEM private key of the TLS certificate. Requires restart.", Type: "string"},
 		},
 		"Performance": {
 			{Key: "max_task_retries", Label: "Max Task Retries", Description: "Number of times to retry a failed chunk before giving up.", Type: "int"},
@@ -165,6 +214,8 @@ func DefaultSettings() *Settings {
 			ClipboardMonitor:  true,
 			Theme:             ThemeAdaptive,
 			LogRetentionCount: 5,
+
+			MinFreeSpace: 512 * MB,
 		},
 		Network: NetworkSettings{
 			MaxConnectionsPerHost:  32,
@@ -173,6 +224,7 @@ func DefaultSettings() *Settings {
 			SequentialDownload:     false,
 			MinChunkSize:           2 * MB,
 			WorkerBufferSize:       512 * KB,
+			TorrentListenPort:      6881,
 		},
 		Performance: PerformanceSettings{
 			MaxTaskRetries:        3,
@@ -247,6 +299,16 @@ type RuntimeConfig struct {
 	SlowWorkerGracePeriod time.Duration
 	StallTimeout          time.Duration
 	SpeedEmaAlpha         float64
+	SSHKeyFile            string
+	SSHKnownHostsFile     string
+	TorrentListenPort     int
+	SeedRatio             float64
+	SeedTimeLimit         time.Duration
+	TorrentUploadLimit    int64
+	PreallocateFiles      bool
+	MinFreeSpace          int64
+	Categories            []CategorySettings
+	HostPolicies          []HostPolicy
 }
 
 // ToRuntimeConfig creates a RuntimeConfig from user Settings
@@ -263,5 +325,15 @@ func (s *Settings) ToRuntimeConfig() *RuntimeConfig {
 		SlowWorkerGracePeriod: s.Performance.SlowWorkerGracePeriod,
 		StallTimeout:          s.Performance.StallTimeout,
 		SpeedEmaAlpha:         s.Performance.SpeedEmaAlpha,
+		SSHKeyFile:            s.Network.SSHKeyFile,
+		SSHKnownHostsFile:     s.Network.SSHKnownHostsFile,
+		TorrentListenPort:     s.Network.TorrentListenPort,
+		SeedRatio:             s.Network.SeedRatio,
+		SeedTimeLimit:         s.Network.SeedTimeLimit,
+		TorrentUploadLimit:    s.Network.TorrentUploadLimit,
+		PreallocateFiles:      s.General.PreallocateFiles,
+		MinFreeSpace:          s.General.MinFreeSpace,
+		Categories:            s.Categories,
+		HostPolicies:          s.HostPolicies,
 	}
 }
@@ -49,6 +49,10 @@ func TestDefaultSettings(t *testing.T) {
 		if settings.Network.SequentialDownload {
 			t.Error("SequentialDownload should be false by default")
 		}
+		// Seeding is opt-in
+		if settings.Network.SeedRatio != 0 || settings.Network.SeedTimeLimit != 0 {
+			t.Errorf("torrents should not seed by default, got ratio %v, time limit %v", settings.Network.SeedRatio, settings.Network.SeedTimeLimit)
+		}
 	})
 
 	// Verify Chunk settings
@@ -304,6 +308,13 @@ func TestToRuntimeConfig(t *testing.T) {
 	if runtime.SpeedEmaAlpha != settings.Performance.SpeedEmaAlpha {
 		t.Error("SpeedEmaAlpha not correctly mapped")
 	}
+
+	settings.Network.SSHKeyFile = "/home/user/.ssh/build_key"
+	settings.Network.SSHKnownHostsFile = "/home/user/.ssh/build_hosts"
+	runtime = settings.ToRuntimeConfig()
+	if runtime.SSHKeyFile != settings.Network.SSHKeyFile || runtime.SSHKnownHostsFile != settings.Network.SSHKnownHostsFile {
+		t.Error("SSH settings not correctly mapped")
+	}
 }
 
 func TestGetSettingsMetadata(t *testing.T) {
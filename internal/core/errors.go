@@ -0,0 +1,11 @@
+package core
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when no active or persisted download has the given id
+	ErrNotFound = errors.New("download not found")
+
+	// ErrAlreadyCompleted is returned when resuming a download that has finished
+	ErrAlreadyCompleted = errors.New("download already completed")
+)
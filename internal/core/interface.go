@@ -17,7 +17,8 @@ type DownloadService interface {
 	History() ([]types.DownloadEntry, error)
 
 	// Add queues a new download.
-	Add(url string, path string, filename string, mirrors []string, headers map[string]string) (string, error)
+	// opts carries optional per-download settings such as an expected checksum.
+	Add(url string, path string, filename string, mirrors []string, headers map[string]string, opts types.DownloadOptions) (string, error)
 
 	// Pause pauses an active download.
 	Pause(id string) error
@@ -31,6 +32,19 @@ type DownloadService interface {
 	// Delete cancels and removes a download.
 	Delete(id string) error
 
+	// SetSpeedLimit changes the bandwidth cap (bytes/sec, 0 = unlimited) of a download
+	// without restarting it. An empty id sets the global cap.
+	SetSpeedLimit(id string, bytesPerSec int64) error
+
+	// SetActiveWindows restricts downloads to daily windows ("HH:MM-HH:MM"),
+	// pausing and resuming running ones as they close and open. No windows
+	// removes the restriction.
+	SetActiveWindows(specs []string) error
+
+	// Move reorders a queued download: "top" puts it first, "up" and "down" swap
+	// it with its neighbour.
+	Move(id string, direction string) error
+
 	// StreamEvents returns a channel that receives real-time download events.
 	// For local mode, this is a direct channel.
 	// For remote mode, this is sourced from SSE.
@@ -2,18 +2,27 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/cookies"
 	"github.com/surge-downloader/surge/internal/engine/events"
 	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/stream"
+	"github.com/surge-downloader/surge/internal/engine/torrent"
 	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/extract"
+	"github.com/surge-downloader/surge/internal/hooks"
 	"github.com/surge-downloader/surge/internal/utils"
 )
 
@@ -26,6 +35,7 @@ func (s *LocalDownloadService) ReloadSettings() error {
 	s.settingsMu.Lock()
 	s.settings = settings
 	s.settingsMu.Unlock()
+	bandwidth.Global().SetLimit(settings.Network.GlobalSpeedLimit)
 	return nil
 }
 
@@ -47,6 +57,10 @@ type LocalDownloadService struct {
 	// Settings Cache
 	settings   *config.Settings
 	settingsMu sync.RWMutex
+
+	// Post-download work (hooks, extraction) still running, waited on at shutdown
+	postWg sync.WaitGroup
+	postMu sync.Mutex
 }
 
 const (
@@ -95,6 +109,9 @@ func NewLocalDownloadServiceWithInput(pool *download.WorkerPool, inputCh chan in
 
 func (s *LocalDownloadService) broadcastLoop() {
 	for msg := range s.InputCh {
+		s.triggerHooks(msg)
+		s.triggerExtract(msg)
+
 		s.listenerMu.Lock()
 		for _, ch := range s.listeners {
 			// Check message type
@@ -180,6 +197,9 @@ func (s *LocalDownloadService) reportProgressLoop() {
 				Speed:             currentSpeed,
 				Elapsed:           totalElapsed,
 				ActiveConnections: int(connections),
+				RateLimitedFor:    cfg.State.RateLimitRemaining(),
+				SpeedLimit:        cfg.State.SpeedLimit.Limit(),
+				Mirrors:           cfg.State.GetMirrors(),
 			}
 
 			// Add Chunk Bitmap for visualization (if initialized)
@@ -206,6 +226,171 @@ func (s *LocalDownloadService) reportProgressLoop() {
 	}
 }
 
+// triggerHooks runs the configured hooks in the background when a download completes or fails
+func (s *LocalDownloadService) triggerHooks(msg interface{}) {
+	var ev hooks.Event
+	switch m := msg.(type) {
+	case events.DownloadCompleteMsg:
+		ev = hooks.Event{
+			Type:     hooks.EventComplete,
+			ID:       m.DownloadID,
+			Filename: m.Filename,
+			Size:     m.Total,
+			Elapsed:  m.Elapsed.Milliseconds(),
+		}
+	case events.DownloadErrorMsg:
+		ev = hooks.Event{
+			Type:     hooks.EventError,
+			ID:       m.DownloadID,
+			Filename: m.Filename,
+		}
+		if m.Err != nil {
+			ev.Error = m.Err.Error()
+		}
+	default:
+		return
+	}
+
+	s.settingsMu.RLock()
+	configured := s.settings.Hooks
+	s.settingsMu.RUnlock()
+	if len(configured) == 0 {
+		return
+	}
+
+	s.goPostDownload(func() {
+		// Events don't carry the URL or destination; both are persisted before they are emitted
+		if entry, err := state.GetDownload(ev.ID); err == nil && entry != nil {
+			ev.Path = entry.DestPath
+			ev.URL = entry.URL
+			if ev.Filename == "" {
+				ev.Filename = entry.Filename
+			}
+			if ev.Size == 0 {
+				ev.Size = entry.TotalSize
+			}
+		}
+
+		for _, res := range hooks.NewRunner(configured).Run(s.ctx, ev) {
+			result := events.HookResultMsg{
+				DownloadID: ev.ID,
+				Filename:   ev.Filename,
+				Hook:       res.Hook,
+				Event:      ev.Type,
+				Attempts:   res.Attempts,
+				ExitCode:   res.ExitCode,
+			}
+			if res.Err != nil {
+				result.Err = res.Err.Error()
+				utils.Debug("Hook %q failed for %s after %d attempt(s): %v", res.Hook, ev.ID, res.Attempts, res.Err)
+			} else {
+				utils.Debug("Hook %q succeeded for %s (status %d)", res.Hook, ev.ID, res.ExitCode)
+			}
+
+			if !s.emit(result) {
+				return
+			}
+		}
+	})
+}
+
+// triggerExtract unpacks a completed archive in the background if extraction is
+// enabled for the download or globally
+func (s *LocalDownloadService) triggerExtract(msg interface{}) {
+	m, ok := msg.(events.DownloadCompleteMsg)
+	if !ok {
+		return
+	}
+
+	s.settingsMu.RLock()
+	general := s.settings.General
+	s.settingsMu.RUnlock()
+
+	s.goPostDownload(func() {
+		entry, err := state.GetDownload(m.DownloadID)
+		if err != nil || entry == nil {
+			return
+		}
+
+		mode := entry.Extract
+		if mode == extract.ModeDefault {
+			switch {
+			case !general.ExtractArchives:
+				mode = extract.ModeOff
+			case general.DeleteArchives:
+				mode = extract.ModeDelete
+			default:
+				mode = extract.ModeKeep
+			}
+		}
+		if mode == extract.ModeOff {
+			return
+		}
+
+		filename := m.Filename
+		if filename == "" {
+			filename = entry.Filename
+		}
+		if extract.Detect(entry.DestPath) == extract.FormatNone {
+			// Only complain when extraction was asked for explicitly
+			if entry.Extract != extract.ModeDefault {
+				s.emit(events.ExtractErrorMsg{DownloadID: m.DownloadID, Filename: filename, Err: "not a supported archive"})
+			}
+			return
+		}
+
+		res, err := extract.Extract(s.ctx, entry.DestPath, func(p extract.Progress) {
+			// Progress is best-effort; never hold up the extraction for a slow consumer
+			select {
+			case s.InputCh <- events.ExtractProgressMsg{DownloadID: m.DownloadID, Filename: filename, Done: p.Done, Total: p.Total, Files: p.Files}:
+			default:
+			}
+		})
+		if err != nil {
+			utils.Debug("Extraction failed for %s: %v", m.DownloadID, err)
+			s.emit(events.ExtractErrorMsg{DownloadID: m.DownloadID, Filename: filename, Err: err.Error()})
+			return
+		}
+
+		done := events.ExtractCompleteMsg{DownloadID: m.DownloadID, Filename: filename, Dir: res.Dir, Files: res.Files}
+		if mode == extract.ModeDelete {
+			if err := os.Remove(entry.DestPath); err != nil {
+				utils.Debug("Failed to delete archive %s: %v", entry.DestPath, err)
+			} else {
+				done.ArchiveDeleted = true
+			}
+		}
+		utils.Debug("Extracted %s into %s (%d files)", entry.DestPath, res.Dir, res.Files)
+		s.emit(done)
+	})
+}
+
+// goPostDownload runs fn in the background unless the service is shutting down.
+// Shutdown waits for it before closing InputCh.
+func (s *LocalDownloadService) goPostDownload(fn func()) {
+	s.postMu.Lock()
+	defer s.postMu.Unlock()
+	if s.ctx.Err() != nil {
+		return // Shutting down
+	}
+
+	s.postWg.Add(1)
+	go func() {
+		defer s.postWg.Done()
+		fn()
+	}()
+}
+
+// emit sends a message from background work to the broadcaster, giving up on shutdown
+func (s *LocalDownloadService) emit(msg interface{}) bool {
+	select {
+	case s.InputCh <- msg:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
 // StreamEvents returns a channel that receives real-time download events.
 func (s *LocalDownloadService) StreamEvents(ctx context.Context) (<-chan interface{}, func(), error) {
 	if ctx == nil {
@@ -266,8 +451,11 @@ func (s *LocalDownloadService) Shutdown() error {
 		s.Pool.GracefulShutdown()
 	}
 
-	// Stop listeners and broadcaster
+	// Stop listeners, broadcaster and post-download work
+	s.postMu.Lock()
 	s.cancel()
+	s.postMu.Unlock()
+	s.postWg.Wait()
 
 	// Close input channel to stop broadcaster
 	close(s.InputCh)
@@ -287,6 +475,8 @@ func (s *LocalDownloadService) List() ([]types.DownloadStatus, error) {
 				URL:      cfg.URL,
 				Filename: cfg.Filename,
 				Status:   "downloading",
+				Priority: cfg.Priority,
+				Category: cfg.Category,
 			}
 
 			if cfg.State != nil {
@@ -316,16 +506,22 @@ func (s *LocalDownloadService) List() ([]types.DownloadStatus, error) {
 
 				// Get active connections count
 				status.Connections = int(connections)
+				status.SpeedLimit = cfg.State.SpeedLimit.Limit()
 
 				// Update status based on state
 				if cfg.State.IsPausing() {
 					status.Status = "pausing"
 				} else if cfg.State.IsPaused() {
 					status.Status = "paused"
+					status.PauseReason = s.Pool.PauseReason(cfg.ID)
 				} else if cfg.State.Done.Load() {
 					status.Status = "completed"
 				}
 			}
+			if startAt, ok := s.Pool.ScheduledAt(cfg.ID); ok {
+				status.Status = "scheduled"
+				status.ScheduledAt = startAt.Unix()
+			}
 
 			statuses = append(statuses, status)
 		}
@@ -370,6 +566,10 @@ func (s *LocalDownloadService) List() ([]types.DownloadStatus, error) {
 				Progress:    progress,
 				Speed:       speed,
 				Connections: 0,
+				ScheduledAt: d.StartAt,
+				SpeedLimit:  d.SpeedLimit,
+				Priority:    d.Priority,
+				Category:    d.Category,
 			})
 		}
 	}
@@ -378,17 +578,73 @@ func (s *LocalDownloadService) List() ([]types.DownloadStatus, error) {
 }
 
 // Add queues a new download.
-func (s *LocalDownloadService) Add(url string, path string, filename string, mirrors []string, headers map[string]string) (string, error) {
+func (s *LocalDownloadService) Add(url string, path string, filename string, mirrors []string, headers map[string]string, opts types.DownloadOptions) (string, error) {
 	if s.Pool == nil {
 		return "", fmt.Errorf("worker pool not initialized")
 	}
 
+	// Normalize and validate expected checksum up-front so bad input fails the add, not the download
+	var expectedChecksum string
+	if opts.Checksum != "" {
+		spec, err := checksum.Parse(opts.Checksum)
+		if err != nil {
+			return "", fmt.Errorf("invalid checksum: %w", err)
+		}
+		expectedChecksum = spec.String()
+	}
+
+	if opts.Window != "" {
+		if _, err := download.ParseWindow(opts.Window); err != nil {
+			return "", err
+		}
+	}
+	if opts.SpeedLimit < 0 {
+		return "", fmt.Errorf("invalid speed limit: %d", opts.SpeedLimit)
+	}
+	if opts.ExpectedSize < 0 {
+		return "", fmt.Errorf("invalid size: %d", opts.ExpectedSize)
+	}
+	extractMode, err := extract.ParseMode(opts.Extract)
+	if err != nil {
+		return "", err
+	}
+	if _, err := stream.ParseSelector(opts.Variant); err != nil {
+		return "", err
+	}
+	if torrent.IsMagnet(url) {
+		if _, err := torrent.ParseMagnet(url); err != nil {
+			return "", err
+		}
+	}
+	if opts.Cookies != "" {
+		jarCookies, err := cookies.Parse(strings.NewReader(opts.Cookies))
+		if err != nil {
+			return "", fmt.Errorf("invalid cookies: %w", err)
+		}
+		if err := cookies.Shared().Import(jarCookies); err != nil {
+			return "", err
+		}
+	}
+
 	s.settingsMu.RLock()
 	settings := s.settings
 	s.settingsMu.RUnlock()
 
+	// A category is chosen explicitly or matched by extension and host; MIME types are only known after probing
+	category := config.FindCategory(settings.Categories, opts.Category)
+	if opts.Category != "" && category == nil {
+		return "", fmt.Errorf("unknown category %q", opts.Category)
+	}
+	if category == nil {
+		category = config.MatchCategory(settings.Categories, url, filename, "")
+	}
+	defaultDir := path == "" || opts.DefaultDir
+
 	// Prepare output path
 	outPath := path
+	if defaultDir && category != nil && category.Dir != "" {
+		outPath = category.Dir
+	}
 	if outPath == "" {
 		if settings.General.DefaultDownloadDir != "" {
 			outPath = settings.General.DefaultDownloadDir
@@ -403,6 +659,7 @@ func (s *LocalDownloadService) Add(url string, path string, filename string, mir
 	// Create configuration
 	state := types.NewProgressState(id, 0)
 	state.DestPath = filepath.Join(outPath, filename) // Best guess until download starts
+	state.SpeedLimit.SetLimit(opts.SpeedLimit)
 
 	cfg := types.DownloadConfig{
 		URL:        url,
@@ -413,8 +670,20 @@ func (s *LocalDownloadService) Add(url string, path string, filename string, mir
 		Verbose:    false,
 		ProgressCh: s.InputCh,
 		State:      state,
-		Runtime:    types.ConvertRuntimeConfig(settings.ToRuntimeConfig()),
+		Runtime:    types.ConvertRuntimeConfig(settings.ToRuntimeConfig()).ForCategory(category),
 		Headers:    headers,
+		Checksum:   expectedChecksum,
+		StartAt:    opts.StartAt,
+		Window:     opts.Window,
+		Extract:    extractMode,
+		Variant:    opts.Variant,
+		Priority:   opts.Priority,
+		DefaultDir: defaultDir && category == nil,
+
+		ExpectedSize: opts.ExpectedSize,
+	}
+	if category != nil {
+		cfg.Category = category.Name
 	}
 
 	s.Pool.Add(cfg)
@@ -446,7 +715,7 @@ func (s *LocalDownloadService) Pause(id string) error {
 		return nil // Already stopped
 	}
 
-	return fmt.Errorf("download not found")
+	return ErrNotFound
 }
 
 // Resume resumes a paused download.
@@ -463,11 +732,11 @@ func (s *LocalDownloadService) Resume(id string) error {
 	// Cold Resume Logic
 	entry, err := state.GetDownload(id)
 	if err != nil || entry == nil {
-		return fmt.Errorf("download not found")
+		return ErrNotFound
 	}
 
 	if entry.Status == "completed" {
-		return fmt.Errorf("download already completed")
+		return ErrAlreadyCompleted
 	}
 
 	s.settingsMu.RLock()
@@ -480,11 +749,17 @@ func (s *LocalDownloadService) Resume(id string) error {
 		outputPath = "."
 	}
 
+	// Scheduled downloads that never started only know their target directory
+	if entry.DestPath == "" && entry.OutputDir != "" {
+		outputPath = entry.OutputDir
+	}
+
 	// Load saved state
 	savedState, stateErr := state.LoadState(entry.URL, entry.DestPath)
 
 	var mirrorURLs []string
 	var dmState *types.ProgressState
+	expectedChecksum := entry.Checksum
 
 	if stateErr == nil && savedState != nil {
 		dmState = types.NewProgressState(id, savedState.TotalSize)
@@ -507,6 +782,7 @@ func (s *LocalDownloadService) Resume(id string) error {
 		dmState.DestPath = entry.DestPath
 		mirrorURLs = []string{entry.URL}
 	}
+	dmState.SpeedLimit.SetLimit(entry.SpeedLimit)
 
 	cfg := types.DownloadConfig{
 		URL:        entry.URL,
@@ -519,8 +795,18 @@ func (s *LocalDownloadService) Resume(id string) error {
 		ProgressCh: s.InputCh,
 		State:      dmState,
 		SavedState: savedState, // Pass loaded state to avoid re-query
-		Runtime:    types.ConvertRuntimeConfig(settings.ToRuntimeConfig()),
+		Runtime:    types.ConvertRuntimeConfig(settings.ToRuntimeConfig()).ForCategory(config.FindCategory(settings.Categories, entry.Category)),
 		Mirrors:    mirrorURLs,
+		Checksum:   expectedChecksum,
+		StartAt:    unixTime(entry.StartAt),
+		Window:     entry.Window,
+		Extract:    entry.Extract,
+		Variant:    entry.Variant,
+		Priority:   entry.Priority,
+		Category:   entry.Category,
+		Headers:    savedHeaders(id),
+
+		ExpectedSize: entry.ExpectedSize,
 	}
 
 	s.Pool.Add(cfg)
@@ -533,6 +819,16 @@ func (s *LocalDownloadService) Resume(id string) error {
 	return nil
 }
 
+// savedHeaders returns the request headers stored for a download, so that it
+// resumes with the credentials it was added with
+func savedHeaders(id string) map[string]string {
+	headers, err := state.LoadHeaders(id)
+	if err != nil {
+		utils.Debug("Failed to load headers of %s: %v", id, err)
+	}
+	return headers
+}
+
 // ResumeBatch resumes multiple paused downloads efficiently.
 func (s *LocalDownloadService) ResumeBatch(ids []string) []error {
 	errs := make([]error, len(ids))
@@ -592,6 +888,11 @@ func (s *LocalDownloadService) ResumeBatch(ids []string) []error {
 			errs[idx] = fmt.Errorf("download not found or completed")
 			continue
 		}
+		if savedState.DestPath == "" {
+			// Never started (e.g. scheduled); the cold path restores its target directory
+			errs[idx] = s.Resume(id)
+			continue
+		}
 
 		// Create Config
 		var dmState *types.ProgressState
@@ -611,6 +912,7 @@ func (s *LocalDownloadService) ResumeBatch(ids []string) []error {
 			dmState.SetMirrors(mirrors)
 		}
 		dmState.DestPath = savedState.DestPath
+		dmState.SpeedLimit.SetLimit(savedState.SpeedLimit)
 
 		cfg := types.DownloadConfig{
 			URL:        savedState.URL,
@@ -623,8 +925,18 @@ func (s *LocalDownloadService) ResumeBatch(ids []string) []error {
 			ProgressCh: s.InputCh,
 			State:      dmState,
 			SavedState: savedState, // Pass loaded state to avoid re-query
-			Runtime:    types.ConvertRuntimeConfig(settings.ToRuntimeConfig()),
+			Runtime:    types.ConvertRuntimeConfig(settings.ToRuntimeConfig()).ForCategory(config.FindCategory(settings.Categories, savedState.Category)),
 			Mirrors:    mirrorURLs,
+			Checksum:   savedState.Checksum,
+			StartAt:    unixTime(savedState.StartAt),
+			Window:     savedState.Window,
+			Extract:    savedState.Extract,
+			Variant:    savedState.Variant,
+			Priority:   savedState.Priority,
+			Category:   savedState.Category,
+			Headers:    savedHeaders(id),
+
+			ExpectedSize: savedState.ExpectedSize,
 		}
 
 		s.Pool.Add(cfg)
@@ -648,8 +960,12 @@ func (s *LocalDownloadService) Delete(id string) error {
 	if entry, err := state.GetDownload(id); err == nil && entry != nil {
 		removedFilename = entry.Filename
 		_ = state.DeleteState(entry.ID, entry.URL, entry.DestPath)
+		if torrent.IsMagnet(entry.URL) {
+			torrent.StopSeeding(entry.URL)
+		}
 		if entry.DestPath != "" && entry.Status != "completed" {
-			_ = os.Remove(entry.DestPath + types.IncompleteSuffix)
+			// Multi-file torrents download into a directory
+			_ = os.RemoveAll(entry.DestPath + types.IncompleteSuffix)
 		}
 	}
 
@@ -668,6 +984,50 @@ func (s *LocalDownloadService) Delete(id string) error {
 	return nil
 }
 
+// Move reorders a queued download. Downloads that are running, paused or
+// finished are not in the queue and fail with types.ErrNotQueued.
+func (s *LocalDownloadService) Move(id string, direction string) error {
+	if s.Pool == nil {
+		return fmt.Errorf("worker pool not initialized")
+	}
+
+	err := s.Pool.Move(id, direction)
+	if errors.Is(err, types.ErrNotQueued) && s.Pool.GetStatus(id) == nil {
+		if entry, dbErr := state.GetDownload(id); dbErr != nil || entry == nil {
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+// SetSpeedLimit changes a bandwidth cap while downloads keep running.
+// An empty id targets the global limit, which is not persisted here (it lives in settings).
+func (s *LocalDownloadService) SetSpeedLimit(id string, bytesPerSec int64) error {
+	if bytesPerSec < 0 {
+		return fmt.Errorf("invalid speed limit: %d", bytesPerSec)
+	}
+	if id == "" {
+		bandwidth.Global().SetLimit(bytesPerSec)
+		return nil
+	}
+
+	inPool := s.Pool != nil && s.Pool.SetSpeedLimit(id, bytesPerSec)
+
+	// Persist so the cap survives pauses and restarts
+	if err := state.UpdateSpeedLimit(id, bytesPerSec); err != nil && !inPool {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetActiveWindows restricts the pool's downloads to daily windows
+func (s *LocalDownloadService) SetActiveWindows(specs []string) error {
+	if s.Pool == nil {
+		return fmt.Errorf("worker pool not initialized")
+	}
+	return s.Pool.SetActiveWindows(specs)
+}
+
 // GetStatus returns a status for a single download by id.
 func (s *LocalDownloadService) GetStatus(id string) (*types.DownloadStatus, error) {
 	if id == "" {
@@ -706,11 +1066,14 @@ func (s *LocalDownloadService) GetStatus(id string) (*types.DownloadStatus, erro
 			Progress:   progress,
 			Speed:      speed,
 			Status:     entry.Status,
+			SpeedLimit: entry.SpeedLimit,
+			Priority:   entry.Priority,
+			Category:   entry.Category,
 		}
 		return &status, nil
 	}
 
-	return nil, fmt.Errorf("download not found")
+	return nil, ErrNotFound
 }
 
 // History returns completed downloads
@@ -718,3 +1081,11 @@ func (s *LocalDownloadService) History() ([]types.DownloadEntry, error) {
 	// For local service, we can directly access the state DB
 	return state.LoadCompletedDownloads()
 }
+
+// unixTime converts a persisted Unix timestamp to a time, treating 0 as unset
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
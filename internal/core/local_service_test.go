@@ -1,16 +1,24 @@
 package core
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/download"
 	"github.com/surge-downloader/surge/internal/engine/events"
 	"github.com/surge-downloader/surge/internal/engine/state"
 	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/extract"
+	"github.com/surge-downloader/surge/internal/hooks"
 )
 
 func TestLocalDownloadService_Delete_DBOnlyBroadcastsRemoved(t *testing.T) {
@@ -81,3 +89,139 @@ func TestLocalDownloadService_Delete_DBOnlyBroadcastsRemoved(t *testing.T) {
 		t.Fatalf("expected entry to be removed, got %+v", entry)
 	}
 }
+
+func TestLocalDownloadService_CompleteTriggersHooks(t *testing.T) {
+	tempDir := t.TempDir()
+	state.CloseDB()
+	state.Configure(filepath.Join(tempDir, "surge.db"))
+	defer state.CloseDB()
+
+	received := make(chan hooks.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hooks.Event
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		received <- ev
+	}))
+	defer server.Close()
+
+	ch := make(chan interface{}, 20)
+	pool := download.NewWorkerPool(ch, 1)
+	svc := NewLocalDownloadServiceWithInput(pool, ch)
+	defer func() { _ = svc.Shutdown() }()
+	settings := config.DefaultSettings()
+	settings.Hooks = []config.HookSettings{{Name: "notify", URL: server.URL}}
+	svc.settingsMu.Lock()
+	svc.settings = settings
+	svc.settingsMu.Unlock()
+
+	streamCh, cleanup, err := svc.StreamEvents(context.Background())
+	if err != nil {
+		t.Fatalf("failed to stream events: %v", err)
+	}
+	defer cleanup()
+
+	id := "hook-complete-id"
+	destPath := filepath.Join(tempDir, "file.bin")
+	if err := state.AddToMasterList(types.DownloadEntry{
+		ID:        id,
+		URL:       "https://example.com/file.bin",
+		DestPath:  destPath,
+		Filename:  "file.bin",
+		Status:    "completed",
+		TotalSize: 1000,
+	}); err != nil {
+		t.Fatalf("failed to seed entry: %v", err)
+	}
+
+	ch <- events.DownloadCompleteMsg{DownloadID: id, Filename: "file.bin", Total: 1000, Elapsed: time.Second}
+
+	select {
+	case ev := <-received:
+		if ev.Type != hooks.EventComplete || ev.ID != id || ev.Path != destPath || ev.URL != "https://example.com/file.bin" {
+			t.Errorf("webhook payload = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-streamCh:
+			if m, ok := msg.(events.HookResultMsg); ok {
+				if m.DownloadID != id || m.Hook != "notify" || m.Err != "" || m.ExitCode != http.StatusOK {
+					t.Errorf("hook result = %+v", m)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected HookResultMsg")
+		}
+	}
+}
+
+func TestLocalDownloadService_CompleteExtractsArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	state.CloseDB()
+	state.Configure(filepath.Join(tempDir, "surge.db"))
+	defer state.CloseDB()
+
+	archivePath := filepath.Join(tempDir, "bundle.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("docs/readme.txt")
+	_, _ = w.Write([]byte("hello"))
+	_ = zw.Close()
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 20)
+	pool := download.NewWorkerPool(ch, 1)
+	svc := NewLocalDownloadServiceWithInput(pool, ch)
+	defer func() { _ = svc.Shutdown() }()
+
+	streamCh, cleanup, err := svc.StreamEvents(context.Background())
+	if err != nil {
+		t.Fatalf("failed to stream events: %v", err)
+	}
+	defer cleanup()
+
+	id := "extract-complete-id"
+	if err := state.AddToMasterList(types.DownloadEntry{
+		ID:       id,
+		URL:      "https://example.com/bundle.zip",
+		DestPath: archivePath,
+		Filename: "bundle.zip",
+		Status:   "completed",
+		Extract:  extract.ModeDelete,
+	}); err != nil {
+		t.Fatalf("failed to seed entry: %v", err)
+	}
+
+	ch <- events.DownloadCompleteMsg{DownloadID: id, Filename: "bundle.zip"}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-streamCh:
+			switch m := msg.(type) {
+			case events.ExtractErrorMsg:
+				t.Fatalf("extraction failed: %s", m.Err)
+			case events.ExtractCompleteMsg:
+				if m.Dir != filepath.Join(tempDir, "bundle") || m.Files != 1 || !m.ArchiveDeleted {
+					t.Errorf("extract result = %+v", m)
+				}
+				if data, err := os.ReadFile(filepath.Join(m.Dir, "docs", "readme.txt")); err != nil || string(data) != "hello" {
+					t.Errorf("extracted file = %q, %v", data, err)
+				}
+				if _, err := os.Stat(archivePath); !os.IsNotExist(err) {
+					t.Error("archive was not deleted")
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected ExtractCompleteMsg")
+		}
+	}
+}
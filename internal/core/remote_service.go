@@ -4,16 +4,22 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/download"
 	"github.com/surge-downloader/surge/internal/engine/events"
 	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/websocket"
 )
 
 // RemoteDownloadService implements DownloadService for a remote daemon.
@@ -22,8 +28,11 @@ type RemoteDownloadService struct {
 	Token     string
 	Client    *http.Client
 	SSEClient *http.Client
+	TLSConfig *tls.Config // For https:// daemons; nil uses the system roots
 	ctx       context.Context
 	cancel    context.CancelFunc
+
+	ws atomic.Pointer[wsClient] // Connected event socket, which also carries commands
 }
 
 // NewRemoteDownloadService creates a new remote service instance.
@@ -39,6 +48,16 @@ func NewRemoteDownloadService(baseURL string, token string) *RemoteDownloadServi
 	}
 }
 
+// UseTLSConfig makes every connection to the daemon use cfg, e.g. to trust its
+// pinned self-signed certificate
+func (s *RemoteDownloadService) UseTLSConfig(cfg *tls.Config) {
+	s.TLSConfig = cfg
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	s.Client = &http.Client{Timeout: 30 * time.Second, Transport: transport}
+	s.SSEClient = &http.Client{Transport: transport}
+}
+
 func (s *RemoteDownloadService) doRequest(method, path string, body interface{}) (*http.Response, error) {
 	var bodyReader io.Reader
 	if body != nil {
@@ -76,13 +95,17 @@ func (s *RemoteDownloadService) doRequest(method, path string, body interface{})
 
 // List returns the status of all active and completed downloads.
 func (s *RemoteDownloadService) List() ([]types.DownloadStatus, error) {
+	var statuses []types.DownloadStatus
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandList}, &statuses); ok {
+		return statuses, err
+	}
+
 	resp, err := s.doRequest("GET", "/list", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	var statuses []types.DownloadStatus
 	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
 		return nil, err
 	}
@@ -91,13 +114,17 @@ func (s *RemoteDownloadService) List() ([]types.DownloadStatus, error) {
 
 // History returns completed downloads
 func (s *RemoteDownloadService) History() ([]types.DownloadEntry, error) {
+	var history []types.DownloadEntry
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandHistory}, &history); ok {
+		return history, err
+	}
+
 	resp, err := s.doRequest("GET", "/history", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	var history []types.DownloadEntry
 	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
 		return nil, err
 	}
@@ -106,13 +133,20 @@ func (s *RemoteDownloadService) History() ([]types.DownloadEntry, error) {
 
 // GetStatus returns a status for a single download by id.
 func (s *RemoteDownloadService) GetStatus(id string) (*types.DownloadStatus, error) {
+	var status types.DownloadStatus
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandGet, DownloadID: id}, &status); ok {
+		if err != nil {
+			return nil, err
+		}
+		return &status, nil
+	}
+
 	resp, err := s.doRequest("GET", "/download?id="+url.QueryEscape(id), nil)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	var status types.DownloadStatus
 	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
 		return nil, err
 	}
@@ -120,7 +154,11 @@ func (s *RemoteDownloadService) GetStatus(id string) (*types.DownloadStatus, err
 }
 
 // Add queues a new download.
-func (s *RemoteDownloadService) Add(url string, path string, filename string, mirrors []string, headers map[string]string) (string, error) {
+func (s *RemoteDownloadService) Add(url string, path string, filename string, mirrors []string, headers map[string]string, opts types.DownloadOptions) (string, error) {
+	// The daemon resolves its own default directory, and the category's
+	if opts.DefaultDir {
+		path = ""
+	}
 	req := map[string]interface{}{
 		"url":           url,
 		"path":          path,
@@ -129,6 +167,43 @@ func (s *RemoteDownloadService) Add(url string, path string, filename string, mi
 		"headers":       headers,
 		"skip_approval": true,
 	}
+	if opts.Checksum != "" {
+		req["checksum"] = opts.Checksum
+	}
+	if !opts.StartAt.IsZero() {
+		req["start_at"] = opts.StartAt.Format(time.RFC3339)
+	}
+	if opts.Window != "" {
+		req["window"] = opts.Window
+	}
+	if opts.SpeedLimit > 0 {
+		req["limit"] = strconv.FormatInt(opts.SpeedLimit, 10)
+	}
+	if opts.Extract != "" {
+		req["extract"] = opts.Extract
+	}
+	if opts.Variant != "" {
+		req["variant"] = opts.Variant
+	}
+	if opts.Priority != download.PriorityNormal {
+		req["priority"] = download.PriorityName(opts.Priority)
+	}
+	if opts.Category != "" {
+		req["category"] = opts.Category
+	}
+	if opts.ExpectedSize > 0 {
+		req["size"] = opts.ExpectedSize
+	}
+	if opts.Cookies != "" {
+		req["cookies"] = opts.Cookies
+	}
+
+	var result map[string]string
+	if body, err := json.Marshal(req); err == nil {
+		if ok, err := s.wsCommand(WSCommand{Type: WSCommandAdd, Request: body}, &result); ok {
+			return result["id"], err
+		}
+	}
 
 	resp, err := s.doRequest("POST", "/download", req)
 	if err != nil {
@@ -136,7 +211,6 @@ func (s *RemoteDownloadService) Add(url string, path string, filename string, mi
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	var result map[string]string
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", err
 	}
@@ -145,6 +219,10 @@ func (s *RemoteDownloadService) Add(url string, path string, filename string, mi
 
 // Pause pauses an active download.
 func (s *RemoteDownloadService) Pause(id string) error {
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandPause, DownloadID: id}, nil); ok {
+		return err
+	}
+
 	resp, err := s.doRequest("POST", "/pause?id="+url.QueryEscape(id), nil)
 	if err != nil {
 		return err
@@ -155,6 +233,10 @@ func (s *RemoteDownloadService) Pause(id string) error {
 
 // Resume resumes a paused download.
 func (s *RemoteDownloadService) Resume(id string) error {
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandResume, DownloadID: id}, nil); ok {
+		return err
+	}
+
 	resp, err := s.doRequest("POST", "/resume?id="+url.QueryEscape(id), nil)
 	if err != nil {
 		return err
@@ -174,6 +256,10 @@ func (s *RemoteDownloadService) ResumeBatch(ids []string) []error {
 
 // Delete cancels and removes a download.
 func (s *RemoteDownloadService) Delete(id string) error {
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandDelete, DownloadID: id}, nil); ok {
+		return err
+	}
+
 	resp, err := s.doRequest("POST", "/delete?id="+url.QueryEscape(id), nil)
 	// Some APIs use DELETE method, checking previous implementation in server it supports both POST and DELETE
 	// but mostly POST for actions. Let's stick to POST as per server implementation.
@@ -184,13 +270,58 @@ func (s *RemoteDownloadService) Delete(id string) error {
 	return nil
 }
 
+// SetSpeedLimit changes a bandwidth cap on the daemon (empty id = global).
+func (s *RemoteDownloadService) SetSpeedLimit(id string, bytesPerSec int64) error {
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandLimit, DownloadID: id, SpeedLimit: bytesPerSec}, nil); ok {
+		return err
+	}
+
+	query := url.Values{}
+	if id != "" {
+		query.Set("id", id)
+	}
+	query.Set("rate", strconv.FormatInt(bytesPerSec, 10))
+
+	resp, err := s.doRequest("POST", "/limit?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return nil
+}
+
+// SetActiveWindows is a no-op: the daemon follows the windows in its own settings.
+func (s *RemoteDownloadService) SetActiveWindows(specs []string) error {
+	return nil
+}
+
+// Move reorders a queued download on the daemon.
+func (s *RemoteDownloadService) Move(id string, direction string) error {
+	if ok, err := s.wsCommand(WSCommand{Type: WSCommandMove, DownloadID: id, Direction: direction}, nil); ok {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("id", id)
+	query.Set("to", direction)
+
+	resp, err := s.doRequest("POST", "/move?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return nil
+}
+
 // Shutdown stops the service.
 func (s *RemoteDownloadService) Shutdown() error {
 	s.cancel()
 	return nil
 }
 
-// StreamEvents returns a channel that receives real-time download events via SSE.
+// StreamEvents returns a channel that receives real-time download events. They come
+// over the daemon's WebSocket, which then also carries commands, or over SSE from
+// daemons without one.
 func (s *RemoteDownloadService) StreamEvents(ctx context.Context) (<-chan interface{}, func(), error) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -209,6 +340,7 @@ func (s *RemoteDownloadService) Publish(msg interface{}) error {
 func (s *RemoteDownloadService) streamWithReconnect(ctx context.Context, ch chan interface{}) {
 	defer close(ch)
 	backoff := 1 * time.Second
+	useSSE := false
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -218,7 +350,18 @@ func (s *RemoteDownloadService) streamWithReconnect(ctx context.Context, ch chan
 		default:
 		}
 
-		err := s.connectSSE(ctx, ch)
+		var err error
+		if useSSE {
+			err = s.connectSSE(ctx, ch)
+		} else {
+			err = s.connectWS(ctx, ch)
+			var hsErr *websocket.HandshakeError
+			if errors.As(err, &hsErr) && hsErr.StatusCode == http.StatusNotFound {
+				// Daemon predates /ws
+				useSSE = true
+				continue
+			}
+		}
 		if err == nil {
 			return // Clean shutdown (e.g. server closed stream cleanly or context canceled during request)
 		}
@@ -294,63 +437,8 @@ func (s *RemoteDownloadService) connectSSE(ctx context.Context, ch chan interfac
 		}
 		jsonData := strings.Join(dataLines, "\n")
 
-		var msg interface{}
-		switch eventType {
-		case "progress":
-			var m events.ProgressMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "started":
-			var m events.DownloadStartedMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "complete":
-			var m events.DownloadCompleteMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "error":
-			var m events.DownloadErrorMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "paused":
-			var m events.DownloadPausedMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "resumed":
-			var m events.DownloadResumedMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "queued":
-			var m events.DownloadQueuedMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "removed":
-			var m events.DownloadRemovedMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		case "request":
-			var m events.DownloadRequestMsg
-			if err := json.Unmarshal([]byte(jsonData), &m); err != nil {
-				continue
-			}
-			msg = m
-		default:
+		msg, err := events.Decode(eventType, []byte(jsonData))
+		if err != nil {
 			continue
 		}
 
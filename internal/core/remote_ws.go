@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/websocket"
+)
+
+var (
+	// errWSClosed refuses commands once the socket is gone; they were not sent
+	errWSClosed = errors.New("websocket connection closed")
+
+	// errWSLost fails commands still waiting when the socket goes away
+	errWSLost = errors.New("websocket connection lost before the command's result")
+)
+
+// wsClient matches command results arriving on the event socket to their callers
+type wsClient struct {
+	conn   *websocket.Conn
+	nextID atomic.Uint64
+
+	mu      sync.Mutex
+	pending map[string]chan WSMessage // nil once the connection is gone
+}
+
+func newWSClient(conn *websocket.Conn) *wsClient {
+	return &wsClient{conn: conn, pending: make(map[string]chan WSMessage)}
+}
+
+// call sends cmd and waits for its result
+func (c *wsClient) call(ctx context.Context, cmd WSCommand) (WSMessage, error) {
+	cmd.ID = strconv.FormatUint(c.nextID.Add(1), 10)
+	done := make(chan WSMessage, 1)
+
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return WSMessage{}, errWSClosed
+	}
+	c.pending[cmd.ID] = done
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.pending != nil {
+			delete(c.pending, cmd.ID)
+		}
+		c.mu.Unlock()
+	}()
+
+	if err := c.conn.WriteJSON(cmd); err != nil {
+		return WSMessage{}, err
+	}
+	select {
+	case msg, ok := <-done:
+		if !ok {
+			return WSMessage{}, errWSLost
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return WSMessage{}, ctx.Err()
+	}
+}
+
+// deliver hands a result to the call waiting for it
+func (c *wsClient) deliver(msg WSMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if done, ok := c.pending[msg.ID]; ok {
+		done <- msg
+		delete(c.pending, msg.ID)
+	}
+}
+
+// closePending fails every waiting call and refuses new ones
+func (c *wsClient) closePending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, done := range c.pending {
+		close(done)
+	}
+	c.pending = nil
+}
+
+// connectWS streams events from the daemon's /ws endpoint and serves commands
+// over it until the connection drops
+func (s *RemoteDownloadService) connectWS(ctx context.Context, ch chan interface{}) error {
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+s.Token)
+	dialer := &websocket.Dialer{TLSConfig: s.TLSConfig}
+	conn, err := dialer.Dial(ctx, s.BaseURL+"/ws", header)
+	if err != nil {
+		return err
+	}
+	conn.SetIdleTimeout(WSIdleTimeout)
+
+	client := newWSClient(conn)
+	s.ws.Store(client)
+	defer func() {
+		s.ws.CompareAndSwap(client, nil)
+		client.closePending()
+	}()
+
+	// Unblock the read loop on shutdown
+	stopCtx := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stopCtx()
+	stopSvc := context.AfterFunc(s.ctx, func() { _ = conn.Close() })
+	defer stopSvc()
+
+	for {
+		var msg WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			_ = conn.Close()
+			if ctx.Err() != nil || s.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		switch msg.Type {
+		case WSMessageResult:
+			client.deliver(msg)
+		case WSMessageEvent:
+			event, err := events.Decode(msg.Event, msg.Data)
+			if err != nil {
+				continue
+			}
+			// Non-blocking send
+			select {
+			case ch <- event:
+			default:
+				// Drop message if channel is full to prevent blocking the reader
+			}
+		}
+	}
+}
+
+// wsCommand runs cmd over the event socket and decodes its result into result.
+// ok is false when no socket is connected and the caller should use HTTP.
+func (s *RemoteDownloadService) wsCommand(cmd WSCommand, result any) (ok bool, err error) {
+	client := s.ws.Load()
+	if client == nil {
+		return false, nil
+	}
+
+	timeout := 30 * time.Second
+	if s.Client != nil && s.Client.Timeout > 0 {
+		timeout = s.Client.Timeout
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+
+	msg, err := client.call(ctx, cmd)
+	if errors.Is(err, errWSClosed) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if msg.Error != nil {
+		if msg.Error.Code == "not_found" {
+			return true, ErrNotFound
+		}
+		return true, fmt.Errorf("API error %s: %s", msg.Error.Code, msg.Error.Message)
+	}
+	if result != nil && len(msg.Data) > 0 {
+		return true, json.Unmarshal(msg.Data, result)
+	}
+	return true, nil
+}
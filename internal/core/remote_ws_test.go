@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/websocket"
+)
+
+// serveTestWS answers commands like a daemon: those about the download "missing"
+// fail, everything else succeeds. It sends one progress event once connected.
+func serveTestWS(commands chan<- WSCommand) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		data, _ := json.Marshal(events.ProgressMsg{DownloadID: "a", Downloaded: 42})
+		if err := conn.WriteJSON(WSMessage{Type: WSMessageEvent, Event: "progress", Data: data}); err != nil {
+			return
+		}
+		for {
+			var cmd WSCommand
+			if err := conn.ReadJSON(&cmd); err != nil {
+				return
+			}
+			commands <- cmd
+			reply := WSMessage{Type: WSMessageResult, ID: cmd.ID}
+			switch {
+			case cmd.DownloadID == "missing":
+				reply.Error = &WSError{Code: "not_found", Message: "download not found"}
+			case cmd.Type == WSCommandAdd:
+				reply.Data = json.RawMessage(`{"id":"new","status":"queued"}`)
+			case cmd.Type == WSCommandGet:
+				reply.Data = json.RawMessage(fmt.Sprintf(`{"id":%q,"status":"paused"}`, cmd.DownloadID))
+			}
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestRemoteDownloadService_WebSocket(t *testing.T) {
+	commands := make(chan WSCommand, 10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", serveTestWS(commands))
+	mux.HandleFunc("/pause", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Pause went over HTTP while the socket was connected")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	svc := NewRemoteDownloadService(srv.URL, "secret")
+	defer func() { _ = svc.Shutdown() }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, cleanup, err := svc.StreamEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	select {
+	case msg := <-stream:
+		if p, ok := msg.(events.ProgressMsg); !ok || p.DownloadID != "a" || p.Downloaded != 42 {
+			t.Fatalf("Event = %#v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event over the socket")
+	}
+
+	if err := svc.Pause("a"); err != nil {
+		t.Errorf("Pause failed: %v", err)
+	}
+	if cmd := <-commands; cmd.Type != WSCommandPause || cmd.DownloadID != "a" {
+		t.Errorf("Daemon got %+v", cmd)
+	}
+	if err := svc.Resume("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resume of a missing download = %v, want ErrNotFound", err)
+	}
+	<-commands
+
+	status, err := svc.GetStatus("b")
+	if err != nil || status.ID != "b" || status.Status != "paused" {
+		t.Errorf("GetStatus = %+v %v", status, err)
+	}
+	<-commands
+
+	id, err := svc.Add("https://example.com/x", "", "x", nil, nil, types.DownloadOptions{})
+	if err != nil || id != "new" {
+		t.Errorf("Add = %q %v", id, err)
+	}
+	cmd := <-commands
+	var req map[string]any
+	if err := json.Unmarshal(cmd.Request, &req); err != nil || req["url"] != "https://example.com/x" || req["skip_approval"] != true {
+		t.Errorf("Add request = %s %v", cmd.Request, err)
+	}
+}
+
+func TestRemoteDownloadService_FallsBackToSSE(t *testing.T) {
+	mux := http.NewServeMux()
+	// An older daemon: no /ws, events over SSE
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "event: paused\ndata: {\"DownloadID\":\"a\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	svc := NewRemoteDownloadService(srv.URL, "secret")
+	defer func() { _ = svc.Shutdown() }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, _, _ := svc.StreamEvents(ctx)
+
+	select {
+	case msg := <-stream:
+		if p, ok := msg.(events.DownloadPausedMsg); !ok || p.DownloadID != "a" {
+			t.Errorf("Event = %#v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event over SSE")
+	}
+}
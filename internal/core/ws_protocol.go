@@ -0,0 +1,64 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The daemon's /ws endpoint carries the same events as /events plus commands.
+// Clients send WSCommand messages; the daemon sends WSMessage messages: every
+// event as it happens, and one result per command echoing the command's id.
+
+// Command types
+const (
+	WSCommandAdd       = "add"       // Request: a download request as accepted by POST /api/v1/downloads
+	WSCommandPause     = "pause"     // DownloadID
+	WSCommandResume    = "resume"    // DownloadID
+	WSCommandDelete    = "delete"    // DownloadID
+	WSCommandLimit     = "limit"     // DownloadID (empty = global cap) and SpeedLimit
+	WSCommandMove      = "move"      // DownloadID and Direction
+	WSCommandSubscribe = "subscribe" // DownloadIDs: only send their events; empty = all
+	WSCommandList      = "list"
+	WSCommandHistory   = "history"
+	WSCommandGet       = "get" // DownloadID
+)
+
+// Message types sent by the daemon
+const (
+	WSMessageEvent  = "event"
+	WSMessageResult = "result"
+)
+
+const (
+	// WSPingInterval is how often the daemon pings an idle connection
+	WSPingInterval = 30 * time.Second
+
+	// WSIdleTimeout drops a connection that sent nothing, pongs included, for this long
+	WSIdleTimeout = 3 * WSPingInterval
+)
+
+// WSCommand is a command sent to the daemon over /ws
+type WSCommand struct {
+	ID          string          `json:"id,omitempty"` // Chosen by the client, echoed in the result
+	Type        string          `json:"type"`
+	DownloadID  string          `json:"download_id,omitempty"`
+	DownloadIDs []string        `json:"download_ids,omitempty"`
+	SpeedLimit  int64           `json:"speed_limit,omitempty"` // Bytes per second, 0 = unlimited
+	Direction   string          `json:"direction,omitempty"`   // "top", "up" or "down"
+	Request     json.RawMessage `json:"request,omitempty"`
+}
+
+// WSMessage is an event or a command result sent by the daemon over /ws
+type WSMessage struct {
+	Type  string          `json:"type"`            // WSMessageEvent or WSMessageResult
+	Event string          `json:"event,omitempty"` // Event name, as in the SSE stream
+	ID    string          `json:"id,omitempty"`    // Id of the command a result answers
+	Data  json.RawMessage `json:"data,omitempty"`  // Event payload or command result
+	Error *WSError        `json:"error,omitempty"` // Set when a command failed
+}
+
+// WSError describes a failed command, with the codes of the REST API's errors
+type WSError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,64 @@
+package download
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// errCategoryFull stops a download whose category, matched only after probing,
+// already runs as many downloads as it may. The pool queues it again.
+var errCategoryFull = errors.New("category is full")
+
+// setCategory applies a category matched after probing to the running download ad.
+// It reports false, leaving ad unchanged, when the category has no room for it.
+func (p *WorkerPool) setCategory(ad *activeDownload, name string, runtime *types.RuntimeConfig, outputPath string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cfg := ad.config
+	cfg.Category, cfg.Runtime, cfg.OutputPath = name, runtime, outputPath
+	if p.categoryFull(cfg) {
+		return false
+	}
+	ad.config.Category, ad.config.Runtime, ad.config.OutputPath = name, runtime, outputPath
+	return true
+}
+
+// categoryFull reports whether cfg's category already runs as many downloads as
+// it may. Caller must hold p.mu.
+func (p *WorkerPool) categoryFull(cfg types.DownloadConfig) bool {
+	if cfg.Category == "" || cfg.Runtime == nil {
+		return false
+	}
+	c := config.FindCategory(cfg.Runtime.Categories, cfg.Category)
+	if c == nil || c.MaxConcurrent <= 0 {
+		return false
+	}
+
+	running := 0
+	for _, ad := range p.downloads {
+		st := ad.config.State
+		if !strings.EqualFold(ad.config.Category, cfg.Category) || st == nil || st.Done.Load() || st.IsPaused() {
+			continue
+		}
+		running++
+	}
+	return running >= c.MaxConcurrent
+}
+
+// wake hands the workers a token for queued downloads skipped while their category or host was full
+func (p *WorkerPool) wake() {
+	p.mu.RLock()
+	pending := len(p.queue) > 0
+	p.mu.RUnlock()
+	if !pending {
+		return
+	}
+	select {
+	case p.taskChan <- struct{}{}:
+	default: // Plenty of tokens already
+	}
+}
@@ -0,0 +1,159 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func TestWorkerPool_Next_CategoryLimit(t *testing.T) {
+	runtime := &types.RuntimeConfig{Categories: []config.CategorySettings{
+		{Name: "Video", MaxConcurrent: 1},
+		{Name: "ISOs"},
+	}}
+	pool := runningPool(make(chan any, 10), types.DownloadConfig{ID: "v1", Category: "Video", Runtime: runtime})
+	pool.maxDownloads = 3
+
+	pool.Add(types.DownloadConfig{ID: "v2", Category: "video", Runtime: runtime})
+	pool.Add(types.DownloadConfig{ID: "iso", Category: "ISOs", Runtime: runtime})
+
+	// v2 waits for v1; the ISO has no limit of its own
+	pool.mu.Lock()
+	cfg, ok := pool.next()
+	pool.mu.Unlock()
+	if !ok || cfg.ID != "iso" {
+		t.Fatalf("next = %q, want iso", cfg.ID)
+	}
+	pool.mu.Lock()
+	_, ok = pool.next()
+	pool.mu.Unlock()
+	if ok {
+		t.Fatal("v2 dispatched while its category was full")
+	}
+
+	// Pausing v1 frees the category's slot
+	pool.downloads["v1"].config.State.Pause()
+	pool.mu.Lock()
+	cfg, ok = pool.next()
+	pool.mu.Unlock()
+	if !ok || cfg.ID != "v2" {
+		t.Errorf("next = %q, want v2", cfg.ID)
+	}
+}
+
+func TestTUIDownload_MatchesCategoryByMIME(t *testing.T) {
+	tmpDir := t.TempDir()
+	state.CloseDB()
+	state.Configure(filepath.Join(tmpDir, "surge.db"))
+	defer state.CloseDB()
+
+	server := testutil.NewMockServerT(t,
+		testutil.WithFileSize(64*types.KB),
+		testutil.WithRangeSupport(false),
+		testutil.WithContentType("video/mp4"),
+	)
+	videoDir := filepath.Join(tmpDir, "Video")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	progState := types.NewProgressState("video-id", 0)
+	cfg := types.DownloadConfig{
+		URL:        server.URL() + "/watch",
+		OutputPath: tmpDir,
+		DefaultDir: true,
+		ID:         progState.ID,
+		Filename:   "watch",
+		ProgressCh: make(chan any, 100),
+		State:      progState,
+		Runtime: &types.RuntimeConfig{Categories: []config.CategorySettings{
+			{Name: "Video", MimeTypes: []string{"video/*"}, Dir: videoDir},
+		}},
+	}
+	if err := TUIDownload(ctx, &cfg); err != nil {
+		t.Fatalf("TUIDownload failed: %v", err)
+	}
+
+	if cfg.Category != "Video" {
+		t.Errorf("Category = %q, want Video", cfg.Category)
+	}
+	if _, err := os.Stat(filepath.Join(videoDir, "watch")); err != nil {
+		t.Errorf("download not in the category's directory: %v", err)
+	}
+	entry, err := state.GetDownload("video-id")
+	if err != nil || entry == nil || entry.Category != "Video" {
+		t.Errorf("persisted entry = %+v, %v", entry, err)
+	}
+}
+
+func TestWorkerPool_SetCategory(t *testing.T) {
+	runtime := &types.RuntimeConfig{Categories: []config.CategorySettings{
+		{Name: "Video", MaxConcurrent: 1},
+		{Name: "ISOs"},
+	}}
+	pool := runningPool(make(chan any, 10), types.DownloadConfig{ID: "v1", Category: "Video", Runtime: runtime})
+	ad := &activeDownload{config: types.DownloadConfig{ID: "probed", Runtime: runtime, OutputPath: "/downloads"}}
+	pool.downloads["probed"] = ad
+
+	// Matched after probing, but v1 already fills the category
+	if pool.setCategory(ad, "Video", runtime, "/videos") {
+		t.Fatal("setCategory succeeded while the category was full")
+	}
+	if ad.config.Category != "" || ad.config.OutputPath != "/downloads" {
+		t.Errorf("rejected category was applied: %+v", ad.config)
+	}
+
+	if !pool.setCategory(ad, "ISOs", runtime, "/isos") {
+		t.Fatal("setCategory failed for a category with room")
+	}
+	if ad.config.Category != "ISOs" || ad.config.OutputPath != "/isos" || ad.config.Runtime != runtime {
+		t.Errorf("category not applied: %+v", ad.config)
+	}
+}
+
+func TestRunDownload_CategoryFull(t *testing.T) {
+	tmpDir := t.TempDir()
+	state.CloseDB()
+	state.Configure(filepath.Join(tmpDir, "surge.db"))
+	defer state.CloseDB()
+
+	server := testutil.NewMockServerT(t,
+		testutil.WithFileSize(64*types.KB),
+		testutil.WithRangeSupport(false),
+		testutil.WithContentType("video/mp4"),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	progState := types.NewProgressState("full-id", 0)
+	cfg := types.DownloadConfig{
+		URL:        server.URL() + "/watch",
+		OutputPath: tmpDir,
+		ID:         progState.ID,
+		Filename:   "watch",
+		ProgressCh: make(chan any, 100),
+		State:      progState,
+		Runtime: &types.RuntimeConfig{Categories: []config.CategorySettings{
+			{Name: "Video", MimeTypes: []string{"video/*"}, MaxConcurrent: 1},
+		}},
+	}
+	full := func(string, *types.RuntimeConfig, string) bool { return false }
+	if err := runDownload(ctx, &cfg, full); !errors.Is(err, errCategoryFull) {
+		t.Fatalf("runDownload = %v, want errCategoryFull", err)
+	}
+	if cfg.Category != "" {
+		t.Errorf("Category = %q, want it left to the pool", cfg.Category)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "watch")); !os.IsNotExist(err) {
+		t.Errorf("download started despite the full category: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package download
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/diskspace"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// lowDiskResumeMargin is the free space needed above the threshold before
+// low-disk pauses resume, so downloads do not flap around it
+const lowDiskResumeMargin = 64 * 1024 * 1024
+
+// Filesystem queries, replaced in tests
+var (
+	freeSpace   = diskspace.Free
+	volumeOf    = diskspace.Volume
+	allocatedOf = diskspace.Allocated
+)
+
+// reservation is the disk space a running download may still write
+type reservation struct {
+	volume  string
+	working string // The .surge file (or directory) being written
+	total   int64
+}
+
+// remaining returns the bytes r still needs beyond what it already occupies on disk
+func (r reservation) remaining() int64 {
+	have, err := allocatedOf(r.working)
+	if err != nil {
+		have = 0
+	}
+	return max(r.total-have, 0)
+}
+
+var (
+	reservationsMu sync.Mutex
+	reservations   = make(map[string]reservation)
+)
+
+// reserveSpace checks that the filesystem holding destPath can take the rest of
+// a size-byte download, on top of what other downloads there still need, while
+// keeping minFree bytes spare. The download then counts against later checks
+// until release is called. Unknown sizes and unsupported filesystems pass.
+func reserveSpace(id, destPath string, size, minFree int64) (release func(), err error) {
+	release = func() {}
+	if size <= 0 {
+		return release, nil
+	}
+	dir := filepath.Dir(destPath)
+	free, err := freeSpace(dir)
+	if err != nil {
+		utils.Debug("Skipping disk space check for %s: %v", dir, err)
+		return release, nil
+	}
+	vol, err := volumeOf(dir)
+	if err != nil {
+		utils.Debug("Skipping disk space check for %s: %v", dir, err)
+		return release, nil
+	}
+
+	r := reservation{volume: vol, working: destPath + types.IncompleteSuffix, total: size}
+
+	reservationsMu.Lock()
+	defer reservationsMu.Unlock()
+
+	need := r.remaining()
+	for otherID, other := range reservations {
+		if otherID != id && other.volume == vol {
+			need += other.remaining()
+		}
+	}
+	if need+minFree > free {
+		return release, fmt.Errorf("%w: %s needed (keeping %s free), %s available in %s", types.ErrNoSpace,
+			utils.ConvertBytesToHumanReadable(need), utils.ConvertBytesToHumanReadable(minFree),
+			utils.ConvertBytesToHumanReadable(free), dir)
+	}
+
+	reservations[id] = r
+	return func() {
+		reservationsMu.Lock()
+		delete(reservations, id)
+		reservationsMu.Unlock()
+	}, nil
+}
+
+// checkDiskSpace pauses running downloads whose filesystem has dropped below
+// their free space threshold and resumes them once space returns
+func (p *WorkerPool) checkDiskSpace(now time.Time) {
+	var toPause, toResume []string
+	free := make(map[string]int64) // By output path, queried once per check
+
+	p.mu.Lock()
+	for id, ad := range p.downloads {
+		st := ad.config.State
+		minFree := ad.config.Runtime.GetMinFreeSpace()
+		if st == nil || st.Done.Load() || st.IsPausing() || minFree == 0 {
+			continue
+		}
+		if _, held := p.windowPaused[id]; held {
+			continue
+		}
+		if p.diskPaused[id] && !st.IsPaused() {
+			delete(p.diskPaused, id) // Resumed by hand
+		}
+		if st.IsPaused() && !p.diskPaused[id] {
+			continue
+		}
+
+		avail, ok := free[ad.config.OutputPath]
+		if !ok {
+			var err error
+			if avail, err = freeSpace(ad.config.OutputPath); err != nil {
+				continue
+			}
+			free[ad.config.OutputPath] = avail
+		}
+
+		if p.diskPaused[id] {
+			if avail >= minFree+lowDiskResumeMargin && p.inWindow(ad.config, now) {
+				delete(p.diskPaused, id)
+				toResume = append(toResume, id)
+			}
+		} else if avail < minFree {
+			p.diskPaused[id] = true
+			toPause = append(toPause, id)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, id := range toPause {
+		utils.Debug("WorkerPool: Low disk space, pausing %s", id)
+		p.pause(id, types.PauseReasonLowDisk)
+	}
+	for _, id := range toResume {
+		utils.Debug("WorkerPool: Disk space available again, resuming %s", id)
+		p.Resume(id)
+	}
+}
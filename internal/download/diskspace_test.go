@@ -0,0 +1,170 @@
+package download
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// fakeDisk replaces the filesystem queries for one test
+func fakeDisk(t *testing.T, free *int64, allocated map[string]int64) {
+	t.Helper()
+	origFree, origVolume, origAllocated := freeSpace, volumeOf, allocatedOf
+	freeSpace = func(string) (int64, error) { return *free, nil }
+	volumeOf = func(string) (string, error) { return "disk", nil }
+	allocatedOf = func(path string) (int64, error) { return allocated[path], nil }
+
+	reservationsMu.Lock()
+	reservations = make(map[string]reservation)
+	reservationsMu.Unlock()
+
+	t.Cleanup(func() {
+		freeSpace, volumeOf, allocatedOf = origFree, origVolume, origAllocated
+		reservationsMu.Lock()
+		reservations = make(map[string]reservation)
+		reservationsMu.Unlock()
+	})
+}
+
+func TestReserveSpace(t *testing.T) {
+	tests := []struct {
+		name      string
+		free      int64
+		size      int64
+		minFree   int64
+		allocated int64 // Already on disk from an earlier session
+		others    int64 // Still needed by another running download
+		wantErr   bool
+	}{
+		{name: "fits", free: 1000, size: 600},
+		{name: "too big", free: 1000, size: 1200, wantErr: true},
+		{name: "threshold", free: 1000, size: 600, minFree: 500, wantErr: true},
+		{name: "resume counts bytes on disk", free: 1000, size: 1500, allocated: 800},
+		{name: "other downloads", free: 1000, size: 600, others: 500, wantErr: true},
+		{name: "unknown size", free: 0, size: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			free := tt.free
+			fakeDisk(t, &free, map[string]int64{"/dl/file.bin" + types.IncompleteSuffix: tt.allocated})
+
+			if tt.others > 0 {
+				releaseOther, err := reserveSpace("other", "/dl/other.bin", tt.others, 0)
+				if err != nil {
+					t.Fatalf("reserving the other download failed: %v", err)
+				}
+				defer releaseOther()
+			}
+
+			release, err := reserveSpace("id", "/dl/file.bin", tt.size, tt.minFree)
+			defer release()
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrNoSpace) {
+					t.Errorf("err = %v, want ErrNoSpace", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReserveSpace_ReleaseFreesRoom(t *testing.T) {
+	free := int64(1000)
+	fakeDisk(t, &free, nil)
+
+	release, err := reserveSpace("a", "/dl/a.bin", 800, 0)
+	if err != nil {
+		t.Fatalf("first reservation failed: %v", err)
+	}
+	if _, err := reserveSpace("b", "/dl/b.bin", 800, 0); err == nil {
+		t.Fatal("second reservation should not fit while the first is running")
+	}
+
+	release()
+	if _, err := reserveSpace("b", "/dl/b.bin", 800, 0); err != nil {
+		t.Errorf("reservation after release failed: %v", err)
+	}
+}
+
+func TestWorkerPool_LowDisk_PausesAndResumes(t *testing.T) {
+	free := int64(1 << 30)
+	fakeDisk(t, &free, nil)
+
+	ch := make(chan any, 20)
+	pool := newSchedulingPool(ch)
+	state := types.NewProgressState("big", 1000)
+	pool.downloads["big"] = &activeDownload{
+		config: types.DownloadConfig{
+			ID:      "big",
+			State:   state,
+			Runtime: &types.RuntimeConfig{MinFreeSpace: 100 << 20},
+		},
+		cancel: func() {},
+	}
+
+	pool.checkDiskSpace(time.Now())
+	if state.IsPaused() {
+		t.Fatal("download paused with plenty of space")
+	}
+
+	free = 50 << 20
+	pool.checkDiskSpace(time.Now())
+	if !state.IsPaused() {
+		t.Fatal("download should pause below the threshold")
+	}
+	paused, ok := (<-ch).(events.DownloadPausedMsg)
+	if !ok || paused.Reason != types.PauseReasonLowDisk {
+		t.Errorf("expected a low-disk DownloadPausedMsg, got %+v", paused)
+	}
+	state.SetPausing(false)
+	if status := pool.GetStatus("big"); status == nil || status.PauseReason != types.PauseReasonLowDisk {
+		t.Errorf("GetStatus = %+v, want pause reason %q", status, types.PauseReasonLowDisk)
+	}
+
+	// Just above the threshold is not enough to resume
+	free = 110 << 20
+	pool.checkDiskSpace(time.Now())
+	if !state.IsPaused() {
+		t.Fatal("download resumed inside the hysteresis margin")
+	}
+
+	free = 1 << 30
+	pool.checkDiskSpace(time.Now())
+	if state.IsPaused() {
+		t.Error("download should resume once space returns")
+	}
+	if pool.PauseReason("big") != "" {
+		t.Error("pause reason should clear after resuming")
+	}
+}
+
+func TestWorkerPool_LowDisk_LeavesUserPausesAlone(t *testing.T) {
+	free := int64(0)
+	fakeDisk(t, &free, nil)
+
+	pool := newSchedulingPool(nil)
+	state := types.NewProgressState("mine", 1000)
+	state.Pause()
+	pool.downloads["mine"] = &activeDownload{
+		config: types.DownloadConfig{
+			ID:      "mine",
+			State:   state,
+			Runtime: &types.RuntimeConfig{MinFreeSpace: 100 << 20},
+		},
+		cancel: func() {},
+	}
+
+	pool.checkDiskSpace(time.Now())
+	free = 1 << 30
+	pool.checkDiskSpace(time.Now())
+	if !state.IsPaused() {
+		t.Error("a download paused by the user must not be resumed by the disk watcher")
+	}
+}
@@ -0,0 +1,73 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func TestTUIDownload_FTP(t *testing.T) {
+	tmpDir := t.TempDir()
+	state.CloseDB()
+	state.Configure(filepath.Join(tmpDir, "surge.db"))
+	defer state.CloseDB()
+
+	data := make([]byte, 3*types.MB)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	server := testutil.NewFTPServerT(t, "pub/release.tar", data, testutil.WithFTPCredentials("alice", "secret"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	probe, err := engine.ProbeServer(ctx, server.URL(), "", nil)
+	if err != nil {
+		t.Fatalf("ProbeServer failed: %v", err)
+	}
+	if !probe.SupportsRange || probe.FileSize != int64(len(data)) || probe.Filename != "release.tar" {
+		t.Errorf("Probe = %+v", probe)
+	}
+	if probe.LastModified != server.ModTime.Format(http.TimeFormat) {
+		t.Errorf("LastModified = %q", probe.LastModified)
+	}
+
+	progState := types.NewProgressState("ftp-manager", 0)
+	cfg := types.DownloadConfig{
+		URL:        server.URL(),
+		OutputPath: tmpDir,
+		ID:         progState.ID,
+		ProgressCh: make(chan any, 100),
+		State:      progState,
+		Runtime:    &types.RuntimeConfig{},
+		Checksum:   "sha256:" + hex.EncodeToString(sum[:]),
+	}
+	if err := download.TUIDownload(ctx, &cfg); err != nil {
+		t.Fatalf("TUIDownload failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(tmpDir, "release.tar"))
+	if err != nil {
+		t.Fatalf("Failed to read download: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Downloaded content differs")
+	}
+	if server.Retrievals.Load() < 2 {
+		t.Errorf("Retrievals = %d, want a segmented download", server.Retrievals.Load())
+	}
+}
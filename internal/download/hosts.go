@@ -0,0 +1,56 @@
+package download
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// hostPolicy returns the host policy matching cfg's URL, or nil
+func hostPolicy(cfg types.DownloadConfig) *config.HostPolicy {
+	if cfg.Runtime == nil {
+		return nil
+	}
+	return config.MatchHostPolicy(cfg.Runtime.HostPolicies, cfg.URL)
+}
+
+// samePolicy reports whether every mirror matches policy, the policy of the download's URL
+func samePolicy(policies []config.HostPolicy, policy *config.HostPolicy, mirrors []string) bool {
+	for _, m := range mirrors {
+		if config.MatchHostPolicy(policies, m) != policy {
+			return false
+		}
+	}
+	return true
+}
+
+// hostname returns the lower-cased hostname of rawURL, or "" if it has none
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// hostFull reports whether cfg's host already serves as many downloads as its
+// policy allows. Caller must hold p.mu.
+func (p *WorkerPool) hostFull(cfg types.DownloadConfig) bool {
+	policy := hostPolicy(cfg)
+	if policy == nil || policy.MaxConcurrent <= 0 {
+		return false
+	}
+	host := hostname(cfg.URL)
+
+	running := 0
+	for _, ad := range p.downloads {
+		st := ad.config.State
+		if hostname(ad.config.URL) != host || st == nil || st.Done.Load() || st.IsPaused() {
+			continue
+		}
+		running++
+	}
+	return running >= policy.MaxConcurrent
+}
@@ -0,0 +1,120 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func TestWorkerPool_Next_HostLimit(t *testing.T) {
+	runtime := &types.RuntimeConfig{HostPolicies: []config.HostPolicy{
+		{Pattern: "*.public.example", MaxConcurrent: 1},
+	}}
+	pool := runningPool(make(chan any, 10), types.DownloadConfig{ID: "a1", URL: "https://dl.public.example/a", Runtime: runtime})
+	pool.maxDownloads = 3
+
+	pool.Add(types.DownloadConfig{ID: "a2", URL: "https://DL.public.example/b", Runtime: runtime})
+	pool.Add(types.DownloadConfig{ID: "other", URL: "https://cdn.public.example/c", Runtime: runtime})
+
+	// a2 waits for a1 on the same host; another host of the pattern has its own limit
+	pool.mu.Lock()
+	cfg, ok := pool.next()
+	pool.mu.Unlock()
+	if !ok || cfg.ID != "other" {
+		t.Fatalf("next = %q, want other", cfg.ID)
+	}
+	pool.mu.Lock()
+	_, ok = pool.next()
+	pool.mu.Unlock()
+	if ok {
+		t.Fatal("a2 dispatched while its host was full")
+	}
+
+	// Finishing a1 frees the host's slot
+	pool.downloads["a1"].config.State.Done.Store(true)
+	pool.mu.Lock()
+	cfg, ok = pool.next()
+	pool.mu.Unlock()
+	if !ok || cfg.ID != "a2" {
+		t.Errorf("next = %q, want a2", cfg.ID)
+	}
+}
+
+func TestSamePolicy(t *testing.T) {
+	policies := []config.HostPolicy{
+		{Pattern: "*.private.example", Headers: map[string]string{"X-Api-Key": "secret"}},
+		{Pattern: "other.example"},
+	}
+	policy := config.MatchHostPolicy(policies, "https://a.private.example/file")
+
+	tests := []struct {
+		name    string
+		mirrors []string
+		want    bool
+	}{
+		{"no mirrors", nil, true},
+		{"same pattern", []string{"https://a.private.example/file", "https://b.private.example/file"}, true},
+		{"other policy", []string{"https://a.private.example/file", "https://other.example/file"}, false},
+		{"no policy", []string{"https://a.private.example/file", "https://public.example/file"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := samePolicy(policies, policy, tt.mirrors); got != tt.want {
+				t.Errorf("samePolicy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTUIDownload_PolicyHeadersStayOffOtherMirrors(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "policy-headers")
+
+	fileSize := int64(256 * types.KB)
+	data := make([]byte, fileSize)
+	var leaked atomic.Bool
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "" {
+			leaked.Store(true)
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+	}
+	primary := testutil.NewMockServerT(t, testutil.WithHandler(serve))
+	defer primary.Close()
+	mirror := testutil.NewMockServerT(t, testutil.WithHandler(serve))
+	defer mirror.Close()
+
+	// Same server, but under a hostname the policy does not cover
+	mirrorURL := strings.Replace(mirror.URL(), "127.0.0.1", "localhost", 1)
+	cfg := types.DownloadConfig{
+		URL:        primary.URL(),
+		Mirrors:    []string{primary.URL(), mirrorURL},
+		OutputPath: tmpDir,
+		ID:         "policy-headers-id",
+		Filename:   "file.bin",
+		ProgressCh: make(chan any, 100),
+		State:      types.NewProgressState("policy-headers-id", 0),
+		Runtime: &types.RuntimeConfig{HostPolicies: []config.HostPolicy{
+			{Pattern: "127.0.0.1", Headers: map[string]string{"X-Api-Key": "secret"}},
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := TUIDownload(ctx, &cfg); err != nil {
+		t.Fatalf("TUIDownload failed: %v", err)
+	}
+	if _, ok := cfg.Headers["X-Api-Key"]; ok {
+		t.Error("Policy headers applied although a mirror is on another host")
+	}
+	if leaked.Load() {
+		t.Error("Policy header sent")
+	}
+}
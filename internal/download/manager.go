@@ -4,17 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/engine"
 	"github.com/surge-downloader/surge/internal/engine/concurrent"
 	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/ftp"
+	"github.com/surge-downloader/surge/internal/engine/netrc"
+	"github.com/surge-downloader/surge/internal/engine/sftp"
 	"github.com/surge-downloader/surge/internal/engine/single"
 	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/stream"
+	"github.com/surge-downloader/surge/internal/engine/torrent"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
 )
@@ -78,17 +85,133 @@ func uniqueFilePath(path string) string {
 	return path
 }
 
+// hasHeader reports whether headers set key, ignoring case
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameHost reports whether every mirror is served by rawURL's host
+func sameHost(rawURL string, mirrors []string) bool {
+	host := hostname(rawURL)
+	for _, m := range mirrors {
+		if hostname(m) != host {
+			return false
+		}
+	}
+	return true
+}
+
 // TUIDownload is the main entry point for TUI downloads
 func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
+	return runDownload(ctx, cfg, nil)
+}
+
+// categorySetter applies a category matched after probing to a download, along with
+// the runtime settings and output directory that come with it. It reports false,
+// leaving the download unchanged, when the category has no room for it.
+type categorySetter func(name string, runtime *types.RuntimeConfig, outputPath string) bool
+
+// runDownload runs a download for TUIDownload. The worker pool passes setCategory so
+// a category matched after probing is applied under its lock; nil writes it to cfg.
+func runDownload(ctx context.Context, cfg *types.DownloadConfig, setCategory categorySetter) error {
+	// Rules for the server override the download's connection settings and headers
+	var policy *config.HostPolicy
+	if cfg.Runtime != nil {
+		policies := cfg.Runtime.HostPolicies
+		if policy = config.MatchHostPolicy(policies, cfg.URL); policy != nil {
+			utils.Debug("TUIDownload: %s matches host policy %s", cfg.URL, policy.Pattern)
+			cfg.Runtime = cfg.Runtime.ForHost(policy)
+			// Headers go to every mirror, so only when all of them fall under this policy
+			if samePolicy(policies, policy, cfg.Mirrors) {
+				cfg.Headers = policy.ApplyHeaders(cfg.Headers)
+			}
+		}
+	}
+
+	// Credentials from ~/.netrc, unless the download brings its own. Headers go to
+	// every mirror, so only when all of them are on the same host.
+	if !hasHeader(cfg.Headers, "Authorization") && sameHost(cfg.URL, cfg.Mirrors) {
+		if auth := netrc.Authorization(cfg.URL); auth != "" {
+			utils.Debug("TUIDownload: using .netrc credentials for %s", cfg.URL)
+			headers := maps.Clone(cfg.Headers)
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers["Authorization"] = auth
+			cfg.Headers = headers
+		}
+	}
+
 	// Probe server once to get all metadata
 	utils.Debug("TUIDownload: Probing server... %s", cfg.URL)
-	probe, err := engine.ProbeServer(ctx, cfg.URL, cfg.Filename, cfg.Headers)
+	var probe *engine.ProbeResult
+	var err error
+	var tor *torrent.Torrent
+	if torrent.IsMagnet(cfg.URL) {
+		// Magnet links have no server to probe: join the swarm and read the metadata
+		if tor, err = torrent.Open(ctx, cfg.URL, cfg.Runtime); err != nil {
+			return fmt.Errorf("failed to open torrent: %w", err)
+		}
+		defer tor.Close()
+		info := tor.Info()
+		probe = &engine.ProbeResult{Filename: info.Name, FileSize: info.Length}
+	} else if sftp.IsSFTPURL(cfg.URL) {
+		probe, err = engine.ProbeSFTP(ctx, cfg.URL, cfg.Filename, cfg.Runtime)
+	} else {
+		probe, err = engine.ProbeServer(ctx, cfg.URL, cfg.Filename, cfg.Headers)
+	}
 	if err != nil {
 		utils.Debug("TUIDownload: Probe failed: %v\n", err)
 		return err
 	}
 	utils.Debug("TUIDownload: Probe success %d", probe.FileSize)
 
+	// HLS/DASH manifests are resolved to their media segments, which are joined into one file
+	var streamPlan *stream.Plan
+	if stream.IsManifest(cfg.URL, probe.ContentType) {
+		sel, err := stream.ParseSelector(cfg.Variant)
+		if err != nil {
+			return err
+		}
+		if streamPlan, err = stream.Open(ctx, cfg.URL, cfg.Headers, sel, cfg.Runtime); err != nil {
+			return fmt.Errorf("failed to read stream manifest: %w", err)
+		}
+		probe.Filename = stream.OutputName(probe.Filename, streamPlan.Ext)
+		probe.FileSize = streamPlan.EstimatedSize
+		probe.SupportsRange = false
+	}
+
+	// A size announced up front (e.g. by a Metalink) must match what the server serves
+	if streamPlan == nil && cfg.ExpectedSize > 0 && probe.FileSize > 0 && probe.FileSize != cfg.ExpectedSize {
+		return fmt.Errorf("%w: expected %d bytes, server reports %d", types.ErrSizeMismatch, cfg.ExpectedSize, probe.FileSize)
+	}
+
+	// The MIME type is known now, so downloads without a category get another chance to match one
+	if cfg.Category == "" && !cfg.IsResume && cfg.Runtime != nil {
+		name := probe.Filename
+		if cfg.Filename != "" {
+			name = cfg.Filename
+		}
+		if c := config.MatchCategory(cfg.Runtime.Categories, cfg.URL, name, probe.ContentType); c != nil {
+			utils.Debug("TUIDownload: %s matches category %s", cfg.URL, c.Name)
+			runtime := cfg.Runtime.ForCategory(c).ForHost(policy)
+			outputPath := cfg.OutputPath
+			if cfg.DefaultDir && c.Dir != "" {
+				outputPath = utils.EnsureAbsPath(c.Dir)
+			}
+			if setCategory == nil {
+				cfg.Category, cfg.Runtime, cfg.OutputPath = c.Name, runtime, outputPath
+			} else if !setCategory(c.Name, runtime, outputPath) {
+				return errCategoryFull
+			}
+		}
+	}
+
 	// Start download timer (exclude probing time)
 	start := time.Now()
 	defer func() {
@@ -159,6 +282,13 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 	cfg.Filename = finalFilename
 	cfg.DestPath = destPath // Save resolved path for resume logic (WorkerPool)
 
+	// Fail before writing anything rather than with ENOSPC half way through
+	release, err := reserveSpace(cfg.ID, destPath, probe.FileSize, cfg.Runtime.GetMinFreeSpace())
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Send download started message
 	if cfg.ProgressCh != nil {
 		cfg.ProgressCh <- events.DownloadStartedMsg{
@@ -167,6 +297,7 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 			Filename:   finalFilename,
 			Total:      probe.FileSize,
 			DestPath:   destPath,
+			Category:   cfg.Category,
 			State:      cfg.State,
 		}
 	}
@@ -178,7 +309,39 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 
 	// Choose downloader based on probe results
 	var downloadErr error
-	if probe.SupportsRange && probe.FileSize > 0 {
+	if tor != nil {
+		utils.Debug("Using torrent downloader")
+		d := torrent.NewTorrentDownloader(cfg.ID, cfg.ProgressCh, cfg.State, cfg.Runtime)
+		d.Checksum = cfg.Checksum
+		d.Extract = cfg.Extract
+		downloadErr = d.Download(ctx, tor, cfg.URL, destPath, cfg.Verbose)
+	} else if streamPlan != nil {
+		utils.Debug("Using stream downloader")
+		d := stream.NewStreamDownloader(cfg.ID, cfg.ProgressCh, cfg.State, cfg.Runtime)
+		d.Headers = cfg.Headers // Forward custom headers from browser extension
+		d.Checksum = cfg.Checksum
+		d.Extract = cfg.Extract
+		d.Variant = cfg.Variant
+		downloadErr = d.Download(ctx, streamPlan, cfg.URL, destPath, cfg.Verbose)
+		// The joined size is only known once every segment is in
+		if info, err := os.Stat(destPath); downloadErr == nil && err == nil {
+			probe.FileSize = info.Size()
+		}
+	} else if ftp.IsFTPURL(cfg.URL) {
+		utils.Debug("Using FTP downloader")
+		d := ftp.NewFTPDownloader(cfg.ID, cfg.ProgressCh, cfg.State, cfg.Runtime)
+		d.Checksum = cfg.Checksum
+		d.LastModified = probe.LastModified
+		d.Extract = cfg.Extract
+		downloadErr = d.Download(ctx, cfg.URL, destPath, probe.FileSize, probe.SupportsRange, cfg.Verbose)
+	} else if sftp.IsSFTPURL(cfg.URL) {
+		utils.Debug("Using SFTP downloader")
+		d := sftp.NewSFTPDownloader(cfg.ID, cfg.ProgressCh, cfg.State, cfg.Runtime)
+		d.Checksum = cfg.Checksum
+		d.LastModified = probe.LastModified
+		d.Extract = cfg.Extract
+		downloadErr = d.Download(ctx, cfg.URL, destPath, probe.FileSize, cfg.Verbose)
+	} else if probe.SupportsRange && probe.FileSize > 0 {
 		utils.Debug("Using concurrent downloader")
 
 		// We probe all candidate mirrors (cfg.Mirrors) to filter out invalid ones
@@ -186,7 +349,13 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 		if len(cfg.Mirrors) > 0 {
 			utils.Debug("Probing %d mirrors", len(cfg.Mirrors))
 			// Always check primary + mirrors to ensure we are using the best set
-			allToCheck := append([]string{cfg.URL}, cfg.Mirrors...)
+			// FTP and SFTP mirrors cannot serve HTTP range requests
+			allToCheck := []string{cfg.URL}
+			for _, m := range cfg.Mirrors {
+				if !ftp.IsFTPURL(m) && !sftp.IsSFTPURL(m) {
+					allToCheck = append(allToCheck, m)
+				}
+			}
 			valid, errs := engine.ProbeMirrors(ctx, allToCheck)
 
 			// Log errors
@@ -205,6 +374,10 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 
 		d := concurrent.NewConcurrentDownloader(cfg.ID, cfg.ProgressCh, cfg.State, cfg.Runtime)
 		d.Headers = cfg.Headers // Forward custom headers from browser extension
+		d.Checksum = cfg.Checksum
+		d.ETag = probe.ETag
+		d.LastModified = probe.LastModified
+		d.Extract = cfg.Extract
 		utils.Debug("Calling Download with mirrors: %v", cfg.Mirrors)
 		downloadErr = d.Download(ctx, cfg.URL, cfg.Mirrors, activeMirrors, destPath, probe.FileSize, cfg.Verbose)
 	} else {
@@ -212,6 +385,7 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 		utils.Debug("Using single-threaded downloader")
 		d := single.NewSingleDownloader(cfg.ID, cfg.ProgressCh, cfg.State, cfg.Runtime)
 		d.Headers = cfg.Headers // Forward custom headers from browser extension
+		d.Checksum = cfg.Checksum
 		downloadErr = d.Download(ctx, cfg.URL, destPath, probe.FileSize, probe.Filename, cfg.Verbose)
 	}
 
@@ -242,6 +416,11 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 			Downloaded:  probe.FileSize,
 			CompletedAt: time.Now().Unix(),
 			TimeTaken:   elapsed.Milliseconds(),
+			Checksum:    cfg.Checksum,
+			Extract:     cfg.Extract,
+			Variant:     cfg.Variant,
+			Priority:    cfg.Priority,
+			Category:    cfg.Category,
 		}); err != nil {
 			utils.Debug("Failed to persist completed download: %v", err)
 		}
@@ -261,19 +440,43 @@ func TUIDownload(ctx context.Context, cfg *types.DownloadConfig) error {
 			return nil
 		}
 
-		// Persist error state
+		// Persist error state (checksum failures are tracked separately so they can be retried)
+		status := "error"
+		downloaded := cfg.State.Downloaded.Load()
+		mismatch := errors.Is(downloadErr, types.ErrChecksumMismatch)
+		if mismatch {
+			status = "verify_failed"
+			// The bytes on disk are known bad: a retry must download them again
+			if err := os.Remove(destPath + types.IncompleteSuffix); err != nil && !os.IsNotExist(err) {
+				utils.Debug("Failed to remove corrupt working file: %v", err)
+			}
+			downloaded = 0
+		}
 		if err := state.AddToMasterList(types.DownloadEntry{
 			ID:         cfg.ID,
 			URL:        cfg.URL,
 			URLHash:    state.URLHash(cfg.URL),
 			DestPath:   destPath,
 			Filename:   finalFilename,
-			Status:     "error",
+			Status:     status,
 			TotalSize:  probe.FileSize,
-			Downloaded: cfg.State.Downloaded.Load(),
+			Downloaded: downloaded,
+			Checksum:   cfg.Checksum,
+			SpeedLimit: cfg.State.SpeedLimit.Limit(),
+			Extract:    cfg.Extract,
+			Variant:    cfg.Variant,
+			Priority:   cfg.Priority,
+			Category:   cfg.Category,
+
+			ExpectedSize: cfg.ExpectedSize,
 		}); err != nil {
 			utils.Debug("Failed to persist error state: %v", err)
 		}
+		if mismatch {
+			if err := state.ResetProgress(cfg.ID); err != nil {
+				utils.Debug("Failed to reset progress of %s: %v", cfg.ID, err)
+			}
+		}
 	}
 
 	return downloadErr
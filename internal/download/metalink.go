@@ -0,0 +1,182 @@
+package download
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+)
+
+// MetalinkMaxSize caps how much of a Metalink document is read
+const MetalinkMaxSize = 10 * 1024 * 1024
+
+// MetalinkContentTypes are the media types of Metalink 4 (RFC 5854) and Metalink 3 documents
+var MetalinkContentTypes = []string{"application/metalink4+xml", "application/metalink+xml"}
+
+// MetalinkFile is one file described by a Metalink document
+type MetalinkFile struct {
+	Name     string
+	Size     int64    // 0 if the document does not say
+	Checksum string   // Strongest supported hash as "algo:hex"; empty if none
+	URLs     []string // Download locations, most preferred first
+}
+
+// metalinkDoc covers both Metalink 4 (<metalink><file>) and Metalink 3
+// (<metalink><files><file>) layouts. Element names are matched regardless of namespace.
+type metalinkDoc struct {
+	XMLName xml.Name       `xml:"metalink"`
+	Files   []metalinkFile `xml:"file"`
+	V3Files []metalinkFile `xml:"files>file"`
+}
+
+type metalinkFile struct {
+	Name     string         `xml:"name,attr"`
+	Size     int64          `xml:"size"`
+	Hashes   []metalinkHash `xml:"hash"`
+	URLs     []metalinkURL  `xml:"url"`
+	V3Hashes []metalinkHash `xml:"verification>hash"`
+	V3URLs   []metalinkURL  `xml:"resources>url"`
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type metalinkURL struct {
+	Priority   int    `xml:"priority,attr"`   // Metalink 4: 1 is most preferred
+	Preference int    `xml:"preference,attr"` // Metalink 3: 100 is most preferred
+	Value      string `xml:",chardata"`
+}
+
+// hashPreference lists supported Metalink hash types, strongest first
+var hashPreference = []string{"sha-512", "sha-256", "sha-1", "md5"}
+
+// IsMetalinkName reports whether a file name or URL path looks like a Metalink document
+func IsMetalinkName(name string) bool {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" && u.Path != "" {
+		name = u.Path
+	}
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".meta4") || strings.HasSuffix(lower, ".metalink")
+}
+
+// IsMetalinkContentType reports whether a Content-Type header denotes a Metalink document
+func IsMetalinkContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range MetalinkContentTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMetalink reads a Metalink 4 or Metalink 3 document. Only HTTP(S) locations
+// are kept; file names containing ".." or absolute paths are rejected.
+func ParseMetalink(r io.Reader) ([]MetalinkFile, error) {
+	var doc metalinkDoc
+	dec := xml.NewDecoder(io.LimitReader(r, MetalinkMaxSize))
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid metalink: %w", err)
+	}
+
+	entries := append(doc.Files, doc.V3Files...)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("invalid metalink: no files")
+	}
+
+	files := make([]MetalinkFile, 0, len(entries))
+	for _, e := range entries {
+		f, err := e.resolve()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func (e metalinkFile) resolve() (MetalinkFile, error) {
+	name := strings.ReplaceAll(strings.TrimSpace(e.Name), "\\", "/")
+	if name == "" {
+		return MetalinkFile{}, fmt.Errorf("invalid metalink: file without a name")
+	}
+	if strings.HasPrefix(name, "/") || slices.Contains(strings.Split(name, "/"), "..") {
+		return MetalinkFile{}, fmt.Errorf("invalid metalink: unsafe file name %q", e.Name)
+	}
+
+	f := MetalinkFile{
+		// Surge downloads into a single directory, so any subdirectory is dropped
+		Name: path.Base(name),
+		Size: e.Size,
+	}
+	if f.Size < 0 {
+		return MetalinkFile{}, fmt.Errorf("invalid metalink: negative size for %s", f.Name)
+	}
+
+	f.Checksum = pickHash(append(e.Hashes, e.V3Hashes...))
+	f.URLs = orderURLs(e.URLs, e.V3URLs)
+	if len(f.URLs) == 0 {
+		return MetalinkFile{}, fmt.Errorf("invalid metalink: no HTTP(S) URLs for %s", f.Name)
+	}
+	return f, nil
+}
+
+// pickHash returns the strongest valid hash as "algo:hex"
+func pickHash(hashes []metalinkHash) string {
+	for _, want := range hashPreference {
+		for _, h := range hashes {
+			typ := strings.ToLower(strings.TrimSpace(h.Type))
+			// Metalink 3 spells types without the dash ("sha256")
+			if typ != want && typ != strings.ReplaceAll(want, "-", "") {
+				continue
+			}
+			if spec, err := checksum.Parse(want + ":" + strings.TrimSpace(h.Value)); err == nil {
+				return spec.String()
+			}
+		}
+	}
+	return ""
+}
+
+// orderURLs keeps HTTP(S) locations, most preferred first, without duplicates
+func orderURLs(v4, v3 []metalinkURL) []string {
+	type ranked struct {
+		url  string
+		rank int // Lower is better
+	}
+	var all []ranked
+	for _, u := range v4 {
+		rank := u.Priority
+		if rank <= 0 {
+			rank = 1 << 30 // Unranked locations go last
+		}
+		all = append(all, ranked{strings.TrimSpace(u.Value), rank})
+	}
+	for _, u := range v3 {
+		all = append(all, ranked{strings.TrimSpace(u.Value), 100 - u.Preference})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].rank < all[j].rank })
+
+	seen := make(map[string]bool)
+	var urls []string
+	for _, r := range all {
+		parsed, err := url.Parse(r.url)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			continue
+		}
+		if !seen[r.url] {
+			seen[r.url] = true
+			urls = append(urls, r.url)
+		}
+	}
+	return urls
+}
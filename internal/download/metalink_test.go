@@ -0,0 +1,153 @@
+package download
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+const testMeta4 = `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <published>2024-01-01T00:00:00Z</published>
+  <file name="distro.iso">
+    <size>14471447</size>
+    <hash type="md5">5d41402abc4b2a76b9719d911017c592</hash>
+    <hash type="sha-256">` + testSHA256 + `</hash>
+    <url location="de" priority="2">https://de.example.com/distro.iso</url>
+    <url location="us" priority="1">https://us.example.com/distro.iso</url>
+    <url>http://fallback.example.com/distro.iso</url>
+    <url priority="3">ftp://ftp.example.com/distro.iso</url>
+    <metaurl mediatype="torrent">https://example.com/distro.iso.torrent</metaurl>
+  </file>
+  <file name="sub/distro.iso.sig">
+    <url priority="1">https://us.example.com/distro.iso.sig</url>
+  </file>
+</metalink>`
+
+const testMetalink3 = `<?xml version="1.0" encoding="UTF-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="tool.tar.gz">
+      <size>2048</size>
+      <verification>
+        <hash type="sha256">` + testSHA256 + `</hash>
+      </verification>
+      <resources>
+        <url type="http" preference="50">http://slow.example.com/tool.tar.gz</url>
+        <url type="http" preference="100">http://fast.example.com/tool.tar.gz</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`
+
+func TestParseMetalink_V4(t *testing.T) {
+	files, err := ParseMetalink(strings.NewReader(testMeta4))
+	if err != nil {
+		t.Fatalf("ParseMetalink failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+
+	iso := files[0]
+	if iso.Name != "distro.iso" || iso.Size != 14471447 {
+		t.Errorf("file = %+v", iso)
+	}
+	if iso.Checksum != "sha256:"+testSHA256 {
+		t.Errorf("Checksum = %q, want strongest hash", iso.Checksum)
+	}
+	wantURLs := []string{
+		"https://us.example.com/distro.iso",
+		"https://de.example.com/distro.iso",
+		"http://fallback.example.com/distro.iso",
+	}
+	if !reflect.DeepEqual(iso.URLs, wantURLs) {
+		t.Errorf("URLs = %v, want %v", iso.URLs, wantURLs)
+	}
+
+	sig := files[1]
+	if sig.Name != "distro.iso.sig" || sig.Checksum != "" || sig.Size != 0 {
+		t.Errorf("second file = %+v", sig)
+	}
+}
+
+func TestParseMetalink_V3(t *testing.T) {
+	files, err := ParseMetalink(strings.NewReader(testMetalink3))
+	if err != nil {
+		t.Fatalf("ParseMetalink failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	f := files[0]
+	if f.Size != 2048 || f.Checksum != "sha256:"+testSHA256 {
+		t.Errorf("file = %+v", f)
+	}
+	if len(f.URLs) != 2 || f.URLs[0] != "http://fast.example.com/tool.tar.gz" {
+		t.Errorf("URLs = %v, want preferred mirror first", f.URLs)
+	}
+}
+
+func TestParseMetalink_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"not xml", "hello"},
+		{"wrong root", `<feed><file name="a"><url>https://example.com/a</url></file></feed>`},
+		{"no files", `<metalink xmlns="urn:ietf:params:xml:ns:metalink"></metalink>`},
+		{"traversal", `<metalink><file name="../../etc/passwd"><url>https://example.com/a</url></file></metalink>`},
+		{"inner traversal", `<metalink><file name="isos/../../passwd"><url>https://example.com/a</url></file></metalink>`},
+		{"absolute", `<metalink><file name="/etc/passwd"><url>https://example.com/a</url></file></metalink>`},
+		{"no name", `<metalink><file><url>https://example.com/a</url></file></metalink>`},
+		{"no http urls", `<metalink><file name="a"><url>ftp://example.com/a</url></file></metalink>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMetalink(strings.NewReader(tt.doc)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestParseMetalink_DotsInName(t *testing.T) {
+	doc := `<metalink><file name="isos/distro..v2.iso"><url>https://example.com/a</url></file></metalink>`
+	files, err := ParseMetalink(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ParseMetalink failed: %v", err)
+	}
+	if files[0].Name != "distro..v2.iso" {
+		t.Errorf("Name = %q, want distro..v2.iso", files[0].Name)
+	}
+}
+
+func TestIsMetalinkName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"distro.meta4", true},
+		{"/tmp/Distro.METALINK", true},
+		{"https://example.com/distro.iso.meta4", true},
+		{"https://example.com/distro.iso.meta4?mirror=1", true},
+		{"https://example.com/distro.iso", false},
+		{"distro.iso", false},
+	}
+	for _, tt := range tests {
+		if got := IsMetalinkName(tt.in); got != tt.want {
+			t.Errorf("IsMetalinkName(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsMetalinkContentType(t *testing.T) {
+	if !IsMetalinkContentType("application/metalink4+xml; charset=utf-8") {
+		t.Error("metalink4 content type not recognised")
+	}
+	if IsMetalinkContentType("application/json") {
+		t.Error("json treated as metalink")
+	}
+}
@@ -2,11 +2,14 @@ package download
 
 import (
 	"context"
+	"errors"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
 	"github.com/surge-downloader/surge/internal/engine/state"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
@@ -14,15 +17,26 @@ import (
 
 // activeDownload tracks a download that's currently running
 type activeDownload struct {
-	config types.DownloadConfig
-	cancel context.CancelFunc
+	config  types.DownloadConfig
+	cancel  context.CancelFunc
+	started uint64 // Start order, to preempt the most recent first
 }
 
+// scheduleCheckInterval is how often the pool re-evaluates start times and active windows
+const scheduleCheckInterval = time.Second
+
 type WorkerPool struct {
-	taskChan     chan types.DownloadConfig
+	taskChan     chan struct{} // One token per queued download; workers take the head of queue
 	progressCh   chan<- any
 	downloads    map[string]*activeDownload      // Track active downloads for pause/resume
 	queued       map[string]types.DownloadConfig // Track queued downloads
+	queue        []string                        // Dispatch order of queued: priority, then insertion
+	scheduled    map[string]types.DownloadConfig // Downloads held until their start time / window opens
+	windowPaused map[string]bool                 // Downloads paused because their window closed (value: status persisted)
+	diskPaused   map[string]bool                 // Downloads paused because their disk ran low on space
+	preempted    map[string]bool                 // Downloads paused to make room, requeued once they settle
+	starts       uint64                          // Downloads started so far
+	windows      Windows                         // Global daily active windows (empty = always active)
 	mu           sync.RWMutex
 	wg           sync.WaitGroup // We use this to wait for all active downloads to pause before exiting the program
 	maxDownloads int
@@ -33,22 +47,198 @@ func NewWorkerPool(progressCh chan<- any, maxDownloads int) *WorkerPool {
 		maxDownloads = 3 // Default to 3 if invalid
 	}
 	pool := &WorkerPool{
-		taskChan:     make(chan types.DownloadConfig, 100), // We make it buffered to avoid blocking add
+		taskChan:     make(chan struct{}, 100), // We make it buffered to avoid blocking add
 		progressCh:   progressCh,
 		downloads:    make(map[string]*activeDownload),
 		queued:       make(map[string]types.DownloadConfig),
+		scheduled:    make(map[string]types.DownloadConfig),
+		windowPaused: make(map[string]bool),
+		diskPaused:   make(map[string]bool),
+		preempted:    make(map[string]bool),
 		maxDownloads: maxDownloads,
 	}
 	for i := 0; i < maxDownloads; i++ {
 		go pool.worker()
 	}
+	go pool.scheduler()
 	return pool
 }
 
-// Add adds a new download task to the pool
+// SetActiveWindows restricts all downloads to daily windows ("HH:MM-HH:MM").
+// Downloads may run while any window is open; no windows removes the
+// restriction. Running downloads are paused or resumed on the next check.
+func (p *WorkerPool) SetActiveWindows(specs []string) error {
+	windows, err := ParseWindows(specs)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.windows = windows
+	p.mu.Unlock()
+	return nil
+}
+
+// nextEligible returns the earliest time at or after now when cfg may run.
+// Caller must hold p.mu.
+func (p *WorkerPool) nextEligible(cfg types.DownloadConfig, now time.Time) time.Time {
+	t := now
+	if cfg.StartAt.After(t) {
+		t = cfg.StartAt
+	}
+
+	windows := []Windows{p.windows}
+	if cfg.Window != "" {
+		if w, err := ParseWindow(cfg.Window); err == nil {
+			windows = append(windows, Windows{w})
+		}
+	}
+
+	// Advance until the global and per-download windows are open at once (bounded in case they never overlap)
+	for i := 0; i < 8; i++ {
+		next := t
+		for _, w := range windows {
+			next = w.NextOpen(next)
+		}
+		if next.Equal(t) {
+			return t
+		}
+		t = next
+	}
+	return t
+}
+
+// inWindow reports whether cfg's active windows are open at now. Caller must hold p.mu.
+func (p *WorkerPool) inWindow(cfg types.DownloadConfig, now time.Time) bool {
+	if !p.windows.Contains(now) {
+		return false
+	}
+	if cfg.Window != "" {
+		if w, err := ParseWindow(cfg.Window); err == nil && !w.Contains(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// hold parks cfg in the scheduled set. Caller must hold p.mu.
+func (p *WorkerPool) hold(cfg types.DownloadConfig) {
+	delete(p.queued, cfg.ID)
+	p.scheduled[cfg.ID] = cfg
+}
+
+// saveHeaders stores the download's own request headers, encrypted, for resuming
+// it after a restart. Headers added from host policies or .netrc are looked up
+// again when it resumes.
+func saveHeaders(cfg types.DownloadConfig) {
+	if len(cfg.Headers) == 0 {
+		return
+	}
+	if err := state.SaveHeaders(cfg.ID, cfg.Headers); err != nil {
+		utils.Debug("Failed to persist headers of %s: %v", cfg.ID, err)
+	}
+}
+
+// announceScheduled persists cfg as "scheduled" so it survives restarts and notifies listeners
+func (p *WorkerPool) announceScheduled(cfg types.DownloadConfig, startAt time.Time) {
+	var downloaded, total, speedLimit int64
+	if cfg.State != nil {
+		downloaded = cfg.State.Downloaded.Load()
+		total = cfg.State.TotalSize
+		speedLimit = cfg.State.SpeedLimit.Limit()
+	}
+	var scheduledFor int64
+	if !cfg.StartAt.IsZero() {
+		scheduledFor = cfg.StartAt.Unix()
+	}
+	if err := state.AddToMasterList(types.DownloadEntry{
+		ID:         cfg.ID,
+		URL:        cfg.URL,
+		URLHash:    state.URLHash(cfg.URL),
+		DestPath:   cfg.DestPath,
+		Filename:   cfg.Filename,
+		Status:     "scheduled",
+		TotalSize:  total,
+		Downloaded: downloaded,
+		Mirrors:    cfg.Mirrors,
+		Checksum:   cfg.Checksum,
+		StartAt:    scheduledFor,
+		Window:     cfg.Window,
+		OutputDir:  cfg.OutputPath,
+		SpeedLimit: speedLimit,
+		Extract:    cfg.Extract,
+		Variant:    cfg.Variant,
+		Priority:   cfg.Priority,
+		Category:   cfg.Category,
+
+		ExpectedSize: cfg.ExpectedSize,
+	}); err != nil {
+		utils.Debug("Failed to persist scheduled download: %v", err)
+	}
+
+	saveHeaders(cfg)
+
+	if p.progressCh != nil {
+		p.progressCh <- events.DownloadScheduledMsg{
+			DownloadID: cfg.ID,
+			Filename:   cfg.Filename,
+			StartAt:    startAt,
+		}
+	}
+}
+
+// ScheduledAt returns when a held download will start, if it is currently scheduled
+func (p *WorkerPool) ScheduledAt(id string) (time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	cfg, ok := p.scheduled[id]
+	if !ok {
+		return time.Time{}, false
+	}
+	return p.nextEligible(cfg, time.Now()), true
+}
+
+// PauseReason returns why Surge paused a download on its own, or "" if it did not
+func (p *WorkerPool) PauseReason(id string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.diskPaused[id] {
+		return types.PauseReasonLowDisk
+	}
+	if p.preempted[id] {
+		return types.PauseReasonPreempted
+	}
+	return ""
+}
+
+// Add adds a new download task to the pool. Downloads run by priority, then in
+// the order they were added; one of high priority or above pauses a running
+// download of lower priority when no slot is free, and one whose category or host runs
+// its maximum number of downloads waits for one of them to stop. Downloads whose
+// start time or active window has not yet arrived are held until they become eligible.
 func (p *WorkerPool) Add(cfg types.DownloadConfig) {
+	p.add(cfg, time.Now())
+}
+
+// add queues cfg, or holds it if it is not yet eligible at now
+func (p *WorkerPool) add(cfg types.DownloadConfig, now time.Time) {
 	p.mu.Lock()
+	if next := p.nextEligible(cfg, now); next.After(now) {
+		p.hold(cfg)
+		p.mu.Unlock()
+		p.announceScheduled(cfg, next)
+		return
+	}
 	p.queued[cfg.ID] = cfg
+	p.enqueue(cfg.ID, cfg.Priority, false)
+	var victim string
+	if cfg.Priority >= PriorityHigh {
+		if victim = p.preemptFor(cfg.Priority, slices.Index(p.queue, cfg.ID)); victim != "" {
+			p.preempted[victim] = true
+		}
+	}
 	p.mu.Unlock()
 
 	if p.progressCh != nil && !cfg.IsResume {
@@ -58,7 +248,12 @@ func (p *WorkerPool) Add(cfg types.DownloadConfig) {
 		}
 	}
 
-	p.taskChan <- cfg
+	p.taskChan <- struct{}{}
+
+	if victim != "" {
+		utils.Debug("WorkerPool: Preempting %s for %s", victim, cfg.ID)
+		p.pause(victim, types.PauseReasonPreempted)
+	}
 }
 
 // HasDownload checks if a download with the given URL already exists
@@ -95,11 +290,46 @@ func (p *WorkerPool) ActiveCount() int {
 			count++
 		}
 	}
-	// Also count queued
-	count += len(p.queued)
+	// Also count queued, scheduled and window-paused downloads (they will run later)
+	count += len(p.queued) + len(p.scheduled) + len(p.windowPaused)
 	return count
 }
 
+// StateCounts holds how many downloads the pool has in each state
+type StateCounts struct {
+	Active    int // Running, or pausing
+	Queued    int // Waiting for a free slot
+	Scheduled int // Held until their start time or active window
+	Paused    int
+}
+
+// StateCounts counts the pool's downloads by state
+func (p *WorkerPool) StateCounts() StateCounts {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	counts := StateCounts{Queued: len(p.queued), Scheduled: len(p.scheduled)}
+	for id, ad := range p.downloads {
+		st := ad.config.State
+		if st == nil || st.Done.Load() {
+			continue
+		}
+		if _, requeued := p.queued[id]; requeued {
+			continue // Resumed and counted as queued
+		}
+		_, windowHeld := p.windowPaused[id]
+		switch {
+		case windowHeld:
+			counts.Scheduled++
+		case st.IsPaused() && !st.IsPausing():
+			counts.Paused++
+		default:
+			counts.Active++
+		}
+	}
+	return counts
+}
+
 // GetAll returns all active download configs (for listing)
 func (p *WorkerPool) GetAll() []types.DownloadConfig {
 	p.mu.RLock()
@@ -109,7 +339,13 @@ func (p *WorkerPool) GetAll() []types.DownloadConfig {
 	for _, ad := range p.downloads {
 		configs = append(configs, ad.config)
 	}
-	for _, cfg := range p.queued {
+	// Queued downloads in the order they will start
+	for _, id := range p.queue {
+		if cfg, ok := p.queued[id]; ok {
+			configs = append(configs, cfg)
+		}
+	}
+	for _, cfg := range p.scheduled {
 		configs = append(configs, cfg)
 	}
 	return configs
@@ -117,6 +353,11 @@ func (p *WorkerPool) GetAll() []types.DownloadConfig {
 
 // Pause pauses a specific download by ID. Returns true if found and pause initiated (or already paused), false otherwise.
 func (p *WorkerPool) Pause(downloadID string) bool {
+	return p.pause(downloadID, "")
+}
+
+// pause pauses a download, telling listeners why when Surge rather than the user asked
+func (p *WorkerPool) pause(downloadID, reason string) bool {
 	p.mu.RLock()
 	ad, exists := p.downloads[downloadID]
 	p.mu.RUnlock()
@@ -145,6 +386,7 @@ func (p *WorkerPool) Pause(downloadID string) bool {
 			DownloadID: downloadID,
 			Filename:   ad.config.Filename,
 			Downloaded: downloaded,
+			Reason:     reason,
 		}
 	}
 	return true
@@ -152,6 +394,10 @@ func (p *WorkerPool) Pause(downloadID string) bool {
 
 // PauseAll pauses all active downloads (for graceful shutdown)
 func (p *WorkerPool) PauseAll() {
+	p.mu.Lock()
+	clear(p.preempted) // Shutting down: nothing is requeued
+	p.mu.Unlock()
+
 	p.mu.RLock()
 	ids := make([]string, 0, len(p.downloads)) // This stores the uuids of the downloads to be paused
 	for id, ad := range p.downloads {
@@ -174,8 +420,25 @@ func (p *WorkerPool) Cancel(downloadID string) {
 	if exists {
 		delete(p.downloads, downloadID)
 	}
+	delete(p.queued, downloadID)
+	p.dequeue(downloadID)
+	sCfg, wasScheduled := p.scheduled[downloadID]
+	delete(p.scheduled, downloadID)
+	delete(p.windowPaused, downloadID)
+	delete(p.diskPaused, downloadID)
+	delete(p.preempted, downloadID)
 	p.mu.Unlock()
 
+	if wasScheduled && !exists {
+		if p.progressCh != nil {
+			p.progressCh <- events.DownloadRemovedMsg{
+				DownloadID: downloadID,
+				Filename:   sCfg.Filename,
+			}
+		}
+		return
+	}
+
 	if !exists || ad == nil {
 		return
 	}
@@ -190,10 +453,10 @@ func (p *WorkerPool) Cancel(downloadID string) {
 		ad.config.State.Done.Store(true)
 	}
 
-	// Best-effort cleanup of active partial file.
+	// Best-effort cleanup of active partial file (a directory for multi-file torrents).
 	// This handles cancels before paused state is persisted in DB.
 	if ad.config.State != nil && ad.config.State.DestPath != "" {
-		_ = os.Remove(ad.config.State.DestPath + types.IncompleteSuffix)
+		_ = os.RemoveAll(ad.config.State.DestPath + types.IncompleteSuffix)
 	}
 
 	// Send removal message
@@ -247,23 +510,155 @@ func (p *WorkerPool) Resume(downloadID string) bool {
 	return true
 }
 
+// SetSpeedLimit changes the bandwidth cap of a running, queued or scheduled download
+// (0 = unlimited). Running workers pick up the new limit immediately. Returns false
+// if the pool does not hold the download.
+func (p *WorkerPool) SetSpeedLimit(downloadID string, bytesPerSec int64) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var cfg types.DownloadConfig
+	if ad, ok := p.downloads[downloadID]; ok && ad != nil {
+		cfg = ad.config
+	} else if qCfg, ok := p.queued[downloadID]; ok {
+		cfg = qCfg
+	} else if sCfg, ok := p.scheduled[downloadID]; ok {
+		cfg = sCfg
+	} else {
+		return false
+	}
+
+	if cfg.State == nil {
+		return false
+	}
+	cfg.State.SpeedLimit.SetLimit(bytesPerSec)
+	return true
+}
+
+// scheduler periodically re-evaluates start times and active windows
+func (p *WorkerPool) scheduler() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		p.checkSchedule(now)
+		p.checkDiskSpace(now)
+	}
+}
+
+// checkSchedule starts held downloads that have become eligible, pauses running
+// downloads whose window has closed and resumes them once it reopens
+func (p *WorkerPool) checkSchedule(now time.Time) {
+	var ready, settled []types.DownloadConfig
+	var toPause, toResume []string
+
+	p.mu.Lock()
+	for id, cfg := range p.scheduled {
+		if !p.nextEligible(cfg, now).After(now) {
+			delete(p.scheduled, id)
+			ready = append(ready, cfg)
+		}
+	}
+	for id, ad := range p.downloads {
+		st := ad.config.State
+		if st == nil || st.Done.Load() || st.IsPausing() {
+			continue
+		}
+		if persisted, ok := p.windowPaused[id]; ok {
+			if p.inWindow(ad.config, now) {
+				delete(p.windowPaused, id)
+				toResume = append(toResume, id)
+			} else if !persisted && st.IsPaused() {
+				p.windowPaused[id] = true
+				settled = append(settled, ad.config)
+			}
+			continue
+		}
+		if !st.IsPaused() && !p.inWindow(ad.config, now) {
+			p.windowPaused[id] = false
+			toPause = append(toPause, id)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, cfg := range ready {
+		utils.Debug("WorkerPool: Starting scheduled download %s", cfg.ID)
+		p.add(cfg, now)
+	}
+	for _, id := range toPause {
+		utils.Debug("WorkerPool: Active window closed, pausing %s", id)
+		p.Pause(id)
+	}
+	for _, cfg := range settled {
+		// Pausing saved the state as "paused"; mark it scheduled so it is restored on restart
+		if err := state.UpdateStatus(cfg.ID, "scheduled"); err != nil {
+			utils.Debug("Failed to mark %s as scheduled: %v", cfg.ID, err)
+		}
+		if p.progressCh != nil {
+			p.mu.RLock()
+			next := p.nextEligible(cfg, now)
+			p.mu.RUnlock()
+			p.progressCh <- events.DownloadScheduledMsg{
+				DownloadID: cfg.ID,
+				Filename:   cfg.Filename,
+				StartAt:    next,
+			}
+		}
+	}
+	for _, id := range toResume {
+		utils.Debug("WorkerPool: Active window opened, resuming %s", id)
+		p.Resume(id)
+	}
+}
+
 func (p *WorkerPool) worker() {
-	for cfg := range p.taskChan {
+	for range p.taskChan {
+		now := time.Now()
+		p.mu.Lock()
+		cfg, ok := p.next()
+		if !ok {
+			p.mu.Unlock()
+			continue
+		}
+		// The window may have closed while the download sat in the queue
+		if next := p.nextEligible(cfg, now); next.After(now) {
+			p.hold(cfg)
+			p.mu.Unlock()
+			p.announceScheduled(cfg, next)
+			continue
+		}
+
 		p.wg.Add(1)
 		// Create cancellable context
 		ctx, cancel := context.WithCancel(context.Background())
 
 		// Register active download
+		p.starts++
 		ad := &activeDownload{
-			config: cfg,
-			cancel: cancel,
+			config:  cfg,
+			cancel:  cancel,
+			started: p.starts,
 		}
-		p.mu.Lock()
 		delete(p.queued, cfg.ID)
 		p.downloads[cfg.ID] = ad
 		p.mu.Unlock()
 
-		err := TUIDownload(ctx, &ad.config)
+		err := runDownload(ctx, &ad.config, func(name string, runtime *types.RuntimeConfig, outputPath string) bool {
+			return p.setCategory(ad, name, runtime, outputPath)
+		})
+		if errors.Is(err, errCategoryFull) {
+			// Nothing was downloaded yet: wait in the queue for the category to have room
+			utils.Debug("WorkerPool: Category of %s is full, queueing it again", cfg.ID)
+			cancel()
+			p.mu.Lock()
+			delete(p.downloads, cfg.ID)
+			p.queued[cfg.ID] = ad.config
+			p.enqueue(cfg.ID, cfg.Priority, true)
+			p.mu.Unlock()
+			p.wake() // The category may have freed up in the meantime
+			p.wg.Done()
+			continue
+		}
 
 		// Logic:
 		// 1. If Pause() was called: State.IsPaused() is true. We keep the task in p.downloads (so it can be resumed).
@@ -276,10 +671,36 @@ func (p *WorkerPool) worker() {
 			ad.config.State.SetPausing(false)
 		}
 
+		p.mu.Lock()
+		preempted := isPaused && p.preempted[cfg.ID]
+		delete(p.preempted, cfg.ID)
+		p.mu.Unlock()
+
 		if isPaused {
 			utils.Debug("WorkerPool: Download %s paused cleanly", cfg.ID)
+			// Pausing saved the state without its priority, category and expected size
+			if cfg.Priority != PriorityNormal {
+				if err := state.UpdatePriority(cfg.ID, cfg.Priority); err != nil {
+					utils.Debug("Failed to persist priority of %s: %v", cfg.ID, err)
+				}
+			}
+			if ad.config.Category != "" {
+				if err := state.UpdateCategory(cfg.ID, ad.config.Category); err != nil {
+					utils.Debug("Failed to persist category of %s: %v", cfg.ID, err)
+				}
+			}
+			if cfg.ExpectedSize > 0 {
+				if err := state.UpdateExpectedSize(cfg.ID, cfg.ExpectedSize); err != nil {
+					utils.Debug("Failed to persist expected size of %s: %v", cfg.ID, err)
+				}
+			}
+			saveHeaders(cfg)
+			if preempted {
+				p.requeue(ad.config)
+			}
 			// If paused, we keep it in downloads map for potential resume
 		} else if err != nil {
+			metrics.DownloadsFailed.Inc()
 			if cfg.State != nil {
 				cfg.State.SetError(err)
 			}
@@ -294,20 +715,34 @@ func (p *WorkerPool) worker() {
 			p.mu.Lock()
 			delete(p.downloads, cfg.ID)
 			p.mu.Unlock()
+			saveHeaders(cfg)
 
 		} else if !isPaused {
 			// Only mark as done if not paused
+			metrics.DownloadsCompleted.Inc()
 			if cfg.State != nil {
 				cfg.State.Done.Store(true)
 			}
 			// Note: DownloadCompleteMsg is sent by the progress reporter when it detects Done=true
 
+			// Credentials are only kept while they may still be needed
+			if len(cfg.Headers) > 0 {
+				if err := state.SaveHeaders(cfg.ID, nil); err != nil {
+					utils.Debug("Failed to clear headers of %s: %v", cfg.ID, err)
+				}
+			}
+
 			// Clean up from tracking
 			p.mu.Lock()
 			delete(p.downloads, cfg.ID)
 			p.mu.Unlock()
 		}
 		// If paused, we keep it in downloads map for potential resume
+
+		// Its category or host may have been holding back queued downloads
+		if ad.config.Category != "" || hostPolicy(ad.config) != nil {
+			p.wake()
+		}
 		p.wg.Done()
 	}
 }
@@ -317,21 +752,51 @@ func (p *WorkerPool) GetStatus(id string) *types.DownloadStatus {
 	p.mu.RLock()
 	ad, exists := p.downloads[id]
 	qCfg, qExists := p.queued[id]
+	sCfg, sExists := p.scheduled[id]
 	p.mu.RUnlock()
 
+	if sExists {
+		startAt, _ := p.ScheduledAt(id)
+		status := &types.DownloadStatus{
+			ID:          id,
+			URL:         sCfg.URL,
+			Filename:    sCfg.Filename,
+			DestPath:    sCfg.DestPath,
+			Status:      "scheduled",
+			ScheduledAt: startAt.Unix(),
+			Priority:    sCfg.Priority,
+			Category:    sCfg.Category,
+		}
+		if sCfg.State != nil {
+			status.SpeedLimit = sCfg.State.SpeedLimit.Limit()
+			status.TotalSize = sCfg.State.TotalSize
+			status.Downloaded = sCfg.State.Downloaded.Load()
+			if status.TotalSize > 0 {
+				status.Progress = float64(status.Downloaded) * 100 / float64(status.TotalSize)
+			}
+		}
+		return status
+	}
+
 	if !exists && !qExists {
 		return nil
 	}
 
 	if qExists {
-		return &types.DownloadStatus{
+		status := &types.DownloadStatus{
 			ID:         id,
 			URL:        qCfg.URL,
 			Filename:   qCfg.Filename,
 			Status:     "queued",
 			Downloaded: 0,
 			TotalSize:  0, // Metadata not yet fetched
+			Priority:   qCfg.Priority,
+			Category:   qCfg.Category,
+		}
+		if qCfg.State != nil {
+			status.SpeedLimit = qCfg.State.SpeedLimit.Limit()
 		}
+		return status
 	}
 
 	state := ad.config.State
@@ -346,18 +811,25 @@ func (p *WorkerPool) GetStatus(id string) *types.DownloadStatus {
 		TotalSize:  state.TotalSize,
 		Downloaded: state.Downloaded.Load(),
 		Status:     "downloading",
+		SpeedLimit: state.SpeedLimit.Limit(),
+		Priority:   ad.config.Priority,
+		Category:   ad.config.Category,
 	}
 
 	if ad.config.State.IsPausing() {
 		status.Status = "pausing"
 	} else if ad.config.State.IsPaused() {
 		status.Status = "paused"
+		status.PauseReason = p.PauseReason(id)
 	} else if state.Done.Load() {
 		status.Status = "completed"
 	}
 
 	if err := state.GetError(); err != nil {
 		status.Status = "error"
+		if errors.Is(err, types.ErrChecksumMismatch) {
+			status.Status = "verify_failed"
+		}
 		status.Error = err.Error()
 	}
 
@@ -374,6 +846,8 @@ func (p *WorkerPool) GetStatus(id string) *types.DownloadStatus {
 		status.Speed = bytesPerSec / (1024 * 1024)
 	}
 
+	status.Mirrors = state.GetMirrors()
+
 	return status
 }
 
@@ -104,3 +104,34 @@ func TestWorkerPool_GetStatus_Completed(t *testing.T) {
 		t.Errorf("Expected status 'completed', got '%s'", status.Status)
 	}
 }
+
+func TestWorkerPool_StateCounts(t *testing.T) {
+	pool := NewWorkerPool(make(chan any, 10), 3)
+
+	add := func(id string, setup func(*types.ProgressState)) {
+		state := types.NewProgressState(id, 1000)
+		if setup != nil {
+			setup(state)
+		}
+		pool.downloads[id] = &activeDownload{config: types.DownloadConfig{ID: id, State: state}}
+	}
+
+	pool.mu.Lock()
+	add("active", nil)
+	add("pausing", func(s *types.ProgressState) { s.Pause(); s.SetPausing(true) })
+	add("paused", func(s *types.ProgressState) { s.Pause() })
+	add("done", func(s *types.ProgressState) { s.Done.Store(true) })
+	add("window", func(s *types.ProgressState) { s.Pause() })
+	pool.windowPaused["window"] = true
+	add("resumed", func(s *types.ProgressState) { s.Pause() })
+	pool.queued["resumed"] = types.DownloadConfig{ID: "resumed"}
+	pool.queued["waiting"] = types.DownloadConfig{ID: "waiting"}
+	pool.scheduled["later"] = types.DownloadConfig{ID: "later"}
+	pool.mu.Unlock()
+
+	got := pool.StateCounts()
+	want := StateCounts{Active: 2, Queued: 2, Scheduled: 2, Paused: 1}
+	if got != want {
+		t.Errorf("StateCounts() = %+v, want %+v", got, want)
+	}
+}
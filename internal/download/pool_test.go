@@ -782,3 +782,39 @@ func TestWorkerPool_PauseResume_Idempotency(t *testing.T) {
 		// OK
 	}
 }
+
+func TestWorkerPool_SetSpeedLimit(t *testing.T) {
+	ch := make(chan any, 10)
+	pool := NewWorkerPool(ch, 3)
+
+	active := types.NewProgressState("active-id", 1000)
+	queued := types.NewProgressState("queued-id", 0)
+
+	pool.mu.Lock()
+	pool.downloads["active-id"] = &activeDownload{
+		config: types.DownloadConfig{ID: "active-id", State: active},
+	}
+	pool.queued["queued-id"] = types.DownloadConfig{ID: "queued-id", State: queued}
+	pool.mu.Unlock()
+
+	if !pool.SetSpeedLimit("active-id", 1024*1024) {
+		t.Fatal("SetSpeedLimit should find the active download")
+	}
+	if got := active.SpeedLimit.Limit(); got != 1024*1024 {
+		t.Errorf("active limit = %d, want %d", got, 1024*1024)
+	}
+	if status := pool.GetStatus("active-id"); status == nil || status.SpeedLimit != 1024*1024 {
+		t.Errorf("GetStatus did not report the limit: %+v", status)
+	}
+
+	if !pool.SetSpeedLimit("queued-id", 512*1024) {
+		t.Fatal("SetSpeedLimit should find the queued download")
+	}
+	if got := queued.SpeedLimit.Limit(); got != 512*1024 {
+		t.Errorf("queued limit = %d, want %d", got, 512*1024)
+	}
+
+	if pool.SetSpeedLimit("missing-id", 1024) {
+		t.Error("SetSpeedLimit should report unknown downloads")
+	}
+}
@@ -0,0 +1,206 @@
+package download
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// Named priority levels. Any integer is a valid priority; higher runs first.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// Directions accepted by WorkerPool.Move
+const (
+	MoveTop  = "top"
+	MoveUp   = "up"
+	MoveDown = "down"
+)
+
+// ParsePriority parses "low", "normal", "high" or an integer. Empty means normal.
+func ParsePriority(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority %q: expected low, normal, high or a number", s)
+	}
+	return n, nil
+}
+
+// PriorityName returns the name of a priority level, or its number
+func PriorityName(priority int) string {
+	switch priority {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return strconv.Itoa(priority)
+}
+
+// enqueue places id in the dispatch queue after every download of the same or
+// higher priority, or with front set, ahead of those of the same priority.
+// Caller must hold p.mu.
+func (p *WorkerPool) enqueue(id string, priority int, front bool) {
+	p.dequeue(id)
+	i := 0
+	for ; i < len(p.queue); i++ {
+		other := p.queued[p.queue[i]].Priority
+		if other < priority || (front && other == priority) {
+			break
+		}
+	}
+	p.queue = slices.Insert(p.queue, i, id)
+}
+
+// dequeue removes id from the dispatch queue. Caller must hold p.mu.
+func (p *WorkerPool) dequeue(id string) {
+	if i := slices.Index(p.queue, id); i >= 0 {
+		p.queue = slices.Delete(p.queue, i, i+1)
+	}
+}
+
+// next pops the first download in the queue whose category and host have room for it.
+// Caller must hold p.mu.
+func (p *WorkerPool) next() (types.DownloadConfig, bool) {
+	for i := 0; i < len(p.queue); {
+		cfg, ok := p.queued[p.queue[i]]
+		if !ok {
+			p.queue = slices.Delete(p.queue, i, i+1)
+			continue
+		}
+		if p.categoryFull(cfg) || p.hostFull(cfg) {
+			i++
+			continue
+		}
+		p.queue = slices.Delete(p.queue, i, i+1)
+		return cfg, true
+	}
+	return types.DownloadConfig{}, false
+}
+
+// Move reorders a queued download: MoveTop puts it first, MoveUp and MoveDown
+// swap it with its neighbour. A download moved past one of another priority
+// takes on that priority, so the queue stays ordered by priority.
+func (p *WorkerPool) Move(downloadID, direction string) error {
+	p.mu.Lock()
+	i := slices.Index(p.queue, downloadID)
+	if i < 0 {
+		p.mu.Unlock()
+		return types.ErrNotQueued
+	}
+
+	j := i
+	switch direction {
+	case MoveTop:
+		j = 0
+	case MoveUp:
+		j = max(i-1, 0)
+	case MoveDown:
+		j = min(i+1, len(p.queue)-1)
+	default:
+		p.mu.Unlock()
+		return fmt.Errorf("invalid direction %q: expected top, up or down", direction)
+	}
+
+	cfg := p.queued[downloadID]
+	neighbour := p.queued[p.queue[j]].Priority
+	changed := (j < i && neighbour > cfg.Priority) || (j > i && neighbour < cfg.Priority)
+	if changed {
+		cfg.Priority = neighbour
+		p.queued[downloadID] = cfg
+	}
+	p.queue = slices.Delete(p.queue, i, i+1)
+	p.queue = slices.Insert(p.queue, j, downloadID)
+	p.mu.Unlock()
+
+	// Downloads that never started have no row yet; theirs is written when they pause
+	if changed {
+		if err := state.UpdatePriority(downloadID, cfg.Priority); err != nil {
+			utils.Debug("Priority of %s not persisted: %v", downloadID, err)
+		}
+	}
+	return nil
+}
+
+// preemptFor picks a running download to pause so that a newly queued one of
+// the given priority, at index in the queue, can start now. It returns "" when a
+// slot will free up anyway or nothing running has a lower priority. The most
+// recently started of the lowest-priority downloads loses the least work.
+// Caller must hold p.mu.
+func (p *WorkerPool) preemptFor(priority, index int) string {
+	var running []*activeDownload
+	for id, ad := range p.downloads {
+		st := ad.config.State
+		if st == nil || st.Done.Load() || st.IsPaused() {
+			continue
+		}
+		if _, requeued := p.queued[id]; requeued {
+			continue
+		}
+		running = append(running, ad)
+	}
+	if index < p.maxDownloads-len(running) {
+		return ""
+	}
+
+	var victim *activeDownload
+	for _, ad := range running {
+		if ad.config.Priority >= priority {
+			continue
+		}
+		if victim == nil || ad.config.Priority < victim.config.Priority ||
+			(ad.config.Priority == victim.config.Priority && ad.started > victim.started) {
+			victim = ad
+		}
+	}
+	if victim == nil {
+		return ""
+	}
+	return victim.config.ID
+}
+
+// requeue puts a preempted download back in the queue, ahead of the others of its priority
+func (p *WorkerPool) requeue(cfg types.DownloadConfig) {
+	if cfg.State != nil {
+		cfg.State.Resume()
+		cfg.State.SyncSessionStart()
+	}
+	cfg.IsResume = true
+
+	p.mu.Lock()
+	p.queued[cfg.ID] = cfg
+	p.enqueue(cfg.ID, cfg.Priority, true)
+	p.mu.Unlock()
+
+	// Pausing saved the state as "paused"; a restart should queue it again
+	if err := state.UpdateStatus(cfg.ID, "queued"); err != nil {
+		utils.Debug("Failed to mark %s as queued: %v", cfg.ID, err)
+	}
+	if p.progressCh != nil {
+		p.progressCh <- events.DownloadQueuedMsg{
+			DownloadID: cfg.ID,
+			Filename:   cfg.Filename,
+		}
+	}
+	p.taskChan <- struct{}{}
+}
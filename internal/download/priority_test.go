@@ -0,0 +1,224 @@
+package download
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"", PriorityNormal, false},
+		{"normal", PriorityNormal, false},
+		{"HIGH", PriorityHigh, false},
+		{" low ", PriorityLow, false},
+		{"5", 5, false},
+		{"-3", -3, false},
+		{"urgent", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePriority(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePriority(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePriority(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+
+	for _, p := range []int{PriorityLow, PriorityNormal, PriorityHigh, 7} {
+		if got, err := ParsePriority(PriorityName(p)); err != nil || got != p {
+			t.Errorf("ParsePriority(PriorityName(%d)) = %d, %v", p, got, err)
+		}
+	}
+}
+
+// queuedIDs returns the pool's queued downloads in dispatch order
+func queuedIDs(p *WorkerPool) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Clone(p.queue)
+}
+
+func TestWorkerPool_Add_OrdersByPriority(t *testing.T) {
+	pool := newSchedulingPool(make(chan any, 10))
+
+	pool.Add(types.DownloadConfig{ID: "a"})
+	pool.Add(types.DownloadConfig{ID: "b", Priority: PriorityLow})
+	pool.Add(types.DownloadConfig{ID: "c", Priority: PriorityHigh})
+	pool.Add(types.DownloadConfig{ID: "d"})
+	pool.Add(types.DownloadConfig{ID: "e", Priority: PriorityHigh})
+
+	want := []string{"c", "e", "a", "d", "b"}
+	if got := queuedIDs(pool); !slices.Equal(got, want) {
+		t.Errorf("queue = %v, want %v", got, want)
+	}
+	var listed []string
+	for _, cfg := range pool.GetAll() {
+		listed = append(listed, cfg.ID)
+	}
+	if !slices.Equal(listed, want) {
+		t.Errorf("GetAll = %v, want %v", listed, want)
+	}
+
+	// Workers take the head of the queue
+	pool.mu.Lock()
+	cfg, ok := pool.next()
+	pool.mu.Unlock()
+	if !ok || cfg.ID != "c" {
+		t.Errorf("next = %q, want c", cfg.ID)
+	}
+}
+
+func TestWorkerPool_Move(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		direction string
+		want      []string
+		priority  int // Of the moved download afterwards
+	}{
+		{"Up within priority", "n2", MoveUp, []string{"h1", "n2", "n1", "l1"}, PriorityNormal},
+		{"Down within priority", "n1", MoveDown, []string{"h1", "n2", "n1", "l1"}, PriorityNormal},
+		{"Up past higher priority", "n1", MoveUp, []string{"n1", "h1", "n2", "l1"}, PriorityHigh},
+		{"Down past lower priority", "n2", MoveDown, []string{"h1", "n1", "l1", "n2"}, PriorityLow},
+		{"Top", "l1", MoveTop, []string{"l1", "h1", "n1", "n2"}, PriorityHigh},
+		{"Top when first", "h1", MoveTop, []string{"h1", "n1", "n2", "l1"}, PriorityHigh},
+		{"Down when last", "l1", MoveDown, []string{"h1", "n1", "n2", "l1"}, PriorityLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newSchedulingPool(make(chan any, 10))
+			pool.Add(types.DownloadConfig{ID: "n1"})
+			pool.Add(types.DownloadConfig{ID: "n2"})
+			pool.Add(types.DownloadConfig{ID: "l1", Priority: PriorityLow})
+			pool.Add(types.DownloadConfig{ID: "h1", Priority: PriorityHigh})
+
+			if err := pool.Move(tt.id, tt.direction); err != nil {
+				t.Fatalf("Move failed: %v", err)
+			}
+			if got := queuedIDs(pool); !slices.Equal(got, tt.want) {
+				t.Errorf("queue = %v, want %v", got, tt.want)
+			}
+			if got := pool.queued[tt.id].Priority; got != tt.priority {
+				t.Errorf("priority = %d, want %d", got, tt.priority)
+			}
+		})
+	}
+}
+
+func TestWorkerPool_Move_Errors(t *testing.T) {
+	pool := newSchedulingPool(make(chan any, 10))
+	pool.Add(types.DownloadConfig{ID: "queued"})
+	pool.downloads["running"] = &activeDownload{
+		config: types.DownloadConfig{ID: "running", State: types.NewProgressState("running", 1000)},
+		cancel: func() {},
+	}
+
+	if err := pool.Move("running", MoveTop); !errors.Is(err, types.ErrNotQueued) {
+		t.Errorf("Move(running) = %v, want ErrNotQueued", err)
+	}
+	if err := pool.Move("queued", "sideways"); err == nil {
+		t.Error("Move should reject an unknown direction")
+	}
+
+	// A cancelled download leaves the queue
+	pool.Cancel("queued")
+	if err := pool.Move("queued", MoveTop); !errors.Is(err, types.ErrNotQueued) {
+		t.Errorf("Move(cancelled) = %v, want ErrNotQueued", err)
+	}
+	pool.mu.Lock()
+	_, ok := pool.next()
+	pool.mu.Unlock()
+	if ok {
+		t.Error("cancelled download was dispatched")
+	}
+}
+
+// runningPool returns a pool whose slots are all taken by the given running downloads
+func runningPool(ch chan any, running ...types.DownloadConfig) *WorkerPool {
+	pool := newSchedulingPool(ch)
+	pool.maxDownloads = len(running)
+	for _, cfg := range running {
+		cfg.State = types.NewProgressState(cfg.ID, 1000)
+		pool.starts++
+		pool.downloads[cfg.ID] = &activeDownload{config: cfg, cancel: func() {}, started: pool.starts}
+	}
+	return pool
+}
+
+func TestWorkerPool_Preempt(t *testing.T) {
+	ch := make(chan any, 20)
+	pool := runningPool(ch,
+		types.DownloadConfig{ID: "low", Priority: PriorityLow},
+		types.DownloadConfig{ID: "older"},
+		types.DownloadConfig{ID: "newer"},
+	)
+
+	// A normal download waits for a free slot
+	pool.Add(types.DownloadConfig{ID: "normal"})
+	for _, id := range []string{"low", "older", "newer"} {
+		if pool.downloads[id].config.State.IsPaused() {
+			t.Fatalf("%s paused for a normal-priority download", id)
+		}
+	}
+
+	// A high one pauses the lowest-priority download, then the most recently started
+	pool.Add(types.DownloadConfig{ID: "urgent", Priority: PriorityHigh})
+	pool.Add(types.DownloadConfig{ID: "urgent2", Priority: PriorityHigh})
+	if !pool.downloads["low"].config.State.IsPaused() || !pool.downloads["newer"].config.State.IsPaused() {
+		t.Fatal("expected low and newer to be preempted")
+	}
+	if pool.downloads["older"].config.State.IsPaused() {
+		t.Error("older should keep running")
+	}
+	if got := pool.PauseReason("newer"); got != types.PauseReasonPreempted {
+		t.Errorf("PauseReason = %q, want %q", got, types.PauseReasonPreempted)
+	}
+
+	var reasons []string
+	for len(ch) > 0 {
+		if msg, ok := (<-ch).(events.DownloadPausedMsg); ok {
+			reasons = append(reasons, msg.Reason)
+		}
+	}
+	if len(reasons) != 2 || reasons[0] != types.PauseReasonPreempted {
+		t.Errorf("pause reasons = %v", reasons)
+	}
+
+	// The slots being freed go to the first two; a third needs one more
+	pool.Add(types.DownloadConfig{ID: "urgent3", Priority: PriorityHigh})
+	if !pool.downloads["older"].config.State.IsPaused() {
+		t.Error("expected older to be preempted for a third high download")
+	}
+
+	// Once it has paused, a preempted download goes back ahead of its peers
+	pool.requeue(pool.downloads["newer"].config)
+	want := []string{"urgent", "urgent2", "urgent3", "newer", "normal"}
+	if got := queuedIDs(pool); !slices.Equal(got, want) {
+		t.Errorf("queue = %v, want %v", got, want)
+	}
+	if pool.downloads["newer"].config.State.IsPaused() {
+		t.Error("requeued download is still paused")
+	}
+}
+
+func TestWorkerPool_Preempt_NotWhenSlotFree(t *testing.T) {
+	pool := runningPool(make(chan any, 10), types.DownloadConfig{ID: "a"}, types.DownloadConfig{ID: "b"})
+	pool.maxDownloads = 3
+
+	pool.Add(types.DownloadConfig{ID: "urgent", Priority: PriorityHigh})
+	for _, id := range []string{"a", "b"} {
+		if pool.downloads[id].config.State.IsPaused() {
+			t.Errorf("%s preempted although a slot was free", id)
+		}
+	}
+}
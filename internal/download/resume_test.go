@@ -1,10 +1,15 @@
 package download_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -170,3 +175,84 @@ func TestIntegration_PauseResume(t *testing.T) {
 		t.Error("State should be deleted after completion")
 	}
 }
+
+func TestIntegration_ResumeVerifyFailed(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-verify")
+
+	good := bytes.Repeat([]byte("surge"), 400*1024)
+	bad := bytes.Repeat([]byte("SURGE"), 400*1024)
+	sum := sha256.Sum256(good)
+	var corrupt atomic.Bool
+	corrupt.Store(true)
+	server := testutil.NewHTTPServerT(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := good
+		if corrupt.Load() {
+			data = bad
+		}
+		http.ServeContent(w, r, "release.bin", time.Unix(1700000000, 0), bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	id := uuid.New().String()
+	cfg := types.DownloadConfig{
+		URL:        server.URL + "/release.bin",
+		OutputPath: tmpDir,
+		ID:         id,
+		ProgressCh: make(chan any, 100),
+		State:      types.NewProgressState(id, 0),
+		Runtime:    &types.RuntimeConfig{},
+		Checksum:   "sha256:" + hex.EncodeToString(sum[:]),
+	}
+	// Tasks left over from an earlier pause must not survive the failure either
+	if err := state.SaveState(cfg.URL, filepath.Join(tmpDir, "release.bin"), &types.DownloadState{
+		ID:        id,
+		URL:       cfg.URL,
+		DestPath:  filepath.Join(tmpDir, "release.bin"),
+		Filename:  "release.bin",
+		TotalSize: int64(len(good)),
+		Tasks:     []types.Task{{Offset: int64(len(good)) - 1024, Length: 1024}},
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	if err := download.TUIDownload(ctx, &cfg); !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Fatalf("TUIDownload error = %v, want ErrChecksumMismatch", err)
+	}
+
+	entry, err := state.GetDownload(id)
+	if err != nil || entry == nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if entry.Status != "verify_failed" || entry.Downloaded != 0 {
+		t.Errorf("entry status = %q, downloaded = %d; want verify_failed, 0", entry.Status, entry.Downloaded)
+	}
+	if testutil.FileExists(entry.DestPath + types.IncompleteSuffix) {
+		t.Error("corrupt working file was kept")
+	}
+
+	// Retry the way a cold resume does, now that the server sends the right bytes
+	corrupt.Store(false)
+	saved, _ := state.LoadState(entry.URL, entry.DestPath)
+	if saved != nil && len(saved.Tasks) > 0 {
+		t.Errorf("saved state still has %d tasks", len(saved.Tasks))
+	}
+	retry := types.DownloadConfig{
+		URL:        entry.URL,
+		OutputPath: tmpDir,
+		DestPath:   entry.DestPath,
+		ID:         id,
+		Filename:   entry.Filename,
+		IsResume:   true,
+		ProgressCh: make(chan any, 100),
+		State:      types.NewProgressState(id, entry.TotalSize),
+		SavedState: saved,
+		Runtime:    &types.RuntimeConfig{},
+		Checksum:   entry.Checksum,
+	}
+	if err := download.TUIDownload(ctx, &retry); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	testutil.VerifyDownloadT(t, entry.DestPath, good)
+}
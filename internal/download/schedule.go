@@ -0,0 +1,160 @@
+package download
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time range during which downloads are allowed to run.
+// Start and End are minutes since local midnight. A window whose End is
+// before its Start wraps past midnight (e.g. 23:00-06:00).
+type Window struct {
+	Start int
+	End   int
+}
+
+// ParseWindow parses a window spec of the form "HH:MM-HH:MM"
+func ParseWindow(spec string) (Window, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", spec)
+	}
+
+	start, err := parseClock(startStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	end, err := parseClock(endStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("invalid window %q: start and end are equal", spec)
+	}
+
+	return Window{Start: start, End: end}, nil
+}
+
+// ParseWindows parses a list of window specs. Blank entries are skipped.
+func ParseWindows(specs []string) (Windows, error) {
+	var windows Windows
+	for _, spec := range specs {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		w, err := ParseWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", strings.TrimSpace(s))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// String returns the window in "HH:MM-HH:MM" form
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// Contains reports whether t falls inside the window
+func (w Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	// Wraps past midnight
+	return m >= w.Start || m < w.End
+}
+
+// NextOpen returns the earliest time at or after t when the window is open
+func (w Window) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	open := time.Date(t.Year(), t.Month(), t.Day(), w.Start/60, w.Start%60, 0, 0, t.Location())
+	if !open.After(t) {
+		open = open.AddDate(0, 0, 1)
+	}
+	return open
+}
+
+// Windows is a set of daily windows; downloads may run while any of them is
+// open. An empty set is always open.
+type Windows []Window
+
+// Contains reports whether t falls inside any of the windows
+func (ws Windows) Contains(t time.Time) bool {
+	if len(ws) == 0 {
+		return true
+	}
+	for _, w := range ws {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the earliest time at or after t when any window is open
+func (ws Windows) NextOpen(t time.Time) time.Time {
+	if ws.Contains(t) {
+		return t
+	}
+	next := ws[0].NextOpen(t)
+	for _, w := range ws[1:] {
+		if open := w.NextOpen(t); open.Before(next) {
+			next = open
+		}
+	}
+	return next
+}
+
+// ParseStartAt parses a start time for a scheduled download. Accepted forms:
+//   - "HH:MM"            next occurrence of that local time
+//   - "+1h30m"           relative to now
+//   - "2006-01-02 15:04" local date and time
+//   - RFC 3339
+func ParseStartAt(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s[1:])
+		if err != nil || d < 0 {
+			return time.Time{}, fmt.Errorf("invalid start time %q", s)
+		}
+		return now.Add(d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	if m, err := parseClock(s); err == nil {
+		t := time.Date(now.Year(), now.Month(), now.Day(), m/60, m%60, 0, 0, now.Location())
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid start time %q: use HH:MM, +duration, \"YYYY-MM-DD HH:MM\" or RFC 3339", s)
+}
@@ -0,0 +1,303 @@
+package download
+
+import (
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/events"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Window
+		wantErr bool
+	}{
+		{"01:00-07:00", Window{Start: 60, End: 420}, false},
+		{" 23:30 - 06:15 ", Window{Start: 1410, End: 375}, false},
+		{"00:00-23:59", Window{Start: 0, End: 1439}, false},
+		{"01:00", Window{}, true},
+		{"01:00-01:00", Window{}, true},
+		{"25:00-07:00", Window{}, true},
+		{"night", Window{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseWindow(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindow(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseWindow(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow_ContainsAndNextOpen(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, 3, 10, h, m, 0, 0, time.UTC) }
+
+	day := Window{Start: 60, End: 420}     // 01:00-07:00
+	night := Window{Start: 1380, End: 360} // 23:00-06:00, wraps midnight
+
+	tests := []struct {
+		name     string
+		w        Window
+		t        time.Time
+		contains bool
+		nextOpen time.Time
+	}{
+		{"inside", day, at(3, 0), true, at(3, 0)},
+		{"start is inclusive", day, at(1, 0), true, at(1, 0)},
+		{"end is exclusive", day, at(7, 0), false, at(1, 0).AddDate(0, 0, 1)},
+		{"before start", day, at(0, 30), false, at(1, 0)},
+		{"wrapped late", night, at(23, 30), true, at(23, 30)},
+		{"wrapped early", night, at(5, 59), true, at(5, 59)},
+		{"wrapped closed", night, at(12, 0), false, at(23, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.w.Contains(tt.t); got != tt.contains {
+				t.Errorf("Contains(%v) = %v, want %v", tt.t, got, tt.contains)
+			}
+			if got := tt.w.NextOpen(tt.t); !got.Equal(tt.nextOpen) {
+				t.Errorf("NextOpen(%v) = %v, want %v", tt.t, got, tt.nextOpen)
+			}
+		})
+	}
+}
+
+func TestWindows_ContainsAndNextOpen(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, 3, 10, h, m, 0, 0, time.UTC) }
+
+	windows, err := ParseWindows([]string{"01:00-03:00", " ", "22:00-23:00", "12:00-13:00"})
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+	if len(windows) != 3 {
+		t.Fatalf("ParseWindows returned %d windows, want 3", len(windows))
+	}
+
+	tests := []struct {
+		name     string
+		ws       Windows
+		t        time.Time
+		contains bool
+		nextOpen time.Time
+	}{
+		{"inside first", windows, at(2, 0), true, at(2, 0)},
+		{"inside last", windows, at(12, 30), true, at(12, 30)},
+		{"between picks the nearest", windows, at(5, 0), false, at(12, 0)},
+		{"after all wraps to tomorrow", windows, at(23, 30), false, at(1, 0).AddDate(0, 0, 1)},
+		{"empty is always open", nil, at(5, 0), true, at(5, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ws.Contains(tt.t); got != tt.contains {
+				t.Errorf("Contains(%v) = %v, want %v", tt.t, got, tt.contains)
+			}
+			if got := tt.ws.NextOpen(tt.t); !got.Equal(tt.nextOpen) {
+				t.Errorf("NextOpen(%v) = %v, want %v", tt.t, got, tt.nextOpen)
+			}
+		})
+	}
+
+	if _, err := ParseWindows([]string{"01:00-03:00", "noon"}); err == nil {
+		t.Error("expected error for an invalid window in the list")
+	}
+}
+
+func TestParseStartAt(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"13:30", time.Date(2025, 3, 10, 13, 30, 0, 0, time.UTC), false},
+		{"09:00", time.Date(2025, 3, 11, 9, 0, 0, 0, time.UTC), false},
+		{"+90m", now.Add(90 * time.Minute), false},
+		{"2025-04-01 02:00", time.Date(2025, 4, 1, 2, 0, 0, 0, time.UTC), false},
+		{"2025-04-01T02:00:00Z", time.Date(2025, 4, 1, 2, 0, 0, 0, time.UTC), false},
+		{"+-5m", time.Time{}, true},
+		{"tomorrow", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseStartAt(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStartAt(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseStartAt(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// newSchedulingPool builds a pool without workers so queued tasks stay observable
+func newSchedulingPool(ch chan any) *WorkerPool {
+	return &WorkerPool{
+		taskChan:     make(chan struct{}, 10),
+		progressCh:   ch,
+		downloads:    make(map[string]*activeDownload),
+		queued:       make(map[string]types.DownloadConfig),
+		scheduled:    make(map[string]types.DownloadConfig),
+		windowPaused: make(map[string]bool),
+		diskPaused:   make(map[string]bool),
+		preempted:    make(map[string]bool),
+		maxDownloads: 1,
+	}
+}
+
+func TestWorkerPool_Add_HoldsUntilStartAt(t *testing.T) {
+	ch := make(chan any, 10)
+	pool := newSchedulingPool(ch)
+
+	startAt := time.Now().Add(time.Hour)
+	pool.Add(types.DownloadConfig{ID: "later", URL: "http://example.com/later.bin", StartAt: startAt})
+
+	select {
+	case msg := <-ch:
+		scheduled, ok := msg.(events.DownloadScheduledMsg)
+		if !ok {
+			t.Fatalf("expected DownloadScheduledMsg, got %T", msg)
+		}
+		if !scheduled.StartAt.Equal(startAt) {
+			t.Errorf("StartAt = %v, want %v", scheduled.StartAt, startAt)
+		}
+	default:
+		t.Fatal("expected a scheduled message")
+	}
+	if len(pool.taskChan) != 0 {
+		t.Error("scheduled download should not be queued yet")
+	}
+	if status := pool.GetStatus("later"); status == nil || status.Status != "scheduled" {
+		t.Fatalf("GetStatus = %+v, want scheduled", status)
+	}
+	if pool.ActiveCount() != 1 {
+		t.Errorf("ActiveCount = %d, want 1 (scheduled downloads are pending work)", pool.ActiveCount())
+	}
+
+	// Once the start time has passed the scheduler releases it into the queue
+	pool.checkSchedule(startAt.Add(time.Second))
+	if _, ok := pool.ScheduledAt("later"); ok {
+		t.Error("download still scheduled after its start time")
+	}
+	if len(pool.taskChan) != 1 {
+		t.Errorf("taskChan length = %d, want 1", len(pool.taskChan))
+	}
+}
+
+func TestWorkerPool_Cancel_RemovesScheduled(t *testing.T) {
+	ch := make(chan any, 10)
+	pool := newSchedulingPool(ch)
+
+	pool.Add(types.DownloadConfig{ID: "later", Filename: "later.bin", StartAt: time.Now().Add(time.Hour)})
+	<-ch // DownloadScheduledMsg
+
+	pool.Cancel("later")
+	if _, ok := pool.ScheduledAt("later"); ok {
+		t.Error("cancelled download is still scheduled")
+	}
+	select {
+	case msg := <-ch:
+		if _, ok := msg.(events.DownloadRemovedMsg); !ok {
+			t.Errorf("expected DownloadRemovedMsg, got %T", msg)
+		}
+	default:
+		t.Error("expected a removed message")
+	}
+}
+
+func TestWorkerPool_ActiveWindow_PausesAndResumes(t *testing.T) {
+	ch := make(chan any, 20)
+	pool := newSchedulingPool(ch)
+
+	// A two-hour window around the current time; three hours from now it is closed
+	now := time.Now()
+	window := Window{
+		Start: (now.Hour()*60 + now.Minute() + 23*60) % (24 * 60),
+		End:   (now.Hour()*60 + now.Minute() + 60) % (24 * 60),
+	}
+	if err := pool.SetActiveWindows([]string{window.String()}); err != nil {
+		t.Fatalf("SetActiveWindows failed: %v", err)
+	}
+
+	state := types.NewProgressState("windowed", 1000)
+	pool.downloads["windowed"] = &activeDownload{
+		config: types.DownloadConfig{ID: "windowed", State: state},
+		cancel: func() {},
+	}
+
+	pool.checkSchedule(now.Add(3 * time.Hour))
+	if !state.IsPaused() {
+		t.Fatal("download should be paused when the window closes")
+	}
+
+	// Worker exits and clears the pausing flag
+	state.SetPausing(false)
+	pool.checkSchedule(now.Add(3 * time.Hour))
+	pool.mu.RLock()
+	persisted := pool.windowPaused["windowed"]
+	pool.mu.RUnlock()
+	if !persisted {
+		t.Error("window-paused download should be marked scheduled once paused")
+	}
+
+	pool.checkSchedule(time.Now())
+	if state.IsPaused() {
+		t.Error("download should resume when the window reopens")
+	}
+	if len(pool.taskChan) != 1 {
+		t.Errorf("taskChan length = %d, want 1 after resume", len(pool.taskChan))
+	}
+}
+
+func TestWorkerPool_SetActiveWindows_Invalid(t *testing.T) {
+	pool := newSchedulingPool(nil)
+	if err := pool.SetActiveWindows([]string{"01:00-07:00", "whenever"}); err == nil {
+		t.Error("expected error for invalid window")
+	}
+	if err := pool.SetActiveWindows(nil); err != nil {
+		t.Errorf("no windows should clear the restriction, got %v", err)
+	}
+}
+
+func TestWorkerPool_SetActiveWindows_AppliesWithoutRestart(t *testing.T) {
+	pool := newSchedulingPool(nil)
+	at := func(h, m int) time.Time { return time.Date(2025, 3, 10, h, m, 0, 0, time.Local) }
+	cfg := types.DownloadConfig{ID: "later"}
+
+	if err := pool.SetActiveWindows([]string{"01:00-03:00", "12:00-13:00"}); err != nil {
+		t.Fatalf("SetActiveWindows failed: %v", err)
+	}
+	pool.mu.RLock()
+	inLunch := pool.inWindow(cfg, at(12, 30))
+	next := pool.nextEligible(cfg, at(5, 0))
+	pool.mu.RUnlock()
+	if !inLunch {
+		t.Error("download should run inside the second window")
+	}
+	if !next.Equal(at(12, 0)) {
+		t.Errorf("nextEligible = %v, want %v", next, at(12, 0))
+	}
+
+	// Replacing the windows on a running pool takes effect at once
+	if err := pool.SetActiveWindows([]string{"06:00-07:00"}); err != nil {
+		t.Fatalf("SetActiveWindows failed: %v", err)
+	}
+	pool.mu.RLock()
+	inLunch = pool.inWindow(cfg, at(12, 30))
+	pool.mu.RUnlock()
+	if inLunch {
+		t.Error("old windows should no longer apply")
+	}
+}
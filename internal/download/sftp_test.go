@@ -0,0 +1,70 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func TestTUIDownload_SFTP(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	state.CloseDB()
+	state.Configure(filepath.Join(tmpDir, "surge.db"))
+	defer state.CloseDB()
+
+	data := make([]byte, 3*types.MB)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(tmpDir, "remote", "build.tar")
+	if err := os.MkdirAll(filepath.Dir(remote), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(remote, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := testutil.NewSSHServerT(t, "builder", testutil.WithSSHPassword("hunter2"))
+	knownHosts := filepath.Join(tmpDir, "known_hosts")
+	if err := server.WriteKnownHosts(knownHosts); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	progState := types.NewProgressState("sftp-manager", 0)
+	cfg := types.DownloadConfig{
+		URL:        "sftp://builder:hunter2@" + server.Addr() + filepath.ToSlash(remote),
+		OutputPath: tmpDir,
+		ID:         progState.ID,
+		ProgressCh: make(chan any, 100),
+		State:      progState,
+		Runtime:    &types.RuntimeConfig{SSHKnownHostsFile: knownHosts},
+	}
+	if err := download.TUIDownload(ctx, &cfg); err != nil {
+		t.Fatalf("TUIDownload failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(tmpDir, "build.tar"))
+	if err != nil {
+		t.Fatalf("Failed to read download: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Downloaded content differs")
+	}
+	if progState.TotalSize != int64(len(data)) {
+		t.Errorf("TotalSize = %d, want %d", progState.TotalSize, len(data))
+	}
+}
@@ -0,0 +1,201 @@
+// Package bandwidth provides token-bucket limiters used to cap download speed.
+package bandwidth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxReadSize bounds a single throttled read so waits stay short and
+	// bandwidth is shared fairly between workers
+	MaxReadSize = 64 * 1024
+	minReadSize = 1024
+
+	// recheckInterval is how often a waiting caller checks for a changed limit
+	recheckInterval = 250 * time.Millisecond
+
+	// bindingWindow is how recently a caller must have waited for the cap to count as binding
+	bindingWindow = 2 * time.Second
+)
+
+// Limiter is a token bucket measured in bytes per second. The zero value is an
+// unlimited limiter, and the limit can be changed while downloads are running.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   int64     // Bytes per second (0 = unlimited)
+	tokens float64   // Available bytes; negative when callers have reserved ahead
+	last   time.Time // Last refill
+	gen    uint64    // Bumped on every limit change so waiters re-reserve
+	waited time.Time // Last time a caller had to wait for tokens
+}
+
+// global is shared by every worker of every download
+var global = &Limiter{}
+
+// Global returns the process-wide limiter
+func Global() *Limiter {
+	return global
+}
+
+// NewLimiter creates a limiter capped at bytesPerSec (0 = unlimited)
+func NewLimiter(bytesPerSec int64) *Limiter {
+	l := &Limiter{}
+	l.SetLimit(bytesPerSec)
+	return l
+}
+
+// SetLimit changes the cap. Values <= 0 remove it.
+func (l *Limiter) SetLimit(bytesPerSec int64) {
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate != bytesPerSec {
+		// Start the new rate with an empty bucket so a raised limit doesn't burst
+		l.rate = bytesPerSec
+		l.tokens = 0
+		l.last = time.Now()
+		l.gen++
+	}
+}
+
+// Limit returns the current cap in bytes per second (0 = unlimited)
+func (l *Limiter) Limit() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// binding reports whether the cap made a caller wait within bindingWindow
+func (l *Limiter) binding(now time.Time) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate > 0 && !l.waited.IsZero() && now.Sub(l.waited) < bindingWindow
+}
+
+// generation returns the current limit generation
+func (l *Limiter) generation() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gen
+}
+
+// reserve takes n bytes from the bucket and returns how long the caller must
+// wait before using them, along with the limit generation it was computed for
+func (l *Limiter) reserve(n int, now time.Time) (time.Duration, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0, l.gen
+	}
+
+	// Refill, holding at most one second's worth of burst
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	}
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0, l.gen
+	}
+	l.waited = now
+	return time.Duration(-l.tokens / float64(l.rate) * float64(time.Second)), l.gen
+}
+
+// WaitN blocks until n bytes may be consumed or ctx is done
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	wait, gen := l.reserve(n, time.Now())
+	deadline := time.Now().Add(wait)
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		if remaining > recheckInterval {
+			remaining = recheckInterval
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		// The limit changed while we slept: the old reservation is void, take a new one
+		if g := l.generation(); g != gen {
+			wait, gen = l.reserve(n, time.Now())
+			deadline = time.Now().Add(wait)
+		}
+	}
+}
+
+// Wait blocks until n bytes fit under every limiter
+func Wait(ctx context.Context, n int, limiters ...*Limiter) error {
+	for _, l := range limiters {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Limited reports whether any of the limiters has a cap set
+func Limited(limiters ...*Limiter) bool {
+	return ReadSize(limiters...) > 0
+}
+
+// Binding reports whether any of the limiters is currently holding readers back,
+// as opposed to merely having a cap that traffic stays under
+func Binding(limiters ...*Limiter) bool {
+	now := time.Now()
+	for _, l := range limiters {
+		if l.binding(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadSize returns how many bytes a worker should read at a time under the
+// strictest of the limiters, or 0 if none of them has a cap
+func ReadSize(limiters ...*Limiter) int {
+	var lowest int64
+	for _, l := range limiters {
+		if r := l.Limit(); r > 0 && (lowest == 0 || r < lowest) {
+			lowest = r
+		}
+	}
+	if lowest == 0 {
+		return 0
+	}
+
+	size := int(lowest / 8) // Several reads per second keeps the flow smooth
+	if size < minReadSize {
+		size = minReadSize
+	}
+	if size > MaxReadSize {
+		size = MaxReadSize
+	}
+	return size
+}
@@ -0,0 +1,138 @@
+package bandwidth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_ZeroValueIsUnlimited(t *testing.T) {
+	var l Limiter
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := l.WaitN(context.Background(), MaxReadSize); err != nil {
+			t.Fatalf("WaitN failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited limiter blocked for %v", elapsed)
+	}
+	if Limited(&l) {
+		t.Error("zero-value limiter reported as limited")
+	}
+}
+
+func TestLimiter_EnforcesRate(t *testing.T) {
+	const rate = 100 * 1024
+	l := NewLimiter(rate)
+
+	// The bucket starts empty, so every byte is paced
+	start := time.Now()
+	for sent := 0; sent < rate/2; sent += 4096 {
+		if err := l.WaitN(context.Background(), 4096); err != nil {
+			t.Fatalf("WaitN failed: %v", err)
+		}
+	}
+	elapsed := time.Since(start)
+	if elapsed < 400*time.Millisecond || elapsed > 900*time.Millisecond {
+		t.Errorf("sending half a second of data took %v, want ~500ms", elapsed)
+	}
+}
+
+func TestLimiter_SharedAcrossCallers(t *testing.T) {
+	const rate = 200 * 1024
+	l := NewLimiter(rate)
+
+	start := time.Now()
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for sent := 0; sent < rate/8; sent += 8192 {
+				_ = l.WaitN(context.Background(), 8192)
+			}
+		}()
+	}
+	for w := 0; w < 4; w++ {
+		<-done
+	}
+
+	// Four workers each moving an eighth of the rate should take about half a second in total
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("four workers finished in %v, limiter is not shared", elapsed)
+	}
+}
+
+func TestLimiter_SetLimitWakesWaiters(t *testing.T) {
+	l := NewLimiter(1024)
+
+	done := make(chan error, 1)
+	go func() {
+		// Ten seconds' worth at the initial rate
+		done <- l.WaitN(context.Background(), 10*1024)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.SetLimit(0)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WaitN failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter did not pick up the removed limit")
+	}
+}
+
+func TestLimiter_ContextCancel(t *testing.T) {
+	l := NewLimiter(1024)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := l.WaitN(ctx, 100*1024)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitN error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestReadSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		limiters []*Limiter
+		want     int
+	}{
+		{"unlimited", []*Limiter{{}, {}}, 0},
+		{"nil", []*Limiter{nil}, 0},
+		{"strictest wins", []*Limiter{NewLimiter(80 * 1024), NewLimiter(8 * 1024 * 1024)}, 10 * 1024},
+		{"floor", []*Limiter{NewLimiter(100)}, minReadSize},
+		{"ceiling", []*Limiter{NewLimiter(100 * 1024 * 1024)}, MaxReadSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReadSize(tt.limiters...); got != tt.want {
+				t.Errorf("ReadSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinding(t *testing.T) {
+	if Binding(&Limiter{}, nil) {
+		t.Error("Unlimited limiters should never be binding")
+	}
+
+	l := NewLimiter(1024)
+	if Binding(l) {
+		t.Error("Limiter should not be binding before anyone waited on it")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = l.WaitN(ctx, 4096) // Overdraws the bucket even though the wait is abandoned
+	if !Binding(&Limiter{}, l) {
+		t.Error("Limiter should be binding after a caller had to wait")
+	}
+}
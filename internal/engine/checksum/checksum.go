@@ -0,0 +1,139 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// Supported digest algorithms
+const (
+	SHA512 = "sha512"
+	SHA256 = "sha256"
+	SHA1   = "sha1"
+	MD5    = "md5"
+)
+
+// Spec is a parsed expected digest
+type Spec struct {
+	Algorithm string
+	Digest    string // Lowercase hex
+}
+
+// String returns the canonical "algo:hex" form stored in the database
+func (s Spec) String() string {
+	return s.Algorithm + ":" + s.Digest
+}
+
+// Parse parses an expected digest in "algo:hex" (or "algo=hex") form.
+// A bare hex string is accepted when its length identifies the algorithm.
+func Parse(raw string) (*Spec, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, fmt.Errorf("empty checksum")
+	}
+
+	algo, digest := "", raw
+	if idx := strings.IndexAny(raw, ":="); idx != -1 {
+		algo = strings.ToLower(strings.TrimSpace(raw[:idx]))
+		digest = strings.TrimSpace(raw[idx+1:])
+	}
+	digest = strings.ToLower(digest)
+
+	if _, err := hex.DecodeString(digest); err != nil {
+		return nil, fmt.Errorf("invalid checksum digest %q: not hex", digest)
+	}
+
+	switch algo {
+	case "sha-512":
+		algo = SHA512
+	case "sha-256":
+		algo = SHA256
+	case "sha-1":
+		algo = SHA1
+	case "":
+		// Infer algorithm from digest length
+		switch len(digest) {
+		case sha512.Size * 2:
+			algo = SHA512
+		case sha256.Size * 2:
+			algo = SHA256
+		case sha1.Size * 2:
+			algo = SHA1
+		case md5.Size * 2:
+			algo = MD5
+		default:
+			return nil, fmt.Errorf("cannot infer checksum algorithm from %d hex chars", len(digest))
+		}
+	}
+
+	h, err := newHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != h.Size()*2 {
+		return nil, fmt.Errorf("invalid %s digest length: got %d hex chars, want %d", algo, len(digest), h.Size()*2)
+	}
+
+	return &Spec{Algorithm: algo, Digest: digest}, nil
+}
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case SHA512:
+		return sha512.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case MD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+}
+
+// VerifyFile hashes the file at path and compares it against the expected digest.
+// An empty expected string disables verification.
+// Returns an error wrapping types.ErrChecksumMismatch when the digests differ.
+func VerifyFile(path string, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	spec, err := Parse(expected)
+	if err != nil {
+		return err
+	}
+
+	h, err := newHash(spec.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file for verification: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	buf := make([]byte, 1024*1024)
+	if _, err := io.CopyBuffer(h, f, buf); err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != spec.Digest {
+		return fmt.Errorf("%w: %s expected %s, got %s", types.ErrChecksumMismatch, spec.Algorithm, spec.Digest, actual)
+	}
+	return nil
+}
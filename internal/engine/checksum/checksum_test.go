@@ -0,0 +1,111 @@
+package checksum
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	helloSHA1   = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	helloMD5    = "5d41402abc4b2a76b9719d911017c592"
+	helloSHA512 = "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantAlgo string
+		wantErr  bool
+	}{
+		{"sha256 prefixed", "sha256:" + helloSHA256, SHA256, false},
+		{"sha256 equals", "sha256=" + helloSHA256, SHA256, false},
+		{"sha-256 alias", "SHA-256:" + helloSHA256, SHA256, false},
+		{"sha1 prefixed", "sha1:" + helloSHA1, SHA1, false},
+		{"md5 prefixed", "md5:" + helloMD5, MD5, false},
+		{"sha-512 alias", "sha-512:" + helloSHA512, SHA512, false},
+		{"bare sha512", helloSHA512, SHA512, false},
+		{"bare sha256", helloSHA256, SHA256, false},
+		{"bare sha1", helloSHA1, SHA1, false},
+		{"bare md5", helloMD5, MD5, false},
+		{"uppercase digest", "sha256:2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824", SHA256, false},
+		{"empty", "", "", true},
+		{"unknown algorithm", "crc32:" + helloMD5, "", true},
+		{"wrong length", "sha256:" + helloMD5, "", true},
+		{"not hex", "md5:zz41402abc4b2a76b9719d911017c592", "", true},
+		{"bare unknown length", "abcd", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) expected error, got %+v", tt.input, spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) unexpected error: %v", tt.input, err)
+			}
+			if spec.Algorithm != tt.wantAlgo {
+				t.Errorf("Algorithm = %q, want %q", spec.Algorithm, tt.wantAlgo)
+			}
+		})
+	}
+}
+
+func TestSpec_String(t *testing.T) {
+	spec, err := Parse("SHA-256=" + helloSHA256)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got, want := spec.String(), "sha256:"+helloSHA256; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"sha256:" + helloSHA256,
+		"sha1:" + helloSHA1,
+		"md5:" + helloMD5,
+		"",
+	} {
+		if err := VerifyFile(path, expected); err != nil {
+			t.Errorf("VerifyFile(%q) unexpected error: %v", expected, err)
+		}
+	}
+}
+
+func TestVerifyFile_Mismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello!"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	err := VerifyFile(path, "sha256:"+helloSHA256)
+	if !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestVerifyFile_MissingFile(t *testing.T) {
+	err := VerifyFile(filepath.Join(t.TempDir(), "missing"), "md5:"+helloMD5)
+	if err == nil {
+		t.Fatal("expected error for missing file")
+	}
+	if errors.Is(err, types.ErrChecksumMismatch) {
+		t.Error("missing file should not be reported as a checksum mismatch")
+	}
+}
@@ -646,3 +646,34 @@ func TestCreateTasks_ZeroChunkSize(t *testing.T) {
 		t.Error("createTasks should return nil for negative chunk size")
 	}
 }
+
+func TestConcurrentDownloader_ChecksumMismatch(t *testing.T) {
+	tmpDir, cleanup := initTestState(t)
+	defer cleanup()
+
+	fileSize := int64(256 * types.KB)
+	server := testutil.NewMockServerT(t,
+		testutil.WithFileSize(fileSize),
+		testutil.WithRangeSupport(true),
+	)
+	defer server.Close()
+
+	destPath := filepath.Join(tmpDir, "checksum_bad.bin")
+	state := types.NewProgressState("checksum-id", fileSize)
+	runtime := &types.RuntimeConfig{MaxConnectionsPerHost: 4}
+
+	downloader := NewConcurrentDownloader("checksum-id", nil, state, runtime)
+	downloader.Checksum = "sha1:0000000000000000000000000000000000000000"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := downloader.Download(ctx, server.URL(), nil, nil, destPath, fileSize, false)
+	if !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	if err := testutil.VerifyFileSize(destPath, fileSize); err == nil {
+		t.Error("Final file should not be renamed into place after failed verification")
+	}
+}
@@ -3,6 +3,7 @@ package concurrent
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"math"
 	"net"
@@ -13,6 +14,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/cookies"
+	"github.com/surge-downloader/surge/internal/engine/diskspace"
 	"github.com/surge-downloader/surge/internal/engine/state"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
@@ -30,6 +34,13 @@ type ConcurrentDownloader struct {
 	Runtime      *types.RuntimeConfig
 	bufPool      sync.Pool
 	Headers      map[string]string // Custom HTTP headers from browser (cookies, auth, etc.)
+	Checksum     string            // Expected digest ("algo:hex"), verified before finalizing
+	hostLimiter  *HostLimiter      // Per-host 429/503 backoff (shared across downloads by default)
+	ETag         string            // Remote validators from the probe, sent as If-Range
+	LastModified string
+	Extract      string // Extract mode on completion, carried across pauses
+
+	mirrorStats map[string]*mirrorStat // Per-mirror scheduling stats (guarded by activeMu)
 }
 
 // NewConcurrentDownloader creates a new concurrent downloader with all required parameters
@@ -39,7 +50,9 @@ func NewConcurrentDownloader(id string, progressCh chan<- any, progState *types.
 		ProgressChan: progressCh,
 		State:        progState,
 		activeTasks:  make(map[int]*ActiveTask),
+		mirrorStats:  make(map[string]*mirrorStat),
 		Runtime:      runtime,
+		hostLimiter:  defaultHostLimiter,
 		bufPool: sync.Pool{
 			New: func() any {
 				// Use configured buffer size
@@ -88,24 +101,22 @@ func (d *ConcurrentDownloader) getInitialConnections(fileSize int64) int {
 	return calculatedWorkers
 }
 
-// ReportMirrorError marks a mirror as having an error in the state
-func (d *ConcurrentDownloader) ReportMirrorError(url string) {
-	if d.State == nil {
-		return
+// limiter returns the host limiter, falling back to the shared default
+func (d *ConcurrentDownloader) limiter() *HostLimiter {
+	if d.hostLimiter == nil {
+		return defaultHostLimiter
 	}
+	return d.hostLimiter
+}
 
-	mirrors := d.State.GetMirrors()
-	changed := false
-	for i, m := range mirrors {
-		if m.URL == url && !m.Error {
-			mirrors[i].Error = true
-			changed = true
-			break
-		}
-	}
+// ReportMirrorError records a failed request against a mirror
+func (d *ConcurrentDownloader) ReportMirrorError(url string) {
+	d.activeMu.Lock()
+	d.mirrorStatLocked(url).failures++
+	d.activeMu.Unlock()
 
-	if changed {
-		d.State.SetMirrors(mirrors)
+	if d.State != nil {
+		d.State.AddMirrorError(url)
 	}
 }
 
@@ -221,7 +232,7 @@ func (d *ConcurrentDownloader) newConcurrentClient(numConns int) *http.Client {
 	}
 
 	return &http.Client{
-		Transport: transport,
+		Transport: cookies.Transport(transport),
 		// Preserve headers on redirects for authenticated downloads
 		// By default, Go strips sensitive headers (Cookie, Authorization) on cross-domain redirects.
 		// Since these headers were explicitly provided by the browser for this download, we forward them.
@@ -326,6 +337,13 @@ func (d *ConcurrentDownloader) Download(ctx context.Context, rawurl string, cand
 	savedState, err := state.LoadState(rawurl, destPath)
 	isResume := err == nil && savedState != nil && len(savedState.Tasks) > 0
 
+	// Never stitch bytes from two different versions of the remote file together
+	if isResume && remoteChanged(savedState, d.ETag, d.LastModified, fileSize) {
+		utils.Debug("Remote file changed since pause (etag %q -> %q, last-modified %q -> %q), restarting from scratch",
+			savedState.ETag, d.ETag, savedState.LastModified, d.LastModified)
+		isResume = false
+	}
+
 	if isResume {
 		// Resume: use saved tasks and restore downloaded counter
 		tasks = savedState.Tasks
@@ -349,7 +367,7 @@ func (d *ConcurrentDownloader) Download(ctx context.Context, rawurl string, cand
 		utils.Debug("Resuming from saved state: %d tasks, %d bytes downloaded", len(tasks), savedState.Downloaded)
 	} else {
 		// Fresh download: preallocate file and create new tasks
-		if err := outFile.Truncate(fileSize); err != nil {
+		if err := diskspace.Allocate(outFile, fileSize, d.Runtime != nil && d.Runtime.PreallocateFiles); err != nil {
 			return fmt.Errorf("failed to preallocate file: %w", err)
 		}
 		// Robustness: ensure state counter starts at 0 for fresh download
@@ -468,6 +486,9 @@ func (d *ConcurrentDownloader) Download(ctx context.Context, rawurl string, cand
 			err := d.worker(downloadCtx, workerID, workerMirrors, outFile, queue, fileSize, startTime, verbose, client)
 			if err != nil && err != context.Canceled {
 				workerErrors <- err
+				if errors.Is(err, types.ErrRemoteChanged) {
+					cancel() // Remaining bytes would come from a different file version
+				}
 			}
 		}(i)
 	}
@@ -536,9 +557,16 @@ func (d *ConcurrentDownloader) Download(ctx context.Context, rawurl string, cand
 			Filename:        filepath.Base(destPath),
 			Elapsed:         totalElapsed.Nanoseconds(),
 			Mirrors:         candidateMirrors,
+			Checksum:        d.Checksum,
+			ETag:            d.ETag,
+			LastModified:    d.LastModified,
+			Extract:         d.Extract,
 			ChunkBitmap:     chunkBitmap,
 			ActualChunkSize: actualChunkSize,
 		}
+		if d.State != nil {
+			s.SpeedLimit = d.State.SpeedLimit.Limit()
+		}
 		if err := state.SaveState(d.URL, destPath, s); err != nil {
 			utils.Debug("Failed to save pause state: %v", err)
 		}
@@ -548,6 +576,10 @@ func (d *ConcurrentDownloader) Download(ctx context.Context, rawurl string, cand
 		return types.ErrPaused // Signal valid pause to caller
 	}
 
+	if errors.Is(downloadErr, types.ErrRemoteChanged) {
+		return downloadErr
+	}
+
 	// Handle cancel: context was cancelled but not via Pause()
 	// Propagate cancellation so callers don't treat this as a successful completion.
 	if downloadCtx.Err() == context.Canceled {
@@ -566,6 +598,11 @@ func (d *ConcurrentDownloader) Download(ctx context.Context, rawurl string, cand
 	// Close file before renaming
 	_ = outFile.Close()
 
+	// Verify expected digest before exposing the file under its final name
+	if err := checksum.VerifyFile(workingPath, d.Checksum); err != nil {
+		return err
+	}
+
 	// Rename from .surge to final destination
 	if err := os.Rename(workingPath, destPath); err != nil {
 		// Check for race condition: did someone else already rename it?
@@ -1,16 +1,24 @@
 package concurrent
 
 import (
+	"sync/atomic"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
 	"github.com/surge-downloader/surge/internal/utils"
 )
 
-// checkWorkerHealth detects slow workers and cancels them
+// checkWorkerHealth detects stalled and slow workers and cancels them
 func (d *ConcurrentDownloader) checkWorkerHealth() {
 	d.activeMu.Lock()
 	defer d.activeMu.Unlock()
 
+	// While a speed cap is holding workers back they are slow by design, so
+	// neither compare their speeds nor blame their mirrors. Stalls still count.
+	binding := bandwidth.Binding(d.State.Limiters()...)
+	d.updateMirrorStats(!binding)
+
 	if len(d.activeTasks) == 0 {
 		return
 	}
@@ -63,9 +71,20 @@ func (d *ConcurrentDownloader) checkWorkerHealth() {
 			continue
 		}
 
+		// Check for stalled worker
+		stallTimeout := d.Runtime.GetStallTimeout()
+		if lastActivity := atomic.LoadInt64(&active.LastActivity); lastActivity > 0 && now.Sub(time.Unix(0, lastActivity)) > stallTimeout {
+			utils.Debug("Health: Worker %d stalled (no data for %v), cancelling", workerID, stallTimeout)
+			if active.Cancel != nil {
+				active.Cancel()
+				metrics.WorkerRestarts.Inc()
+			}
+			continue
+		}
+
 		// Check for slow worker
 		// Only cancel if: below threshold
-		if meanSpeed > 0 {
+		if meanSpeed > 0 && !binding {
 			workerSpeed := active.GetSpeed()
 			threshold := d.Runtime.GetSlowWorkerThreshold()
 			isBelowThreshold := workerSpeed > 0 && workerSpeed < threshold*meanSpeed
@@ -75,6 +94,7 @@ func (d *ConcurrentDownloader) checkWorkerHealth() {
 					workerID, workerSpeed/1024, meanSpeed/1024)
 				if active.Cancel != nil {
 					active.Cancel()
+					metrics.WorkerRestarts.Inc()
 				}
 			}
 		}
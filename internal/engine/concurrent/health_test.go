@@ -144,3 +144,59 @@ func TestHealth_GracePeriod(t *testing.T) {
 	default:
 	}
 }
+
+func TestHealth_SpeedCap(t *testing.T) {
+	runtime := &types.RuntimeConfig{
+		SlowWorkerThreshold:   0.5,
+		SlowWorkerGracePeriod: 0,
+		StallTimeout:          time.Second,
+	}
+
+	newWorkers := func(t *testing.T, state *types.ProgressState) (*ConcurrentDownloader, context.Context, context.Context) {
+		d := NewConcurrentDownloader("test", nil, state, runtime)
+		now := time.Now()
+		_, fastCancel := context.WithCancel(context.Background())
+		slowCtx, slowCancel := context.WithCancel(context.Background())
+		stalledCtx, stalledCancel := context.WithCancel(context.Background())
+		t.Cleanup(func() { fastCancel(); slowCancel(); stalledCancel() })
+
+		d.activeTasks[0] = &ActiveTask{StartTime: now.Add(-10 * time.Second), LastActivity: now.UnixNano(), Speed: 10 * 1024 * 1024, Cancel: fastCancel}
+		d.activeTasks[1] = &ActiveTask{StartTime: now.Add(-10 * time.Second), LastActivity: now.UnixNano(), Speed: 1 * 1024 * 1024, Cancel: slowCancel}
+		d.activeTasks[2] = &ActiveTask{StartTime: now.Add(-10 * time.Second), LastActivity: now.Add(-5 * time.Second).UnixNano(), Speed: 10 * 1024 * 1024, Cancel: stalledCancel}
+		return d, slowCtx, stalledCtx
+	}
+
+	t.Run("cap not reached", func(t *testing.T) {
+		state := types.NewProgressState("test", 1000)
+		state.SpeedLimit.SetLimit(100 * 1024 * 1024)
+		d, slowCtx, stalledCtx := newWorkers(t, state)
+
+		d.checkWorkerHealth()
+
+		if slowCtx.Err() == nil {
+			t.Error("Slow worker should be cancelled while the cap is not holding it back")
+		}
+		if stalledCtx.Err() == nil {
+			t.Error("Stalled worker should be cancelled")
+		}
+	})
+
+	t.Run("cap binding", func(t *testing.T) {
+		state := types.NewProgressState("test", 1000)
+		state.SpeedLimit.SetLimit(1024)
+		// Drain the bucket so the next reader has to wait
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_ = state.SpeedLimit.WaitN(ctx, 4096)
+		d, slowCtx, stalledCtx := newWorkers(t, state)
+
+		d.checkWorkerHealth()
+
+		if slowCtx.Err() != nil {
+			t.Error("Slow worker should not be cancelled while the cap holds it back")
+		}
+		if stalledCtx.Err() == nil {
+			t.Error("Stalled worker should be cancelled even under a binding cap")
+		}
+	})
+}
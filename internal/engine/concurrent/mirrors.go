@@ -0,0 +1,215 @@
+package concurrent
+
+import (
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// mirrorStat is the scheduler's view of one mirror (guarded by activeMu)
+type mirrorStat struct {
+	rate     float64 // Last measured per-connection speed in bytes/sec
+	strikes  int     // Consecutive health checks in which the mirror was slow
+	failures int     // Consecutive failed requests
+	demoted  bool
+}
+
+// mirrorStatLocked returns the stats for url, creating them if needed. Caller must hold activeMu.
+func (d *ConcurrentDownloader) mirrorStatLocked(url string) *mirrorStat {
+	if d.mirrorStats == nil {
+		d.mirrorStats = make(map[string]*mirrorStat)
+	}
+	s, ok := d.mirrorStats[url]
+	if !ok {
+		s = &mirrorStat{}
+		d.mirrorStats[url] = s
+	}
+	return s
+}
+
+// pickMirror chooses the mirror for a worker's next task. Measured per-connection speed
+// acts as each mirror's weight: the worker joins the mirror with the fewest connections
+// per unit of throughput, so faster mirrors carry proportionally more connections.
+// Mirrors that keep failing are penalised, and demoted mirrors are skipped unless every
+// mirror is demoted. Ties keep the worker on its current mirror.
+func (d *ConcurrentDownloader) pickMirror(mirrors []string, current int) int {
+	if len(mirrors) <= 1 {
+		return 0
+	}
+
+	d.activeMu.Lock()
+	defer d.activeMu.Unlock()
+
+	conns := make(map[string]int)
+	for _, active := range d.activeTasks {
+		conns[active.Mirror]++
+	}
+
+	// Unmeasured mirrors get the mean rate so they still receive work
+	var sum float64
+	var measured int
+	allDemoted := true
+	for _, m := range mirrors {
+		s := d.mirrorStats[m]
+		if s != nil && s.rate > 0 {
+			sum += s.rate
+			measured++
+		}
+		if s == nil || !s.demoted {
+			allDemoted = false
+		}
+	}
+	mean := 1.0
+	if measured > 0 {
+		mean = sum / float64(measured)
+	}
+
+	best := current
+	bestScore := -1.0
+	for offset := 0; offset < len(mirrors); offset++ {
+		i := (current + offset) % len(mirrors)
+		s := d.mirrorStats[mirrors[i]]
+		rate := mean
+		failures := 0
+		if s != nil {
+			if s.demoted && !allDemoted {
+				continue
+			}
+			if s.rate > 0 {
+				rate = s.rate
+			}
+			failures = s.failures
+		}
+		score := float64(conns[mirrors[i]]+1) * float64(failures+1) / rate
+		if bestScore < 0 || score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// nextMirror returns the mirror to fail over to after mirrors[current],
+// skipping demoted mirrors while any other mirror is still in good standing
+func (d *ConcurrentDownloader) nextMirror(mirrors []string, current int) int {
+	d.activeMu.Lock()
+	defer d.activeMu.Unlock()
+
+	for step := 1; step < len(mirrors); step++ {
+		i := (current + step) % len(mirrors)
+		if s := d.mirrorStats[mirrors[i]]; s == nil || !s.demoted {
+			return i
+		}
+	}
+	return (current + 1) % len(mirrors)
+}
+
+// recordMirrorSuccess clears a mirror's consecutive failure count
+func (d *ConcurrentDownloader) recordMirrorSuccess(url string) {
+	d.activeMu.Lock()
+	defer d.activeMu.Unlock()
+	if s := d.mirrorStats[url]; s != nil {
+		s.failures = 0
+	}
+}
+
+// updateMirrorStats refreshes per-mirror throughput from the active tasks and, when
+// allowed, demotes mirrors that stay far slower than the fastest one. Tasks on a newly
+// demoted mirror are cancelled so their remaining bytes move to a faster mirror.
+// Caller must hold activeMu.
+func (d *ConcurrentDownloader) updateMirrorStats(allowDemotion bool) {
+	type load struct {
+		total float64
+		conns int
+	}
+	loads := make(map[string]*load)
+	for _, active := range d.activeTasks {
+		if active.Mirror == "" {
+			continue
+		}
+		l, ok := loads[active.Mirror]
+		if !ok {
+			l = &load{}
+			loads[active.Mirror] = l
+		}
+		if speed := active.GetSpeed(); speed > 0 {
+			l.total += speed
+			l.conns++
+		}
+	}
+
+	if d.State != nil {
+		for _, m := range d.State.GetMirrors() {
+			var speed float64
+			if l := loads[m.URL]; l != nil {
+				speed = l.total
+			}
+			if speed != m.Speed {
+				d.State.SetMirrorSpeed(m.URL, speed)
+			}
+		}
+	}
+
+	var best float64
+	healthy := 0
+	for url, l := range loads {
+		s := d.mirrorStatLocked(url)
+		if l.conns > 0 {
+			s.rate = l.total / float64(l.conns)
+		}
+		if !s.demoted && s.rate > best {
+			best = s.rate
+		}
+	}
+	for _, s := range d.mirrorStats {
+		if !s.demoted {
+			healthy++
+		}
+	}
+
+	// Slowness is relative, so it takes at least two measured mirrors
+	if !allowDemotion || len(loads) < 2 || best <= 0 {
+		return
+	}
+
+	for url, l := range loads {
+		s := d.mirrorStats[url]
+		if s.demoted || l.conns == 0 {
+			continue
+		}
+		if s.rate >= types.SlowMirrorThreshold*best {
+			s.strikes = 0
+			continue
+		}
+		s.strikes++
+		if s.strikes < types.MirrorDemoteStrikes || healthy <= 1 {
+			continue
+		}
+
+		s.demoted = true
+		healthy--
+		utils.Debug("Mirrors: demoting %s (%.2f KB/s per connection vs best %.2f KB/s)", url, s.rate/1024, best/1024)
+		if d.State != nil {
+			d.State.SetMirrorDemoted(url, true)
+		}
+		for _, active := range d.activeTasks {
+			if active.Mirror == url && active.Cancel != nil {
+				active.Cancel()
+			}
+		}
+	}
+}
+
+// estimatedFinish returns how long an active task needs for its remaining bytes, in
+// seconds. Tasks without a speed sample fall back to their mirror's rate, then fallback.
+// Caller must hold activeMu.
+func (d *ConcurrentDownloader) estimatedFinish(active *ActiveTask, remaining int64, fallback float64) float64 {
+	speed := active.GetSpeed()
+	if speed <= 0 {
+		if s := d.mirrorStats[active.Mirror]; s != nil && s.rate > 0 {
+			speed = s.rate
+		}
+	}
+	if speed <= 0 {
+		speed = fallback
+	}
+	return float64(remaining) / speed
+}
@@ -117,3 +117,151 @@ func TestMirrors_Failover(t *testing.T) {
 		t.Error("Expected good server to handle requests after failover")
 	}
 }
+
+// newWeightingTestDownloader returns a downloader whose active tasks run on the given
+// mirrors at the given per-connection speeds
+func newWeightingTestDownloader(speeds map[string][]float64) *ConcurrentDownloader {
+	state := types.NewProgressState("weighting", 100*types.MB)
+	var statuses []types.MirrorStatus
+	d := NewConcurrentDownloader("weighting", nil, state, &types.RuntimeConfig{})
+	id := 0
+	now := time.Now()
+	for url, list := range speeds {
+		statuses = append(statuses, types.MirrorStatus{URL: url, Active: true})
+		for _, speed := range list {
+			d.activeTasks[id] = &ActiveTask{
+				Task:         types.Task{Offset: int64(id) * 20 * types.MB, Length: 20 * types.MB},
+				Mirror:       url,
+				StopAt:       int64(id+1) * 20 * types.MB,
+				Speed:        speed,
+				LastActivity: now.UnixNano(),
+			}
+			d.activeTasks[id].CurrentOffset = d.activeTasks[id].Task.Offset
+			id++
+		}
+	}
+	state.SetMirrors(statuses)
+	return d
+}
+
+func TestPickMirror_PrefersFasterMirror(t *testing.T) {
+	mirrors := []string{"http://slow", "http://fast"}
+	d := newWeightingTestDownloader(map[string][]float64{
+		"http://slow": {100 * types.KB},
+		"http://fast": {1 * types.MB},
+	})
+	d.activeMu.Lock()
+	d.updateMirrorStats(false)
+	d.activeMu.Unlock()
+
+	// Both mirrors carry one connection; the fast one can take another far better
+	if got := d.pickMirror(mirrors, 0); got != 1 {
+		t.Errorf("pickMirror = %d, want fast mirror", got)
+	}
+
+	// Unmeasured mirrors share work evenly, keeping the worker where it is on ties
+	fresh := NewConcurrentDownloader("fresh", nil, nil, &types.RuntimeConfig{})
+	if got := fresh.pickMirror(mirrors, 1); got != 1 {
+		t.Errorf("pickMirror without stats = %d, want current mirror", got)
+	}
+}
+
+func TestPickMirror_AvoidsFailingAndDemotedMirrors(t *testing.T) {
+	mirrors := []string{"http://a", "http://b", "http://c"}
+	d := NewConcurrentDownloader("demoted", nil, nil, &types.RuntimeConfig{})
+
+	d.ReportMirrorError("http://a")
+	if got := d.pickMirror(mirrors, 0); got == 0 {
+		t.Error("pickMirror kept a failing mirror")
+	}
+
+	d.activeMu.Lock()
+	d.mirrorStatLocked("http://b").demoted = true
+	d.activeMu.Unlock()
+	if got := d.nextMirror(mirrors, 0); got != 2 {
+		t.Errorf("nextMirror = %d, want to skip demoted mirror", got)
+	}
+
+	// A success clears the penalty
+	d.recordMirrorSuccess("http://a")
+	if got := d.pickMirror(mirrors, 0); got != 0 {
+		t.Errorf("pickMirror = %d, want recovered mirror", got)
+	}
+}
+
+func TestUpdateMirrorStats_DemotesConsistentlySlowMirror(t *testing.T) {
+	d := newWeightingTestDownloader(map[string][]float64{
+		"http://slow": {10 * types.KB},
+		"http://fast": {1 * types.MB, 1 * types.MB},
+	})
+
+	d.activeMu.Lock()
+	for i := 0; i < types.MirrorDemoteStrikes-1; i++ {
+		d.updateMirrorStats(true)
+	}
+	d.activeMu.Unlock()
+	if d.mirrorStats["http://slow"].demoted {
+		t.Fatal("mirror demoted before reaching the strike limit")
+	}
+
+	d.activeMu.Lock()
+	d.updateMirrorStats(true)
+	d.activeMu.Unlock()
+	if !d.mirrorStats["http://slow"].demoted {
+		t.Fatal("slow mirror was not demoted")
+	}
+
+	for _, m := range d.State.GetMirrors() {
+		switch m.URL {
+		case "http://slow":
+			if !m.Demoted || m.Speed != 10*types.KB {
+				t.Errorf("slow mirror status = %+v", m)
+			}
+		case "http://fast":
+			if m.Demoted || m.Speed != 2*types.MB {
+				t.Errorf("fast mirror status = %+v", m)
+			}
+		}
+	}
+}
+
+func TestUpdateMirrorStats_NoDemotionWhileThrottled(t *testing.T) {
+	d := newWeightingTestDownloader(map[string][]float64{
+		"http://slow": {10 * types.KB},
+		"http://fast": {1 * types.MB},
+	})
+
+	d.activeMu.Lock()
+	for i := 0; i < types.MirrorDemoteStrikes*2; i++ {
+		d.updateMirrorStats(false)
+	}
+	d.activeMu.Unlock()
+	if d.mirrorStats["http://slow"].demoted {
+		t.Error("mirror demoted while demotion was disabled")
+	}
+}
+
+func TestStealWork_PrefersTaskOnSlowMirror(t *testing.T) {
+	d := newWeightingTestDownloader(map[string][]float64{
+		"http://slow": {100 * types.KB},
+		"http://fast": {10 * types.MB},
+	})
+	// Give the fast task more bytes left; it still finishes far sooner
+	for _, active := range d.activeTasks {
+		if active.Mirror == "http://fast" {
+			active.Task.Length *= 2
+			active.StopAt = active.Task.Offset + active.Task.Length
+		}
+	}
+
+	queue := NewTaskQueue()
+	if !d.StealWork(queue) {
+		t.Fatal("StealWork found nothing to steal")
+	}
+	for _, active := range d.activeTasks {
+		shortened := active.StopAt < active.Task.Offset+active.Task.Length
+		if shortened != (active.Mirror == "http://slow") {
+			t.Errorf("task on %s shortened = %v", active.Mirror, shortened)
+		}
+	}
+}
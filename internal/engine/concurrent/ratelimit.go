@@ -0,0 +1,240 @@
+package concurrent
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// hostSlotPollInterval is how often a worker re-checks for a free connection slot
+// on a host at its connection cap
+const hostSlotPollInterval = 50 * time.Millisecond
+
+// HostLimiter coordinates rate-limit backoff for every worker targeting the same host.
+// A 429/503 from a host puts it into cooldown (all workers wait) and halves the number
+// of concurrent connections allowed to it. Successful responses restore connections
+// one at a time.
+type HostLimiter struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	cooldownUntil time.Time
+	strikes       int // Consecutive rate-limit responses without a success
+	inFlight      int // Requests currently holding a slot
+	maxConns      int // Connection cap (0 = unlimited)
+	peakConns     int // In-flight count when the cap was first applied
+}
+
+// defaultHostLimiter is shared by all downloads so that two downloads from the
+// same server back off together
+var defaultHostLimiter = NewHostLimiter()
+
+// SharedHostLimiter returns the host limiter used by downloads that do not set their own
+func SharedHostLimiter() *HostLimiter {
+	return defaultHostLimiter
+}
+
+// NewHostLimiter creates an empty host limiter
+func NewHostLimiter() *HostLimiter {
+	return &HostLimiter{hosts: make(map[string]*hostState)}
+}
+
+// hostKey returns the host (with port) used to group requests
+func hostKey(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+	return strings.ToLower(u.Host)
+}
+
+func (l *HostLimiter) get(host string) *hostState {
+	hs, ok := l.hosts[host]
+	if !ok {
+		hs = &hostState{}
+		l.hosts[host] = hs
+	}
+	return hs
+}
+
+// Acquire blocks until the host is out of cooldown and below its connection cap,
+// then takes a connection slot. limit is the host's configured cap across all
+// downloads (0 = none). onWait (may be nil) is called with the cooldown end
+// whenever the caller has to wait out a cooldown.
+// Every successful Acquire must be paired with Release.
+func (l *HostLimiter) Acquire(ctx context.Context, host string, limit int, onWait func(until time.Time)) error {
+	for {
+		l.mu.Lock()
+		hs := l.get(host)
+		now := time.Now()
+
+		var wait time.Duration
+		var until time.Time
+		switch {
+		case now.Before(hs.cooldownUntil):
+			until = hs.cooldownUntil
+			wait = until.Sub(now)
+		case hs.maxConns > 0 && hs.inFlight >= hs.maxConns, limit > 0 && hs.inFlight >= limit:
+			wait = hostSlotPollInterval
+		default:
+			hs.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if !until.IsZero() && onWait != nil {
+			onWait(until)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Release returns a connection slot taken by Acquire
+func (l *HostLimiter) Release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hs, ok := l.hosts[host]
+	if !ok {
+		return
+	}
+	if hs.inFlight > 0 {
+		hs.inFlight--
+	}
+	// Forget hosts that have fully recovered
+	if hs.inFlight == 0 && hs.maxConns == 0 && hs.strikes == 0 && time.Now().After(hs.cooldownUntil) {
+		delete(l.hosts, host)
+	}
+}
+
+// Throttle records a rate-limit response from host and returns the end of its cooldown.
+// retryAfter is the server-requested delay; when zero an exponential fallback is used.
+func (l *HostLimiter) Throttle(host string, retryAfter time.Duration) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hs := l.get(host)
+	hs.strikes++
+
+	delay := retryAfter
+	if delay <= 0 {
+		delay = types.RateLimitBaseDelay
+		for i := 1; i < hs.strikes && delay < types.MaxRateLimitDelay; i++ {
+			delay *= 2
+		}
+	}
+	if delay > types.MaxRateLimitDelay {
+		delay = types.MaxRateLimitDelay
+	}
+
+	if until := time.Now().Add(delay); until.After(hs.cooldownUntil) {
+		hs.cooldownUntil = until
+	}
+
+	// Multiplicative decrease of the connection cap
+	current := hs.maxConns
+	if current == 0 {
+		current = hs.inFlight
+		if current > hs.peakConns {
+			hs.peakConns = current
+		}
+	}
+	hs.maxConns = current / 2
+	if hs.maxConns < 1 {
+		hs.maxConns = 1
+	}
+
+	utils.Debug("RateLimit: host %s cooling down for %v (strike %d, max conns %d)", host, delay, hs.strikes, hs.maxConns)
+	return hs.cooldownUntil
+}
+
+// Success records a successful response from host, restoring one connection slot
+func (l *HostLimiter) Success(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hs, ok := l.hosts[host]
+	if !ok {
+		return
+	}
+	hs.strikes = 0
+	if hs.maxConns > 0 {
+		hs.maxConns++
+		if hs.maxConns >= hs.peakConns {
+			// Back to the original connection count: lift the cap
+			hs.maxConns = 0
+			hs.peakConns = 0
+		}
+	}
+}
+
+// MaxConns returns the current connection cap for host (0 = unlimited)
+func (l *HostLimiter) MaxConns(host string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if hs, ok := l.hosts[host]; ok {
+		return hs.maxConns
+	}
+	return 0
+}
+
+// CooldownUntil returns the end of the host's current cooldown (zero if none)
+func (l *HostLimiter) CooldownUntil(host string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if hs, ok := l.hosts[host]; ok && time.Now().Before(hs.cooldownUntil) {
+		return hs.cooldownUntil
+	}
+	return time.Time{}
+}
+
+// ParseRetryAfter parses a Retry-After header value, which is either a number of
+// seconds or an HTTP-date. Returns false if the value is missing or malformed.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		if secs > int64(types.MaxRateLimitDelay/time.Second) {
+			return types.MaxRateLimitDelay, true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+// isRateLimitStatus reports whether a status code asks the client to back off
+func isRateLimitStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
@@ -0,0 +1,274 @@
+package concurrent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"seconds", "42", 42 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"padded", "  7 ", 7 * time.Second, true},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"date in past", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"clamped", "86400", types.MaxRateLimitDelay, true},
+		{"empty", "", 0, false},
+		{"negative", "-5", 0, false},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseRetryAfter(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostLimiter_ThrottleHalvesConnections(t *testing.T) {
+	l := NewHostLimiter()
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		if err := l.Acquire(ctx, "example.com", 0, nil); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+	}
+
+	l.Throttle("example.com", 10*time.Millisecond)
+	if got := l.MaxConns("example.com"); got != 4 {
+		t.Errorf("MaxConns after first throttle = %d, want 4", got)
+	}
+
+	l.Throttle("example.com", 10*time.Millisecond)
+	if got := l.MaxConns("example.com"); got != 2 {
+		t.Errorf("MaxConns after second throttle = %d, want 2", got)
+	}
+
+	// Additive increase until the original connection count is restored
+	for i := 0; i < 5; i++ {
+		l.Success("example.com")
+	}
+	if got := l.MaxConns("example.com"); got != 7 {
+		t.Errorf("MaxConns after 5 successes = %d, want 7", got)
+	}
+	l.Success("example.com")
+	if got := l.MaxConns("example.com"); got != 0 {
+		t.Errorf("MaxConns after recovery = %d, want 0 (unlimited)", got)
+	}
+
+	// Other hosts are unaffected
+	if got := l.MaxConns("other.com"); got != 0 {
+		t.Errorf("MaxConns for unrelated host = %d, want 0", got)
+	}
+}
+
+func TestHostLimiter_FallbackDelayGrows(t *testing.T) {
+	l := NewHostLimiter()
+
+	start := time.Now()
+	first := l.Throttle("example.com", 0).Sub(start)
+	second := l.Throttle("example.com", 0).Sub(start)
+
+	if first < types.RateLimitBaseDelay || first > 2*types.RateLimitBaseDelay {
+		t.Errorf("first fallback cooldown = %v, want ~%v", first, types.RateLimitBaseDelay)
+	}
+	if second < 2*types.RateLimitBaseDelay {
+		t.Errorf("second fallback cooldown = %v, want >= %v", second, 2*types.RateLimitBaseDelay)
+	}
+}
+
+func TestHostLimiter_AcquireWaitsForCooldown(t *testing.T) {
+	l := NewHostLimiter()
+	l.Throttle("example.com", 150*time.Millisecond)
+
+	var reported time.Time
+	start := time.Now()
+	if err := l.Acquire(context.Background(), "example.com", 0, func(until time.Time) { reported = until }); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer l.Release("example.com")
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Acquire returned after %v, expected to wait out the cooldown", elapsed)
+	}
+	if reported.IsZero() {
+		t.Error("onWait was not called with the cooldown end")
+	}
+
+	// A different host is not blocked
+	start = time.Now()
+	if err := l.Acquire(context.Background(), "other.com", 0, nil); err != nil {
+		t.Fatalf("Acquire for other host failed: %v", err)
+	}
+	l.Release("other.com")
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Acquire for unrelated host waited %v", elapsed)
+	}
+}
+
+func TestHostLimiter_AcquireHonorsContext(t *testing.T) {
+	l := NewHostLimiter()
+	l.Throttle("example.com", time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := l.Acquire(ctx, "example.com", 0, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline error, got %v", err)
+	}
+}
+
+func TestHostLimiter_AcquireRespectsLimit(t *testing.T) {
+	l := NewHostLimiter()
+	for i := 0; i < 2; i++ {
+		if err := l.Acquire(context.Background(), "example.com", 2, nil); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx, "example.com", 2, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third Acquire = %v, want it to wait for a free slot", err)
+	}
+
+	// Freeing a slot lets the next request through
+	l.Release("example.com")
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Acquire(ctx, "example.com", 2, nil); err != nil {
+		t.Fatalf("Acquire after Release failed: %v", err)
+	}
+}
+
+func TestConcurrentDownloader_HonorsRetryAfter(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			tmpDir, cleanup := initTestState(t)
+			defer cleanup()
+
+			fileSize := int64(256 * types.KB)
+			data := make([]byte, fileSize)
+			var requests atomic.Int32
+
+			server := testutil.NewMockServerT(t,
+				testutil.WithFileSize(fileSize),
+				testutil.WithRangeSupport(true),
+				testutil.WithHandler(func(w http.ResponseWriter, r *http.Request) {
+					if requests.Add(1) == 1 {
+						w.Header().Set("Retry-After", "1")
+						w.WriteHeader(status)
+						return
+					}
+					http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+				}),
+			)
+			defer server.Close()
+
+			destPath := filepath.Join(tmpDir, "retry_after.bin")
+			state := types.NewProgressState("retry-after", fileSize)
+			runtime := &types.RuntimeConfig{
+				MaxConnectionsPerHost: 1,
+				MaxTaskRetries:        5,
+				MinChunkSize:          64 * types.KB,
+			}
+
+			downloader := NewConcurrentDownloader("retry-after-id", nil, state, runtime)
+			downloader.hostLimiter = NewHostLimiter()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			if err := downloader.Download(ctx, server.URL(), nil, nil, destPath, fileSize, false); err != nil {
+				t.Fatalf("Download failed: %v", err)
+			}
+			elapsed := time.Since(start)
+
+			if elapsed < 900*time.Millisecond {
+				t.Errorf("Download finished in %v, expected to wait for Retry-After (1s)", elapsed)
+			}
+			if err := testutil.VerifyFileSize(destPath, fileSize); err != nil {
+				t.Error(err)
+			}
+			if remaining := state.RateLimitRemaining(); remaining != 0 {
+				t.Errorf("RateLimitRemaining after success = %v, want 0", remaining)
+			}
+		})
+	}
+}
+
+func TestConcurrentDownloader_RateLimitDoesNotUseRetries(t *testing.T) {
+	tmpDir, cleanup := initTestState(t)
+	defer cleanup()
+
+	fileSize := int64(64 * types.KB)
+	data := make([]byte, fileSize)
+	var requests atomic.Int32
+
+	// Rate-limit more times than MaxTaskRetries allows failures
+	server := testutil.NewMockServerT(t,
+		testutil.WithFileSize(fileSize),
+		testutil.WithRangeSupport(true),
+		testutil.WithHandler(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= 2 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+		}),
+	)
+	defer server.Close()
+
+	destPath := filepath.Join(tmpDir, "throttled.bin")
+	state := types.NewProgressState("throttled", fileSize)
+	runtime := &types.RuntimeConfig{
+		MaxConnectionsPerHost: 1,
+		MaxTaskRetries:        2,
+		MinChunkSize:          64 * types.KB,
+	}
+
+	downloader := NewConcurrentDownloader("throttled-id", nil, state, runtime)
+	downloader.hostLimiter = NewHostLimiter()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mirrors := []string{server.URL()}
+	if err := downloader.Download(ctx, server.URL(), mirrors, mirrors, destPath, fileSize, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if err := testutil.VerifyFileSize(destPath, fileSize); err != nil {
+		t.Error(err)
+	}
+	for _, m := range state.GetMirrors() {
+		if m.Error {
+			t.Errorf("Throttled mirror %s marked as errored", m.URL)
+		}
+	}
+}
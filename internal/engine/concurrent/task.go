@@ -12,8 +12,9 @@ import (
 // ActiveTask tracks a task currently being processed by a worker
 type ActiveTask struct {
 	Task          types.Task
-	CurrentOffset int64 // Atomic
-	StopAt        int64 // Atomic
+	Mirror        string // URL the task is being fetched from
+	CurrentOffset int64  // Atomic
+	StopAt        int64  // Atomic
 
 	// Health monitoring fields
 	LastActivity int64              // Atomic: Unix nano timestamp of last data received
@@ -0,0 +1,34 @@
+package concurrent
+
+import (
+	"strings"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// ifRangeValidator returns the value to send in If-Range: the strong ETag when
+// available, otherwise Last-Modified. Weak ETags are not allowed in If-Range (RFC 9110).
+func ifRangeValidator(etag, lastModified string) string {
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return lastModified
+}
+
+// remoteChanged reports whether the resource behind a paused download differs from
+// what the server reports now. Missing validators on either side are treated as unchanged.
+func remoteChanged(saved *types.DownloadState, etag, lastModified string, fileSize int64) bool {
+	if saved == nil {
+		return false
+	}
+	if saved.TotalSize > 0 && fileSize > 0 && saved.TotalSize != fileSize {
+		return true
+	}
+	if saved.ETag != "" && etag != "" {
+		return saved.ETag != etag
+	}
+	if saved.LastModified != "" && lastModified != "" {
+		return saved.LastModified != lastModified
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package concurrent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func TestIfRangeValidator(t *testing.T) {
+	lastMod := "Wed, 21 Oct 2015 07:28:00 GMT"
+
+	if got := ifRangeValidator(`"abc"`, lastMod); got != `"abc"` {
+		t.Errorf("strong etag: got %q", got)
+	}
+	if got := ifRangeValidator(`W/"abc"`, lastMod); got != lastMod {
+		t.Errorf("weak etag should fall back to Last-Modified, got %q", got)
+	}
+	if got := ifRangeValidator("", lastMod); got != lastMod {
+		t.Errorf("no etag: got %q", got)
+	}
+	if got := ifRangeValidator(`W/"abc"`, ""); got != "" {
+		t.Errorf("weak etag without Last-Modified should disable If-Range, got %q", got)
+	}
+}
+
+func TestRemoteChanged(t *testing.T) {
+	saved := &types.DownloadState{TotalSize: 100, ETag: `"v1"`, LastModified: "Mon, 01 Jan 2024 00:00:00 GMT"}
+
+	tests := []struct {
+		name    string
+		saved   *types.DownloadState
+		etag    string
+		lastMod string
+		size    int64
+		want    bool
+	}{
+		{"same etag", saved, `"v1"`, "", 100, false},
+		{"different etag", saved, `"v2"`, saved.LastModified, 100, true},
+		{"size changed", saved, `"v1"`, "", 200, true},
+		{"etag missing now, same last-modified", saved, "", saved.LastModified, 100, false},
+		{"etag missing now, last-modified changed", saved, "", "Tue, 02 Jan 2024 00:00:00 GMT", 100, true},
+		{"no validators saved", &types.DownloadState{TotalSize: 100}, `"v9"`, "", 100, false},
+		{"nil state", nil, `"v1"`, "", 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remoteChanged(tt.saved, tt.etag, tt.lastMod, tt.size); got != tt.want {
+				t.Errorf("remoteChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// etagServer serves data with the given ETag, honoring If-Range via http.ServeContent
+func etagServer(t *testing.T, data []byte, etag string, seen *[]string, mu *sync.Mutex) *testutil.MockServer {
+	return testutil.NewMockServerT(t,
+		testutil.WithFileSize(int64(len(data))),
+		testutil.WithRangeSupport(true),
+		testutil.WithHandler(func(w http.ResponseWriter, r *http.Request) {
+			if seen != nil {
+				mu.Lock()
+				*seen = append(*seen, r.Header.Get("If-Range"))
+				mu.Unlock()
+			}
+			w.Header().Set("ETag", etag)
+			http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+		}),
+	)
+}
+
+func TestConcurrentDownloader_SendsIfRange(t *testing.T) {
+	tmpDir, cleanup := initTestState(t)
+	defer cleanup()
+
+	fileSize := int64(256 * types.KB)
+	data := make([]byte, fileSize)
+
+	var mu sync.Mutex
+	var seen []string
+	server := etagServer(t, data, `"v1"`, &seen, &mu)
+	defer server.Close()
+
+	destPath := filepath.Join(tmpDir, "ifrange.bin")
+	runtime := &types.RuntimeConfig{MaxConnectionsPerHost: 2, MinChunkSize: 64 * types.KB}
+	downloader := NewConcurrentDownloader("ifrange-id", nil, types.NewProgressState("ifrange-id", fileSize), runtime)
+	downloader.ETag = `"v1"`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := downloader.Download(ctx, server.URL(), nil, nil, destPath, fileSize, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) == 0 {
+		t.Fatal("server saw no requests")
+	}
+	for _, v := range seen {
+		if v != `"v1"` {
+			t.Errorf("If-Range = %q, want %q", v, `"v1"`)
+		}
+	}
+}
+
+func TestConcurrentDownloader_FailsWhenRemoteChanges(t *testing.T) {
+	tmpDir, cleanup := initTestState(t)
+	defer cleanup()
+
+	// Large enough to be split across several ranged requests
+	fileSize := int64(4 * types.MB)
+	data := make([]byte, fileSize)
+
+	// Server now serves "v2" while we believe the file is "v1"
+	server := etagServer(t, data, `"v2"`, nil, nil)
+	defer server.Close()
+
+	destPath := filepath.Join(tmpDir, "changed.bin")
+	runtime := &types.RuntimeConfig{MaxConnectionsPerHost: 4, MinChunkSize: 64 * types.KB}
+	downloader := NewConcurrentDownloader("changed-id", nil, types.NewProgressState("changed-id", fileSize), runtime)
+	downloader.ETag = `"v1"`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := downloader.Download(ctx, server.URL(), nil, nil, destPath, fileSize, false)
+	if !errors.Is(err, types.ErrRemoteChanged) {
+		t.Fatalf("expected ErrRemoteChanged, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Error("final file should not exist after remote change")
+	}
+}
+
+func TestConcurrentDownloader_RestartsResumeWhenRemoteChanged(t *testing.T) {
+	tmpDir, cleanup := initTestState(t)
+	defer cleanup()
+
+	fileSize := int64(256 * types.KB)
+	data := make([]byte, fileSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	server := etagServer(t, data, `"v2"`, nil, nil)
+	defer server.Close()
+
+	destPath := filepath.Join(tmpDir, "restart.bin")
+
+	// Simulate a paused download of the old version: first half already on disk (stale bytes)
+	stale := bytes.Repeat([]byte{0xFF}, int(fileSize))
+	if err := os.WriteFile(destPath+types.IncompleteSuffix, stale, 0o644); err != nil {
+		t.Fatalf("failed to write partial file: %v", err)
+	}
+	half := fileSize / 2
+	if err := state.SaveState(server.URL(), destPath, &types.DownloadState{
+		ID:         "restart-id",
+		URL:        server.URL(),
+		DestPath:   destPath,
+		TotalSize:  fileSize,
+		Downloaded: half,
+		Tasks:      []types.Task{{Offset: half, Length: fileSize - half}},
+		Filename:   "restart.bin",
+		ETag:       `"v1"`,
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	runtime := &types.RuntimeConfig{MaxConnectionsPerHost: 2, MinChunkSize: 64 * types.KB}
+	downloader := NewConcurrentDownloader("restart-id", nil, types.NewProgressState("restart-id", fileSize), runtime)
+	downloader.ETag = `"v2"`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := downloader.Download(ctx, server.URL(), nil, nil, destPath, fileSize, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	got, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("failed to read result: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded file mixes old and new content; expected a clean restart")
+	}
+}
@@ -2,6 +2,7 @@ package concurrent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +10,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
 )
@@ -25,6 +28,7 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 
 	// Initial mirror assignment: Round Robin based on ID
 	currentMirrorIdx := id % len(mirrors)
+	firstTask := true
 
 	for {
 		// Get next task
@@ -34,15 +38,29 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 			return nil // Queue closed, no more work
 		}
 
+		// Once throughput has been measured, move towards the mirror that can take another connection best
+		if !firstTask {
+			currentMirrorIdx = d.pickMirror(mirrors, currentMirrorIdx)
+		}
+		firstTask = false
+
 		// Update active workers
 		if d.State != nil {
 			d.State.ActiveWorkers.Add(1)
 		}
 
 		var lastErr error
+		rateLimited := false
 		maxRetries := d.Runtime.GetMaxTaskRetries()
 		for attempt := 0; attempt < maxRetries; attempt++ {
-			if attempt > 0 {
+			if rateLimited {
+				// A throttled mirror is healthy, so it is not reported as failing.
+				// Other mirrors carry on while the host limiter waits out its cooldown.
+				if len(mirrors) > 1 {
+					currentMirrorIdx = d.nextMirror(mirrors, currentMirrorIdx)
+				}
+			} else if attempt > 0 {
+				metrics.Retries.Inc()
 
 				if len(mirrors) == 1 {
 					time.Sleep(time.Duration(1<<attempt) * types.RetryBaseDelay) // Exponential backoff incase of failure
@@ -52,7 +70,7 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 				// Report error for the previous mirror
 				d.ReportMirrorError(mirrors[currentMirrorIdx])
 
-				currentMirrorIdx = (currentMirrorIdx + 1) % len(mirrors)
+				currentMirrorIdx = d.nextMirror(mirrors, currentMirrorIdx)
 				utils.Debug("Worker %d: switching to mirror %s (attempt %d)", id, mirrors[currentMirrorIdx], attempt+1)
 			}
 
@@ -64,6 +82,7 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 			now := time.Now()
 			activeTask := &ActiveTask{
 				Task:          task,
+				Mirror:        currentURL,
 				CurrentOffset: task.Offset,
 				StopAt:        task.Offset + task.Length,
 				LastActivity:  now.UnixNano(),
@@ -84,7 +103,23 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 			}
 
 			taskStart := time.Now()
-			lastErr = d.downloadTask(taskCtx, currentURL, file, activeTask, buf, verbose, client, totalSize)
+			host := hostKey(currentURL)
+			lastErr = d.limiter().Acquire(taskCtx, host, d.Runtime.GetHostConnLimit(currentURL), d.State.ExtendRateLimit)
+			if lastErr == nil {
+				metrics.HostConnections.Inc(host)
+				lastErr = d.downloadTask(taskCtx, currentURL, file, activeTask, buf, verbose, client, totalSize)
+				metrics.HostConnections.Dec(host)
+
+				// Throttle while this connection still counts as in flight, so the
+				// host's cap is scaled down from the real number of connections
+				var rlErr *types.RateLimitError
+				if errors.As(lastErr, &rlErr) {
+					d.State.ExtendRateLimit(d.limiter().Throttle(host, rlErr.RetryAfter))
+				}
+				d.limiter().Release(host)
+			}
+			var rlErr *types.RateLimitError
+			rateLimited = errors.As(lastErr, &rlErr)
 
 			// CRITICAL: Capture external cancellation state BEFORE calling taskCancel()
 			// If we call taskCancel() first, taskCtx.Err() will always be non-nil
@@ -109,8 +144,8 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 				// Health monitor cancelled this task - re-queue REMAINING work only
 
 				// Force rotation to next mirror to avoid getting stuck on the slow one
-				currentMirrorIdx = (currentMirrorIdx + 1) % len(mirrors)
-				utils.Debug("Worker %d: Health check cancelled task, rotating from mirror %s to %s", id, mirrors[(currentMirrorIdx+len(mirrors)-1)%len(mirrors)], mirrors[currentMirrorIdx])
+				currentMirrorIdx = d.nextMirror(mirrors, currentMirrorIdx)
+				utils.Debug("Worker %d: Health check cancelled task, rotating from mirror %s to %s", id, currentURL, mirrors[currentMirrorIdx])
 
 				if remaining := activeTask.RemainingTask(); remaining != nil {
 					// Clamp to original task end (don't go past original boundary)
@@ -139,6 +174,8 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 			d.activeMu.Unlock()
 
 			if lastErr == nil {
+				d.recordMirrorSuccess(currentURL)
+
 				// Check if we stopped early due to stealing
 				stopAt := atomic.LoadInt64(&activeTask.StopAt)
 				current := atomic.LoadInt64(&activeTask.CurrentOffset)
@@ -150,12 +187,25 @@ func (d *ConcurrentDownloader) worker(ctx context.Context, id int, mirrors []str
 				break
 			}
 
+			// The file changed under us: retrying would mix two versions
+			if errors.Is(lastErr, types.ErrRemoteChanged) {
+				if d.State != nil {
+					d.State.ActiveWorkers.Add(-1)
+				}
+				return lastErr
+			}
+
 			// Resume-on-retry: update task to reflect remaining work
 			// This prevents double-counting bytes on retry
 			current := atomic.LoadInt64(&activeTask.CurrentOffset)
 			if current > task.Offset {
 				task = types.Task{Offset: current, Length: task.Offset + task.Length - current}
 			}
+
+			// Waiting out a rate limit does not use up an attempt
+			if rateLimited {
+				attempt--
+			}
 		}
 
 		// Update active workers
@@ -197,6 +247,17 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 	// Range header is always set for partial downloads (overrides any browser Range header)
 	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", task.Offset, task.Offset+task.Length-1))
 
+	// If-Range makes the server send the full (new) entity instead of a range of it when
+	// the file changed. Mirrors may carry different validators, so only the primary gets it.
+	ifRange := ""
+	if rawurl == d.URL {
+		ifRange = ifRangeValidator(d.ETag, d.LastModified)
+	}
+	if ifRange != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
+
+	requestStart := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -206,10 +267,14 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 			utils.Debug("Error closing response body: %v", err)
 		}
 	}()
+	if d.State != nil {
+		d.State.ObserveMirrorLatency(rawurl, time.Since(requestStart))
+	}
 
-	// Handle rate limiting explicitly
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return fmt.Errorf("rate limited (429)")
+	// Handle rate limiting explicitly: the worker backs off the whole host
+	if isRateLimitStatus(resp.StatusCode) {
+		retryAfter, _ := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return &types.RateLimitError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
 	}
 
 	// Validate status code
@@ -217,12 +282,20 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 		// Valid only if we requested the full file
 		// If we wanted a partial range but got the whole file (200), that's an error because we can't handle the full stream at a non-zero offset
 		if task.Offset != 0 || task.Length != totalSize {
+			if ifRange != "" {
+				return fmt.Errorf("%w: server returned 200 for If-Range %s", types.ErrRemoteChanged, ifRange)
+			}
 			return fmt.Errorf("server indicated success (200) but ignored range request (expected 206)")
 		}
 	} else if resp.StatusCode != http.StatusPartialContent {
 		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
 
+	d.limiter().Success(hostKey(rawurl))
+	if d.State != nil {
+		d.State.SetRateLimitedUntil(time.Time{})
+	}
+
 	// Batching State
 	var pendingBytes int64
 	var pendingStart int64 = -1
@@ -238,6 +311,7 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 
 			// Update Downloaded Counter (Atomic)
 			d.State.Downloaded.Add(pendingBytes)
+			d.State.AddMirrorBytes(rawurl, pendingBytes)
 
 			pendingBytes = 0
 			pendingStart = -1
@@ -271,6 +345,12 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 			readSize = remaining
 		}
 
+		// Read in smaller pieces while throttled so workers share the cap evenly
+		limiters := d.State.Limiters()
+		if throttled := int64(bandwidth.ReadSize(limiters...)); throttled > 0 && readSize > throttled {
+			readSize = throttled
+		}
+
 		readSoFar := 0
 		var readErr error
 
@@ -278,6 +358,13 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 			n, err := resp.Body.Read(buf[readSoFar:readSize])
 			if n > 0 {
 				readSoFar += n
+				metrics.AddBytes(n)
+				if waitErr := bandwidth.Wait(ctx, n, limiters...); waitErr != nil {
+					readErr = waitErr
+					break
+				}
+				// Time spent waiting on a speed cap is not a stall
+				atomic.StoreInt64(&activeTask.LastActivity, time.Now().UnixNano())
 			}
 			if err != nil {
 				readErr = err
@@ -363,19 +450,41 @@ func (d *ConcurrentDownloader) downloadTask(ctx context.Context, rawurl string,
 }
 
 // StealWork tries to split an active task from a busy worker
-// It greedily targets the worker with the MOST remaining work.
+// It targets the task that will take LONGEST to finish, so a large range stuck on a
+// slow mirror is split first and the stolen half goes to an idle worker, which picks
+// the fastest available mirror.
 func (d *ConcurrentDownloader) StealWork(queue *TaskQueue) bool {
 	d.activeMu.Lock()
 	defer d.activeMu.Unlock()
 
+	// Tasks without any speed information are ranked by size alone
+	var speedSum float64
+	var speedCount int
+	for _, active := range d.activeTasks {
+		if speed := active.GetSpeed(); speed > 0 {
+			speedSum += speed
+			speedCount++
+		}
+	}
+	fallback := 1.0
+	if speedCount > 0 {
+		fallback = speedSum / float64(speedCount)
+	}
+
 	bestID := -1
 	var maxRemaining int64 = 0
+	var maxFinish float64
 	var bestActive *ActiveTask
 
-	// Find the worker with the MOST remaining work
+	// Find the worker with the longest time to finish
 	for id, active := range d.activeTasks {
 		remaining := active.RemainingBytes()
-		if remaining > types.MinChunk && remaining > maxRemaining {
+		if remaining <= types.MinChunk {
+			continue
+		}
+		finish := d.estimatedFinish(active, remaining, fallback)
+		if bestID == -1 || finish > maxFinish {
+			maxFinish = finish
 			maxRemaining = remaining
 			bestID = id
 			bestActive = active
@@ -0,0 +1,310 @@
+package cookies
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/utils"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Jar is a cookie jar keyed by domain, saved as a Netscape cookies.txt file so
+// that cookies imported from a browser or set by servers survive restarts.
+// Unlike net/http/cookiejar it keeps session cookies, which is what most
+// exported logins are.
+type Jar struct {
+	mu      sync.Mutex
+	path    string                       // File the jar is saved to ("" = memory only)
+	domains map[string]map[string]Cookie // Domain -> path + "\t" + name -> cookie
+	loaded  bool
+}
+
+// New returns an empty jar saved to path, or kept in memory when path is empty.
+// An existing file at path is read on first use.
+func New(path string) *Jar {
+	return &Jar{path: path, domains: make(map[string]map[string]Cookie)}
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = New("")
+)
+
+// Configure makes the jar shared by all downloads persist to path
+func Configure(path string) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	shared = New(path)
+}
+
+// Shared returns the jar used by all downloads
+func Shared() *Jar {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	return shared
+}
+
+// load reads the jar's file once. Caller must hold j.mu.
+func (j *Jar) load() {
+	if j.loaded {
+		return
+	}
+	j.loaded = true
+	if j.path == "" {
+		return
+	}
+	f, err := os.Open(j.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			utils.Debug("Cookies: failed to open %s: %v", j.path, err)
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+	cookies, err := Parse(f)
+	if err != nil {
+		utils.Debug("Cookies: ignoring %s: %v", j.path, err)
+		return
+	}
+	for _, c := range cookies {
+		j.put(c)
+	}
+}
+
+// put stores c, replacing a cookie of the same domain, path and name. Caller must hold j.mu.
+func (j *Jar) put(c Cookie) bool {
+	m, ok := j.domains[c.Domain]
+	if !ok {
+		m = make(map[string]Cookie)
+		j.domains[c.Domain] = m
+	}
+	key := c.Path + "\t" + c.Name
+	if old, ok := m[key]; ok && old == c {
+		return false
+	}
+	m[key] = c
+	return true
+}
+
+// remove deletes a cookie. Caller must hold j.mu.
+func (j *Jar) remove(domain, path, name string) bool {
+	m, ok := j.domains[domain]
+	if !ok {
+		return false
+	}
+	key := path + "\t" + name
+	if _, ok := m[key]; !ok {
+		return false
+	}
+	delete(m, key)
+	if len(m) == 0 {
+		delete(j.domains, domain)
+	}
+	return true
+}
+
+// Import adds cookies to the jar, replacing ones with the same domain, path and
+// name, and saves it
+func (j *Jar) Import(cookies []Cookie) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.load()
+	for _, c := range cookies {
+		j.put(c)
+	}
+	return j.save()
+}
+
+// all lists unexpired cookies ordered by domain, path and name. Caller must hold j.mu.
+func (j *Jar) all(now time.Time) []Cookie {
+	var out []Cookie
+	for _, m := range j.domains {
+		for _, c := range m {
+			if c.Expires.IsZero() || c.Expires.After(now) {
+				out = append(out, c)
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b Cookie) int {
+		return strings.Compare(a.Domain+"\t"+a.Path+"\t"+a.Name, b.Domain+"\t"+b.Path+"\t"+b.Name)
+	})
+	return out
+}
+
+// save writes the jar to its file. Caller must hold j.mu.
+func (j *Jar) save() error {
+	if j.path == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, j.all(time.Now())); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	// Cookies are credentials: keep them private to the user
+	tempPath := j.path + ".tmp"
+	if err := os.WriteFile(tempPath, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to save cookies: %w", err)
+	}
+	return os.Rename(tempPath, j.path)
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return nil
+	}
+	reqPath := u.EscapedPath()
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.load()
+
+	var matched []Cookie
+	for domain := host; ; {
+		for _, c := range j.domains[domain] {
+			if c.HostOnly && domain != host {
+				continue
+			}
+			if (c.Secure && !secure) || !pathMatch(reqPath, c.Path) {
+				continue
+			}
+			if !c.Expires.IsZero() && !c.Expires.After(now) {
+				continue
+			}
+			matched = append(matched, c)
+		}
+		// Walk up the parent domains; IP addresses have none
+		i := strings.IndexByte(domain, '.')
+		if i < 0 || net.ParseIP(host) != nil {
+			break
+		}
+		domain = domain[i+1:]
+	}
+
+	// Longer paths first, as browsers send them
+	slices.SortFunc(matched, func(a, b Cookie) int {
+		if n := len(b.Path) - len(a.Path); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	out := make([]*http.Cookie, 0, len(matched))
+	for _, c := range matched {
+		out = append(out, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return out
+}
+
+// SetCookies implements http.CookieJar, saving the jar when a cookie changed
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.load()
+
+	changed := false
+	for _, hc := range cookies {
+		c, ok := fromHTTP(hc, host, u.EscapedPath(), now)
+		if !ok {
+			continue
+		}
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			// Servers delete cookies by expiring them
+			changed = j.remove(c.Domain, c.Path, c.Name) || changed
+			continue
+		}
+		changed = j.put(c) || changed
+	}
+	if changed {
+		if err := j.save(); err != nil {
+			utils.Debug("Cookies: %v", err)
+		}
+	}
+}
+
+// fromHTTP converts a Set-Cookie received from host, rejecting cookies for
+// domains the host may not set
+func fromHTTP(hc *http.Cookie, host, reqPath string, now time.Time) (Cookie, bool) {
+	c := Cookie{
+		Domain:   host,
+		HostOnly: true,
+		Path:     hc.Path,
+		Secure:   hc.Secure,
+		HTTPOnly: hc.HttpOnly,
+		Name:     hc.Name,
+		Value:    hc.Value,
+	}
+	if c.Name == "" {
+		return c, false
+	}
+
+	d := strings.ToLower(strings.TrimPrefix(hc.Domain, "."))
+	if ps, _ := publicsuffix.PublicSuffix(d); d != "" && ps == d && net.ParseIP(d) == nil {
+		// A public suffix such as co.uk or github.io would reach every site below it.
+		// As in net/http/cookiejar, only a host that is one itself may set it, host-only.
+		if d != host {
+			return c, false
+		}
+	} else if d != "" && d != host {
+		// A domain cookie must cover the host and not be a bare TLD
+		if !strings.HasSuffix(host, "."+d) || !strings.Contains(d, ".") || net.ParseIP(host) != nil {
+			return c, false
+		}
+		c.Domain = d
+		c.HostOnly = false
+	} else if d != "" {
+		c.HostOnly = false
+	}
+
+	if !strings.HasPrefix(c.Path, "/") {
+		// Default path: the directory of the request path
+		c.Path = "/"
+		if i := strings.LastIndexByte(reqPath, '/'); i > 0 {
+			c.Path = reqPath[:i]
+		}
+	}
+
+	switch {
+	case hc.MaxAge < 0:
+		c.Expires = now.Add(-time.Second)
+	case hc.MaxAge > 0:
+		c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+	case !hc.Expires.IsZero():
+		c.Expires = hc.Expires
+	}
+	return c, true
+}
+
+// pathMatch implements the cookie path-match of RFC 6265 section 5.1.4
+func pathMatch(reqPath, cookiePath string) bool {
+	if reqPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(reqPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/'
+}
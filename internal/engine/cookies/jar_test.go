@@ -0,0 +1,167 @@
+package cookies
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// names returns the cookies the jar sends to rawURL as "name=value" pairs
+func names(j *Jar, rawURL string) string {
+	u, _ := url.Parse(rawURL)
+	var out []string
+	for _, c := range j.Cookies(u) {
+		out = append(out, c.Name+"="+c.Value)
+	}
+	return strings.Join(out, "; ")
+}
+
+func TestJar_Cookies(t *testing.T) {
+	j := New("")
+	if err := j.Import([]Cookie{
+		{Domain: "example.com", Path: "/", Name: "all", Value: "1"},
+		{Domain: "example.com", HostOnly: true, Path: "/", Name: "host", Value: "2"},
+		{Domain: "example.com", Path: "/docs", Name: "docs", Value: "3"},
+		{Domain: "example.com", Path: "/", Secure: true, Name: "secure", Value: "4"},
+		{Domain: "example.com", Path: "/", Expires: time.Now().Add(-time.Hour), Name: "expired", Value: "5"},
+	}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/file", "all=1; host=2; secure=4"},
+		{"http://example.com/file", "all=1; host=2"},
+		{"https://dl.example.com/docs/a.pdf", "docs=3; all=1; secure=4"},
+		{"http://example.com/docsearch", "all=1; host=2"},
+		{"http://notexample.com/", ""},
+	}
+	for _, tt := range tests {
+		if got := names(j, tt.url); got != tt.want {
+			t.Errorf("Cookies(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestJar_SetCookies(t *testing.T) {
+	j := New("")
+	u, _ := url.Parse("https://dl.example.com/files/a.zip")
+
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "parent", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "tld", Value: "3", Domain: "com"},
+		{Name: "other", Value: "4", Domain: "evil.example"},
+	})
+
+	if got := names(j, "https://dl.example.com/files/b.zip"); got != "host=1; parent=2" {
+		t.Errorf("same host = %q, want host=1; parent=2", got)
+	}
+	if got := names(j, "https://www.example.com/"); got != "parent=2" {
+		t.Errorf("sibling host = %q, want parent=2", got)
+	}
+	if got := names(j, "https://dl.example.com/other"); got != "parent=2" {
+		t.Errorf("outside default path = %q, want parent=2", got)
+	}
+	if got := names(j, "https://evil.example/"); got != "" {
+		t.Errorf("foreign domain accepted: %q", got)
+	}
+
+	// Servers delete cookies by expiring them
+	j.SetCookies(u, []*http.Cookie{{Name: "parent", Domain: "example.com", Path: "/", MaxAge: -1}})
+	if got := names(j, "https://www.example.com/"); got != "" {
+		t.Errorf("deleted cookie still sent: %q", got)
+	}
+}
+
+func TestJar_RejectsPublicSuffixes(t *testing.T) {
+	j := New("")
+	u, _ := url.Parse("https://evil.github.io/")
+	j.SetCookies(u, []*http.Cookie{{Name: "super", Value: "1", Domain: "github.io", Path: "/"}})
+	u, _ = url.Parse("https://shop.example.co.uk/")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "super", Value: "2", Domain: ".co.uk", Path: "/"},
+		{Name: "site", Value: "3", Domain: "example.co.uk", Path: "/"},
+	})
+
+	if got := names(j, "https://victim.github.io/"); got != "" {
+		t.Errorf("cookie for github.io reached another site: %q", got)
+	}
+	if got := names(j, "https://other.co.uk/"); got != "" {
+		t.Errorf("cookie for co.uk reached another site: %q", got)
+	}
+	if got := names(j, "https://www.example.co.uk/"); got != "site=3" {
+		t.Errorf("registrable domain = %q, want site=3", got)
+	}
+
+	// A host that is itself a public suffix keeps its cookie to itself
+	u, _ = url.Parse("https://github.io/")
+	j.SetCookies(u, []*http.Cookie{{Name: "own", Value: "4", Domain: "github.io", Path: "/"}})
+	if got := names(j, "https://github.io/"); got != "own=4" {
+		t.Errorf("public suffix host = %q, want own=4", got)
+	}
+	if got := names(j, "https://victim.github.io/"); got != "" {
+		t.Errorf("public suffix host cookie leaked to %q", got)
+	}
+}
+
+func TestJar_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	u, _ := url.Parse("https://example.com/")
+
+	j := New(path)
+	j.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("jar not saved: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("cookie file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if got := names(New(path), "https://example.com/"); got != "session=abc" {
+		t.Errorf("reloaded jar sends %q, want session=abc", got)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	j := New("")
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("login"); err != nil || c.Value != "ok" {
+			http.Error(w, "no login cookie", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer final.Close()
+
+	// The login cookie is set by a redirect, as sign-in pages do. Cookies ignore
+	// ports, so the two test servers share them.
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "login", Value: "ok", Path: "/"})
+		http.Redirect(w, r, final.URL+"/file", http.StatusFound)
+	}))
+	defer start.Close()
+
+	client := &http.Client{Transport: &transport{base: http.DefaultTransport, jar: j}}
+	req, _ := http.NewRequest(http.MethodGet, start.URL+"/start", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with the cookie from the redirect", resp.StatusCode)
+	}
+	if req.Header.Get("Cookie") != "" {
+		t.Errorf("caller's request was modified: %q", req.Header.Get("Cookie"))
+	}
+}
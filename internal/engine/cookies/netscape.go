@@ -0,0 +1,111 @@
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in files exported by browsers
+const httpOnlyPrefix = "#HttpOnly_"
+
+// Cookie is one line of a Netscape cookies.txt file
+type Cookie struct {
+	Domain   string    // Lower-case, without a leading dot
+	HostOnly bool      // Sent to Domain only, not to its subdomains
+	Path     string    // Path prefix the cookie applies to
+	Secure   bool      // Only sent over HTTPS
+	HTTPOnly bool      // Kept for round-tripping; downloads are not scripts
+	Expires  time.Time // Zero for session cookies
+	Name     string
+	Value    string
+}
+
+// Parse reads cookies in the Netscape cookies.txt format used by curl, wget and
+// browser export extensions: seven tab-separated fields per line (domain,
+// include subdomains, path, secure, expiry, name, value).
+func Parse(r io.Reader) ([]Cookie, error) {
+	var cookies []Cookie
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, httpOnlyPrefix); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "") // Some exporters drop the tab of an empty value
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", n, len(fields))
+		}
+		domain := strings.ToLower(strings.TrimSpace(fields[0]))
+		if strings.Trim(domain, ".") == "" {
+			return nil, fmt.Errorf("line %d: empty domain", n)
+		}
+		expiry, err := strconv.ParseInt(strings.TrimSpace(fields[4]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", n, fields[4])
+		}
+
+		c := Cookie{
+			Domain:   strings.TrimPrefix(domain, "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(domain, "."),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// Write writes cookies in the Netscape cookies.txt format
+func Write(w io.Writer, cookies []Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	fmt.Fprintln(bw, "# Written by Surge. Lines are: domain, include subdomains, path, secure, expiry, name, value.")
+	for _, c := range cookies {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HTTPOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expiry int64
+		if !c.Expires.IsZero() {
+			expiry = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, boolField(!c.HostOnly), c.Path, boolField(c.Secure), expiry, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+func boolField(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
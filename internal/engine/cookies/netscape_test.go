@@ -0,0 +1,83 @@
+package cookies
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleFile = `# Netscape HTTP Cookie File
+# https://curl.se/docs/http-cookies.html
+
+.example.com	TRUE	/	FALSE	2000000000	session	abc123
+files.example.com	FALSE	/private	TRUE	0	token	s3cr3t
+#HttpOnly_.corp.example	TRUE	/	TRUE	2000000000	sid	x=y
+legacy.example	FALSE	/	FALSE	0	empty
+`
+
+func TestParse(t *testing.T) {
+	got, err := Parse(strings.NewReader(sampleFile))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Cookie{
+		{Domain: "example.com", Path: "/", Expires: time.Unix(2000000000, 0), Name: "session", Value: "abc123"},
+		{Domain: "files.example.com", HostOnly: true, Path: "/private", Secure: true, Name: "token", Value: "s3cr3t"},
+		{Domain: "corp.example", Path: "/", Secure: true, HTTPOnly: true, Expires: time.Unix(2000000000, 0), Name: "sid", Value: "x=y"},
+		{Domain: "legacy.example", HostOnly: true, Path: "/", Name: "empty"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Parse returned %d cookies, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Expires.Equal(want[i].Expires) {
+			t.Errorf("cookie %d expires %v, want %v", i, got[i].Expires, want[i].Expires)
+		}
+		got[i].Expires, want[i].Expires = time.Time{}, time.Time{}
+		if got[i] != want[i] {
+			t.Errorf("cookie %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Too few fields", "example.com\tTRUE\t/\n"},
+		{"Spaces instead of tabs", "example.com TRUE / FALSE 0 name value\n"},
+		{"Bad expiry", "example.com\tTRUE\t/\tFALSE\tsoon\tname\tvalue\n"},
+		{"Empty domain", ".\tTRUE\t/\tFALSE\t0\tname\tvalue\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.input)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestWrite_RoundTrip(t *testing.T) {
+	cookies, err := Parse(strings.NewReader(sampleFile))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, cookies); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	again, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse of written file failed: %v\n%s", err, buf.String())
+	}
+	if !slices.EqualFunc(cookies, again, func(a, b Cookie) bool {
+		return a.Expires.Equal(b.Expires) && a.Domain == b.Domain && a.HostOnly == b.HostOnly &&
+			a.Path == b.Path && a.Secure == b.Secure && a.HTTPOnly == b.HTTPOnly && a.Name == b.Name && a.Value == b.Value
+	}) {
+		t.Errorf("round trip = %+v, want %+v", again, cookies)
+	}
+}
@@ -0,0 +1,37 @@
+package cookies
+
+import "net/http"
+
+// transport sends the jar's cookies with every request and stores the cookies
+// of every response, redirects included. Unlike http.Client.Jar it leaves the
+// caller's request untouched, so redirect handlers that copy the original
+// headers to another host do not forward the jar's cookies with them.
+type transport struct {
+	base http.RoundTripper
+	jar  *Jar
+}
+
+// Transport wraps base (nil = http.DefaultTransport) to use the shared jar
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, jar: Shared()}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if cookies := t.jar.Cookies(req.URL); len(cookies) > 0 {
+		req = req.Clone(req.Context())
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		t.jar.SetCookies(req.URL, cookies)
+	}
+	return resp, nil
+}
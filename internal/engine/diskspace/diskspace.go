@@ -0,0 +1,72 @@
+// Package diskspace reports free space on the filesystem holding a download and
+// reserves room for files before they are written.
+package diskspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Free returns the bytes available to unprivileged users on the filesystem
+// holding path. path need not exist yet; its nearest existing parent is used.
+func Free(path string) (int64, error) {
+	dir, err := existing(path)
+	if err != nil {
+		return 0, err
+	}
+	return free(dir)
+}
+
+// Volume identifies the filesystem holding path, so callers can tell whether two
+// paths share free space
+func Volume(path string) (string, error) {
+	dir, err := existing(path)
+	if err != nil {
+		return "", err
+	}
+	return volume(dir)
+}
+
+// Allocated returns the bytes a file occupies on disk, which for sparse or
+// preallocated files differs from its size. A missing file occupies nothing.
+func Allocated(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return allocated(info), nil
+}
+
+// Allocate sizes f to size bytes for a fresh download. With preallocate the
+// space is reserved on disk first, so later writes cannot run out of it;
+// otherwise the file is left sparse.
+func Allocate(f *os.File, size int64, preallocate bool) error {
+	if preallocate {
+		if err := Preallocate(f, size); err != nil {
+			return err
+		}
+	}
+	return f.Truncate(size)
+}
+
+// existing returns path or its nearest ancestor that exists
+func existing(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", os.ErrNotExist
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package diskspace
+
+import (
+	"errors"
+	"os"
+)
+
+func free(string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func volume(string) (string, error) {
+	return "", errors.ErrUnsupported
+}
+
+func allocated(info os.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,107 @@
+package diskspace
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFree_MissingPathUsesParent(t *testing.T) {
+	dir := t.TempDir()
+
+	got, err := Free(filepath.Join(dir, "not", "yet", "created"))
+	if err != nil {
+		t.Fatalf("Free failed: %v", err)
+	}
+	if got <= 0 {
+		t.Errorf("Free = %d, want > 0", got)
+	}
+}
+
+func TestVolume_SameFilesystem(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := Volume(dir)
+	if err != nil {
+		t.Fatalf("Volume failed: %v", err)
+	}
+	b, err := Volume(filepath.Join(dir, "child.bin"))
+	if err != nil {
+		t.Fatalf("Volume failed: %v", err)
+	}
+	if a != b {
+		t.Errorf("Volume differs within one directory: %q vs %q", a, b)
+	}
+}
+
+func TestAllocated(t *testing.T) {
+	dir := t.TempDir()
+
+	if got, err := Allocated(filepath.Join(dir, "missing")); err != nil || got != 0 {
+		t.Errorf("Allocated(missing) = %d, %v; want 0, nil", got, err)
+	}
+
+	// A sparse file has a size but occupies (almost) nothing
+	path := filepath.Join(dir, "sparse.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := f.Truncate(64 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	got, err := Allocated(path)
+	if err != nil {
+		t.Fatalf("Allocated failed: %v", err)
+	}
+	if got >= 64<<20 {
+		t.Errorf("Allocated(sparse) = %d, want less than its size", got)
+	}
+
+	if err := Preallocate(f, 64<<20); err != nil {
+		t.Fatalf("Preallocate failed: %v", err)
+	}
+	if got, _ = Allocated(path); got < 64<<20 {
+		t.Skipf("Allocated after Preallocate = %d; this filesystem does not support fallocate", got)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	dir := t.TempDir()
+	const size = 16 << 20
+
+	for _, preallocate := range []bool{false, true} {
+		path := filepath.Join(dir, "file.bin")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Allocate(f, size, preallocate); err != nil {
+			t.Fatalf("Allocate(preallocate=%v) failed: %v", preallocate, err)
+		}
+		_ = f.Close()
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != size {
+			t.Errorf("size after Allocate(preallocate=%v) = %d, want %d", preallocate, info.Size(), size)
+		}
+		if runtime.GOOS == "linux" {
+			got, _ := Allocated(path)
+			if !preallocate && got >= size {
+				t.Errorf("Allocate without preallocate used %d bytes, want a sparse file", got)
+			}
+			if preallocate && got < size {
+				t.Logf("Allocated after preallocating = %d; this filesystem does not support fallocate", got)
+			}
+		}
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build linux || darwin || freebsd
+
+package diskspace
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+func free(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+func volume(path string) (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(uint64(st.Dev), 10), nil
+}
+
+func allocated(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		// st_blocks is always counted in 512-byte units
+		return int64(st.Blocks) * 512
+	}
+	return info.Size()
+}
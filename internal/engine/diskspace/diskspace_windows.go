@@ -0,0 +1,31 @@
+package diskspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func free(path string) (int64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if ok, _, callErr := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&available)), 0, 0); ok == 0 {
+		return 0, callErr
+	}
+	return int64(available), nil
+}
+
+func volume(path string) (string, error) {
+	return strings.ToUpper(filepath.VolumeName(path)), nil
+}
+
+func allocated(info os.FileInfo) int64 {
+	return info.Size()
+}
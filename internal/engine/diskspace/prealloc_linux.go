@@ -0,0 +1,20 @@
+package diskspace
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Preallocate reserves size bytes for f so later writes cannot run out of space.
+// Filesystems without fallocate support are left as they are.
+func Preallocate(f *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package diskspace
+
+import "os"
+
+// Preallocate reserves size bytes for f so later writes cannot run out of space.
+// Only Linux supports it; elsewhere the file is left as it is.
+func Preallocate(f *os.File, size int64) error {
+	return nil
+}
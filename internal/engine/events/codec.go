@@ -0,0 +1,110 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Wire names of the events streamed to remote clients over SSE and WebSocket
+var eventNames = map[string]func() any{
+	"started":          func() any { return &DownloadStartedMsg{} },
+	"complete":         func() any { return &DownloadCompleteMsg{} },
+	"error":            func() any { return &DownloadErrorMsg{} },
+	"progress":         func() any { return &ProgressMsg{} },
+	"paused":           func() any { return &DownloadPausedMsg{} },
+	"resumed":          func() any { return &DownloadResumedMsg{} },
+	"queued":           func() any { return &DownloadQueuedMsg{} },
+	"scheduled":        func() any { return &DownloadScheduledMsg{} },
+	"hook":             func() any { return &HookResultMsg{} },
+	"extract_progress": func() any { return &ExtractProgressMsg{} },
+	"extracted":        func() any { return &ExtractCompleteMsg{} },
+	"extract_error":    func() any { return &ExtractErrorMsg{} },
+	"removed":          func() any { return &DownloadRemovedMsg{} },
+	"request":          func() any { return &DownloadRequestMsg{} },
+}
+
+// Name returns the wire name of an event, or "unknown"
+func Name(msg any) string {
+	switch msg.(type) {
+	case DownloadStartedMsg:
+		return "started"
+	case DownloadCompleteMsg:
+		return "complete"
+	case DownloadErrorMsg:
+		return "error"
+	case ProgressMsg:
+		return "progress"
+	case DownloadPausedMsg:
+		return "paused"
+	case DownloadResumedMsg:
+		return "resumed"
+	case DownloadQueuedMsg:
+		return "queued"
+	case DownloadScheduledMsg:
+		return "scheduled"
+	case HookResultMsg:
+		return "hook"
+	case ExtractProgressMsg:
+		return "extract_progress"
+	case ExtractCompleteMsg:
+		return "extracted"
+	case ExtractErrorMsg:
+		return "extract_error"
+	case DownloadRemovedMsg:
+		return "removed"
+	case DownloadRequestMsg:
+		return "request"
+	}
+	return "unknown"
+}
+
+// Decode rebuilds an event from its wire name and JSON encoding
+func Decode(name string, data []byte) (any, error) {
+	newMsg, ok := eventNames[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", name)
+	}
+	ptr := newMsg()
+	if err := json.Unmarshal(data, ptr); err != nil {
+		return nil, err
+	}
+	// Events travel by value
+	return reflect.ValueOf(ptr).Elem().Interface(), nil
+}
+
+// DownloadID returns the id of the download an event is about, or "" when the
+// event is not tied to one
+func DownloadID(msg any) string {
+	switch m := msg.(type) {
+	case DownloadStartedMsg:
+		return m.DownloadID
+	case DownloadCompleteMsg:
+		return m.DownloadID
+	case DownloadErrorMsg:
+		return m.DownloadID
+	case ProgressMsg:
+		return m.DownloadID
+	case DownloadPausedMsg:
+		return m.DownloadID
+	case DownloadResumedMsg:
+		return m.DownloadID
+	case DownloadQueuedMsg:
+		return m.DownloadID
+	case DownloadScheduledMsg:
+		return m.DownloadID
+	case HookResultMsg:
+		return m.DownloadID
+	case ExtractProgressMsg:
+		return m.DownloadID
+	case ExtractCompleteMsg:
+		return m.DownloadID
+	case ExtractErrorMsg:
+		return m.DownloadID
+	case DownloadRemovedMsg:
+		return m.DownloadID
+	case DownloadRequestMsg:
+		return m.ID
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	msgs := []any{
+		DownloadStartedMsg{DownloadID: "a", Filename: "a.iso", Total: 100},
+		ProgressMsg{DownloadID: "a", Downloaded: 50, Total: 100, Speed: 12.5},
+		DownloadPausedMsg{DownloadID: "a", Downloaded: 50},
+		DownloadRemovedMsg{DownloadID: "a"},
+		DownloadRequestMsg{ID: "r", URL: "https://example.com/a.iso"},
+	}
+	for _, msg := range msgs {
+		name := Name(msg)
+		t.Run(name, func(t *testing.T) {
+			if name == "unknown" {
+				t.Fatalf("No name for %T", msg)
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(name, data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, msg) {
+				t.Errorf("Decode = %#v, want %#v", got, msg)
+			}
+			if DownloadID(got) == "" {
+				t.Errorf("DownloadID(%T) is empty", got)
+			}
+		})
+	}
+}
+
+func TestCodec_EveryNameDecodes(t *testing.T) {
+	for name, newMsg := range eventNames {
+		msg, err := Decode(name, []byte("{}"))
+		if err != nil {
+			t.Errorf("Decode(%q) failed: %v", name, err)
+			continue
+		}
+		if Name(msg) != name {
+			t.Errorf("Name(%T) = %q, want %q", msg, Name(msg), name)
+		}
+		if reflect.TypeOf(msg) != reflect.TypeOf(newMsg()).Elem() {
+			t.Errorf("Decode(%q) returned %T", name, msg)
+		}
+	}
+}
+
+func TestCodec_Unknown(t *testing.T) {
+	if _, err := Decode("nope", []byte("{}")); err == nil {
+		t.Error("Decode of an unknown event succeeded")
+	}
+	if Name(struct{}{}) != "unknown" || DownloadID(struct{}{}) != "" {
+		t.Error("Unknown messages should have no name or download")
+	}
+}
@@ -20,6 +20,10 @@ type ProgressMsg struct {
 	BitmapWidth       int
 	ActualChunkSize   int64
 	ChunkProgress     []int64
+	RateLimitedFor    time.Duration // Remaining host cooldown after a 429/503 (0 = not rate limited)
+	SpeedLimit        int64         // Per-download bandwidth cap in bytes per second (0 = unlimited)
+
+	Mirrors []types.MirrorStatus // Per-mirror throughput, latency and errors (nil for single-source downloads)
 }
 
 // DownloadCompleteMsg signals that the download finished successfully
@@ -97,6 +101,7 @@ type DownloadStartedMsg struct {
 	Filename   string
 	Total      int64
 	DestPath   string               // Full path to the destination file
+	Category   string               // Download category from settings, if any
 	State      *types.ProgressState `json:"-"`
 }
 
@@ -104,6 +109,7 @@ type DownloadPausedMsg struct {
 	DownloadID string
 	Filename   string
 	Downloaded int64
+	Reason     string // Why Surge paused it on its own ("" = paused by request)
 }
 
 type DownloadResumedMsg struct {
@@ -116,6 +122,49 @@ type DownloadQueuedMsg struct {
 	Filename   string
 }
 
+// DownloadScheduledMsg is sent when a download is held until its start time or active window
+type DownloadScheduledMsg struct {
+	DownloadID string
+	Filename   string
+	StartAt    time.Time // When the download becomes eligible to run
+}
+
+// HookResultMsg reports the outcome of a post-download hook
+type HookResultMsg struct {
+	DownloadID string
+	Filename   string
+	Hook       string // Hook name, command or URL
+	Event      string // "complete" or "error"
+	Attempts   int
+	ExitCode   int    // Process exit status, or HTTP status for webhooks
+	Err        string // Empty on success
+}
+
+// ExtractProgressMsg reports progress while a completed archive is being unpacked
+type ExtractProgressMsg struct {
+	DownloadID string
+	Filename   string
+	Done       int64 // Bytes processed
+	Total      int64
+	Files      int // Entries written so far
+}
+
+// ExtractCompleteMsg signals that a completed archive was unpacked
+type ExtractCompleteMsg struct {
+	DownloadID     string
+	Filename       string
+	Dir            string // Directory the archive was extracted into
+	Files          int
+	ArchiveDeleted bool
+}
+
+// ExtractErrorMsg signals that unpacking a completed archive failed
+type ExtractErrorMsg struct {
+	DownloadID string
+	Filename   string
+	Err        string
+}
+
 type DownloadRemovedMsg struct {
 	DownloadID string
 	Filename   string
@@ -130,4 +179,15 @@ type DownloadRequestMsg struct {
 	Path     string
 	Mirrors  []string
 	Headers  map[string]string
+	Checksum string
+	StartAt  time.Time
+	Window   string
+	Extract  string // Extract mode on completion ("keep", "delete", "off"); empty = global setting
+	Variant  string // HLS/DASH variant selector ("best", "worst", "720p"); empty = best
+	Priority int    // Queue priority; higher runs first (0 = normal)
+	Category string // Category from settings; empty = match automatically
+
+	DefaultDir   bool  // Path is the default directory, which a category's directory replaces
+	SpeedLimit   int64 // Per-download bandwidth cap in bytes per second (0 = unlimited)
+	ExpectedSize int64 // Size announced by the source (e.g. a Metalink); 0 = unknown
 }
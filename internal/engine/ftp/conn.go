@@ -0,0 +1,136 @@
+// Package ftp downloads files from FTP and FTPS servers.
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	goftp "github.com/jlaffaye/ftp"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// MaxConnections caps segments per download: many FTP servers limit logins per client
+const MaxConnections = 4
+
+const (
+	defaultPort       = "21"
+	implicitTLSPort   = "990"
+	anonymousUser     = "anonymous"
+	anonymousPassword = "anonymous@"
+)
+
+// IsFTPURL reports whether rawurl uses the ftp or ftps scheme
+func IsFTPURL(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "ftp" || scheme == "ftps"
+}
+
+// target is a parsed FTP URL
+type target struct {
+	addr     string // host:port
+	host     string
+	user     string
+	password string
+	path     string // Path relative to the login directory (RFC 1738)
+	tls      bool   // ftps: explicit AUTH TLS, or implicit TLS on port 990
+	implicit bool
+}
+
+// parseTarget splits an ftp:// or ftps:// URL into connection parameters.
+// Credentials come from the URL and default to anonymous.
+func parseTarget(rawurl string) (*target, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FTP URL: %w", err)
+	}
+	if !IsFTPURL(rawurl) {
+		return nil, fmt.Errorf("invalid FTP URL: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid FTP URL: missing host")
+	}
+
+	// RFC 1738: the path is relative to the login directory, so "ftp://host//abs" is absolute
+	p := strings.TrimPrefix(u.Path, "/")
+	if p == "" || strings.HasSuffix(p, "/") {
+		return nil, fmt.Errorf("invalid FTP URL: no file in %q", u.Path)
+	}
+
+	t := &target{
+		host:     u.Hostname(),
+		path:     p,
+		user:     anonymousUser,
+		password: anonymousPassword,
+		tls:      strings.EqualFold(u.Scheme, "ftps"),
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	t.implicit = t.tls && port == implicitTLSPort
+	t.addr = net.JoinHostPort(t.host, port)
+
+	if u.User != nil {
+		t.user = u.User.Username()
+		t.password, _ = u.User.Password()
+	}
+	return t, nil
+}
+
+// dial opens a logged-in control connection in passive mode (EPSV, falling back to PASV).
+// The connection is closed when ctx is cancelled so blocked commands return promptly.
+func dial(ctx context.Context, t *target) (*goftp.ServerConn, func(), error) {
+	opts := []goftp.DialOption{
+		goftp.DialWithContext(ctx),
+		goftp.DialWithTimeout(types.DialTimeout),
+		goftp.DialWithShutTimeout(types.DefaultResponseHeaderTimeout),
+	}
+	if t.tls {
+		cfg := &tls.Config{
+			ServerName: t.host,
+			MinVersion: tls.VersionTLS12,
+			// Servers commonly require data connections to resume the control session
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		}
+		if t.implicit {
+			opts = append(opts, goftp.DialWithTLS(cfg))
+		} else {
+			opts = append(opts, goftp.DialWithExplicitTLS(cfg))
+		}
+	}
+
+	conn, err := goftp.Dial(t.addr, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ftp connect failed: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Quit() })
+	closeConn := func() {
+		if stop() {
+			_ = conn.Quit()
+		}
+	}
+
+	if err := conn.Login(t.user, t.password); err != nil {
+		closeConn()
+		return nil, nil, fmt.Errorf("ftp login failed: %w", err)
+	}
+	return conn, closeConn, nil
+}
+
+// isConnectionLimit reports whether the server refused a login because too many
+// connections are open (421)
+func isConnectionLimit(err error) bool {
+	var tpErr *textproto.Error
+	return errors.As(err, &tpErr) && tpErr.Code == goftp.StatusNotAvailable
+}
@@ -0,0 +1,552 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/diskspace"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// errConnectionRefused signals that a worker gave up its slot because the server
+// refused another login; its work was handed back to the queue
+var errConnectionRefused = errors.New("ftp server refused another connection")
+
+// FTPDownloader handles FTP and FTPS downloads. When the server supports REST the file
+// is split into segments fetched over separate connections and can be paused and
+// resumed; otherwise it is streamed over a single connection and restarts if interrupted.
+type FTPDownloader struct {
+	ProgressChan chan<- any           // Channel for events (start/complete/error)
+	ID           string               // Download ID
+	State        *types.ProgressState // Shared state for TUI polling
+	Runtime      *types.RuntimeConfig
+	URL          string // For pause/resume
+	DestPath     string // For pause/resume
+	Checksum     string // Expected digest ("algo:hex"), verified before finalizing
+	LastModified string // Modification time from the probe, compared on resume
+	Extract      string // Extract mode on completion, carried across pauses
+
+	segments   map[int]*segment // Segments in flight, by worker
+	segmentsMu sync.Mutex
+}
+
+// segment is a byte range being fetched by one connection
+type segment struct {
+	task    types.Task
+	current atomic.Int64 // Next offset to write
+}
+
+// remaining returns the part of the segment not yet written, or nil if done
+func (s *segment) remaining() *types.Task {
+	current := s.current.Load()
+	end := s.task.Offset + s.task.Length
+	if current >= end {
+		return nil
+	}
+	return &types.Task{Offset: current, Length: end - current}
+}
+
+// taskList is the shared queue of segments waiting for a connection
+type taskList struct {
+	mu    sync.Mutex
+	tasks []types.Task
+}
+
+func (q *taskList) pop() (types.Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tasks) == 0 {
+		return types.Task{}, false
+	}
+	task := q.tasks[0]
+	q.tasks = q.tasks[1:]
+	return task, true
+}
+
+func (q *taskList) push(task types.Task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tasks = append(q.tasks, task)
+}
+
+func (q *taskList) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.tasks)
+}
+
+func (q *taskList) drain() []types.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	tasks := q.tasks
+	q.tasks = nil
+	return tasks
+}
+
+// NewFTPDownloader creates a new FTP downloader with all required parameters
+func NewFTPDownloader(id string, progressCh chan<- any, progState *types.ProgressState, runtime *types.RuntimeConfig) *FTPDownloader {
+	return &FTPDownloader{
+		ID:           id,
+		ProgressChan: progressCh,
+		State:        progState,
+		Runtime:      runtime,
+		segments:     make(map[int]*segment),
+	}
+}
+
+// connections returns how many segments to fetch in parallel
+func (d *FTPDownloader) connections(fileSize int64) int {
+	n := d.Runtime.GetMaxConnectionsPerHost()
+	if n > MaxConnections {
+		n = MaxConnections
+	}
+	if minChunk := d.Runtime.GetMinChunkSize(); minChunk > 0 {
+		if bySize := int(fileSize / minChunk); bySize < n {
+			n = bySize
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// splitTasks divides the file into n segments aligned to AlignSize
+func splitTasks(fileSize int64, n int) ([]types.Task, int64) {
+	size := (fileSize + int64(n) - 1) / int64(n)
+	size = (size + types.AlignSize - 1) / types.AlignSize * types.AlignSize
+
+	var tasks []types.Task
+	for offset := int64(0); offset < fileSize; offset += size {
+		length := size
+		if offset+length > fileSize {
+			length = fileSize - offset
+		}
+		tasks = append(tasks, types.Task{Offset: offset, Length: length})
+	}
+	return tasks, size
+}
+
+// Download fetches rawurl into destPath. resumable reports whether the probe found
+// working REST support; without it, or without a known size, a single stream is used.
+func (d *FTPDownloader) Download(ctx context.Context, rawurl, destPath string, fileSize int64, resumable bool, verbose bool) error {
+	utils.Debug("FTPDownloader.Download: %s -> %s (size: %d, resumable: %v)", rawurl, destPath, fileSize, resumable)
+
+	t, err := parseTarget(rawurl)
+	if err != nil {
+		return err
+	}
+
+	// Store URL and path for pause/resume (final path without .surge)
+	d.URL = rawurl
+	d.DestPath = destPath
+	workingPath := destPath + types.IncompleteSuffix
+
+	// Create cancellable context for pause support
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if d.State != nil {
+		d.State.CancelFunc = cancel
+	}
+
+	if !resumable || fileSize <= 0 {
+		if err := d.downloadStream(downloadCtx, t, workingPath); err != nil {
+			return err
+		}
+		return d.finalize(workingPath, destPath)
+	}
+
+	numConns := d.connections(fileSize)
+	tasks, segmentSize := splitTasks(fileSize, numConns)
+
+	if verbose {
+		fmt.Printf("File size: %s, connections: %d, segment size: %s\n",
+			utils.ConvertBytesToHumanReadable(fileSize),
+			numConns,
+			utils.ConvertBytesToHumanReadable(segmentSize))
+	}
+
+	// Initialize chunk visualization
+	if d.State != nil {
+		d.State.InitBitmap(fileSize, segmentSize)
+	}
+
+	outFile, err := os.OpenFile(workingPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		if err := outFile.Close(); err != nil {
+			utils.Debug("Error closing file: %v", err)
+		}
+	}()
+
+	// Check for saved state BEFORE truncating (resume case).
+	// Never stitch bytes from two different versions of the remote file together.
+	savedState, err := state.LoadState(rawurl, destPath)
+	isResume := err == nil && savedState != nil && len(savedState.Tasks) > 0 &&
+		savedState.TotalSize == fileSize && savedState.LastModified == d.LastModified
+
+	if isResume {
+		tasks = savedState.Tasks
+		if d.State != nil {
+			d.State.Downloaded.Store(savedState.Downloaded)
+			d.State.SetSavedElapsed(time.Duration(savedState.Elapsed))
+			d.State.SyncSessionStart()
+			if len(savedState.ChunkBitmap) > 0 && savedState.ActualChunkSize > 0 {
+				d.State.RestoreBitmap(savedState.ChunkBitmap, savedState.ActualChunkSize)
+				d.State.RecalculateProgress(savedState.Tasks)
+			}
+		}
+		utils.Debug("Resuming FTP download: %d segments, %d bytes downloaded", len(tasks), savedState.Downloaded)
+	} else {
+		if err := diskspace.Allocate(outFile, fileSize, d.Runtime != nil && d.Runtime.PreallocateFiles); err != nil {
+			return fmt.Errorf("failed to preallocate file: %w", err)
+		}
+		if d.State != nil {
+			d.State.Downloaded.Store(0)
+			d.State.SyncSessionStart()
+		}
+	}
+
+	startTime := time.Now()
+	queue := &taskList{tasks: tasks}
+	if numConns > len(tasks) {
+		numConns = len(tasks)
+	}
+
+	// Workers that lose a connection slot leave their work in the queue; finish it with one connection
+	var downloadErr error
+	for conns := numConns; ; conns = 1 {
+		downloadErr = d.runWorkers(downloadCtx, t, outFile, queue, conns)
+		if downloadErr != nil || downloadCtx.Err() != nil || queue.len() == 0 {
+			break
+		}
+	}
+
+	// Handle pause: save the remaining ranges for resume
+	if d.State != nil && d.State.IsPaused() {
+		remainingTasks := queue.drain()
+		d.segmentsMu.Lock()
+		for _, seg := range d.segments {
+			if remaining := seg.remaining(); remaining != nil {
+				remainingTasks = append(remainingTasks, *remaining)
+			}
+		}
+		d.segmentsMu.Unlock()
+
+		var remainingBytes int64
+		for _, task := range remainingTasks {
+			remainingBytes += task.Length
+		}
+		bitmap, _, _, chunkSize, _ := d.State.GetBitmap()
+
+		s := &types.DownloadState{
+			URL:             d.URL,
+			ID:              d.ID,
+			DestPath:        destPath,
+			TotalSize:       fileSize,
+			Downloaded:      fileSize - remainingBytes,
+			Tasks:           remainingTasks,
+			Filename:        filepath.Base(destPath),
+			Elapsed:         (d.State.SavedElapsed + time.Since(startTime)).Nanoseconds(),
+			Checksum:        d.Checksum,
+			LastModified:    d.LastModified,
+			Extract:         d.Extract,
+			SpeedLimit:      d.State.SpeedLimit.Limit(),
+			ChunkBitmap:     bitmap,
+			ActualChunkSize: chunkSize,
+		}
+		if err := state.SaveState(d.URL, destPath, s); err != nil {
+			utils.Debug("Failed to save pause state: %v", err)
+		}
+		utils.Debug("FTP download paused, state saved (Downloaded=%d, RemainingTasks=%d)", s.Downloaded, len(remainingTasks))
+		return types.ErrPaused
+	}
+
+	if downloadCtx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	if downloadErr != nil {
+		return downloadErr
+	}
+
+	if err := outFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	_ = outFile.Close()
+
+	return d.finalize(workingPath, destPath)
+}
+
+// runWorkers fetches queued segments over up to n connections
+func (d *FTPDownloader) runWorkers(ctx context.Context, t *target, file *os.File, queue *taskList, n int) error {
+	var wg sync.WaitGroup
+	var active atomic.Int32
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		active.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			defer active.Add(-1)
+			if err := d.worker(ctx, workerID, t, file, queue, &active); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var firstErr error
+	for err := range errs {
+		if errors.Is(err, errConnectionRefused) || errors.Is(err, context.Canceled) {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// worker fetches segments from the queue until it is empty
+func (d *FTPDownloader) worker(ctx context.Context, id int, t *target, file *os.File, queue *taskList, active *atomic.Int32) error {
+	buf := make([]byte, d.Runtime.GetWorkerBufferSize())
+
+	for {
+		task, ok := queue.pop()
+		if !ok {
+			return nil
+		}
+
+		seg := &segment{task: task}
+		seg.current.Store(task.Offset)
+		d.segmentsMu.Lock()
+		d.segments[id] = seg
+		d.segmentsMu.Unlock()
+
+		var lastErr error
+		maxRetries := d.Runtime.GetMaxTaskRetries()
+		for attempt := 0; attempt < maxRetries; attempt++ {
+			if attempt > 0 {
+				metrics.Retries.Inc()
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(1<<attempt) * types.RetryBaseDelay):
+				}
+			}
+
+			lastErr = d.fetchSegment(ctx, t, file, seg, buf)
+			if lastErr == nil || ctx.Err() != nil {
+				break
+			}
+
+			// Other connections are still busy: hand the rest back rather than fight for a slot
+			if isConnectionLimit(lastErr) && active.Load() > 1 {
+				if remaining := seg.remaining(); remaining != nil {
+					queue.push(*remaining)
+				}
+				d.segmentsMu.Lock()
+				delete(d.segments, id)
+				d.segmentsMu.Unlock()
+				utils.Debug("FTP worker %d: server refused another connection, leaving %d segments to others", id, queue.len())
+				return errConnectionRefused
+			}
+			utils.Debug("FTP worker %d: segment at %d failed (attempt %d): %v", id, seg.current.Load(), attempt+1, lastErr)
+		}
+
+		// Pause/cancel: keep the segment so its remaining range is saved
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if lastErr != nil {
+			return lastErr
+		}
+
+		d.segmentsMu.Lock()
+		delete(d.segments, id)
+		d.segmentsMu.Unlock()
+	}
+}
+
+// fetchSegment downloads the rest of seg over a fresh connection using REST
+func (d *FTPDownloader) fetchSegment(ctx context.Context, t *target, file *os.File, seg *segment, buf []byte) error {
+	conn, closeConn, err := dial(ctx, t)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	metrics.HostConnections.Inc(t.addr)
+	defer metrics.HostConnections.Dec(t.addr)
+
+	offset := seg.current.Load()
+	end := seg.task.Offset + seg.task.Length
+
+	resp, err := conn.RetrFrom(t.path, uint64(offset))
+	if err != nil {
+		return fmt.Errorf("ftp retrieve failed: %w", err)
+	}
+	stopData := context.AfterFunc(ctx, func() { _ = resp.SetDeadline(time.Now()) })
+	defer func() {
+		stopData()
+		// Quit before closing the data connection: servers may never acknowledge a
+		// transfer cut short at the segment end, and a closed control connection
+		// makes the close return immediately
+		closeConn()
+		_ = resp.Close()
+	}()
+
+	if d.State != nil {
+		d.State.ActiveWorkers.Add(1)
+		defer d.State.ActiveWorkers.Add(-1)
+	}
+
+	limiters := d.State.Limiters()
+	for offset < end {
+		readSize := int64(len(buf))
+		if remaining := end - offset; readSize > remaining {
+			readSize = remaining
+		}
+		if throttled := int64(bandwidth.ReadSize(limiters...)); throttled > 0 && readSize > throttled {
+			readSize = throttled
+		}
+
+		n, readErr := io.ReadFull(resp, buf[:readSize])
+		if n > 0 {
+			metrics.AddBytes(n)
+			if err := bandwidth.Wait(ctx, n, limiters...); err != nil {
+				return err
+			}
+			if _, err := file.WriteAt(buf[:n], offset); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			if d.State != nil {
+				d.State.Downloaded.Add(int64(n))
+				d.State.UpdateChunkStatus(offset, int64(n), types.ChunkCompleted)
+			}
+			offset += int64(n)
+			seg.current.Store(offset)
+		}
+
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if offset >= end {
+				break
+			}
+			if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+				return fmt.Errorf("ftp transfer ended early at offset %d of %d", offset, end)
+			}
+			return fmt.Errorf("read error: %w", readErr)
+		}
+	}
+	return nil
+}
+
+// downloadStream fetches the whole file over one connection. Without REST there is
+// nothing to resume from, so an interrupted stream is discarded.
+func (d *FTPDownloader) downloadStream(ctx context.Context, t *target, workingPath string) error {
+	conn, closeConn, err := dial(ctx, t)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	metrics.HostConnections.Inc(t.addr)
+	defer metrics.HostConnections.Dec(t.addr)
+
+	resp, err := conn.Retr(t.path)
+	if err != nil {
+		return fmt.Errorf("ftp retrieve failed: %w", err)
+	}
+	stopData := context.AfterFunc(ctx, func() { _ = resp.SetDeadline(time.Now()) })
+	defer stopData()
+	defer func() { _ = resp.Close() }()
+
+	outFile, err := os.Create(workingPath)
+	if err != nil {
+		return err
+	}
+	success := false
+	defer func() {
+		_ = outFile.Close()
+		if !success {
+			_ = os.Remove(workingPath)
+		}
+	}()
+
+	if d.State != nil {
+		d.State.Downloaded.Store(0)
+		d.State.SyncSessionStart()
+		d.State.ActiveWorkers.Add(1)
+		defer d.State.ActiveWorkers.Add(-1)
+	}
+
+	var written int64
+	buf := make([]byte, d.Runtime.GetWorkerBufferSize())
+	limiters := d.State.Limiters()
+	for {
+		readBuf := buf
+		if throttled := bandwidth.ReadSize(limiters...); throttled > 0 && throttled < len(buf) {
+			readBuf = buf[:throttled]
+		}
+
+		nr, readErr := resp.Read(readBuf)
+		if nr > 0 {
+			metrics.AddBytes(nr)
+			if err := bandwidth.Wait(ctx, nr, limiters...); err != nil {
+				return err
+			}
+			if _, err := outFile.Write(readBuf[:nr]); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			written += int64(nr)
+			if d.State != nil {
+				d.State.Downloaded.Store(written)
+				d.State.VerifiedProgress.Store(written)
+			}
+		}
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if readErr == io.EOF {
+				break
+			}
+			return fmt.Errorf("read error: %w", readErr)
+		}
+	}
+
+	if err := outFile.Sync(); err != nil {
+		return fmt.Errorf("sync error: %w", err)
+	}
+	success = true
+	return nil
+}
+
+// finalize verifies the working file and moves it to its final name
+func (d *FTPDownloader) finalize(workingPath, destPath string) error {
+	// Verify expected digest before exposing the file under its final name
+	if err := checksum.VerifyFile(workingPath, d.Checksum); err != nil {
+		return err
+	}
+	if err := os.Rename(workingPath, destPath); err != nil {
+		return fmt.Errorf("failed to rename completed file: %w", err)
+	}
+	_ = state.DeleteState(d.ID, d.URL, destPath)
+	return nil
+}
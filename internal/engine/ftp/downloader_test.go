@@ -0,0 +1,198 @@
+package ftp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func randomData(t *testing.T, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestProbe(t *testing.T) {
+	data := randomData(t, 64*int(types.KB))
+
+	t.Run("Resumable", func(t *testing.T) {
+		server := testutil.NewFTPServerT(t, "pub/file.bin", data, testutil.WithFTPCredentials("alice", "secret"))
+		info, err := Probe(context.Background(), server.URL())
+		if err != nil {
+			t.Fatalf("Probe failed: %v", err)
+		}
+		if info.Filename != "file.bin" || info.Size != int64(len(data)) || !info.SupportsResume {
+			t.Errorf("Probe = %+v", info)
+		}
+		if !info.ModTime.Equal(server.ModTime) {
+			t.Errorf("ModTime = %v, want %v", info.ModTime, server.ModTime)
+		}
+	})
+
+	t.Run("No REST", func(t *testing.T) {
+		server := testutil.NewFTPServerT(t, "file.bin", data, testutil.WithFTPNoRest())
+		info, err := Probe(context.Background(), server.URL())
+		if err != nil {
+			t.Fatalf("Probe failed: %v", err)
+		}
+		if info.SupportsResume {
+			t.Error("Probe reported resume support without REST")
+		}
+	})
+
+	t.Run("Bad login", func(t *testing.T) {
+		server := testutil.NewFTPServerT(t, "file.bin", data, testutil.WithFTPCredentials("alice", "secret"))
+		wrong := "ftp://alice:wrong@" + server.Listener.Addr().String() + "/file.bin"
+		if _, err := Probe(context.Background(), wrong); err == nil {
+			t.Error("Probe succeeded with wrong password")
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		server := testutil.NewFTPServerT(t, "file.bin", data)
+		missing := "ftp://" + server.Listener.Addr().String() + "/other.bin"
+		if _, err := Probe(context.Background(), missing); err == nil {
+			t.Error("Probe succeeded for a missing file")
+		}
+	})
+}
+
+func TestFTPDownloader_Segmented(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-ftp-test")
+	data := randomData(t, int(4*types.MB))
+	server := testutil.NewFTPServerT(t, "file.bin", data)
+
+	destPath := filepath.Join(tmpDir, "file.bin")
+	progState := types.NewProgressState("ftp-segmented", int64(len(data)))
+	d := NewFTPDownloader("ftp-segmented", nil, progState, &types.RuntimeConfig{
+		MaxConnectionsPerHost: 4,
+		MinChunkSize:          512 * types.KB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := d.Download(ctx, server.URL(), destPath, int64(len(data)), true, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, data)
+	if got := server.Retrievals.Load(); got != MaxConnections {
+		t.Errorf("Retrievals = %d, want %d segments", got, MaxConnections)
+	}
+	if got := progState.Downloaded.Load(); got != int64(len(data)) {
+		t.Errorf("Downloaded = %d, want %d", got, len(data))
+	}
+}
+
+func TestFTPDownloader_Stream(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-ftp-test")
+	data := randomData(t, int(1*types.MB))
+	server := testutil.NewFTPServerT(t, "file.bin", data, testutil.WithFTPNoRest())
+
+	destPath := filepath.Join(tmpDir, "file.bin")
+	d := NewFTPDownloader("ftp-stream", nil, types.NewProgressState("ftp-stream", 0), &types.RuntimeConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := d.Download(ctx, server.URL(), destPath, int64(len(data)), false, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, data)
+	if got := server.Retrievals.Load(); got != 1 {
+		t.Errorf("Retrievals = %d, want a single stream", got)
+	}
+}
+
+func TestFTPDownloader_ConnectionLimit(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-ftp-test")
+	data := randomData(t, int(4*types.MB))
+	server := testutil.NewFTPServerT(t, "file.bin", data, testutil.WithFTPMaxConns(2))
+
+	destPath := filepath.Join(tmpDir, "file.bin")
+	d := NewFTPDownloader("ftp-limit", nil, types.NewProgressState("ftp-limit", int64(len(data))), &types.RuntimeConfig{
+		MaxConnectionsPerHost: 4,
+		MinChunkSize:          512 * types.KB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := d.Download(ctx, server.URL(), destPath, int64(len(data)), true, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, data)
+	if server.Refused.Load() == 0 {
+		t.Error("Expected the server to refuse extra connections")
+	}
+}
+
+func TestFTPDownloader_PauseResume(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-ftp-test")
+	data := randomData(t, int(2*types.MB))
+	server := testutil.NewFTPServerT(t, "file.bin", data, testutil.WithFTPByteLatency(time.Millisecond))
+
+	destPath := filepath.Join(tmpDir, "file.bin")
+	runtime := &types.RuntimeConfig{MaxConnectionsPerHost: 2, MinChunkSize: 512 * types.KB}
+	lastModified := server.ModTime.Format(time.RFC1123)
+
+	progState := types.NewProgressState("ftp-resume", int64(len(data)))
+	d := NewFTPDownloader("ftp-resume", nil, progState, runtime)
+	d.LastModified = lastModified
+
+	go func() {
+		for progState.Downloaded.Load() < 256*types.KB {
+			time.Sleep(5 * time.Millisecond)
+		}
+		progState.Pause()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := d.Download(ctx, server.URL(), destPath, int64(len(data)), true, false)
+	if !errors.Is(err, types.ErrPaused) {
+		t.Fatalf("Download returned %v, want ErrPaused", err)
+	}
+
+	saved, err := state.LoadState(server.URL(), destPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if saved.LastModified != lastModified || len(saved.Tasks) == 0 || saved.Downloaded == 0 {
+		t.Fatalf("Saved state = %+v", saved)
+	}
+	var remaining int64
+	for _, task := range saved.Tasks {
+		remaining += task.Length
+	}
+	if saved.Downloaded+remaining != int64(len(data)) {
+		t.Errorf("Saved %d downloaded + %d remaining, want %d", saved.Downloaded, remaining, len(data))
+	}
+
+	// Resume on a fresh downloader and fetch only the remaining ranges
+	before := server.Retrievals.Load()
+	resumed := types.NewProgressState("ftp-resume", int64(len(data)))
+	d = NewFTPDownloader("ftp-resume", nil, resumed, runtime)
+	d.LastModified = lastModified
+	if err := d.Download(ctx, server.URL(), destPath, int64(len(data)), true, false); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, data)
+	if got := server.Retrievals.Load() - before; got != int64(len(saved.Tasks)) {
+		t.Errorf("Resume made %d retrievals, want %d", got, len(saved.Tasks))
+	}
+	if _, err := state.LoadState(server.URL(), destPath); err == nil {
+		t.Error("State not deleted after completion")
+	}
+}
@@ -0,0 +1,68 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// Info is what a probe learns about a remote file
+type Info struct {
+	Filename       string
+	Size           int64     // 0 if the server does not support SIZE
+	ModTime        time.Time // Zero if the server does not support MDTM
+	SupportsResume bool      // REST STREAM works, so ranges can be fetched independently
+}
+
+// Probe logs in and queries the file's size, modification time and REST support
+func Probe(ctx context.Context, rawurl string) (*Info, error) {
+	t, err := parseTarget(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, closeConn, err := dial(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConn()
+
+	info := &Info{Filename: path.Base(t.path)}
+
+	if size, err := conn.FileSize(t.path); err == nil {
+		info.Size = size
+	} else {
+		utils.Debug("FTP SIZE failed for %s: %v", t.path, err)
+	}
+
+	if conn.IsGetTimeSupported() {
+		if modTime, err := conn.GetTime(t.path); err == nil {
+			info.ModTime = modTime
+		}
+	}
+
+	// FEAT is not exposed by the client, so test REST by fetching the last byte.
+	// This also confirms the file is retrievable at all.
+	if info.Size > 1 {
+		resp, err := conn.RetrFrom(t.path, uint64(info.Size-1))
+		if err == nil {
+			_ = resp.Close()
+			info.SupportsResume = true
+		} else {
+			utils.Debug("FTP REST not supported by %s: %v", t.addr, err)
+		}
+	}
+	if !info.SupportsResume {
+		resp, err := conn.Retr(t.path)
+		if err != nil {
+			return nil, fmt.Errorf("ftp retrieve failed: %w", err)
+		}
+		_ = resp.Close()
+	}
+
+	utils.Debug("FTP probe complete - filename: %s, size: %d, resume: %v", info.Filename, info.Size, info.SupportsResume)
+	return info, nil
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+)
+
+// Family is a named metric with its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample is one value of a family, told apart from its siblings by labels
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Gather returns the engine's metrics
+func Gather() []Family {
+	hosts := HostConnections.Values()
+	hostSamples := make([]Sample, 0, len(hosts))
+	for host, n := range hosts {
+		hostSamples = append(hostSamples, Sample{Labels: map[string]string{"host": host}, Value: float64(n)})
+	}
+	sort.Slice(hostSamples, func(i, j int) bool {
+		return hostSamples[i].Labels["host"] < hostSamples[j].Labels["host"]
+	})
+
+	return []Family{
+		counterFamily("surge_downloaded_bytes_total", "Payload bytes received by all downloads.", &BytesDownloaded),
+		{Name: "surge_download_speed_bytes", Help: "Aggregate download speed in bytes per second over the last few seconds.", Type: TypeGauge, Samples: []Sample{{Value: Speed.PerSecond()}}},
+		{Name: "surge_host_connections", Help: "Open download connections per host.", Type: TypeGauge, Samples: hostSamples},
+		counterFamily("surge_worker_restarts_total", "Workers cancelled by the health check for being too slow.", &WorkerRestarts),
+		counterFamily("surge_retries_total", "Chunk requests retried after a failure.", &Retries),
+		counterFamily("surge_probe_failures_total", "Failed probes of download URLs.", &ProbeFailures),
+		counterFamily("surge_downloads_completed_total", "Downloads that finished successfully.", &DownloadsCompleted),
+		counterFamily("surge_downloads_failed_total", "Downloads that stopped with an error.", &DownloadsFailed),
+	}
+}
+
+func counterFamily(name, help string, c *Counter) Family {
+	return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Value: float64(c.Value())}}}
+}
+
+// Write writes families in the text exposition format
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name)
+			writeLabels(bw, s.Labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+func writeLabels(bw *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		fmt.Fprintf(bw, `%s="%s"`, name, escapeLabel(labels[name]))
+	}
+	bw.WriteByte('}')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,155 @@
+// Package metrics holds the engine's process-wide counters and writes them in the
+// Prometheus text exposition format. Downloaders update the counters as they work;
+// nothing here is derived from the download list.
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a value that only goes up
+type Counter struct {
+	v atomic.Int64
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add adds n, which must not be negative
+func (c *Counter) Add(n int64) {
+	if n > 0 {
+		c.v.Add(n)
+	}
+}
+
+// Value returns the current count
+func (c *Counter) Value() int64 {
+	return c.v.Load()
+}
+
+// GaugeVec is a set of gauges told apart by one label value. Gauges that drop
+// back to zero are removed, so short-lived label values don't pile up.
+type GaugeVec struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+// Inc adds one to the gauge for label
+func (g *GaugeVec) Inc(label string) {
+	g.add(label, 1)
+}
+
+// Dec subtracts one from the gauge for label
+func (g *GaugeVec) Dec(label string) {
+	g.add(label, -1)
+}
+
+func (g *GaugeVec) add(label string, delta int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.values == nil {
+		g.values = make(map[string]int64)
+	}
+	g.values[label] += delta
+	if g.values[label] <= 0 {
+		delete(g.values, label)
+	}
+}
+
+// Values returns a copy of the non-zero gauges
+func (g *GaugeVec) Values() map[string]int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]int64, len(g.values))
+	for k, v := range g.values {
+		out[k] = v
+	}
+	return out
+}
+
+// rateWindow is the number of whole seconds Rate averages over
+const rateWindow = 5
+
+// Rate measures throughput over the last few seconds
+type Rate struct {
+	mu      sync.Mutex
+	buckets [rateWindow + 1]rateBucket // One per second, plus the current one
+}
+
+type rateBucket struct {
+	second int64
+	n      int64
+}
+
+// Add records n units at the current time
+func (r *Rate) Add(n int64) {
+	r.add(n, time.Now())
+}
+
+func (r *Rate) add(n int64, now time.Time) {
+	sec := now.Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &r.buckets[sec%int64(len(r.buckets))]
+	if b.second != sec {
+		b.second, b.n = sec, 0
+	}
+	b.n += n
+}
+
+// PerSecond returns the average rate over the last complete seconds
+func (r *Rate) PerSecond() float64 {
+	return r.perSecond(time.Now())
+}
+
+func (r *Rate) perSecond(now time.Time) float64 {
+	sec := now.Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for _, b := range r.buckets {
+		// The current second is still filling up
+		if b.second < sec && b.second >= sec-rateWindow {
+			total += b.n
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// Engine metrics
+var (
+	// BytesDownloaded counts payload bytes received by every downloader
+	BytesDownloaded Counter
+
+	// Speed is the aggregate download speed in bytes per second
+	Speed Rate
+
+	// WorkerRestarts counts workers cancelled by the health check for being slow
+	WorkerRestarts Counter
+
+	// Retries counts failed requests for a chunk that were tried again
+	Retries Counter
+
+	// ProbeFailures counts probes of a URL that failed
+	ProbeFailures Counter
+
+	// DownloadsCompleted and DownloadsFailed count downloads as they finish
+	DownloadsCompleted Counter
+	DownloadsFailed    Counter
+
+	// HostConnections counts open download connections per host
+	HostConnections GaugeVec
+)
+
+// AddBytes records n downloaded bytes in BytesDownloaded and Speed
+func AddBytes(n int) {
+	if n <= 0 {
+		return
+	}
+	BytesDownloaded.Add(int64(n))
+	Speed.Add(int64(n))
+}
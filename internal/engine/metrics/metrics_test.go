@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGaugeVec_DropsZeroes(t *testing.T) {
+	var g GaugeVec
+	g.Inc("a.example")
+	g.Inc("a.example")
+	g.Inc("b.example")
+	g.Dec("b.example")
+	g.Dec("a.example")
+
+	values := g.Values()
+	if len(values) != 1 || values["a.example"] != 1 {
+		t.Errorf("Values() = %v, want map[a.example:1]", values)
+	}
+}
+
+func TestRate_PerSecond(t *testing.T) {
+	var r Rate
+	start := time.Unix(1000, 0)
+
+	r.add(100, start.Add(-10*time.Second)) // Outside the window
+	r.add(300, start.Add(-3*time.Second))
+	r.add(200, start.Add(-1*time.Second))
+	r.add(999, start) // Current second, not complete yet
+
+	if got, want := r.perSecond(start), 100.0; got != want {
+		t.Errorf("perSecond() = %v, want %v", got, want)
+	}
+	if got := r.perSecond(start.Add(time.Minute)); got != 0 {
+		t.Errorf("perSecond() after a quiet minute = %v, want 0", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	families := []Family{
+		{Name: "test_total", Help: "A counter\\with\nescapes.", Type: TypeCounter, Samples: []Sample{{Value: 42}}},
+		{Name: "test_gauge", Help: "A gauge.", Type: TypeGauge, Samples: []Sample{
+			{Labels: map[string]string{"z": "1", "host": `a"b\c`}, Value: 1.5},
+		}},
+		{Name: "test_empty", Help: "No samples.", Type: TypeGauge},
+	}
+
+	var sb strings.Builder
+	if err := Write(&sb, families); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_total A counter\\with\nescapes.
+# TYPE test_total counter
+test_total 42
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge{host="a\"b\\c",z="1"} 1.5
+# HELP test_empty No samples.
+# TYPE test_empty gauge
+`
+	if sb.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
+
+func TestGather_CountsEngineActivity(t *testing.T) {
+	before := BytesDownloaded.Value()
+	AddBytes(1024)
+	AddBytes(-1)
+	if got := BytesDownloaded.Value() - before; got != 1024 {
+		t.Errorf("BytesDownloaded grew by %d, want 1024", got)
+	}
+
+	HostConnections.Inc("gather.example")
+	defer HostConnections.Dec("gather.example")
+
+	var sb strings.Builder
+	if err := Write(&sb, Gather()); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE surge_downloaded_bytes_total counter",
+		`surge_host_connections{host="gather.example"} 1`,
+		"# TYPE surge_worker_restarts_total counter",
+		"# TYPE surge_probe_failures_total counter",
+	} {
+		if !strings.Contains(sb.String(), line) {
+			t.Errorf("Gather() output is missing %q", line)
+		}
+	}
+}
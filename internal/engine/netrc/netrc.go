@@ -0,0 +1,159 @@
+// Package netrc reads login credentials from a .netrc file, the format curl,
+// wget and ftp clients use to store passwords per host.
+package netrc
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Machine is one entry of a .netrc file. The "default" entry has an empty Name.
+type Machine struct {
+	Name     string
+	Login    string
+	Password string
+}
+
+// Parse reads .netrc entries. Macro definitions are skipped.
+func Parse(r io.Reader) ([]Machine, error) {
+	var tokens []string
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// A macro runs until the next empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if strings.HasPrefix(f, "#") {
+				break
+			}
+			if f == "macdef" {
+				inMacro = true
+				tokens = append(tokens, fields[i:min(i+2, len(fields))]...)
+				break
+			}
+			tokens = append(tokens, f)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var machines []Machine
+	var cur *Machine
+	for i := 0; i < len(tokens); i++ {
+		value := func() string {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+			return ""
+		}
+		switch tokens[i] {
+		case "machine":
+			machines = append(machines, Machine{Name: strings.ToLower(value())})
+			cur = &machines[len(machines)-1]
+		case "default":
+			machines = append(machines, Machine{})
+			cur = &machines[len(machines)-1]
+		case "login":
+			if v := value(); cur != nil {
+				cur.Login = v
+			}
+		case "password":
+			if v := value(); cur != nil {
+				cur.Password = v
+			}
+		case "account", "macdef":
+			value()
+		}
+	}
+	return machines, nil
+}
+
+// Path returns the .netrc file to read: $NETRC, else .netrc (or _netrc on
+// Windows) in the home directory
+func Path() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		if p := filepath.Join(home, "_netrc"); fileExists(p) {
+			return p
+		}
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Find returns the entry for host, falling back to the default entry
+func Find(machines []Machine, host string) (Machine, bool) {
+	host = strings.ToLower(host)
+	var def *Machine
+	for i := range machines {
+		if machines[i].Name == "" {
+			if def == nil {
+				def = &machines[i]
+			}
+			continue
+		}
+		if machines[i].Name == host {
+			return machines[i], true
+		}
+	}
+	if def != nil {
+		return *def, true
+	}
+	return Machine{}, false
+}
+
+// Authorization returns a Basic Authorization header value for rawURL from the
+// user's .netrc, or "" if it has no login for the URL's host. The file is read
+// on every call, so edits apply to the next download without a restart.
+func Authorization(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return ""
+	}
+	path := Path()
+	if path == "" {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+	machines, err := Parse(f)
+	if err != nil {
+		return ""
+	}
+	m, ok := Find(machines, u.Hostname())
+	if !ok || m.Login == "" {
+		return ""
+	}
+	return BasicAuth(m.Login, m.Password)
+}
+
+// BasicAuth returns the Authorization header value for HTTP basic authentication
+func BasicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
@@ -0,0 +1,73 @@
+package netrc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sample = `# Work servers
+machine files.corp.example login alice password s3cr3t
+machine ftp.example.org
+	login anonymous
+	password guest@example.org
+	account ignored
+
+macdef init
+cd /pub
+binary
+
+machine Mixed.Example login bob password hunter2
+default login guest password guest
+`
+
+func TestParse(t *testing.T) {
+	machines, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Machine{
+		{Name: "files.corp.example", Login: "alice", Password: "s3cr3t"},
+		{Name: "ftp.example.org", Login: "anonymous", Password: "guest@example.org"},
+		{Name: "mixed.example", Login: "bob", Password: "hunter2"},
+		{Login: "guest", Password: "guest"},
+	}
+	if len(machines) != len(want) {
+		t.Fatalf("Parse returned %+v, want %+v", machines, want)
+	}
+	for i := range want {
+		if machines[i] != want[i] {
+			t.Errorf("machine %d = %+v, want %+v", i, machines[i], want[i])
+		}
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte("machine files.corp.example login alice password s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", path)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://files.corp.example/build.tar", BasicAuth("alice", "s3cr3t")},
+		{"http://FILES.corp.example:8080/build.tar", BasicAuth("alice", "s3cr3t")},
+		{"https://other.example/file", ""},
+		{"https://bob:pw@files.corp.example/file", ""}, // Credentials in the URL win
+		{"ftp://files.corp.example/file", ""},
+	}
+	for _, tt := range tests {
+		if got := Authorization(tt.url); got != tt.want {
+			t.Errorf("Authorization(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+	if got := Authorization("https://files.corp.example/build.tar"); got != "" {
+		t.Errorf("Authorization without a netrc file = %q", got)
+	}
+}
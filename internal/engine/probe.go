@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/cookies"
+	"github.com/surge-downloader/surge/internal/engine/ftp"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+	"github.com/surge-downloader/surge/internal/engine/sftp"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
 )
@@ -24,19 +29,43 @@ type ProbeResult struct {
 	SupportsRange bool
 	Filename      string
 	ContentType   string
+	ETag          string // Entity tag (empty if not sent)
+	LastModified  string // Last-Modified header (empty if not sent)
 }
 
 // ProbeServer sends GET with Range: bytes=0-0 to determine server capabilities
-// headers is optional - pass nil for non-authenticated probes
+// headers is optional - pass nil for non-authenticated probes. FTP and SFTP URLs are
+// probed over their own protocols; SFTP uses the default SSH keys and known_hosts.
 func ProbeServer(ctx context.Context, rawurl string, filenameHint string, headers map[string]string) (*ProbeResult, error) {
+	result, err := probeServer(ctx, rawurl, filenameHint, headers)
+	countProbeFailure(ctx, err)
+	return result, err
+}
+
+// countProbeFailure records a failed probe. Cancelled probes are not failures.
+func countProbeFailure(ctx context.Context, err error) {
+	if err != nil && ctx.Err() == nil {
+		metrics.ProbeFailures.Inc()
+	}
+}
+
+func probeServer(ctx context.Context, rawurl string, filenameHint string, headers map[string]string) (*ProbeResult, error) {
 	utils.Debug("Probing server: %s", rawurl)
 
+	if ftp.IsFTPURL(rawurl) {
+		return probeFTP(ctx, rawurl, filenameHint)
+	}
+	if sftp.IsSFTPURL(rawurl) {
+		return probeSFTP(ctx, rawurl, filenameHint, nil)
+	}
+
 	var resp *http.Response
 	var err error
 
 	// Create a client that preserves headers on redirects (for authenticated downloads)
 	client := &http.Client{
-		Timeout: types.ProbeTimeout,
+		Timeout:   types.ProbeTimeout,
+		Transport: cookies.Transport(nil),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 10 {
 				return fmt.Errorf("stopped after 10 redirects")
@@ -169,6 +198,8 @@ func ProbeServer(ctx context.Context, rawurl string, filenameHint string, header
 	}
 
 	result.ContentType = resp.Header.Get("Content-Type")
+	result.ETag = resp.Header.Get("ETag")
+	result.LastModified = resp.Header.Get("Last-Modified")
 
 	utils.Debug("Probe complete - filename: %s, size: %d, range: %v",
 		result.Filename, result.FileSize, result.SupportsRange)
@@ -176,17 +207,17 @@ func ProbeServer(ctx context.Context, rawurl string, filenameHint string, header
 	return result, nil
 }
 
-// ProbeMirrors concurrently checks a list of mirrors and returns valid ones and errors
+// ProbeMirrors concurrently checks a list of mirrors and returns valid ones and errors.
+// Valid mirrors keep their input order, so callers can list them by preference.
 func ProbeMirrors(ctx context.Context, mirrors []string) (valid []string, errors map[string]error) {
-	// Deduplicate
-	unique := make(map[string]bool)
-	for _, m := range mirrors {
-		unique[m] = true
-	}
-
+	// Deduplicate, remembering each mirror's position
+	order := make(map[string]int)
 	var candidates []string
-	for m := range unique {
-		candidates = append(candidates, m)
+	for _, m := range mirrors {
+		if _, seen := order[m]; !seen {
+			order[m] = len(candidates)
+			candidates = append(candidates, m)
+		}
 	}
 
 	utils.Debug("Probing %d mirrors...", len(candidates))
@@ -224,6 +255,7 @@ func ProbeMirrors(ctx context.Context, mirrors []string) (valid []string, errors
 	}
 
 	wg.Wait()
+	sort.Slice(valid, func(i, j int) bool { return order[valid[i]] < order[valid[j]] })
 	utils.Debug("Mirror probing complete: %d valid, %d failed", len(valid), len(errors))
 	return valid, errors
 }
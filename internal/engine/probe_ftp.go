@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/surge-downloader/surge/internal/engine/ftp"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// probeFTP maps an FTP probe onto ProbeResult. REST support plays the role of
+// range support, and the modification time stands in for Last-Modified so resume
+// can detect a changed file.
+func probeFTP(ctx context.Context, rawurl string, filenameHint string) (*ProbeResult, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, types.ProbeTimeout)
+	defer cancel()
+
+	info, err := ftp.Probe(probeCtx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProbeResult{
+		FileSize:      info.Size,
+		SupportsRange: info.SupportsResume && info.Size > 0,
+		Filename:      info.Filename,
+	}
+	if filenameHint != "" {
+		result.Filename = filenameHint
+	}
+	if !info.ModTime.IsZero() {
+		result.LastModified = info.ModTime.UTC().Format(http.TimeFormat)
+	}
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/surge-downloader/surge/internal/engine/sftp"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// ProbeSFTP stats a file over SFTP using the SSH settings in runtime. SFTP reads
+// at any offset, so ranges are always supported; the modification time stands in
+// for Last-Modified so resume can detect a changed file.
+func ProbeSFTP(ctx context.Context, rawurl string, filenameHint string, runtime *types.RuntimeConfig) (*ProbeResult, error) {
+	result, err := probeSFTP(ctx, rawurl, filenameHint, runtime)
+	countProbeFailure(ctx, err)
+	return result, err
+}
+
+func probeSFTP(ctx context.Context, rawurl string, filenameHint string, runtime *types.RuntimeConfig) (*ProbeResult, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, types.ProbeTimeout)
+	defer cancel()
+
+	info, err := sftp.Probe(probeCtx, rawurl, runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProbeResult{
+		FileSize:      info.Size,
+		SupportsRange: true,
+		Filename:      info.Filename,
+		LastModified:  info.ModTime.UTC().Format(http.TimeFormat),
+	}
+	if filenameHint != "" {
+		result.Filename = filenameHint
+	}
+	return result, nil
+}
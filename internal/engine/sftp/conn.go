@@ -0,0 +1,268 @@
+// Package sftp downloads files over SFTP using the user's SSH credentials.
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// MaxChannels caps the SFTP channels opened over a download's SSH connection
+const MaxChannels = 8
+
+const defaultPort = "22"
+
+// defaultIdentityFiles are tried when no key file is configured, like ssh(1) does
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// IsSFTPURL reports whether rawurl uses the sftp or scp scheme. scp:// URLs are
+// fetched over the SFTP subsystem, as current OpenSSH scp does.
+func IsSFTPURL(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "sftp" || scheme == "scp"
+}
+
+// target is a parsed SFTP URL
+type target struct {
+	addr     string // host:port
+	user     string
+	password string // Only if given in the URL
+	path     string // Absolute, or relative to the home directory for "/~/" paths
+}
+
+// parseTarget splits an sftp:// or scp:// URL into connection parameters.
+// The user defaults to the local user name.
+func parseTarget(rawurl string) (*target, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SFTP URL: %w", err)
+	}
+	if !IsSFTPURL(rawurl) {
+		return nil, fmt.Errorf("invalid SFTP URL: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid SFTP URL: missing host")
+	}
+
+	// As in curl, "/~/" starts a path relative to the home directory
+	p := u.Path
+	if rest, ok := strings.CutPrefix(p, "/~/"); ok {
+		p = rest
+	}
+	if p == "" || p == "/" || strings.HasSuffix(p, "/") {
+		return nil, fmt.Errorf("invalid SFTP URL: no file in %q", u.Path)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	t := &target{
+		addr: net.JoinHostPort(u.Hostname(), port),
+		path: p,
+	}
+	if u.User != nil {
+		t.user = u.User.Username()
+		t.password, _ = u.User.Password()
+	}
+	if t.user == "" {
+		t.user = localUser()
+	}
+	return t, nil
+}
+
+// localUser returns the name of the user running Surge
+func localUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		// Windows reports DOMAIN\user
+		if _, name, ok := strings.Cut(u.Username, `\`); ok {
+			return name
+		}
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// sshDir returns ~/.ssh, or "" if the home directory is unknown
+func sshDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh")
+}
+
+// signers collects keys from the SSH agent and from the configured key file (or the
+// default identity files). The returned function closes the agent connection.
+func signers(runtime *types.RuntimeConfig) ([]ssh.Signer, func(), error) {
+	var keys []ssh.Signer
+	closeAgent := func() {}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closeAgent = func() { _ = conn.Close() }
+			if agentKeys, err := agent.NewClient(conn).Signers(); err == nil {
+				keys = append(keys, agentKeys...)
+			} else {
+				utils.Debug("SSH agent signers unavailable: %v", err)
+			}
+		} else {
+			utils.Debug("SSH agent unreachable: %v", err)
+		}
+	}
+
+	if runtime != nil && runtime.SSHKeyFile != "" {
+		key, err := loadKey(runtime.SSHKeyFile)
+		if err != nil {
+			closeAgent()
+			return nil, nil, fmt.Errorf("ssh key file: %w", err)
+		}
+		keys = append(keys, key)
+	} else if dir := sshDir(); dir != "" {
+		for _, name := range defaultIdentityFiles {
+			// Missing and passphrase-protected keys are skipped; the agent covers the latter
+			if key, err := loadKey(filepath.Join(dir, name)); err == nil {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, closeAgent, nil
+}
+
+// loadKey reads an unencrypted private key
+func loadKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("%s is passphrase-protected; add it to your SSH agent instead", path)
+	}
+	return key, err
+}
+
+// hostKeyCallback verifies servers against the configured known_hosts file.
+// Unknown hosts are rejected: add them with ssh-keyscan or by connecting once with ssh.
+func hostKeyCallback(runtime *types.RuntimeConfig) (ssh.HostKeyCallback, error) {
+	path := ""
+	if runtime != nil {
+		path = runtime.SSHKnownHostsFile
+	}
+	if path == "" {
+		dir := sshDir()
+		if dir == "" {
+			return nil, fmt.Errorf("cannot verify host key: home directory unknown")
+		}
+		path = filepath.Join(dir, "known_hosts")
+	}
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify host key: %w", err)
+	}
+	return cb, nil
+}
+
+// hostKeyAlgorithms lists the algorithms of the keys known for addr, so the server
+// presents a key we can verify rather than its first preference
+func hostKeyAlgorithms(cb ssh.HostKeyCallback, addr string) []string {
+	remote, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil
+	}
+	// A throwaway key never matches, so the error lists the known keys
+	placeholder, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(cb(addr, remote, placeholder), &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, known := range keyErr.Want {
+		switch known.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, known.Key.Type())
+		}
+	}
+	return algos
+}
+
+// dial opens an authenticated SSH connection. It is closed when ctx is cancelled,
+// which aborts any SFTP reads running over it.
+func dial(ctx context.Context, t *target, runtime *types.RuntimeConfig) (*ssh.Client, func(), error) {
+	hostKeys, err := hostKeyCallback(runtime)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, closeAgent, err := signers(runtime)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeAgent()
+
+	var auth []ssh.AuthMethod
+	if len(keys) > 0 {
+		auth = append(auth, ssh.PublicKeys(keys...))
+	}
+	if t.password != "" {
+		auth = append(auth, ssh.Password(t.password))
+	}
+	if len(auth) == 0 {
+		return nil, nil, fmt.Errorf("no SSH credentials: start an SSH agent or set an SSH key file in settings")
+	}
+
+	dialer := net.Dialer{Timeout: types.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh connect failed: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	// Bound the handshake; the deadline is cleared once authenticated
+	_ = conn.SetDeadline(time.Now().Add(types.DefaultResponseHeaderTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.addr, &ssh.ClientConfig{
+		User:              t.user,
+		Auth:              auth,
+		HostKeyCallback:   hostKeys,
+		HostKeyAlgorithms: hostKeyAlgorithms(hostKeys, t.addr),
+		Timeout:           types.DialTimeout,
+	})
+	if err != nil {
+		stop()
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("ssh login failed: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+	closeClient := func() {
+		stop()
+		_ = client.Close()
+	}
+	return client, closeClient, nil
+}
@@ -0,0 +1,415 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gosftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/diskspace"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// SFTPDownloader fetches a file over several SFTP channels multiplexed on one SSH
+// connection. Each channel reads its own byte ranges, so downloads can be paused and
+// resumed like HTTP range downloads.
+type SFTPDownloader struct {
+	ProgressChan chan<- any           // Channel for events (start/complete/error)
+	ID           string               // Download ID
+	State        *types.ProgressState // Shared state for TUI polling
+	Runtime      *types.RuntimeConfig
+	URL          string // For pause/resume
+	DestPath     string // For pause/resume
+	Checksum     string // Expected digest ("algo:hex"), verified before finalizing
+	LastModified string // Modification time from the probe, compared on resume
+	Extract      string // Extract mode on completion, carried across pauses
+
+	segments   map[int]*segment // Segments in flight, by channel
+	segmentsMu sync.Mutex
+}
+
+// segment is a byte range being read by one channel
+type segment struct {
+	task    types.Task
+	current atomic.Int64 // Next offset to write
+}
+
+// remaining returns the part of the segment not yet written, or nil if done
+func (s *segment) remaining() *types.Task {
+	current := s.current.Load()
+	end := s.task.Offset + s.task.Length
+	if current >= end {
+		return nil
+	}
+	return &types.Task{Offset: current, Length: end - current}
+}
+
+// taskList is the shared queue of segments waiting for a channel
+type taskList struct {
+	mu    sync.Mutex
+	tasks []types.Task
+}
+
+func (q *taskList) pop() (types.Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tasks) == 0 {
+		return types.Task{}, false
+	}
+	task := q.tasks[0]
+	q.tasks = q.tasks[1:]
+	return task, true
+}
+
+func (q *taskList) push(task types.Task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tasks = append(q.tasks, task)
+}
+
+func (q *taskList) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.tasks)
+}
+
+func (q *taskList) drain() []types.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	tasks := q.tasks
+	q.tasks = nil
+	return tasks
+}
+
+// NewSFTPDownloader creates a new SFTP downloader with all required parameters
+func NewSFTPDownloader(id string, progressCh chan<- any, progState *types.ProgressState, runtime *types.RuntimeConfig) *SFTPDownloader {
+	return &SFTPDownloader{
+		ID:           id,
+		ProgressChan: progressCh,
+		State:        progState,
+		Runtime:      runtime,
+		segments:     make(map[int]*segment),
+	}
+}
+
+// channels returns how many SFTP channels to read with
+func (d *SFTPDownloader) channels(fileSize int64) int {
+	n := min(d.Runtime.GetMaxConnectionsPerHost(), MaxChannels)
+	if minChunk := d.Runtime.GetMinChunkSize(); minChunk > 0 {
+		n = min(n, int(fileSize/minChunk))
+	}
+	return max(n, 1)
+}
+
+// splitTasks divides the file into n segments aligned to AlignSize
+func splitTasks(fileSize int64, n int) ([]types.Task, int64) {
+	size := (fileSize + int64(n) - 1) / int64(n)
+	size = (size + types.AlignSize - 1) / types.AlignSize * types.AlignSize
+
+	var tasks []types.Task
+	for offset := int64(0); offset < fileSize; offset += size {
+		tasks = append(tasks, types.Task{Offset: offset, Length: min(size, fileSize-offset)})
+	}
+	return tasks, size
+}
+
+// Download fetches rawurl into destPath, resuming from saved state when the remote
+// file is unchanged
+func (d *SFTPDownloader) Download(ctx context.Context, rawurl, destPath string, fileSize int64, verbose bool) error {
+	utils.Debug("SFTPDownloader.Download: %s -> %s (size: %d)", rawurl, destPath, fileSize)
+
+	t, err := parseTarget(rawurl)
+	if err != nil {
+		return err
+	}
+
+	// Store URL and path for pause/resume (final path without .surge)
+	d.URL = rawurl
+	d.DestPath = destPath
+	workingPath := destPath + types.IncompleteSuffix
+
+	// Create cancellable context for pause support
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if d.State != nil {
+		d.State.CancelFunc = cancel
+	}
+
+	numChannels := d.channels(fileSize)
+	tasks, segmentSize := splitTasks(fileSize, numChannels)
+
+	if verbose {
+		fmt.Printf("File size: %s, channels: %d, segment size: %s\n",
+			utils.ConvertBytesToHumanReadable(fileSize),
+			numChannels,
+			utils.ConvertBytesToHumanReadable(segmentSize))
+	}
+
+	// Initialize chunk visualization
+	if d.State != nil && fileSize > 0 {
+		d.State.InitBitmap(fileSize, segmentSize)
+	}
+
+	outFile, err := os.OpenFile(workingPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		if err := outFile.Close(); err != nil {
+			utils.Debug("Error closing file: %v", err)
+		}
+	}()
+
+	// Check for saved state BEFORE truncating (resume case).
+	// Never stitch bytes from two different versions of the remote file together.
+	savedState, err := state.LoadState(rawurl, destPath)
+	isResume := err == nil && savedState != nil && len(savedState.Tasks) > 0 &&
+		savedState.TotalSize == fileSize && savedState.LastModified == d.LastModified
+
+	if isResume {
+		tasks = savedState.Tasks
+		if d.State != nil {
+			d.State.Downloaded.Store(savedState.Downloaded)
+			d.State.SetSavedElapsed(time.Duration(savedState.Elapsed))
+			d.State.SyncSessionStart()
+			if len(savedState.ChunkBitmap) > 0 && savedState.ActualChunkSize > 0 {
+				d.State.RestoreBitmap(savedState.ChunkBitmap, savedState.ActualChunkSize)
+				d.State.RecalculateProgress(savedState.Tasks)
+			}
+		}
+		utils.Debug("Resuming SFTP download: %d segments, %d bytes downloaded", len(tasks), savedState.Downloaded)
+	} else {
+		if err := diskspace.Allocate(outFile, fileSize, d.Runtime != nil && d.Runtime.PreallocateFiles); err != nil {
+			return fmt.Errorf("failed to preallocate file: %w", err)
+		}
+		if d.State != nil {
+			d.State.Downloaded.Store(0)
+			d.State.SyncSessionStart()
+		}
+	}
+
+	startTime := time.Now()
+	queue := &taskList{tasks: tasks}
+	numChannels = min(numChannels, len(tasks))
+
+	// A failure usually means the SSH connection dropped: reconnect and pick up
+	// every unfinished range, keeping what was already written
+	var downloadErr error
+	maxRetries := d.Runtime.GetMaxTaskRetries()
+	for attempt := 0; queue.len() > 0; attempt++ {
+		if attempt > 0 {
+			metrics.Retries.Inc()
+			select {
+			case <-downloadCtx.Done():
+			case <-time.After(time.Duration(1<<attempt) * types.RetryBaseDelay):
+			}
+		}
+		downloadErr = d.runChannels(downloadCtx, t, outFile, queue, numChannels)
+		if downloadErr == nil || downloadCtx.Err() != nil || attempt+1 >= maxRetries {
+			break
+		}
+		utils.Debug("SFTP attempt %d failed, reconnecting: %v", attempt+1, downloadErr)
+		d.requeueSegments(queue)
+	}
+
+	// Handle pause: save the remaining ranges for resume
+	if d.State != nil && d.State.IsPaused() {
+		remainingTasks := queue.drain()
+		d.segmentsMu.Lock()
+		for _, seg := range d.segments {
+			if remaining := seg.remaining(); remaining != nil {
+				remainingTasks = append(remainingTasks, *remaining)
+			}
+		}
+		d.segmentsMu.Unlock()
+
+		var remainingBytes int64
+		for _, task := range remainingTasks {
+			remainingBytes += task.Length
+		}
+		bitmap, _, _, chunkSize, _ := d.State.GetBitmap()
+
+		s := &types.DownloadState{
+			URL:             d.URL,
+			ID:              d.ID,
+			DestPath:        destPath,
+			TotalSize:       fileSize,
+			Downloaded:      fileSize - remainingBytes,
+			Tasks:           remainingTasks,
+			Filename:        filepath.Base(destPath),
+			Elapsed:         (d.State.SavedElapsed + time.Since(startTime)).Nanoseconds(),
+			Checksum:        d.Checksum,
+			LastModified:    d.LastModified,
+			Extract:         d.Extract,
+			SpeedLimit:      d.State.SpeedLimit.Limit(),
+			ChunkBitmap:     bitmap,
+			ActualChunkSize: chunkSize,
+		}
+		if err := state.SaveState(d.URL, destPath, s); err != nil {
+			utils.Debug("Failed to save pause state: %v", err)
+		}
+		utils.Debug("SFTP download paused, state saved (Downloaded=%d, RemainingTasks=%d)", s.Downloaded, len(remainingTasks))
+		return types.ErrPaused
+	}
+
+	if downloadCtx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	if downloadErr != nil {
+		return downloadErr
+	}
+
+	if err := outFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	_ = outFile.Close()
+
+	// Verify expected digest before exposing the file under its final name
+	if err := checksum.VerifyFile(workingPath, d.Checksum); err != nil {
+		return err
+	}
+	if err := os.Rename(workingPath, destPath); err != nil {
+		return fmt.Errorf("failed to rename completed file: %w", err)
+	}
+	_ = state.DeleteState(d.ID, d.URL, destPath)
+	return nil
+}
+
+// requeueSegments returns the unfinished part of every in-flight segment to the queue
+func (d *SFTPDownloader) requeueSegments(queue *taskList) {
+	d.segmentsMu.Lock()
+	defer d.segmentsMu.Unlock()
+	for id, seg := range d.segments {
+		if remaining := seg.remaining(); remaining != nil {
+			queue.push(*remaining)
+		}
+		delete(d.segments, id)
+	}
+}
+
+// runChannels opens one SSH connection and reads queued segments over n SFTP channels.
+// The first failure tears the connection down so the caller can retry from a clean state.
+func (d *SFTPDownloader) runChannels(ctx context.Context, t *target, file *os.File, queue *taskList, n int) error {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client, closeClient, err := dial(attemptCtx, t, d.Runtime)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+	metrics.HostConnections.Inc(t.addr)
+	defer metrics.HostConnections.Dec(t.addr)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(channelID int) {
+			defer wg.Done()
+			if err := d.channel(attemptCtx, channelID, client, t.path, file, queue); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// channel reads segments from the queue over its own SFTP session until the queue is empty
+func (d *SFTPDownloader) channel(ctx context.Context, id int, client *ssh.Client, remotePath string, file *os.File, queue *taskList) error {
+	sc, err := gosftp.NewClient(client, gosftp.UseConcurrentReads(true))
+	if err != nil {
+		return fmt.Errorf("sftp session failed: %w", err)
+	}
+	defer func() { _ = sc.Close() }()
+
+	remote, err := sc.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("sftp open failed: %w", err)
+	}
+	defer func() { _ = remote.Close() }()
+
+	if d.State != nil {
+		d.State.ActiveWorkers.Add(1)
+		defer d.State.ActiveWorkers.Add(-1)
+	}
+
+	buf := make([]byte, d.Runtime.GetWorkerBufferSize())
+	limiters := d.State.Limiters()
+	for {
+		task, ok := queue.pop()
+		if !ok {
+			return nil
+		}
+		seg := &segment{task: task}
+		seg.current.Store(task.Offset)
+		d.segmentsMu.Lock()
+		d.segments[id] = seg
+		d.segmentsMu.Unlock()
+
+		offset := task.Offset
+		end := task.Offset + task.Length
+		for offset < end {
+			readSize := min(int64(len(buf)), end-offset)
+			if throttled := int64(bandwidth.ReadSize(limiters...)); throttled > 0 && readSize > throttled {
+				readSize = throttled
+			}
+
+			n, readErr := remote.ReadAt(buf[:readSize], offset)
+			if n > 0 {
+				metrics.AddBytes(n)
+				if err := bandwidth.Wait(ctx, n, limiters...); err != nil {
+					return err
+				}
+				if _, err := file.WriteAt(buf[:n], offset); err != nil {
+					return fmt.Errorf("write error: %w", err)
+				}
+				if d.State != nil {
+					d.State.Downloaded.Add(int64(n))
+					d.State.UpdateChunkStatus(offset, int64(n), types.ChunkCompleted)
+				}
+				offset += int64(n)
+				seg.current.Store(offset)
+			}
+
+			if readErr != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if offset >= end {
+					break
+				}
+				if errors.Is(readErr, io.EOF) {
+					return fmt.Errorf("sftp file shrank: read ended at offset %d of %d", offset, end)
+				}
+				return fmt.Errorf("read error: %w", readErr)
+			}
+		}
+
+		d.segmentsMu.Lock()
+		delete(d.segments, id)
+		d.segmentsMu.Unlock()
+	}
+}
@@ -0,0 +1,276 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+// sshFixture is a server with a file to fetch and a runtime trusting it
+type sshFixture struct {
+	server  *testutil.SSHServer
+	runtime *types.RuntimeConfig
+	remote  string // Absolute path of the served file
+	data    []byte
+	dir     string
+}
+
+func newSSHFixture(t *testing.T, size int, opts ...testutil.SSHServerOption) *sshFixture {
+	t.Helper()
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("HOME", t.TempDir()) // Keep the real ~/.ssh out of the test
+
+	state.CloseDB()
+	dir := t.TempDir()
+	state.Configure(filepath.Join(dir, "surge.db"))
+	t.Cleanup(state.CloseDB)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_test")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := testutil.NewSSHServerT(t, "builder", append(opts, testutil.WithSSHAuthorizedKey(sshPub))...)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := server.WriteKnownHosts(knownHosts); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(dir, "artifact.bin")
+	if err := os.WriteFile(remote, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return &sshFixture{
+		server: server,
+		runtime: &types.RuntimeConfig{
+			MaxConnectionsPerHost: 4,
+			MinChunkSize:          256 * types.KB,
+			SSHKeyFile:            keyFile,
+			SSHKnownHostsFile:     knownHosts,
+		},
+		remote: remote,
+		data:   data,
+		dir:    dir,
+	}
+}
+
+func (f *sshFixture) url() string {
+	return f.server.URL(filepath.ToSlash(f.remote))
+}
+
+func TestProbe(t *testing.T) {
+	f := newSSHFixture(t, 100*int(types.KB))
+
+	info, err := Probe(context.Background(), f.url(), f.runtime)
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if info.Filename != "artifact.bin" || info.Size != int64(len(f.data)) || info.ModTime.IsZero() {
+		t.Errorf("Probe = %+v", info)
+	}
+
+	missing := f.server.URL(filepath.ToSlash(filepath.Join(f.dir, "missing.bin")))
+	if _, err := Probe(context.Background(), missing, f.runtime); err == nil {
+		t.Error("Probe succeeded for a missing file")
+	}
+	if _, err := Probe(context.Background(), f.server.URL(filepath.ToSlash(f.dir)), f.runtime); err == nil {
+		t.Error("Probe succeeded for a directory")
+	}
+}
+
+func TestProbe_RejectsUnknownHost(t *testing.T) {
+	f := newSSHFixture(t, 1024)
+
+	other := testutil.NewSSHServerT(t, "builder")
+	if err := other.WriteKnownHosts(f.runtime.SSHKnownHostsFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Probe(context.Background(), f.url(), f.runtime); err == nil {
+		t.Fatal("Probe accepted a host key missing from known_hosts")
+	}
+	if f.server.Logins.Load() != 0 {
+		t.Error("Credentials were sent to an unverified host")
+	}
+}
+
+func TestProbe_AgentAndPasswordAuth(t *testing.T) {
+	t.Run("Agent", func(t *testing.T) {
+		f := newSSHFixture(t, 1024)
+
+		// Move the key into an agent and drop it from settings
+		data, err := os.ReadFile(f.runtime.SSHKeyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ssh.ParseRawPrivateKey(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyring := agent.NewKeyring()
+		if err := keyring.Add(agent.AddedKey{PrivateKey: raw}); err != nil {
+			t.Fatal(err)
+		}
+		sock := filepath.Join(f.dir, "agent.sock")
+		ln, err := net.Listen("unix", sock)
+		if err != nil {
+			t.Skipf("unix sockets unavailable: %v", err)
+		}
+		defer func() { _ = ln.Close() }()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					_ = agent.ServeAgent(keyring, conn)
+					_ = conn.Close()
+				}()
+			}
+		}()
+		t.Setenv("SSH_AUTH_SOCK", sock)
+		f.runtime.SSHKeyFile = ""
+
+		if _, err := Probe(context.Background(), f.url(), f.runtime); err != nil {
+			t.Fatalf("Probe with agent failed: %v", err)
+		}
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		f := newSSHFixture(t, 1024, testutil.WithSSHPassword("hunter2"))
+		f.runtime.SSHKeyFile = ""
+
+		if _, err := Probe(context.Background(), f.url(), f.runtime); err == nil {
+			t.Fatal("Probe succeeded without credentials")
+		}
+		withPassword := "sftp://builder:hunter2@" + f.server.Addr() + filepath.ToSlash(f.remote)
+		if _, err := Probe(context.Background(), withPassword, f.runtime); err != nil {
+			t.Fatalf("Probe with password failed: %v", err)
+		}
+	})
+}
+
+func TestSFTPDownloader_Download(t *testing.T) {
+	f := newSSHFixture(t, int(2*types.MB)+123)
+
+	destPath := filepath.Join(f.dir, "out.bin")
+	progState := types.NewProgressState("sftp-download", int64(len(f.data)))
+	d := NewSFTPDownloader("sftp-download", nil, progState, f.runtime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := d.Download(ctx, f.url(), destPath, int64(len(f.data)), false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, f.data)
+	if got := f.server.Logins.Load(); got != 1 {
+		t.Errorf("Logins = %d, want channels multiplexed on one connection", got)
+	}
+	if got := f.server.Channels.Load(); got != 4 {
+		t.Errorf("Channels = %d, want 4", got)
+	}
+	if got := progState.Downloaded.Load(); got != int64(len(f.data)) {
+		t.Errorf("Downloaded = %d, want %d", got, len(f.data))
+	}
+	bitmap, _, _, _, _ := progState.GetBitmap()
+	if len(bitmap) == 0 {
+		t.Error("Chunk bitmap was not initialized")
+	}
+}
+
+func TestSFTPDownloader_EmptyFile(t *testing.T) {
+	f := newSSHFixture(t, 0)
+
+	destPath := filepath.Join(f.dir, "empty.bin")
+	d := NewSFTPDownloader("sftp-empty", nil, types.NewProgressState("sftp-empty", 0), f.runtime)
+	if err := d.Download(context.Background(), f.url(), destPath, 0, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	testutil.VerifyDownloadT(t, destPath, nil)
+}
+
+func TestSFTPDownloader_PauseResume(t *testing.T) {
+	f := newSSHFixture(t, int(1*types.MB), testutil.WithSSHByteLatency(time.Millisecond))
+	f.runtime.MaxConnectionsPerHost = 2
+	f.runtime.WorkerBufferSize = int(32 * types.KB) // Report progress at packet granularity
+	lastModified := time.Now().UTC().Format(time.RFC1123)
+
+	destPath := filepath.Join(f.dir, "out.bin")
+	progState := types.NewProgressState("sftp-resume", int64(len(f.data)))
+	d := NewSFTPDownloader("sftp-resume", nil, progState, f.runtime)
+	d.LastModified = lastModified
+
+	go func() {
+		for progState.Downloaded.Load() < 64*types.KB {
+			time.Sleep(5 * time.Millisecond)
+		}
+		progState.Pause()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := d.Download(ctx, f.url(), destPath, int64(len(f.data)), false)
+	if !errors.Is(err, types.ErrPaused) {
+		t.Fatalf("Download returned %v, want ErrPaused", err)
+	}
+
+	saved, err := state.LoadState(f.url(), destPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	var remaining int64
+	for _, task := range saved.Tasks {
+		remaining += task.Length
+	}
+	if saved.Downloaded == 0 || remaining == 0 || saved.Downloaded+remaining != int64(len(f.data)) {
+		t.Fatalf("Saved %d downloaded + %d remaining, want %d", saved.Downloaded, remaining, len(f.data))
+	}
+
+	// Resume from the partial file on a fresh downloader
+	resumed := types.NewProgressState("sftp-resume", int64(len(f.data)))
+	d = NewSFTPDownloader("sftp-resume", nil, resumed, f.runtime)
+	d.LastModified = lastModified
+	if err := d.Download(ctx, f.url(), destPath, int64(len(f.data)), false); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, f.data)
+	if got := resumed.Downloaded.Load(); got != int64(len(f.data)) {
+		t.Errorf("Downloaded after resume = %d, want %d", got, len(f.data))
+	}
+	if _, err := state.LoadState(f.url(), destPath); err == nil {
+		t.Error("State not deleted after completion")
+	}
+}
@@ -0,0 +1,56 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	gosftp "github.com/pkg/sftp"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// Info is what a probe learns about a remote file
+type Info struct {
+	Filename string
+	Size     int64
+	ModTime  time.Time
+}
+
+// Probe logs in and stats the remote file
+func Probe(ctx context.Context, rawurl string, runtime *types.RuntimeConfig) (*Info, error) {
+	t, err := parseTarget(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	client, closeClient, err := dial(ctx, t, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer closeClient()
+
+	sc, err := gosftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("sftp session failed: %w", err)
+	}
+	defer func() { _ = sc.Close() }()
+
+	fi, err := sc.Stat(t.path)
+	if err != nil {
+		return nil, fmt.Errorf("sftp stat failed: %w", err)
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("sftp: %s is not a regular file", t.path)
+	}
+
+	info := &Info{
+		Filename: path.Base(t.path),
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+	}
+	utils.Debug("SFTP probe complete - filename: %s, size: %d", info.Filename, info.Size)
+	return info, nil
+}
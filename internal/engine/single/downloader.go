@@ -6,8 +6,13 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/cookies"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
 	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
 )
@@ -22,12 +27,13 @@ type SingleDownloader struct {
 	State        *types.ProgressState // Shared state for TUI polling
 	Runtime      *types.RuntimeConfig
 	Headers      map[string]string // Custom HTTP headers (cookies, auth, etc.)
+	Checksum     string            // Expected digest ("algo:hex"), verified before finalizing
 }
 
 // NewSingleDownloader creates a new single-threaded downloader with all required parameters
 func NewSingleDownloader(id string, progressCh chan<- any, state *types.ProgressState, runtime *types.RuntimeConfig) *SingleDownloader {
 	return &SingleDownloader{
-		Client:       &http.Client{Timeout: 0},
+		Client:       &http.Client{Transport: cookies.Transport(nil)},
 		ProgressChan: progressCh,
 		ID:           id,
 		State:        state,
@@ -58,6 +64,9 @@ func (d *SingleDownloader) Download(ctx context.Context, rawurl, destPath string
 			utils.Debug("Error closing response body: %v", err)
 		}
 	}()
+	host := strings.ToLower(req.URL.Host)
+	metrics.HostConnections.Inc(host)
+	defer metrics.HostConnections.Dec(host)
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
@@ -84,6 +93,10 @@ func (d *SingleDownloader) Download(ctx context.Context, rawurl, destPath string
 	// Copy response body to file with context cancellation support
 	var written int64
 	buf := make([]byte, d.Runtime.GetWorkerBufferSize())
+	limiters := []*bandwidth.Limiter{bandwidth.Global()}
+	if d.State != nil {
+		limiters = []*bandwidth.Limiter{&d.State.SpeedLimit, bandwidth.Global()}
+	}
 
 	for {
 		// Check for context cancellation (allows clean shutdown)
@@ -94,8 +107,17 @@ func (d *SingleDownloader) Download(ctx context.Context, rawurl, destPath string
 		default:
 		}
 
-		nr, readErr := resp.Body.Read(buf)
+		readBuf := buf
+		if throttled := bandwidth.ReadSize(limiters...); throttled > 0 && throttled < len(buf) {
+			readBuf = buf[:throttled]
+		}
+
+		nr, readErr := resp.Body.Read(readBuf)
 		if nr > 0 {
+			metrics.AddBytes(nr)
+			if err := bandwidth.Wait(ctx, nr, limiters...); err != nil {
+				return err
+			}
 			nw, writeErr := outFile.Write(buf[0:nr])
 			if nw > 0 {
 				written += int64(nw)
@@ -125,6 +147,11 @@ func (d *SingleDownloader) Download(ctx context.Context, rawurl, destPath string
 		return fmt.Errorf("close error: %w", err)
 	}
 
+	// Verify expected digest before exposing the file under its final name
+	if err := checksum.VerifyFile(workingPath, d.Checksum); err != nil {
+		return err
+	}
+
 	// Rename .surge file to final destination
 	if err := os.Rename(workingPath, destPath); err != nil {
 		// Fallback: copy if rename fails (cross-device)
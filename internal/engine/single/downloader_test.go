@@ -2,6 +2,9 @@ package single
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -491,3 +494,65 @@ func TestSingleDownloader_Download_ContentIntegrity(t *testing.T) {
 		t.Error("Content should not be all zeros with random data")
 	}
 }
+
+// =============================================================================
+// SingleDownloader - Checksum verification
+// =============================================================================
+
+func TestSingleDownloader_ChecksumMatch(t *testing.T) {
+	tmpDir, cleanup, _ := testutil.TempDir("surge-checksum-single")
+	defer cleanup()
+
+	fileSize := int64(64 * types.KB)
+	server := testutil.NewMockServerT(t,
+		testutil.WithFileSize(fileSize),
+		testutil.WithRangeSupport(false),
+	)
+	defer server.Close()
+
+	// Mock server serves zeros
+	sum := sha256.Sum256(make([]byte, fileSize))
+
+	destPath := filepath.Join(tmpDir, "checksum_ok.bin")
+	downloader := NewSingleDownloader("checksum-ok", nil, nil, &types.RuntimeConfig{})
+	downloader.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := downloader.Download(ctx, server.URL(), destPath, fileSize, "checksum_ok.bin", false); err != nil {
+		t.Fatalf("Download with matching checksum failed: %v", err)
+	}
+
+	if err := testutil.VerifyFileSize(destPath, fileSize); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSingleDownloader_ChecksumMismatch(t *testing.T) {
+	tmpDir, cleanup, _ := testutil.TempDir("surge-checksum-bad-single")
+	defer cleanup()
+
+	fileSize := int64(64 * types.KB)
+	server := testutil.NewMockServerT(t,
+		testutil.WithFileSize(fileSize),
+		testutil.WithRangeSupport(false),
+	)
+	defer server.Close()
+
+	destPath := filepath.Join(tmpDir, "checksum_bad.bin")
+	downloader := NewSingleDownloader("checksum-bad", nil, nil, &types.RuntimeConfig{})
+	downloader.Checksum = "md5:00000000000000000000000000000000"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := downloader.Download(ctx, server.URL(), destPath, fileSize, "checksum_bad.bin", false)
+	if !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Error("Final file should not exist after failed verification")
+	}
+}
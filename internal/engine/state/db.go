@@ -70,6 +70,11 @@ func initDB() error {
 		length INTEGER,
 		FOREIGN KEY(download_id) REFERENCES downloads(id) ON DELETE CASCADE
 	);
+
+	CREATE TABLE IF NOT EXISTS torrents (
+		info_hash TEXT PRIMARY KEY,
+		info BLOB NOT NULL
+	);
 	`
 
 	if _, err := db.Exec(query); err != nil {
@@ -83,6 +88,39 @@ func initDB() error {
 	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN chunk_bitmap BLOB")
 	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN actual_chunk_size INTEGER")
 
+	// Migration: Add expected checksum column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN checksum TEXT")
+
+	// Migration: Add remote validator columns (resume change detection)
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN etag TEXT")
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN last_modified TEXT")
+
+	// Migration: Add scheduling columns
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN start_at INTEGER")
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN active_window TEXT")
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN output_dir TEXT")
+
+	// Migration: Add per-download bandwidth cap column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN speed_limit INTEGER")
+
+	// Migration: Add extract-on-complete mode column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN extract_mode TEXT")
+
+	// Migration: Add media stream variant selector column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN stream_variant TEXT")
+
+	// Migration: Add queue priority column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN priority INTEGER")
+
+	// Migration: Add download category column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN category TEXT")
+
+	// Migration: Add announced size column (e.g. from a Metalink)
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN expected_size INTEGER")
+
+	// Migration: Add encrypted request headers column
+	_, _ = db.Exec("ALTER TABLE downloads ADD COLUMN headers TEXT")
+
 	return nil
 }
 
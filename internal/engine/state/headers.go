@@ -0,0 +1,143 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// headersKeyFile holds the key request headers are encrypted with. It lives next
+// to the database, so a copy of the database alone does not reveal credentials.
+const headersKeyFile = "headers.key"
+
+// headersKey returns the encryption key for stored headers, creating it on first use
+func headersKey(create bool) ([]byte, error) {
+	dbMu.Lock()
+	path := filepath.Join(filepath.Dir(dbPath), headersKeyFile)
+	dbMu.Unlock()
+
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key in %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	// O_EXCL: if another process created the key first, use theirs
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return headersKey(false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+func headersCipher(create bool) (cipher.AEAD, error) {
+	key, err := headersKey(create)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load headers key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SaveHeaders stores a download's custom request headers (cookies, authorization)
+// encrypted, so that resuming it after a restart still authenticates. Empty
+// headers clear what was stored.
+func SaveHeaders(id string, headers map[string]string) error {
+	db := getDBHelper()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var sealed sql.NullString
+	if len(headers) > 0 {
+		plain, err := json.Marshal(headers)
+		if err != nil {
+			return err
+		}
+		aead, err := headersCipher(true)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		// The ID is authenticated so stored headers cannot be moved to another download
+		sealed.String = base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, []byte(id)))
+		sealed.Valid = true
+	}
+
+	result, err := db.Exec("UPDATE downloads SET headers = ? WHERE id = ?", sealed, id)
+	if err != nil {
+		return fmt.Errorf("failed to save headers: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("download not found: %s", id)
+	}
+	return nil
+}
+
+// LoadHeaders returns the headers stored by SaveHeaders, or nil if there are none
+func LoadHeaders(id string) (map[string]string, error) {
+	db := getDBHelper()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var sealed sql.NullString
+	err := db.QueryRow("SELECT headers FROM downloads WHERE id = ?", id).Scan(&sealed)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && sealed.String == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load headers: %w", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed.String)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt headers of %s: %w", id, err)
+	}
+	aead, err := headersCipher(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("corrupt headers of %s", id)
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt headers of %s: %w", id, err)
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal(plain, &headers); err != nil {
+		return nil, fmt.Errorf("corrupt headers of %s: %w", id, err)
+	}
+	return headers, nil
+}
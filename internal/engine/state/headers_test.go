@@ -0,0 +1,73 @@
+package state
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+func TestHeadersPersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/private.zip"
+	for _, id := range []string{"private-id", "other-id"} {
+		if err := SaveState(testURL, filepath.Join(tmpDir, id), &types.DownloadState{ID: id, URL: testURL, DestPath: filepath.Join(tmpDir, id)}); err != nil {
+			t.Fatalf("SaveState failed: %v", err)
+		}
+	}
+
+	headers := map[string]string{"Authorization": "Basic YWxpY2U6czNjcjN0", "X-Api-Key": "k"}
+	if err := SaveHeaders("private-id", headers); err != nil {
+		t.Fatalf("SaveHeaders failed: %v", err)
+	}
+
+	got, err := LoadHeaders("private-id")
+	if err != nil {
+		t.Fatalf("LoadHeaders failed: %v", err)
+	}
+	if !maps.Equal(got, headers) {
+		t.Errorf("LoadHeaders = %v, want %v", got, headers)
+	}
+
+	// Credentials are not stored in the clear
+	var raw string
+	if err := getDBHelper().QueryRow("SELECT headers FROM downloads WHERE id = ?", "private-id").Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(raw, "YWxpY2U6czNjcjN0") || strings.Contains(raw, "Authorization") {
+		t.Errorf("headers stored in the clear: %q", raw)
+	}
+	info, err := os.Stat(filepath.Join(tmpDir, headersKeyFile))
+	if err != nil {
+		t.Fatalf("key file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Sealed headers only open for the download they belong to
+	if _, err := getDBHelper().Exec("UPDATE downloads SET headers = ? WHERE id = ?", raw, "other-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHeaders("other-id"); err == nil {
+		t.Error("headers copied to another download were accepted")
+	}
+
+	// Empty headers clear them
+	if err := SaveHeaders("private-id", nil); err != nil {
+		t.Fatalf("SaveHeaders(nil) failed: %v", err)
+	}
+	if got, err := LoadHeaders("private-id"); err != nil || got != nil {
+		t.Errorf("LoadHeaders after clearing = %v, %v", got, err)
+	}
+
+	if err := SaveHeaders("missing-id", headers); err == nil {
+		t.Error("SaveHeaders should fail for an unknown download")
+	}
+}
@@ -41,8 +41,8 @@ func SaveState(url string, destPath string, state *types.DownloadState) error {
 		// 1. Upsert into downloads table
 		_, err := tx.Exec(`
 			INSERT INTO downloads (
-				id, url, dest_path, filename, status, total_size, downloaded, url_hash, created_at, paused_at, time_taken, mirrors, chunk_bitmap, actual_chunk_size
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				id, url, dest_path, filename, status, total_size, downloaded, url_hash, created_at, paused_at, time_taken, mirrors, chunk_bitmap, actual_chunk_size, checksum, etag, last_modified, speed_limit, extract_mode, stream_variant
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT(id) DO UPDATE SET
 				url=excluded.url,
 				dest_path=excluded.dest_path,
@@ -55,8 +55,14 @@ func SaveState(url string, destPath string, state *types.DownloadState) error {
 				time_taken=excluded.time_taken,
 				mirrors=excluded.mirrors,
 				chunk_bitmap=excluded.chunk_bitmap,
-				actual_chunk_size=excluded.actual_chunk_size
-		`, state.ID, state.URL, state.DestPath, state.Filename, "paused", state.TotalSize, state.Downloaded, state.URLHash, state.CreatedAt, state.PausedAt, state.Elapsed/1e6, strings.Join(state.Mirrors, ","), state.ChunkBitmap, state.ActualChunkSize)
+				actual_chunk_size=excluded.actual_chunk_size,
+				checksum=excluded.checksum,
+				etag=excluded.etag,
+				last_modified=excluded.last_modified,
+				speed_limit=excluded.speed_limit,
+				extract_mode=excluded.extract_mode,
+				stream_variant=excluded.stream_variant
+		`, state.ID, state.URL, state.DestPath, state.Filename, "paused", state.TotalSize, state.Downloaded, state.URLHash, state.CreatedAt, state.PausedAt, state.Elapsed/1e6, strings.Join(state.Mirrors, ","), state.ChunkBitmap, state.ActualChunkSize, state.Checksum, state.ETag, state.LastModified, state.SpeedLimit, state.Extract, state.Variant)
 		if err != nil {
 			return fmt.Errorf("failed to upsert download: %w", err)
 		}
@@ -133,12 +139,12 @@ func LoadState(url string, destPath string) (*types.DownloadState, error) {
 	}
 
 	var state types.DownloadState
-	var timeTaken, createdAt, pausedAt, actualChunkSize sql.NullInt64 // handle null
-	var mirrors sql.NullString                                        // handle null mirrors
+	var timeTaken, createdAt, pausedAt, actualChunkSize, startAt, speedLimit, priority, expectedSize sql.NullInt64 // handle null
+	var mirrors, checksum, etag, lastModified, window, extract, variant, category sql.NullString                   // handle null text columns
 	var chunkBitmap []byte
 
 	row := db.QueryRow(`
-		SELECT id, url, dest_path, filename, total_size, downloaded, url_hash, created_at, paused_at, time_taken, mirrors, chunk_bitmap, actual_chunk_size
+		SELECT id, url, dest_path, filename, total_size, downloaded, url_hash, created_at, paused_at, time_taken, mirrors, chunk_bitmap, actual_chunk_size, checksum, etag, last_modified, start_at, active_window, speed_limit, extract_mode, stream_variant, priority, category, expected_size
 		FROM downloads 
 		WHERE url = ? AND dest_path = ? AND status != 'completed'
 		ORDER BY paused_at DESC LIMIT 1
@@ -147,7 +153,7 @@ func LoadState(url string, destPath string) (*types.DownloadState, error) {
 	err := row.Scan(
 		&state.ID, &state.URL, &state.DestPath, &state.Filename,
 		&state.TotalSize, &state.Downloaded, &state.URLHash,
-		&createdAt, &pausedAt, &timeTaken, &mirrors, &chunkBitmap, &actualChunkSize,
+		&createdAt, &pausedAt, &timeTaken, &mirrors, &chunkBitmap, &actualChunkSize, &checksum, &etag, &lastModified, &startAt, &window, &speedLimit, &extract, &variant, &priority, &category, &expectedSize,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -172,6 +178,39 @@ func LoadState(url string, destPath string) (*types.DownloadState, error) {
 	if actualChunkSize.Valid {
 		state.ActualChunkSize = actualChunkSize.Int64
 	}
+	if checksum.Valid {
+		state.Checksum = checksum.String
+	}
+	if etag.Valid {
+		state.ETag = etag.String
+	}
+	if lastModified.Valid {
+		state.LastModified = lastModified.String
+	}
+	if startAt.Valid {
+		state.StartAt = startAt.Int64
+	}
+	if window.Valid {
+		state.Window = window.String
+	}
+	if speedLimit.Valid {
+		state.SpeedLimit = speedLimit.Int64
+	}
+	if extract.Valid {
+		state.Extract = extract.String
+	}
+	if variant.Valid {
+		state.Variant = variant.String
+	}
+	if priority.Valid {
+		state.Priority = int(priority.Int64)
+	}
+	if category.Valid {
+		state.Category = category.String
+	}
+	if expectedSize.Valid {
+		state.ExpectedSize = expectedSize.Int64
+	}
 	state.ChunkBitmap = chunkBitmap
 
 	// Load tasks
@@ -245,7 +284,7 @@ func LoadMasterList() (*types.MasterList, error) {
 	}
 
 	rows, err := db.Query(`
-		SELECT id, url, dest_path, filename, status, total_size, downloaded, completed_at, time_taken, url_hash, mirrors 
+		SELECT id, url, dest_path, filename, status, total_size, downloaded, completed_at, time_taken, url_hash, mirrors, checksum, start_at, active_window, output_dir, speed_limit, extract_mode, stream_variant, priority, category, expected_size
 		FROM downloads
 	`)
 	if err != nil {
@@ -260,12 +299,12 @@ func LoadMasterList() (*types.MasterList, error) {
 	var list types.MasterList
 	for rows.Next() {
 		var e types.DownloadEntry
-		var completedAt, timeTaken sql.NullInt64      // handle nulls
-		var filename, urlHash, mirrors sql.NullString // handle nulls
+		var completedAt, timeTaken, startAt, speedLimit, priority, expectedSize sql.NullInt64                  // handle nulls
+		var filename, urlHash, mirrors, checksum, window, outputDir, extract, variant, category sql.NullString // handle nulls
 
 		if err := rows.Scan(
 			&e.ID, &e.URL, &e.DestPath, &filename, &e.Status, &e.TotalSize, &e.Downloaded,
-			&completedAt, &timeTaken, &urlHash, &mirrors,
+			&completedAt, &timeTaken, &urlHash, &mirrors, &checksum, &startAt, &window, &outputDir, &speedLimit, &extract, &variant, &priority, &category, &expectedSize,
 		); err != nil {
 			return nil, err
 		}
@@ -285,6 +324,36 @@ func LoadMasterList() (*types.MasterList, error) {
 		if mirrors.Valid && mirrors.String != "" {
 			e.Mirrors = strings.Split(mirrors.String, ",")
 		}
+		if checksum.Valid {
+			e.Checksum = checksum.String
+		}
+		if startAt.Valid {
+			e.StartAt = startAt.Int64
+		}
+		if window.Valid {
+			e.Window = window.String
+		}
+		if outputDir.Valid {
+			e.OutputDir = outputDir.String
+		}
+		if speedLimit.Valid {
+			e.SpeedLimit = speedLimit.Int64
+		}
+		if extract.Valid {
+			e.Extract = extract.String
+		}
+		if variant.Valid {
+			e.Variant = variant.String
+		}
+		if priority.Valid {
+			e.Priority = int(priority.Int64)
+		}
+		if category.Valid {
+			e.Category = category.String
+		}
+		if expectedSize.Valid {
+			e.ExpectedSize = expectedSize.Int64
+		}
 
 		list.Downloads = append(list.Downloads, e)
 	}
@@ -307,8 +376,8 @@ func AddToMasterList(entry types.DownloadEntry) error {
 	return withTx(func(tx *sql.Tx) error {
 		_, err := tx.Exec(`
 			INSERT INTO downloads (
-				id, url, dest_path, filename, status, total_size, downloaded, completed_at, time_taken, url_hash, mirrors
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				id, url, dest_path, filename, status, total_size, downloaded, completed_at, time_taken, url_hash, mirrors, checksum, start_at, active_window, output_dir, speed_limit, extract_mode, stream_variant, priority, category, expected_size
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT(id) DO UPDATE SET
 				url=excluded.url,
 				dest_path=excluded.dest_path,
@@ -319,10 +388,21 @@ func AddToMasterList(entry types.DownloadEntry) error {
 				completed_at=excluded.completed_at,
 				time_taken=excluded.time_taken,
 				url_hash=excluded.url_hash,
-				mirrors=excluded.mirrors
+				mirrors=excluded.mirrors,
+				checksum=excluded.checksum,
+				start_at=excluded.start_at,
+				active_window=excluded.active_window,
+				output_dir=excluded.output_dir,
+				speed_limit=excluded.speed_limit,
+				extract_mode=excluded.extract_mode,
+				stream_variant=excluded.stream_variant,
+				priority=excluded.priority,
+				category=excluded.category,
+				expected_size=excluded.expected_size
 		`,
 			entry.ID, entry.URL, entry.DestPath, entry.Filename, entry.Status, entry.TotalSize, entry.Downloaded,
-			entry.CompletedAt, entry.TimeTaken, entry.URLHash, strings.Join(entry.Mirrors, ","))
+			entry.CompletedAt, entry.TimeTaken, entry.URLHash, strings.Join(entry.Mirrors, ","), entry.Checksum,
+			entry.StartAt, entry.Window, entry.OutputDir, entry.SpeedLimit, entry.Extract, entry.Variant, entry.Priority, entry.Category, entry.ExpectedSize)
 
 		return err
 	})
@@ -347,18 +427,18 @@ func GetDownload(id string) (*types.DownloadEntry, error) {
 	}
 
 	var e types.DownloadEntry
-	var completedAt, timeTaken sql.NullInt64
-	var urlHash, filename, mirrors sql.NullString
+	var completedAt, timeTaken, startAt, speedLimit, priority, expectedSize sql.NullInt64
+	var urlHash, filename, mirrors, checksum, window, outputDir, extract, variant, category sql.NullString
 
 	row := db.QueryRow(`
-		SELECT id, url, dest_path, filename, status, total_size, downloaded, completed_at, time_taken, url_hash, mirrors 
+		SELECT id, url, dest_path, filename, status, total_size, downloaded, completed_at, time_taken, url_hash, mirrors, checksum, start_at, active_window, output_dir, speed_limit, extract_mode, stream_variant, priority, category, expected_size
 		FROM downloads
 		WHERE id = ?
 	`, id)
 
 	if err := row.Scan(
 		&e.ID, &e.URL, &e.DestPath, &filename, &e.Status, &e.TotalSize, &e.Downloaded,
-		&completedAt, &timeTaken, &urlHash, &mirrors,
+		&completedAt, &timeTaken, &urlHash, &mirrors, &checksum, &startAt, &window, &outputDir, &speedLimit, &extract, &variant, &priority, &category, &expectedSize,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found
@@ -381,6 +461,36 @@ func GetDownload(id string) (*types.DownloadEntry, error) {
 	if mirrors.Valid && mirrors.String != "" {
 		e.Mirrors = strings.Split(mirrors.String, ",")
 	}
+	if checksum.Valid {
+		e.Checksum = checksum.String
+	}
+	if startAt.Valid {
+		e.StartAt = startAt.Int64
+	}
+	if window.Valid {
+		e.Window = window.String
+	}
+	if outputDir.Valid {
+		e.OutputDir = outputDir.String
+	}
+	if speedLimit.Valid {
+		e.SpeedLimit = speedLimit.Int64
+	}
+	if extract.Valid {
+		e.Extract = extract.String
+	}
+	if variant.Valid {
+		e.Variant = variant.String
+	}
+	if priority.Valid {
+		e.Priority = int(priority.Int64)
+	}
+	if category.Valid {
+		e.Category = category.String
+	}
+	if expectedSize.Valid {
+		e.ExpectedSize = expectedSize.Int64
+	}
 
 	return &e, nil
 }
@@ -395,7 +505,7 @@ func LoadPausedDownloads() ([]types.DownloadEntry, error) {
 
 	var paused []types.DownloadEntry
 	for _, e := range list.Downloads {
-		if e.Status == "paused" || e.Status == "queued" {
+		if e.Status == "paused" || e.Status == "queued" || e.Status == "scheduled" {
 			paused = append(paused, e)
 		}
 	}
@@ -455,6 +565,105 @@ func UpdateStatus(id string, status string) error {
 	return nil
 }
 
+// ResetProgress forgets what was downloaded of a download, so that resuming it
+// fetches the whole file again
+func ResetProgress(id string) error {
+	return withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE downloads SET downloaded = 0, chunk_bitmap = NULL, actual_chunk_size = 0,
+			etag = NULL, last_modified = NULL WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("failed to reset progress: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fmt.Errorf("download not found: %s", id)
+		}
+		if _, err := tx.Exec("DELETE FROM tasks WHERE download_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete tasks: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateSpeedLimit stores the per-download bandwidth cap (0 = unlimited)
+func UpdateSpeedLimit(id string, bytesPerSec int64) error {
+	db := getDBHelper()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	result, err := db.Exec("UPDATE downloads SET speed_limit = ? WHERE id = ?", bytesPerSec, id)
+	if err != nil {
+		return fmt.Errorf("failed to update speed limit: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	return nil
+}
+
+// UpdatePriority stores the queue priority of a download
+func UpdatePriority(id string, priority int) error {
+	db := getDBHelper()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	result, err := db.Exec("UPDATE downloads SET priority = ? WHERE id = ?", priority, id)
+	if err != nil {
+		return fmt.Errorf("failed to update priority: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	return nil
+}
+
+// UpdateExpectedSize stores the size a download's source announced for it
+func UpdateExpectedSize(id string, size int64) error {
+	db := getDBHelper()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	result, err := db.Exec("UPDATE downloads SET expected_size = ? WHERE id = ?", size, id)
+	if err != nil {
+		return fmt.Errorf("failed to update expected size: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	return nil
+}
+
+// UpdateCategory stores the category of a download
+func UpdateCategory(id, category string) error {
+	db := getDBHelper()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	result, err := db.Exec("UPDATE downloads SET category = ? WHERE id = ?", category, id)
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	return nil
+}
+
 // PauseAllDownloads pauses all non-completed downloads
 func PauseAllDownloads() error {
 	db := getDBHelper()
@@ -524,7 +733,7 @@ func LoadStates(ids []string) (map[string]*types.DownloadState, error) {
 
 	// 1. Load Downloads
 	query := fmt.Sprintf(`
-		SELECT id, url, dest_path, filename, total_size, downloaded, url_hash, created_at, paused_at, time_taken, mirrors, chunk_bitmap, actual_chunk_size
+		SELECT id, url, dest_path, filename, total_size, downloaded, url_hash, created_at, paused_at, time_taken, mirrors, chunk_bitmap, actual_chunk_size, checksum, etag, last_modified, start_at, active_window, speed_limit, extract_mode, stream_variant, priority, category, expected_size
 		FROM downloads
 		WHERE id IN (%s) AND status != 'completed'
 	`, inClause)
@@ -544,14 +753,14 @@ func LoadStates(ids []string) (map[string]*types.DownloadState, error) {
 
 	for rows.Next() {
 		var state types.DownloadState
-		var timeTaken, createdAt, pausedAt, actualChunkSize sql.NullInt64
-		var mirrors sql.NullString
+		var timeTaken, createdAt, pausedAt, actualChunkSize, startAt, speedLimit, priority, expectedSize sql.NullInt64
+		var mirrors, checksum, etag, lastModified, window, extract, variant, category sql.NullString
 		var chunkBitmap []byte
 
 		if err := rows.Scan(
 			&state.ID, &state.URL, &state.DestPath, &state.Filename,
 			&state.TotalSize, &state.Downloaded, &state.URLHash,
-			&createdAt, &pausedAt, &timeTaken, &mirrors, &chunkBitmap, &actualChunkSize,
+			&createdAt, &pausedAt, &timeTaken, &mirrors, &chunkBitmap, &actualChunkSize, &checksum, &etag, &lastModified, &startAt, &window, &speedLimit, &extract, &variant, &priority, &category, &expectedSize,
 		); err != nil {
 			return nil, err
 		}
@@ -571,6 +780,39 @@ func LoadStates(ids []string) (map[string]*types.DownloadState, error) {
 		if actualChunkSize.Valid {
 			state.ActualChunkSize = actualChunkSize.Int64
 		}
+		if checksum.Valid {
+			state.Checksum = checksum.String
+		}
+		if etag.Valid {
+			state.ETag = etag.String
+		}
+		if lastModified.Valid {
+			state.LastModified = lastModified.String
+		}
+		if startAt.Valid {
+			state.StartAt = startAt.Int64
+		}
+		if window.Valid {
+			state.Window = window.String
+		}
+		if speedLimit.Valid {
+			state.SpeedLimit = speedLimit.Int64
+		}
+		if extract.Valid {
+			state.Extract = extract.String
+		}
+		if variant.Valid {
+			state.Variant = variant.String
+		}
+		if priority.Valid {
+			state.Priority = int(priority.Int64)
+		}
+		if category.Valid {
+			state.Category = category.String
+		}
+		if expectedSize.Valid {
+			state.ExpectedSize = expectedSize.Int64
+		}
 		state.ChunkBitmap = chunkBitmap
 
 		states[state.ID] = &state
@@ -601,3 +843,35 @@ func LoadStates(ids []string) (map[string]*types.DownloadState, error) {
 
 	return states, nil
 }
+
+// SaveTorrentInfo caches a torrent's info dictionary so magnet links resume without
+// fetching metadata from peers again
+func SaveTorrentInfo(infoHash string, info []byte) error {
+	db := getDBHelper()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO torrents (info_hash, info) VALUES (?, ?)", infoHash, info); err != nil {
+		return fmt.Errorf("failed to save torrent info: %w", err)
+	}
+	return nil
+}
+
+// LoadTorrentInfo returns the cached info dictionary of a torrent, or nil if unknown
+func LoadTorrentInfo(infoHash string) ([]byte, error) {
+	db := getDBHelper()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var info []byte
+	err := db.QueryRow("SELECT info FROM torrents WHERE info_hash = ?", infoHash).Scan(&info)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load torrent info: %w", err)
+	}
+	return info, nil
+}
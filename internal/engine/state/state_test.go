@@ -612,3 +612,432 @@ func TestMirrorsPersistence(t *testing.T) {
 		t.Error("Completed download not found in list")
 	}
 }
+
+func TestChecksumPersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/checksum-test.iso"
+	testDestPath := filepath.Join(tmpDir, "checksum-test.iso")
+	expected := "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	state := &types.DownloadState{
+		ID:         "checksum-state-id",
+		URL:        testURL,
+		DestPath:   testDestPath,
+		TotalSize:  1000,
+		Downloaded: 100,
+		Filename:   "checksum-test.iso",
+		Checksum:   expected,
+	}
+
+	if err := SaveState(testURL, testDestPath, state); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loadedState, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loadedState.Checksum != expected {
+		t.Errorf("Loaded state checksum = %q, want %q", loadedState.Checksum, expected)
+	}
+
+	entry := types.DownloadEntry{
+		ID:       "checksum-entry-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Status:   "verify_failed",
+		Checksum: expected,
+	}
+	if err := AddToMasterList(entry); err != nil {
+		t.Fatalf("AddToMasterList failed: %v", err)
+	}
+
+	loaded, err := GetDownload("checksum-entry-id")
+	if err != nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if loaded == nil {
+		t.Fatal("GetDownload returned nil")
+	}
+	if loaded.Checksum != expected {
+		t.Errorf("Loaded entry checksum = %q, want %q", loaded.Checksum, expected)
+	}
+	if loaded.Status != "verify_failed" {
+		t.Errorf("Loaded entry status = %q, want verify_failed", loaded.Status)
+	}
+}
+
+func TestValidatorPersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/validator-test.bin"
+	testDestPath := filepath.Join(tmpDir, "validator-test.bin")
+
+	state := &types.DownloadState{
+		ID:           "validator-id",
+		URL:          testURL,
+		DestPath:     testDestPath,
+		TotalSize:    1000,
+		Downloaded:   500,
+		Filename:     "validator-test.bin",
+		ETag:         `"abc123"`,
+		LastModified: "Wed, 21 Oct 2015 07:28:00 GMT",
+		Tasks:        []types.Task{{Offset: 500, Length: 500}},
+	}
+	if err := SaveState(testURL, testDestPath, state); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loaded, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loaded.ETag != state.ETag || loaded.LastModified != state.LastModified {
+		t.Errorf("LoadState validators = (%q, %q), want (%q, %q)", loaded.ETag, loaded.LastModified, state.ETag, state.LastModified)
+	}
+
+	batch, err := LoadStates([]string{"validator-id"})
+	if err != nil {
+		t.Fatalf("LoadStates failed: %v", err)
+	}
+	if got := batch["validator-id"]; got == nil || got.ETag != state.ETag || got.LastModified != state.LastModified {
+		t.Errorf("LoadStates did not restore validators: %+v", got)
+	}
+}
+
+func TestSchedulePersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	startAt := time.Now().Add(2 * time.Hour).Unix()
+	entry := types.DownloadEntry{
+		ID:       "scheduled-entry-id",
+		URL:      "https://example.com/scheduled.iso",
+		URLHash:  URLHash("https://example.com/scheduled.iso"),
+		Filename: "scheduled.iso",
+		Status:   "scheduled",
+		StartAt:  startAt,
+		Window:   "01:00-07:00",
+	}
+	if err := AddToMasterList(entry); err != nil {
+		t.Fatalf("AddToMasterList failed: %v", err)
+	}
+
+	loaded, err := GetDownload(entry.ID)
+	if err != nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if loaded == nil {
+		t.Fatal("GetDownload returned nil")
+	}
+	if loaded.StartAt != startAt || loaded.Window != "01:00-07:00" {
+		t.Errorf("schedule = (%d, %q), want (%d, %q)", loaded.StartAt, loaded.Window, startAt, "01:00-07:00")
+	}
+
+	// Scheduled downloads are restored on startup alongside paused and queued ones
+	paused, err := LoadPausedDownloads()
+	if err != nil {
+		t.Fatalf("LoadPausedDownloads failed: %v", err)
+	}
+	found := false
+	for _, e := range paused {
+		if e.ID == entry.ID {
+			found = true
+			if e.Status != "scheduled" || e.Window != "01:00-07:00" {
+				t.Errorf("restored entry = (%q, %q), want (scheduled, 01:00-07:00)", e.Status, e.Window)
+			}
+		}
+	}
+	if !found {
+		t.Error("scheduled download not returned by LoadPausedDownloads")
+	}
+}
+
+func TestSpeedLimitPersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/limited.bin"
+	testDestPath := filepath.Join(tmpDir, "limited.bin")
+
+	state := &types.DownloadState{
+		ID:         "limited-id",
+		URL:        testURL,
+		DestPath:   testDestPath,
+		TotalSize:  1000,
+		Downloaded: 100,
+		Filename:   "limited.bin",
+		SpeedLimit: 512 * 1024,
+		Tasks:      []types.Task{{Offset: 100, Length: 900}},
+	}
+	if err := SaveState(testURL, testDestPath, state); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loaded, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loaded.SpeedLimit != state.SpeedLimit {
+		t.Errorf("LoadState SpeedLimit = %d, want %d", loaded.SpeedLimit, state.SpeedLimit)
+	}
+
+	// Live changes are persisted without rewriting the rest of the state
+	if err := UpdateSpeedLimit("limited-id", 2*1024*1024); err != nil {
+		t.Fatalf("UpdateSpeedLimit failed: %v", err)
+	}
+	batch, err := LoadStates([]string{"limited-id"})
+	if err != nil {
+		t.Fatalf("LoadStates failed: %v", err)
+	}
+	if got := batch["limited-id"]; got == nil || got.SpeedLimit != 2*1024*1024 {
+		t.Errorf("LoadStates did not restore speed limit: %+v", got)
+	}
+
+	entry, err := GetDownload("limited-id")
+	if err != nil || entry == nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if entry.SpeedLimit != 2*1024*1024 {
+		t.Errorf("entry SpeedLimit = %d, want %d", entry.SpeedLimit, 2*1024*1024)
+	}
+
+	if err := UpdateSpeedLimit("nonexistent-id", 1024); err == nil {
+		t.Error("UpdateSpeedLimit should fail for nonexistent ID")
+	}
+}
+
+func TestPriorityPersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/urgent.bin"
+	testDestPath := filepath.Join(tmpDir, "urgent.bin")
+
+	// Saving progress must not reset a priority set earlier
+	if err := AddToMasterList(types.DownloadEntry{
+		ID:       "urgent-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "urgent.bin",
+		Status:   "queued",
+		Priority: 1,
+	}); err != nil {
+		t.Fatalf("AddToMasterList failed: %v", err)
+	}
+	if err := SaveState(testURL, testDestPath, &types.DownloadState{
+		ID:       "urgent-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "urgent.bin",
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loaded, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loaded.Priority != 1 {
+		t.Errorf("LoadState Priority = %d, want 1", loaded.Priority)
+	}
+
+	if err := UpdatePriority("urgent-id", -1); err != nil {
+		t.Fatalf("UpdatePriority failed: %v", err)
+	}
+	batch, err := LoadStates([]string{"urgent-id"})
+	if err != nil {
+		t.Fatalf("LoadStates failed: %v", err)
+	}
+	if got := batch["urgent-id"]; got == nil || got.Priority != -1 {
+		t.Errorf("LoadStates did not restore priority: %+v", got)
+	}
+
+	entry, err := GetDownload("urgent-id")
+	if err != nil || entry == nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if entry.Priority != -1 {
+		t.Errorf("entry Priority = %d, want -1", entry.Priority)
+	}
+
+	if err := UpdatePriority("nonexistent-id", 1); err == nil {
+		t.Error("UpdatePriority should fail for nonexistent ID")
+	}
+}
+
+func TestCategoryPersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/movie.mp4"
+	testDestPath := filepath.Join(tmpDir, "movie.mp4")
+
+	// Categories matched after probing are written once the download has a row
+	if err := SaveState(testURL, testDestPath, &types.DownloadState{
+		ID:       "movie-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "movie.mp4",
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	if err := UpdateCategory("movie-id", "Video"); err != nil {
+		t.Fatalf("UpdateCategory failed: %v", err)
+	}
+
+	loaded, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loaded.Category != "Video" {
+		t.Errorf("LoadState Category = %q, want Video", loaded.Category)
+	}
+
+	if err := AddToMasterList(types.DownloadEntry{
+		ID:       "movie-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "movie.mp4",
+		Status:   "completed",
+		Category: "Video",
+	}); err != nil {
+		t.Fatalf("AddToMasterList failed: %v", err)
+	}
+	entry, err := GetDownload("movie-id")
+	if err != nil || entry == nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if entry.Category != "Video" {
+		t.Errorf("entry Category = %q, want Video", entry.Category)
+	}
+
+	if err := UpdateCategory("nonexistent-id", "Video"); err == nil {
+		t.Error("UpdateCategory should fail for nonexistent ID")
+	}
+}
+
+func TestExpectedSizePersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/distro.iso"
+	testDestPath := filepath.Join(tmpDir, "distro.iso")
+
+	// Pausing writes the state first; the announced size is added afterwards
+	if err := SaveState(testURL, testDestPath, &types.DownloadState{
+		ID:       "distro-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "distro.iso",
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	if err := UpdateExpectedSize("distro-id", 4096); err != nil {
+		t.Fatalf("UpdateExpectedSize failed: %v", err)
+	}
+
+	// Saving progress again must keep it
+	if err := SaveState(testURL, testDestPath, &types.DownloadState{
+		ID:       "distro-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "distro.iso",
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loaded, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loaded.ExpectedSize != 4096 {
+		t.Errorf("LoadState ExpectedSize = %d, want 4096", loaded.ExpectedSize)
+	}
+	batch, err := LoadStates([]string{"distro-id"})
+	if err != nil {
+		t.Fatalf("LoadStates failed: %v", err)
+	}
+	if got := batch["distro-id"]; got == nil || got.ExpectedSize != 4096 {
+		t.Errorf("LoadStates did not restore expected size: %+v", got)
+	}
+
+	if err := AddToMasterList(types.DownloadEntry{
+		ID:           "distro-id",
+		URL:          testURL,
+		DestPath:     testDestPath,
+		Filename:     "distro.iso",
+		Status:       "error",
+		ExpectedSize: 8192,
+	}); err != nil {
+		t.Fatalf("AddToMasterList failed: %v", err)
+	}
+	entry, err := GetDownload("distro-id")
+	if err != nil || entry == nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if entry.ExpectedSize != 8192 {
+		t.Errorf("entry ExpectedSize = %d, want 8192", entry.ExpectedSize)
+	}
+
+	if err := UpdateExpectedSize("nonexistent-id", 1); err == nil {
+		t.Error("UpdateExpectedSize should fail for nonexistent ID")
+	}
+}
+
+func TestExtractModePersistence(t *testing.T) {
+	tmpDir := setupTestDB(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer CloseDB()
+
+	testURL := "https://example.com/bundle.tar.gz"
+	testDestPath := filepath.Join(tmpDir, "bundle.tar.gz")
+
+	if err := SaveState(testURL, testDestPath, &types.DownloadState{
+		ID:       "extract-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "bundle.tar.gz",
+		Extract:  "delete",
+		Tasks:    []types.Task{{Offset: 0, Length: 100}},
+	}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loaded, err := LoadState(testURL, testDestPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if loaded.Extract != "delete" {
+		t.Errorf("LoadState Extract = %q, want delete", loaded.Extract)
+	}
+
+	// Completion rewrites the entry and must keep the mode for the extractor
+	if err := AddToMasterList(types.DownloadEntry{
+		ID:       "extract-id",
+		URL:      testURL,
+		DestPath: testDestPath,
+		Filename: "bundle.tar.gz",
+		Status:   "completed",
+		Extract:  "delete",
+	}); err != nil {
+		t.Fatalf("AddToMasterList failed: %v", err)
+	}
+	entry, err := GetDownload("extract-id")
+	if err != nil || entry == nil {
+		t.Fatalf("GetDownload failed: %v", err)
+	}
+	if entry.Extract != "delete" {
+		t.Errorf("entry Extract = %q, want delete", entry.Extract)
+	}
+}
@@ -0,0 +1,447 @@
+package stream
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDASHSegments bounds template expansion for malformed manifests
+const maxDASHSegments = 200000
+
+type mpd struct {
+	Type                      string      `xml:"type,attr"`
+	MediaPresentationDuration string      `xml:"mediaPresentationDuration,attr"`
+	BaseURL                   []string    `xml:"BaseURL"`
+	Periods                   []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	Duration       string             `xml:"duration,attr"`
+	BaseURL        []string           `xml:"BaseURL"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	MimeType        string              `xml:"mimeType,attr"`
+	ContentType     string              `xml:"contentType,attr"`
+	BaseURL         []string            `xml:"BaseURL"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+	Representations []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID              string              `xml:"id,attr"`
+	Bandwidth       int64               `xml:"bandwidth,attr"`
+	Width           int                 `xml:"width,attr"`
+	Height          int                 `xml:"height,attr"`
+	MimeType        string              `xml:"mimeType,attr"`
+	Codecs          string              `xml:"codecs,attr"`
+	BaseURL         []string            `xml:"BaseURL"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+}
+
+type mpdSegmentTemplate struct {
+	Media           string              `xml:"media,attr"`
+	Initialization  string              `xml:"initialization,attr"`
+	StartNumber     *int64              `xml:"startNumber,attr"`
+	Timescale       *int64              `xml:"timescale,attr"`
+	Duration        *int64              `xml:"duration,attr"`
+	SegmentTimeline *mpdSegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type mpdSegmentTimeline struct {
+	S []struct {
+		T *int64 `xml:"t,attr"`
+		D int64  `xml:"d,attr"`
+		R int64  `xml:"r,attr"`
+	} `xml:"S"`
+}
+
+type mpdSegmentList struct {
+	Timescale      *int64 `xml:"timescale,attr"`
+	Duration       *int64 `xml:"duration,attr"`
+	Initialization *struct {
+		SourceURL string `xml:"sourceURL,attr"`
+		Range     string `xml:"range,attr"`
+	} `xml:"Initialization"`
+	SegmentURLs []struct {
+		Media      string `xml:"media,attr"`
+		MediaRange string `xml:"mediaRange,attr"`
+	} `xml:"SegmentURL"`
+}
+
+// dashRepresentation is a representation with the attributes it inherits resolved
+type dashRepresentation struct {
+	variant  Variant
+	mimeType string
+	base     *url.URL
+	template *mpdSegmentTemplate
+	list     *mpdSegmentList
+}
+
+// dashManifest is a parsed static, single-period MPD
+type dashManifest struct {
+	duration        float64 // Seconds
+	representations []dashRepresentation
+}
+
+// parseDASH parses an MPD and collects the representations of its period.
+// Video adaptation sets are preferred; audio is only offered when there is no video.
+func parseDASH(base *url.URL, body []byte) (*dashManifest, error) {
+	var doc mpd
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid DASH manifest: %w", err)
+	}
+	if doc.Type == "dynamic" {
+		return nil, fmt.Errorf("live DASH streams are not supported")
+	}
+	if len(doc.Periods) == 0 {
+		return nil, fmt.Errorf("DASH manifest has no periods")
+	}
+	if len(doc.Periods) > 1 {
+		// Periods may switch codecs or resolution, so they cannot simply be concatenated
+		return nil, fmt.Errorf("multi-period DASH manifests are not supported")
+	}
+	period := doc.Periods[0]
+
+	m := &dashManifest{}
+	var err error
+	if period.Duration != "" {
+		m.duration, err = parseISODuration(period.Duration)
+	} else if doc.MediaPresentationDuration != "" {
+		m.duration, err = parseISODuration(doc.MediaPresentationDuration)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	periodBase, err := resolveBase(base, doc.BaseURL, period.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	byKind := map[string][]dashRepresentation{}
+	for _, set := range period.AdaptationSets {
+		setBase, err := resolveBase(periodBase, set.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		for _, rep := range set.Representations {
+			repBase, err := resolveBase(setBase, rep.BaseURL)
+			if err != nil {
+				return nil, err
+			}
+			mimeType := rep.MimeType
+			if mimeType == "" {
+				mimeType = set.MimeType
+			}
+			kind, _, _ := strings.Cut(mimeType, "/")
+			if kind == "" {
+				kind = set.ContentType
+			}
+
+			list := rep.SegmentList
+			if list == nil {
+				list = set.SegmentList
+			}
+			byKind[kind] = append(byKind[kind], dashRepresentation{
+				variant: Variant{
+					Bandwidth: rep.Bandwidth,
+					Width:     rep.Width,
+					Height:    rep.Height,
+					Codecs:    rep.Codecs,
+					URI:       rep.ID,
+				},
+				mimeType: mimeType,
+				base:     repBase,
+				template: mergeTemplates(set.SegmentTemplate, rep.SegmentTemplate),
+				list:     list,
+			})
+		}
+	}
+
+	for _, kind := range []string{"video", "audio"} {
+		if reps := byKind[kind]; len(reps) > 0 {
+			m.representations = reps
+			return m, nil
+		}
+	}
+	for _, reps := range byKind {
+		m.representations = append(m.representations, reps...)
+	}
+	if len(m.representations) == 0 {
+		return nil, fmt.Errorf("DASH manifest has no representations")
+	}
+	return m, nil
+}
+
+// variants lists the representations offered by the manifest
+func (m *dashManifest) variants() []Variant {
+	variants := make([]Variant, len(m.representations))
+	for i, rep := range m.representations {
+		variants[i] = rep.variant
+	}
+	return variants
+}
+
+// segments expands a representation into the URLs to fetch, initialization first
+func (m *dashManifest) segments(rep dashRepresentation) ([]Segment, error) {
+	switch {
+	case rep.template != nil && rep.template.Media != "":
+		return m.templateSegments(rep)
+	case rep.list != nil:
+		return listSegments(rep)
+	default:
+		// SegmentBase or a bare BaseURL: the representation is one file
+		return []Segment{{URL: rep.base.String(), Duration: m.duration}}, nil
+	}
+}
+
+func (m *dashManifest) templateSegments(rep dashRepresentation) ([]Segment, error) {
+	tmpl := rep.template
+	timescale := int64(1)
+	if tmpl.Timescale != nil && *tmpl.Timescale > 0 {
+		timescale = *tmpl.Timescale
+	}
+	number := int64(1)
+	if tmpl.StartNumber != nil {
+		number = *tmpl.StartNumber
+	}
+
+	var segments []Segment
+	if tmpl.Initialization != "" {
+		init, err := resolve(rep.base, expandTemplate(tmpl.Initialization, rep.variant, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, Segment{URL: init})
+	}
+
+	add := func(t, d int64) error {
+		if len(segments) > maxDASHSegments {
+			return fmt.Errorf("DASH manifest lists too many segments")
+		}
+		u, err := resolve(rep.base, expandTemplate(tmpl.Media, rep.variant, number, t))
+		if err != nil {
+			return err
+		}
+		segments = append(segments, Segment{URL: u, Duration: float64(d) / float64(timescale)})
+		number++
+		return nil
+	}
+
+	if tmpl.SegmentTimeline != nil {
+		var t int64
+		for i, s := range tmpl.SegmentTimeline.S {
+			if s.T != nil {
+				t = *s.T
+			}
+			repeat := s.R
+			if repeat < 0 {
+				// Repeat until the next S element or the end of the period
+				var end float64
+				if i+1 < len(tmpl.SegmentTimeline.S) && tmpl.SegmentTimeline.S[i+1].T != nil {
+					end = float64(*tmpl.SegmentTimeline.S[i+1].T)
+				} else {
+					end = m.duration * float64(timescale)
+				}
+				if s.D <= 0 || end <= float64(t) {
+					return nil, fmt.Errorf("invalid open-ended SegmentTimeline entry")
+				}
+				repeat = int64(math.Ceil((end-float64(t))/float64(s.D))) - 1
+			}
+			for r := int64(0); r <= repeat; r++ {
+				if err := add(t, s.D); err != nil {
+					return nil, err
+				}
+				t += s.D
+			}
+		}
+		return segments, nil
+	}
+
+	if tmpl.Duration == nil || *tmpl.Duration <= 0 {
+		return nil, fmt.Errorf("SegmentTemplate has neither a duration nor a timeline")
+	}
+	if m.duration <= 0 {
+		return nil, fmt.Errorf("DASH manifest does not state its duration")
+	}
+	segDuration := float64(*tmpl.Duration) / float64(timescale)
+	count := int64(math.Ceil(m.duration/segDuration - 1e-9))
+	for i := int64(0); i < count; i++ {
+		if err := add(i*(*tmpl.Duration), *tmpl.Duration); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+func listSegments(rep dashRepresentation) ([]Segment, error) {
+	list := rep.list
+	var segDuration float64
+	if list.Duration != nil {
+		timescale := int64(1)
+		if list.Timescale != nil && *list.Timescale > 0 {
+			timescale = *list.Timescale
+		}
+		segDuration = float64(*list.Duration) / float64(timescale)
+	}
+
+	var segments []Segment
+	if init := list.Initialization; init != nil {
+		seg, err := rangedSegment(rep.base, init.SourceURL, init.Range)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+	for _, su := range list.SegmentURLs {
+		seg, err := rangedSegment(rep.base, su.Media, su.MediaRange)
+		if err != nil {
+			return nil, err
+		}
+		seg.Duration = segDuration
+		segments = append(segments, seg)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("SegmentList is empty")
+	}
+	return segments, nil
+}
+
+// rangedSegment builds a segment from a URL (empty = the base) and an "a-b" range
+func rangedSegment(base *url.URL, ref, byteRange string) (Segment, error) {
+	u := base.String()
+	if ref != "" {
+		var err error
+		if u, err = resolve(base, ref); err != nil {
+			return Segment{}, err
+		}
+	}
+	seg := Segment{URL: u}
+	if byteRange != "" {
+		first, last, ok := strings.Cut(byteRange, "-")
+		a, err1 := strconv.ParseInt(first, 10, 64)
+		b, err2 := strconv.ParseInt(last, 10, 64)
+		if !ok || err1 != nil || err2 != nil || b < a {
+			return Segment{}, fmt.Errorf("invalid range %q in manifest", byteRange)
+		}
+		seg.Offset, seg.Length = a, b-a+1
+	}
+	return seg, nil
+}
+
+// dashExt picks the output extension from the representation's MIME type
+func dashExt(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case "video/webm", "audio/webm":
+		return ".webm"
+	case "audio/mp4":
+		return ".m4a"
+	default:
+		return ".mp4"
+	}
+}
+
+// resolveBase applies each level's first BaseURL in turn
+func resolveBase(base *url.URL, levels ...[]string) (*url.URL, error) {
+	for _, level := range levels {
+		if len(level) == 0 || strings.TrimSpace(level[0]) == "" {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(level[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid BaseURL %q: %w", level[0], err)
+		}
+		base = base.ResolveReference(ref)
+	}
+	return base, nil
+}
+
+// mergeTemplates overlays a representation's SegmentTemplate on its adaptation set's
+func mergeTemplates(parent, child *mpdSegmentTemplate) *mpdSegmentTemplate {
+	if parent == nil {
+		return child
+	}
+	if child == nil {
+		return parent
+	}
+	merged := *parent
+	if child.Media != "" {
+		merged.Media = child.Media
+	}
+	if child.Initialization != "" {
+		merged.Initialization = child.Initialization
+	}
+	if child.StartNumber != nil {
+		merged.StartNumber = child.StartNumber
+	}
+	if child.Timescale != nil {
+		merged.Timescale = child.Timescale
+	}
+	if child.Duration != nil {
+		merged.Duration = child.Duration
+	}
+	if child.SegmentTimeline != nil {
+		merged.SegmentTimeline = child.SegmentTimeline
+	}
+	return &merged
+}
+
+// templateIdentifier matches $Name$ and $Name%0Nd$ template identifiers
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Time|Bandwidth)(%0\d+d)?\$`)
+
+// expandTemplate substitutes SegmentTemplate identifiers; "$$" is a literal dollar
+func expandTemplate(tmpl string, v Variant, number, t int64) string {
+	parts := strings.Split(tmpl, "$$")
+	for i, part := range parts {
+		parts[i] = templateIdentifier.ReplaceAllStringFunc(part, func(match string) string {
+			sub := templateIdentifier.FindStringSubmatch(match)
+			format := "%d"
+			if sub[2] != "" {
+				format = sub[2]
+			}
+			switch sub[1] {
+			case "RepresentationID":
+				return v.URI
+			case "Number":
+				return fmt.Sprintf(format, number)
+			case "Time":
+				return fmt.Sprintf(format, t)
+			default:
+				return fmt.Sprintf(format, v.Bandwidth)
+			}
+		})
+	}
+	return strings.Join(parts, "$")
+}
+
+// isoDuration matches the xs:duration forms used by MPDs, e.g. PT1H2M3.5S or P1DT2H
+var isoDuration = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration converts an ISO 8601 duration to seconds
+func parseISODuration(s string) (float64, error) {
+	sub := isoDuration.FindStringSubmatch(strings.TrimSpace(s))
+	if sub == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("invalid duration %q in manifest", s)
+	}
+	var total float64
+	for i, unit := range []float64{86400, 3600, 60, 1} {
+		if sub[i+1] == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(sub[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q in manifest", s)
+		}
+		total += f * unit
+	}
+	return total, nil
+}
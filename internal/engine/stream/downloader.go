@@ -0,0 +1,514 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/concurrent"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// fallbackSegmentSize stands in for the segment size when the manifest gives no hint
+const fallbackSegmentSize = 1 << 20
+
+// StreamDownloader fetches the segments of a resolved Plan concurrently and appends
+// them in order to a single file. Each segment is one cell of the chunk map, so the
+// map is sized by a virtual length of (estimated segment size × segment count).
+type StreamDownloader struct {
+	ProgressChan chan<- any           // Channel for events (start/complete/error)
+	ID           string               // Download ID
+	State        *types.ProgressState // Shared state for TUI polling
+	Runtime      *types.RuntimeConfig
+	URL          string            // Manifest URL, for pause/resume
+	DestPath     string            // For pause/resume
+	Headers      map[string]string // Custom HTTP headers from browser (cookies, auth, etc.)
+	Checksum     string            // Expected digest ("algo:hex"), verified before finalizing
+	Extract      string            // Extract mode on completion, carried across pauses
+	Variant      string            // Variant selector, carried across pauses
+
+	hostLimiter *concurrent.HostLimiter // Per-host 429/503 backoff, shared with HTTP downloads
+	keys        map[string][]byte       // AES-128 keys by URI
+	keysMu      sync.Mutex
+}
+
+// segmentResult is a fetched (and decrypted) segment waiting to be written
+type segmentResult struct {
+	index int
+	data  []byte
+}
+
+// NewStreamDownloader creates a new stream downloader with all required parameters
+func NewStreamDownloader(id string, progressCh chan<- any, progState *types.ProgressState, runtime *types.RuntimeConfig) *StreamDownloader {
+	return &StreamDownloader{
+		ID:           id,
+		ProgressChan: progressCh,
+		State:        progState,
+		Runtime:      runtime,
+		hostLimiter:  concurrent.SharedHostLimiter(),
+		keys:         make(map[string][]byte),
+	}
+}
+
+// virtualChunkSize is the chunk map cell size: the estimated size of one segment
+func virtualChunkSize(estimatedSize int64, segments int) int64 {
+	if estimatedSize <= 0 {
+		return fallbackSegmentSize
+	}
+	return max(1, estimatedSize/int64(segments))
+}
+
+// Download fetches the segments of plan (resolved from the manifest at rawurl) into destPath
+func (d *StreamDownloader) Download(ctx context.Context, plan *Plan, rawurl, destPath string, verbose bool) error {
+	utils.Debug("StreamDownloader.Download: %s -> %s (%s, %d segments)", rawurl, destPath, plan.Kind, len(plan.Segments))
+
+	// Store URL and path for pause/resume (final path without .surge)
+	d.URL = rawurl
+	d.DestPath = destPath
+	workingPath := destPath + types.IncompleteSuffix
+
+	// Create cancellable context for pause support
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if d.State != nil {
+		d.State.CancelFunc = cancel
+	}
+
+	count := len(plan.Segments)
+	if count == 0 {
+		return fmt.Errorf("stream has no segments")
+	}
+	chunkSize := virtualChunkSize(plan.EstimatedSize, count)
+	virtualSize := chunkSize * int64(count)
+	numConns := min(d.Runtime.GetMaxConnectionsPerHost(), count)
+
+	if verbose {
+		fmt.Printf("Stream: %s, %d segments, ~%s, connections: %d\n",
+			strings.ToUpper(string(plan.Kind)), count,
+			utils.ConvertBytesToHumanReadable(plan.EstimatedSize), numConns)
+	}
+
+	if d.State != nil {
+		d.State.SetTotalSize(virtualSize)
+		d.State.InitBitmap(virtualSize, chunkSize)
+	}
+
+	outFile, err := os.OpenFile(workingPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		if err := outFile.Close(); err != nil {
+			utils.Debug("Error closing file: %v", err)
+		}
+	}()
+
+	// Resume after the last segment written, provided the saved state describes the same stream
+	next, written := 0, int64(0)
+	savedState, err := state.LoadState(rawurl, destPath)
+	if err == nil && savedState != nil && len(savedState.Tasks) > 0 &&
+		savedState.TotalSize == virtualSize && savedState.ActualChunkSize == chunkSize &&
+		savedState.Variant == d.Variant && savedState.Tasks[0].Offset%chunkSize == 0 {
+		info, statErr := outFile.Stat()
+		if statErr == nil && info.Size() >= savedState.Downloaded {
+			next = int(savedState.Tasks[0].Offset / chunkSize)
+			written = savedState.Downloaded
+		}
+	}
+	if next > 0 {
+		if d.State != nil {
+			d.State.Downloaded.Store(written)
+			d.State.SetSavedElapsed(time.Duration(savedState.Elapsed))
+			d.State.SyncSessionStart()
+			if len(savedState.ChunkBitmap) > 0 {
+				d.State.RestoreBitmap(savedState.ChunkBitmap, chunkSize)
+				d.State.RecalculateProgress(savedState.Tasks)
+			}
+		}
+		utils.Debug("Resuming stream at segment %d/%d, %d bytes written", next, count, written)
+	} else if d.State != nil {
+		d.State.Downloaded.Store(0)
+		d.State.SyncSessionStart()
+	}
+	// Drop segments that were partly written when the download stopped
+	if err := outFile.Truncate(written); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+
+	startTime := time.Now()
+	f := newFetcher(d.Runtime, d.Headers)
+
+	queue := concurrent.NewTaskQueue()
+	tasks := make([]types.Task, 0, count-next)
+	for i := next; i < count; i++ {
+		tasks = append(tasks, types.Task{Offset: int64(i) * chunkSize, Length: chunkSize})
+	}
+	queue.PushMultiple(tasks)
+	queue.Close()
+
+	// Workers may run ahead of the writer by a bounded number of segments held in memory
+	window := make(chan struct{}, 2*numConns)
+	results := make(chan segmentResult, numConns)
+	workerCtx, stopWorkers := context.WithCancel(downloadCtx)
+	defer stopWorkers()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, numConns)
+	for i := 0; i < numConns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.worker(workerCtx, f, plan, chunkSize, queue, window, results); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					errs <- err
+				}
+				stopWorkers()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Append segments in playback order as they arrive
+	pending := make(map[int][]byte)
+	var writeErr error
+	for res := range results {
+		if writeErr != nil {
+			continue
+		}
+		pending[res.index] = res.data
+		for data, ok := pending[next]; ok; data, ok = pending[next] {
+			delete(pending, next)
+			if _, err := outFile.WriteAt(data, written); err != nil {
+				writeErr = fmt.Errorf("write error: %w", err)
+				stopWorkers()
+				break
+			}
+			written += int64(len(data))
+			if d.State != nil {
+				d.State.UpdateChunkStatus(int64(next)*chunkSize, chunkSize, types.ChunkCompleted)
+			}
+			next++
+			<-window
+		}
+	}
+	close(errs)
+
+	// Handle pause: segments in flight are fetched again on resume
+	if d.State != nil && d.State.IsPaused() {
+		remainingTasks := []types.Task{{Offset: int64(next) * chunkSize, Length: int64(count-next) * chunkSize}}
+		d.State.Downloaded.Store(written)
+		bitmap, _, _, _, _ := d.State.GetBitmap()
+
+		s := &types.DownloadState{
+			URL:             d.URL,
+			ID:              d.ID,
+			DestPath:        destPath,
+			TotalSize:       virtualSize,
+			Downloaded:      written,
+			Tasks:           remainingTasks,
+			Filename:        filepath.Base(destPath),
+			Elapsed:         (d.State.SavedElapsed + time.Since(startTime)).Nanoseconds(),
+			Checksum:        d.Checksum,
+			Extract:         d.Extract,
+			Variant:         d.Variant,
+			SpeedLimit:      d.State.SpeedLimit.Limit(),
+			ChunkBitmap:     bitmap,
+			ActualChunkSize: chunkSize,
+		}
+		if err := state.SaveState(d.URL, destPath, s); err != nil {
+			utils.Debug("Failed to save pause state: %v", err)
+		}
+		utils.Debug("Stream download paused, state saved (Downloaded=%d, NextSegment=%d)", written, next)
+		return types.ErrPaused
+	}
+
+	if downloadCtx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err, ok := <-errs; ok {
+		return err
+	}
+	if next < count {
+		return fmt.Errorf("stream incomplete: %d of %d segments written", next, count)
+	}
+
+	if err := outFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	_ = outFile.Close()
+
+	// The size is known exactly now
+	if d.State != nil {
+		d.State.SetTotalSize(written)
+		d.State.Downloaded.Store(written)
+		d.State.VerifiedProgress.Store(written)
+	}
+
+	// Verify expected digest before exposing the file under its final name
+	if err := checksum.VerifyFile(workingPath, d.Checksum); err != nil {
+		return err
+	}
+	if err := os.Rename(workingPath, destPath); err != nil {
+		return fmt.Errorf("failed to rename completed file: %w", err)
+	}
+	_ = state.DeleteState(d.ID, d.URL, destPath)
+	return nil
+}
+
+// worker fetches queued segments and hands them to the writer
+func (d *StreamDownloader) worker(ctx context.Context, f *fetcher, plan *Plan, chunkSize int64, queue *concurrent.TaskQueue, window chan struct{}, results chan<- segmentResult) error {
+	for {
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		task, ok := queue.Pop()
+		if !ok {
+			<-window
+			return nil
+		}
+		index := int(task.Offset / chunkSize)
+
+		data, err := d.fetchSegment(ctx, f, plan.Segments[index], index, task)
+		if err != nil {
+			return err
+		}
+		select {
+		case results <- segmentResult{index: index, data: data}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fetchSegment downloads and decrypts one segment, retrying failures
+func (d *StreamDownloader) fetchSegment(ctx context.Context, f *fetcher, seg Segment, index int, task types.Task) ([]byte, error) {
+	if d.State != nil {
+		d.State.UpdateChunkStatus(task.Offset, 1, types.ChunkDownloading)
+		d.State.ActiveWorkers.Add(1)
+		defer d.State.ActiveWorkers.Add(-1)
+	}
+
+	host := segmentHost(seg.URL)
+	var lastErr error
+	maxRetries := d.Runtime.GetMaxTaskRetries()
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		var rlErr *types.RateLimitError
+		if attempt > 0 {
+			metrics.Retries.Inc()
+			// Rate-limited hosts are backed off by the host limiter instead
+			if !errors.As(lastErr, &rlErr) {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(1<<attempt) * types.RetryBaseDelay):
+				}
+			}
+		}
+
+		if err := d.hostLimiter.Acquire(ctx, host, d.Runtime.GetHostConnLimit(seg.URL), d.State.ExtendRateLimit); err != nil {
+			return nil, err
+		}
+		var data []byte
+		metrics.HostConnections.Inc(host)
+		data, lastErr = d.fetchOnce(ctx, f, seg, host)
+		metrics.HostConnections.Dec(host)
+		d.hostLimiter.Release(host)
+
+		if lastErr == nil {
+			if seg.Key != nil {
+				return d.decrypt(ctx, f, seg.Key, data)
+			}
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.As(lastErr, &rlErr) {
+			d.State.ExtendRateLimit(d.hostLimiter.Throttle(host, rlErr.RetryAfter))
+		}
+		utils.Debug("Stream segment %d failed (attempt %d): %v", index, attempt+1, lastErr)
+	}
+	return nil, fmt.Errorf("segment %d: %w", index, lastErr)
+}
+
+// fetchOnce downloads one segment into memory. Bytes are counted as they arrive and
+// taken back if the attempt fails.
+func (d *StreamDownloader) fetchOnce(ctx context.Context, f *fetcher, seg Segment, host string) (data []byte, err error) {
+	req, err := f.newRequest(ctx, http.MethodGet, seg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if seg.Length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seg.Offset, seg.Offset+seg.Length-1))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter, _ := concurrent.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return nil, &types.RateLimitError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+	}
+
+	var body io.Reader = resp.Body
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server ignored the range: skip to it in the full resource
+		if seg.Length > 0 {
+			if _, err := io.CopyN(io.Discard, resp.Body, seg.Offset); err != nil {
+				return nil, fmt.Errorf("failed to skip to byte range: %w", err)
+			}
+			body = io.LimitReader(resp.Body, seg.Length)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	d.hostLimiter.Success(host)
+	if d.State != nil {
+		d.State.SetRateLimitedUntil(time.Time{})
+	}
+
+	var out bytes.Buffer
+	if seg.Length > 0 {
+		out.Grow(int(seg.Length))
+	} else if resp.ContentLength > 0 {
+		out.Grow(int(resp.ContentLength))
+	}
+
+	var counted int64
+	defer func() {
+		if err != nil && d.State != nil {
+			d.State.Downloaded.Add(-counted)
+		}
+	}()
+
+	buf := make([]byte, d.Runtime.GetWorkerBufferSize())
+	limiters := d.State.Limiters()
+	for {
+		readBuf := buf
+		if throttled := bandwidth.ReadSize(limiters...); throttled > 0 && throttled < len(buf) {
+			readBuf = buf[:throttled]
+		}
+		n, readErr := body.Read(readBuf)
+		if n > 0 {
+			metrics.AddBytes(n)
+			if err := bandwidth.Wait(ctx, n, limiters...); err != nil {
+				return nil, err
+			}
+			out.Write(readBuf[:n])
+			counted += int64(n)
+			if d.State != nil {
+				d.State.Downloaded.Add(int64(n))
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("read error: %w", readErr)
+		}
+	}
+
+	if seg.Length > 0 && counted != seg.Length {
+		return nil, fmt.Errorf("segment ended early: got %d of %d bytes", counted, seg.Length)
+	}
+	if resp.ContentLength > 0 && seg.Length == 0 && counted != resp.ContentLength {
+		return nil, fmt.Errorf("segment ended early: got %d of %d bytes", counted, resp.ContentLength)
+	}
+	return out.Bytes(), nil
+}
+
+// decrypt reverses HLS AES-128 encryption: AES-CBC with PKCS#7 padding
+func (d *StreamDownloader) decrypt(ctx context.Context, f *fetcher, key *Key, data []byte) ([]byte, error) {
+	secret, err := d.key(ctx, f, key.URI)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment is not a whole number of blocks (%d bytes)", len(data))
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCDecrypter(block, key.IV).CryptBlocks(data, data)
+
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, fmt.Errorf("invalid padding in decrypted segment (wrong key?)")
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("invalid padding in decrypted segment (wrong key?)")
+		}
+	}
+	return data[:len(data)-pad], nil
+}
+
+// key fetches (once) the 16-byte AES key served at uri
+func (d *StreamDownloader) key(ctx context.Context, f *fetcher, uri string) ([]byte, error) {
+	d.keysMu.Lock()
+	defer d.keysMu.Unlock()
+	if k, ok := d.keys[uri]; ok {
+		return k, nil
+	}
+
+	req, err := f.newRequest(ctx, http.MethodGet, uri)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch decryption key: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch decryption key: %s", resp.Status)
+	}
+	k, err := io.ReadAll(io.LimitReader(resp.Body, aes.BlockSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decryption key: %w", err)
+	}
+	if len(k) != aes.BlockSize {
+		return nil, fmt.Errorf("decryption key is %d bytes, expected %d", len(k), aes.BlockSize)
+	}
+	d.keys[uri] = k
+	return k, nil
+}
+
+// segmentHost returns the host (with port) used to group requests for rate limiting
+func segmentHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+	return strings.ToLower(u.Host)
+}
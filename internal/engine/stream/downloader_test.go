@@ -0,0 +1,296 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+// streamServer serves an HLS stream of AES-128 encrypted segments and a DASH
+// stream of plain segments, counting requests per path
+type streamServer struct {
+	*httptest.Server
+	segments [][]byte // Plaintext segments
+	key      []byte
+	latency  time.Duration
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newStreamServer(t *testing.T, count, size int, latency time.Duration) *streamServer {
+	t.Helper()
+	s := &streamServer{key: make([]byte, 16), latency: latency, requests: make(map[string]int)}
+	if _, err := rand.Read(s.key); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		seg := make([]byte, size+i) // Uneven sizes exercise the padding
+		if _, err := rand.Read(seg); err != nil {
+			t.Fatal(err)
+		}
+		s.segments = append(s.segments, seg)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *streamServer) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *streamServer) plaintext() []byte {
+	return bytes.Join(s.segments, nil)
+}
+
+func (s *streamServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	s.mu.Unlock()
+
+	var index int
+	switch {
+	case r.URL.Path == "/master.m3u8":
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100000,RESOLUTION=320x180\nlo/index.m3u8\n")
+		fmt.Fprintf(w, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=1280x720\nhi/index.m3u8\n", 200000+8*len(s.segments[0]))
+	case r.URL.Path == "/hi/index.m3u8":
+		var b strings.Builder
+		b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-KEY:METHOD=AES-128,URI=\"/key.bin\"\n")
+		for i := range s.segments {
+			fmt.Fprintf(&b, "#EXTINF:1.0,\nseg%d.ts\n", i)
+		}
+		b.WriteString("#EXT-X-ENDLIST\n")
+		fmt.Fprint(w, b.String())
+	case r.URL.Path == "/key.bin":
+		_, _ = w.Write(s.key)
+	case r.URL.Path == "/manifest.mpd":
+		fmt.Fprintf(w, `<MPD type="static" mediaPresentationDuration="PT%dS"><Period><AdaptationSet mimeType="video/mp4">
+<SegmentTemplate media="dash/$Number$.m4s" startNumber="0" duration="1"/>
+<Representation id="v" bandwidth="%d"/></AdaptationSet></Period></MPD>`, len(s.segments), 8*len(s.segments[0]))
+	case scanIndex(r.URL.Path, "/hi/seg%d.ts", &index) && index < len(s.segments):
+		time.Sleep(s.latency)
+		_, _ = w.Write(encryptSegment(s.key, index, s.segments[index]))
+	case scanIndex(r.URL.Path, "/dash/%d.m4s", &index) && index < len(s.segments):
+		time.Sleep(s.latency)
+		_, _ = w.Write(s.segments[index])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func scanIndex(path, format string, index *int) bool {
+	n, err := fmt.Sscanf(path, format, index)
+	return err == nil && n == 1
+}
+
+// encryptSegment applies HLS AES-128 with the IV derived from the sequence number
+func encryptSegment(key []byte, sequence int, plain []byte) []byte {
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(bytes.Clone(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, sequenceIV(int64(sequence))).CryptBlocks(data, data)
+	return data
+}
+
+func TestStreamDownloader_HLSEncrypted(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-stream-test")
+	server := newStreamServer(t, 12, 40*1024, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	plan, err := Open(ctx, server.URL+"/master.m3u8", nil, Selector{}, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if plan.Kind != KindHLS || plan.Variants != 2 || plan.Variant.Height != 720 || len(plan.Segments) != 12 || plan.Ext != ".ts" {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	destPath := filepath.Join(tmpDir, "video.ts")
+	progState := types.NewProgressState("hls", 0)
+	d := NewStreamDownloader("hls", nil, progState, &types.RuntimeConfig{MaxConnectionsPerHost: 4})
+	if err := d.Download(ctx, plan, server.URL+"/master.m3u8", destPath, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	want := server.plaintext()
+	testutil.VerifyDownloadT(t, destPath, want)
+	if got := server.count("/key.bin"); got != 1 {
+		t.Errorf("Key fetched %d times, want once", got)
+	}
+	if server.count("/lo/index.m3u8") != 0 {
+		t.Error("Fetched the variant that was not selected")
+	}
+	if _, total, _, _, _, _ := progState.GetProgress(); total != int64(len(want)) {
+		t.Errorf("TotalSize = %d, want the joined size %d", total, len(want))
+	}
+	if _, width, _, _, _ := progState.GetBitmap(); width != len(plan.Segments) {
+		t.Errorf("Chunk map width = %d, want one cell per segment (%d)", width, len(plan.Segments))
+	}
+}
+
+func TestStreamDownloader_DASH(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-stream-test")
+	server := newStreamServer(t, 5, 16*1024, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	plan, err := Open(ctx, server.URL+"/manifest.mpd", nil, Selector{}, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if plan.Kind != KindDASH || len(plan.Segments) != 5 || plan.Ext != ".mp4" {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	destPath := filepath.Join(tmpDir, "video.mp4")
+	d := NewStreamDownloader("dash", nil, types.NewProgressState("dash", 0), &types.RuntimeConfig{MaxConnectionsPerHost: 2})
+	if err := d.Download(ctx, plan, server.URL+"/manifest.mpd", destPath, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	testutil.VerifyDownloadT(t, destPath, server.plaintext())
+}
+
+func TestStreamDownloader_ByteRanges(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-stream-test")
+	data := make([]byte, 300*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.m3u8" {
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-MAP:URI=\"all.mp4\",BYTERANGE=\"1000@0\"\n")
+			for offset := 1000; offset < len(data); offset += 50000 {
+				fmt.Fprintf(w, "#EXTINF:2,\n#EXT-X-BYTERANGE:%d@%d\nall.mp4\n", min(50000, len(data)-offset), offset)
+			}
+			fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+			return
+		}
+		http.ServeContent(w, r, "all.mp4", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	plan, err := Open(ctx, server.URL+"/index.m3u8", nil, Selector{}, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if plan.EstimatedSize != int64(len(data)) || plan.Ext != ".mp4" {
+		t.Fatalf("plan estimate = %d, ext %q; want exact size %d", plan.EstimatedSize, plan.Ext, len(data))
+	}
+
+	destPath := filepath.Join(tmpDir, "all.mp4")
+	d := NewStreamDownloader("ranges", nil, types.NewProgressState("ranges", 0), &types.RuntimeConfig{MaxConnectionsPerHost: 3})
+	if err := d.Download(ctx, plan, server.URL+"/index.m3u8", destPath, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	testutil.VerifyDownloadT(t, destPath, data)
+}
+
+func TestStreamDownloader_WrongKey(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-stream-test")
+	server := newStreamServer(t, 3, 4096, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	plan, err := Open(ctx, server.URL+"/master.m3u8", nil, Selector{}, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	server.key = make([]byte, 16) // Segments are now encrypted with a key the client never sees
+
+	d := NewStreamDownloader("wrong-key", nil, types.NewProgressState("wrong-key", 0), &types.RuntimeConfig{MaxConnectionsPerHost: 1, MaxTaskRetries: 1})
+	d.keys[plan.Segments[0].Key.URI] = bytes.Repeat([]byte{1}, 16)
+	if err := d.Download(ctx, plan, server.URL+"/master.m3u8", filepath.Join(tmpDir, "video.ts"), false); err == nil {
+		t.Fatal("Download succeeded with the wrong key")
+	}
+}
+
+func TestStreamDownloader_PauseResume(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-stream-test")
+	server := newStreamServer(t, 20, 32*1024, 20*time.Millisecond)
+	manifestURL := server.URL + "/master.m3u8"
+	runtime := &types.RuntimeConfig{MaxConnectionsPerHost: 2}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	plan, err := Open(ctx, manifestURL, nil, Selector{}, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	chunk := virtualChunkSize(plan.EstimatedSize, len(plan.Segments))
+
+	destPath := filepath.Join(tmpDir, "video.ts")
+	progState := types.NewProgressState("stream-resume", 0)
+	d := NewStreamDownloader("stream-resume", nil, progState, runtime)
+	d.Variant = "best"
+
+	go func() {
+		for progState.VerifiedProgress.Load() < 4*chunk {
+			time.Sleep(2 * time.Millisecond)
+		}
+		progState.Pause()
+		progState.CancelFunc()
+	}()
+
+	err = d.Download(ctx, plan, manifestURL, destPath, false)
+	if !errors.Is(err, types.ErrPaused) {
+		t.Fatalf("Download returned %v, want ErrPaused", err)
+	}
+
+	saved, err := state.LoadState(manifestURL, destPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if saved.Variant != "best" || len(saved.Tasks) != 1 || saved.ActualChunkSize != chunk {
+		t.Fatalf("Saved state = %+v", saved)
+	}
+	done := int(saved.Tasks[0].Offset / chunk)
+	if done < 4 || done >= len(plan.Segments) {
+		t.Fatalf("Saved %d written segments", done)
+	}
+	var wantWritten int
+	for _, seg := range server.segments[:done] {
+		wantWritten += len(seg)
+	}
+	if saved.Downloaded != int64(wantWritten) {
+		t.Errorf("Saved Downloaded = %d, want %d bytes of whole segments", saved.Downloaded, wantWritten)
+	}
+
+	// Resume on a fresh downloader: written segments are not fetched again
+	before := server.count("/hi/seg0.ts")
+	resumed := types.NewProgressState("stream-resume", 0)
+	d = NewStreamDownloader("stream-resume", nil, resumed, runtime)
+	d.Variant = "best"
+	if err := d.Download(ctx, plan, manifestURL, destPath, false); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	testutil.VerifyDownloadT(t, destPath, server.plaintext())
+	if got := server.count("/hi/seg0.ts"); got != before {
+		t.Errorf("Segment 0 fetched again on resume")
+	}
+	if _, err := state.LoadState(manifestURL, destPath); err == nil {
+		t.Error("State not deleted after completion")
+	}
+}
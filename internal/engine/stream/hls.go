@@ -0,0 +1,277 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// hlsMediaPlaylist is a parsed HLS media playlist
+type hlsMediaPlaylist struct {
+	Segments []Segment
+	HasMap   bool // Segments are fragmented MP4 (EXT-X-MAP)
+	Live     bool // No EXT-X-ENDLIST: the playlist is a snapshot of a live stream
+}
+
+// isHLSMaster reports whether an HLS playlist lists variants rather than segments
+func isHLSMaster(body string) bool {
+	return strings.Contains(body, "#EXT-X-STREAM-INF")
+}
+
+// parseHLSMaster returns the variants of a master playlist with absolute URIs
+func parseHLSMaster(base *url.URL, body string) ([]Variant, error) {
+	var variants []Variant
+	var pending *Variant
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			v := Variant{Codecs: attrs["CODECS"]}
+			v.Bandwidth, _ = strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			if w, h, ok := strings.Cut(attrs["RESOLUTION"], "x"); ok {
+				v.Width, _ = strconv.Atoi(w)
+				v.Height, _ = strconv.Atoi(h)
+			}
+			pending = &v
+		case strings.HasPrefix(line, "#"):
+			// Other tags (EXT-X-MEDIA, I-frame playlists, ...) are not downloaded
+		case pending != nil:
+			uri, err := resolve(base, line)
+			if err != nil {
+				return nil, err
+			}
+			pending.URI = uri
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("master playlist lists no variants")
+	}
+	return variants, nil
+}
+
+// parseHLSMedia returns the segments of a media playlist with absolute URLs. An
+// initialization section (EXT-X-MAP) is emitted before the first segment it applies to.
+func parseHLSMedia(base *url.URL, body string) (*hlsMediaPlaylist, error) {
+	playlist := &hlsMediaPlaylist{Live: true}
+
+	var (
+		sequence    int64
+		duration    float64
+		byteRange   string
+		key         *Key
+		keyHasIV    bool
+		currentMap  string
+		pendingMap  *Segment
+		lastURL     string
+		lastRangeAt int64
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case line == "":
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EXT-X-MEDIA-SEQUENCE %q", value)
+			}
+			sequence = n
+		case tag == "#EXTINF":
+			d, _, _ := strings.Cut(value, ",")
+			f, err := strconv.ParseFloat(strings.TrimSpace(d), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EXTINF %q", value)
+			}
+			duration = f
+		case tag == "#EXT-X-BYTERANGE":
+			byteRange = value
+		case tag == "#EXT-X-KEY":
+			attrs := parseAttributes(value)
+			switch attrs["METHOD"] {
+			case "NONE":
+				key, keyHasIV = nil, false
+			case "AES-128":
+				if format := attrs["KEYFORMAT"]; format != "" && format != "identity" {
+					return nil, fmt.Errorf("DRM-protected streams are not supported (key format %q)", format)
+				}
+				uri, err := resolve(base, attrs["URI"])
+				if err != nil {
+					return nil, err
+				}
+				key = &Key{URI: uri}
+				keyHasIV = false
+				if iv := attrs["IV"]; iv != "" {
+					b, err := parseIV(iv)
+					if err != nil {
+						return nil, err
+					}
+					key.IV = b
+					keyHasIV = true
+				}
+			default:
+				return nil, fmt.Errorf("%s encrypted streams are not supported", attrs["METHOD"])
+			}
+		case tag == "#EXT-X-MAP":
+			attrs := parseAttributes(value)
+			uri, err := resolve(base, attrs["URI"])
+			if err != nil {
+				return nil, err
+			}
+			seg := Segment{URL: uri}
+			if r := attrs["BYTERANGE"]; r != "" {
+				if seg.Offset, seg.Length, err = parseByteRange(r, 0); err != nil {
+					return nil, err
+				}
+			}
+			if id := fmt.Sprintf("%s@%d-%d", uri, seg.Offset, seg.Length); id != currentMap {
+				currentMap = id
+				// Only an explicit IV can apply to the initialization section
+				if key != nil && keyHasIV {
+					seg.Key = key
+				}
+				pendingMap = &seg
+			}
+			playlist.HasMap = true
+		case tag == "#EXT-X-ENDLIST":
+			playlist.Live = false
+		case strings.HasPrefix(line, "#"):
+			// Unhandled tags (discontinuities, program dates, ...) do not change the bytes
+		default:
+			uri, err := resolve(base, line)
+			if err != nil {
+				return nil, err
+			}
+			if pendingMap != nil {
+				playlist.Segments = append(playlist.Segments, *pendingMap)
+				pendingMap = nil
+			}
+
+			seg := Segment{URL: uri, Duration: duration}
+			if byteRange != "" {
+				// Without an explicit offset the range follows the previous one of the same resource
+				prevEnd := int64(0)
+				if uri == lastURL {
+					prevEnd = lastRangeAt
+				}
+				if seg.Offset, seg.Length, err = parseByteRange(byteRange, prevEnd); err != nil {
+					return nil, err
+				}
+				lastURL, lastRangeAt = uri, seg.Offset+seg.Length
+			}
+			if key != nil {
+				segKey := *key
+				if !keyHasIV {
+					segKey.IV = sequenceIV(sequence)
+				}
+				seg.Key = &segKey
+			}
+			playlist.Segments = append(playlist.Segments, seg)
+
+			sequence++
+			duration = 0
+			byteRange = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	if len(playlist.Segments) == 0 {
+		return nil, fmt.Errorf("playlist has no segments")
+	}
+	return playlist, nil
+}
+
+// hlsExt picks the output extension from the segment format
+func hlsExt(playlist *hlsMediaPlaylist) string {
+	if playlist.HasMap {
+		return ".mp4"
+	}
+	u, err := url.Parse(playlist.Segments[0].URL)
+	if err == nil {
+		switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+		case ".aac", ".mp3", ".ac3":
+			return ext
+		}
+	}
+	return ".ts"
+}
+
+// parseAttributes splits an HLS attribute list (KEY=value,KEY="quoted, value")
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		name = strings.TrimSpace(name)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[name] = strings.TrimSpace(value)
+		s = rest
+	}
+	return attrs
+}
+
+// parseByteRange parses "length[@offset]"; a missing offset continues from prevEnd
+func parseByteRange(s string, prevEnd int64) (offset, length int64, err error) {
+	l, o, hasOffset := strings.Cut(s, "@")
+	length, err = strconv.ParseInt(l, 10, 64)
+	if err != nil || length <= 0 {
+		return 0, 0, fmt.Errorf("invalid byte range %q", s)
+	}
+	offset = prevEnd
+	if hasOffset {
+		offset, err = strconv.ParseInt(o, 10, 64)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid byte range %q", s)
+		}
+	}
+	return offset, length, nil
+}
+
+// parseIV decodes a 0x-prefixed 128-bit hexadecimal IV
+func parseIV(s string) ([]byte, error) {
+	h := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	b, err := hex.DecodeString(h)
+	if err != nil || len(b) != 16 {
+		return nil, fmt.Errorf("invalid IV %q", s)
+	}
+	return b, nil
+}
+
+// sequenceIV is the implicit IV: the media sequence number as a 128-bit big-endian integer
+func sequenceIV(sequence int64) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	return iv
+}
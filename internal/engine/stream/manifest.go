@@ -0,0 +1,159 @@
+// Package stream downloads HLS and DASH media streams: it resolves a manifest to a
+// single variant, fetches its segments concurrently and joins them into one file.
+package stream
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the manifest format
+type Kind string
+
+const (
+	KindHLS  Kind = "hls"
+	KindDASH Kind = "dash"
+)
+
+// Variant is one rendition offered by a manifest
+type Variant struct {
+	Bandwidth int64 // Bits per second (0 = unknown)
+	Width     int
+	Height    int
+	Codecs    string
+	URI       string // HLS media playlist or DASH representation ID
+}
+
+// Segment is one piece of the selected variant, in playback order
+type Segment struct {
+	URL      string
+	Offset   int64   // Byte range start (with Length > 0)
+	Length   int64   // Byte range length (0 = whole resource)
+	Duration float64 // Seconds (0 for initialization segments)
+	Key      *Key    // AES-128 key, nil if the segment is not encrypted
+}
+
+// Key describes HLS AES-128 encryption for a run of segments
+type Key struct {
+	URI string
+	IV  []byte // 16 bytes, explicit or derived from the media sequence number
+}
+
+// Plan is a resolved manifest: what to fetch and how the result should be named
+type Plan struct {
+	Kind          Kind
+	Variant       Variant
+	Variants      int // Number of variants the manifest offered
+	Segments      []Segment
+	Ext           string // Output extension, e.g. ".ts" or ".mp4"
+	EstimatedSize int64  // Best guess at the joined size in bytes (0 = unknown)
+}
+
+// Duration returns the total playback time in seconds
+func (p *Plan) Duration() float64 {
+	var total float64
+	for _, seg := range p.Segments {
+		total += seg.Duration
+	}
+	return total
+}
+
+// manifestExts are URL extensions that identify a manifest
+var manifestExts = map[string]bool{".m3u8": true, ".m3u": true, ".mpd": true}
+
+// manifestTypes are Content-Types that identify a manifest
+var manifestTypes = map[string]bool{
+	"application/vnd.apple.mpegurl": true,
+	"application/x-mpegurl":         true,
+	"audio/mpegurl":                 true,
+	"audio/x-mpegurl":               true,
+	"application/dash+xml":          true,
+}
+
+// IsManifest reports whether a URL or the Content-Type it was served with points to
+// an HLS or DASH manifest rather than a plain file
+func IsManifest(rawurl, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && manifestTypes[strings.ToLower(mediaType)] {
+		return true
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return manifestExts[strings.ToLower(path.Ext(u.Path))]
+}
+
+// OutputName replaces a manifest file name's extension with the media extension
+func OutputName(name, ext string) string {
+	if manifestExts[strings.ToLower(path.Ext(name))] {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if name == "" {
+		name = "stream"
+	}
+	if strings.EqualFold(path.Ext(name), ext) {
+		return name
+	}
+	return name + ext
+}
+
+// resolve makes ref absolute against base
+func resolve(base *url.URL, ref string) (string, error) {
+	r, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q in manifest: %w", ref, err)
+	}
+	return base.ResolveReference(r).String(), nil
+}
+
+// Selector picks a variant: "best" (highest bandwidth), "worst", or "<height>p" for the
+// best variant no taller than height
+type Selector struct {
+	worst     bool
+	maxHeight int
+}
+
+// ParseSelector validates a variant selector. An empty string means "best".
+func ParseSelector(s string) (Selector, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "best":
+		return Selector{}, nil
+	case "worst":
+		return Selector{worst: true}, nil
+	}
+	if h, ok := strings.CutSuffix(s, "p"); ok {
+		if n, err := strconv.Atoi(h); err == nil && n > 0 {
+			return Selector{maxHeight: n}, nil
+		}
+	}
+	return Selector{}, fmt.Errorf("invalid variant %q (use best, worst or a height like 720p)", s)
+}
+
+// Choose returns the index of the selected variant. Variants with unknown height
+// satisfy any height limit; if none fits, the smallest variant is chosen.
+func (sel Selector) Choose(variants []Variant) int {
+	if len(variants) == 0 {
+		return -1
+	}
+	best, worst := -1, 0
+	for i, v := range variants {
+		if v.Bandwidth < variants[worst].Bandwidth {
+			worst = i
+		}
+		if sel.maxHeight > 0 && v.Height > sel.maxHeight {
+			continue
+		}
+		if best < 0 || v.Bandwidth > variants[best].Bandwidth {
+			best = i
+		}
+	}
+	if sel.worst || best < 0 {
+		return worst
+	}
+	return best
+}
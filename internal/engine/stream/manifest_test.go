@@ -0,0 +1,439 @@
+package stream
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func mustURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestIsManifest(t *testing.T) {
+	tests := []struct {
+		url         string
+		contentType string
+		want        bool
+	}{
+		{"https://cdn.example.com/live/master.m3u8", "", true},
+		{"https://cdn.example.com/video.MPD?token=abc", "", true},
+		{"https://cdn.example.com/watch", "application/vnd.apple.mpegurl", true},
+		{"https://cdn.example.com/watch", "application/dash+xml; charset=utf-8", true},
+		{"https://cdn.example.com/video.mp4", "video/mp4", false},
+		{"ftp://example.com/list.m3u8", "", false},
+		{"https://cdn.example.com/playlist.m3u8.zip", "application/zip", false},
+	}
+	for _, tt := range tests {
+		if got := IsManifest(tt.url, tt.contentType); got != tt.want {
+			t.Errorf("IsManifest(%q, %q) = %v, want %v", tt.url, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		name, ext, want string
+	}{
+		{"master.m3u8", ".ts", "master.ts"},
+		{"manifest.mpd", ".mp4", "manifest.mp4"},
+		{"episode-1", ".mp4", "episode-1.mp4"},
+		{"clip.mp4", ".mp4", "clip.mp4"},
+		{".m3u8", ".ts", "stream.ts"},
+	}
+	for _, tt := range tests {
+		if got := OutputName(tt.name, tt.ext); got != tt.want {
+			t.Errorf("OutputName(%q, %q) = %q, want %q", tt.name, tt.ext, got, tt.want)
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	variants := []Variant{
+		{Bandwidth: 800000, Height: 360},
+		{Bandwidth: 5000000, Height: 1080},
+		{Bandwidth: 2500000, Height: 720},
+		{Bandwidth: 400000, Height: 240},
+	}
+	tests := []struct {
+		selector string
+		want     int
+		wantErr  bool
+	}{
+		{"", 1, false},
+		{"best", 1, false},
+		{"BEST", 1, false},
+		{"worst", 3, false},
+		{"720p", 2, false},
+		{"480p", 0, false},
+		{"100p", 3, false}, // Nothing fits: smallest
+		{"720", 0, true},
+		{"0p", 0, true},
+		{"high", 0, true},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.selector)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSelector(%q) error = %v, wantErr %v", tt.selector, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got := sel.Choose(variants); got != tt.want {
+			t.Errorf("Choose(%q) = %d, want %d", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseHLSMaster(t *testing.T) {
+	body := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080
+https://other.example.com/hi/index.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,URI="low/iframe.m3u8"
+`
+	variants, err := parseHLSMaster(mustURL(t, "https://cdn.example.com/show/master.m3u8"), body)
+	if err != nil {
+		t.Fatalf("parseHLSMaster failed: %v", err)
+	}
+	want := []Variant{
+		{Bandwidth: 1280000, Width: 640, Height: 360, Codecs: "avc1.4d401e,mp4a.40.2", URI: "https://cdn.example.com/show/low/index.m3u8"},
+		{Bandwidth: 5000000, Width: 1920, Height: 1080, URI: "https://other.example.com/hi/index.m3u8"},
+	}
+	if len(variants) != len(want) {
+		t.Fatalf("got %d variants, want %d: %+v", len(variants), len(want), variants)
+	}
+	for i := range want {
+		if variants[i] != want[i] {
+			t.Errorf("variant %d = %+v, want %+v", i, variants[i], want[i])
+		}
+	}
+}
+
+func TestParseHLSMedia(t *testing.T) {
+	base := mustURL(t, "https://cdn.example.com/hls/index.m3u8")
+
+	t.Run("Encrypted", func(t *testing.T) {
+		body := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:7
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXTINF:10.0,
+seg7.ts
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/k2",IV=0x000102030405060708090a0b0c0d0e0f
+#EXTINF:9.5,
+seg8.ts
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4,
+seg9.ts
+#EXT-X-ENDLIST
+`
+		playlist, err := parseHLSMedia(base, body)
+		if err != nil {
+			t.Fatalf("parseHLSMedia failed: %v", err)
+		}
+		if playlist.Live || playlist.HasMap || len(playlist.Segments) != 3 {
+			t.Fatalf("playlist = %+v", playlist)
+		}
+		segs := playlist.Segments
+		if segs[0].URL != "https://cdn.example.com/hls/seg7.ts" || segs[0].Duration != 10 {
+			t.Errorf("segment 0 = %+v", segs[0])
+		}
+		if segs[0].Key == nil || segs[0].Key.URI != "https://cdn.example.com/hls/key.bin" || !bytes.Equal(segs[0].Key.IV, sequenceIV(7)) {
+			t.Errorf("segment 0 key = %+v, want IV from sequence 7", segs[0].Key)
+		}
+		if segs[1].Key == nil || segs[1].Key.IV[15] != 0x0f || segs[1].Key.URI != "https://keys.example.com/k2" {
+			t.Errorf("segment 1 key = %+v", segs[1].Key)
+		}
+		if segs[2].Key != nil {
+			t.Errorf("segment 2 should not be encrypted after METHOD=NONE")
+		}
+		if hlsExt(playlist) != ".ts" {
+			t.Errorf("ext = %q, want .ts", hlsExt(playlist))
+		}
+	})
+
+	t.Run("Byte ranges and map", func(t *testing.T) {
+		body := `#EXTM3U
+#EXT-X-MAP:URI="main.mp4",BYTERANGE="720@0"
+#EXTINF:6,
+#EXT-X-BYTERANGE:1000@720
+main.mp4
+#EXTINF:6,
+#EXT-X-BYTERANGE:1200
+main.mp4
+#EXT-X-ENDLIST
+`
+		playlist, err := parseHLSMedia(base, body)
+		if err != nil {
+			t.Fatalf("parseHLSMedia failed: %v", err)
+		}
+		want := []Segment{
+			{URL: "https://cdn.example.com/hls/main.mp4", Offset: 0, Length: 720},
+			{URL: "https://cdn.example.com/hls/main.mp4", Offset: 720, Length: 1000, Duration: 6},
+			{URL: "https://cdn.example.com/hls/main.mp4", Offset: 1720, Length: 1200, Duration: 6},
+		}
+		if len(playlist.Segments) != len(want) {
+			t.Fatalf("got %d segments, want %d: %+v", len(playlist.Segments), len(want), playlist.Segments)
+		}
+		for i := range want {
+			if playlist.Segments[i] != want[i] {
+				t.Errorf("segment %d = %+v, want %+v", i, playlist.Segments[i], want[i])
+			}
+		}
+		if hlsExt(playlist) != ".mp4" {
+			t.Errorf("ext = %q, want .mp4", hlsExt(playlist))
+		}
+	})
+
+	t.Run("Live snapshot", func(t *testing.T) {
+		playlist, err := parseHLSMedia(base, "#EXTM3U\n#EXTINF:2,\na.aac\n")
+		if err != nil {
+			t.Fatalf("parseHLSMedia failed: %v", err)
+		}
+		if !playlist.Live || hlsExt(playlist) != ".aac" {
+			t.Errorf("playlist = %+v, ext %q", playlist, hlsExt(playlist))
+		}
+	})
+
+	errorCases := map[string]string{
+		"SAMPLE-AES":  "#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"k\"\n#EXTINF:2,\na.ts\n",
+		"DRM":         "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\",KEYFORMAT=\"com.apple.streamingkeydelivery\"\n#EXTINF:2,\na.ts\n",
+		"Bad IV":      "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\",IV=0x1234\n#EXTINF:2,\na.ts\n",
+		"No segments": "#EXTM3U\n#EXT-X-ENDLIST\n",
+		"Bad range":   "#EXTM3U\n#EXTINF:2,\n#EXT-X-BYTERANGE:abc\na.ts\n",
+	}
+	for name, body := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseHLSMedia(base, body); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	attrs := parseAttributes(`BANDWIDTH=1280000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,NAME="a=b"`)
+	want := map[string]string{
+		"BANDWIDTH":  "1280000",
+		"CODECS":     "avc1.4d401e,mp4a.40.2",
+		"RESOLUTION": "640x360",
+		"NAME":       "a=b",
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("%s = %q, want %q", k, attrs[k], v)
+		}
+	}
+}
+
+func TestParseDASH(t *testing.T) {
+	base := mustURL(t, "https://cdn.example.com/dash/manifest.mpd")
+
+	t.Run("Template with number", func(t *testing.T) {
+		body := `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT9.5S">
+  <Period>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="a1" bandwidth="128000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate media="$RepresentationID$/seg-$Number%03d$.m4s" initialization="$RepresentationID$/init.mp4" startNumber="5" timescale="1000" duration="4000"/>
+      <Representation id="v720" bandwidth="2000000" width="1280" height="720"/>
+      <Representation id="v1080" bandwidth="4000000" width="1920" height="1080"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+		m, err := parseDASH(base, []byte(body))
+		if err != nil {
+			t.Fatalf("parseDASH failed: %v", err)
+		}
+		if len(m.representations) != 2 {
+			t.Fatalf("got %d representations, want the 2 video ones", len(m.representations))
+		}
+		rep := m.representations[Selector{}.Choose(m.variants())]
+		if rep.variant.URI != "v1080" {
+			t.Fatalf("selected %q, want v1080", rep.variant.URI)
+		}
+		segs, err := m.segments(rep)
+		if err != nil {
+			t.Fatalf("segments failed: %v", err)
+		}
+		wantURLs := []string{
+			"https://cdn.example.com/dash/v1080/init.mp4",
+			"https://cdn.example.com/dash/v1080/seg-005.m4s",
+			"https://cdn.example.com/dash/v1080/seg-006.m4s",
+			"https://cdn.example.com/dash/v1080/seg-007.m4s",
+		}
+		if len(segs) != len(wantURLs) {
+			t.Fatalf("got %d segments, want %d: %+v", len(segs), len(wantURLs), segs)
+		}
+		for i, want := range wantURLs {
+			if segs[i].URL != want {
+				t.Errorf("segment %d = %q, want %q", i, segs[i].URL, want)
+			}
+		}
+		if dashExt(rep.mimeType) != ".mp4" {
+			t.Errorf("ext = %q", dashExt(rep.mimeType))
+		}
+	})
+
+	t.Run("Timeline and base URLs", func(t *testing.T) {
+		body := `<MPD type="static" mediaPresentationDuration="PT30S">
+  <BaseURL>https://media.example.com/root/</BaseURL>
+  <Period>
+    <AdaptationSet contentType="video">
+      <BaseURL>video/</BaseURL>
+      <Representation id="1" bandwidth="1000" mimeType="video/webm">
+        <SegmentTemplate media="t$Time$.webm" initialization="init.webm" timescale="10">
+          <SegmentTimeline>
+            <S t="100" d="20" r="2"/>
+            <S d="30"/>
+            <S t="200" d="10" r="-1"/>
+          </SegmentTimeline>
+        </SegmentTemplate>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+		m, err := parseDASH(base, []byte(body))
+		if err != nil {
+			t.Fatalf("parseDASH failed: %v", err)
+		}
+		segs, err := m.segments(m.representations[0])
+		if err != nil {
+			t.Fatalf("segments failed: %v", err)
+		}
+		var got []string
+		for _, s := range segs {
+			got = append(got, strings.TrimPrefix(s.URL, "https://media.example.com/root/video/"))
+		}
+		want := "init.webm t100.webm t120.webm t140.webm t160.webm t200.webm t210.webm t220.webm t230.webm t240.webm t250.webm t260.webm t270.webm t280.webm t290.webm"
+		if strings.Join(got, " ") != want {
+			t.Errorf("segments = %s\nwant       %s", strings.Join(got, " "), want)
+		}
+		if dashExt(m.representations[0].mimeType) != ".webm" {
+			t.Errorf("ext = %q", dashExt(m.representations[0].mimeType))
+		}
+	})
+
+	t.Run("Segment list", func(t *testing.T) {
+		body := `<MPD type="static" mediaPresentationDuration="PT4S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="v" bandwidth="1000">
+        <BaseURL>movie.mp4</BaseURL>
+        <SegmentList timescale="1" duration="2">
+          <Initialization range="0-99"/>
+          <SegmentURL mediaRange="100-599"/>
+          <SegmentURL media="part2.mp4"/>
+        </SegmentList>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+		m, err := parseDASH(base, []byte(body))
+		if err != nil {
+			t.Fatalf("parseDASH failed: %v", err)
+		}
+		segs, err := m.segments(m.representations[0])
+		if err != nil {
+			t.Fatalf("segments failed: %v", err)
+		}
+		want := []Segment{
+			{URL: "https://cdn.example.com/dash/movie.mp4", Offset: 0, Length: 100},
+			{URL: "https://cdn.example.com/dash/movie.mp4", Offset: 100, Length: 500, Duration: 2},
+			{URL: "https://cdn.example.com/dash/part2.mp4", Duration: 2},
+		}
+		if len(segs) != len(want) {
+			t.Fatalf("got %d segments, want %d: %+v", len(segs), len(want), segs)
+		}
+		for i := range want {
+			if segs[i] != want[i] {
+				t.Errorf("segment %d = %+v, want %+v", i, segs[i], want[i])
+			}
+		}
+	})
+
+	t.Run("Single file", func(t *testing.T) {
+		body := `<MPD type="static" mediaPresentationDuration="PT1M"><Period><AdaptationSet mimeType="audio/mp4">
+<Representation id="a" bandwidth="64000"><BaseURL>audio.m4a</BaseURL><SegmentBase indexRange="0-100"/></Representation>
+</AdaptationSet></Period></MPD>`
+		m, err := parseDASH(base, []byte(body))
+		if err != nil {
+			t.Fatalf("parseDASH failed: %v", err)
+		}
+		segs, err := m.segments(m.representations[0])
+		if err != nil {
+			t.Fatalf("segments failed: %v", err)
+		}
+		if len(segs) != 1 || segs[0].URL != "https://cdn.example.com/dash/audio.m4a" || segs[0].Duration != 60 {
+			t.Errorf("segments = %+v", segs)
+		}
+		if dashExt(m.representations[0].mimeType) != ".m4a" {
+			t.Errorf("ext = %q", dashExt(m.representations[0].mimeType))
+		}
+	})
+
+	errorCases := map[string]string{
+		"Live":         `<MPD type="dynamic"><Period><AdaptationSet><Representation id="a"/></AdaptationSet></Period></MPD>`,
+		"Multi-period": `<MPD type="static"><Period/><Period/></MPD>`,
+		"Not XML":      `<MPD`,
+		"Empty":        `<MPD type="static"><Period/></MPD>`,
+	}
+	for name, body := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseDASH(base, []byte(body)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	v := Variant{URI: "video-1", Bandwidth: 250000}
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"$RepresentationID$/$Number$.m4s", "video-1/42.m4s"},
+		{"seg_$Number%05d$.m4s", "seg_00042.m4s"},
+		{"t$Time$_$Bandwidth$.m4s", "t9000_250000.m4s"},
+		{"price$$$Number$.m4s", "price$42.m4s"},
+	}
+	for _, tt := range tests {
+		if got := expandTemplate(tt.tmpl, v, 42, 9000); got != tt.want {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"PT1H2M3.5S", 3723.5, false},
+		{"PT0S", 0, false},
+		{"P1DT1S", 86401, false},
+		{"PT90M", 5400, false},
+		{"PT", 0, true},
+		{"1H", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseISODuration(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseISODuration(%q) = %v, %v; want %v (err %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/surge-downloader/surge/internal/engine/cookies"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// maxManifestSize bounds how much of a manifest is read
+const maxManifestSize = 16 << 20
+
+// fetcher issues the HTTP requests of one stream with the download's headers
+type fetcher struct {
+	client    *http.Client
+	headers   map[string]string // Custom HTTP headers from browser (cookies, auth, etc.)
+	userAgent string
+}
+
+func newFetcher(runtime *types.RuntimeConfig, headers map[string]string) *fetcher {
+	return &fetcher{
+		client:    newClient(runtime),
+		headers:   headers,
+		userAgent: runtime.GetUserAgent(),
+	}
+}
+
+// newClient creates an http.Client for manifests and segments, honouring the
+// configured proxy and forwarding custom headers across redirects
+func newClient(runtime *types.RuntimeConfig) *http.Client {
+	maxConns := runtime.GetMaxConnectionsPerHost()
+
+	proxyFunc := http.ProxyFromEnvironment
+	if runtime != nil && runtime.ProxyURL != "" {
+		if parsedURL, err := url.Parse(runtime.ProxyURL); err == nil {
+			proxyFunc = http.ProxyURL(parsedURL)
+		} else {
+			utils.Debug("Invalid proxy URL %s: %v", runtime.ProxyURL, err)
+		}
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        types.DefaultMaxIdleConns,
+		MaxIdleConnsPerHost: maxConns + 2,
+		MaxConnsPerHost:     maxConns,
+		Proxy:               proxyFunc,
+
+		IdleConnTimeout:       types.DefaultIdleConnTimeout,
+		TLSHandshakeTimeout:   types.DefaultTLSHandshakeTimeout,
+		ResponseHeaderTimeout: types.DefaultResponseHeaderTimeout,
+		ExpectContinueTimeout: types.DefaultExpectContinueTimeout,
+
+		// Segments are small: one TCP connection per worker, like the concurrent downloader
+		ForceAttemptHTTP2: false,
+		TLSNextProto:      make(map[string]func(authority string, c *tls.Conn) http.RoundTripper),
+
+		DialContext: (&net.Dialer{
+			Timeout:   types.DialTimeout,
+			KeepAlive: types.KeepAliveDuration,
+		}).DialContext,
+	}
+
+	return &http.Client{
+		Transport: cookies.Transport(transport),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			// Browser-provided headers (cookies, auth) apply to every request of the stream
+			if len(via) > 0 {
+				for key, vals := range via[0].Header {
+					req.Header[key] = vals
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// newRequest builds a GET request carrying the download's custom headers
+func (f *fetcher) newRequest(ctx context.Context, method, rawurl string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range f.headers {
+		if key == "Range" {
+			continue
+		}
+		req.Header.Set(key, val)
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+	return req, nil
+}
+
+// fetchManifest downloads a manifest and returns it with the URL it was finally served
+// from, which relative references resolve against
+func (f *fetcher) fetchManifest(ctx context.Context, rawurl string) ([]byte, *url.URL, error) {
+	req, err := f.newRequest(ctx, http.MethodGet, rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch manifest: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if len(body) > maxManifestSize {
+		return nil, nil, fmt.Errorf("manifest is larger than %d bytes", maxManifestSize)
+	}
+	return body, resp.Request.URL, nil
+}
+
+// Open fetches the manifest at rawurl and resolves the variant chosen by sel into
+// the list of segments to download
+func Open(ctx context.Context, rawurl string, headers map[string]string, sel Selector, runtime *types.RuntimeConfig) (*Plan, error) {
+	f := newFetcher(runtime, headers)
+	body, base, err := f.fetchManifest(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+
+	var plan *Plan
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		plan, err = openHLS(ctx, f, base, string(trimmed), sel)
+	case bytes.Contains(trimmed[:min(len(trimmed), 4096)], []byte("<MPD")):
+		plan, err = openDASH(base, trimmed, sel)
+	default:
+		return nil, fmt.Errorf("not an HLS or DASH manifest")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.EstimatedSize <= 0 {
+		plan.EstimatedSize = f.estimateFromFirstSegment(ctx, plan)
+	}
+	utils.Debug("Stream plan: %s, %d segments, variant %d bps %dx%d, ~%d bytes",
+		plan.Kind, len(plan.Segments), plan.Variant.Bandwidth, plan.Variant.Width, plan.Variant.Height, plan.EstimatedSize)
+	return plan, nil
+}
+
+func openHLS(ctx context.Context, f *fetcher, base *url.URL, body string, sel Selector) (*Plan, error) {
+	plan := &Plan{Kind: KindHLS, Variants: 1}
+
+	if isHLSMaster(body) {
+		variants, err := parseHLSMaster(base, body)
+		if err != nil {
+			return nil, err
+		}
+		plan.Variants = len(variants)
+		plan.Variant = variants[sel.Choose(variants)]
+
+		media, mediaBase, err := f.fetchManifest(ctx, plan.Variant.URI)
+		if err != nil {
+			return nil, err
+		}
+		body, base = string(bytes.TrimSpace(media)), mediaBase
+		if isHLSMaster(body) {
+			return nil, fmt.Errorf("variant playlist is another master playlist")
+		}
+	}
+
+	playlist, err := parseHLSMedia(base, body)
+	if err != nil {
+		return nil, err
+	}
+	if playlist.Live {
+		utils.Debug("HLS playlist has no EXT-X-ENDLIST; downloading the %d segments listed now", len(playlist.Segments))
+	}
+	plan.Segments = playlist.Segments
+	plan.Ext = hlsExt(playlist)
+	plan.EstimatedSize = estimateSize(plan)
+	return plan, nil
+}
+
+func openDASH(base *url.URL, body []byte, sel Selector) (*Plan, error) {
+	manifest, err := parseDASH(base, body)
+	if err != nil {
+		return nil, err
+	}
+	variants := manifest.variants()
+	rep := manifest.representations[sel.Choose(variants)]
+
+	segments, err := manifest.segments(rep)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		Kind:     KindDASH,
+		Variant:  rep.variant,
+		Variants: len(variants),
+		Segments: segments,
+		Ext:      dashExt(rep.mimeType),
+	}
+	plan.EstimatedSize = estimateSize(plan)
+	return plan, nil
+}
+
+// estimateSize uses exact byte ranges when every segment has one, else bandwidth × duration
+func estimateSize(plan *Plan) int64 {
+	var ranged int64
+	for _, seg := range plan.Segments {
+		if seg.Length <= 0 {
+			ranged = -1
+			break
+		}
+		ranged += seg.Length
+	}
+	if ranged > 0 {
+		return ranged
+	}
+	if plan.Variant.Bandwidth > 0 {
+		return int64(float64(plan.Variant.Bandwidth) * plan.Duration() / 8)
+	}
+	return 0
+}
+
+// estimateFromFirstSegment extrapolates the size from the first media segment's length
+func (f *fetcher) estimateFromFirstSegment(ctx context.Context, plan *Plan) int64 {
+	var media []Segment
+	for _, seg := range plan.Segments {
+		if seg.Duration > 0 {
+			media = append(media, seg)
+		}
+	}
+	if len(media) == 0 {
+		media = plan.Segments
+	}
+	first := media[0]
+	if first.Length > 0 {
+		return first.Length * int64(len(media))
+	}
+
+	req, err := f.newRequest(ctx, http.MethodHead, first.URL)
+	if err != nil {
+		return 0
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 {
+		return 0
+	}
+	return resp.ContentLength * int64(len(media))
+}
@@ -0,0 +1,228 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Bencoded values decode to int64, string, []any and map[string]any
+
+// maxBencodeDepth bounds nesting so hostile input cannot exhaust the stack
+const maxBencodeDepth = 64
+
+var errBencodeEOF = errors.New("bencode: unexpected end of data")
+
+// bdecoder walks a bencoded buffer. pos is left just past the last value read,
+// which lets callers find where a dictionary ends and trailing data begins.
+type bdecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// decodeBencode decodes a single value that must span all of data
+func decodeBencode(data []byte) (any, error) {
+	d := &bdecoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("bencode: %d trailing bytes", len(data)-d.pos)
+	}
+	return v, nil
+}
+
+func (d *bdecoder) value() (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, errBencodeEOF
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		return d.integer('e')
+	case c == 'l':
+		return d.list()
+	case c == 'd':
+		return d.dict(nil)
+	case c >= '0' && c <= '9':
+		return d.str()
+	default:
+		return nil, fmt.Errorf("bencode: invalid byte %q at %d", c, d.pos)
+	}
+}
+
+// integer reads digits up to the terminator and consumes it
+func (d *bdecoder) integer(term byte) (int64, error) {
+	end := bytes.IndexByte(d.data[d.pos:], term)
+	if end < 0 {
+		return 0, errBencodeEOF
+	}
+	s := string(d.data[d.pos : d.pos+end])
+	if s == "" || s == "-0" || (len(s) > 1 && s[0] == '0') || (len(s) > 2 && s[:2] == "-0") {
+		return 0, fmt.Errorf("bencode: malformed integer %q", s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bencode: malformed integer %q", s)
+	}
+	d.pos += end + 1
+	return n, nil
+}
+
+func (d *bdecoder) str() (string, error) {
+	n, err := d.integer(':')
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > int64(len(d.data)-d.pos) {
+		return "", errBencodeEOF
+	}
+	s := string(d.data[d.pos : d.pos+int(n)])
+	d.pos += int(n)
+	return s, nil
+}
+
+func (d *bdecoder) list() ([]any, error) {
+	if d.depth++; d.depth > maxBencodeDepth {
+		return nil, fmt.Errorf("bencode: nesting too deep")
+	}
+	defer func() { d.depth-- }()
+
+	d.pos++ // 'l'
+	list := []any{}
+	for {
+		if d.pos >= len(d.data) {
+			return nil, errBencodeEOF
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return list, nil
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+}
+
+// dict decodes a dictionary. When raw is non-nil it receives the encoded bytes of
+// every value, which is how the info dictionary is hashed exactly as it was sent.
+func (d *bdecoder) dict(raw map[string][]byte) (map[string]any, error) {
+	if d.depth++; d.depth > maxBencodeDepth {
+		return nil, fmt.Errorf("bencode: nesting too deep")
+	}
+	defer func() { d.depth-- }()
+
+	d.pos++ // 'd'
+	dict := make(map[string]any)
+	for {
+		if d.pos >= len(d.data) {
+			return nil, errBencodeEOF
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return dict, nil
+		}
+		key, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = v
+		if raw != nil {
+			raw[key] = d.data[start:d.pos]
+		}
+	}
+}
+
+// decodeDictPrefix decodes the dictionary at the start of data and returns it with
+// the bytes that follow it (ut_metadata messages append the piece after the dict)
+func decodeDictPrefix(data []byte) (map[string]any, []byte, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, nil, fmt.Errorf("bencode: expected a dictionary")
+	}
+	d := &bdecoder{data: data}
+	dict, err := d.dict(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dict, data[d.pos:], nil
+}
+
+// encodeBencode encodes ints, strings, byte slices, lists and string-keyed maps
+func encodeBencode(v any) []byte {
+	var buf bytes.Buffer
+	writeBencode(&buf, v)
+	return buf.Bytes()
+}
+
+func writeBencode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case int:
+		fmt.Fprintf(buf, "i%de", v)
+	case int64:
+		fmt.Fprintf(buf, "i%de", v)
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(v), v)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(v))
+		buf.Write(v)
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			writeBencode(buf, item)
+		}
+		buf.WriteByte('e')
+	case []string:
+		buf.WriteByte('l')
+		for _, item := range v {
+			writeBencode(buf, item)
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			writeBencode(buf, k)
+			writeBencode(buf, v[k])
+		}
+		buf.WriteByte('e')
+	default:
+		panic(fmt.Sprintf("bencode: unsupported type %T", v))
+	}
+}
+
+// Typed accessors for decoded dictionaries
+
+func dictString(d map[string]any, key string) (string, bool) {
+	s, ok := d[key].(string)
+	return s, ok
+}
+
+func dictInt(d map[string]any, key string) (int64, bool) {
+	n, ok := d[key].(int64)
+	return n, ok
+}
+
+func dictDict(d map[string]any, key string) (map[string]any, bool) {
+	m, ok := d[key].(map[string]any)
+	return m, ok
+}
+
+func dictList(d map[string]any, key string) ([]any, bool) {
+	l, ok := d[key].([]any)
+	return l, ok
+}
@@ -0,0 +1,275 @@
+package torrent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/checksum"
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// TorrentDownloader downloads a torrent's pieces from its swarm. Each piece is one
+// cell of the chunk map; on pause the missing pieces are saved as tasks, and on
+// resume the pieces already on disk are re-verified against their hashes.
+type TorrentDownloader struct {
+	ProgressChan chan<- any           // Channel for events (start/complete/error)
+	ID           string               // Download ID
+	State        *types.ProgressState // Shared state for TUI polling
+	Runtime      *types.RuntimeConfig
+	URL          string // Magnet link, for pause/resume
+	DestPath     string // For pause/resume
+	Checksum     string // Expected digest ("algo:hex") of single-file torrents
+	Extract      string // Extract mode on completion, carried across pauses
+}
+
+// NewTorrentDownloader creates a new torrent downloader with all required parameters
+func NewTorrentDownloader(id string, progressCh chan<- any, progState *types.ProgressState, runtime *types.RuntimeConfig) *TorrentDownloader {
+	return &TorrentDownloader{
+		ID:           id,
+		ProgressChan: progressCh,
+		State:        progState,
+		Runtime:      runtime,
+	}
+}
+
+// missingRanges merges the pieces not in have into byte ranges
+func missingRanges(info *Info, have bitfield) []types.Task {
+	var tasks []types.Task
+	for i := 0; i < info.NumPieces(); i++ {
+		if have.has(i) {
+			continue
+		}
+		offset := int64(i) * info.PieceLength
+		if n := len(tasks); n > 0 && tasks[n-1].Offset+tasks[n-1].Length == offset {
+			tasks[n-1].Length += info.PieceSize(i)
+		} else {
+			tasks = append(tasks, types.Task{Offset: offset, Length: info.PieceSize(i)})
+		}
+	}
+	return tasks
+}
+
+// recheck returns the pieces outside the saved missing ranges whose data on disk
+// still matches their hash
+func recheck(st *storage, missing []types.Task) bitfield {
+	info := st.info
+	have := newBitfield(info.NumPieces())
+	for i := 0; i < info.NumPieces(); i++ {
+		have.set(i)
+	}
+	for _, task := range missing {
+		first := int(task.Offset / info.PieceLength)
+		last := int((task.Offset + task.Length - 1) / info.PieceLength)
+		for i := first; i <= last; i++ {
+			have.unset(i)
+		}
+	}
+	for i := 0; i < info.NumPieces(); i++ {
+		if have.has(i) && !st.verifyPiece(i) {
+			have.unset(i)
+		}
+	}
+	return have
+}
+
+// Download fetches t into destPath: the file of a single-file torrent, or the
+// directory of a multi-file one. When seeding is enabled the torrent keeps serving
+// peers from destPath after Download returns.
+func (d *TorrentDownloader) Download(ctx context.Context, t *Torrent, rawurl, destPath string, verbose bool) error {
+	info := t.Info()
+	utils.Debug("TorrentDownloader.Download: %s -> %s (%d pieces of %d bytes)", info.Name, destPath, info.NumPieces(), info.PieceLength)
+
+	// Store URL and path for pause/resume (final path without .surge)
+	d.URL = rawurl
+	d.DestPath = destPath
+	workingPath := destPath + types.IncompleteSuffix
+
+	// Create cancellable context for pause support
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if d.State != nil {
+		d.State.CancelFunc = cancel
+	}
+
+	if verbose {
+		fmt.Printf("Torrent: %s, %d files, %d pieces of %s\n",
+			info.Name, len(info.Files), info.NumPieces(),
+			utils.ConvertBytesToHumanReadable(info.PieceLength))
+	}
+
+	if d.State != nil {
+		d.State.SetTotalSize(info.Length)
+		d.State.InitBitmap(info.Length, info.PieceLength)
+	}
+
+	st, err := openStorage(info, workingPath, true)
+	if err != nil {
+		return err
+	}
+
+	// Resume from the pieces saved as complete, provided they still verify
+	have := newBitfield(info.NumPieces())
+	savedState, err := state.LoadState(d.URL, destPath)
+	if err == nil && savedState != nil && savedState.TotalSize == info.Length && savedState.ActualChunkSize == info.PieceLength {
+		have = recheck(st, savedState.Tasks)
+		if d.State != nil {
+			d.State.SetSavedElapsed(time.Duration(savedState.Elapsed))
+		}
+		utils.Debug("Resuming torrent with %d/%d pieces verified", have.count(info.NumPieces()), info.NumPieces())
+	}
+	var verified int64
+	for i := 0; i < info.NumPieces(); i++ {
+		if have.has(i) {
+			verified += info.PieceSize(i)
+		}
+	}
+	if d.State != nil {
+		d.State.Downloaded.Store(verified)
+		d.State.SyncSessionStart()
+		d.State.RecalculateProgress(missingRanges(info, have))
+	}
+
+	startTime := time.Now()
+	s := t.s
+	s.mu.Lock()
+	s.sequential = d.Runtime != nil && d.Runtime.SequentialDownload
+	s.limiters = d.State.Limiters()
+	if d.State != nil {
+		s.onBytes = func(n int64) { d.State.Downloaded.Add(n) }
+		s.onPiece = func(index int, length int64) {
+			d.State.UpdateChunkStatus(int64(index)*info.PieceLength, length, types.ChunkCompleted)
+		}
+	}
+	s.mu.Unlock()
+	s.attach(st, have)
+
+	// Report connected peers as workers while waiting
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	var downloadErr error
+wait:
+	for {
+		if d.State != nil {
+			d.State.ActiveWorkers.Store(int32(s.peerCount()))
+		}
+		select {
+		case <-downloadCtx.Done():
+			downloadErr = downloadCtx.Err()
+			break wait
+		case <-s.failed:
+			downloadErr = s.err
+			break wait
+		case <-s.completed:
+			break wait
+		case <-ticker.C:
+		}
+	}
+	if d.State != nil {
+		d.State.ActiveWorkers.Store(0)
+	}
+
+	// Stop writing before the file is saved, moved or verified
+	if detached := s.detachStorage(); detached != nil {
+		if err := detached.sync(); err != nil && downloadErr == nil {
+			downloadErr = fmt.Errorf("failed to sync file: %w", err)
+		}
+		detached.close()
+	}
+
+	// Handle pause: save the missing pieces for resume
+	if d.State != nil && d.State.IsPaused() {
+		s.mu.Lock()
+		remainingTasks := missingRanges(info, s.have)
+		downloaded := s.haveBytes
+		s.mu.Unlock()
+		bitmap, _, _, chunkSize, _ := d.State.GetBitmap()
+
+		saved := &types.DownloadState{
+			URL:             d.URL,
+			ID:              d.ID,
+			DestPath:        destPath,
+			TotalSize:       info.Length,
+			Downloaded:      downloaded,
+			Tasks:           remainingTasks,
+			Filename:        filepath.Base(destPath),
+			Elapsed:         (d.State.SavedElapsed + time.Since(startTime)).Nanoseconds(),
+			Checksum:        d.Checksum,
+			Extract:         d.Extract,
+			SpeedLimit:      d.State.SpeedLimit.Limit(),
+			ChunkBitmap:     bitmap,
+			ActualChunkSize: chunkSize,
+		}
+		if err := state.SaveState(d.URL, destPath, saved); err != nil {
+			utils.Debug("Failed to save pause state: %v", err)
+		}
+		utils.Debug("Torrent download paused, state saved (Downloaded=%d, RemainingTasks=%d)", downloaded, len(remainingTasks))
+		return types.ErrPaused
+	}
+
+	if downloadErr != nil {
+		return downloadErr
+	}
+
+	// Verify expected digest before exposing the file under its final name
+	if !info.MultiFile {
+		if err := checksum.VerifyFile(workingPath, d.Checksum); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(workingPath, destPath); err != nil {
+		return fmt.Errorf("failed to rename completed download: %w", err)
+	}
+	_ = state.DeleteState(d.ID, d.URL, destPath)
+
+	d.startSeeding(t, destPath)
+	return nil
+}
+
+// startSeeding keeps serving the completed files until the configured share ratio
+// or time limit is reached
+func (d *TorrentDownloader) startSeeding(t *Torrent, destPath string) {
+	if d.Runtime == nil || d.Runtime.SeedRatio <= 0 {
+		return
+	}
+	info := t.Info()
+	st, err := openStorage(info, destPath, false)
+	if err != nil {
+		utils.Debug("Torrent: cannot seed %s: %v", info.Name, err)
+		return
+	}
+	s := t.s
+	s.seed(st)
+	t.kept = true
+
+	target := int64(d.Runtime.SeedRatio * float64(info.Length))
+	limit := d.Runtime.SeedTimeLimit
+	utils.Debug("Seeding %s until %d bytes uploaded (time limit %v)", info.Name, target, limit)
+
+	go func() {
+		var deadline <-chan time.Time
+		if limit > 0 {
+			timer := time.NewTimer(limit)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		ticker := time.NewTicker(maintenanceInterval)
+		defer ticker.Stop()
+		for s.uploaded.Load() < target {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-deadline:
+				s.close()
+				return
+			case <-ticker.C:
+			}
+		}
+		utils.Debug("Finished seeding %s: %d bytes uploaded", info.Name, s.uploaded.Load())
+		s.close()
+	}()
+}
@@ -0,0 +1,327 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/testutil"
+)
+
+func newTestSession(t *testing.T) *Session {
+	t.Helper()
+	s, err := NewSession("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// startSeeder serves the files of a torrent from dir in a new session
+func startSeeder(t *testing.T, raw []byte, files []testFile, dir string) (*Session, *Torrent) {
+	t.Helper()
+	mi, err := ParseMetaInfo(raw)
+	if err != nil {
+		t.Fatalf("ParseMetaInfo failed: %v", err)
+	}
+	root := filepath.Join(dir, mi.Info.Name)
+	for _, f := range files {
+		p := root
+		if mi.Info.MultiFile {
+			p = filepath.Join(root, filepath.FromSlash(f.path))
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, f.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestSession(t)
+	m, err := ParseMagnet(mi.Magnet())
+	if err != nil {
+		t.Fatalf("ParseMagnet failed: %v", err)
+	}
+	tor, err := s.Open(context.Background(), m, mi.InfoBytes)
+	if err != nil {
+		t.Fatalf("Seeder Open failed: %v", err)
+	}
+	t.Cleanup(tor.Close)
+	st, err := openStorage(mi.Info, root, true)
+	if err != nil {
+		t.Fatalf("openStorage failed: %v", err)
+	}
+	tor.s.attach(st, recheck(st, nil))
+	return s, tor
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func verifyFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: content mismatch (%d bytes, want %d)", path, len(got), len(want))
+	}
+}
+
+func TestTorrentDownloader_MagnetFromTracker(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-torrent-test")
+	tracker := newTestTracker(t)
+	data := randomBytes(t, 300000)
+	files := []testFile{{data: data}}
+	raw := makeTorrent(t, "file.bin", files, 16384, tracker.announceURL())
+	startSeeder(t, raw, files, filepath.Join(tmpDir, "seed"))
+	waitFor(t, "the seeder to announce", func() bool { return tracker.sawEvent("started") })
+
+	// The magnet link carries no metadata: it has to come from the seeder
+	mi, _ := ParseMetaInfo(raw)
+	m, _ := ParseMagnet(mi.Magnet())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	leecher := newTestSession(t)
+	tor, err := leecher.Open(ctx, m, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if info := tor.Info(); info.Name != "file.bin" || info.Length != int64(len(data)) {
+		t.Fatalf("Metadata = %+v", info)
+	}
+
+	destPath := filepath.Join(tmpDir, "file.bin")
+	progState := types.NewProgressState("magnet", 0)
+	d := NewTorrentDownloader("magnet", nil, progState, nil)
+	if err := d.Download(ctx, tor, mi.Magnet(), destPath, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	tor.Close()
+
+	verifyFile(t, destPath, data)
+	if got := progState.VerifiedProgress.Load(); got != int64(len(data)) {
+		t.Errorf("VerifiedProgress = %d, want %d", got, len(data))
+	}
+	if !tracker.sawEvent("stopped") {
+		t.Error("Tracker was not told the torrent stopped")
+	}
+}
+
+func TestTorrentDownloader_PauseResume(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-torrent-test")
+	data := randomBytes(t, 512*1024)
+	files := []testFile{{data: data}}
+	raw := makeTorrent(t, "video.mkv", files, 32768)
+	seeder, seederTorrent := startSeeder(t, raw, files, filepath.Join(tmpDir, "seed"))
+
+	// No tracker: the seeder is found through the magnet link's peer address
+	mi, _ := ParseMetaInfo(raw)
+	m, _ := ParseMagnet(mi.Magnet())
+	m.Peers = []string{seeder.listener.Addr().String()}
+	rawurl := m.String()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	leecher := newTestSession(t)
+	destPath := filepath.Join(tmpDir, "video.mkv")
+	pieces := int64(32768)
+
+	tor, err := leecher.Open(ctx, m, mi.InfoBytes)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	downloadCtx, downloadCancel := context.WithCancel(ctx)
+	defer downloadCancel()
+	progState := types.NewProgressState("torrent-resume", 0)
+	progState.CancelFunc = downloadCancel
+	progState.SpeedLimit.SetLimit(256 * 1024)
+	d := NewTorrentDownloader("torrent-resume", nil, progState, nil)
+	go func() {
+		for progState.VerifiedProgress.Load() < 4*pieces {
+			time.Sleep(2 * time.Millisecond)
+		}
+		progState.Pause()
+	}()
+	err = d.Download(downloadCtx, tor, rawurl, destPath, false)
+	tor.Close()
+	if !errors.Is(err, types.ErrPaused) {
+		t.Fatalf("Download returned %v, want ErrPaused", err)
+	}
+
+	saved, err := state.LoadState(rawurl, destPath)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if saved.ActualChunkSize != pieces || saved.TotalSize != int64(len(data)) || len(saved.Tasks) == 0 {
+		t.Fatalf("Saved state = %+v", saved)
+	}
+	var missing int64
+	for _, task := range saved.Tasks {
+		missing += task.Length
+	}
+	if saved.Downloaded < 4*pieces || saved.Downloaded+missing != int64(len(data)) {
+		t.Errorf("Saved Downloaded = %d with %d bytes missing", saved.Downloaded, missing)
+	}
+
+	// Resume: pieces on disk are verified and kept
+	tor, err = leecher.Open(ctx, m, mi.InfoBytes)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer tor.Close()
+	uploadedBefore := seederTorrent.s.uploaded.Load()
+	d = NewTorrentDownloader("torrent-resume", nil, types.NewProgressState("torrent-resume", 0), nil)
+	if err := d.Download(ctx, tor, rawurl, destPath, false); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	verifyFile(t, destPath, data)
+	if fetched := seederTorrent.s.uploaded.Load() - uploadedBefore; fetched > missing {
+		t.Errorf("Resume fetched %d bytes, want at most the %d missing", fetched, missing)
+	}
+	if _, err := state.LoadState(rawurl, destPath); err == nil {
+		t.Error("State not deleted after completion")
+	}
+}
+
+func TestTorrentDownloader_MultiFileAndSeeding(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-torrent-test")
+	files := []testFile{
+		{path: "disc1/track01.flac", data: randomBytes(t, 70000)},
+		{path: "disc1/track02.flac", data: randomBytes(t, 50001)},
+		{path: "cover.jpg", data: randomBytes(t, 9999)},
+	}
+	raw := makeTorrent(t, "album", files, 16384)
+	seeder, seederTorrent := startSeeder(t, raw, files, filepath.Join(tmpDir, "seed"))
+	mi, _ := ParseMetaInfo(raw)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// First leecher downloads the album and keeps seeding it
+	m, _ := ParseMagnet(mi.Magnet())
+	m.Peers = []string{seeder.listener.Addr().String()}
+	first := newTestSession(t)
+	tor, err := first.Open(ctx, m, mi.InfoBytes)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tor.Close()
+	destDir := filepath.Join(tmpDir, "first", "album")
+	runtime := &types.RuntimeConfig{SeedRatio: 1, SeedTimeLimit: time.Minute}
+	d := NewTorrentDownloader("album", nil, types.NewProgressState("album", 0), runtime)
+	if err := d.Download(ctx, tor, m.String(), destDir, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	for _, f := range files {
+		verifyFile(t, filepath.Join(destDir, filepath.FromSlash(f.path)), f.data)
+	}
+	if _, err := os.Stat(destDir + types.IncompleteSuffix); !os.IsNotExist(err) {
+		t.Error("Working directory left behind")
+	}
+	if !tor.s.isSeeding() {
+		t.Fatal("Completed torrent is not seeding")
+	}
+
+	// With the original seeder gone, a second leecher can only get the album from the first
+	seederTorrent.Close()
+	m2, _ := ParseMagnet(mi.Magnet())
+	m2.Peers = []string{first.listener.Addr().String()}
+	second := newTestSession(t)
+	tor2, err := second.Open(ctx, m2, mi.InfoBytes)
+	if err != nil {
+		t.Fatalf("Second Open failed: %v", err)
+	}
+	defer tor2.Close()
+	secondDir := filepath.Join(tmpDir, "second", "album")
+	d2 := NewTorrentDownloader("album-2", nil, types.NewProgressState("album-2", 0), nil)
+	if err := d2.Download(ctx, tor2, m2.String(), secondDir, false); err != nil {
+		t.Fatalf("Second download failed: %v", err)
+	}
+	for _, f := range files {
+		verifyFile(t, filepath.Join(secondDir, filepath.FromSlash(f.path)), f.data)
+	}
+
+	// Uploading the whole album reaches ratio 1, which ends seeding
+	waitFor(t, "seeding to stop at the ratio", func() bool {
+		first.mu.Lock()
+		defer first.mu.Unlock()
+		return first.swarms[mi.InfoHash] == nil
+	})
+	if up := tor.s.uploaded.Load(); up < mi.Info.Length {
+		t.Errorf("Seeding stopped after %d bytes, want %d", up, mi.Info.Length)
+	}
+}
+
+func TestTorrentDownloader_CorruptPeerIsBanned(t *testing.T) {
+	tmpDir := testutil.StateDirT(t, "surge-torrent-test")
+	data := randomBytes(t, 64*1024)
+	files := []testFile{{data: data}}
+	raw := makeTorrent(t, "file.bin", files, 16384)
+
+	// The seeder's copy is corrupt in every piece but reports having all of them
+	bad := bytes.Clone(data)
+	for i := 0; i < len(bad); i += 16384 {
+		bad[i] ^= 0xff
+	}
+	seedDir := filepath.Join(tmpDir, "seed")
+	seeder, seederTorrent := startSeeder(t, raw, []testFile{{data: bad}}, seedDir)
+	full := newBitfield(4)
+	for i := 0; i < 4; i++ {
+		full.set(i)
+	}
+	st, err := openStorage(seederTorrent.Info(), filepath.Join(seedDir, "file.bin"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seederTorrent.s.attach(st, full)
+
+	mi, _ := ParseMetaInfo(raw)
+	m, _ := ParseMagnet(mi.Magnet())
+	m.Peers = []string{seeder.listener.Addr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	leecher := newTestSession(t)
+	tor, err := leecher.Open(ctx, m, mi.InfoBytes)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tor.Close()
+
+	downloadCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	d := NewTorrentDownloader("corrupt", nil, types.NewProgressState("corrupt", 0), nil)
+	go func() { done <- d.Download(downloadCtx, tor, m.String(), filepath.Join(tmpDir, "file.bin"), false) }()
+
+	waitFor(t, "the corrupt peer to be banned", func() bool {
+		tor.s.mu.Lock()
+		defer tor.s.mu.Unlock()
+		return tor.s.banned["127.0.0.1"]
+	})
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Download returned %v, want context.Canceled", err)
+	}
+	tor.s.mu.Lock()
+	defer tor.s.mu.Unlock()
+	if tor.s.numHave != 0 {
+		t.Errorf("Accepted %d corrupt pieces", tor.s.numHave)
+	}
+}
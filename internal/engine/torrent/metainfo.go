@@ -0,0 +1,309 @@
+// Package torrent downloads BitTorrent content from .torrent files and magnet links.
+// It speaks the peer wire protocol with the extension protocol for fetching metadata
+// (BEP 3, 9, 10), announces to HTTP and UDP trackers (BEP 15), and keeps seeding
+// completed torrents until the configured ratio or time limit is reached. DHT is not
+// supported, so magnet links need a tracker or a peer address (x.pe) to find peers.
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxTorrentFiles bounds how many files a single torrent may describe
+const maxTorrentFiles = 100000
+
+// File is one file of a torrent, placed at Offset in the torrent's byte stream
+type File struct {
+	Path    string // Slash-separated path relative to the torrent root
+	Length  int64
+	Offset  int64
+	Padding bool // BEP 47 padding file: part of the piece space, never written to disk
+}
+
+// Info is the parsed info dictionary of a torrent
+type Info struct {
+	Name        string
+	PieceLength int64
+	Pieces      [][sha1.Size]byte
+	Length      int64  // Total length of all files
+	Files       []File // A single-file torrent has one file whose Path is Name
+	MultiFile   bool   // Files live in a directory called Name
+}
+
+// NumPieces returns the number of pieces
+func (i *Info) NumPieces() int {
+	return len(i.Pieces)
+}
+
+// PieceSize returns the length of piece index; the last piece may be short
+func (i *Info) PieceSize(index int) int64 {
+	if index == len(i.Pieces)-1 {
+		return i.Length - int64(index)*i.PieceLength
+	}
+	return i.PieceLength
+}
+
+// parseInfo parses a bencoded info dictionary
+func parseInfo(data []byte) (*Info, error) {
+	v, err := decodeBencode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid info dictionary: %w", err)
+	}
+	dict, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid info dictionary")
+	}
+
+	info := &Info{}
+	name, ok := dictString(dict, "name.utf-8")
+	if !ok {
+		name, _ = dictString(dict, "name")
+	}
+	if err := checkPathComponent(name); err != nil {
+		return nil, fmt.Errorf("invalid torrent name: %w", err)
+	}
+	info.Name = name
+
+	if info.PieceLength, ok = dictInt(dict, "piece length"); !ok || info.PieceLength <= 0 || info.PieceLength > 1<<30 {
+		return nil, fmt.Errorf("invalid piece length")
+	}
+	pieces, ok := dictString(dict, "pieces")
+	if !ok || len(pieces) == 0 || len(pieces)%sha1.Size != 0 {
+		if version, _ := dictInt(dict, "meta version"); version == 2 {
+			return nil, fmt.Errorf("BitTorrent v2-only torrents are not supported")
+		}
+		return nil, fmt.Errorf("invalid piece hashes")
+	}
+	info.Pieces = make([][sha1.Size]byte, len(pieces)/sha1.Size)
+	for i := range info.Pieces {
+		copy(info.Pieces[i][:], pieces[i*sha1.Size:])
+	}
+
+	if files, ok := dictList(dict, "files"); ok {
+		if len(files) == 0 || len(files) > maxTorrentFiles {
+			return nil, fmt.Errorf("invalid file list")
+		}
+		info.MultiFile = true
+		seen := make(map[string]bool, len(files))
+		for _, f := range files {
+			fd, ok := f.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid file entry")
+			}
+			file, err := parseFileEntry(fd)
+			if err != nil {
+				return nil, err
+			}
+			if seen[file.Path] && !file.Padding {
+				return nil, fmt.Errorf("duplicate file %q", file.Path)
+			}
+			seen[file.Path] = true
+			file.Offset = info.Length
+			info.Length += file.Length
+			info.Files = append(info.Files, file)
+		}
+	} else {
+		length, ok := dictInt(dict, "length")
+		if !ok || length < 0 {
+			return nil, fmt.Errorf("invalid length")
+		}
+		info.Length = length
+		info.Files = []File{{Path: info.Name, Length: length}}
+	}
+
+	if info.Length <= 0 {
+		return nil, fmt.Errorf("torrent is empty")
+	}
+	if want := (info.Length + info.PieceLength - 1) / info.PieceLength; int64(len(info.Pieces)) != want {
+		return nil, fmt.Errorf("torrent has %d piece hashes, want %d", len(info.Pieces), want)
+	}
+	return info, nil
+}
+
+func parseFileEntry(fd map[string]any) (File, error) {
+	length, ok := dictInt(fd, "length")
+	if !ok || length < 0 {
+		return File{}, fmt.Errorf("invalid file length")
+	}
+	parts, ok := dictList(fd, "path.utf-8")
+	if !ok {
+		parts, _ = dictList(fd, "path")
+	}
+	if len(parts) == 0 {
+		return File{}, fmt.Errorf("file without a path")
+	}
+	components := make([]string, 0, len(parts))
+	for _, p := range parts {
+		s, ok := p.(string)
+		if !ok {
+			return File{}, fmt.Errorf("invalid file path")
+		}
+		if err := checkPathComponent(s); err != nil {
+			return File{}, fmt.Errorf("invalid file path: %w", err)
+		}
+		components = append(components, s)
+	}
+	attr, _ := dictString(fd, "attr")
+	return File{
+		Path:    path.Join(components...),
+		Length:  length,
+		Padding: strings.Contains(attr, "p"),
+	}, nil
+}
+
+// checkPathComponent rejects names that would escape the download directory
+func checkPathComponent(s string) error {
+	switch {
+	case s == "" || s == "." || s == "..":
+		return fmt.Errorf("%q is not a valid name", s)
+	case strings.ContainsAny(s, "/\\\x00"):
+		return fmt.Errorf("%q contains a path separator", s)
+	}
+	return nil
+}
+
+// MetaInfo is a parsed .torrent file
+type MetaInfo struct {
+	InfoHash  [sha1.Size]byte
+	Info      *Info
+	InfoBytes []byte   // The info dictionary exactly as encoded, hashed into InfoHash
+	Trackers  []string // announce and announce-list, in tier order
+}
+
+// ParseMetaInfo parses the contents of a .torrent file
+func ParseMetaInfo(data []byte) (*MetaInfo, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, fmt.Errorf("not a torrent file")
+	}
+	d := &bdecoder{data: data}
+	raw := make(map[string][]byte)
+	dict, err := d.dict(raw)
+	if err != nil {
+		return nil, fmt.Errorf("not a torrent file: %w", err)
+	}
+
+	infoBytes, ok := raw["info"]
+	if !ok {
+		return nil, fmt.Errorf("torrent file has no info dictionary")
+	}
+	info, err := parseInfo(infoBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	mi := &MetaInfo{
+		InfoHash:  sha1.Sum(infoBytes),
+		Info:      info,
+		InfoBytes: append([]byte(nil), infoBytes...),
+	}
+	seen := make(map[string]bool)
+	addTracker := func(v any) {
+		if s, ok := v.(string); ok && s != "" && !seen[s] {
+			seen[s] = true
+			mi.Trackers = append(mi.Trackers, s)
+		}
+	}
+	if tiers, ok := dictList(dict, "announce-list"); ok {
+		for _, tier := range tiers {
+			if list, ok := tier.([]any); ok {
+				for _, t := range list {
+					addTracker(t)
+				}
+			}
+		}
+	}
+	addTracker(dict["announce"])
+	return mi, nil
+}
+
+// Magnet returns a magnet link carrying the info hash, name and trackers
+func (m *MetaInfo) Magnet() string {
+	return (&Magnet{InfoHash: m.InfoHash, Name: m.Info.Name, Trackers: m.Trackers}).String()
+}
+
+// Magnet is a parsed magnet link
+type Magnet struct {
+	InfoHash [sha1.Size]byte
+	Name     string
+	Trackers []string
+	Peers    []string // host:port addresses from x.pe
+}
+
+// IsMagnet reports whether s is a magnet link
+func IsMagnet(s string) bool {
+	return len(s) > 8 && strings.EqualFold(s[:8], "magnet:?")
+}
+
+// IsTorrentFile reports whether s names a .torrent file
+func IsTorrentFile(s string) bool {
+	return strings.HasSuffix(strings.ToLower(s), ".torrent")
+}
+
+// ParseMagnet parses a magnet link with a BitTorrent v1 info hash (hex or base32)
+func ParseMagnet(uri string) (*Magnet, error) {
+	if !IsMagnet(uri) {
+		return nil, fmt.Errorf("not a magnet link")
+	}
+	query, err := url.ParseQuery(uri[8:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid magnet link: %w", err)
+	}
+
+	m := &Magnet{Name: query.Get("dn")}
+	found := false
+	for _, xt := range query["xt"] {
+		if !strings.HasPrefix(strings.ToLower(xt), "urn:btih:") {
+			continue
+		}
+		hash := xt[len("urn:btih:"):]
+		var decoded []byte
+		switch len(hash) {
+		case 40:
+			decoded, err = hex.DecodeString(hash)
+		case 32:
+			decoded, err = base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+		default:
+			err = fmt.Errorf("unexpected length %d", len(hash))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid info hash in magnet link: %w", err)
+		}
+		copy(m.InfoHash[:], decoded)
+		found = true
+		break
+	}
+	if !found {
+		return nil, fmt.Errorf("magnet link has no BitTorrent info hash")
+	}
+	m.Trackers = query["tr"]
+	m.Peers = query["x.pe"]
+	return m, nil
+}
+
+// HexHash returns the info hash as lowercase hex
+func (m *Magnet) HexHash() string {
+	return hex.EncodeToString(m.InfoHash[:])
+}
+
+// String formats the magnet link with the info hash first
+func (m *Magnet) String() string {
+	var b strings.Builder
+	b.WriteString("magnet:?xt=urn:btih:")
+	b.WriteString(m.HexHash())
+	if m.Name != "" {
+		b.WriteString("&dn=" + url.QueryEscape(m.Name))
+	}
+	for _, tr := range m.Trackers {
+		b.WriteString("&tr=" + url.QueryEscape(tr))
+	}
+	for _, pe := range m.Peers {
+		b.WriteString("&x.pe=" + url.QueryEscape(pe))
+	}
+	return b.String()
+}
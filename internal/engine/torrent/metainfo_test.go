@@ -0,0 +1,218 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFile is a file of a torrent built by makeTorrent
+type testFile struct {
+	path string // Slash-separated; ignored for single-file torrents
+	data []byte
+}
+
+// makeTorrent builds a .torrent file. With one file and no path it is a
+// single-file torrent named name; otherwise a multi-file torrent.
+func makeTorrent(t *testing.T, name string, files []testFile, pieceLength int, trackers ...string) []byte {
+	t.Helper()
+	var all []byte
+	for _, f := range files {
+		all = append(all, f.data...)
+	}
+	var pieces []byte
+	for off := 0; off < len(all); off += pieceLength {
+		sum := sha1.Sum(all[off:min(off+pieceLength, len(all))])
+		pieces = append(pieces, sum[:]...)
+	}
+
+	info := map[string]any{"name": name, "piece length": pieceLength, "pieces": pieces}
+	if len(files) == 1 && files[0].path == "" {
+		info["length"] = len(files[0].data)
+	} else {
+		list := make([]any, 0, len(files))
+		for _, f := range files {
+			list = append(list, map[string]any{"length": len(f.data), "path": strings.Split(f.path, "/")})
+		}
+		info["files"] = list
+	}
+
+	meta := map[string]any{"info": info}
+	if len(trackers) > 0 {
+		meta["announce"] = trackers[0]
+		tiers := make([]any, 0, len(trackers))
+		for _, tr := range trackers {
+			tiers = append(tiers, []string{tr})
+		}
+		meta["announce-list"] = tiers
+	}
+	return encodeBencode(meta)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBencode_RoundTrip(t *testing.T) {
+	v := map[string]any{
+		"int":  int64(-42),
+		"str":  "spam",
+		"list": []any{int64(1), "two", []any{}},
+		"dict": map[string]any{"a": int64(0)},
+	}
+	encoded := encodeBencode(v)
+	if want := "d4:dictd1:ai0ee3:inti-42e4:listli1e3:twolee3:str4:spame"; string(encoded) != want {
+		t.Fatalf("encodeBencode = %s, want %s", encoded, want)
+	}
+	decoded, err := decodeBencode(encoded)
+	if err != nil {
+		t.Fatalf("decodeBencode failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Errorf("Round trip = %#v, want %#v", decoded, v)
+	}
+}
+
+func TestBencode_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Empty", ""},
+		{"Leading zero", "i03e"},
+		{"Negative zero", "i-0e"},
+		{"Empty integer", "ie"},
+		{"Unterminated list", "li1e"},
+		{"String past end", "5:abc"},
+		{"Trailing data", "i1ei2e"},
+		{"Invalid byte", "x"},
+		{"Too deep", strings.Repeat("l", maxBencodeDepth+1) + strings.Repeat("e", maxBencodeDepth+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeBencode([]byte(tt.input)); err == nil {
+				t.Errorf("decodeBencode(%q) succeeded", tt.input)
+			}
+		})
+	}
+}
+
+func TestParseMetaInfo_SingleFile(t *testing.T) {
+	data := randomBytes(t, 100000)
+	raw := makeTorrent(t, "file.bin", []testFile{{data: data}}, 32768, "http://tracker/announce", "udp://tracker:6969")
+
+	mi, err := ParseMetaInfo(raw)
+	if err != nil {
+		t.Fatalf("ParseMetaInfo failed: %v", err)
+	}
+	info := mi.Info
+	if info.Name != "file.bin" || info.MultiFile || info.Length != 100000 || info.NumPieces() != 4 {
+		t.Fatalf("Info = %+v", info)
+	}
+	if got := info.PieceSize(3); got != 100000-3*32768 {
+		t.Errorf("Last piece size = %d", got)
+	}
+	if sha1.Sum(mi.InfoBytes) != mi.InfoHash || !bytes.Contains(raw, mi.InfoBytes) {
+		t.Error("InfoHash is not the hash of the encoded info dictionary")
+	}
+	if want := []string{"http://tracker/announce", "udp://tracker:6969"}; !reflect.DeepEqual(mi.Trackers, want) {
+		t.Errorf("Trackers = %v, want %v", mi.Trackers, want)
+	}
+}
+
+func TestParseMetaInfo_MultiFile(t *testing.T) {
+	raw := makeTorrent(t, "album", []testFile{
+		{path: "cd1/01.flac", data: randomBytes(t, 5000)},
+		{path: "cover.jpg", data: randomBytes(t, 3000)},
+	}, 4096)
+
+	mi, err := ParseMetaInfo(raw)
+	if err != nil {
+		t.Fatalf("ParseMetaInfo failed: %v", err)
+	}
+	want := []File{{Path: "cd1/01.flac", Length: 5000}, {Path: "cover.jpg", Length: 3000, Offset: 5000}}
+	if !mi.Info.MultiFile || !reflect.DeepEqual(mi.Info.Files, want) {
+		t.Errorf("Files = %+v, want %+v", mi.Info.Files, want)
+	}
+}
+
+func TestParseMetaInfo_Invalid(t *testing.T) {
+	good := randomBytes(t, 1000)
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"Not bencode", []byte("<html>")},
+		{"No info", encodeBencode(map[string]any{"announce": "http://t"})},
+		{"Path traversal", makeTorrent(t, "x", []testFile{{path: "../etc/passwd", data: good}}, 512)},
+		{"Dot name", makeTorrent(t, "..", []testFile{{data: good}}, 512)},
+		{"Duplicate files", makeTorrent(t, "x", []testFile{{path: "a", data: good}, {path: "a", data: good}}, 512)},
+		{"Wrong piece count", encodeBencode(map[string]any{"info": map[string]any{
+			"name": "x", "piece length": 512, "length": 1000, "pieces": make([]byte, 20),
+		}})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMetaInfo(tt.raw); err == nil {
+				t.Error("ParseMetaInfo succeeded")
+			}
+		})
+	}
+}
+
+func TestParseMagnet(t *testing.T) {
+	hash := sha1.Sum([]byte("torrent"))
+	b32 := base32.StdEncoding.EncodeToString(hash[:])
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    *Magnet
+		wantErr bool
+	}{
+		{
+			name: "Hex with trackers",
+			uri:  "magnet:?xt=urn:btih:" + strings.ToUpper((&Magnet{InfoHash: hash}).HexHash()) + "&dn=My+File&tr=udp%3A%2F%2Ft%3A80&tr=http%3A%2F%2Fu%2Fa&x.pe=10.0.0.1%3A6881",
+			want: &Magnet{InfoHash: hash, Name: "My File", Trackers: []string{"udp://t:80", "http://u/a"}, Peers: []string{"10.0.0.1:6881"}},
+		},
+		{
+			name: "Base32",
+			uri:  "magnet:?xt=urn:btih:" + strings.ToLower(b32),
+			want: &Magnet{InfoHash: hash},
+		},
+		{name: "No info hash", uri: "magnet:?dn=file", wantErr: true},
+		{name: "Short hash", uri: "magnet:?xt=urn:btih:abcd", wantErr: true},
+		{name: "Not a magnet", uri: "https://example.com/file", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMagnet(tt.uri)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMagnet(%q) succeeded", tt.uri)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMagnet failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMagnet = %+v, want %+v", got, tt.want)
+			}
+			// String must produce a link that parses back to the same magnet
+			again, err := ParseMagnet(got.String())
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("Round trip of %q = %+v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
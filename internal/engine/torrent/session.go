@@ -0,0 +1,235 @@
+package torrent
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// peerIDPrefix identifies the client in Azureus-style peer IDs
+const peerIDPrefix = "-SG0001-"
+
+// Session owns the listening socket shared by every torrent and routes incoming
+// peers to the torrent they ask for
+type Session struct {
+	peerID   [sha1.Size]byte
+	listener net.Listener
+	tracker  *http.Client
+
+	// Upload caps the bandwidth used to serve peers across all torrents (zero value = unlimited)
+	Upload bandwidth.Limiter
+
+	mu     sync.Mutex
+	swarms map[[sha1.Size]byte]*swarm
+	closed bool
+}
+
+// NewSession starts a session accepting peers on listenAddr (e.g. ":6881").
+// An empty address creates a session that only makes outgoing connections.
+func NewSession(listenAddr string, proxyURL string) (*Session, error) {
+	s := &Session{swarms: make(map[[sha1.Size]byte]*swarm)}
+	copy(s.peerID[:], peerIDPrefix)
+	if _, err := rand.Read(s.peerID[len(peerIDPrefix):]); err != nil {
+		return nil, err
+	}
+
+	// Trackers are plain HTTP endpoints and honour the configured proxy; peer
+	// connections do not, as HTTP proxies cannot carry them
+	proxyFunc := http.ProxyFromEnvironment
+	if proxyURL != "" {
+		if parsed, err := url.Parse(proxyURL); err == nil {
+			proxyFunc = http.ProxyURL(parsed)
+		}
+	}
+	s.tracker = &http.Client{
+		Timeout:   types.ProbeTimeout,
+		Transport: &http.Transport{Proxy: proxyFunc, DialContext: (&net.Dialer{Timeout: types.DialTimeout}).DialContext},
+	}
+
+	if listenAddr != "" {
+		ln, err := net.Listen("tcp", listenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for peers: %w", err)
+		}
+		s.listener = ln
+		go s.acceptLoop()
+	}
+	return s, nil
+}
+
+// Port returns the port peers can connect to, or 0 when not listening
+func (s *Session) Port() int {
+	if s.listener == nil {
+		return 0
+	}
+	if addr, ok := s.listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// announcePort is the port reported to trackers; without a listener the
+// conventional port is announced since some trackers reject port 0
+func (s *Session) announcePort() int {
+	if port := s.Port(); port > 0 {
+		return port
+	}
+	return types.DefaultTorrentListenPort
+}
+
+// Close stops every torrent of the session, seeding ones included
+func (s *Session) Close() {
+	s.mu.Lock()
+	s.closed = true
+	swarms := make([]*swarm, 0, len(s.swarms))
+	for _, sw := range s.swarms {
+		swarms = append(swarms, sw)
+	}
+	s.mu.Unlock()
+
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	for _, sw := range swarms {
+		sw.close()
+	}
+}
+
+// StopSeeding stops the torrent with the given info hash if it is only seeding
+func (s *Session) StopSeeding(infoHash [sha1.Size]byte) {
+	s.mu.Lock()
+	sw := s.swarms[infoHash]
+	s.mu.Unlock()
+	if sw != nil && sw.isSeeding() {
+		sw.close()
+	}
+}
+
+// register adds a swarm, replacing a finished torrent that is still seeding
+func (s *Session) register(sw *swarm) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("torrent session is closed")
+	}
+	old := s.swarms[sw.infoHash]
+	if old != nil && !old.isSeeding() {
+		s.mu.Unlock()
+		return errors.New("torrent is already being downloaded")
+	}
+	s.mu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.swarms[sw.infoHash] != nil {
+		return errors.New("torrent is already being downloaded")
+	}
+	s.swarms[sw.infoHash] = sw
+	return nil
+}
+
+func (s *Session) unregister(sw *swarm) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.swarms[sw.infoHash] == sw {
+		delete(s.swarms, sw.infoHash)
+	}
+}
+
+func (s *Session) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return
+		}
+		go s.handleIncoming(conn)
+	}
+}
+
+// handleIncoming answers a peer's handshake if it asks for a torrent of this session
+func (s *Session) handleIncoming(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	h, err := readHandshake(conn)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	sw := s.swarms[h.InfoHash]
+	s.mu.Unlock()
+	if sw == nil || h.PeerID == s.peerID {
+		_ = conn.Close()
+		return
+	}
+	if err := writeHandshake(conn, handshake{InfoHash: h.InfoHash, PeerID: s.peerID}); err != nil {
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	sw.addPeer(conn, h)
+}
+
+var (
+	defaultSessionMu sync.Mutex
+	defaultSession   *Session
+)
+
+// DefaultSession returns the process-wide session, starting it on first use with the
+// listen port from runtime. If the port is taken another one is picked.
+func DefaultSession(runtime *types.RuntimeConfig) *Session {
+	defaultSessionMu.Lock()
+	defer defaultSessionMu.Unlock()
+	if defaultSession != nil {
+		return defaultSession
+	}
+
+	port := runtime.GetTorrentListenPort()
+	var proxyURL string
+	if runtime != nil {
+		proxyURL = runtime.ProxyURL
+	}
+	for _, addr := range []string{":" + strconv.Itoa(port), ":0", ""} {
+		s, err := NewSession(addr, proxyURL)
+		if err == nil {
+			utils.Debug("Torrent session listening on port %d", s.Port())
+			defaultSession = s
+			break
+		}
+		utils.Debug("Torrent session: %v", err)
+	}
+	return defaultSession
+}
+
+// StopSeeding stops seeding the torrent of a magnet link in the default session
+func StopSeeding(rawurl string) {
+	m, err := ParseMagnet(rawurl)
+	if err != nil {
+		return
+	}
+	defaultSessionMu.Lock()
+	s := defaultSession
+	defaultSessionMu.Unlock()
+	if s != nil {
+		s.StopSeeding(m.InfoHash)
+	}
+}
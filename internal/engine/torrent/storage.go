@@ -0,0 +1,131 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// storage maps the torrent's byte stream onto its files. A single-file torrent is
+// stored at root; a multi-file torrent in a directory at root.
+type storage struct {
+	info  *Info
+	root  string
+	files []*os.File // nil for padding files
+}
+
+// filePath returns where f is stored under root
+func (s *storage) filePath(f File) string {
+	if !s.info.MultiFile {
+		return s.root
+	}
+	return filepath.Join(s.root, filepath.FromSlash(f.Path))
+}
+
+// openStorage opens every file of the torrent under root. In write mode missing files
+// are created and sized; read-only mode is used to seed completed downloads.
+func openStorage(info *Info, root string, write bool) (*storage, error) {
+	s := &storage{info: info, root: root, files: make([]*os.File, len(info.Files))}
+	for i, f := range info.Files {
+		if f.Padding {
+			continue
+		}
+		p := s.filePath(f)
+		var file *os.File
+		var err error
+		if write {
+			if err = os.MkdirAll(filepath.Dir(p), 0o755); err == nil {
+				file, err = os.OpenFile(p, os.O_CREATE|os.O_RDWR, 0o644)
+			}
+		} else {
+			file, err = os.Open(p)
+		}
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to open %s: %w", f.Path, err)
+		}
+		s.files[i] = file
+
+		if write {
+			if st, err := file.Stat(); err != nil || st.Size() != f.Length {
+				if err := file.Truncate(f.Length); err != nil {
+					s.close()
+					return nil, fmt.Errorf("failed to preallocate %s: %w", f.Path, err)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *storage) close() {
+	for i, f := range s.files {
+		if f != nil {
+			_ = f.Close()
+			s.files[i] = nil
+		}
+	}
+}
+
+func (s *storage) sync() error {
+	for _, f := range s.files {
+		if f != nil {
+			if err := f.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// span calls fn for each part of [off, off+n) that falls in a file
+func (s *storage) span(off int64, n int, fn func(file *os.File, fileOff int64, lo, hi int) error) error {
+	end := off + int64(n)
+	for i, f := range s.info.Files {
+		fileEnd := f.Offset + f.Length
+		if fileEnd <= off || f.Offset >= end || f.Length == 0 {
+			continue
+		}
+		lo := max(off, f.Offset)
+		hi := min(end, fileEnd)
+		if err := fn(s.files[i], lo-f.Offset, int(lo-off), int(hi-off)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *storage) writeAt(data []byte, off int64) error {
+	return s.span(off, len(data), func(file *os.File, fileOff int64, lo, hi int) error {
+		if file == nil {
+			return nil // Padding
+		}
+		_, err := file.WriteAt(data[lo:hi], fileOff)
+		return err
+	})
+}
+
+func (s *storage) readAt(buf []byte, off int64) error {
+	return s.span(off, len(buf), func(file *os.File, fileOff int64, lo, hi int) error {
+		if file == nil {
+			clear(buf[lo:hi])
+			return nil
+		}
+		_, err := file.ReadAt(buf[lo:hi], fileOff)
+		if err == io.EOF {
+			return fmt.Errorf("file is shorter than expected")
+		}
+		return err
+	})
+}
+
+// verifyPiece reports whether the stored data of piece index matches its hash
+func (s *storage) verifyPiece(index int) bool {
+	buf := make([]byte, s.info.PieceSize(index))
+	if err := s.readAt(buf, int64(index)*s.info.PieceLength); err != nil {
+		return false
+	}
+	return sha1.Sum(buf) == s.info.Pieces[index]
+}
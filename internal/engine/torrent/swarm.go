@@ -0,0 +1,1181 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
+	"github.com/surge-downloader/surge/internal/engine/metrics"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+const (
+	maxPeersPerTorrent  = 50
+	maxPendingRequests  = 32 // Blocks requested from one peer at a time
+	maxUploadSlots      = 8  // Peers unchoked at once
+	maxHashFailures     = 3  // Bad pieces a peer may contribute before it is banned
+	peerSendQueue       = 512
+	handshakeTimeout    = 10 * time.Second
+	peerIdleTimeout     = 2 * time.Minute
+	peerWriteTimeout    = time.Minute
+	peerSnubTimeout     = time.Minute // Requests unanswered this long drop the peer
+	keepAliveInterval   = 90 * time.Second
+	metadataRetry       = 10 * time.Second
+	maintenanceInterval = time.Second
+	trackerRetryBase    = time.Minute
+	stoppedTimeout      = 5 * time.Second
+)
+
+// blockKey identifies a requested block
+type blockKey struct {
+	index int
+	begin int
+}
+
+// pieceDownload collects the blocks of a piece until it can be verified
+type pieceDownload struct {
+	index        int
+	data         []byte
+	received     []bool
+	nreceived    int
+	owners       int            // Peers currently requesting blocks of this piece
+	contributors map[*peer]bool // Peers that sent blocks, blamed if the hash fails
+}
+
+func newPieceDownload(index int, size int64) *pieceDownload {
+	blocks := int((size + blockSize - 1) / blockSize)
+	return &pieceDownload{
+		index:        index,
+		data:         make([]byte, size),
+		received:     make([]bool, blocks),
+		contributors: make(map[*peer]bool),
+	}
+}
+
+func (pd *pieceDownload) blockLen(b int) int {
+	return min(blockSize, len(pd.data)-b*blockSize)
+}
+
+// metadataFetch assembles the info dictionary from ut_metadata pieces
+type metadataFetch struct {
+	data      []byte
+	got       []bool
+	requested []time.Time
+	remaining int
+}
+
+func newMetadataFetch(size int64) *metadataFetch {
+	n := int((size + metadataPiece - 1) / metadataPiece)
+	return &metadataFetch{data: make([]byte, size), got: make([]bool, n), requested: make([]time.Time, n), remaining: n}
+}
+
+// outgoing is a queued message; uploads are read from disk only when they are sent
+type outgoing struct {
+	data   []byte
+	upload *blockRequest
+}
+
+// blockRequest is a block a peer asked for
+type blockRequest struct {
+	index, begin, length int
+}
+
+// peer is one connection of a swarm. Fields below the line are guarded by swarm.mu.
+type peer struct {
+	s         *swarm
+	conn      net.Conn
+	addr      string
+	out       chan outgoing
+	done      chan struct{}
+	closeOnce sync.Once
+
+	bits           bitfield
+	count          int    // Pieces the peer has
+	rawBits        []byte // Bitfield received before the metadata was known
+	rawHaves       []int
+	peerChoking    bool
+	peerInterested bool
+	amChoking      bool
+	amInterested   bool
+	ext            extensionHandshake
+	piece          *pieceDownload
+	pending        map[blockKey]bool
+	exhausted      bool // Peer has nothing left to give until it announces more pieces
+	lastBlock      time.Time
+}
+
+// send queues a message; a peer that does not keep up with its queue is dropped
+func (p *peer) send(o outgoing) {
+	select {
+	case p.out <- o:
+	default:
+		p.close()
+	}
+}
+
+func (p *peer) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		_ = p.conn.Close()
+	})
+}
+
+// swarm is one torrent's set of peer connections. It fetches metadata for magnet
+// links, downloads pieces into storage once attached, and serves pieces it has.
+type swarm struct {
+	session  *Session
+	infoHash [sha1.Size]byte
+	trackers []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	uploaded atomic.Int64 // Bytes served to peers
+
+	metaReady chan struct{} // Closed once info is known
+	completed chan struct{} // Closed once every piece is verified
+	failed    chan struct{} // Closed on a storage error
+	closeOnce sync.Once
+
+	storeMu sync.RWMutex // Guards store; held for reading around disk access
+	store   *storage
+
+	mu           sync.Mutex
+	info         *Info
+	infoBytes    []byte
+	meta         *metadataFetch
+	active       bool // Storage is attached and pieces are being downloaded
+	seeding      bool
+	have         bitfield
+	numHave      int
+	haveBytes    int64
+	availability []int
+	pieces       map[int]*pieceDownload
+	verifying    map[int]bool
+	peers        map[*peer]bool
+	addrs        map[string]bool // Addresses connected to or dialed
+	candidates   []string
+	dialing      int
+	strikes      map[string]int
+	banned       map[string]bool
+	unchoked     int
+	err          error
+
+	// Set by the downloader before attaching storage
+	sequential bool
+	limiters   []*bandwidth.Limiter
+	onBytes    func(n int64)                 // Raw block bytes received (negative when discarded)
+	onPiece    func(index int, length int64) // Piece verified and written
+}
+
+func newSwarm(session *Session, infoHash [sha1.Size]byte, trackers, peers []string) *swarm {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &swarm{
+		session:    session,
+		infoHash:   infoHash,
+		trackers:   trackers,
+		ctx:        ctx,
+		cancel:     cancel,
+		metaReady:  make(chan struct{}),
+		completed:  make(chan struct{}),
+		failed:     make(chan struct{}),
+		pieces:     make(map[int]*pieceDownload),
+		verifying:  make(map[int]bool),
+		peers:      make(map[*peer]bool),
+		addrs:      make(map[string]bool),
+		candidates: append([]string(nil), peers...),
+		strikes:    make(map[string]int),
+		banned:     make(map[string]bool),
+	}
+}
+
+// run starts announcing and connecting to peers
+func (s *swarm) run() {
+	for _, tr := range s.trackers {
+		s.wg.Add(1)
+		go s.trackerLoop(tr)
+	}
+	s.wg.Add(1)
+	go s.maintenanceLoop()
+}
+
+// close disconnects every peer and tells trackers the torrent has stopped
+func (s *swarm) close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.mu.Lock()
+		for p := range s.peers {
+			p.close()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+		s.session.unregister(s)
+
+		if st := s.detachStorage(); st != nil {
+			st.close()
+		}
+		s.announceAll("stopped")
+	})
+}
+
+func (s *swarm) isSeeding() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seeding
+}
+
+func (s *swarm) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+		close(s.failed)
+	}
+}
+
+// setInfoLocked installs verified metadata and replays what peers announced so far
+func (s *swarm) setInfoLocked(info *Info, infoBytes []byte) {
+	if s.info != nil {
+		return
+	}
+	s.info = info
+	s.infoBytes = infoBytes
+	s.meta = nil
+	n := info.NumPieces()
+	s.have = newBitfield(n)
+	s.availability = make([]int, n)
+
+	for p := range s.peers {
+		if p.rawBits != nil {
+			if err := s.applyBitfieldLocked(p, p.rawBits); err != nil {
+				p.close()
+				continue
+			}
+		} else {
+			p.bits = newBitfield(n)
+		}
+		for _, i := range p.rawHaves {
+			s.applyHaveLocked(p, i)
+		}
+		p.rawBits, p.rawHaves = nil, nil
+		// Repeat the extension handshake so peers learn they can fetch metadata from us
+		if p.ext.UTMetadata > 0 {
+			p.send(outgoing{data: encodeExtensionHandshake(len(infoBytes), s.session.Port())})
+		}
+	}
+	close(s.metaReady)
+}
+
+// attach starts downloading into st. have lists pieces already verified on disk.
+func (s *swarm) attach(st *storage, have bitfield) {
+	s.storeMu.Lock()
+	s.store = st
+	s.storeMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.info.NumPieces()
+	s.have = have
+	s.numHave, s.haveBytes = 0, 0
+	var haves []byte
+	for i := 0; i < n; i++ {
+		if have.has(i) {
+			s.numHave++
+			s.haveBytes += s.info.PieceSize(i)
+			haves = append(haves, encodeMessage(msgHave, uint32s(uint32(i)))...)
+		}
+	}
+	s.active = true
+
+	for p := range s.peers {
+		// Peers connected before storage was attached got no bitfield
+		if len(haves) > 0 {
+			p.send(outgoing{data: haves})
+		}
+		s.updateInterestLocked(p)
+		s.pumpLocked(p)
+	}
+	s.unchokeWaitingLocked()
+	if s.numHave == n {
+		s.markCompleteLocked()
+	}
+}
+
+// detachStorage stops disk access and returns the storage for closing
+func (s *swarm) detachStorage() *storage {
+	s.mu.Lock()
+	s.active = false
+	s.mu.Unlock()
+
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	st := s.store
+	s.store = nil
+	return st
+}
+
+// seed serves pieces from st, the completed files at their final location
+func (s *swarm) seed(st *storage) {
+	s.storeMu.Lock()
+	s.store = st
+	s.storeMu.Unlock()
+
+	s.mu.Lock()
+	s.seeding = true
+	s.unchokeWaitingLocked()
+	s.mu.Unlock()
+}
+
+func (s *swarm) markCompleteLocked() {
+	select {
+	case <-s.completed:
+		return
+	default:
+	}
+	close(s.completed)
+	for p := range s.peers {
+		if p.amInterested {
+			p.amInterested = false
+			p.send(outgoing{data: encodeMessage(msgNotInterested)})
+		}
+	}
+}
+
+func (s *swarm) isCompleteLocked() bool {
+	select {
+	case <-s.completed:
+		return true
+	default:
+		return false
+	}
+}
+
+// addPeer takes over a connection that has completed the handshake
+func (s *swarm) addPeer(conn net.Conn, h handshake) {
+	s.mu.Lock()
+	addr := conn.RemoteAddr().String()
+	if s.ctx.Err() != nil || len(s.peers) >= maxPeersPerTorrent || s.banned[hostOf(addr)] {
+		s.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	p := &peer{
+		s:           s,
+		conn:        conn,
+		addr:        addr,
+		out:         make(chan outgoing, peerSendQueue),
+		done:        make(chan struct{}),
+		peerChoking: true,
+		amChoking:   true,
+		pending:     make(map[blockKey]bool),
+		lastBlock:   time.Now(),
+	}
+	s.peers[p] = true
+	s.addrs[addr] = true
+	if s.info != nil {
+		p.bits = newBitfield(s.info.NumPieces())
+	}
+
+	if h.Extensions {
+		p.send(outgoing{data: encodeExtensionHandshake(len(s.infoBytes), s.session.Port())})
+	}
+	if s.active || s.seeding {
+		if s.numHave > 0 {
+			p.send(outgoing{data: encodeMessage(msgBitfield, s.have)})
+		}
+	}
+	s.wg.Add(2)
+	s.mu.Unlock()
+
+	go p.writeLoop()
+	go p.readLoop()
+}
+
+func (s *swarm) removePeer(p *peer) {
+	p.close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.peers[p] {
+		return
+	}
+	delete(s.peers, p)
+	s.releasePieceLocked(p)
+	if s.info != nil && p.bits != nil {
+		for i := range s.availability {
+			if p.bits.has(i) {
+				s.availability[i]--
+			}
+		}
+	}
+	if !p.amChoking {
+		s.unchoked--
+		s.unchokeWaitingLocked()
+	}
+}
+
+// peerCount returns the number of connected peers
+func (s *swarm) peerCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.peers)
+}
+
+func (p *peer) writeLoop() {
+	defer p.s.wg.Done()
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var data []byte
+		select {
+		case <-p.done:
+			return
+		case <-keepAlive.C:
+			data = make([]byte, 4)
+		case o := <-p.out:
+			data = o.data
+			if o.upload != nil {
+				if data = p.s.readBlock(*o.upload); data == nil {
+					continue
+				}
+				if err := p.s.session.Upload.WaitN(p.s.ctx, len(data)); err != nil {
+					return
+				}
+			}
+		}
+		_ = p.conn.SetWriteDeadline(time.Now().Add(peerWriteTimeout))
+		if _, err := p.conn.Write(data); err != nil {
+			p.close()
+			return
+		}
+		if len(data) > 13 && data[4] == msgPiece {
+			p.s.uploaded.Add(int64(len(data) - 13))
+		}
+	}
+}
+
+// readBlock reads a block to serve, returning the encoded piece message
+func (s *swarm) readBlock(r blockRequest) []byte {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	if s.store == nil {
+		return nil
+	}
+	buf := make([]byte, r.length)
+	if err := s.store.readAt(buf, int64(r.index)*s.store.info.PieceLength+int64(r.begin)); err != nil {
+		utils.Debug("Torrent: failed to read block %d/%d: %v", r.index, r.begin, err)
+		return nil
+	}
+	return encodeMessage(msgPiece, uint32s(uint32(r.index), uint32(r.begin)), buf)
+}
+
+func (p *peer) readLoop() {
+	defer p.s.wg.Done()
+	defer p.s.removePeer(p)
+
+	for {
+		_ = p.conn.SetReadDeadline(time.Now().Add(peerIdleTimeout))
+		msg, err := readMessage(p.conn)
+		if err != nil {
+			return
+		}
+		if msg == nil {
+			continue // Keep-alive
+		}
+		if msg.ID == msgPiece {
+			metrics.AddBytes(len(msg.Payload) - 8) // Less the piece index and offset
+			p.s.mu.Lock()
+			limiters := p.s.limiters
+			p.s.mu.Unlock()
+			if err := bandwidth.Wait(p.s.ctx, len(msg.Payload), limiters...); err != nil {
+				return
+			}
+		}
+		if err := p.s.handleMessage(p, msg); err != nil {
+			utils.Debug("Torrent: dropping peer %s: %v", p.addr, err)
+			return
+		}
+	}
+}
+
+func (s *swarm) handleMessage(p *peer, msg *message) error {
+	if msg.ID == msgPiece {
+		return s.receiveBlock(p, msg.Payload)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch msg.ID {
+	case msgChoke:
+		p.peerChoking = true
+		s.releasePieceLocked(p)
+	case msgUnchoke:
+		p.peerChoking = false
+		p.lastBlock = time.Now()
+		s.pumpLocked(p)
+	case msgInterested:
+		p.peerInterested = true
+		s.unchokeWaitingLocked()
+	case msgNotInterested:
+		p.peerInterested = false
+		if !p.amChoking {
+			p.amChoking = true
+			s.unchoked--
+			p.send(outgoing{data: encodeMessage(msgChoke)})
+			s.unchokeWaitingLocked()
+		}
+	case msgHave:
+		if len(msg.Payload) != 4 {
+			return errors.New("malformed have message")
+		}
+		index := int(binary.BigEndian.Uint32(msg.Payload))
+		if s.info == nil {
+			if len(p.rawHaves) < maxMetadata {
+				p.rawHaves = append(p.rawHaves, index)
+			}
+			return nil
+		}
+		if index >= s.info.NumPieces() {
+			return errors.New("have for a piece out of range")
+		}
+		s.applyHaveLocked(p, index)
+		if s.active && !s.have.has(index) {
+			p.exhausted = false
+			s.updateInterestLocked(p)
+			s.pumpLocked(p)
+		}
+	case msgBitfield:
+		if s.info == nil {
+			p.rawBits = bytes.Clone(msg.Payload)
+			return nil
+		}
+		if err := s.applyBitfieldLocked(p, msg.Payload); err != nil {
+			return err
+		}
+		p.exhausted = false
+		s.updateInterestLocked(p)
+		s.pumpLocked(p)
+	case msgRequest:
+		index, begin, length, err := parseBlock(msg.Payload)
+		if err != nil {
+			return err
+		}
+		return s.serveRequestLocked(p, index, begin, length)
+	case msgCancel:
+		// Blocks are small; a cancelled one is sent anyway
+	case msgExtended:
+		if len(msg.Payload) == 0 {
+			return errors.New("empty extended message")
+		}
+		return s.handleExtendedLocked(p, msg.Payload[0], msg.Payload[1:])
+	}
+	return nil
+}
+
+func (s *swarm) applyBitfieldLocked(p *peer, payload []byte) error {
+	n := s.info.NumPieces()
+	if len(payload) != (n+7)/8 {
+		return errors.New("bitfield has the wrong length")
+	}
+	bits := bitfield(bytes.Clone(payload))
+	for i := n; i < len(bits)*8; i++ {
+		if bits.has(i) {
+			return errors.New("bitfield has spare bits set")
+		}
+	}
+	if p.bits != nil {
+		for i := 0; i < n; i++ {
+			if p.bits.has(i) {
+				s.availability[i]--
+			}
+		}
+	}
+	p.bits, p.count = bits, 0
+	for i := 0; i < n; i++ {
+		if bits.has(i) {
+			s.availability[i]++
+			p.count++
+		}
+	}
+	return nil
+}
+
+func (s *swarm) applyHaveLocked(p *peer, index int) {
+	if index < 0 || index >= s.info.NumPieces() {
+		return
+	}
+	if p.bits == nil {
+		p.bits = newBitfield(s.info.NumPieces())
+	}
+	if !p.bits.has(index) {
+		p.bits.set(index)
+		p.count++
+		s.availability[index]++
+	}
+}
+
+// updateInterestLocked tells a choking peer whether it has anything we need
+func (s *swarm) updateInterestLocked(p *peer) {
+	if !s.active || p.bits == nil || s.isCompleteLocked() {
+		return
+	}
+	want := false
+	for i := 0; i < s.info.NumPieces(); i++ {
+		if p.bits.has(i) && !s.have.has(i) {
+			want = true
+			break
+		}
+	}
+	s.setInterestLocked(p, want)
+}
+
+func (s *swarm) setInterestLocked(p *peer, want bool) {
+	if want != p.amInterested {
+		p.amInterested = want
+		id := msgNotInterested
+		if want {
+			id = msgInterested
+		}
+		p.send(outgoing{data: encodeMessage(id)})
+	}
+}
+
+// unchokeWaitingLocked unchokes interested peers while upload slots are free
+func (s *swarm) unchokeWaitingLocked() {
+	if !s.active && !s.seeding {
+		return
+	}
+	for p := range s.peers {
+		if s.unchoked >= maxUploadSlots {
+			return
+		}
+		if p.peerInterested && p.amChoking {
+			p.amChoking = false
+			s.unchoked++
+			p.send(outgoing{data: encodeMessage(msgUnchoke)})
+		}
+	}
+}
+
+func (s *swarm) serveRequestLocked(p *peer, index, begin, length int) error {
+	if p.amChoking || s.info == nil || !s.have.has(index) || (!s.active && !s.seeding) {
+		return nil // Requests while choked are dropped, as the protocol allows
+	}
+	if length <= 0 || length > 2*blockSize || begin < 0 || int64(begin+length) > s.info.PieceSize(index) {
+		return errors.New("invalid block request")
+	}
+	p.send(outgoing{upload: &blockRequest{index, begin, length}})
+	return nil
+}
+
+// releasePieceLocked gives up p's piece; its partial data stays for another peer
+func (s *swarm) releasePieceLocked(p *peer) {
+	if p.piece != nil {
+		p.piece.owners--
+		p.piece = nil
+	}
+	clear(p.pending)
+}
+
+// pumpLocked assigns p a piece and keeps its request pipeline full
+func (s *swarm) pumpLocked(p *peer) {
+	if !s.active || p.peerChoking || p.bits == nil || s.isCompleteLocked() {
+		return
+	}
+	if p.piece == nil && !p.exhausted {
+		if p.piece = s.pickLocked(p); p.piece == nil {
+			p.exhausted = true
+		}
+	}
+	s.setInterestLocked(p, p.piece != nil)
+	if p.piece == nil {
+		return
+	}
+
+	pd := p.piece
+	for b := range pd.received {
+		if len(p.pending) >= maxPendingRequests {
+			break
+		}
+		key := blockKey{pd.index, b * blockSize}
+		if pd.received[b] || p.pending[key] {
+			continue
+		}
+		if len(p.pending) == 0 {
+			p.lastBlock = time.Now()
+		}
+		p.pending[key] = true
+		p.send(outgoing{data: encodeMessage(msgRequest, uint32s(uint32(pd.index), uint32(key.begin), uint32(pd.blockLen(b))))})
+	}
+}
+
+// pickLocked chooses the next piece for p: an abandoned partial piece, then the
+// rarest missing piece (or the first, in sequential mode), then endgame duplicates
+func (s *swarm) pickLocked(p *peer) *pieceDownload {
+	for _, pd := range s.pieces {
+		if pd.owners == 0 && p.bits.has(pd.index) {
+			pd.owners++
+			return pd
+		}
+	}
+
+	n := s.info.NumPieces()
+	best := -1
+	start := 0
+	if !s.sequential {
+		start = rand.IntN(n)
+	}
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if s.have.has(i) || s.pieces[i] != nil || s.verifying[i] || !p.bits.has(i) {
+			continue
+		}
+		if s.sequential {
+			best = i
+			break
+		}
+		if best < 0 || s.availability[i] < s.availability[best] {
+			best = i
+		}
+	}
+	if best >= 0 {
+		pd := newPieceDownload(best, s.info.PieceSize(best))
+		pd.owners = 1
+		s.pieces[best] = pd
+		return pd
+	}
+
+	// Endgame: every missing piece is in flight, so help with the ones this peer has
+	for _, pd := range s.pieces {
+		if p.bits.has(pd.index) {
+			pd.owners++
+			return pd
+		}
+	}
+	return nil
+}
+
+// receiveBlock stores a block and verifies the piece once it is complete
+func (s *swarm) receiveBlock(p *peer, payload []byte) error {
+	if len(payload) < 8 {
+		return errors.New("malformed piece message")
+	}
+	index := int(binary.BigEndian.Uint32(payload))
+	begin := int(binary.BigEndian.Uint32(payload[4:]))
+	data := payload[8:]
+
+	s.mu.Lock()
+	delete(p.pending, blockKey{index, begin})
+	p.lastBlock = time.Now()
+
+	pd := s.pieces[index]
+	b := begin / blockSize
+	if pd == nil || begin%blockSize != 0 || b >= len(pd.received) || len(data) != pd.blockLen(b) || pd.received[b] {
+		s.pumpLocked(p) // Late, duplicate or unsolicited block
+		s.mu.Unlock()
+		return nil
+	}
+	copy(pd.data[begin:], data)
+	pd.received[b] = true
+	pd.nreceived++
+	pd.contributors[p] = true
+
+	var done *pieceDownload
+	if pd.nreceived == len(pd.received) {
+		done = pd
+		delete(s.pieces, index)
+		s.verifying[index] = true
+		for other := range s.peers {
+			if other.piece == pd {
+				other.piece = nil
+				for key := range other.pending {
+					if key.index == index {
+						delete(other.pending, key)
+						if other != p {
+							other.send(outgoing{data: encodeMessage(msgCancel, uint32s(uint32(index), uint32(key.begin), uint32(pd.blockLen(key.begin/blockSize))))})
+						}
+					}
+				}
+				if other != p {
+					s.pumpLocked(other)
+				}
+			}
+		}
+	}
+	s.pumpLocked(p)
+	onBytes := s.onBytes
+	s.mu.Unlock()
+
+	if onBytes != nil {
+		onBytes(int64(len(data)))
+	}
+	if done != nil {
+		s.finishPiece(done)
+	}
+	return nil
+}
+
+// finishPiece verifies a completed piece and writes it to storage
+func (s *swarm) finishPiece(pd *pieceDownload) {
+	ok := sha1.Sum(pd.data) == s.info.Pieces[pd.index]
+	written := false
+	if ok {
+		s.storeMu.RLock()
+		var err error
+		if s.store != nil { // Otherwise detached while the piece was in flight
+			if err = s.store.writeAt(pd.data, int64(pd.index)*s.info.PieceLength); err == nil {
+				written = true
+			}
+		}
+		s.storeMu.RUnlock()
+		if err != nil {
+			s.fail(fmt.Errorf("failed to write piece %d: %w", pd.index, err))
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.verifying, pd.index)
+	onBytes, onPiece := s.onBytes, s.onPiece
+	if ok && !written {
+		s.mu.Unlock()
+		return
+	}
+	if !ok {
+		for p := range pd.contributors {
+			host := hostOf(p.addr)
+			if s.strikes[host]++; s.strikes[host] >= maxHashFailures {
+				utils.Debug("Torrent: banning %s after %d bad pieces", host, s.strikes[host])
+				s.banned[host] = true
+				p.close()
+			}
+		}
+		// The piece can be picked again
+		for p := range s.peers {
+			if p.exhausted {
+				p.exhausted = false
+				s.pumpLocked(p)
+			}
+		}
+		s.mu.Unlock()
+		if onBytes != nil {
+			onBytes(-int64(len(pd.data)))
+		}
+		return
+	}
+
+	if s.have.has(pd.index) {
+		s.mu.Unlock()
+		return
+	}
+	s.have.set(pd.index)
+	s.numHave++
+	s.haveBytes += int64(len(pd.data))
+	have := encodeMessage(msgHave, uint32s(uint32(pd.index)))
+	for p := range s.peers {
+		if p.bits == nil || !p.bits.has(pd.index) {
+			p.send(outgoing{data: have})
+		}
+	}
+	if s.numHave == s.info.NumPieces() {
+		s.markCompleteLocked()
+	}
+	s.mu.Unlock()
+
+	if onPiece != nil {
+		onPiece(pd.index, int64(len(pd.data)))
+	}
+}
+
+func (s *swarm) handleExtendedLocked(p *peer, id byte, payload []byte) error {
+	switch id {
+	case 0:
+		h, err := parseExtensionHandshake(payload)
+		if err != nil {
+			return err
+		}
+		p.ext = h
+		s.requestMetadataLocked(p)
+	case utMetadataID:
+		m, err := parseMetadataMessage(payload)
+		if err != nil {
+			return err
+		}
+		s.handleMetadataLocked(p, m)
+	}
+	return nil
+}
+
+// requestMetadataLocked asks p for the metadata pieces nobody is fetching
+func (s *swarm) requestMetadataLocked(p *peer) {
+	if s.info != nil || p.ext.UTMetadata == 0 || p.ext.MetadataSize == 0 {
+		return
+	}
+	if s.meta == nil {
+		s.meta = newMetadataFetch(p.ext.MetadataSize)
+	}
+	if int64(len(s.meta.data)) != p.ext.MetadataSize {
+		return // Peer disagrees about the size; it cannot have the right metadata
+	}
+	now := time.Now()
+	for i := range s.meta.got {
+		if !s.meta.got[i] && now.Sub(s.meta.requested[i]) > metadataRetry {
+			s.meta.requested[i] = now
+			p.send(outgoing{data: encodeMetadataMessage(p.ext.UTMetadata, metadataRequest, i, nil, 0)})
+		}
+	}
+}
+
+func (s *swarm) handleMetadataLocked(p *peer, m metadataMessage) {
+	switch m.Type {
+	case metadataRequest:
+		if p.ext.UTMetadata == 0 {
+			return
+		}
+		if s.info == nil || m.Piece*metadataPiece >= len(s.infoBytes) {
+			p.send(outgoing{data: encodeMetadataMessage(p.ext.UTMetadata, metadataReject, m.Piece, nil, 0)})
+			return
+		}
+		start := m.Piece * metadataPiece
+		end := min(start+metadataPiece, len(s.infoBytes))
+		p.send(outgoing{data: encodeMetadataMessage(p.ext.UTMetadata, metadataData, m.Piece, s.infoBytes[start:end], len(s.infoBytes))})
+
+	case metadataData:
+		mf := s.meta
+		if s.info != nil || mf == nil || m.Piece >= len(mf.got) || mf.got[m.Piece] {
+			return
+		}
+		start := m.Piece * metadataPiece
+		if len(m.Data) != min(metadataPiece, len(mf.data)-start) {
+			return
+		}
+		copy(mf.data[start:], m.Data)
+		mf.got[m.Piece] = true
+		if mf.remaining--; mf.remaining > 0 {
+			return
+		}
+		if sha1.Sum(mf.data) != s.infoHash {
+			utils.Debug("Torrent: metadata from peers does not match the info hash, refetching")
+			s.meta = nil
+			return
+		}
+		info, err := parseInfo(mf.data)
+		if err != nil {
+			utils.Debug("Torrent: invalid metadata: %v", err)
+			s.meta = nil
+			return
+		}
+		s.setInfoLocked(info, mf.data)
+
+	case metadataReject:
+		if s.meta != nil && m.Piece < len(s.meta.requested) {
+			s.meta.requested[m.Piece] = time.Time{}
+		}
+	}
+}
+
+// maintenanceLoop connects to new peers, retries metadata requests and drops peers
+// that stopped sending
+func (s *swarm) maintenanceLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		s.maintain()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *swarm) maintain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	complete := s.isCompleteLocked()
+	now := time.Now()
+	for p := range s.peers {
+		s.requestMetadataLocked(p)
+		if len(p.pending) > 0 && now.Sub(p.lastBlock) > peerSnubTimeout {
+			utils.Debug("Torrent: peer %s stopped sending", p.addr)
+			p.close()
+		}
+		// Two seeds have nothing to exchange
+		if complete && s.info != nil && p.count == s.info.NumPieces() {
+			p.close()
+		}
+	}
+
+	// Seeds only wait for peers to come to them
+	if complete {
+		return
+	}
+	for len(s.candidates) > 0 && len(s.peers)+s.dialing < maxPeersPerTorrent {
+		addr := s.candidates[0]
+		s.candidates = s.candidates[1:]
+		if s.addrs[addr] || s.banned[hostOf(addr)] {
+			continue
+		}
+		s.addrs[addr] = true
+		s.dialing++
+		s.wg.Add(1)
+		go s.dial(addr)
+	}
+}
+
+// addCandidates queues peer addresses from a tracker
+func (s *swarm) addCandidates(addrs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, addr := range addrs {
+		if !s.addrs[addr] && len(s.candidates) < 4*maxPeersPerTorrent {
+			s.candidates = append(s.candidates, addr)
+		}
+	}
+}
+
+func (s *swarm) dial(addr string) {
+	defer s.wg.Done()
+	conn, err := s.connect(addr)
+
+	s.mu.Lock()
+	s.dialing--
+	if err != nil {
+		delete(s.addrs, addr) // May be retried when a tracker lists it again
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		utils.Debug("Torrent: could not connect to %s: %v", addr, err)
+		return
+	}
+	s.addPeer(conn.conn, conn.handshake)
+}
+
+type dialedConn struct {
+	conn      net.Conn
+	handshake handshake
+}
+
+func (s *swarm) connect(addr string) (*dialedConn, error) {
+	dialer := &net.Dialer{Timeout: types.DialTimeout}
+	conn, err := dialer.DialContext(s.ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := writeHandshake(conn, handshake{InfoHash: s.infoHash, PeerID: s.session.peerID}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	h, err := readHandshake(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if h.InfoHash != s.infoHash {
+		_ = conn.Close()
+		return nil, errors.New("peer answered for another torrent")
+	}
+	if h.PeerID == s.session.peerID {
+		_ = conn.Close()
+		return nil, errors.New("connected to ourselves")
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &dialedConn{conn: conn, handshake: h}, nil
+}
+
+// announceParams reports current progress; left is a placeholder until the size
+// is known so trackers do not treat us as a seed
+func (s *swarm) announceParams(event string) announceParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	left := int64(1 << 20)
+	if s.info != nil {
+		left = s.info.Length - s.haveBytes
+	}
+	return announceParams{
+		InfoHash:   s.infoHash,
+		PeerID:     s.session.peerID,
+		Port:       s.session.announcePort(),
+		Uploaded:   s.uploaded.Load(),
+		Downloaded: s.haveBytes,
+		Left:       left,
+		Event:      event,
+	}
+}
+
+// trackerLoop announces to one tracker until the swarm closes
+func (s *swarm) trackerLoop(tracker string) {
+	defer s.wg.Done()
+
+	event := "started"
+	completed := s.completed
+	backoff := trackerRetryBase
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, types.ProbeTimeout)
+		res, err := announce(ctx, s.session.tracker, tracker, s.announceParams(event))
+		cancel()
+
+		wait := backoff
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			utils.Debug("Torrent: announce to %s failed: %v", tracker, err)
+			backoff = min(2*backoff, defaultAnnounceInterval)
+		} else {
+			event = ""
+			backoff = trackerRetryBase
+			wait = max(res.Interval, minAnnounceInterval)
+			s.addCandidates(res.Peers)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-completed:
+			completed = nil
+			event = "completed"
+		case <-time.After(wait):
+		}
+	}
+}
+
+// announceAll sends a final event to every tracker, bounded by a short timeout
+func (s *swarm) announceAll(event string) {
+	ctx, cancel := context.WithTimeout(context.Background(), stoppedTimeout)
+	defer cancel()
+	params := s.announceParams(event)
+	var wg sync.WaitGroup
+	for _, tr := range s.trackers {
+		wg.Add(1)
+		go func(tr string) {
+			defer wg.Done()
+			_, _ = announce(ctx, s.session.tracker, tr, params)
+		}(tr)
+	}
+	wg.Wait()
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
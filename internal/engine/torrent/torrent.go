@@ -0,0 +1,128 @@
+package torrent
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/utils"
+)
+
+// metadataTimeout bounds how long a magnet link may wait for peers to send its metadata
+const metadataTimeout = 2 * time.Minute
+
+// Torrent is an open torrent whose metadata is known. Close it when done unless it
+// was handed over to seeding.
+type Torrent struct {
+	Magnet *Magnet
+	s      *swarm
+	kept   bool // Seeding: the swarm outlives this handle
+}
+
+// Info returns the torrent's metadata
+func (t *Torrent) Info() *Info {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	return t.s.info
+}
+
+// Close disconnects from the swarm unless the torrent is seeding
+func (t *Torrent) Close() {
+	if !t.kept {
+		t.s.close()
+	}
+}
+
+// Import caches the metadata of a .torrent file in the state database and returns
+// the magnet link to queue it by; opening the link then needs no peer for metadata
+func Import(data []byte) (string, error) {
+	meta, err := ParseMetaInfo(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent file: %w", err)
+	}
+	if err := state.SaveTorrentInfo(hex.EncodeToString(meta.InfoHash[:]), meta.InfoBytes); err != nil {
+		return "", err
+	}
+	return meta.Magnet(), nil
+}
+
+// Open joins the swarm of a magnet link in the default session and waits for its
+// metadata, taken from the state database when the torrent was seen before
+func Open(ctx context.Context, rawurl string, runtime *types.RuntimeConfig) (*Torrent, error) {
+	m, err := ParseMagnet(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	s := DefaultSession(runtime)
+	if s == nil {
+		return nil, errors.New("failed to start torrent session")
+	}
+	if runtime != nil {
+		s.Upload.SetLimit(runtime.TorrentUploadLimit)
+	}
+
+	infoBytes, err := state.LoadTorrentInfo(m.HexHash())
+	if err != nil {
+		utils.Debug("Torrent: no cached metadata: %v", err)
+	}
+	t, err := s.Open(ctx, m, infoBytes)
+	if err != nil {
+		return nil, err
+	}
+	if infoBytes == nil {
+		t.s.mu.Lock()
+		infoBytes = t.s.infoBytes
+		t.s.mu.Unlock()
+		if err := state.SaveTorrentInfo(m.HexHash(), infoBytes); err != nil {
+			utils.Debug("Torrent: failed to cache metadata: %v", err)
+		}
+	}
+	return t, nil
+}
+
+// Open joins the swarm of m. infoBytes is the bencoded info dictionary if known;
+// otherwise it is fetched from peers.
+func (s *Session) Open(ctx context.Context, m *Magnet, infoBytes []byte) (*Torrent, error) {
+	var info *Info
+	if infoBytes != nil {
+		if sha1.Sum(infoBytes) != m.InfoHash {
+			return nil, errors.New("cached torrent metadata does not match the info hash")
+		}
+		var err error
+		if info, err = parseInfo(infoBytes); err != nil {
+			return nil, err
+		}
+	} else if len(m.Trackers) == 0 && len(m.Peers) == 0 {
+		return nil, errors.New("magnet link has no trackers or peers to fetch metadata from (DHT is not supported)")
+	}
+
+	sw := newSwarm(s, m.InfoHash, m.Trackers, m.Peers)
+	if info != nil {
+		sw.mu.Lock()
+		sw.setInfoLocked(info, infoBytes)
+		sw.mu.Unlock()
+	}
+	if err := s.register(sw); err != nil {
+		return nil, err
+	}
+	sw.run()
+	t := &Torrent{Magnet: m, s: sw}
+
+	waitCtx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	select {
+	case <-sw.metaReady:
+		return t, nil
+	case <-waitCtx.Done():
+		t.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("no peer sent the torrent metadata within %v", metadataTimeout)
+	}
+}
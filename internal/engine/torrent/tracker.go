@@ -0,0 +1,242 @@
+package torrent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAnnounceInterval = 30 * time.Minute
+	minAnnounceInterval     = 30 * time.Second
+	maxTrackerResponse      = 1 << 20
+	udpTrackerTimeout       = 5 * time.Second
+	udpTrackerAttempts      = 3
+	udpProtocolID           = 0x41727101980
+	announceNumWant         = 50
+)
+
+// announceParams describes this client's state in an announce
+type announceParams struct {
+	InfoHash   [sha1.Size]byte
+	PeerID     [sha1.Size]byte
+	Port       int
+	Uploaded   int64
+	Downloaded int64
+	Left       int64
+	Event      string // "started", "completed", "stopped" or "" for a regular update
+}
+
+// announceResult is a tracker's answer: where peers are and when to ask again
+type announceResult struct {
+	Interval time.Duration
+	Peers    []string // host:port
+}
+
+// announce reports to an http(s):// or udp:// tracker and returns its peer list
+func announce(ctx context.Context, client *http.Client, tracker string, p announceParams) (*announceResult, error) {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracker URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return announceHTTP(ctx, client, u, p)
+	case "udp":
+		return announceUDP(ctx, u.Host, p)
+	default:
+		return nil, fmt.Errorf("unsupported tracker scheme %q", u.Scheme)
+	}
+}
+
+func announceHTTP(ctx context.Context, client *http.Client, u *url.URL, p announceParams) (*announceResult, error) {
+	query := []string{
+		"info_hash=" + url.QueryEscape(string(p.InfoHash[:])),
+		"peer_id=" + url.QueryEscape(string(p.PeerID[:])),
+		"port=" + strconv.Itoa(p.Port),
+		"uploaded=" + strconv.FormatInt(p.Uploaded, 10),
+		"downloaded=" + strconv.FormatInt(p.Downloaded, 10),
+		"left=" + strconv.FormatInt(p.Left, 10),
+		"compact=1",
+		"numwant=" + strconv.Itoa(announceNumWant),
+	}
+	if p.Event != "" {
+		query = append(query, "event="+p.Event)
+	}
+	target := *u
+	if target.RawQuery != "" {
+		target.RawQuery += "&"
+	}
+	target.RawQuery += strings.Join(query, "&")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tracker request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tracker returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTrackerResponse))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tracker response: %w", err)
+	}
+	return parseHTTPAnnounce(body)
+}
+
+// parseHTTPAnnounce decodes compact (BEP 23) and dictionary peer lists
+func parseHTTPAnnounce(body []byte) (*announceResult, error) {
+	v, err := decodeBencode(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracker response: %w", err)
+	}
+	dict, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid tracker response")
+	}
+	if reason, ok := dictString(dict, "failure reason"); ok {
+		return nil, fmt.Errorf("tracker error: %s", reason)
+	}
+
+	result := &announceResult{Interval: defaultAnnounceInterval}
+	if interval, ok := dictInt(dict, "interval"); ok && interval > 0 {
+		result.Interval = time.Duration(interval) * time.Second
+	}
+	switch peers := dict["peers"].(type) {
+	case string:
+		result.Peers = append(result.Peers, compactPeers([]byte(peers), net.IPv4len)...)
+	case []any:
+		for _, entry := range peers {
+			pd, ok := entry.(map[string]any)
+			if !ok {
+				continue
+			}
+			ip, _ := dictString(pd, "ip")
+			port, _ := dictInt(pd, "port")
+			if ip != "" && port > 0 && port < 65536 {
+				result.Peers = append(result.Peers, net.JoinHostPort(ip, strconv.FormatInt(port, 10)))
+			}
+		}
+	}
+	if peers6, ok := dictString(dict, "peers6"); ok {
+		result.Peers = append(result.Peers, compactPeers([]byte(peers6), net.IPv6len)...)
+	}
+	return result, nil
+}
+
+// compactPeers decodes addresses packed as IP bytes followed by a big-endian port
+func compactPeers(data []byte, ipLen int) []string {
+	size := ipLen + 2
+	var peers []string
+	for i := 0; i+size <= len(data); i += size {
+		ip := net.IP(data[i : i+ipLen])
+		port := binary.BigEndian.Uint16(data[i+ipLen:])
+		if port == 0 {
+			continue
+		}
+		peers = append(peers, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+	}
+	return peers
+}
+
+// udpEvents maps announce events to their BEP 15 codes
+var udpEvents = map[string]uint32{"": 0, "completed": 1, "started": 2, "stopped": 3}
+
+func announceUDP(ctx context.Context, host string, p announceParams) (*announceResult, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", host)
+	if err != nil {
+		return nil, fmt.Errorf("tracker request failed: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	connect := make([]byte, 16)
+	binary.BigEndian.PutUint64(connect[0:], udpProtocolID)
+	binary.BigEndian.PutUint32(connect[8:], 0) // action: connect
+	resp, err := udpRoundTrip(ctx, conn, connect, 16)
+	if err != nil {
+		return nil, err
+	}
+	connectionID := binary.BigEndian.Uint64(resp[8:])
+
+	req := make([]byte, 98)
+	binary.BigEndian.PutUint64(req[0:], connectionID)
+	binary.BigEndian.PutUint32(req[8:], 1) // action: announce
+	copy(req[16:], p.InfoHash[:])
+	copy(req[36:], p.PeerID[:])
+	binary.BigEndian.PutUint64(req[56:], uint64(p.Downloaded))
+	binary.BigEndian.PutUint64(req[64:], uint64(p.Left))
+	binary.BigEndian.PutUint64(req[72:], uint64(p.Uploaded))
+	binary.BigEndian.PutUint32(req[80:], udpEvents[p.Event])
+	binary.BigEndian.PutUint32(req[92:], uint32(announceNumWant))
+	binary.BigEndian.PutUint16(req[96:], uint16(p.Port))
+	resp, err = udpRoundTrip(ctx, conn, req, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &announceResult{Interval: time.Duration(binary.BigEndian.Uint32(resp[8:])) * time.Second}
+	if result.Interval <= 0 {
+		result.Interval = defaultAnnounceInterval
+	}
+	ipLen := net.IPv4len
+	if remote, ok := conn.RemoteAddr().(*net.UDPAddr); ok && remote.IP.To4() == nil {
+		ipLen = net.IPv6len
+	}
+	result.Peers = compactPeers(resp[20:], ipLen)
+	return result, nil
+}
+
+// udpRoundTrip sends req with a fresh transaction ID and waits for the matching
+// reply, retrying on timeouts
+func udpRoundTrip(ctx context.Context, conn net.Conn, req []byte, minLen int) ([]byte, error) {
+	action := binary.BigEndian.Uint32(req[8:])
+	buf := make([]byte, 2048)
+	var lastErr error
+	for attempt := 0; attempt < udpTrackerAttempts; attempt++ {
+		var txid [4]byte
+		if _, err := rand.Read(txid[:]); err != nil {
+			return nil, err
+		}
+		copy(req[12:16], txid[:])
+		if _, err := conn.Write(req); err != nil {
+			return nil, fmt.Errorf("tracker request failed: %w", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(udpTrackerTimeout))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				lastErr = err
+				break
+			}
+			if n < 8 || string(buf[4:8]) != string(txid[:]) {
+				continue // Stale reply to an earlier attempt
+			}
+			switch got := binary.BigEndian.Uint32(buf); {
+			case got == 3:
+				return nil, fmt.Errorf("tracker error: %s", buf[8:n])
+			case got != action || n < minLen:
+				return nil, fmt.Errorf("invalid tracker response")
+			}
+			return append([]byte(nil), buf[:n]...), nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("tracker did not respond: %w", lastErr)
+}
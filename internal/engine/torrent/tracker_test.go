@@ -0,0 +1,194 @@
+package torrent
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTracker is an HTTP tracker that hands every announcing client the list of
+// peers seen so far for the same torrent, all on the loopback address
+type testTracker struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	peers  map[string][]int // info_hash -> ports
+	events []string
+}
+
+func newTestTracker(t *testing.T) *testTracker {
+	t.Helper()
+	tr := &testTracker{peers: make(map[string][]int)}
+	tr.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		port, err := strconv.Atoi(q.Get("port"))
+		if err != nil || len(q.Get("info_hash")) != sha1.Size || q.Get("compact") != "1" {
+			_, _ = w.Write(encodeBencode(map[string]any{"failure reason": "bad request"}))
+			return
+		}
+
+		tr.mu.Lock()
+		hash := q.Get("info_hash")
+		tr.events = append(tr.events, q.Get("event"))
+		known := false
+		var compact []byte
+		for _, p := range tr.peers[hash] {
+			known = known || p == port
+			compact = append(compact, 127, 0, 0, 1, byte(p>>8), byte(p))
+		}
+		if !known && q.Get("event") != "stopped" {
+			tr.peers[hash] = append(tr.peers[hash], port)
+		}
+		tr.mu.Unlock()
+
+		_, _ = w.Write(encodeBencode(map[string]any{"interval": 1, "peers": compact}))
+	}))
+	t.Cleanup(tr.Close)
+	return tr
+}
+
+func (tr *testTracker) announceURL() string {
+	return tr.URL + "/announce"
+}
+
+func (tr *testTracker) sawEvent(event string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for _, e := range tr.events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAnnounceHTTP(t *testing.T) {
+	tr := newTestTracker(t)
+	p := announceParams{InfoHash: sha1.Sum([]byte("x")), Port: 7000, Left: 10, Event: "started"}
+	ctx := context.Background()
+
+	if _, err := announce(ctx, http.DefaultClient, tr.announceURL(), p); err != nil {
+		t.Fatalf("First announce failed: %v", err)
+	}
+	p.Port = 7001
+	res, err := announce(ctx, http.DefaultClient, tr.announceURL(), p)
+	if err != nil {
+		t.Fatalf("Second announce failed: %v", err)
+	}
+	if want := []string{"127.0.0.1:7000"}; !reflect.DeepEqual(res.Peers, want) {
+		t.Errorf("Peers = %v, want %v", res.Peers, want)
+	}
+	if res.Interval != time.Second {
+		t.Errorf("Interval = %v, want 1s", res.Interval)
+	}
+}
+
+func TestParseHTTPAnnounce(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Dictionary peers",
+			body: encodeBencode(map[string]any{"peers": []any{
+				map[string]any{"ip": "10.0.0.1", "port": 6881},
+				map[string]any{"ip": "::1", "port": 51413},
+			}}),
+			want: []string{"10.0.0.1:6881", "[::1]:51413"},
+		},
+		{
+			name: "Compact IPv6 peers",
+			body: encodeBencode(map[string]any{"peers": "", "peers6": string(append(net.ParseIP("2001:db8::1").To16(), 0x1a, 0xe1))}),
+			want: []string{"[2001:db8::1]:6881"},
+		},
+		{
+			name:    "Failure reason",
+			body:    encodeBencode(map[string]any{"failure reason": "unregistered torrent"}),
+			wantErr: true,
+		},
+		{name: "Garbage", body: []byte("<html>"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseHTTPAnnounce(tt.body)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("parseHTTPAnnounce succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseHTTPAnnounce failed: %v", err)
+			}
+			if !reflect.DeepEqual(res.Peers, tt.want) {
+				t.Errorf("Peers = %v, want %v", res.Peers, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnounceUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	const connectionID = 0x1122334455667788
+	received := make(chan announceParams, 1)
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			action := binary.BigEndian.Uint32(buf[8:])
+			reply := make([]byte, 8, 26)
+			binary.BigEndian.PutUint32(reply, action)
+			copy(reply[4:], buf[12:16]) // Transaction ID
+			switch {
+			case action == 0 && n == 16 && binary.BigEndian.Uint64(buf) == udpProtocolID:
+				reply = binary.BigEndian.AppendUint64(reply, connectionID)
+			case action == 1 && n >= 98 && binary.BigEndian.Uint64(buf) == connectionID:
+				var got announceParams
+				copy(got.InfoHash[:], buf[16:36])
+				got.Left = int64(binary.BigEndian.Uint64(buf[64:]))
+				got.Port = int(binary.BigEndian.Uint16(buf[96:]))
+				received <- got
+				reply = binary.BigEndian.AppendUint32(reply, 120) // Interval
+				reply = binary.BigEndian.AppendUint32(reply, 0)   // Leechers
+				reply = binary.BigEndian.AppendUint32(reply, 1)   // Seeders
+				reply = append(reply, 192, 168, 1, 2, 0x1a, 0xe1)
+			default:
+				continue
+			}
+			_, _ = conn.WriteTo(reply, addr)
+		}
+	}()
+
+	p := announceParams{InfoHash: sha1.Sum([]byte("udp")), Port: 6881, Left: 1234}
+	res, err := announce(context.Background(), nil, "udp://"+conn.LocalAddr().String()+"/announce", p)
+	if err != nil {
+		t.Fatalf("announce failed: %v", err)
+	}
+	if want := []string{"192.168.1.2:6881"}; !reflect.DeepEqual(res.Peers, want) {
+		t.Errorf("Peers = %v, want %v", res.Peers, want)
+	}
+	if res.Interval != 2*time.Minute {
+		t.Errorf("Interval = %v, want 2m", res.Interval)
+	}
+	if got := <-received; got.InfoHash != p.InfoHash || got.Left != 1234 || got.Port != 6881 {
+		t.Errorf("Tracker received %+v", got)
+	}
+}
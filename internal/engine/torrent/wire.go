@@ -0,0 +1,240 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const protocolName = "BitTorrent protocol"
+
+// Peer wire message IDs (BEP 3 and BEP 10)
+const (
+	msgChoke         byte = 0
+	msgUnchoke       byte = 1
+	msgInterested    byte = 2
+	msgNotInterested byte = 3
+	msgHave          byte = 4
+	msgBitfield      byte = 5
+	msgRequest       byte = 6
+	msgPiece         byte = 7
+	msgCancel        byte = 8
+	msgExtended      byte = 20
+)
+
+const (
+	blockSize      = 16 * 1024 // Request size every client accepts
+	maxMessageSize = 1 << 21   // Largest message accepted from a peer
+	metadataPiece  = 16 * 1024 // ut_metadata piece size (BEP 9)
+	maxMetadata    = 16 << 20  // Largest info dictionary fetched from peers
+)
+
+// ID this client assigns to ut_metadata in its extension handshake
+const utMetadataID = 1
+
+// handshake is the first message on every peer connection
+type handshake struct {
+	InfoHash   [sha1.Size]byte
+	PeerID     [sha1.Size]byte
+	Extensions bool // Peer supports the extension protocol (BEP 10)
+}
+
+func writeHandshake(w io.Writer, h handshake) error {
+	buf := make([]byte, 0, 68)
+	buf = append(buf, byte(len(protocolName)))
+	buf = append(buf, protocolName...)
+	reserved := make([]byte, 8)
+	reserved[5] |= 0x10 // Extension protocol
+	buf = append(buf, reserved...)
+	buf = append(buf, h.InfoHash[:]...)
+	buf = append(buf, h.PeerID[:]...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readHandshake(r io.Reader) (handshake, error) {
+	var h handshake
+	buf := make([]byte, 68)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return h, err
+	}
+	if int(buf[0]) != len(protocolName) || string(buf[1:20]) != protocolName {
+		return h, fmt.Errorf("not a BitTorrent handshake")
+	}
+	h.Extensions = buf[25]&0x10 != 0
+	copy(h.InfoHash[:], buf[28:48])
+	copy(h.PeerID[:], buf[48:68])
+	return h, nil
+}
+
+// message is a peer wire message; a nil *message is a keep-alive
+type message struct {
+	ID      byte
+	Payload []byte
+}
+
+func readMessage(r io.Reader) (*message, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("peer message of %d bytes is too large", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return &message{ID: buf[0], Payload: buf[1:]}, nil
+}
+
+func encodeMessage(id byte, payload ...[]byte) []byte {
+	length := 1
+	for _, p := range payload {
+		length += len(p)
+	}
+	buf := make([]byte, 4, 4+length)
+	binary.BigEndian.PutUint32(buf, uint32(length))
+	buf = append(buf, id)
+	for _, p := range payload {
+		buf = append(buf, p...)
+	}
+	return buf
+}
+
+func uint32s(values ...uint32) []byte {
+	buf := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(buf[4*i:], v)
+	}
+	return buf
+}
+
+// parseBlock decodes the index, begin and length of request and cancel messages
+func parseBlock(payload []byte) (index, begin, length int, err error) {
+	if len(payload) != 12 {
+		return 0, 0, 0, fmt.Errorf("malformed block message")
+	}
+	return int(binary.BigEndian.Uint32(payload)), int(binary.BigEndian.Uint32(payload[4:])), int(binary.BigEndian.Uint32(payload[8:])), nil
+}
+
+// bitfield records which pieces a peer (or this client) has, high bit first
+type bitfield []byte
+
+func newBitfield(n int) bitfield {
+	return make(bitfield, (n+7)/8)
+}
+
+func (b bitfield) has(i int) bool {
+	if i < 0 || i/8 >= len(b) {
+		return false
+	}
+	return b[i/8]&(0x80>>(i%8)) != 0
+}
+
+func (b bitfield) set(i int) {
+	if i >= 0 && i/8 < len(b) {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+}
+
+func (b bitfield) unset(i int) {
+	if i >= 0 && i/8 < len(b) {
+		b[i/8] &^= 0x80 >> (i % 8)
+	}
+}
+
+func (b bitfield) count(n int) int {
+	c := 0
+	for i := 0; i < n; i++ {
+		if b.has(i) {
+			c++
+		}
+	}
+	return c
+}
+
+// extensionHandshake is the BEP 10 handshake payload
+type extensionHandshake struct {
+	UTMetadata   int   // Peer's message ID for ut_metadata (0 = unsupported)
+	MetadataSize int64 // Size of the info dictionary, if the peer has it
+}
+
+func encodeExtensionHandshake(metadataSize int, port int) []byte {
+	dict := map[string]any{
+		"m": map[string]any{"ut_metadata": utMetadataID},
+		"v": "Surge",
+	}
+	if metadataSize > 0 {
+		dict["metadata_size"] = metadataSize
+	}
+	if port > 0 {
+		dict["p"] = port
+	}
+	return encodeMessage(msgExtended, []byte{0}, encodeBencode(dict))
+}
+
+func parseExtensionHandshake(payload []byte) (extensionHandshake, error) {
+	var h extensionHandshake
+	v, err := decodeBencode(payload)
+	if err != nil {
+		return h, err
+	}
+	dict, ok := v.(map[string]any)
+	if !ok {
+		return h, fmt.Errorf("malformed extension handshake")
+	}
+	if m, ok := dictDict(dict, "m"); ok {
+		if id, ok := dictInt(m, "ut_metadata"); ok && id > 0 && id < 256 {
+			h.UTMetadata = int(id)
+		}
+	}
+	if size, ok := dictInt(dict, "metadata_size"); ok && size > 0 && size <= maxMetadata {
+		h.MetadataSize = size
+	}
+	return h, nil
+}
+
+// ut_metadata message types (BEP 9)
+const (
+	metadataRequest = 0
+	metadataData    = 1
+	metadataReject  = 2
+)
+
+func encodeMetadataMessage(peerID int, msgType, piece int, data []byte, totalSize int) []byte {
+	dict := map[string]any{"msg_type": msgType, "piece": piece}
+	if msgType == metadataData {
+		dict["total_size"] = totalSize
+	}
+	return encodeMessage(msgExtended, []byte{byte(peerID)}, encodeBencode(dict), data)
+}
+
+// metadataMessage is a decoded ut_metadata message
+type metadataMessage struct {
+	Type      int
+	Piece     int
+	TotalSize int64
+	Data      []byte
+}
+
+func parseMetadataMessage(payload []byte) (metadataMessage, error) {
+	var m metadataMessage
+	dict, rest, err := decodeDictPrefix(payload)
+	if err != nil {
+		return m, err
+	}
+	msgType, ok1 := dictInt(dict, "msg_type")
+	piece, ok2 := dictInt(dict, "piece")
+	if !ok1 || !ok2 || piece < 0 || piece > maxMetadata/metadataPiece {
+		return m, fmt.Errorf("malformed metadata message")
+	}
+	m.Type, m.Piece, m.Data = int(msgType), int(piece), bytes.Clone(rest)
+	m.TotalSize, _ = dictInt(dict, "total_size")
+	return m, nil
+}
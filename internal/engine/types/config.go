@@ -2,6 +2,8 @@ package types
 
 import (
 	"time"
+
+	"github.com/surge-downloader/surge/internal/config"
 )
 
 // Size constants
@@ -41,6 +43,9 @@ const (
 	ProbeTimeout                 = 30 * time.Second
 )
 
+// DefaultTorrentListenPort is the conventional BitTorrent port
+const DefaultTorrentListenPort = 6881
+
 // Channel buffer sizes
 const (
 	ProgressChannelBuffer = 100
@@ -61,6 +66,34 @@ type DownloadConfig struct {
 	Runtime    *RuntimeConfig    // Dynamic settings from user config
 	Mirrors    []string          // List of mirror URLs (including primary)
 	Headers    map[string]string // Custom HTTP headers from browser (cookies, auth, etc.)
+	Checksum   string            // Expected digest ("sha256:<hex>"), verified on completion
+	StartAt    time.Time         // Hold the download in the queue until this time (zero = immediately)
+	Window     string            // Daily active window ("HH:MM-HH:MM"); empty = no restriction
+	Extract    string            // Extract mode on completion ("keep", "delete", "off"); empty = global setting
+	Variant    string            // HLS/DASH variant selector ("best", "worst", "720p"); empty = best
+	Priority   int               // Queue priority; higher runs first (0 = normal)
+	Category   string            // Download category from settings; empty = none or not matched yet
+	DefaultDir bool              // OutputPath is the default directory, which a category may replace
+
+	ExpectedSize int64 // Size announced by the source (e.g. a Metalink); a different server size fails the download
+}
+
+// DownloadOptions holds optional per-download settings supplied when a download is added
+type DownloadOptions struct {
+	Checksum   string    // Expected digest ("sha256:<hex>", "sha512:<hex>", "sha1:<hex>" or "md5:<hex>")
+	StartAt    time.Time // Earliest time the download may start (zero = immediately)
+	Window     string    // Daily active window ("HH:MM-HH:MM"); empty = no restriction
+	SpeedLimit int64     // Per-download bandwidth cap in bytes per second (0 = unlimited)
+	Extract    string    // Extract mode on completion ("keep", "delete", "off"); empty = global setting
+	Variant    string    // HLS/DASH variant selector ("best", "worst", "720p"); empty = best
+	Priority   int       // Queue priority; higher runs first (0 = normal)
+	Category   string    // Category from settings; empty = match by extension, MIME type and host
+	DefaultDir bool      // The path is the default directory, which the category's directory replaces
+	Cookies    string    // Netscape cookies.txt content imported into the shared cookie jar
+
+	Headers map[string]string // Request headers from the command line (--header, --user), passed to Add as its headers
+
+	ExpectedSize int64 // Size announced by the source (e.g. a Metalink); 0 = unknown
 }
 
 // RuntimeConfig holds dynamic settings that can override defaults
@@ -77,6 +110,20 @@ type RuntimeConfig struct {
 	SlowWorkerGracePeriod time.Duration
 	StallTimeout          time.Duration
 	SpeedEmaAlpha         float64
+
+	SSHKeyFile        string // Private key for sftp:// URLs (empty = default identity files)
+	SSHKnownHostsFile string // Host keys trusted for sftp:// URLs (empty = ~/.ssh/known_hosts)
+
+	TorrentListenPort  int           // Port accepting BitTorrent peers (0 = DefaultTorrentListenPort)
+	SeedRatio          float64       // Seed completed torrents until uploaded/size reaches this (0 = don't seed)
+	SeedTimeLimit      time.Duration // Stop seeding after this long regardless of ratio (0 = no limit)
+	TorrentUploadLimit int64         // Upload cap for all torrents in bytes per second (0 = unlimited)
+
+	PreallocateFiles bool  // Reserve the whole file on disk before writing (fallocate)
+	MinFreeSpace     int64 // Bytes to keep free; downloads pause below this (0 = don't watch)
+
+	Categories   []config.CategorySettings // Download categories, matched in order
+	HostPolicies []config.HostPolicy       // Per-host connection rules, matched in order
 }
 
 // GetUserAgent returns the configured user agent or the default
@@ -87,6 +134,25 @@ func (r *RuntimeConfig) GetUserAgent() string {
 	return r.UserAgent
 }
 
+// GetTorrentListenPort returns configured value or default
+func (r *RuntimeConfig) GetTorrentListenPort() int {
+	if r == nil || r.TorrentListenPort <= 0 || r.TorrentListenPort > 65535 {
+		return DefaultTorrentListenPort
+	}
+	return r.TorrentListenPort
+}
+
+// GetHostConnLimit returns the connections the URL's host allows across all downloads (0 = no shared cap)
+func (r *RuntimeConfig) GetHostConnLimit(rawURL string) int {
+	if r == nil {
+		return 0
+	}
+	if p := config.MatchHostPolicy(r.HostPolicies, rawURL); p != nil {
+		return p.MaxConnections
+	}
+	return 0
+}
+
 // GetMaxConnectionsPerHost returns configured value or default
 func (r *RuntimeConfig) GetMaxConnectionsPerHost() int {
 	if r == nil || r.MaxConnectionsPerHost <= 0 {
@@ -103,6 +169,14 @@ func (r *RuntimeConfig) GetMinChunkSize() int64 {
 	return r.MinChunkSize
 }
 
+// GetMinFreeSpace returns the bytes to keep free, or 0 when disk space is not watched
+func (r *RuntimeConfig) GetMinFreeSpace() int64 {
+	if r == nil || r.MinFreeSpace < 0 {
+		return 0
+	}
+	return r.MinFreeSpace
+}
+
 // GetWorkerBufferSize returns configured value or default
 func (r *RuntimeConfig) GetWorkerBufferSize() int {
 	if r == nil || r.WorkerBufferSize <= 0 {
@@ -121,6 +195,14 @@ const (
 	SlowWorkerGrace     = 5 * time.Second // Grace period before checking speed
 	StallTimeout        = 5 * time.Second // Restart if no data for x seconds
 	SpeedEMAAlpha       = 0.3             // EMA smoothing factor
+
+	// Mirror weighting constants
+	SlowMirrorThreshold = 0.25 // A mirror is slow if its per-connection speed < x times the fastest mirror's
+	MirrorDemoteStrikes = 5    // Consecutive slow health checks before a mirror is demoted
+
+	// Rate limit constants (429/503 handling)
+	RateLimitBaseDelay = 1 * time.Second // Cooldown when the server sends no Retry-After (doubles per strike)
+	MaxRateLimitDelay  = 5 * time.Minute // Upper bound on a single host cooldown
 )
 
 // GetMaxTaskRetries returns configured value or default
@@ -16,5 +16,57 @@ func ConvertRuntimeConfig(rc *config.RuntimeConfig) *RuntimeConfig {
 		SlowWorkerGracePeriod: rc.SlowWorkerGracePeriod,
 		StallTimeout:          rc.StallTimeout,
 		SpeedEmaAlpha:         rc.SpeedEmaAlpha,
+		SSHKeyFile:            rc.SSHKeyFile,
+		SSHKnownHostsFile:     rc.SSHKnownHostsFile,
+		TorrentListenPort:     rc.TorrentListenPort,
+		SeedRatio:             rc.SeedRatio,
+		SeedTimeLimit:         rc.SeedTimeLimit,
+		TorrentUploadLimit:    rc.TorrentUploadLimit,
+		PreallocateFiles:      rc.PreallocateFiles,
+		MinFreeSpace:          rc.MinFreeSpace,
+		Categories:            rc.Categories,
+		HostPolicies:          rc.HostPolicies,
 	}
 }
+
+// ForCategory returns a copy of r with the connection settings of c applied
+func (r *RuntimeConfig) ForCategory(c *config.CategorySettings) *RuntimeConfig {
+	var rc RuntimeConfig
+	if r != nil {
+		rc = *r
+	}
+	if c == nil {
+		return &rc
+	}
+	if c.MaxConnectionsPerHost > 0 {
+		rc.MaxConnectionsPerHost = c.MaxConnectionsPerHost
+	}
+	if c.MinChunkSize > 0 {
+		rc.MinChunkSize = c.MinChunkSize
+	}
+	if c.SequentialDownload {
+		rc.SequentialDownload = true
+	}
+	return &rc
+}
+
+// ForHost returns a copy of r with the overrides of a host policy applied
+func (r *RuntimeConfig) ForHost(p *config.HostPolicy) *RuntimeConfig {
+	var rc RuntimeConfig
+	if r != nil {
+		rc = *r
+	}
+	if p == nil {
+		return &rc
+	}
+	if p.MaxConnections > 0 {
+		rc.MaxConnectionsPerHost = p.MaxConnections
+	}
+	if p.MinChunkSize > 0 {
+		rc.MinChunkSize = p.MinChunkSize
+	}
+	if p.UserAgent != "" {
+		rc.UserAgent = p.UserAgent
+	}
+	return &rc
+}
@@ -1,8 +1,31 @@
 package types
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // Common errors
 var (
-	ErrPaused = errors.New("download paused")
+	ErrPaused           = errors.New("download paused")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrRemoteChanged    = errors.New("remote file changed")
+	ErrSizeMismatch     = errors.New("size mismatch")
+	ErrNoSpace          = errors.New("not enough disk space")
+	ErrNotQueued        = errors.New("download is not queued")
 )
+
+// RateLimitError is returned when a server answers 429 or 503.
+// RetryAfter is zero when the server did not send a usable Retry-After header.
+type RateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited (%d), retry after %s", e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("rate limited (%d)", e.StatusCode)
+}
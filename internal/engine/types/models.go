@@ -20,6 +20,23 @@ type DownloadState struct {
 	PausedAt   int64    `json:"paused_at"`  // Unix timestamp
 	Elapsed    int64    `json:"elapsed"`    // Elapsed time in nanoseconds
 	Mirrors    []string `json:"mirrors,omitempty"`
+	Checksum   string   `json:"checksum,omitempty"` // Expected digest ("algo:hex")
+
+	// Remote validators captured when the download started (detect server-side changes on resume)
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Schedule carried across pauses and restarts
+	StartAt int64  `json:"start_at,omitempty"` // Unix timestamp
+	Window  string `json:"window,omitempty"`   // Daily active window ("HH:MM-HH:MM")
+
+	SpeedLimit int64  `json:"speed_limit,omitempty"` // Per-download cap in bytes per second
+	Extract    string `json:"extract,omitempty"`     // Extract mode on completion ("keep", "delete", "off")
+	Variant    string `json:"variant,omitempty"`     // HLS/DASH variant selector ("best", "worst", "720p")
+	Priority   int    `json:"priority,omitempty"`    // Queue priority; higher runs first
+	Category   string `json:"category,omitempty"`    // Download category from settings
+
+	ExpectedSize int64 `json:"expected_size,omitempty"` // Size announced by the source (e.g. a Metalink); 0 = unknown
 
 	// Bitmap state
 	ChunkBitmap     []byte `json:"chunk_bitmap,omitempty"`
@@ -33,12 +50,23 @@ type DownloadEntry struct {
 	URL         string   `json:"url"`
 	DestPath    string   `json:"dest_path"`
 	Filename    string   `json:"filename"`
-	Status      string   `json:"status"`       // "paused", "completed", "error"
+	Status      string   `json:"status"`       // "paused", "queued", "scheduled", "completed", "error", "verify_failed"
 	TotalSize   int64    `json:"total_size"`   // File size in bytes
 	Downloaded  int64    `json:"downloaded"`   // Bytes downloaded
 	CompletedAt int64    `json:"completed_at"` // Unix timestamp when completed
 	TimeTaken   int64    `json:"time_taken"`   // Duration in milliseconds (for completed)
 	Mirrors     []string `json:"mirrors,omitempty"`
+	Checksum    string   `json:"checksum,omitempty"`    // Expected digest ("algo:hex")
+	StartAt     int64    `json:"start_at,omitempty"`    // Unix timestamp the download is scheduled for
+	Window      string   `json:"window,omitempty"`      // Daily active window ("HH:MM-HH:MM")
+	OutputDir   string   `json:"output_dir,omitempty"`  // Target directory, for scheduled downloads that have not started yet
+	SpeedLimit  int64    `json:"speed_limit,omitempty"` // Per-download cap in bytes per second
+	Extract     string   `json:"extract,omitempty"`     // Extract mode on completion ("keep", "delete", "off")
+	Variant     string   `json:"variant,omitempty"`     // HLS/DASH variant selector ("best", "worst", "720p")
+	Priority    int      `json:"priority,omitempty"`    // Queue priority; higher runs first
+	Category    string   `json:"category,omitempty"`    // Download category from settings
+
+	ExpectedSize int64 `json:"expected_size,omitempty"` // Size announced by the source (e.g. a Metalink); 0 = unknown
 }
 
 // MasterList holds all tracked downloads
@@ -46,6 +74,12 @@ type MasterList struct {
 	Downloads []DownloadEntry `json:"downloads"`
 }
 
+// PauseReasonLowDisk marks downloads Surge paused because their disk is nearly full
+const PauseReasonLowDisk = "low disk space"
+
+// PauseReasonPreempted marks downloads Surge paused to make room for a higher-priority one
+const PauseReasonPreempted = "preempted by a higher-priority download"
+
 // DownloadStatus represents the transient status of an active download
 type DownloadStatus struct {
 	ID          string  `json:"id"`
@@ -56,9 +90,16 @@ type DownloadStatus struct {
 	Downloaded  int64   `json:"downloaded"`
 	Progress    float64 `json:"progress"` // Percentage 0-100
 	Speed       float64 `json:"speed"`    // MB/s
-	Status      string  `json:"status"`   // "queued", "paused", "downloading", "completed", "error"
+	Status      string  `json:"status"`   // "queued", "scheduled", "paused", "downloading", "completed", "error", "verify_failed"
 	Error       string  `json:"error,omitempty"`
-	ETA         int64   `json:"eta"`         // Estimated seconds remaining
-	Connections int     `json:"connections"` // Active connections
-	AddedAt     int64   `json:"added_at"`    // Unix timestamp when added
+	ETA         int64   `json:"eta"`                    // Estimated seconds remaining
+	Connections int     `json:"connections"`            // Active connections
+	AddedAt     int64   `json:"added_at"`               // Unix timestamp when added
+	ScheduledAt int64   `json:"scheduled_at,omitempty"` // Unix timestamp a scheduled download will start
+	SpeedLimit  int64   `json:"speed_limit,omitempty"`  // Per-download cap in bytes per second (0 = unlimited)
+	PauseReason string  `json:"pause_reason,omitempty"` // Why Surge paused the download itself, e.g. PauseReasonLowDisk
+	Priority    int     `json:"priority,omitempty"`     // Queue priority; higher runs first
+	Category    string  `json:"category,omitempty"`     // Download category from settings
+
+	Mirrors []MirrorStatus `json:"mirrors,omitempty"` // Per-mirror throughput while downloading
 }
@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
 	"github.com/surge-downloader/surge/internal/utils"
 )
 
@@ -28,6 +29,10 @@ type ProgressState struct {
 
 	Mirrors []MirrorStatus // Status of each mirror
 
+	RateLimitedUntil atomic.Int64 // UnixNano until which workers are backing off a rate-limited host (0 = not limited)
+
+	SpeedLimit bandwidth.Limiter // Per-download bandwidth cap (zero value = unlimited), adjustable while running
+
 	// Chunk Visualization (Bitmap)
 	// Chunk Visualization (Bitmap)
 	ChunkBitmap     []byte  // 2 bits per chunk
@@ -39,9 +44,14 @@ type ProgressState struct {
 }
 
 type MirrorStatus struct {
-	URL    string
-	Active bool
-	Error  bool
+	URL        string        `json:"url"`
+	Active     bool          `json:"active"`
+	Error      bool          `json:"error"`
+	Downloaded int64         `json:"downloaded"`        // Bytes received from this mirror in the current session
+	Speed      float64       `json:"speed"`             // Combined throughput of its connections in bytes/sec
+	Latency    time.Duration `json:"latency"`           // Smoothed time to first byte
+	Errors     int           `json:"errors"`            // Failed requests
+	Demoted    bool          `json:"demoted,omitempty"` // Consistently slow; only used once faster mirrors fail
 }
 
 func NewProgressState(id string, totalSize int64) *ProgressState {
@@ -114,6 +124,48 @@ func (ps *ProgressState) IsPausing() bool {
 	return ps.Pausing.Load()
 }
 
+// SetRateLimitedUntil records the end of the current host cooldown
+func (ps *ProgressState) SetRateLimitedUntil(until time.Time) {
+	if until.IsZero() {
+		ps.RateLimitedUntil.Store(0)
+		return
+	}
+	ps.RateLimitedUntil.Store(until.UnixNano())
+}
+
+// ExtendRateLimit moves the end of the host cooldown shown in the UI to until,
+// if that is later. Safe on a nil state.
+func (ps *ProgressState) ExtendRateLimit(until time.Time) {
+	if ps == nil {
+		return
+	}
+	if until.After(time.Unix(0, ps.RateLimitedUntil.Load())) {
+		ps.SetRateLimitedUntil(until)
+	}
+}
+
+// Limiters returns the bandwidth limiters a download's reads go through: its
+// own cap, then the global one. Safe on a nil state.
+func (ps *ProgressState) Limiters() []*bandwidth.Limiter {
+	if ps == nil {
+		return []*bandwidth.Limiter{bandwidth.Global()}
+	}
+	return []*bandwidth.Limiter{&ps.SpeedLimit, bandwidth.Global()}
+}
+
+// RateLimitRemaining returns how long until the current cooldown ends (0 if not rate limited)
+func (ps *ProgressState) RateLimitRemaining() time.Duration {
+	until := ps.RateLimitedUntil.Load()
+	if until == 0 {
+		return 0
+	}
+	remaining := time.Until(time.Unix(0, until))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
 func (ps *ProgressState) SetSavedElapsed(d time.Duration) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
@@ -140,6 +192,52 @@ func (ps *ProgressState) GetMirrors() []MirrorStatus {
 	return mirrors
 }
 
+// updateMirror applies fn to the status of the given mirror, if tracked
+func (ps *ProgressState) updateMirror(url string, fn func(m *MirrorStatus)) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for i := range ps.Mirrors {
+		if ps.Mirrors[i].URL == url {
+			fn(&ps.Mirrors[i])
+			return
+		}
+	}
+}
+
+// AddMirrorBytes credits n downloaded bytes to a mirror
+func (ps *ProgressState) AddMirrorBytes(url string, n int64) {
+	ps.updateMirror(url, func(m *MirrorStatus) { m.Downloaded += n })
+}
+
+// ObserveMirrorLatency folds a time-to-first-byte sample into the mirror's smoothed latency
+func (ps *ProgressState) ObserveMirrorLatency(url string, latency time.Duration) {
+	ps.updateMirror(url, func(m *MirrorStatus) {
+		if m.Latency == 0 {
+			m.Latency = latency
+			return
+		}
+		m.Latency = time.Duration((1-SpeedEMAAlpha)*float64(m.Latency) + SpeedEMAAlpha*float64(latency))
+	})
+}
+
+// AddMirrorError counts a failed request against a mirror and flags it
+func (ps *ProgressState) AddMirrorError(url string) {
+	ps.updateMirror(url, func(m *MirrorStatus) {
+		m.Errors++
+		m.Error = true
+	})
+}
+
+// SetMirrorSpeed records a mirror's current throughput
+func (ps *ProgressState) SetMirrorSpeed(url string, speed float64) {
+	ps.updateMirror(url, func(m *MirrorStatus) { m.Speed = speed })
+}
+
+// SetMirrorDemoted marks a mirror as too slow to be preferred
+func (ps *ProgressState) SetMirrorDemoted(url string, demoted bool) {
+	ps.updateMirror(url, func(m *MirrorStatus) { m.Demoted = demoted })
+}
+
 // ChunkStatus represents the status of a visualization chunk
 type ChunkStatus int
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/bandwidth"
 )
 
 func TestNewProgressState(t *testing.T) {
@@ -192,3 +194,59 @@ func TestProgressState_ElapsedCalculation(t *testing.T) {
 		t.Errorf("TotalElapsed = %v, want ~7s", totalElapsed)
 	}
 }
+
+func TestProgressState_MirrorStats(t *testing.T) {
+	ps := NewProgressState("test-id", 1000)
+	ps.SetMirrors([]MirrorStatus{{URL: "http://a", Active: true}, {URL: "http://b", Active: true}})
+
+	ps.AddMirrorBytes("http://a", 300)
+	ps.AddMirrorBytes("http://a", 200)
+	ps.ObserveMirrorLatency("http://a", 100*time.Millisecond)
+	ps.ObserveMirrorLatency("http://a", 200*time.Millisecond)
+	ps.AddMirrorError("http://b")
+	ps.AddMirrorError("http://b")
+	ps.SetMirrorSpeed("http://b", 512)
+	ps.SetMirrorDemoted("http://b", true)
+	ps.AddMirrorBytes("http://unknown", 100) // Untracked mirrors are ignored
+
+	mirrors := ps.GetMirrors()
+	a, b := mirrors[0], mirrors[1]
+	if a.Downloaded != 500 {
+		t.Errorf("Downloaded = %d, want 500", a.Downloaded)
+	}
+	if a.Latency != 130*time.Millisecond {
+		t.Errorf("Latency = %v, want 130ms", a.Latency)
+	}
+	if !b.Error || b.Errors != 2 || b.Speed != 512 || !b.Demoted {
+		t.Errorf("mirror b = %+v", b)
+	}
+	if a.Error || a.Demoted {
+		t.Errorf("mirror a = %+v", a)
+	}
+}
+
+func TestProgressState_Limiters(t *testing.T) {
+	var nilState *ProgressState
+	if got := nilState.Limiters(); len(got) != 1 || got[0] != bandwidth.Global() {
+		t.Errorf("nil state Limiters() = %v, want only the global limiter", got)
+	}
+
+	ps := NewProgressState("test", 100)
+	got := ps.Limiters()
+	if len(got) != 2 || got[0] != &ps.SpeedLimit || got[1] != bandwidth.Global() {
+		t.Errorf("Limiters() = %v, want the download's own then the global limiter", got)
+	}
+}
+
+func TestProgressState_ExtendRateLimit(t *testing.T) {
+	var nilState *ProgressState
+	nilState.ExtendRateLimit(time.Now().Add(time.Minute)) // Must not panic
+
+	ps := NewProgressState("test", 100)
+	later := time.Now().Add(time.Minute)
+	ps.ExtendRateLimit(later)
+	ps.ExtendRateLimit(later.Add(-30 * time.Second)) // An earlier end does not shorten the cooldown
+	if got := ps.RateLimitedUntil.Load(); got != later.UnixNano() {
+		t.Errorf("RateLimitedUntil = %v, want %v", time.Unix(0, got), later)
+	}
+}
@@ -0,0 +1,493 @@
+// Package extract unpacks downloaded archives into a directory next to them.
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format identifies a supported archive type
+type Format string
+
+const (
+	FormatNone   Format = ""
+	FormatZip    Format = "zip"
+	FormatTar    Format = "tar"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarZst Format = "tar.zst"
+)
+
+// Extract modes stored per download. An empty mode defers to the global settings.
+const (
+	ModeDefault = ""
+	ModeOff     = "off"    // Never extract this download
+	ModeKeep    = "keep"   // Extract and keep the archive
+	ModeDelete  = "delete" // Extract, then delete the archive
+)
+
+// progressInterval throttles progress callbacks
+const progressInterval = 250 * time.Millisecond
+
+// ErrUnsafePath is returned for entries that would be written outside the target directory
+var ErrUnsafePath = errors.New("unsafe path in archive")
+
+// suffixes maps file name endings to formats, longest first so ".tar.gz" wins over ".gz"
+var suffixes = []struct {
+	suffix string
+	format Format
+}{
+	{".tar.gz", FormatTarGz},
+	{".tar.zst", FormatTarZst},
+	{".tgz", FormatTarGz},
+	{".tzst", FormatTarZst},
+	{".tar", FormatTar},
+	{".zip", FormatZip},
+}
+
+// ParseMode validates a per-download extract mode
+func ParseMode(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return ModeDefault, nil
+	case "off", "no", "false":
+		return ModeOff, nil
+	case "keep", "yes", "true", "on":
+		return ModeKeep, nil
+	case "delete":
+		return ModeDelete, nil
+	}
+	return "", fmt.Errorf("invalid extract mode %q (want keep, delete or off)", s)
+}
+
+// Detect returns the archive format implied by a file name
+func Detect(name string) Format {
+	lower := strings.ToLower(name)
+	for _, s := range suffixes {
+		if strings.HasSuffix(lower, s.suffix) {
+			return s.format
+		}
+	}
+	return FormatNone
+}
+
+// TargetDir returns a directory next to the archive named after it, without the
+// archive extension. A counter is appended if that name is already taken.
+func TargetDir(archivePath string) string {
+	dir := filepath.Dir(archivePath)
+	base := filepath.Base(archivePath)
+	lower := strings.ToLower(base)
+	for _, s := range suffixes {
+		if strings.HasSuffix(lower, s.suffix) {
+			base = base[:len(base)-len(s.suffix)]
+			break
+		}
+	}
+	if base == "" {
+		base = "extracted"
+	}
+
+	candidate := filepath.Join(dir, base)
+	for i := 1; i < 1000; i++ {
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s(%d)", base, i))
+	}
+	return candidate
+}
+
+// Progress describes how far an extraction has come
+type Progress struct {
+	Done  int64 // Bytes processed
+	Total int64 // Bytes expected (archive size for tar formats, uncompressed size for zip)
+	Files int   // Entries written so far
+}
+
+// Result summarises a finished extraction
+type Result struct {
+	Dir   string
+	Files int
+}
+
+// Extract unpacks archivePath into a new directory next to it. Entries that would
+// escape that directory (absolute paths, ".." elements, or writes through symlinks)
+// abort the extraction. On failure the partially extracted directory is removed.
+func Extract(ctx context.Context, archivePath string, onProgress func(Progress)) (Result, error) {
+	format := Detect(archivePath)
+	if format == FormatNone {
+		return Result{}, fmt.Errorf("unsupported archive: %s", filepath.Base(archivePath))
+	}
+
+	dest := TargetDir(archivePath)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return Result{}, err
+	}
+
+	x := &extractor{ctx: ctx, dest: dest, onProgress: onProgress}
+	var err error
+	if format == FormatZip {
+		err = x.zip(archivePath)
+	} else {
+		err = x.tar(archivePath, format)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dest)
+		return Result{}, err
+	}
+
+	x.report(true)
+	return Result{Dir: dest, Files: x.progress.Files}, nil
+}
+
+type extractor struct {
+	ctx        context.Context
+	dest       string
+	onProgress func(Progress)
+	progress   Progress
+	lastReport time.Time
+}
+
+func (x *extractor) report(force bool) {
+	if x.onProgress == nil {
+		return
+	}
+	if !force && time.Since(x.lastReport) < progressInterval {
+		return
+	}
+	x.lastReport = time.Now()
+	x.onProgress(x.progress)
+}
+
+func (x *extractor) zip(archivePath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	for _, f := range zr.File {
+		x.progress.Total += int64(f.UncompressedSize64)
+	}
+
+	for _, f := range zr.File {
+		if err := x.ctx.Err(); err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := x.mkdir(f.Name); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			target, err := io.ReadAll(io.LimitReader(rc, 4096))
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := x.symlink(f.Name, string(target)); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = x.writeFile(f.Name, mode, rc, true)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(archivePath string, format Format) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	if info, err := file.Stat(); err == nil {
+		x.progress.Total = info.Size()
+	}
+
+	// Progress for compressed tarballs follows the archive bytes consumed
+	var r io.Reader = &countingReader{r: file, n: &x.progress.Done}
+	switch format {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	case FormatTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := x.ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt archive: %w", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := x.mkdir(hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := x.writeFile(hdr.Name, hdr.FileInfo().Mode(), tr, false); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := x.symlink(hdr.Name, hdr.Linkname); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			if err := x.hardlink(hdr.Name, hdr.Linkname); err != nil {
+				return err
+			}
+		default:
+			// Devices, FIFOs and PAX metadata entries are skipped
+		}
+		x.report(false)
+	}
+}
+
+// resolve maps an archive entry name to a path inside dest, rejecting anything that
+// could land outside it
+func (x *extractor) resolve(name string) (string, error) {
+	clean := strings.ReplaceAll(name, "\\", "/")
+	if clean == "" || strings.HasPrefix(clean, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	for _, elem := range strings.Split(clean, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+		}
+	}
+
+	path := filepath.Join(x.dest, filepath.FromSlash(clean))
+	rel, err := filepath.Rel(x.dest, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	// Refuse to write through symlinks created by earlier entries
+	if err := x.checkParents(rel); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkParents fails if any directory on the way to rel is a symlink
+func (x *extractor) checkParents(rel string) error {
+	parts := strings.Split(filepath.Dir(rel), string(filepath.Separator))
+	current := x.dest
+	for _, part := range parts {
+		if part == "." || part == "" {
+			continue
+		}
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s passes through a symlink", ErrUnsafePath, rel)
+		}
+	}
+	return nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	path, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0o755)
+}
+
+func (x *extractor) writeFile(name string, mode os.FileMode, r io.Reader, countBytes bool) error {
+	path, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Replace rather than follow a symlink left by an earlier entry
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	// Keep permission bits only, and always let the owner read and write
+	perm := mode.Perm() | 0o600
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		if err := x.ctx.Err(); err != nil {
+			_ = f.Close()
+			return err
+		}
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if countBytes {
+				x.progress.Done += int64(n)
+			}
+			x.report(false)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to read %s: %w", name, readErr)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	x.progress.Files++
+	return nil
+}
+
+func (x *extractor) symlink(name, target string) error {
+	path, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	// The link must point somewhere inside dest when resolved from its own directory
+	if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("%w: %s -> %s", ErrUnsafePath, name, target)
+	}
+	if err := x.checkLinkTarget(filepath.Dir(path), target); err != nil {
+		return fmt.Errorf("%w: %s -> %s", err, name, target)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, path); err != nil {
+		return err
+	}
+	x.progress.Files++
+	return nil
+}
+
+// checkLinkTarget fails if target, followed from dir the way the OS would,
+// could leave dest. Joining the path as text only matches what the OS does
+// while each ".." steps out of a real directory, so ".." is refused after a
+// symlink or a path that does not exist yet (a later entry could make it a
+// symlink).
+func (x *extractor) checkLinkTarget(dir, target string) error {
+	current := dir
+	real := true // dir is a real directory, or will be created as one
+	for _, elem := range strings.Split(strings.ReplaceAll(target, "\\", "/"), "/") {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if !real {
+				return ErrUnsafePath
+			}
+			current = filepath.Dir(current)
+			continue
+		}
+		current = filepath.Join(current, elem)
+		info, err := os.Lstat(current)
+		real = err == nil && info.IsDir()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	rel, err := filepath.Rel(x.dest, current)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ErrUnsafePath
+	}
+	return nil
+}
+
+func (x *extractor) hardlink(name, target string) error {
+	path, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+	targetPath, err := x.resolve(target)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Lstat(targetPath); err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: hard link %s -> %s", ErrUnsafePath, name, target)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.Link(targetPath, path); err != nil {
+		return err
+	}
+	x.progress.Files++
+	return nil
+}
+
+// countingReader tracks how many bytes have been read from the archive file
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,299 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type entry struct {
+	name     string
+	body     string
+	linkname string
+	typeflag byte
+}
+
+func writeTar(t *testing.T, path string, format Format, entries []entry) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Linkname: e.linkname, Typeflag: typeflag, Mode: 0o644, Size: int64(len(e.body))}
+		if typeflag == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	switch format {
+	case FormatTarGz:
+		gw := gzip.NewWriter(&out)
+		_, _ = gw.Write(buf.Bytes())
+		_ = gw.Close()
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = zw.Write(buf.Bytes())
+		_ = zw.Close()
+	default:
+		out = buf
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, entries []entry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(e.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing %s: %v", path, err)
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", path, data, want)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		want Format
+	}{
+		{"bundle.zip", FormatZip},
+		{"Bundle.ZIP", FormatZip},
+		{"bundle.tar", FormatTar},
+		{"bundle.tar.gz", FormatTarGz},
+		{"bundle.tgz", FormatTarGz},
+		{"bundle.tar.zst", FormatTarZst},
+		{"bundle.tzst", FormatTarZst},
+		{"bundle.gz", FormatNone},
+		{"bundle.iso", FormatNone},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.name); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", ModeDefault, false},
+		{"keep", ModeKeep, false},
+		{"true", ModeKeep, false},
+		{"DELETE", ModeDelete, false},
+		{"off", ModeOff, false},
+		{"sometimes", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q (err %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTargetDir(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "release.tar.gz")
+
+	if got := TargetDir(archive); got != filepath.Join(dir, "release") {
+		t.Errorf("TargetDir = %q", got)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "release"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := TargetDir(archive); got != filepath.Join(dir, "release(1)") {
+		t.Errorf("TargetDir with existing dir = %q", got)
+	}
+}
+
+func TestExtract_Formats(t *testing.T) {
+	entries := []entry{
+		{name: "docs/", typeflag: tar.TypeDir},
+		{name: "docs/readme.txt", body: "hello"},
+		{name: "bin/tool", body: "binary"},
+	}
+
+	for _, format := range []Format{FormatTar, FormatTarGz, FormatTarZst, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "bundle."+string(format))
+			if format == FormatZip {
+				writeZip(t, archive, entries[1:])
+			} else {
+				writeTar(t, archive, format, entries)
+			}
+
+			var last Progress
+			res, err := Extract(context.Background(), archive, func(p Progress) { last = p })
+			if err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if res.Dir != filepath.Join(dir, "bundle") {
+				t.Errorf("Dir = %q", res.Dir)
+			}
+			if res.Files != 2 {
+				t.Errorf("Files = %d, want 2", res.Files)
+			}
+			assertFile(t, filepath.Join(res.Dir, "docs", "readme.txt"), "hello")
+			assertFile(t, filepath.Join(res.Dir, "bin", "tool"), "binary")
+
+			if last.Total == 0 || last.Done != last.Total {
+				t.Errorf("final progress = %+v, want Done == Total", last)
+			}
+		})
+	}
+}
+
+func TestExtract_RejectsTraversal(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"dotdot", []entry{{name: "../evil.txt", body: "x"}}},
+		{"nested dotdot", []entry{{name: "a/../../evil.txt", body: "x"}}},
+		{"absolute", []entry{{name: "/tmp/evil.txt", body: "x"}}},
+		{"backslash", []entry{{name: "..\\evil.txt", body: "x"}}},
+		{"absolute symlink", []entry{{name: "link", linkname: "/etc", typeflag: tar.TypeSymlink}}},
+		{"escaping symlink", []entry{{name: "link", linkname: "../..", typeflag: tar.TypeSymlink}}},
+		{"write through symlink", []entry{
+			{name: "sub/", typeflag: tar.TypeDir},
+			{name: "link", linkname: "sub", typeflag: tar.TypeSymlink},
+			{name: "link/file.txt", body: "x"},
+		}},
+		{"symlink chain", []entry{
+			{name: "s1", linkname: ".", typeflag: tar.TypeSymlink},
+			{name: "s2", linkname: "s1/../evil.txt", typeflag: tar.TypeSymlink},
+		}},
+		{"dotdot through a later symlink", []entry{
+			{name: "s2", linkname: "a/../evil.txt", typeflag: tar.TypeSymlink},
+			{name: "a", linkname: ".", typeflag: tar.TypeSymlink},
+		}},
+		{"escaping hard link", []entry{{name: "link", linkname: "../outside", typeflag: tar.TypeLink}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && tt.entries[len(tt.entries)-1].typeflag == tar.TypeSymlink {
+				t.Skip("symlinks need privileges on Windows")
+			}
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "evil.tar")
+			writeTar(t, archive, FormatTar, tt.entries)
+
+			_, err := Extract(context.Background(), archive, nil)
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Fatalf("Extract error = %v, want ErrUnsafePath", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+				t.Error("partial extraction directory was not removed")
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.txt")); !os.IsNotExist(err) {
+				t.Error("file escaped the target directory")
+			}
+		})
+	}
+}
+
+func TestExtract_ZipSlip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.zip")
+	writeZip(t, archive, []entry{{name: "ok.txt", body: "fine"}, {name: "../../evil.txt", body: "x"}})
+
+	if _, err := Extract(context.Background(), archive, nil); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Extract error = %v, want ErrUnsafePath", err)
+	}
+}
+
+func TestExtract_InternalSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "libs.tar")
+	writeTar(t, archive, FormatTar, []entry{
+		{name: "lib/libfoo.so.1", body: "elf"},
+		{name: "lib/libfoo.so", linkname: "libfoo.so.1", typeflag: tar.TypeSymlink},
+	})
+
+	res, err := Extract(context.Background(), archive, nil)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	assertFile(t, filepath.Join(res.Dir, "lib", "libfoo.so"), "elf")
+}
+
+func TestExtract_Cancelled(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "bundle.tar")
+	writeTar(t, archive, FormatTar, []entry{{name: "a.txt", body: "a"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Extract(ctx, archive, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Extract error = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bundle")); !os.IsNotExist(err) {
+		t.Error("partial extraction directory was not removed")
+	}
+}
+
+func TestExtract_Unsupported(t *testing.T) {
+	if _, err := Extract(context.Background(), filepath.Join(t.TempDir(), "file.iso"), nil); err == nil {
+		t.Fatal("expected error for unsupported archive")
+	}
+}
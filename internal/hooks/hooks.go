@@ -0,0 +1,222 @@
+// Package hooks runs user-configured commands and webhooks when downloads finish.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/config"
+)
+
+// Event types a hook can subscribe to
+const (
+	EventComplete = "complete"
+	EventError    = "error"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	retryBackoff   = 2 * time.Second
+)
+
+// Event describes a finished download. Commands receive it as SURGE_* environment
+// variables, webhooks as the JSON request body.
+type Event struct {
+	Type     string `json:"event"` // EventComplete or EventError
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+	Size     int64  `json:"size"`
+	Elapsed  int64  `json:"elapsed_ms,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Env returns the event as SURGE_* environment variables
+func (e Event) Env() []string {
+	return []string{
+		"SURGE_EVENT=" + e.Type,
+		"SURGE_ID=" + e.ID,
+		"SURGE_FILENAME=" + e.Filename,
+		"SURGE_PATH=" + e.Path,
+		"SURGE_URL=" + e.URL,
+		"SURGE_SIZE=" + strconv.FormatInt(e.Size, 10),
+		"SURGE_ELAPSED_MS=" + strconv.FormatInt(e.Elapsed, 10),
+		"SURGE_ERROR=" + e.Error,
+	}
+}
+
+// Result records the outcome of one hook
+type Result struct {
+	Hook     string // Display name
+	Attempts int
+	ExitCode int // Process exit status, or HTTP status for webhooks
+	Err      error
+}
+
+// Runner executes hooks for download events
+type Runner struct {
+	hooks   []config.HookSettings
+	client  *http.Client
+	backoff time.Duration
+}
+
+// NewRunner creates a runner for the configured hooks
+func NewRunner(hooks []config.HookSettings) *Runner {
+	return &Runner{
+		hooks:   hooks,
+		client:  &http.Client{},
+		backoff: retryBackoff,
+	}
+}
+
+// Name returns how a hook is identified in logs
+func Name(h config.HookSettings) string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Command != "":
+		return h.Command
+	default:
+		return h.URL
+	}
+}
+
+// Matches reports whether a hook subscribes to the event type
+func Matches(h config.HookSettings, eventType string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Run invokes every hook subscribed to ev, in configuration order, and returns their results
+func (r *Runner) Run(ctx context.Context, ev Event) []Result {
+	var results []Result
+	for _, h := range r.hooks {
+		if !Matches(h, ev.Type) {
+			continue
+		}
+		results = append(results, r.runHook(ctx, h, ev))
+	}
+	return results
+}
+
+// runHook runs a single hook with its timeout, retrying failures
+func (r *Runner) runHook(ctx context.Context, h config.HookSettings, ev Event) Result {
+	res := Result{Hook: Name(h)}
+	if h.Command == "" && h.URL == "" {
+		res.Err = errors.New("hook has neither command nor url")
+		return res
+	}
+
+	timeout := DefaultTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+
+	for attempt := 0; attempt <= h.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(r.backoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				res.Err = ctx.Err()
+				return res
+			}
+		}
+
+		res.Attempts++
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		if h.Command != "" {
+			res.ExitCode, res.Err = runCommand(attemptCtx, h.Command, ev)
+		} else {
+			res.ExitCode, res.Err = r.postWebhook(attemptCtx, h.URL, ev)
+		}
+		cancel()
+
+		if res.Err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return res
+}
+
+// runCommand executes command through the platform shell and returns its exit status
+func runCommand(ctx context.Context, command string, ev Event) (int, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), ev.Env()...)
+	// Children of the shell may keep the output pipe open after it is killed
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return -1, fmt.Errorf("timed out")
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), fmt.Errorf("exit status %d: %s", exitErr.ExitCode(), truncate(output))
+		}
+		return -1, err
+	}
+	return 0, nil
+}
+
+// postWebhook sends the event JSON and returns the HTTP status
+func (r *Runner) postWebhook(ctx context.Context, url string, ev Event) (int, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "surge-hooks")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, fmt.Errorf("timed out")
+		}
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncate(msg))
+	}
+	return resp.StatusCode, nil
+}
+
+// truncate keeps hook output short enough for a log line
+func truncate(b []byte) string {
+	const maxLen = 200
+	s := string(bytes.TrimSpace(b))
+	if len(s) > maxLen {
+		return s[:maxLen] + "..."
+	}
+	return s
+}
@@ -0,0 +1,176 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/config"
+)
+
+func testEvent() Event {
+	return Event{
+		Type:     EventComplete,
+		ID:       "hook-id",
+		Filename: "file.zip",
+		Path:     "/downloads/file.zip",
+		URL:      "https://example.com/file.zip",
+		Size:     1234,
+	}
+}
+
+func newTestRunner(hooks ...config.HookSettings) *Runner {
+	r := NewRunner(hooks)
+	r.backoff = time.Millisecond
+	return r
+}
+
+func skipWithoutShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("command hooks are tested with a POSIX shell")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	both := config.HookSettings{Command: "true"}
+	onlyErrors := config.HookSettings{Command: "true", Events: []string{EventError}}
+
+	if !Matches(both, EventComplete) || !Matches(both, EventError) {
+		t.Error("hook without events should match every event")
+	}
+	if Matches(onlyErrors, EventComplete) {
+		t.Error("error-only hook matched a completion")
+	}
+	if !Matches(onlyErrors, EventError) {
+		t.Error("error-only hook did not match an error")
+	}
+}
+
+func TestRun_CommandReceivesEnv(t *testing.T) {
+	skipWithoutShell(t)
+	out := filepath.Join(t.TempDir(), "env.txt")
+
+	r := newTestRunner(config.HookSettings{
+		Name:    "record",
+		Command: `printf '%s|%s|%s|%s|%s' "$SURGE_EVENT" "$SURGE_ID" "$SURGE_PATH" "$SURGE_URL" "$SURGE_SIZE" > ` + out,
+	})
+	results := r.Run(context.Background(), testEvent())
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if res := results[0]; res.Err != nil || res.ExitCode != 0 || res.Hook != "record" {
+		t.Fatalf("result = %+v, want success", res)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	want := "complete|hook-id|/downloads/file.zip|https://example.com/file.zip|1234"
+	if string(data) != want {
+		t.Errorf("hook env = %q, want %q", data, want)
+	}
+}
+
+func TestRun_CommandFailureRetries(t *testing.T) {
+	skipWithoutShell(t)
+	counter := filepath.Join(t.TempDir(), "count")
+
+	r := newTestRunner(config.HookSettings{
+		Command: "echo x >> " + counter + "; exit 3",
+		Retries: 2,
+	})
+	res := r.Run(context.Background(), testEvent())[0]
+	if res.Err == nil {
+		t.Fatal("expected failure")
+	}
+	if res.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", res.ExitCode)
+	}
+	if res.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", res.Attempts)
+	}
+	data, _ := os.ReadFile(counter)
+	if n := strings.Count(string(data), "x"); n != 3 {
+		t.Errorf("command ran %d times, want 3", n)
+	}
+}
+
+func TestRun_CommandTimeout(t *testing.T) {
+	skipWithoutShell(t)
+
+	r := newTestRunner(config.HookSettings{Command: "sleep 5", TimeoutSeconds: 1})
+	start := time.Now()
+	res := r.Run(context.Background(), testEvent())[0]
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "timed out") {
+		t.Errorf("Err = %v, want timeout", res.Err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("timeout not enforced, took %v", elapsed)
+	}
+}
+
+func TestRun_Webhook(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r := newTestRunner(config.HookSettings{URL: server.URL})
+	res := r.Run(context.Background(), testEvent())[0]
+	if res.Err != nil || res.ExitCode != http.StatusNoContent {
+		t.Fatalf("result = %+v, want 204", res)
+	}
+	if received != testEvent() {
+		t.Errorf("webhook body = %+v, want %+v", received, testEvent())
+	}
+}
+
+func TestRun_WebhookRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r := newTestRunner(config.HookSettings{URL: server.URL, Retries: 2})
+	res := r.Run(context.Background(), testEvent())[0]
+	if res.Err != nil {
+		t.Fatalf("expected success after retry, got %v", res.Err)
+	}
+	if res.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", res.Attempts)
+	}
+}
+
+func TestRun_SkipsUnsubscribedAndInvalid(t *testing.T) {
+	r := newTestRunner(
+		config.HookSettings{Name: "errors only", Command: "true", Events: []string{EventError}},
+		config.HookSettings{Name: "empty"},
+	)
+	results := r.Run(context.Background(), testEvent())
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if results[0].Hook != "empty" || results[0].Err == nil {
+		t.Errorf("result = %+v, want error for hook without command or url", results[0])
+	}
+}
@@ -0,0 +1,303 @@
+package testutil
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// FTPServer is a minimal passive-mode FTP server serving a single file for download tests.
+type FTPServer struct {
+	Listener net.Listener
+
+	// Configuration
+	Path        string        // File path served, relative to the login directory
+	Data        []byte        // File content
+	ModTime     time.Time     // Reported by MDTM
+	User        string        // Required user (empty accepts any login)
+	Password    string        // Required password when User is set
+	NoRest      bool          // Reject REST so transfers cannot resume
+	MaxConns    int           // Answer 421 beyond this many concurrent sessions (0 = unlimited)
+	ByteLatency time.Duration // Delay before each KB of a transfer
+
+	// Tracking
+	Logins      atomic.Int64
+	Retrievals  atomic.Int64
+	Refused     atomic.Int64
+	ActiveConns atomic.Int64
+
+	wg      sync.WaitGroup
+	closed  atomic.Bool
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+}
+
+// FTPServerOption configures an FTPServer.
+type FTPServerOption func(*FTPServer)
+
+// WithFTPCredentials requires the given login.
+func WithFTPCredentials(user, password string) FTPServerOption {
+	return func(s *FTPServer) {
+		s.User = user
+		s.Password = password
+	}
+}
+
+// WithFTPNoRest disables the REST command.
+func WithFTPNoRest() FTPServerOption {
+	return func(s *FTPServer) { s.NoRest = true }
+}
+
+// WithFTPMaxConns limits concurrent sessions, refusing extras with 421.
+func WithFTPMaxConns(n int) FTPServerOption {
+	return func(s *FTPServer) { s.MaxConns = n }
+}
+
+// WithFTPByteLatency slows transfers down by d per KB.
+func WithFTPByteLatency(d time.Duration) FTPServerOption {
+	return func(s *FTPServer) { s.ByteLatency = d }
+}
+
+// NewFTPServerT starts an FTP server serving data at path and skips the test if binding fails.
+func NewFTPServerT(t *testing.T, path string, data []byte, opts ...FTPServerOption) *FTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("tcp4 listener unavailable: %v", err)
+		return nil
+	}
+
+	s := &FTPServer{
+		Listener: ln,
+		Path:     strings.TrimPrefix(path, "/"),
+		Data:     data,
+		ModTime:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// URL returns the ftp:// URL of the served file.
+func (s *FTPServer) URL() string {
+	userinfo := ""
+	if s.User != "" {
+		userinfo = s.User + ":" + s.Password + "@"
+	}
+	return fmt.Sprintf("ftp://%s%s/%s", userinfo, s.Listener.Addr().String(), s.Path)
+}
+
+// Close stops the server and waits for sessions to end.
+func (s *FTPServer) Close() {
+	if s.closed.Swap(true) {
+		return
+	}
+	_ = s.Listener.Close()
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *FTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+		s.connsMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// ftpSession is the state of one control connection
+type ftpSession struct {
+	ctrl     net.Conn
+	r        *bufio.Reader
+	user     string
+	loggedIn bool
+	restAt   int64
+	pasv     net.Listener
+}
+
+func (c *ftpSession) reply(code int, msg string) {
+	_, _ = fmt.Fprintf(c.ctrl, "%d %s\r\n", code, msg)
+}
+
+func (s *FTPServer) handle(conn net.Conn) {
+	c := &ftpSession{ctrl: conn, r: bufio.NewReader(conn)}
+	defer func() {
+		if c.pasv != nil {
+			_ = c.pasv.Close()
+		}
+		_ = conn.Close()
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+	}()
+
+	defer s.ActiveConns.Add(-1)
+	if active := s.ActiveConns.Add(1); s.MaxConns > 0 && active > int64(s.MaxConns) {
+		s.Refused.Add(1)
+		c.reply(421, "Too many connections")
+		return
+	}
+
+	c.reply(220, "surge test server")
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		line, err := c.r.ReadString('\n')
+		if err != nil || s.closed.Load() {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		cmd = strings.ToUpper(cmd)
+
+		if !c.loggedIn && cmd != "USER" && cmd != "PASS" && cmd != "FEAT" && cmd != "QUIT" {
+			c.reply(530, "Not logged in")
+			continue
+		}
+
+		switch cmd {
+		case "USER":
+			c.user = arg
+			c.reply(331, "Password required")
+		case "PASS":
+			if s.User != "" && (c.user != s.User || arg != s.Password) {
+				c.reply(530, "Login incorrect")
+				continue
+			}
+			c.loggedIn = true
+			s.Logins.Add(1)
+			c.reply(230, "Logged in")
+		case "FEAT":
+			feats := []string{"EPSV", "PASV", "SIZE", "MDTM"}
+			if !s.NoRest {
+				feats = append(feats, "REST STREAM")
+			}
+			_, _ = fmt.Fprintf(c.ctrl, "211-Features:\r\n %s\r\n211 End\r\n", strings.Join(feats, "\r\n "))
+		case "TYPE", "OPTS":
+			c.reply(200, "OK")
+		case "SYST":
+			c.reply(215, "UNIX Type: L8")
+		case "PWD":
+			c.reply(257, `"/" is the current directory`)
+		case "SIZE":
+			if arg != s.Path {
+				c.reply(550, "No such file")
+				continue
+			}
+			c.reply(213, strconv.Itoa(len(s.Data)))
+		case "MDTM":
+			if arg != s.Path {
+				c.reply(550, "No such file")
+				continue
+			}
+			c.reply(213, s.ModTime.Format("20060102150405"))
+		case "REST":
+			offset, err := strconv.ParseInt(arg, 10, 64)
+			if s.NoRest || err != nil || offset < 0 {
+				c.reply(502, "REST not supported")
+				continue
+			}
+			c.restAt = offset
+			c.reply(350, "Restarting")
+		case "EPSV", "PASV":
+			if !s.openPassive(c, cmd) {
+				c.reply(425, "Cannot open data connection")
+			}
+		case "RETR":
+			s.retrieve(c, arg)
+		case "QUIT":
+			c.reply(221, "Bye")
+			return
+		default:
+			c.reply(502, "Command not implemented")
+		}
+	}
+}
+
+func (s *FTPServer) openPassive(c *ftpSession, cmd string) bool {
+	if c.pasv != nil {
+		_ = c.pasv.Close()
+	}
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		return false
+	}
+	c.pasv = ln
+	port := ln.Addr().(*net.TCPAddr).Port
+	if cmd == "EPSV" {
+		c.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+	} else {
+		c.reply(227, fmt.Sprintf("Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256))
+	}
+	return true
+}
+
+func (s *FTPServer) retrieve(c *ftpSession, path string) {
+	offset := c.restAt
+	c.restAt = 0
+	if path != s.Path {
+		c.reply(550, "No such file")
+		return
+	}
+	if c.pasv == nil {
+		c.reply(425, "Use PASV first")
+		return
+	}
+	if offset > int64(len(s.Data)) {
+		c.reply(554, "Invalid restart offset")
+		return
+	}
+
+	_ = c.pasv.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	data, err := c.pasv.Accept()
+	_ = c.pasv.Close()
+	c.pasv = nil
+	if err != nil {
+		c.reply(425, "Cannot open data connection")
+		return
+	}
+	s.Retrievals.Add(1)
+	c.reply(150, "Opening data connection")
+
+	remaining := s.Data[offset:]
+	var writeErr error
+	for len(remaining) > 0 && writeErr == nil {
+		n := min(len(remaining), 1024)
+		if s.ByteLatency > 0 {
+			time.Sleep(s.ByteLatency)
+		}
+		_, writeErr = data.Write(remaining[:n])
+		remaining = remaining[n:]
+	}
+	_ = data.Close()
+
+	if writeErr != nil {
+		c.reply(426, "Transfer aborted")
+		return
+	}
+	c.reply(226, "Transfer complete")
+}
@@ -0,0 +1,234 @@
+package testutil
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHServer is an SSH server offering only the SFTP subsystem, serving the local
+// filesystem read-only for download tests.
+type SSHServer struct {
+	Listener net.Listener
+	HostKey  ssh.Signer
+
+	// Configuration
+	User           string          // Accepted user name
+	Password       string          // Accepted password (empty disables password auth)
+	AuthorizedKeys []ssh.PublicKey // Accepted client keys
+	ByteLatency    time.Duration   // Delay before each KB read by a client
+
+	// Tracking
+	Logins   atomic.Int64
+	Channels atomic.Int64 // SFTP subsystem sessions opened
+
+	wg      sync.WaitGroup
+	closed  atomic.Bool
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+}
+
+// SSHServerOption configures an SSHServer.
+type SSHServerOption func(*SSHServer)
+
+// WithSSHPassword accepts password logins with the given password.
+func WithSSHPassword(password string) SSHServerOption {
+	return func(s *SSHServer) { s.Password = password }
+}
+
+// WithSSHAuthorizedKey accepts logins with the given public key.
+func WithSSHAuthorizedKey(key ssh.PublicKey) SSHServerOption {
+	return func(s *SSHServer) { s.AuthorizedKeys = append(s.AuthorizedKeys, key) }
+}
+
+// WithSSHByteLatency slows reads down by d per KB.
+func WithSSHByteLatency(d time.Duration) SSHServerOption {
+	return func(s *SSHServer) { s.ByteLatency = d }
+}
+
+// NewSSHServerT starts an SSH server accepting user and skips the test if binding fails.
+func NewSSHServerT(t *testing.T, user string, opts ...SSHServerOption) *SSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("tcp4 listener unavailable: %v", err)
+		return nil
+	}
+
+	s := &SSHServer{
+		Listener: ln,
+		HostKey:  hostKey,
+		User:     user,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr returns the server's host:port.
+func (s *SSHServer) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+// URL returns the sftp:// URL of an absolute path on the server.
+func (s *SSHServer) URL(path string) string {
+	return fmt.Sprintf("sftp://%s@%s%s", s.User, s.Addr(), path)
+}
+
+// WriteKnownHosts writes a known_hosts file trusting the server's host key.
+func (s *SSHServer) WriteKnownHosts(path string) error {
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.Addr())}, s.HostKey.PublicKey())
+	return os.WriteFile(path, []byte(line+"\n"), 0o600)
+}
+
+// Close stops the server and waits for sessions to end.
+func (s *SSHServer) Close() {
+	if s.closed.Swap(true) {
+		return
+	}
+	_ = s.Listener.Close()
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *SSHServer) config() *ssh.ServerConfig {
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == s.User {
+				for _, authorized := range s.AuthorizedKeys {
+					if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+						return nil, nil
+					}
+				}
+			}
+			return nil, fmt.Errorf("unknown key for %q", meta.User())
+		},
+	}
+	if s.Password != "" {
+		cfg.PasswordCallback = func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == s.User && string(password) == s.Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", meta.User())
+		}
+	}
+	cfg.AddHostKey(s.HostKey)
+	return cfg
+}
+
+func (s *SSHServer) serve() {
+	defer s.wg.Done()
+	cfg := s.config()
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+		s.connsMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				_ = conn.Close()
+				s.connsMu.Lock()
+				delete(s.conns, conn)
+				s.connsMu.Unlock()
+			}()
+			s.handle(conn, cfg)
+		}()
+	}
+}
+
+func (s *SSHServer) handle(conn net.Conn, cfg *ssh.ServerConfig) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	defer func() { _ = sshConn.Close() }()
+	s.Logins.Add(1)
+	go ssh.DiscardRequests(reqs)
+
+	var sessions sync.WaitGroup
+	defer sessions.Wait()
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			_ = newChan.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			s.session(channel, requests)
+		}()
+	}
+}
+
+func (s *SSHServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer func() { _ = channel.Close() }()
+	for req := range requests {
+		if req.Type != "subsystem" || len(req.Payload) < 4 || string(req.Payload[4:]) != "sftp" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+		s.Channels.Add(1)
+		go ssh.DiscardRequests(requests)
+
+		server, err := sftp.NewServer(&slowChannel{Channel: channel, latency: s.ByteLatency}, sftp.ReadOnly())
+		if err != nil {
+			return
+		}
+		_ = server.Serve()
+		_ = server.Close()
+		return
+	}
+}
+
+// slowChannel delays writes to the client to simulate a slow link
+type slowChannel struct {
+	ssh.Channel
+	latency time.Duration
+}
+
+func (c *slowChannel) Write(p []byte) (int, error) {
+	if c.latency > 0 {
+		time.Sleep(c.latency * time.Duration((len(p)+1023)/1024))
+	}
+	return c.Channel.Write(p)
+}
@@ -0,0 +1,44 @@
+package testutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/surge-downloader/surge/internal/engine/state"
+	"github.com/surge-downloader/surge/internal/engine/types"
+)
+
+// StateDirT creates a temporary directory with a fresh state database in it,
+// closed and removed when the test ends, and returns the directory.
+func StateDirT(t *testing.T, prefix string) string {
+	t.Helper()
+	state.CloseDB() // Ensure any previous DB is closed
+	dir, cleanup, err := TempDir(prefix)
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	state.Configure(filepath.Join(dir, "surge.db"))
+	t.Cleanup(func() {
+		state.CloseDB() // Close DB before removing dir
+		cleanup()
+	})
+	return dir
+}
+
+// VerifyDownloadT fails the test unless the completed download at path holds
+// want and its working file is gone.
+func VerifyDownloadT(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read download: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Downloaded content differs (got %d bytes, want %d)", len(got), len(want))
+	}
+	if FileExists(path + types.IncompleteSuffix) {
+		t.Error("Working file left behind")
+	}
+}
@@ -0,0 +1,130 @@
+// Package tlsutil serves the daemon over TLS and lets clients trust a
+// self-signed daemon certificate by pinning its fingerprint on first use.
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// certValidity is how long a generated certificate lasts
+const certValidity = 10 * 365 * 24 * time.Hour
+
+// LoadOrCreate loads the key pair at certFile and keyFile. When neither exists a
+// self-signed certificate is generated there first, and created is true.
+func LoadOrCreate(certFile, keyFile string) (cert tls.Certificate, created bool, err error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		if err := generate(certFile, keyFile); err != nil {
+			return tls.Certificate{}, false, err
+		}
+		created = true
+	}
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	return cert, created, err
+}
+
+// generate writes a self-signed certificate for this machine's names and addresses
+func generate(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Surge daemon"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+	template.IPAddresses = localAddresses()
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0o755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+// localAddresses returns the loopback addresses plus those of the network interfaces
+func localAddresses() []net.IP {
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ips
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER certificate, "sha256:<hex>"
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint accepts a fingerprint with or without the "sha256:" prefix,
+// in either case and with optional colons, as printed by openssl
+func NormalizeFingerprint(fp string) (string, error) {
+	fp = strings.ToLower(strings.TrimSpace(fp))
+	fp = strings.TrimPrefix(fp, "sha256:")
+	fp = strings.ReplaceAll(fp, ":", "")
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint %q", fp)
+	}
+	return "sha256:" + fp, nil
+}
+
+// CertificateFingerprint returns the fingerprint of a loaded key pair's leaf
+func CertificateFingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	return Fingerprint(cert.Certificate[0])
+}
@@ -0,0 +1,109 @@
+package tlsutil
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// sniffTimeout bounds how long a new connection may take to send its first byte
+const sniffTimeout = 10 * time.Second
+
+// recordTypeHandshake starts every TLS ClientHello
+const recordTypeHandshake = 0x16
+
+// NewListener serves TLS on inner. Plain HTTP is still accepted from loopback
+// addresses, so local tools such as the browser extension keep working; other
+// peers that skip the handshake get the HTTP server's "HTTP request to an HTTPS
+// server" reply.
+func NewListener(inner net.Listener, config *tls.Config) net.Listener {
+	l := &listener{
+		Listener: inner,
+		config:   config,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+type listener struct {
+	net.Listener
+	config *tls.Config
+	conns  chan net.Conn
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func (l *listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.stop(err)
+			return
+		}
+		// Classify in the background so a silent client cannot hold up others
+		go l.classify(conn)
+	}
+}
+
+func (l *listener) classify(conn net.Conn) {
+	br := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	first, err := br.Peek(1)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	var out net.Conn = &peekedConn{Conn: conn, r: br}
+	if first[0] == recordTypeHandshake || !isLoopback(conn.RemoteAddr()) {
+		out = tls.Server(out, l.config)
+	}
+	select {
+	case l.conns <- out:
+	case <-l.done:
+		_ = out.Close()
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *listener) Close() error {
+	l.stop(net.ErrClosed)
+	return l.Listener.Close()
+}
+
+func (l *listener) stop(err error) {
+	l.once.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// peekedConn replays the bytes read while classifying the connection
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,181 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	dir := t.TempDir()
+	cert, created, err := LoadOrCreate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil || !created {
+		t.Fatalf("LoadOrCreate = %v, created %v", err, created)
+	}
+	return cert
+}
+
+// serveTest serves a handler that reports whether the request came over TLS
+func serveTest(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsLn := NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			_, _ = io.WriteString(w, "tls")
+		} else {
+			_, _ = io.WriteString(w, "plain")
+		}
+	})}
+	go func() { _ = srv.Serve(tlsLn) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String()
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestLoadOrCreate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	first, created, err := LoadOrCreate(certFile, keyFile)
+	if err != nil || !created {
+		t.Fatalf("First LoadOrCreate = %v, created %v", err, created)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Key file mode = %v, %v", info.Mode().Perm(), err)
+	}
+
+	// The same certificate is reused on the next start
+	second, created, err := LoadOrCreate(certFile, keyFile)
+	if err != nil || created {
+		t.Fatalf("Second LoadOrCreate = %v, created %v", err, created)
+	}
+	if CertificateFingerprint(first) != CertificateFingerprint(second) {
+		t.Error("Fingerprint changed between starts")
+	}
+
+	// A lone key is an error rather than something to overwrite
+	if err := os.Remove(certFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadOrCreate(certFile, keyFile); err == nil {
+		t.Error("LoadOrCreate with a missing certificate should fail")
+	}
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	want := "sha256:" + strings.Repeat("ab", 32)
+	for _, in := range []string{
+		want,
+		strings.Repeat("AB", 32),
+		"SHA256:" + strings.TrimSuffix(strings.Repeat("AB:", 32), ":"),
+	} {
+		if got, err := NormalizeFingerprint(in); err != nil || got != want {
+			t.Errorf("NormalizeFingerprint(%q) = %q, %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"", "sha256:abc", strings.Repeat("zz", 32)} {
+		if _, err := NormalizeFingerprint(bad); err == nil {
+			t.Errorf("NormalizeFingerprint(%q) should fail", bad)
+		}
+	}
+}
+
+func TestListener_TLSAndLoopbackHTTP(t *testing.T) {
+	cert := newTestCert(t)
+	addr := serveTest(t, cert)
+
+	pinned := &http.Client{Transport: &http.Transport{TLSClientConfig: PinnedConfig(CertificateFingerprint(cert))}}
+	if got := get(t, pinned, "https://"+addr+"/"); got != "tls" {
+		t.Errorf("HTTPS request served as %q", got)
+	}
+	if got := get(t, http.DefaultClient, "http://"+addr+"/"); got != "plain" {
+		t.Errorf("Loopback HTTP request served as %q", got)
+	}
+
+	// A client pinning another certificate refuses the connection
+	other := &http.Client{Transport: &http.Transport{TLSClientConfig: PinnedConfig(CertificateFingerprint(newTestCert(t)))}}
+	if _, err := other.Get("https://" + addr + "/"); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("Mismatched pin: err = %v, want ErrFingerprintMismatch", err)
+	}
+}
+
+func TestListener_Close(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsLn := NewListener(ln, &tls.Config{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := tlsLn.Accept()
+		done <- err
+	}()
+	_ = tlsLn.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Accept after Close = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept did not return after Close")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	cert := newTestCert(t)
+	addr := serveTest(t, cert)
+
+	fp, trusted, err := Probe(context.Background(), addr, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp != CertificateFingerprint(cert) || trusted {
+		t.Errorf("Probe = %s, trusted %v; want %s, untrusted", fp, trusted, CertificateFingerprint(cert))
+	}
+}
+
+func TestPins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_daemons")
+
+	if fp, err := LookupPin(path, "host:1700"); err != nil || fp != "" {
+		t.Fatalf("LookupPin on a missing file = %q, %v", fp, err)
+	}
+	if err := SavePin(path, "host:1700", "sha256:aa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SavePin(path, "other:1700", "sha256:bb"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SavePin(path, "host:1700", "sha256:cc"); err != nil {
+		t.Fatal(err)
+	}
+
+	for addr, want := range map[string]string{"host:1700": "sha256:cc", "other:1700": "sha256:bb", "host:1701": ""} {
+		if got, err := LookupPin(path, addr); err != nil || got != want {
+			t.Errorf("LookupPin(%s) = %q, %v; want %q", addr, got, err, want)
+		}
+	}
+}
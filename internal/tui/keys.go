@@ -31,6 +31,11 @@ type DashboardKeyMap struct {
 	Log         key.Binding
 	History     key.Binding
 	OpenFile    key.Binding
+	SpeedLimit  key.Binding
+	MoveUp      key.Binding
+	MoveDown    key.Binding
+	MoveTop     key.Binding
+	Category    key.Binding
 	Quit        key.Binding
 	ForceQuit   key.Binding
 	// Navigation
@@ -176,6 +181,26 @@ var Keys = KeyMap{
 			key.WithKeys("o"),
 			key.WithHelp("o", "open file"),
 		),
+		SpeedLimit: key.NewBinding(
+			key.WithKeys("L"),
+			key.WithHelp("L", "speed limit"),
+		),
+		MoveUp: key.NewBinding(
+			key.WithKeys("K", "shift+up"),
+			key.WithHelp("K", "move up in queue"),
+		),
+		MoveDown: key.NewBinding(
+			key.WithKeys("J", "shift+down"),
+			key.WithHelp("J", "move down in queue"),
+		),
+		MoveTop: key.NewBinding(
+			key.WithKeys("T"),
+			key.WithHelp("T", "move to front of queue"),
+		),
+		Category: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "filter by category"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("ctrl+c", "ctrl+q"),
 			key.WithHelp("ctrl+q", "quit"),
@@ -407,7 +432,8 @@ func (k DashboardKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.TabQueued, k.TabActive, k.TabDone, k.NextTab},
 		{k.Add, k.Search, k.Pause, k.Delete, k.Settings},
-		{k.Log, k.History, k.Quit},
+		{k.Log, k.History, k.SpeedLimit, k.Category, k.Quit},
+		{k.MoveUp, k.MoveDown, k.MoveTop},
 	}
 }
 
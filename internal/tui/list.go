@@ -31,6 +31,12 @@ func (i DownloadItem) Description() string {
 	if d.pausing {
 		// Custom "Pausing..." style using existing colors
 		styledStatus = lipgloss.NewStyle().Foreground(colors.StatePaused).Render("⏸ Pausing...")
+	} else if d.isScheduled() {
+		styledStatus = d.scheduledStatus()
+	} else if d.extracting {
+		styledStatus = lipgloss.NewStyle().Foreground(colors.StateDownloading).Render(fmt.Sprintf("📦 Extracting %.0f%%", d.extractPct))
+	} else if d.isRateLimited() {
+		styledStatus = lipgloss.NewStyle().Foreground(colors.StatePaused).Render(d.rateLimitStatus())
 	} else {
 		styledStatus = components.DetermineStatus(d.done, d.paused, d.err != nil, d.Speed, d.Downloaded).Render()
 	}
@@ -50,6 +56,9 @@ func (i DownloadItem) Description() string {
 	if d.Speed > 0 {
 		speedInfo = fmt.Sprintf(" • %.2f MB/s", d.Speed/Megabyte)
 	}
+	if d.SpeedLimit > 0 && !d.done {
+		speedInfo += " (max " + d.speedLimitLabel() + ")"
+	}
 
 	return fmt.Sprintf("%s • %.0f%%%s • %s", styledStatus, pct, speedInfo, sizeInfo)
 }
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,6 +19,8 @@ import (
 	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/core"
 	"github.com/surge-downloader/surge/internal/engine/types"
+	"github.com/surge-downloader/surge/internal/tui/components"
+	"github.com/surge-downloader/surge/internal/utils"
 	"github.com/surge-downloader/surge/internal/version"
 )
 
@@ -36,6 +39,7 @@ const (
 	BatchFilePickerState                      // BatchFilePickerState is 9
 	BatchConfirmState                         // BatchConfirmState is 10
 	UpdateAvailableState                      // UpdateAvailableState is 11
+	SpeedLimitState                           // SpeedLimitState is 12
 )
 
 const (
@@ -55,6 +59,11 @@ type DownloadModel struct {
 	Speed         float64
 	Connections   int
 
+	RateLimitedFor time.Duration // Remaining host cooldown reported by the engine
+	ScheduledAt    time.Time     // When a scheduled download will start (zero = not scheduled)
+	SpeedLimit     int64         // Per-download bandwidth cap in bytes per second (0 = unlimited)
+	Category       string        // Download category from settings, once known
+
 	StartTime time.Time
 	Elapsed   time.Duration
 
@@ -69,6 +78,9 @@ type DownloadModel struct {
 	paused        bool
 	pausing       bool // UI state: transitioning to pause
 	pendingResume bool // UI state: waiting for async resume
+
+	extracting bool    // Completed archive is being unpacked
+	extractPct float64 // Extraction progress (0-100)
 }
 
 type RootModel struct {
@@ -103,7 +115,8 @@ type RootModel struct {
 	pendingFilename string   // Filename pending confirmation
 	pendingMirrors  []string // Mirrors pending confirmation
 	pendingHeaders  map[string]string
-	duplicateInfo   string // Info about the duplicate
+	pendingOptions  types.DownloadOptions // Per-download options pending confirmation
+	duplicateInfo   string                // Info about the duplicate
 
 	// Graph Data
 	SpeedHistory           []float64 // Stores the last ~60 ticks of speed data
@@ -132,6 +145,12 @@ type RootModel struct {
 	searchActive bool            // Whether search mode is active
 	searchQuery  string          // Current search query
 
+	categoryFilter string // Only list downloads of this category (empty = all)
+
+	// Per-download speed limit editor
+	limitInput    textinput.Model // Text input for the new limit
+	limitTargetID string          // Download the limit applies to
+
 	// Batch import
 	pendingBatchURLs []string // URLs pending batch import
 	batchFilePath    string   // Path to the batch file
@@ -167,6 +186,34 @@ func NewDownloadModel(id string, url string, filename string, total int64) *Down
 	}
 }
 
+// isRateLimited reports whether the engine is backing off a rate-limited host for this download
+func (d *DownloadModel) isRateLimited() bool {
+	return !d.done && !d.paused && d.RateLimitedFor > 0
+}
+
+// rateLimitStatus renders the cooldown, e.g. "rate limited, retrying in 42s"
+func (d *DownloadModel) rateLimitStatus() string {
+	return fmt.Sprintf("⏳ rate limited, retrying in %s", d.RateLimitedFor.Round(time.Second))
+}
+
+// speedLimitLabel renders the download's bandwidth cap, e.g. "2.0 MB/s"
+func (d *DownloadModel) speedLimitLabel() string {
+	if d.SpeedLimit <= 0 {
+		return "Unlimited"
+	}
+	return utils.ConvertBytesToHumanReadable(d.SpeedLimit) + "/s"
+}
+
+// isScheduled reports whether the download is waiting for its start time or active window
+func (d *DownloadModel) isScheduled() bool {
+	return !d.done && d.err == nil && !d.ScheduledAt.IsZero()
+}
+
+// scheduledStatus renders the start time, e.g. "Scheduled • starts Mon 01:00"
+func (d *DownloadModel) scheduledStatus() string {
+	return fmt.Sprintf("%s • starts %s", components.StatusScheduled.Render(), d.ScheduledAt.Format("Mon 15:04"))
+}
+
 func InitialRootModel(serverPort int, currentVersion string, service core.DownloadService, noResume bool) RootModel {
 	// Initialize inputs
 	urlInput := textinput.New()
@@ -191,6 +238,11 @@ func InitialRootModel(serverPort int, currentVersion string, service core.Downlo
 	mirrorsInput.Width = InputWidth
 	mirrorsInput.Prompt = ""
 
+	checksumInput := textinput.New()
+	checksumInput.Placeholder = "(optional) sha256:<hex>"
+	checksumInput.Width = InputWidth
+	checksumInput.Prompt = ""
+
 	pwd, _ := os.Getwd()
 
 	// Initialize file picker for directory selection - default to Downloads folder
@@ -253,7 +305,16 @@ func InitialRootModel(serverPort int, currentVersion string, service core.Downlo
 					// Always resume queued items
 					dm.pendingResume = true
 					dm.paused = true // Will update when resume event received
+				case "scheduled":
+					// Hand scheduled items back to the pool, which holds them until they are due
+					dm.pendingResume = true
+					dm.paused = true
+					if s.ScheduledAt > 0 {
+						dm.ScheduledAt = time.Unix(s.ScheduledAt, 0)
+					}
 				}
+				dm.SpeedLimit = s.SpeedLimit
+				dm.Category = s.Category
 
 				if s.TotalSize > 0 {
 					dm.progress.SetPercent(s.Progress / 100.0)
@@ -283,9 +344,15 @@ func InitialRootModel(serverPort int, currentVersion string, service core.Downlo
 	searchInput.Width = 30
 	searchInput.Prompt = ""
 
+	// Initialize speed limit input
+	limitInput := textinput.New()
+	limitInput.Placeholder = "e.g. 2MB, 500K, 0 = unlimited"
+	limitInput.Width = 30
+	limitInput.Prompt = ""
+
 	m := RootModel{
 		downloads:             downloads,
-		inputs:                []textinput.Model{urlInput, mirrorsInput, pathInput, filenameInput},
+		inputs:                []textinput.Model{urlInput, mirrorsInput, pathInput, filenameInput, checksumInput},
 		state:                 DashboardState,
 		filepicker:            fp,
 		help:                  helpModel,
@@ -298,6 +365,7 @@ func InitialRootModel(serverPort int, currentVersion string, service core.Downlo
 		Settings:              settings,
 		SettingsInput:         settingsInput,
 		searchInput:           searchInput,
+		limitInput:            limitInput,
 		keys:                  Keys,
 		ServerPort:            serverPort,
 		CurrentVersion:        currentVersion,
@@ -382,6 +450,10 @@ func (m RootModel) getFilteredDownloads() []*DownloadModel {
 			}
 		}
 
+		if m.categoryFilter != "" && !strings.EqualFold(d.Category, m.categoryFilter) {
+			continue
+		}
+
 		// Apply search filter if query is set
 		if m.searchQuery != "" {
 			if !strings.Contains(strings.ToLower(d.FilenameLower), searchLower) {
@@ -8,6 +8,7 @@ import (
 	"github.com/surge-downloader/surge/internal/config"
 	"github.com/surge-downloader/surge/internal/core"
 	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/utils"
 )
 
@@ -31,7 +32,7 @@ func TestStartDownload_EnforcesAbsolutePath(t *testing.T) {
 	relPath := "subdir"
 	url := "http://example.com/file.zip"
 
-	m, _ = m.startDownload(url, nil, nil, relPath, "file.zip", "test-id-1")
+	m, _ = m.startDownload(url, nil, nil, relPath, "file.zip", "test-id-1", types.DownloadOptions{})
 
 	// We expect the new download to be appended
 	if len(m.downloads) != 1 {
@@ -82,6 +82,9 @@ func (r *ProgressReporter) PollCmd() tea.Cmd {
 			Speed:             r.lastSpeed,
 			Elapsed:           totalElapsed, // Send total elapsed for UI
 			ActiveConnections: int(connections),
+			RateLimitedFor:    r.state.RateLimitRemaining(),
+			SpeedLimit:        r.state.SpeedLimit.Limit(),
+			Mirrors:           r.state.GetMirrors(),
 		}
 	})
 }
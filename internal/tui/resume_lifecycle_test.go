@@ -62,7 +62,7 @@ func TestResume_RespectsOriginalPath_WhenDefaultChanges(t *testing.T) {
 	testFilename := "file.zip"
 
 	// Start download with relative path "."
-	m, _ = m.startDownload(testURL, nil, nil, ".", testFilename, "id-1")
+	m, _ = m.startDownload(testURL, nil, nil, ".", testFilename, "id-1", types.DownloadOptions{})
 
 	// 4. Verify Immediate State
 	if len(m.downloads) != 1 {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/download"
 	"github.com/surge-downloader/surge/internal/tui/components"
 
 	"github.com/charmbracelet/lipgloss"
@@ -257,6 +258,11 @@ func (m RootModel) getSettingsValues(category string) map[string]interface{} {
 		values["clipboard_monitor"] = m.Settings.General.ClipboardMonitor
 		values["theme"] = m.Settings.General.Theme
 		values["log_retention_count"] = m.Settings.General.LogRetentionCount
+		values["active_windows"] = strings.Join(m.Settings.General.ActiveWindows, ", ")
+		values["extract_archives"] = m.Settings.General.ExtractArchives
+		values["delete_archives_after_extract"] = m.Settings.General.DeleteArchives
+		values["preallocate_files"] = m.Settings.General.PreallocateFiles
+		values["min_free_space"] = m.Settings.General.MinFreeSpace
 
 	case "Network":
 		values["max_connections_per_host"] = m.Settings.Network.MaxConnectionsPerHost
@@ -266,6 +272,16 @@ func (m RootModel) getSettingsValues(category string) map[string]interface{} {
 		values["sequential_download"] = m.Settings.Network.SequentialDownload
 		values["min_chunk_size"] = m.Settings.Network.MinChunkSize
 		values["worker_buffer_size"] = m.Settings.Network.WorkerBufferSize
+		values["global_speed_limit"] = m.Settings.Network.GlobalSpeedLimit
+		values["ssh_key_file"] = m.Settings.Network.SSHKeyFile
+		values["ssh_known_hosts"] = m.Settings.Network.SSHKnownHostsFile
+		values["torrent_listen_port"] = m.Settings.Network.TorrentListenPort
+		values["seed_ratio"] = m.Settings.Network.SeedRatio
+		values["seed_time_limit"] = m.Settings.Network.SeedTimeLimit
+		values["torrent_upload_limit"] = m.Settings.Network.TorrentUploadLimit
+		values["daemon_tls"] = m.Settings.Network.DaemonTLS
+		values["daemon_tls_cert"] = m.Settings.Network.DaemonTLSCert
+		values["daemon_tls_key"] = m.Settings.Network.DaemonTLSKey
 	case "Performance":
 		values["max_task_retries"] = m.Settings.Performance.MaxTaskRetries
 		values["slow_worker_threshold"] = m.Settings.Performance.SlowWorkerThreshold
@@ -348,6 +364,28 @@ func (m *RootModel) setGeneralSetting(key, value, typ string) error {
 			}
 			m.Settings.General.LogRetentionCount = v
 		}
+	case "active_windows":
+		var windows []string
+		for _, spec := range strings.Split(value, ",") {
+			if spec = strings.TrimSpace(spec); spec != "" {
+				windows = append(windows, spec)
+			}
+		}
+		if _, err := download.ParseWindows(windows); err != nil {
+			return nil // Invalid value
+		}
+		m.Settings.General.ActiveWindows = windows
+	case "extract_archives":
+		m.Settings.General.ExtractArchives = !m.Settings.General.ExtractArchives
+	case "delete_archives_after_extract":
+		m.Settings.General.DeleteArchives = !m.Settings.General.DeleteArchives
+	case "preallocate_files":
+		m.Settings.General.PreallocateFiles = !m.Settings.General.PreallocateFiles
+	case "min_free_space":
+		// Parse as MB and convert to bytes
+		if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 {
+			m.Settings.General.MinFreeSpace = int64(v * 1024 * 1024)
+		}
 	}
 	return nil
 }
@@ -389,6 +427,42 @@ func (m *RootModel) setNetworkSetting(key, value, typ string) error {
 		if v, err := strconv.ParseFloat(value, 64); err == nil {
 			m.Settings.Network.WorkerBufferSize = int(v * 1024)
 		}
+	case "global_speed_limit":
+		// Parse as MB/s and convert to bytes per second
+		if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 {
+			m.Settings.Network.GlobalSpeedLimit = int64(v * 1024 * 1024)
+		}
+	case "ssh_key_file":
+		m.Settings.Network.SSHKeyFile = value
+	case "ssh_known_hosts":
+		m.Settings.Network.SSHKnownHostsFile = value
+	case "torrent_listen_port":
+		if v, err := strconv.Atoi(value); err == nil && v >= 1 && v <= 65535 {
+			m.Settings.Network.TorrentListenPort = v
+		}
+	case "seed_ratio":
+		if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 {
+			m.Settings.Network.SeedRatio = v
+		}
+	case "seed_time_limit":
+		// Check if it's just a number, if so treat it as minutes
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			value += "m"
+		}
+		if v, err := time.ParseDuration(value); err == nil && v >= 0 {
+			m.Settings.Network.SeedTimeLimit = v
+		}
+	case "torrent_upload_limit":
+		// Parse as MB/s and convert to bytes per second
+		if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 {
+			m.Settings.Network.TorrentUploadLimit = int64(v * 1024 * 1024)
+		}
+	case "daemon_tls":
+		m.Settings.Network.DaemonTLS = !m.Settings.Network.DaemonTLS
+	case "daemon_tls_cert":
+		m.Settings.Network.DaemonTLSCert = value
+	case "daemon_tls_key":
+		m.Settings.Network.DaemonTLSKey = value
 	}
 	return nil
 }
@@ -495,10 +569,14 @@ func (m RootModel) getSettingsCount() int {
 func (m RootModel) getSettingUnit() string {
 	key := m.getCurrentSettingKey()
 	switch key {
-	case "min_chunk_size":
+	case "min_chunk_size", "min_free_space":
 		return " MB"
 	case "worker_buffer_size":
 		return " KB"
+	case "global_speed_limit", "torrent_upload_limit":
+		return " MB/s"
+	case "seed_time_limit":
+		return " minutes"
 	case "max_task_retries":
 		return " retries"
 	case "slow_worker_grace_period", "stall_timeout":
@@ -513,7 +591,7 @@ func (m RootModel) getSettingUnit() string {
 // formatSettingValueForEdit returns a plain value without units for editing
 func formatSettingValueForEdit(value interface{}, typ, key string) string {
 	switch key {
-	case "min_chunk_size":
+	case "min_chunk_size", "min_free_space", "global_speed_limit", "torrent_upload_limit":
 		if v, ok := value.(int64); ok {
 			mb := float64(v) / (1024 * 1024)
 			return fmt.Sprintf("%.1f", mb)
@@ -529,6 +607,10 @@ func formatSettingValueForEdit(value interface{}, typ, key string) string {
 		if d, ok := value.(time.Duration); ok {
 			return fmt.Sprintf("%.0f", d.Seconds())
 		}
+	case "seed_time_limit":
+		if d, ok := value.(time.Duration); ok {
+			return fmt.Sprintf("%.0f", d.Minutes())
+		}
 	}
 
 	if key == "theme" {
@@ -626,6 +708,16 @@ func (m *RootModel) resetSettingToDefault(category, key string, defaults *config
 			m.Settings.General.Theme = defaults.General.Theme
 		case "log_retention_count":
 			m.Settings.General.LogRetentionCount = defaults.General.LogRetentionCount
+		case "active_windows":
+			m.Settings.General.ActiveWindows = defaults.General.ActiveWindows
+		case "extract_archives":
+			m.Settings.General.ExtractArchives = defaults.General.ExtractArchives
+		case "delete_archives_after_extract":
+			m.Settings.General.DeleteArchives = defaults.General.DeleteArchives
+		case "preallocate_files":
+			m.Settings.General.PreallocateFiles = defaults.General.PreallocateFiles
+		case "min_free_space":
+			m.Settings.General.MinFreeSpace = defaults.General.MinFreeSpace
 		}
 
 	case "Network":
@@ -644,6 +736,26 @@ func (m *RootModel) resetSettingToDefault(category, key string, defaults *config
 			m.Settings.Network.MinChunkSize = defaults.Network.MinChunkSize
 		case "worker_buffer_size":
 			m.Settings.Network.WorkerBufferSize = defaults.Network.WorkerBufferSize
+		case "global_speed_limit":
+			m.Settings.Network.GlobalSpeedLimit = defaults.Network.GlobalSpeedLimit
+		case "ssh_key_file":
+			m.Settings.Network.SSHKeyFile = defaults.Network.SSHKeyFile
+		case "ssh_known_hosts":
+			m.Settings.Network.SSHKnownHostsFile = defaults.Network.SSHKnownHostsFile
+		case "torrent_listen_port":
+			m.Settings.Network.TorrentListenPort = defaults.Network.TorrentListenPort
+		case "seed_ratio":
+			m.Settings.Network.SeedRatio = defaults.Network.SeedRatio
+		case "seed_time_limit":
+			m.Settings.Network.SeedTimeLimit = defaults.Network.SeedTimeLimit
+		case "torrent_upload_limit":
+			m.Settings.Network.TorrentUploadLimit = defaults.Network.TorrentUploadLimit
+		case "daemon_tls":
+			m.Settings.Network.DaemonTLS = defaults.Network.DaemonTLS
+		case "daemon_tls_cert":
+			m.Settings.Network.DaemonTLSCert = defaults.Network.DaemonTLSCert
+		case "daemon_tls_key":
+			m.Settings.Network.DaemonTLSKey = defaults.Network.DaemonTLSKey
 		}
 	case "Performance":
 		switch key {
@@ -2,16 +2,20 @@ package tui
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/surge-downloader/surge/internal/clipboard"
 	"github.com/surge-downloader/surge/internal/config"
+	"github.com/surge-downloader/surge/internal/download"
+	"github.com/surge-downloader/surge/internal/engine/checksum"
 	"github.com/surge-downloader/surge/internal/engine/events"
 	"github.com/surge-downloader/surge/internal/engine/state"
 	"github.com/surge-downloader/surge/internal/engine/types"
@@ -21,6 +25,7 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -136,6 +141,40 @@ func (m *RootModel) removeDownloadByID(id string) bool {
 	return false
 }
 
+// moveQueued mirrors a queue move in the list: d trades places with the next
+// waiting download above or below it, or goes before the first for MoveTop
+func (m *RootModel) moveQueued(d *DownloadModel, direction string) {
+	var waiting []*DownloadModel
+	for _, w := range m.downloads {
+		if w == d || (!w.done && !w.paused && w.Speed == 0 && w.ScheduledAt.IsZero()) {
+			waiting = append(waiting, w)
+		}
+	}
+	i := slices.Index(waiting, d)
+	var anchor *DownloadModel
+	after := false
+	switch {
+	case i < 0:
+		return
+	case direction == download.MoveTop && i > 0:
+		anchor = waiting[0]
+	case direction == download.MoveUp && i > 0:
+		anchor = waiting[i-1]
+	case direction == download.MoveDown && i < len(waiting)-1:
+		anchor = waiting[i+1]
+		after = true
+	default:
+		return
+	}
+
+	m.downloads = slices.DeleteFunc(m.downloads, func(x *DownloadModel) bool { return x == d })
+	j := slices.Index(m.downloads, anchor)
+	if after {
+		j++
+	}
+	m.downloads = slices.Insert(m.downloads, j, d)
+}
+
 // checkForDuplicate checks if a compatible download already exists
 func (m RootModel) checkForDuplicate(url string) *DownloadModel {
 	if !m.Settings.General.WarnOnDuplicate {
@@ -156,7 +195,7 @@ func (m RootModel) checkForDuplicate(url string) *DownloadModel {
 }
 
 // startDownload initiates a new download
-func (m RootModel) startDownload(url string, mirrors []string, headers map[string]string, path, filename, id string) (RootModel, tea.Cmd) {
+func (m RootModel) startDownload(url string, mirrors []string, headers map[string]string, path, filename, id string, opts types.DownloadOptions) (RootModel, tea.Cmd) {
 	if m.Service == nil {
 		m.addLogEntry(LogStyleError.Render("✖ Service unavailable"))
 		return m, nil
@@ -175,7 +214,7 @@ func (m RootModel) startDownload(url string, mirrors []string, headers map[strin
 	// We rely on the event stream to update the UI, OR we add it optimistically.
 	// Optimistic addition gives better UX.
 
-	newID, err := m.Service.Add(url, path, finalFilename, mirrors, headers)
+	newID, err := m.Service.Add(url, path, finalFilename, mirrors, headers, opts)
 	if err != nil {
 		m.addLogEntry(LogStyleError.Render("✖ Failed to add download: " + err.Error()))
 		return m, nil
@@ -184,6 +223,9 @@ func (m RootModel) startDownload(url string, mirrors []string, headers map[strin
 	// Create optimistic model
 	newDownload := NewDownloadModel(newID, url, "Queued", 0)
 	newDownload.Destination = filepath.Join(path, finalFilename)
+	if c := config.FindCategory(m.Settings.Categories, opts.Category); c != nil {
+		newDownload.Category = c.Name
+	}
 	m.downloads = append(m.downloads, newDownload)
 
 	m.SelectedDownloadID = newID
@@ -229,6 +271,20 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		opts := types.DownloadOptions{
+			Checksum:   msg.Checksum,
+			StartAt:    msg.StartAt,
+			Window:     msg.Window,
+			SpeedLimit: msg.SpeedLimit,
+			Extract:    msg.Extract,
+			Variant:    msg.Variant,
+			Priority:   msg.Priority,
+			Category:   msg.Category,
+			DefaultDir: msg.DefaultDir || msg.Path == "",
+
+			ExpectedSize: msg.ExpectedSize,
+		}
+
 		duplicate := m.checkForDuplicate(msg.URL)
 
 		if duplicate != nil && m.Settings.General.WarnOnDuplicate {
@@ -238,6 +294,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pendingHeaders = msg.Headers
 			m.pendingPath = path
 			m.pendingFilename = msg.Filename
+			m.pendingOptions = opts
 			m.duplicateInfo = duplicate.Filename
 			m.state = DuplicateWarningState
 			return m, nil
@@ -249,11 +306,12 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pendingHeaders = msg.Headers
 			m.pendingPath = path
 			m.pendingFilename = msg.Filename
+			m.pendingOptions = opts
 			m.state = ExtensionConfirmationState
 			return m, nil
 		}
 
-		return m.startDownload(msg.URL, msg.Mirrors, msg.Headers, path, msg.Filename, msg.ID)
+		return m.startDownload(msg.URL, msg.Mirrors, msg.Headers, path, msg.Filename, msg.ID, opts)
 
 	case events.DownloadStartedMsg:
 		found := false
@@ -263,7 +321,11 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				d.FilenameLower = strings.ToLower(msg.Filename)
 				d.Total = msg.Total
 				d.Destination = msg.DestPath
+				if msg.Category != "" {
+					d.Category = msg.Category
+				}
 				d.StartTime = time.Now()
+				d.ScheduledAt = time.Time{}
 				d.paused = false
 				d.pausing = false
 				d.pendingResume = false
@@ -285,6 +347,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if !found {
 			newDownload := NewDownloadModel(msg.DownloadID, msg.URL, msg.Filename, msg.Total)
 			newDownload.Destination = msg.DestPath
+			newDownload.Category = msg.Category
 			if msg.State != nil {
 				newDownload.state = msg.State
 			}
@@ -307,6 +370,11 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				d.Speed = msg.Speed
 				d.Elapsed = msg.Elapsed
 				d.Connections = msg.ActiveConnections
+				d.RateLimitedFor = msg.RateLimitedFor
+				d.SpeedLimit = msg.SpeedLimit
+				if d.state != nil && len(msg.Mirrors) > 0 {
+					d.state.SetMirrors(msg.Mirrors)
+				}
 
 				// Update Chunk State if provided
 				if msg.BitmapWidth > 0 && len(msg.ChunkBitmap) > 0 {
@@ -384,7 +452,11 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if d.ID == msg.DownloadID {
 				d.err = msg.Err
 				d.done = true
-				m.addLogEntry(LogStyleError.Render("✖ Error: " + d.Filename))
+				if errors.Is(msg.Err, types.ErrChecksumMismatch) {
+					m.addLogEntry(LogStyleError.Render("✖ Verification failed: " + d.Filename))
+				} else {
+					m.addLogEntry(LogStyleError.Render("✖ Error: " + d.Filename))
+				}
 				break
 			}
 		}
@@ -399,7 +471,11 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				d.pendingResume = false
 				d.Downloaded = msg.Downloaded
 				d.Speed = 0
-				m.addLogEntry(LogStylePaused.Render("⏸ Paused: " + d.Filename))
+				if msg.Reason != "" {
+					m.addLogEntry(LogStylePaused.Render("⏸ Paused (" + msg.Reason + "): " + d.Filename))
+				} else {
+					m.addLogEntry(LogStylePaused.Render("⏸ Paused: " + d.Filename))
+				}
 				break
 			}
 		}
@@ -424,6 +500,9 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		found := false
 		for _, d := range m.downloads {
 			if d.ID == msg.DownloadID {
+				// Back in the queue, e.g. after making room for a higher-priority download
+				d.paused = false
+				d.pausing = false
 				found = true
 				break
 			}
@@ -432,8 +511,88 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Add placeholder
 			newDownload := NewDownloadModel(msg.DownloadID, "", msg.Filename, 0)
 			m.downloads = append(m.downloads, newDownload)
-			m.UpdateListItems()
 		}
+		m.UpdateListItems()
+		return m, tea.Batch(cmds...)
+
+	case events.HookResultMsg:
+		name := msg.Filename
+		if name == "" {
+			name = msg.DownloadID
+		}
+		if msg.Err != "" {
+			m.addLogEntry(LogStyleError.Render(fmt.Sprintf("✖ Hook %s failed for %s: %s", msg.Hook, name, msg.Err)))
+		} else {
+			m.addLogEntry(LogStyleComplete.Render(fmt.Sprintf("⚙ Hook %s finished for %s (status %d)", msg.Hook, name, msg.ExitCode)))
+		}
+		return m, nil
+
+	case events.ExtractProgressMsg:
+		for _, d := range m.downloads {
+			if d.ID == msg.DownloadID {
+				if !d.extracting {
+					d.extracting = true
+					m.addLogEntry(LogStyleStarted.Render("📦 Extracting: " + d.Filename))
+				}
+				if msg.Total > 0 {
+					d.extractPct = float64(msg.Done) / float64(msg.Total) * 100
+				}
+				break
+			}
+		}
+		m.UpdateListItems()
+		return m, nil
+
+	case events.ExtractCompleteMsg:
+		for _, d := range m.downloads {
+			if d.ID == msg.DownloadID {
+				d.extracting = false
+				break
+			}
+		}
+		line := fmt.Sprintf("📦 Extracted: %s → %s (%d files)", msg.Filename, msg.Dir, msg.Files)
+		if msg.ArchiveDeleted {
+			line += ", archive deleted"
+		}
+		m.addLogEntry(LogStyleComplete.Render(line))
+		m.UpdateListItems()
+		return m, nil
+
+	case events.ExtractErrorMsg:
+		for _, d := range m.downloads {
+			if d.ID == msg.DownloadID {
+				d.extracting = false
+				break
+			}
+		}
+		m.addLogEntry(LogStyleError.Render(fmt.Sprintf("✖ Extract failed: %s: %s", msg.Filename, msg.Err)))
+		m.UpdateListItems()
+		return m, nil
+
+	case events.DownloadScheduledMsg:
+		var target *DownloadModel
+		for _, d := range m.downloads {
+			if d.ID == msg.DownloadID {
+				target = d
+				break
+			}
+		}
+		if target == nil {
+			target = NewDownloadModel(msg.DownloadID, "", msg.Filename, 0)
+			m.downloads = append(m.downloads, target)
+		}
+		if target.Filename == "" && msg.Filename != "" {
+			target.Filename = msg.Filename
+			target.FilenameLower = strings.ToLower(msg.Filename)
+		}
+		target.ScheduledAt = msg.StartAt
+		target.pendingResume = false
+		name := target.Filename
+		if name == "" {
+			name = target.URL
+		}
+		m.addLogEntry(LogStylePaused.Render("⏰ Scheduled: " + name + " (starts " + msg.StartAt.Format("Mon 15:04") + ")"))
+		m.UpdateListItems()
 		return m, tea.Batch(cmds...)
 
 	case events.DownloadRemovedMsg:
@@ -578,6 +737,14 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+			// Cycle the category filter
+			if key.Matches(msg, m.keys.Dashboard.Category) {
+				m.nextCategoryFilter()
+				m.updateListTitle()
+				m.UpdateListItems()
+				return m, nil
+			}
+
 			// Tab switching
 			if key.Matches(msg, m.keys.Dashboard.TabQueued) {
 				m.activeTab = TabQueued
@@ -632,6 +799,8 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.inputs[3].Blur()
 				m.inputs[1].SetValue("") // Clear mirrors
 				m.inputs[1].Blur()
+				m.inputs[4].SetValue("") // Clear checksum
+				m.inputs[4].Blur()
 
 				if m.Settings.General.ClipboardMonitor {
 					if url := clipboard.ReadURL(); url != "" {
@@ -723,6 +892,29 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+			// Reorder the queue
+			if key.Matches(msg, m.keys.Dashboard.MoveUp, m.keys.Dashboard.MoveDown, m.keys.Dashboard.MoveTop) && m.activeTab == TabQueued {
+				if d := m.GetSelectedDownload(); d != nil {
+					if m.Service == nil {
+						m.addLogEntry(LogStyleError.Render("✖ Service unavailable"))
+						return m, nil
+					}
+					direction := download.MoveUp
+					if key.Matches(msg, m.keys.Dashboard.MoveDown) {
+						direction = download.MoveDown
+					} else if key.Matches(msg, m.keys.Dashboard.MoveTop) {
+						direction = download.MoveTop
+					}
+					if err := m.Service.Move(d.ID, direction); err != nil {
+						m.addLogEntry(LogStyleError.Render("✖ Move failed: " + err.Error()))
+					} else {
+						m.moveQueued(d, direction)
+					}
+				}
+				m.UpdateListItems()
+				return m, nil
+			}
+
 			// Open file
 			if key.Matches(msg, m.keys.Dashboard.OpenFile) {
 				if d := m.GetSelectedDownload(); d != nil {
@@ -738,6 +930,21 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+			// Speed limit for the selected download
+			if key.Matches(msg, m.keys.Dashboard.SpeedLimit) {
+				if d := m.GetSelectedDownload(); d != nil && !d.done {
+					m.limitTargetID = d.ID
+					m.limitInput.SetValue("")
+					if d.SpeedLimit > 0 {
+						m.limitInput.SetValue(fmt.Sprintf("%dK", d.SpeedLimit/1024))
+					}
+					m.limitInput.Focus()
+					m.state = SpeedLimitState
+					return m, textinput.Blink
+				}
+				return m, nil
+			}
+
 			// Other keys...
 			if key.Matches(msg, m.keys.Dashboard.Log) {
 				m.logFocused = !m.logFocused
@@ -813,8 +1020,8 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, m.filepicker.Init()
 			}
 			if key.Matches(msg, m.keys.Input.Enter) {
-				// Navigate through inputs: URL -> Mirrors -> Path -> Filename -> Start
-				if m.focusedInput < 3 {
+				// Navigate through inputs: URL -> Mirrors -> Path -> Filename -> Checksum -> Start
+				if m.focusedInput < len(m.inputs)-1 {
 					m.inputs[m.focusedInput].Blur()
 					m.focusedInput++
 					m.inputs[m.focusedInput].Focus()
@@ -829,6 +1036,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.inputs[1].Blur()
 					m.inputs[2].Blur()
 					m.inputs[3].Blur()
+					m.inputs[4].Blur()
 					return m, nil
 				}
 
@@ -879,6 +1087,20 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				filename := m.inputs[3].Value()
 
+				// A category's directory replaces the default one, not a directory chosen here
+				opts := types.DownloadOptions{DefaultDir: path == m.Settings.General.DefaultDownloadDir || m.inputs[2].Value() == ""}
+				if raw := strings.TrimSpace(m.inputs[4].Value()); raw != "" {
+					spec, err := checksum.Parse(raw)
+					if err != nil {
+						m.addLogEntry(LogStyleError.Render("✖ Invalid checksum: " + err.Error()))
+						m.inputs[m.focusedInput].Blur()
+						m.focusedInput = 4
+						m.inputs[4].Focus()
+						return m, nil
+					}
+					opts.Checksum = spec.String()
+				}
+
 				// Check for duplicate URL
 				if d := m.checkForDuplicate(url); d != nil {
 					m.pendingURL = url
@@ -886,6 +1108,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.pendingHeaders = nil
 					m.pendingPath = path
 					m.pendingFilename = filename
+					m.pendingOptions = opts
 					m.duplicateInfo = d.Filename
 					m.state = DuplicateWarningState
 					return m, nil
@@ -897,8 +1120,9 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.inputs[1].SetValue("")
 				m.inputs[2].SetValue(path) // Keep path
 				m.inputs[3].SetValue("")
+				m.inputs[4].SetValue("")
 
-				return m.startDownload(url, mirrors, nil, path, filename, "")
+				return m.startDownload(url, mirrors, nil, path, filename, "", opts)
 			}
 
 			// Up/Down navigation between inputs
@@ -908,7 +1132,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.inputs[m.focusedInput].Focus()
 				return m, nil
 			}
-			if key.Matches(msg, m.keys.Input.Down) && m.focusedInput < 3 {
+			if key.Matches(msg, m.keys.Input.Down) && m.focusedInput < len(m.inputs)-1 {
 				m.inputs[m.focusedInput].Blur()
 				m.focusedInput++
 				m.inputs[m.focusedInput].Focus()
@@ -1009,7 +1233,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if key.Matches(msg, m.keys.Duplicate.Continue) {
 				// Continue anyway - startDownload handles unique filename generation
 				m.state = DashboardState
-				return m.startDownload(m.pendingURL, m.pendingMirrors, m.pendingHeaders, m.pendingPath, m.pendingFilename, "")
+				return m.startDownload(m.pendingURL, m.pendingMirrors, m.pendingHeaders, m.pendingPath, m.pendingFilename, "", m.pendingOptions)
 			}
 			if key.Matches(msg, m.keys.Duplicate.Cancel) {
 				// Cancel - don't add
@@ -1041,7 +1265,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// No duplicate (or warning disabled) - add to queue
 				m.state = DashboardState
-				return m.startDownload(m.pendingURL, nil, m.pendingHeaders, m.pendingPath, m.pendingFilename, "")
+				return m.startDownload(m.pendingURL, nil, m.pendingHeaders, m.pendingPath, m.pendingFilename, "", m.pendingOptions)
 			}
 			if key.Matches(msg, m.keys.Extension.No) {
 				// Cancelled
@@ -1122,7 +1346,7 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						skipped++
 						continue
 					}
-					m, _ = m.startDownload(url, nil, nil, path, "", "")
+					m, _ = m.startDownload(url, nil, nil, path, "", "", types.DownloadOptions{DefaultDir: true})
 					added++
 				}
 
@@ -1144,6 +1368,43 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case SpeedLimitState:
+			if key.Matches(msg, m.keys.SettingsEditor.Cancel) {
+				m.limitInput.Blur()
+				m.limitTargetID = ""
+				m.state = DashboardState
+				return m, nil
+			}
+			if key.Matches(msg, m.keys.SettingsEditor.Confirm) {
+				limit, err := utils.ParseBytes(m.limitInput.Value())
+				if err != nil {
+					m.addLogEntry(LogStyleError.Render("✖ Invalid speed limit: " + err.Error()))
+					return m, nil
+				}
+				if m.Service == nil {
+					m.addLogEntry(LogStyleError.Render("✖ Service unavailable"))
+				} else if err := m.Service.SetSpeedLimit(m.limitTargetID, limit); err != nil {
+					m.addLogEntry(LogStyleError.Render("✖ Speed limit failed: " + err.Error()))
+				} else {
+					for _, d := range m.downloads {
+						if d.ID == m.limitTargetID {
+							d.SpeedLimit = limit
+							m.addLogEntry(LogStyleStarted.Render(fmt.Sprintf("⚙ Speed limit for %s: %s", d.Filename, d.speedLimitLabel())))
+							break
+						}
+					}
+				}
+				m.limitInput.Blur()
+				m.limitTargetID = ""
+				m.state = DashboardState
+				m.UpdateListItems()
+				return m, nil
+			}
+
+			var cmd tea.Cmd
+			m.limitInput, cmd = m.limitInput.Update(msg)
+			return m, cmd
+
 		case SettingsState:
 			categoryCount := len(config.CategoryOrder())
 			if categoryCount == 0 {
@@ -1179,6 +1440,16 @@ func (m RootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if key.Matches(msg, m.keys.Settings.Close) {
 				// Save settings and exit
 				_ = config.SaveSettings(m.Settings)
+				// The global speed limit applies to running downloads without a restart
+				if m.Service != nil {
+					if err := m.Service.SetSpeedLimit("", m.Settings.Network.GlobalSpeedLimit); err != nil {
+						m.addLogEntry(LogStyleError.Render("✖ Global speed limit failed: " + err.Error()))
+					}
+					// So do active windows
+					if err := m.Service.SetActiveWindows(m.Settings.General.ActiveWindows); err != nil {
+						m.addLogEntry(LogStyleError.Render("✖ Active windows failed: " + err.Error()))
+					}
+				}
 				m.state = DashboardState
 				return m, nil
 			}
@@ -1364,6 +1635,31 @@ func (m *RootModel) updateListTitle() {
 	case TabDone:
 		m.list.Title = "✅ Completed"
 	}
+	if m.categoryFilter != "" {
+		m.list.Title += " · " + m.categoryFilter
+	}
+}
+
+// nextCategoryFilter steps the category filter through the categories in
+// settings, then back to listing every download
+func (m *RootModel) nextCategoryFilter() {
+	categories := m.Settings.Categories
+	if len(categories) == 0 {
+		m.categoryFilter = ""
+		m.addLogEntry(LogStyleError.Render("✖ No categories configured in settings.json"))
+		return
+	}
+	i := -1 // Showing all: the first category comes next
+	if m.categoryFilter != "" {
+		i = slices.IndexFunc(categories, func(c config.CategorySettings) bool {
+			return strings.EqualFold(c.Name, m.categoryFilter)
+		})
+	}
+	if i+1 < len(categories) {
+		m.categoryFilter = categories[i+1].Name
+	} else {
+		m.categoryFilter = ""
+	}
 }
 
 // generateUniqueFilename creates a unique filename by appending (1), (2), etc.
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/charmbracelet/bubbles/viewport"
@@ -322,6 +324,67 @@ func TestUpdate_DownloadRemoved_NoOpWhenUnknownID(t *testing.T) {
 	}
 }
 
+func TestMoveQueued(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		direction string
+		want      string
+	}{
+		{"Up skips paused", "b", download.MoveUp, "b,a,p,c,done"},
+		{"Down skips paused", "a", download.MoveDown, "p,b,a,c,done"},
+		{"Top", "c", download.MoveTop, "c,a,p,b,done"},
+		{"Up when first", "a", download.MoveUp, "a,p,b,c,done"},
+		{"Down when last", "c", download.MoveDown, "a,p,b,c,done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m RootModel
+			for _, id := range []string{"a", "p", "b", "c", "done"} {
+				m.downloads = append(m.downloads, NewDownloadModel(id, "http://example.com/"+id, id, 100))
+			}
+			m.downloads[1].paused = true
+			m.downloads[4].done = true
+
+			i := slices.IndexFunc(m.downloads, func(d *DownloadModel) bool { return d.ID == tt.id })
+			m.moveQueued(m.downloads[i], tt.direction)
+
+			var ids []string
+			for _, d := range m.downloads {
+				ids = append(ids, d.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryFilter(t *testing.T) {
+	m := RootModel{Settings: config.DefaultSettings(), activeTab: TabQueued}
+	m.Settings.Categories = []config.CategorySettings{{Name: "Video"}, {Name: "ISOs"}}
+	for id, category := range map[string]string{"movie": "Video", "distro": "ISOs", "other": ""} {
+		d := NewDownloadModel(id, "http://example.com/"+id, id, 100)
+		d.Category = category
+		m.downloads = append(m.downloads, d)
+	}
+
+	var got []string
+	for range 3 {
+		m.nextCategoryFilter()
+		var ids []string
+		for _, d := range m.getFilteredDownloads() {
+			ids = append(ids, d.ID)
+		}
+		slices.Sort(ids)
+		got = append(got, m.categoryFilter+"="+strings.Join(ids, ","))
+	}
+	want := []string{"Video=movie", "ISOs=distro", "=distro,movie,other"}
+	if !slices.Equal(got, want) {
+		t.Errorf("filters = %v, want %v", got, want)
+	}
+}
+
 func TestGenerateUniqueFilename_EmptyFilename(t *testing.T) {
 	m := &RootModel{}
 	got := m.generateUniqueFilename("/tmp", "")
@@ -2,9 +2,12 @@ package tui
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/surge-downloader/surge/internal/engine/types"
 	"github.com/surge-downloader/surge/internal/tui/components"
 	"github.com/surge-downloader/surge/internal/utils"
 
@@ -56,6 +59,8 @@ func (m RootModel) View() string {
 			pathLine,
 			"", // Spacer
 			lipgloss.JoinHorizontal(lipgloss.Left, labelStyle.Render("Filename:"), m.inputs[3].View()),
+			"", // Spacer
+			lipgloss.JoinHorizontal(lipgloss.Left, labelStyle.Render("Checksum:"), m.inputs[4].View()),
 			"", // Bottom spacer
 			"",
 			// Render dynamic help
@@ -65,7 +70,7 @@ func (m RootModel) View() string {
 		// Apply padding to the content before boxing it
 		paddedContent := lipgloss.NewStyle().Padding(0, 2).Render(content)
 
-		box := renderBtopBox(PaneTitleStyle.Render(" Add Download "), "", paddedContent, 80, 11, ColorNeonPink)
+		box := renderBtopBox(PaneTitleStyle.Render(" Add Download "), "", paddedContent, 80, 13, ColorNeonPink)
 
 		return m.renderModalWithOverlay(box)
 	}
@@ -144,6 +149,30 @@ func (m RootModel) View() string {
 		return m.renderModalWithOverlay(box)
 	}
 
+	if m.state == SpeedLimitState {
+		labelStyle := lipgloss.NewStyle().Width(10).Foreground(ColorLightGray)
+		filename := ""
+		for _, d := range m.downloads {
+			if d.ID == m.limitTargetID {
+				filename = d.Filename
+				break
+			}
+		}
+
+		content := lipgloss.JoinVertical(lipgloss.Left,
+			"",
+			lipgloss.JoinHorizontal(lipgloss.Left, labelStyle.Render("File:"), truncateString(filename, 40)),
+			"",
+			lipgloss.JoinHorizontal(lipgloss.Left, labelStyle.Render("Limit/s:"), m.limitInput.View()),
+			"",
+			m.help.View(m.keys.SettingsEditor),
+		)
+		paddedContent := lipgloss.NewStyle().Padding(0, 2).Render(content)
+
+		box := renderBtopBox(PaneTitleStyle.Render(" Speed Limit "), "", paddedContent, 60, 9, ColorNeonCyan)
+		return m.renderModalWithOverlay(box)
+	}
+
 	if m.state == UpdateAvailableState && m.UpdateInfo != nil {
 		modal := components.ConfirmationModal{
 			Title:       "⬆ Update Available",
@@ -758,6 +787,9 @@ func renderFocusedDetails(d *DownloadModel, w int) string {
 			speedStr = "N/A"
 		}
 		etaStr = "Done"
+	} else if d.isRateLimited() {
+		speedStr = d.rateLimitStatus()
+		etaStr = "∞"
 	} else if d.paused || d.Speed == 0 {
 		speedStr = "Paused"
 		etaStr = "∞"
@@ -795,6 +827,7 @@ func renderFocusedDetails(d *DownloadModel, w int) string {
 	rightCol := lipgloss.JoinVertical(lipgloss.Left,
 		lipgloss.JoinHorizontal(lipgloss.Left, StatsLabelStyle.Width(7).Render("Time:"), StatsValueStyle.Render(timeStr)),
 		lipgloss.JoinHorizontal(lipgloss.Left, StatsLabelStyle.Width(7).Render("ETA:"), StatsValueStyle.Render(etaStr)),
+		lipgloss.JoinHorizontal(lipgloss.Left, StatsLabelStyle.Width(7).Render("Limit:"), StatsValueStyle.Render(d.speedLimitLabel())),
 	)
 
 	statsContent := lipgloss.JoinHorizontal(lipgloss.Top,
@@ -821,7 +854,9 @@ func renderFocusedDetails(d *DownloadModel, w int) string {
 		mirrorLabel := StatsLabelStyle.Render("Mirrors")
 		mirrorStats := lipgloss.NewStyle().Foreground(ColorLightGray).Render(fmt.Sprintf("%d Active / %d Total (%d Errors)", activeCount, total, errorCount))
 
-		mirrorSection = sectionStyle.Render(lipgloss.JoinVertical(lipgloss.Left, mirrorLabel, mirrorStats))
+		lines := []string{mirrorLabel, mirrorStats}
+		lines = append(lines, renderMirrorRows(d.state.GetMirrors(), contentWidth)...)
+		mirrorSection = sectionStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
 	}
 
 	// --- 6. Error Section ---
@@ -860,6 +895,9 @@ func renderFocusedDetails(d *DownloadModel, w int) string {
 }
 
 func getDownloadStatus(d *DownloadModel) string {
+	if d.isScheduled() {
+		return d.scheduledStatus()
+	}
 	status := components.DetermineStatus(d.done, d.paused, d.err != nil, d.Speed, d.Downloaded)
 	return status.Render()
 }
@@ -889,6 +927,53 @@ func (m RootModel) CalculateStats() (active, queued, downloaded int) {
 	return
 }
 
+// maxMirrorRows caps the per-mirror breakdown in the detail pane
+const maxMirrorRows = 5
+
+// renderMirrorRows renders one line per mirror: host, throughput, bytes received,
+// latency and errors. Demoted mirrors are marked and listed after the rest.
+func renderMirrorRows(mirrors []types.MirrorStatus, width int) []string {
+	sort.SliceStable(mirrors, func(i, j int) bool {
+		if mirrors[i].Demoted != mirrors[j].Demoted {
+			return !mirrors[i].Demoted
+		}
+		return mirrors[i].Speed > mirrors[j].Speed
+	})
+
+	var rows []string
+	for i, m := range mirrors {
+		if i == maxMirrorRows {
+			rows = append(rows, lipgloss.NewStyle().Foreground(ColorGray).Render(fmt.Sprintf("  +%d more", len(mirrors)-maxMirrorRows)))
+			break
+		}
+
+		host := m.URL
+		if u, err := url.Parse(m.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		stats := fmt.Sprintf("%s/s  %s", utils.ConvertBytesToHumanReadable(int64(m.Speed)), utils.ConvertBytesToHumanReadable(m.Downloaded))
+		if m.Latency > 0 {
+			stats += fmt.Sprintf("  %dms", m.Latency.Milliseconds())
+		}
+		if m.Errors > 0 {
+			stats += fmt.Sprintf("  %d err", m.Errors)
+		}
+
+		style := lipgloss.NewStyle().Foreground(ColorLightGray)
+		switch {
+		case m.Demoted:
+			stats += "  demoted"
+			style = lipgloss.NewStyle().Foreground(ColorGray)
+		case m.Error && m.Downloaded == 0:
+			style = lipgloss.NewStyle().Foreground(ColorStateError)
+		}
+
+		hostWidth := width - len(stats) - 6
+		rows = append(rows, style.Render("  "+truncateString(host, hostWidth)+"  "+stats))
+	}
+	return rows
+}
+
 func truncateString(s string, i int) string {
 	if i <= 0 {
 		return ""
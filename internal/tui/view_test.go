@@ -4,6 +4,9 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/surge-downloader/surge/internal/engine/types"
 )
 
 var ansiEscapeRE = regexp.MustCompile(`\x1b\[[0-9;]*m`)
@@ -70,3 +73,32 @@ func TestView_NetworkActivityShowsFiveAxisLabelsWhenTall(t *testing.T) {
 		t.Fatalf("expected 5-axis labels (including 0.8 and 0.2 MB/s), got:\n%s", plain)
 	}
 }
+
+func TestRenderMirrorRows(t *testing.T) {
+	mirrors := []types.MirrorStatus{
+		{URL: "https://slow.example.com/f.iso", Active: true, Speed: 10 * types.KB, Demoted: true},
+		{URL: "https://fast.example.com/f.iso", Active: true, Speed: 4 * types.MB, Downloaded: 64 * types.MB, Latency: 45 * time.Millisecond, Errors: 2},
+	}
+
+	rows := renderMirrorRows(mirrors, 80)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	first := ansiEscapeRE.ReplaceAllString(rows[0], "")
+	for _, want := range []string{"fast.example.com", "4.0 MB/s", "64.0 MB", "45ms", "2 err"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("row %q missing %q", first, want)
+		}
+	}
+	if second := ansiEscapeRE.ReplaceAllString(rows[1], ""); !strings.Contains(second, "demoted") {
+		t.Errorf("demoted mirror should be listed last and marked, got %q", second)
+	}
+
+	many := make([]types.MirrorStatus, maxMirrorRows+3)
+	for i := range many {
+		many[i].URL = "https://example.com"
+	}
+	if rows := renderMirrorRows(many, 80); len(rows) != maxMirrorRows+1 {
+		t.Errorf("got %d rows, want %d plus an overflow line", len(rows), maxMirrorRows)
+	}
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 )
 
 // ConvertBytesToHumanReadable converts a given number of bytes into a human-readable format (e.g., KB, MB, GB).
@@ -20,3 +22,42 @@ func ConvertBytesToHumanReadable(bytes int64) string {
 	pre := "KMGTPE"[exp-1]
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/math.Pow(unit, float64(exp)), pre)
 }
+
+// ParseBytes parses a human-readable size such as "5MB", "512k", "1.5 GiB" or "2M/s"
+// into bytes. Units are binary (1K = 1024) and case-insensitive; a bare number is bytes.
+func ParseBytes(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "/S")
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	// Split the numeric prefix from the unit suffix
+	i := 0
+	for i < len(str) && (str[i] >= '0' && str[i] <= '9' || str[i] == '.') {
+		i++
+	}
+	number, unit := str[:i], strings.TrimSpace(str[i:])
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	var multiplier float64
+	switch unit {
+	case "", "B":
+		multiplier = 1
+	case "K", "KB", "KIB":
+		multiplier = 1 << 10
+	case "M", "MB", "MIB":
+		multiplier = 1 << 20
+	case "G", "GB", "GIB":
+		multiplier = 1 << 30
+	default:
+		return 0, fmt.Errorf("invalid size unit %q", unit)
+	}
+
+	return int64(value * multiplier), nil
+}
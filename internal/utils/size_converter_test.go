@@ -84,3 +84,36 @@ func TestConvertBytesToHumanReadable_BoundaryValues(t *testing.T) {
 		})
 	}
 }
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"512B", 512, false},
+		{"500k", 500 * 1024, false},
+		{"5MB", 5 * 1024 * 1024, false},
+		{"1.5 MiB", 1536 * 1024, false},
+		{"2M/s", 2 * 1024 * 1024, false},
+		{" 1gb ", 1024 * 1024 * 1024, false},
+		{"", 0, true},
+		{"fast", 0, true},
+		{"5TB", 0, true},
+		{"-1MB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseBytes(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBytes(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBytes(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+// Package websocket implements the parts of RFC 6455 the daemon and its remote
+// clients need: the opening handshake on both sides, text and binary messages,
+// fragmentation and control frames. Extensions such as permessage-deflate and
+// subprotocols are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types (frame opcodes)
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// Close status codes
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	// DefaultReadLimit caps the size of a received message
+	DefaultReadLimit = 1 << 20
+
+	maxControlPayload = 125
+	writeTimeout      = 10 * time.Second
+)
+
+var (
+	// ErrCloseSent is returned when writing after the close frame went out
+	ErrCloseSent = errors.New("websocket: close sent")
+
+	// ErrReadLimit is returned for a message larger than the read limit
+	ErrReadLimit = errors.New("websocket: message too big")
+
+	errProtocol = errors.New("websocket: protocol error")
+)
+
+// CloseError is returned by ReadMessage once the peer closed the connection
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text != "" {
+		return fmt.Sprintf("websocket: closed with status %d: %s", e.Code, e.Text)
+	}
+	return fmt.Sprintf("websocket: closed with status %d", e.Code)
+}
+
+// Conn is a WebSocket connection. One goroutine may read while others write;
+// writes are serialized.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // Masks outgoing frames and expects unmasked ones
+
+	readLimit   int64
+	idleTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{conn: conn, br: br, client: client, readLimit: DefaultReadLimit}
+}
+
+// SetReadLimit sets the largest message ReadMessage accepts
+func (c *Conn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+// SetIdleTimeout makes reads fail when no frame, pongs included, arrives for d.
+// Zero disables the timeout.
+func (c *Conn) SetIdleTimeout(d time.Duration) {
+	c.idleTimeout = d
+}
+
+// RemoteAddr returns the peer's network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered and
+// pongs skipped on the way; a close from the peer is echoed and returned as a
+// *CloseError.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		msgType int
+		msg     []byte
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.writeFrame(true, PongMessage, payload); err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if msgType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, errProtocol)
+			}
+			msgType, msg = opcode, payload
+		case continuationFrame:
+			if msgType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, errProtocol)
+			}
+			msg = append(msg, payload...)
+		default:
+			return 0, nil, c.fail(CloseProtocolError, errProtocol)
+		}
+
+		if int64(len(msg)) > c.readLimit {
+			return 0, nil, c.fail(CloseTooBig, ErrReadLimit)
+		}
+		if fin {
+			if msgType == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, c.fail(CloseInvalidPayload, errors.New("websocket: invalid UTF-8 in text message"))
+			}
+			return msgType, msg, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it into v
+func (c *Conn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a text or binary message, or a ping or pong
+func (c *Conn) WriteMessage(msgType int, data []byte) error {
+	switch msgType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return errors.New("websocket: control frame payload too long")
+		}
+	default:
+		return fmt.Errorf("websocket: cannot write message type %d", msgType)
+	}
+	return c.writeFrame(true, msgType, data)
+}
+
+// WriteJSON sends v encoded as a text message
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(true, TextMessage, data)
+}
+
+// CloseWithStatus sends a close frame and closes the connection
+func (c *Conn) CloseWithStatus(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	payload = append(payload, reason...)
+	werr := c.writeFrame(true, CloseMessage, payload)
+	cerr := c.conn.Close()
+	if werr != nil && !errors.Is(werr, ErrCloseSent) {
+		return werr
+	}
+	return cerr
+}
+
+// Close closes the connection with a normal closure status
+func (c *Conn) Close() error {
+	return c.CloseWithStatus(CloseNormal, "")
+}
+
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	var reply []byte
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, errProtocol)
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		reply = payload[:2]
+	}
+	_ = c.writeFrame(true, CloseMessage, reply)
+	_ = c.conn.Close()
+	return closeErr
+}
+
+// fail closes the connection with a status after a violation by the peer
+func (c *Conn) fail(code int, err error) error {
+	_ = c.CloseWithStatus(code, "")
+	return err
+}
+
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	if c.idleTimeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	// No extension was negotiated, so the reserved bits must be clear. Clients
+	// mask every frame and servers none.
+	if head[0]&0x70 != 0 || masked == c.client {
+		return false, 0, nil, c.fail(CloseProtocolError, errProtocol)
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= CloseMessage && (!fin || length > maxControlPayload) {
+		return false, 0, nil, c.fail(CloseProtocolError, errProtocol)
+	}
+	if length > uint64(c.readLimit) {
+		return false, 0, nil, c.fail(CloseTooBig, ErrReadLimit)
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *Conn) writeFrame(fin bool, opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, b0, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, b0, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, b0, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(frame); err != nil {
+		return err
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	return nil
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
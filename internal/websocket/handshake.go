@@ -0,0 +1,230 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acceptGUID is the fixed suffix of the handshake key digest
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// IsUpgrade reports whether r asks for a WebSocket connection
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// SameOrigin reports whether r's Origin header names the server r was sent to.
+// Browsers always set Origin on WebSocket handshakes, including cross-site ones.
+func SameOrigin(r *http.Request) bool {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	origin := r.Header.Get("Origin")
+	return origin != "" && strings.EqualFold(origin, scheme+"://"+r.Host)
+}
+
+// Accept completes the opening handshake of a WebSocket request and takes over
+// its connection. On failure it has already answered the request.
+func Accept(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket: handshake method is not GET")
+	}
+	if !IsUpgrade(r) {
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	_ = netConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	_ = netConn.SetDeadline(time.Time{})
+	return newConn(netConn, rw.Reader, false), nil
+}
+
+// HandshakeError is returned by Dial when the server refuses the upgrade
+type HandshakeError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: handshake refused: " + e.Status
+}
+
+// Dialer opens client connections
+type Dialer struct {
+	TLSConfig        *tls.Config   // For wss:// URLs; nil uses the defaults
+	HandshakeTimeout time.Duration // Zero means 30 seconds
+}
+
+// DefaultDialer is used by Dial
+var DefaultDialer = &Dialer{}
+
+// Dial connects to a ws://, wss://, http:// or https:// URL using DefaultDialer
+func Dial(ctx context.Context, rawurl string, header http.Header) (*Conn, error) {
+	return DefaultDialer.Dial(ctx, rawurl, header)
+}
+
+// Dial connects to a ws://, wss://, http:// or https:// URL. header is sent with
+// the handshake request, e.g. for authorization.
+func (d *Dialer) Dial(ctx context.Context, rawurl string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	var secure bool
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme, secure = "https", true
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	hostPort := u.Host
+	if u.Port() == "" {
+		if secure {
+			hostPort = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			hostPort = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	timeout := d.HandshakeTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var netDialer net.Dialer
+	netConn, err := netDialer.DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = netConn.SetDeadline(deadline)
+	if secure {
+		cfg := &tls.Config{}
+		if d.TLSConfig != nil {
+			cfg = d.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(netConn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+
+	conn, err := clientHandshake(netConn, u, header)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	_ = netConn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func clientHandshake(netConn net.Conn, u *url.URL, header http.Header) (*Conn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(netConn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, &HandshakeError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if !headerHasToken(resp.Header, "Upgrade", "websocket") || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("websocket: invalid handshake response")
+	}
+	return newConn(netConn, br, true), nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether a comma-separated header lists token
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer answers every message with the same message
+func newEchoServer(t *testing.T, setup func(*Conn)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		if setup != nil {
+			setup(conn)
+		}
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialTest(t *testing.T, srv *httptest.Server) *Conn {
+	t.Helper()
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestEcho(t *testing.T) {
+	conn := dialTest(t, newEchoServer(t, nil))
+
+	tests := []struct {
+		name    string
+		msgType int
+		data    []byte
+	}{
+		{"Short text", TextMessage, []byte("hello")},
+		{"Empty", BinaryMessage, nil},
+		{"16-bit length", BinaryMessage, bytes.Repeat([]byte{0xab}, 300)},
+		{"64-bit length", BinaryMessage, bytes.Repeat([]byte{0x01, 0x02}, 40000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.WriteMessage(tt.msgType, tt.data); err != nil {
+				t.Fatalf("WriteMessage failed: %v", err)
+			}
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage failed: %v", err)
+			}
+			if msgType != tt.msgType || !bytes.Equal(data, tt.data) {
+				t.Errorf("Echo = type %d, %d bytes; want type %d, %d bytes", msgType, len(data), tt.msgType, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	conn := dialTest(t, newEchoServer(t, nil))
+
+	type msg struct {
+		Type string   `json:"type"`
+		IDs  []string `json:"ids"`
+	}
+	if err := conn.WriteJSON(msg{Type: "subscribe", IDs: []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	var got msg
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "subscribe" || len(got.IDs) != 2 {
+		t.Errorf("ReadJSON = %+v", got)
+	}
+}
+
+func TestFragmentedMessageAndPing(t *testing.T) {
+	conn := dialTest(t, newEchoServer(t, nil))
+
+	// A ping between the fragments is answered without disturbing the message
+	for _, step := range []struct {
+		fin    bool
+		opcode int
+		data   string
+	}{
+		{false, TextMessage, "frag"},
+		{true, PingMessage, "are you there"},
+		{false, continuationFrame, "men"},
+		{true, continuationFrame, "ted"},
+	} {
+		if err := conn.writeFrame(step.fin, step.opcode, []byte(step.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The pong arrives first: read it at the frame level
+	fin, opcode, payload, err := conn.readFrame()
+	if err != nil || !fin || opcode != PongMessage || string(payload) != "are you there" {
+		t.Fatalf("Expected pong, got %v %d %q %v", fin, opcode, payload, err)
+	}
+	msgType, data, err := conn.ReadMessage()
+	if err != nil || msgType != TextMessage || string(data) != "fragmented" {
+		t.Errorf("ReadMessage = %d %q %v", msgType, data, err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	conn := dialTest(t, newEchoServer(t, nil))
+
+	if err := conn.CloseWithStatus(CloseGoingAway, "bye"); err != nil {
+		t.Fatalf("CloseWithStatus failed: %v", err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrCloseSent) {
+		t.Errorf("Write after close = %v, want ErrCloseSent", err)
+	}
+
+	// The server side sees the status
+	closed := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r)
+		if err != nil {
+			closed <- err
+			return
+		}
+		_, _, err = c.ReadMessage()
+		closed <- err
+	}))
+	defer srv.Close()
+	conn = dialTest(t, srv)
+	_ = conn.CloseWithStatus(CloseGoingAway, "bye")
+
+	var closeErr *CloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Errorf("Server read %v, want close 1001 bye", err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	srv := newEchoServer(t, func(c *Conn) { c.SetReadLimit(1000) })
+	conn := dialTest(t, srv)
+
+	if err := conn.WriteMessage(BinaryMessage, make([]byte, 2000)); err != nil {
+		t.Fatal(err)
+	}
+	var closeErr *CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseTooBig {
+		t.Errorf("ReadMessage = %v, want close %d", err, CloseTooBig)
+	}
+}
+
+func TestUnmaskedClientFrameRejected(t *testing.T) {
+	conn := dialTest(t, newEchoServer(t, nil))
+
+	// Pretend to be a server: frames go out unmasked
+	conn.client = false
+	if err := conn.writeFrame(true, TextMessage, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	conn.client = true
+	var closeErr *CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseProtocolError {
+		t.Errorf("ReadMessage = %v, want close %d", err, CloseProtocolError)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		time.Sleep(time.Second)
+		_ = c.Close()
+	}))
+	defer srv.Close()
+	conn := dialTest(t, srv)
+	conn.SetIdleTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("ReadMessage succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Idle timeout took %v", elapsed)
+	}
+}
+
+func TestHandshakeRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"Not a WebSocket endpoint", http.NotFound, http.StatusNotFound},
+		{"Unauthorized", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			_, err := Dial(context.Background(), srv.URL, nil)
+			var hsErr *HandshakeError
+			if !errors.As(err, &hsErr) || hsErr.StatusCode != tt.status {
+				t.Errorf("Dial = %v, want handshake error %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestAccept_RejectsPlainRequests(t *testing.T) {
+	srv := newEchoServer(t, nil)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Plain GET = %d, want 400", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("Old version = %d %q", resp.StatusCode, resp.Header.Get("Sec-WebSocket-Version"))
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %s", got)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		tls    bool
+		want   bool
+	}{
+		{"http://localhost:1700", false, true},
+		{"HTTP://LocalHost:1700", false, true},
+		{"https://localhost:1700", true, true},
+		{"https://localhost:1700", false, false},
+		{"http://localhost:1701", false, false},
+		{"https://evil.example", false, false},
+		{"null", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:1700/ws", nil)
+		if tt.tls {
+			req = httptest.NewRequest(http.MethodGet, "https://localhost:1700/ws", nil)
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := SameOrigin(req); got != tt.want {
+			t.Errorf("SameOrigin(origin %q, tls %v) = %v, want %v", tt.origin, tt.tls, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// Surge dashboard: the lists come from /api/v1, live updates from /events.
+"use strict";
+
+const API = "/api/v1";
+const GRAPH_POINTS = 120; // Seconds of speed history kept per download
+const ACTIVE = new Set(["downloading"]);
+const QUEUED = new Set(["queued", "scheduled", "paused", "error", "verify_failed"]);
+
+const downloads = new Map(); // id -> DownloadStatus, kept current by events
+const speeds = new Map(); // id -> bytes/s samples, newest last
+const chunks = new Map(); // id -> {bitmap: Uint8Array, width: number}
+let history = [];
+let totalSpeeds = [];
+let selected = null;
+let refreshTimer = null;
+let token = null; // Bearer token for API calls; the session cookie alone does not authorize them
+
+const $ = (id) => document.getElementById(id);
+
+// --- API -------------------------------------------------------------------
+
+async function signIn() {
+  const resp = await fetch("/ui/session", { cache: "no-store" });
+  if (!resp.ok || resp.redirected) {
+    location.href = "/ui/login";
+    throw new Error("Signed out");
+  }
+  token = (await resp.json()).token;
+}
+
+async function api(method, path, body) {
+  if (token === null) await signIn();
+  const opts = { method, headers: { Authorization: "Bearer " + token } };
+  if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  const resp = await fetch(API + path, opts);
+  if (resp.status === 401) {
+    location.href = "/ui/login";
+    throw new Error("Signed out");
+  }
+  if (!resp.ok) {
+    let message = resp.statusText;
+    try {
+      message = (await resp.json()).error.message;
+    } catch (_) {
+      // Not a JSON error body
+    }
+    throw new Error(message);
+  }
+  return resp.status === 204 ? null : resp.json();
+}
+
+async function refresh() {
+  const [list, hist] = await Promise.all([
+    api("GET", "/downloads?limit=500"),
+    api("GET", "/history?limit=100"),
+  ]);
+  downloads.clear();
+  for (const d of list.items) downloads.set(d.id, d);
+  history = hist.items
+    .filter((e) => e.status === "completed")
+    .sort((a, b) => b.completed_at - a.completed_at);
+  render();
+}
+
+// Coalesce the refreshes triggered by bursts of events
+function scheduleRefresh() {
+  clearTimeout(refreshTimer);
+  refreshTimer = setTimeout(() => refresh().catch(console.error), 300);
+}
+
+// --- Events ----------------------------------------------------------------
+
+function connectEvents() {
+  const source = new EventSource("/events");
+  source.onopen = () => {
+    $("connection").textContent = "live";
+    $("connection").classList.add("live");
+    scheduleRefresh();
+  };
+  source.onerror = () => {
+    $("connection").textContent = "reconnecting…";
+    $("connection").classList.remove("live");
+  };
+  source.addEventListener("progress", (e) => onProgress(JSON.parse(e.data)));
+  for (const name of ["started", "complete", "error", "paused", "resumed", "queued", "scheduled", "removed"]) {
+    source.addEventListener(name, scheduleRefresh);
+  }
+}
+
+function onProgress(msg) {
+  const d = downloads.get(msg.DownloadID);
+  if (!d) {
+    scheduleRefresh();
+    return;
+  }
+  d.downloaded = msg.Downloaded;
+  if (msg.Total > 0) {
+    d.total_size = msg.Total;
+    d.progress = (msg.Downloaded / msg.Total) * 100;
+  }
+  d.speed = msg.Speed / (1024 * 1024);
+  d.connections = msg.ActiveConnections;
+  d.eta = msg.Speed > 0 && msg.Total > 0 ? Math.round((msg.Total - msg.Downloaded) / msg.Speed) : 0;
+  d.status = "downloading";
+  if (msg.ChunkBitmap && msg.BitmapWidth > 0) {
+    chunks.set(d.id, { bitmap: decodeBase64(msg.ChunkBitmap), width: msg.BitmapWidth });
+  }
+  updateRow(d);
+}
+
+// Sample speeds once a second so graphs advance even between events
+function sample() {
+  let total = 0;
+  for (const d of downloads.values()) {
+    const bps = d.status === "downloading" ? (d.speed || 0) * 1024 * 1024 : 0;
+    total += bps;
+    push(speeds, d.id, bps);
+  }
+  totalSpeeds.push(total);
+  if (totalSpeeds.length > GRAPH_POINTS) totalSpeeds.shift();
+  $("total-speed").textContent = formatBytes(total) + "/s";
+  drawGraph($("speed-graph"), totalSpeeds);
+  if (selected) renderDetails();
+}
+
+function push(map, id, value) {
+  const samples = map.get(id) || [];
+  samples.push(value);
+  if (samples.length > GRAPH_POINTS) samples.shift();
+  map.set(id, samples);
+}
+
+// --- Rendering -------------------------------------------------------------
+
+function render() {
+  const all = [...downloads.values()].sort((a, b) => a.added_at - b.added_at);
+  fillTable("active", all.filter((d) => ACTIVE.has(d.status)), downloadRow);
+  fillTable("queued", all.filter((d) => QUEUED.has(d.status)), downloadRow);
+  fillTable("completed", history, historyRow);
+  if (selected && !downloads.has(selected)) selectDownload(null);
+}
+
+function fillTable(id, items, rowFn) {
+  const body = $(id).tBodies[0];
+  body.replaceChildren(...items.map(rowFn));
+  if (items.length === 0) {
+    body.append(el("tr", {}, el("td", { className: "empty" }, "Nothing here")));
+  }
+  $("count-" + id).textContent = items.length ? String(items.length) : "";
+}
+
+function downloadRow(d) {
+  const row = el("tr", { className: "download status-" + d.status, id: "row-" + d.id });
+  row.addEventListener("click", () => selectDownload(d.id));
+  fillRow(row, d);
+  return row;
+}
+
+function fillRow(row, d) {
+  const pct = Math.max(0, Math.min(100, d.progress || 0));
+  const bar = el("div", { className: "bar" }, el("div", { style: `width:${pct}%` }));
+  let info = d.status;
+  if (d.status === "downloading") {
+    info = `${formatBytes((d.speed || 0) * 1024 * 1024)}/s · ${formatETA(d.eta)}`;
+  } else if (d.error) {
+    info = d.error;
+  } else if (d.pause_reason) {
+    info = `paused: ${d.pause_reason}`;
+  }
+
+  const actions = el("td", { className: "actions" });
+  if (d.status === "downloading" || d.status === "queued" || d.status === "scheduled") {
+    actions.append(button("Pause", () => setStatus(d.id, "paused")));
+  } else if (d.status !== "completed") {
+    actions.append(button("Resume", () => setStatus(d.id, "downloading")));
+  }
+  actions.append(button("Delete", () => remove(d), "secondary"));
+
+  row.replaceChildren(
+    el("td", { className: "name", title: d.url }, d.filename || d.url),
+    el("td", {}, bar),
+    el("td", { className: "num" }, `${formatBytes(d.downloaded)} / ${d.total_size > 0 ? formatBytes(d.total_size) : "?"}`),
+    el("td", { className: "num" }, info),
+    actions,
+  );
+}
+
+function updateRow(d) {
+  const row = document.getElementById("row-" + d.id);
+  if (row && row.closest("table").id === "active") {
+    fillRow(row, d);
+  } else {
+    render();
+  }
+}
+
+function historyRow(e) {
+  const row = el("tr", {});
+  row.append(
+    el("td", { className: "name", title: e.url }, e.filename || e.url),
+    el("td", { className: "num" }, formatBytes(e.total_size)),
+    el("td", { className: "num" }, e.completed_at ? new Date(e.completed_at * 1000).toLocaleString() : ""),
+    el("td", { className: "num" }, e.time_taken ? formatDuration(e.time_taken / 1000) : ""),
+  );
+  return row;
+}
+
+function selectDownload(id) {
+  selected = id;
+  $("details").hidden = id === null;
+  if (id !== null) renderDetails();
+}
+
+function renderDetails() {
+  const d = downloads.get(selected);
+  if (!d) return;
+  $("details-name").textContent = d.filename || d.url;
+  $("details-url").textContent = d.url;
+  drawGraph($("details-graph"), speeds.get(d.id) || []);
+  drawChunks($("chunk-map"), chunks.get(d.id), d);
+}
+
+function drawGraph(canvas, samples) {
+  const ctx = canvas.getContext("2d");
+  const { width, height } = canvas;
+  ctx.clearRect(0, 0, width, height);
+  if (samples.length < 2) return;
+  const max = Math.max(...samples, 1);
+  const step = width / (GRAPH_POINTS - 1);
+  const x0 = width - step * (samples.length - 1);
+  ctx.beginPath();
+  ctx.moveTo(x0, height);
+  samples.forEach((v, i) => ctx.lineTo(x0 + i * step, height - (v / max) * (height - 4)));
+  ctx.lineTo(width, height);
+  ctx.closePath();
+  ctx.fillStyle = cssVar("--accent") + "40";
+  ctx.fill();
+  ctx.strokeStyle = cssVar("--accent");
+  ctx.lineWidth = 1.5;
+  ctx.stroke();
+}
+
+// The bitmap holds 2 bits per chunk, 4 chunks per byte: 0 pending, 1 downloading, 2 done
+function drawChunks(canvas, map, d) {
+  const ctx = canvas.getContext("2d");
+  const { width, height } = canvas;
+  ctx.clearRect(0, 0, width, height);
+  const colors = [cssVar("--pending"), cssVar("--downloading"), cssVar("--completed"), cssVar("--completed")];
+  if (!map) {
+    // No chunk data yet: show overall progress
+    ctx.fillStyle = colors[0];
+    ctx.fillRect(0, 0, width, height);
+    ctx.fillStyle = colors[2];
+    ctx.fillRect(0, 0, (width * (d.progress || 0)) / 100, height);
+    return;
+  }
+  const rows = 4;
+  const perRow = Math.ceil(map.width / rows);
+  const cellW = width / perRow;
+  const cellH = height / rows;
+  for (let i = 0; i < map.width; i++) {
+    const state = (map.bitmap[i >> 2] >> ((i & 3) * 2)) & 3;
+    ctx.fillStyle = colors[state];
+    ctx.fillRect((i % perRow) * cellW, Math.floor(i / perRow) * cellH, Math.max(cellW - 1, 1), cellH - 2);
+  }
+}
+
+// --- Actions ---------------------------------------------------------------
+
+async function setStatus(id, status) {
+  try {
+    await api("PATCH", "/downloads/" + encodeURIComponent(id), { status });
+  } catch (err) {
+    alert(err.message);
+  }
+  scheduleRefresh();
+}
+
+async function remove(d) {
+  if (!confirm(`Delete ${d.filename || d.url}?`)) return;
+  try {
+    await api("DELETE", "/downloads/" + encodeURIComponent(d.id));
+  } catch (err) {
+    alert(err.message);
+  }
+  scheduleRefresh();
+}
+
+$("add-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const form = e.target;
+  const req = { url: form.url.value.trim(), skip_approval: true };
+  if (form.filename.value.trim()) req.filename = form.filename.value.trim();
+  if (form.path.value.trim()) req.path = form.path.value.trim();
+  $("add-error").hidden = true;
+  try {
+    await api("POST", "/downloads", req);
+    form.reset();
+  } catch (err) {
+    $("add-error").textContent = err.message;
+    $("add-error").hidden = false;
+  }
+  scheduleRefresh();
+});
+
+$("details-close").addEventListener("click", () => selectDownload(null));
+
+// --- Helpers ---------------------------------------------------------------
+
+function el(tag, props, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(props)) {
+    if (key === "style") node.style.cssText = value;
+    else node[key] = value;
+  }
+  node.append(...children);
+  return node;
+}
+
+function button(label, onClick, extraClass) {
+  const b = el("button", { type: "button", className: "small " + (extraClass || "") }, label);
+  b.addEventListener("click", (e) => {
+    e.stopPropagation();
+    onClick();
+  });
+  return b;
+}
+
+function cssVar(name) {
+  return getComputedStyle(document.documentElement).getPropertyValue(name).trim();
+}
+
+function decodeBase64(s) {
+  const bin = atob(s);
+  const out = new Uint8Array(bin.length);
+  for (let i = 0; i < bin.length; i++) out[i] = bin.charCodeAt(i);
+  return out;
+}
+
+function formatBytes(n) {
+  if (!n || n < 0) return "0 B";
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return `${n.toFixed(i === 0 ? 0 : 1)} ${units[i]}`;
+}
+
+function formatDuration(seconds) {
+  seconds = Math.round(seconds);
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  const s = seconds % 60;
+  if (h > 0) return `${h}h ${m}m`;
+  if (m > 0) return `${m}m ${s}s`;
+  return `${s}s`;
+}
+
+function formatETA(seconds) {
+  return seconds > 0 ? formatDuration(seconds) + " left" : "–";
+}
+
+refresh().catch(console.error);
+connectEvents();
+setInterval(sample, 1000);
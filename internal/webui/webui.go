@@ -0,0 +1,41 @@
+// Package webui embeds the browser dashboard the daemon can serve next to its API.
+// The dashboard talks to the daemon through /api/v1 and /events only, sending
+// its token as a bearer token on API calls.
+package webui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Prefix is the URL path the dashboard is served under
+const Prefix = "/ui/"
+
+// LoginPath is the sign-in page, reachable without a token
+const LoginPath = Prefix + "login"
+
+// SessionPath hands the signed-in dashboard its token for API calls
+const SessionPath = Prefix + "session"
+
+//go:embed static
+var static embed.FS
+
+// files holds the dashboard's files at their URL paths below Prefix
+var files, _ = fs.Sub(static, "static")
+
+// Handler serves the dashboard's files below Prefix
+func Handler() http.Handler {
+	fileServer := http.StripPrefix(Prefix, http.FileServerFS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Pick up a new build after a daemon upgrade
+		w.Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// ServeLogin writes the sign-in page
+func ServeLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFileFS(w, r, files, "login.html")
+}
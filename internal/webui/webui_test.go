@@ -0,0 +1,53 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ServesEmbeddedFiles(t *testing.T) {
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{Prefix, "text/html", `src="/ui/app.js"`},
+		{Prefix + "app.js", "javascript", "new EventSource"},
+		{Prefix + "style.css", "text/css", "--accent"},
+	}
+	h := Handler()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d", tt.path, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", ct, tt.contentType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.contains) {
+				t.Errorf("Body lacks %q", tt.contains)
+			}
+			if rec.Header().Get("Cache-Control") != "no-cache" {
+				t.Errorf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Prefix+"missing.js", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Missing file = %d, want 404", rec.Code)
+	}
+}
+
+func TestServeLogin(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ServeLogin(rec, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `action="/ui/login"`) {
+		t.Errorf("Login page = %d %s", rec.Code, rec.Body.String())
+	}
+}